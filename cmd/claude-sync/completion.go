@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+func completionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion bash|zsh|fish|powershell",
+		Short: "Generate a shell completion script",
+		Long: `Print a completion script for your shell. Besides commands and flags, it
+completes the paths of synced files for commands that take one (cat,
+history, restore, ls, pause, resume, conflicts resolve), from this device's
+sync state, without connecting to storage.
+
+Bash (needs the bash-completion package):
+  source <(claude-sync completion bash)
+  # or once, for every session:
+  claude-sync completion bash > /etc/bash_completion.d/claude-sync   # Linux
+  claude-sync completion bash > $(brew --prefix)/etc/bash_completion.d/claude-sync   # macOS
+
+Zsh:
+  # If completion isn't enabled yet:
+  echo "autoload -U compinit; compinit" >> ~/.zshrc
+  claude-sync completion zsh > "${fpath[1]}/_claude-sync"
+
+Fish:
+  claude-sync completion fish > ~/.config/fish/completions/claude-sync.fish
+
+PowerShell:
+  claude-sync completion powershell | Out-String | Invoke-Expression
+  # Add that line to your profile to load it in every session.
+
+Start a new shell for the change to take effect.`,
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(os.Stdout, true)
+			case "zsh":
+				return root.GenZshCompletion(os.Stdout)
+			case "fish":
+				return root.GenFishCompletion(os.Stdout, true)
+			case "powershell":
+				return root.GenPowerShellCompletionWithDesc(os.Stdout)
+			}
+			return fmt.Errorf("unsupported shell %q", args[0])
+		},
+	}
+}
+
+// completeSyncPaths completes the first argument with the paths this device
+// tracks in its sync state.
+func completeSyncPaths(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	state, err := sync.LoadState()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	paths := make([]string, 0, len(state.Files))
+	for path := range state.Files {
+		paths = append(paths, path)
+	}
+	return pathCompletions(paths, toComplete)
+}
+
+// completePausedPaths completes the first argument with the paths that are
+// paused.
+func completePausedPaths(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	state, err := sync.LoadState()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var paths []string
+	for _, p := range state.PausedPaths(time.Now()) {
+		paths = append(paths, p.Path)
+	}
+	return pathCompletions(paths, toComplete)
+}
+
+// completeConflictPaths completes the first argument with the files that
+// have conflicts.
+func completeConflictPaths(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	state, err := sync.LoadState()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	conflicts, err := sync.FindConflicts(config.ClaudeDir(), state)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var paths []string
+	for _, c := range conflicts {
+		paths = append(paths, c.Path)
+	}
+	return pathCompletions(paths, toComplete)
+}
+
+// pathCompletions returns the paths that continue toComplete, cut after the
+// next '/' so a directory is offered before the files in it.
+func pathCompletions(paths []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	seen := make(map[string]bool)
+	var completions []string
+	directive := cobra.ShellCompDirectiveNoFileComp
+	for _, path := range paths {
+		if !strings.HasPrefix(path, toComplete) {
+			continue
+		}
+		if i := strings.Index(path[len(toComplete):], "/"); i >= 0 {
+			path = path[:len(toComplete)+i+1]
+			directive |= cobra.ShellCompDirectiveNoSpace
+		}
+		if !seen[path] {
+			seen[path] = true
+			completions = append(completions, path)
+		}
+	}
+	sort.Strings(completions)
+	return completions, directive
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestPathCompletions(t *testing.T) {
+	paths := []string{"agents/b.md", "CLAUDE.md", "agents/a.md", "projects/x/1.jsonl", "settings.json"}
+
+	got, directive := pathCompletions(paths, "")
+	if want := []string{"CLAUDE.md", "agents/", "projects/", "settings.json"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("top level = %v, want %v", got, want)
+	}
+	if directive&cobra.ShellCompDirectiveNoSpace == 0 {
+		t.Error("directories offered without NoSpace")
+	}
+
+	got, directive = pathCompletions(paths, "agents/")
+	if want := []string{"agents/a.md", "agents/b.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("agents/ = %v, want %v", got, want)
+	}
+	if directive != cobra.ShellCompDirectiveNoFileComp {
+		t.Errorf("directive = %v, want NoFileComp only", directive)
+	}
+
+	if got, _ = pathCompletions(paths, "proj"); !reflect.DeepEqual(got, []string{"projects/"}) {
+		t.Errorf("proj = %v, want [projects/]", got)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+func TestConflictOpenCommand(t *testing.T) {
+	const local = "/home/u/.claude/CLAUDE.md"
+	const remote = "/home/u/.claude/CLAUDE.md.conflict.20260208-095132"
+
+	tests := []struct {
+		name     string
+		goos     string
+		editor   string
+		wantName string
+		wantArgs []string
+	}{
+		{"vim diff mode", "linux", "vim", "vim", []string{"-d", local, remote}},
+		{"nvim with path", "darwin", "/usr/local/bin/nvim", "/usr/local/bin/nvim", []string{"-d", local, remote}},
+		{"vscode keeps flags", "linux", "code --wait", "code", []string{"--wait", "--diff", local, remote}},
+		{"unknown editor gets both files", "linux", "nano", "nano", []string{local, remote}},
+		{"plain vi has no diff mode", "linux", "vi", "vi", []string{local, remote}},
+		{"macOS reveal", "darwin", "", "open", []string{"-R", local, remote}},
+		{"windows explorer", "windows", "", "explorer", []string{"/select," + remote}},
+		{"linux file manager", "linux", "", "xdg-open", []string{"/home/u/.claude"}},
+		{"unsupported platform", "plan9", "", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, args := conflictOpenCommand(tt.goos, tt.editor, local, remote)
+			if name != tt.wantName {
+				t.Errorf("name = %q, want %q", name, tt.wantName)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestClaudeRelPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	claudeDir := filepath.Join(home, ".claude")
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "settings.json", want: "settings.json"},
+		{in: "agents/../agents/a.md", want: "agents/a.md"},
+		{in: filepath.Join(claudeDir, "agents", "a.md"), want: "agents/a.md"},
+		{in: "~/.claude/CLAUDE.md", want: "CLAUDE.md"},
+		{in: filepath.Join(home, "elsewhere.md"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := claudeRelPath(claudeDir, tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("claudeRelPath(%q) = %q, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("claudeRelPath(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestConflictTime(t *testing.T) {
+	defer func() { utcTimes, isoTimes = false, false }()
+	detected := time.Date(2026, 2, 8, 9, 51, 32, 0, time.UTC)
+	c := sync.Conflict{Timestamp: "20260208-095132", DetectedAt: detected}
+
+	utcTimes, isoTimes = true, true
+	if got := conflictTime(c); got != "2026-02-08T09:51:32Z" {
+		t.Errorf("--utc --iso: got %q", got)
+	}
+	isoTimes = false
+	if got := conflictTime(c); !strings.HasPrefix(got, "2026-02-08 09:51 UTC (") || !strings.HasSuffix(got, " ago)") {
+		t.Errorf("--utc: got %q", got)
+	}
+	if got := conflictTime(sync.Conflict{Timestamp: "garbled"}); got != "garbled" {
+		t.Errorf("Expected the raw timestamp when unparsed, got %q", got)
+	}
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+
+	"github.com/tawanorg/claude-sync/internal/diff"
+	"github.com/tawanorg/claude-sync/internal/sync"
+	"github.com/tawanorg/claude-sync/internal/util"
+)
+
+// The conflict TUI is a bubbletea model: key presses update a conflictTUI
+// and the screen is redrawn from View(). The diff body scrolls in a
+// bubbles viewport, which also handles the scrolling keys.
+
+// conflictPane is the per-conflict state of the TUI.
+type conflictPane struct {
+	conflict  sync.Conflict
+	loaded    bool
+	loadErr   error
+	binary    bool
+	diff      *diff.Diff
+	rows      []diff.Row
+	hunkRows  []int  // First row index of each hunk
+	useRemote []bool // Per-hunk choice; a single entry for binary files
+	hunk      int    // Selected hunk
+	offset    int    // Scroll position, kept while other conflicts are shown
+	done      string // How the conflict was resolved, "" while pending
+}
+
+type conflictTUI struct {
+	claudeDir string
+	panes     []*conflictPane
+	current   int
+	width     int
+	height    int
+	body      viewport.Model
+	status    string
+	resolved  int
+	quitting  bool
+
+	resolve func(c sync.Conflict, choice sync.Resolution, merged []byte) error
+}
+
+// pairOpenedMsg reports that the editor (or file manager) opened from the
+// TUI has returned.
+type pairOpenedMsg struct {
+	pane *conflictPane
+	err  error
+}
+
+const conflictTUIHelp = "←/→ keep local/remote  tab/⇧tab hunk  L/R all  enter apply  o open  [/] file  s skip  q quit"
+
+func newConflictTUI(conflicts []sync.Conflict, claudeDir string, resolve func(sync.Conflict, sync.Resolution, []byte) error) *conflictTUI {
+	t := &conflictTUI{claudeDir: claudeDir, resolve: resolve}
+	for _, c := range conflicts {
+		t.panes = append(t.panes, &conflictPane{conflict: c})
+	}
+	t.load(t.pane())
+	t.resize(80, 24)
+	return t
+}
+
+func (t *conflictTUI) pane() *conflictPane {
+	return t.panes[t.current]
+}
+
+// load reads both versions and computes the diff. A missing local file is
+// treated as empty so the remote version can still be taken hunk by hunk.
+func (p *conflictPane) load(claudeDir string) {
+	p.loaded, p.loadErr, p.binary = true, nil, false
+	p.diff, p.rows, p.hunkRows = nil, nil, nil
+	local, err := os.ReadFile(filepath.Join(claudeDir, filepath.FromSlash(p.conflict.Path)))
+	if err != nil && !os.IsNotExist(err) {
+		p.loadErr = err
+		return
+	}
+	remote, err := os.ReadFile(filepath.Join(claudeDir, filepath.FromSlash(p.conflict.ConflictPath)))
+	if err != nil {
+		p.loadErr = err
+		return
+	}
+
+	if diff.IsBinary(local) || diff.IsBinary(remote) {
+		p.binary = true
+		p.useRemote = []bool{false}
+		return
+	}
+
+	p.diff = diff.Compute(local, remote)
+	p.rows = p.diff.Rows()
+	p.useRemote = make([]bool, len(p.diff.Hunks))
+	p.hunkRows = make([]int, len(p.diff.Hunks))
+	for i := len(p.rows) - 1; i >= 0; i-- {
+		if h := p.rows[i].Hunk; h >= 0 {
+			p.hunkRows[h] = i
+		}
+	}
+	p.hunk, p.offset = 0, 0
+}
+
+// load (re)loads p and, if it is showing, scrolls its first hunk into view.
+func (t *conflictTUI) load(p *conflictPane) {
+	p.load(t.claudeDir)
+	if p == t.pane() {
+		t.selectHunk(0)
+	}
+}
+
+// resolution turns the per-hunk choices into a resolution. Uniform choices
+// map to keeping one whole file; anything mixed becomes merged content.
+func (p *conflictPane) resolution() (sync.Resolution, []byte) {
+	remote := 0
+	for _, r := range p.useRemote {
+		if r {
+			remote++
+		}
+	}
+	switch {
+	case remote == 0:
+		return sync.KeepLocal, nil
+	case remote == len(p.useRemote):
+		return sync.KeepRemote, nil
+	default:
+		return sync.KeepMerged, p.diff.Merge(p.useRemote)
+	}
+}
+
+// resize fits the screen to a terminal of width by height cells.
+func (t *conflictTUI) resize(width, height int) {
+	t.width, t.height = width, height
+	// Three header lines, two footer lines
+	t.body.Width, t.body.Height = width, max(height-5, 1)
+	t.selectHunk(t.pane().hunk)
+	t.refresh()
+}
+
+// refresh renders the current pane into the viewport, keeping its scroll
+// position.
+func (t *conflictTUI) refresh() {
+	p := t.pane()
+	t.body.SetContent(strings.Join(t.bodyLines(p, (t.width-4)/2), "\n"))
+	t.body.SetYOffset(p.offset)
+	p.offset = t.body.YOffset
+}
+
+// selectHunk moves the cursor to hunk i and scrolls it into view, leaving a
+// few lines of context above it.
+func (t *conflictTUI) selectHunk(i int) {
+	p := t.pane()
+	if i < 0 || i >= len(p.hunkRows) {
+		return
+	}
+	p.hunk = i
+	row := p.hunkRows[i]
+	if row < p.offset || row >= p.offset+t.body.Height {
+		p.offset = row - t.body.Height/3
+	}
+}
+
+func (t *conflictTUI) choose(remote bool) {
+	p := t.pane()
+	if len(p.useRemote) == 0 {
+		return
+	}
+	p.useRemote[p.hunk] = remote
+	t.selectHunk(p.hunk + 1)
+}
+
+func (t *conflictTUI) chooseAll(remote bool) {
+	for i := range t.pane().useRemote {
+		t.pane().useRemote[i] = remote
+	}
+}
+
+// switchTo shows conflict i, loading its diff on first visit.
+func (t *conflictTUI) switchTo(i int) {
+	if i < 0 || i >= len(t.panes) {
+		return
+	}
+	t.current = i
+	if !t.pane().loaded {
+		t.load(t.pane())
+	}
+}
+
+// nextPending moves to the next unresolved conflict after the current one,
+// wrapping around. It reports false when none are left.
+func (t *conflictTUI) nextPending() bool {
+	for step := 1; step <= len(t.panes); step++ {
+		i := (t.current + step) % len(t.panes)
+		if t.panes[i].done == "" {
+			t.switchTo(i)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *conflictTUI) apply() {
+	p := t.pane()
+	if p.done != "" {
+		t.status = "Already resolved"
+		return
+	}
+	if p.loadErr != nil {
+		t.status = "Cannot resolve: " + p.loadErr.Error()
+		return
+	}
+
+	choice, merged := p.resolution()
+	if err := t.resolve(p.conflict, choice, merged); err != nil {
+		t.status = "Error: " + err.Error()
+		return
+	}
+
+	switch choice {
+	case sync.KeepLocal:
+		p.done = "kept local"
+	case sync.KeepRemote:
+		p.done = "kept remote"
+	default:
+		p.done = "merged"
+	}
+	t.resolved++
+	t.status = fmt.Sprintf("%s: %s", p.conflict.Path, p.done)
+	if !t.nextPending() {
+		t.quitting = true
+	}
+}
+
+// Init implements tea.Model.
+func (t *conflictTUI) Init() tea.Cmd {
+	return nil
+}
+
+// Update implements tea.Model.
+func (t *conflictTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		t.resize(msg.Width, msg.Height)
+		return t, nil
+	case pairOpenedMsg:
+		if msg.err != nil {
+			t.status = "Error: " + msg.err.Error()
+		}
+		// The files may have been edited
+		t.load(msg.pane)
+		t.refresh()
+		return t, nil
+	case tea.KeyMsg:
+		cmd := t.handleKey(msg)
+		if t.quitting {
+			return t, tea.Quit
+		}
+		t.refresh()
+		return t, cmd
+	}
+	return t, nil
+}
+
+// handleKey applies one key press. Keys the resolver doesn't use go to the
+// viewport, which scrolls on ↑/↓, j/k, PgUp/PgDn, space, b/f and u/d.
+func (t *conflictTUI) handleKey(msg tea.KeyMsg) tea.Cmd {
+	t.status = ""
+	p := t.pane()
+	switch msg.String() {
+	case "q", "esc", "ctrl+c":
+		t.quitting = true
+	case "home", "g":
+		p.offset = 0
+	case "end", "G":
+		p.offset = len(p.rows)
+	case "tab", "n":
+		t.selectHunk(p.hunk + 1)
+	case "shift+tab", "p":
+		t.selectHunk(p.hunk - 1)
+	case "left", "h":
+		t.choose(false)
+	case "right", "l":
+		t.choose(true)
+	case "L":
+		t.chooseAll(false)
+	case "R":
+		t.chooseAll(true)
+	case "enter", "w":
+		t.apply()
+	case "]":
+		t.switchTo(t.current + 1)
+	case "[":
+		t.switchTo(t.current - 1)
+	case "s":
+		if !t.nextPending() {
+			t.status = "No other pending conflicts"
+		}
+	case "o":
+		if p.done == "" {
+			return tea.Exec(&openPairCommand{claudeDir: t.claudeDir, conflict: p.conflict}, func(err error) tea.Msg {
+				return pairOpenedMsg{pane: p, err: err}
+			})
+		}
+	default:
+		t.body.SetYOffset(p.offset)
+		t.body, _ = t.body.Update(msg)
+		p.offset = t.body.YOffset
+	}
+	return nil
+}
+
+// View implements tea.Model, rendering the full screen as exactly t.height
+// lines.
+func (t *conflictTUI) View() string {
+	if t.quitting {
+		return ""
+	}
+	p := t.pane()
+	var lines []string
+
+	title := fmt.Sprintf("%s[%d/%d]%s %s%s%s", colorCyan, t.current+1, len(t.panes), colorReset, colorBold, p.conflict.Path, colorReset)
+	if p.done != "" {
+		title += fmt.Sprintf("  %s✓ %s%s", colorGreen, p.done, colorReset)
+	}
+	lines = append(lines, title)
+
+	info := fmt.Sprintf("Local: %s  |  Remote: %s", util.FormatSize(p.conflict.LocalSize), util.FormatSize(p.conflict.RemoteSize))
+	if p.conflict.LocalMissing {
+		info = fmt.Sprintf("Local: deleted  |  Remote: %s", util.FormatSize(p.conflict.RemoteSize))
+	}
+	if origin := describeConflictOrigin(p.conflict); origin != "" {
+		info += "  |  " + origin
+	}
+	if n := len(p.useRemote); n > 0 && !p.binary {
+		info += fmt.Sprintf("  |  hunk %d/%d", p.hunk+1, n)
+	}
+	lines = append(lines, colorDim+truncateCell(info, t.width)+colorReset)
+
+	colW := (t.width - 4) / 2
+	lines = append(lines, fmt.Sprintf(" %s%s%s │ %s%s%s", colorBold, padCell("Local", colW), colorReset, colorBold, padCell("Remote (conflict)", colW), colorReset))
+
+	lines = append(lines, t.body.View())
+	lines = append(lines, colorDim+truncateCell(conflictTUIHelp, t.width)+colorReset)
+	lines = append(lines, t.status)
+	return strings.Join(lines, "\n")
+}
+
+// bodyLines renders every row of p's diff, or a note when there is no diff
+// to show; the viewport shows the part that fits.
+func (t *conflictTUI) bodyLines(p *conflictPane, colW int) []string {
+	switch {
+	case p.loadErr != nil:
+		return []string{fmt.Sprintf("%s✗%s Could not read conflict: %v", colorYellow, colorReset, p.loadErr)}
+	case p.binary:
+		side := "local"
+		if p.useRemote[0] {
+			side = "remote"
+		}
+		return []string{
+			"Binary file: no line diff available.",
+			fmt.Sprintf("Will keep the %s%s%s version (←/→ to change, enter to apply).", colorBold, side, colorReset),
+		}
+	case len(p.rows) == 0:
+		return []string{"Both versions are empty."}
+	case len(p.useRemote) == 0:
+		return []string{"Both versions are identical; enter resolves the conflict."}
+	}
+
+	out := make([]string, 0, len(p.rows))
+	textW := colW - 5 // Line number column
+	for _, row := range p.rows {
+		left := numberedCell(row.LocalNo, row.Local, textW)
+		right := numberedCell(row.RemoteNo, row.Remote, textW)
+		if row.Hunk < 0 {
+			out = append(out, " "+left+" │ "+right)
+			continue
+		}
+
+		gutter := " "
+		if row.Hunk == p.hunk {
+			gutter = colorCyan + "▌" + colorReset
+		}
+		leftColor, rightColor := colorGreen, colorDim
+		if p.useRemote[row.Hunk] {
+			leftColor, rightColor = colorDim, colorGreen
+		}
+		out = append(out, gutter+leftColor+left+colorReset+" │ "+rightColor+right+colorReset)
+	}
+	return out
+}
+
+func numberedCell(n int, text string, width int) string {
+	num := "    "
+	if n > 0 {
+		num = fmt.Sprintf("%4d", n)
+	}
+	return num + " " + padCell(text, width)
+}
+
+// sanitizeCell makes file content safe to paint: tabs become spaces and
+// control characters (including ESC, which could drive the terminal) are
+// replaced.
+func sanitizeCell(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\t':
+			b.WriteString("    ")
+		case r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0):
+			b.WriteRune('·')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func truncateCell(s string, width int) string {
+	s = sanitizeCell(s)
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + "…"
+}
+
+func padCell(s string, width int) string {
+	s = truncateCell(s, width)
+	if n := utf8.RuneCountInString(s); n < width {
+		s += strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// openPairCommand opens a conflicting pair from the TUI through tea.Exec,
+// which hands the terminal to the editor while it runs.
+type openPairCommand struct {
+	claudeDir string
+	conflict  sync.Conflict
+}
+
+func (c *openPairCommand) Run() error {
+	return openConflictPair(c.claudeDir, c.conflict)
+}
+
+// openConflictPair uses the process's own stdio, which are the program's.
+func (c *openPairCommand) SetStdin(io.Reader)  {}
+func (c *openPairCommand) SetStdout(io.Writer) {}
+func (c *openPairCommand) SetStderr(io.Writer) {}
+
+// runConflictTUI takes over the terminal until every conflict is resolved or
+// the user quits, and returns the number resolved. State is updated in
+// memory; the caller saves it.
+func runConflictTUI(conflicts []sync.Conflict, claudeDir string, state *sync.SyncState) (int, error) {
+	t := newConflictTUI(conflicts, claudeDir, func(c sync.Conflict, choice sync.Resolution, merged []byte) error {
+		return sync.ResolveConflict(claudeDir, state, c, choice, merged)
+	})
+	if _, err := tea.NewProgram(t, tea.WithAltScreen()).Run(); err != nil {
+		return t.resolved, fmt.Errorf("conflict resolver failed: %w", err)
+	}
+	return t.resolved, nil
+}
+
+// tuiResolveConflicts is the terminal front end for interactive resolution.
+func tuiResolveConflicts(conflicts []sync.Conflict, claudeDir string, state *sync.SyncState) error {
+	resolved, err := runConflictTUI(conflicts, claudeDir, state)
+	if resolved > 0 {
+		if saveErr := state.Save(); saveErr != nil {
+			fmt.Printf("%s⚠%s Warning: failed to save state: %v\n", colorYellow, colorReset, saveErr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s✓%s Resolved %d of %d conflict(s)\n", colorGreen, colorReset, resolved, len(conflicts))
+	return nil
+}
+
+// isInteractiveTerminal reports whether both stdin and stdout are terminals,
+// which the full-screen resolver needs, and input isn't disabled.
+func isInteractiveTerminal() bool {
+	return !inputDisabled() && term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+type recordedResolution struct {
+	path   string
+	choice sync.Resolution
+	merged string
+}
+
+func newTestConflictTUI(t *testing.T, files map[string]string, conflicts []sync.Conflict) (*conflictTUI, *[]recordedResolution) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []recordedResolution
+	tui := newConflictTUI(conflicts, dir, func(c sync.Conflict, choice sync.Resolution, merged []byte) error {
+		got = append(got, recordedResolution{c.Path, choice, string(merged)})
+		return nil
+	})
+	return tui, &got
+}
+
+// press sends key presses to the TUI the way bubbletea delivers them.
+func press(tui *conflictTUI, keys ...string) {
+	for _, key := range keys {
+		var msg tea.KeyMsg
+		switch key {
+		case "tab":
+			msg.Type = tea.KeyTab
+		case "right":
+			msg.Type = tea.KeyRight
+		case "down":
+			msg.Type = tea.KeyDown
+		case "enter":
+			msg.Type = tea.KeyEnter
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		}
+		tui.Update(msg)
+	}
+}
+
+func TestConflictTUIHunkSelection(t *testing.T) {
+	files := map[string]string{
+		"CLAUDE.md":                            "# Notes\nlocal one\nshared\nlocal two\n",
+		"CLAUDE.md.conflict.20260208-095132":   "# Notes\nremote one\nshared\nremote two\n",
+		"agents/a.md":                          "a\n",
+		"agents/a.md.conflict.20260208-095133": "b\n",
+	}
+	conflicts := []sync.Conflict{
+		{Path: "CLAUDE.md", ConflictPath: "CLAUDE.md.conflict.20260208-095132"},
+		{Path: "agents/a.md", ConflictPath: "agents/a.md.conflict.20260208-095133"},
+	}
+	tui, got := newTestConflictTUI(t, files, conflicts)
+
+	// Take the second hunk from remote: skip the first, pick right on the second
+	press(tui, "tab", "right", "enter")
+	// Second file: keep all remote
+	press(tui, "R", "enter")
+
+	want := []recordedResolution{
+		{"CLAUDE.md", sync.KeepMerged, "# Notes\nlocal one\nshared\nremote two\n"},
+		{"agents/a.md", sync.KeepRemote, ""},
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("Resolutions = %+v, want %+v", *got, want)
+	}
+	if !tui.quitting || tui.resolved != 2 {
+		t.Errorf("Expected TUI to finish after resolving everything, quitting=%v resolved=%d", tui.quitting, tui.resolved)
+	}
+}
+
+func TestConflictTUINavigation(t *testing.T) {
+	files := map[string]string{
+		"a.md":                          "x\n",
+		"a.md.conflict.20260208-095132": "y\n",
+		"b.md":                          "x\n",
+		"b.md.conflict.20260208-095132": "y\n",
+	}
+	conflicts := []sync.Conflict{
+		{Path: "a.md", ConflictPath: "a.md.conflict.20260208-095132"},
+		{Path: "b.md", ConflictPath: "b.md.conflict.20260208-095132"},
+	}
+	tui, got := newTestConflictTUI(t, files, conflicts)
+
+	press(tui, "s")
+	if tui.current != 1 {
+		t.Fatalf("Expected skip to move to the second conflict, at %d", tui.current)
+	}
+	press(tui, "enter") // Keep local for b.md
+	if tui.current != 0 || tui.quitting {
+		t.Fatalf("Expected to wrap back to the pending first conflict, at %d quitting=%v", tui.current, tui.quitting)
+	}
+	press(tui, "]", "enter")
+	if tui.status != "Already resolved" || len(*got) != 1 {
+		t.Errorf("Expected resolved conflict not to be applied twice, status=%q", tui.status)
+	}
+	if _, cmd := tui.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")}); cmd == nil || !tui.quitting {
+		t.Error("Expected q to quit")
+	}
+}
+
+func TestConflictTUIViewSanitizes(t *testing.T) {
+	files := map[string]string{
+		"a.md":                          "safe\n",
+		"a.md.conflict.20260208-095132": "evil \x1b[2Jline\n",
+	}
+	conflicts := []sync.Conflict{{Path: "a.md", ConflictPath: "a.md.conflict.20260208-095132"}}
+	tui, _ := newTestConflictTUI(t, files, conflicts)
+	tui.Update(tea.WindowSizeMsg{Width: 60, Height: 10})
+
+	view := tui.View()
+	if strings.Contains(view, "\x1b[2J") {
+		t.Error("Expected escape sequences in file content to be neutralized")
+	}
+	if n := strings.Count(view, "\n") + 1; n != tui.height {
+		t.Errorf("View has %d lines, want %d", n, tui.height)
+	}
+	if !strings.Contains(view, "safe") || !strings.Contains(view, "evil") {
+		t.Errorf("Expected both sides in view:\n%s", view)
+	}
+}
+
+func TestConflictTUIScrolls(t *testing.T) {
+	var local, remote strings.Builder
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&local, "line %d\n", i)
+		fmt.Fprintf(&remote, "line %d\n", i)
+	}
+	local.WriteString("local end\n")
+	remote.WriteString("remote end\n")
+	files := map[string]string{
+		"a.md":                          local.String(),
+		"a.md.conflict.20260208-095132": remote.String(),
+	}
+	conflicts := []sync.Conflict{{Path: "a.md", ConflictPath: "a.md.conflict.20260208-095132"}}
+	tui, _ := newTestConflictTUI(t, files, conflicts)
+	tui.Update(tea.WindowSizeMsg{Width: 60, Height: 15})
+
+	// The only hunk is scrolled into view
+	if tui.body.YOffset == 0 || !strings.Contains(tui.View(), "remote end") {
+		t.Fatalf("Expected the hunk in view, offset %d:\n%s", tui.body.YOffset, tui.View())
+	}
+	press(tui, "g", "down", "down")
+	if tui.body.YOffset != 2 {
+		t.Errorf("Offset after g, down, down = %d, want 2", tui.body.YOffset)
+	}
+	if strings.Contains(tui.View(), "remote end") {
+		t.Error("Expected the hunk out of view after scrolling to the top")
+	}
+}
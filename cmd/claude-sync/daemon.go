@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/notify"
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+// runSyncCommand runs one 'claude-sync <op> -q [paths]' for the daemon,
+// replaced in tests. Prompts fail instead of waiting: nobody is there to
+// answer. A sync run by hand is waited for rather than failing the round.
+var runSyncCommand = func(ctx context.Context, execPath, op string, paths ...string) error {
+	args := append([]string{op, "-q", "--wait", daemonLockWait.String(), "--"}, paths...)
+	c := exec.CommandContext(ctx, execPath, args...)
+	c.Env = append(os.Environ(), noInputEnv+"=1")
+	c.Stdout, c.Stderr = os.Stdout, os.Stderr
+	return c.Run()
+}
+
+// checkStale looks for local changes left unpushed past stale_after, from
+// this device's state alone; replaced in tests.
+var checkStale = func() (*sync.StaleWarning, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	syncer, err := sync.NewOfflineSyncer(cfg, true)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := syncer.Status(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return syncer.CheckStale(changes, time.Now())
+}
+
+// watchScanInterval is how often watch mode scans for changed files,
+// replaced in tests.
+var watchScanInterval = 2 * time.Second
+
+// maxBatchPaths caps the paths a watch-mode push names; a bigger batch
+// pushes everything instead, keeping the command line short.
+const maxBatchPaths = 100
+
+func daemonCmd() *cobra.Command {
+	var interval, debounce, maxBatchDelay time.Duration
+	var notifyDesktop, watch bool
+	var metricsAddr string
+
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Pull and push on a schedule, honoring sync windows",
+		Long: `Run 'claude-sync pull -q' and then 'claude-sync push -q' every --interval
+until stopped, so paths limited by sync_windows still sync once their window
+opens, even with no Claude Code session running to trigger the auto-sync
+hooks. Run it in the foreground under launchd, systemd or a terminal
+multiplexer. A failed run is logged and tried again at the next interval;
+when the pull fails, that run's push is skipped.
+
+Conflicts and failed runs also show a desktop notification (Notification
+Center on macOS, notify-send on Linux), so problems don't go unnoticed in
+the background. A failure is notified when it starts or changes, not again
+at every interval; --notify=false turns this off. With a desktop channel in
+the notify config, that channel notifies instead.
+
+--metrics-addr serves Prometheus metrics at /metrics on that address: runs
+and their duration, files and bytes transferred, errors, conflicts, and when
+each of pull and push last succeeded. Counters start from zero with the
+daemon.
+
+With ping_url in the config, each round ends with a request to it, or to
+ping_url + "/fail" when the pull or push failed, for cron monitors such as
+Healthchecks.io that alert when pings stop or fail. A pull that saved
+conflicts counts as a success.
+
+Local changes still unpushed after stale_after (14 days by default), say
+because every push has failed, are notified too, once until they're pushed.
+
+--watch also pushes local changes between rounds, soon after they're made.
+It scans the sync paths every few seconds and batches what changed: a burst
+of writes, like Claude Code appending to session files, is pushed once no
+file in it has changed for --debounce. A burst that goes on is pushed every
+--max-batch-delay, without the files written in the last --debounce, which
+are probably still being written and follow in a later batch.
+
+Examples:
+  claude-sync daemon                 # Every 15 minutes
+  claude-sync daemon --interval 1h
+  claude-sync daemon --notify=false  # Output only
+  claude-sync daemon --metrics-addr 127.0.0.1:9464
+  claude-sync daemon --watch --debounce 10s`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if interval < time.Minute {
+				return fmt.Errorf("--interval must be at least 1m")
+			}
+			if watch && (debounce <= 0 || maxBatchDelay < debounce) {
+				return fmt.Errorf("--debounce must be positive and no longer than --max-batch-delay")
+			}
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if err := sync.CheckSyncWindows(cfg.SyncWindows); err != nil {
+				return err
+			}
+			if err := cfg.CheckPingURL(); err != nil {
+				return err
+			}
+			execPath, err := currentExecutable()
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			d := &daemon{execPath: execPath, interval: interval, pingURL: cfg.PingURL}
+			if notifyDesktop && !hasDesktopNotifier(cfg) {
+				if d.notifier, err = notify.NewDesktop(config.NotifierConfig{Type: "desktop"}); err != nil && cmd.Flags().Changed("notify") {
+					printWarning(err.Error())
+				}
+			}
+			if d.configNotifier, err = sync.NewNotifier(cfg.Notify); err != nil {
+				return err
+			}
+			if metricsAddr != "" {
+				d.metrics = newDaemonMetrics()
+				if err := serveMetrics(ctx, metricsAddr, d.metrics); err != nil {
+					return err
+				}
+			}
+
+			if watch {
+				d.watcher = sync.NewWatcher(cfg)
+				d.batch = sync.NewPushBatch(debounce, maxBatchDelay)
+			}
+
+			printInfo(fmt.Sprintf("Syncing every %s; stop with Ctrl-C", interval))
+			if watch {
+				fmt.Printf("  %sPushing local changes as they settle (after %s quiet, at most every %s)%s\n", colorDim, debounce, maxBatchDelay, colorReset)
+			}
+			for _, w := range cfg.SyncWindows {
+				fmt.Printf("  %s%s only between %s and %s%s\n", colorDim, strings.Join(w.Paths, ", "), w.Start, w.End, colorReset)
+			}
+			if metricsAddr != "" {
+				fmt.Printf("  %sMetrics at http://%s/metrics%s\n", colorDim, metricsAddr, colorReset)
+			}
+			return d.run(ctx)
+		},
+	}
+
+	cmd.Flags().DurationVar(&interval, "interval", 15*time.Minute, "Time between syncs")
+	cmd.Flags().BoolVar(&notifyDesktop, "notify", true, "Show a desktop notification for conflicts and failed runs")
+	cmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "Serve Prometheus metrics at /metrics on this address (e.g. 127.0.0.1:9464)")
+	cmd.Flags().BoolVar(&watch, "watch", false, "Also push local changes between rounds, in batches")
+	cmd.Flags().DurationVar(&debounce, "debounce", 5*time.Second, "With --watch, push once files have stopped changing for this long")
+	cmd.Flags().DurationVar(&maxBatchDelay, "max-batch-delay", time.Minute, "With --watch, push ongoing changes at least this often")
+
+	return cmd
+}
+
+// daemon pulls and pushes every interval; see daemonCmd.
+type daemon struct {
+	execPath string
+	interval time.Duration
+	notifier sync.Notifier  // Told about conflicts and failures, if set
+	metrics  *daemonMetrics // Counts runs, if set
+	pingURL  string         // Requested after each round, if set
+
+	// In watch mode, local changes are pushed between rounds
+	watcher *sync.Watcher
+	batch   *sync.PushBatch
+
+	// The notify config's channels, told about what only the daemon sees:
+	// the sync commands it runs notify them of the rest
+	configNotifier sync.Notifier
+
+	// The last exit code notified for each op, so an outage lasting many
+	// intervals is notified once
+	notified map[string]int
+	// Whether the unpushed changes were notified as stale, likewise
+	staleNotified bool
+}
+
+// run syncs every interval until ctx is done.
+func (d *daemon) run(ctx context.Context) error {
+	for {
+		failed := false
+		for _, op := range []string{"pull", "push"} {
+			began := time.Now()
+			err := runSyncCommand(ctx, d.execPath, op)
+			if err != nil && ctx.Err() != nil {
+				break
+			}
+			code := runExitCode(err)
+			d.finished(ctx, op, code, began)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s %s✗%s %s failed: %v\n", time.Now().Format(time.DateTime), colorYellow, colorReset, op, err)
+				failed = code != exitConflicts
+				break
+			}
+		}
+		if d.pingURL != "" && ctx.Err() == nil {
+			if err := pingMonitor(ctx, d.pingURL, failed); err != nil {
+				fmt.Fprintf(os.Stderr, "%s %s!%s %v\n", time.Now().Format(time.DateTime), colorYellow, colorReset, err)
+			}
+		}
+		if ctx.Err() == nil {
+			d.checkStale(ctx)
+		}
+		if !d.wait(ctx) {
+			return nil
+		}
+	}
+}
+
+// wait returns true when it's time for the next round, or false when ctx is
+// done. In watch mode it pushes local changes meanwhile.
+func (d *daemon) wait(ctx context.Context) bool {
+	next := time.After(d.interval)
+	if d.watcher == nil {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-next:
+			return true
+		}
+	}
+
+	// The round pushed what had changed and its pull wrote files that
+	// mustn't be pushed straight back, so watching starts afresh
+	if _, err := d.watcher.Scan(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s!%s Watching failed: %v\n", time.Now().Format(time.DateTime), colorYellow, colorReset, err)
+	}
+	d.batch.Clear()
+
+	ticker := time.NewTicker(watchScanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-next:
+			return true
+		case now := <-ticker.C:
+			changed, err := d.watcher.Scan()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s %s!%s Watching failed: %v\n", now.Format(time.DateTime), colorYellow, colorReset, err)
+				continue
+			}
+			d.batch.Add(changed, now)
+			if paths := d.batch.Take(now); len(paths) > 0 {
+				d.pushBatch(ctx, paths)
+			}
+		}
+	}
+}
+
+// pushBatch pushes the changes at paths that watch mode collected.
+func (d *daemon) pushBatch(ctx context.Context, paths []string) {
+	if len(paths) > maxBatchPaths {
+		paths = nil
+		d.batch.Clear()
+	}
+	began := time.Now()
+	err := runSyncCommand(ctx, d.execPath, "push", paths...)
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	d.finished(ctx, "push", runExitCode(err), began)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s✗%s push failed: %v\n", time.Now().Format(time.DateTime), colorYellow, colorReset, err)
+	}
+}
+
+// finished reports a run of op that began at began and exited with code.
+func (d *daemon) finished(ctx context.Context, op string, code int, began time.Time) {
+	entry := runActivity(op, began)
+	if d.metrics != nil {
+		d.metrics.record(op, code, time.Since(began), entry)
+	}
+	// A run that failed before syncing, on a held lock or a missing key say,
+	// left the status file as it was
+	if entry == nil && code != exitOK && code != exitConflicts {
+		err := sync.UpdateSyncStatus(func(st *sync.SyncStatus) {
+			st.LastError = fmt.Sprintf("%s exited with code %d", op, code)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s %s!%s Failed to update the status file: %v\n", time.Now().Format(time.DateTime), colorYellow, colorReset, err)
+		}
+	}
+
+	if d.notifier != nil && code != exitOK && d.notified[op] != code {
+		if err := d.notifier.Notify(ctx, daemonEvent(op, code)); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %s!%s Notification failed: %v\n", time.Now().Format(time.DateTime), colorYellow, colorReset, err)
+		}
+	}
+	if d.notified == nil {
+		d.notified = make(map[string]int)
+	}
+	d.notified[op] = code
+}
+
+// checkStale notifies local changes left unpushed past stale_after, once
+// until they're pushed.
+func (d *daemon) checkStale(ctx context.Context) {
+	stale, err := checkStale()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s!%s Checking for unpushed changes failed: %v\n", time.Now().Format(time.DateTime), colorYellow, colorReset, err)
+		return
+	}
+	if stale == nil || d.staleNotified {
+		d.staleNotified = stale != nil
+		return
+	}
+	d.staleNotified = true
+
+	days := int(time.Since(stale.LastPush).Hours() / 24)
+	event := sync.Event{
+		Kind:    sync.EventStale,
+		Time:    time.Now(),
+		Message: fmt.Sprintf("%d change(s) unpushed and no push in %d days; run 'claude-sync push' to keep your devices in step", stale.Pending, days),
+	}
+	event.Device, _ = os.Hostname()
+	fmt.Fprintf(os.Stderr, "%s %s!%s %s\n", event.Time.Format(time.DateTime), colorYellow, colorReset, event.Message)
+	for _, n := range []sync.Notifier{d.notifier, d.configNotifier} {
+		if n == nil {
+			continue
+		}
+		if err := n.Notify(ctx, event); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %s!%s Notification failed: %v\n", time.Now().Format(time.DateTime), colorYellow, colorReset, err)
+		}
+	}
+}
+
+// pingClient makes the monitoring pings; a hung monitor mustn't hold up
+// the next sync for long.
+var pingClient = &http.Client{Timeout: 10 * time.Second}
+
+// pingMonitor requests pingURL, with "/fail" added to its path when the
+// round failed, the way Healthchecks.io and compatible monitors expect.
+func pingMonitor(ctx context.Context, pingURL string, failed bool) error {
+	u, err := url.Parse(pingURL)
+	if err != nil {
+		return fmt.Errorf("invalid ping_url: %w", err)
+	}
+	if failed {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/fail"
+		u.RawPath = ""
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("invalid ping_url: %w", err)
+	}
+	resp, err := pingClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ping failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("ping returned %s", resp.Status)
+	}
+	return nil
+}
+
+// runExitCode is the exit code of a finished sync command, from the error
+// runSyncCommand returned; exitError when it didn't run at all.
+func runExitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var exited interface{ ExitCode() int }
+	if errors.As(err, &exited) && exited.ExitCode() > 0 {
+		return exited.ExitCode()
+	}
+	return exitError
+}
+
+// daemonEvent describes a sync command that exited with code.
+func daemonEvent(op string, code int) sync.Event {
+	event := sync.Event{Kind: sync.EventError, Time: time.Now(), Operation: op}
+	switch code {
+	case exitConflicts:
+		event.Kind = sync.EventConflict
+		event.Message = "Pull saved conflicts; run 'claude-sync conflicts' to resolve them"
+	case exitAuth:
+		event.Message = "Storage refused the credentials; run 'claude-sync doctor'"
+	case exitKeyMismatch:
+		event.Message = "This device's key doesn't open the bucket"
+	case exitNotConfigured:
+		event.Message = "Not configured; run 'claude-sync init'"
+	case exitPartial:
+		event.Message = "Some files failed to " + op + "; see the daemon's output"
+	default:
+		event.Message = op + " failed; see the daemon's output"
+	}
+	return event
+}
+
+// hasDesktopNotifier reports whether the notify config already has a desktop
+// channel, which the sync commands the daemon runs notify through.
+func hasDesktopNotifier(cfg *config.Config) bool {
+	for _, n := range cfg.Notify {
+		if n.Type == "desktop" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+func TestRunDaemon(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	orig := runSyncCommand
+	defer func() { runSyncCommand = orig }()
+
+	tests := []struct {
+		name    string
+		failing string
+		want    []string
+	}{
+		{name: "pull then push", want: []string{"pull", "push"}},
+		{name: "failed pull skips push", failing: "pull", want: []string{"pull"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var ran []string
+			runSyncCommand = func(ctx context.Context, execPath, op string, paths ...string) error {
+				ran = append(ran, op)
+				// Stop after the first round
+				if op == tt.failing {
+					cancel()
+					return errors.New("offline")
+				}
+				if op == "push" {
+					cancel()
+				}
+				return nil
+			}
+
+			done := make(chan error, 1)
+			go func() { done <- (&daemon{execPath: "claude-sync", interval: time.Hour}).run(ctx) }()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("run() error = %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("run() didn't stop when its context was cancelled")
+			}
+			if !reflect.DeepEqual(ran, tt.want) {
+				t.Errorf("ran %v, want %v", ran, tt.want)
+			}
+		})
+	}
+}
+
+type exitCodeError int
+
+func (e exitCodeError) Error() string { return "exit status" }
+func (e exitCodeError) ExitCode() int { return int(e) }
+
+type recordingNotifier struct{ events []sync.Event }
+
+func (r *recordingNotifier) Notify(ctx context.Context, event sync.Event) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestRunDaemonNotifies(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	orig := runSyncCommand
+	defer func() { runSyncCommand = orig }()
+
+	// Each round's pull result: conflicts twice, then success, then offline
+	pulls := []error{exitCodeError(exitConflicts), exitCodeError(exitConflicts), nil, errors.New("offline"), nil}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	round := 0
+	runSyncCommand = func(ctx context.Context, execPath, op string, paths ...string) error {
+		if op == "push" {
+			return nil
+		}
+		err := pulls[round]
+		if round++; round == len(pulls) {
+			cancel()
+		}
+		return err
+	}
+
+	rec := &recordingNotifier{}
+	d := &daemon{execPath: "claude-sync", interval: time.Millisecond, notifier: rec}
+	if err := d.run(ctx); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	var kinds []sync.EventKind
+	for _, e := range rec.events {
+		kinds = append(kinds, e.Kind)
+	}
+	// The repeated conflict is notified once
+	if want := []sync.EventKind{sync.EventConflict, sync.EventError}; !reflect.DeepEqual(kinds, want) {
+		t.Errorf("Notified %v, want %v", kinds, want)
+	}
+
+	// The offline pull wrote no activity, so the daemon recorded it
+	st, err := sync.LoadSyncStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.LastError != "pull exited with code 1" {
+		t.Errorf("Status file error = %q", st.LastError)
+	}
+}
+
+func TestRunDaemonPings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	orig := runSyncCommand
+	defer func() { runSyncCommand = orig }()
+
+	var pings []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pings = append(pings, r.URL.RequestURI())
+	}))
+	defer srv.Close()
+
+	// Rounds: success, a failed pull, conflicts, then stop
+	pulls := []error{nil, errors.New("offline"), exitCodeError(exitConflicts), nil}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	round := 0
+	runSyncCommand = func(ctx context.Context, execPath, op string, paths ...string) error {
+		if op == "push" {
+			return nil
+		}
+		err := pulls[round]
+		if round++; round == len(pulls) {
+			cancel()
+		}
+		return err
+	}
+
+	d := &daemon{execPath: "claude-sync", interval: time.Millisecond, pingURL: srv.URL + "/abc-123?rid=x"}
+	if err := d.run(ctx); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if want := []string{"/abc-123?rid=x", "/abc-123/fail?rid=x", "/abc-123?rid=x"}; !reflect.DeepEqual(pings, want) {
+		t.Errorf("Pinged %v, want %v", pings, want)
+	}
+}
+
+func TestRunDaemonNotifiesStale(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	orig, origStale := runSyncCommand, checkStale
+	defer func() { runSyncCommand, checkStale = orig, origStale }()
+	runSyncCommand = func(ctx context.Context, execPath, op string, paths ...string) error { return nil }
+
+	// Each round's staleness: stale twice, pushed, then stale again
+	warning := &sync.StaleWarning{LastPush: time.Now().Add(-20 * 24 * time.Hour), Pending: 3}
+	stale := []*sync.StaleWarning{warning, warning, nil, warning}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	round := 0
+	checkStale = func() (*sync.StaleWarning, error) {
+		w := stale[round]
+		if round++; round == len(stale) {
+			cancel()
+		}
+		return w, nil
+	}
+
+	rec, configured := &recordingNotifier{}, &recordingNotifier{}
+	d := &daemon{execPath: "claude-sync", interval: time.Millisecond, notifier: rec, configNotifier: configured}
+	if err := d.run(ctx); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if len(rec.events) != 2 || len(configured.events) != 2 {
+		t.Fatalf("Expected each stale spell notified once on both, got %d and %d", len(rec.events), len(configured.events))
+	}
+	if e := rec.events[0]; e.Kind != sync.EventStale || !strings.Contains(e.Message, "3 change(s)") {
+		t.Errorf("Notified %+v", e)
+	}
+}
+
+func TestRunDaemonWatch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	orig, origScan := runSyncCommand, watchScanInterval
+	defer func() { runSyncCommand, watchScanInterval = orig, origScan }()
+	watchScanInterval = 10 * time.Millisecond
+
+	claudeDir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(claudeDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rounds := make(chan struct{}, 1)
+	batches := make(chan []string, 1)
+	runSyncCommand = func(ctx context.Context, execPath, op string, paths ...string) error {
+		switch {
+		case op == "pull":
+			// Files the round pulls aren't pushed back
+			write("settings.json", "{}")
+		case len(paths) == 0:
+			rounds <- struct{}{}
+		default:
+			batches <- paths
+			cancel()
+		}
+		return nil
+	}
+
+	d := &daemon{
+		execPath: "claude-sync",
+		interval: time.Hour,
+		watcher:  sync.NewWatcher(&config.Config{ClaudeDirOverride: claudeDir}),
+		batch:    sync.NewPushBatch(50*time.Millisecond, time.Second),
+	}
+	done := make(chan error, 1)
+	go func() { done <- d.run(ctx) }()
+
+	<-rounds
+	// Let watching start from the files the round left
+	time.Sleep(100 * time.Millisecond)
+	write("CLAUDE.md", "rules")
+	write("CLAUDE.md", "more rules")
+
+	select {
+	case paths := <-batches:
+		if want := []string{"CLAUDE.md"}; !reflect.DeepEqual(paths, want) {
+			t.Errorf("Pushed %v, want %v", paths, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Changed file never pushed")
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import "testing"
+
+func TestColorizeDiff(t *testing.T) {
+	got := colorizeDiff("--- local/a\n+++ remote/a\n@@ -1 +1 @@\n-old\n+new\n same\n")
+	want := colorBold + "--- local/a" + colorReset + "\n" +
+		colorBold + "+++ remote/a" + colorReset + "\n" +
+		colorCyan + "@@ -1 +1 @@" + colorReset + "\n" +
+		colorYellow + "-old" + colorReset + "\n" +
+		colorGreen + "+new" + colorReset + "\n" +
+		" same\n"
+	if got != want {
+		t.Errorf("colorizeDiff = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/storage"
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+// Clock skew doctor warns about, and fails at. S3-style signatures are
+// rejected once the clock is 15 minutes off.
+const (
+	skewWarn = time.Minute
+	skewFail = 5 * time.Minute
+)
+
+// doctorReport prints check results and counts the failures.
+type doctorReport struct {
+	failed, warned int
+}
+
+func (r *doctorReport) ok(format string, args ...any) {
+	fmt.Printf("%s✓%s %s\n", colorGreen, colorReset, fmt.Sprintf(format, args...))
+}
+
+// fail reports a failed check and how to fix it.
+func (r *doctorReport) fail(fix, format string, args ...any) {
+	r.failed++
+	fmt.Printf("%s✗%s %s\n", colorYellow, colorReset, fmt.Sprintf(format, args...))
+	if fix != "" {
+		fmt.Printf("  %s→ %s%s\n", colorDim, fix, colorReset)
+	}
+}
+
+// warn reports a problem that doesn't stop syncing.
+func (r *doctorReport) warn(fix, format string, args ...any) {
+	r.warned++
+	fmt.Printf("%s!%s %s\n", colorYellow, colorReset, fmt.Sprintf(format, args...))
+	if fix != "" {
+		fmt.Printf("  %s→ %s%s\n", colorDim, fix, colorReset)
+	}
+}
+
+func (r *doctorReport) skip(format string, args ...any) {
+	fmt.Printf("%s- %s%s\n", colorDim, fmt.Sprintf(format, args...), colorReset)
+}
+
+func doctorCmd() *cobra.Command {
+	var offline bool
+
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose setup problems and suggest fixes",
+		Long: `Check everything a sync depends on and say how to fix what is wrong:
+
+  - the Claude directory exists
+  - config.yaml loads, its settings are valid, and it isn't readable by others
+  - the encryption key is there
+  - the state file (~/.claude-sync/state.json) is readable
+  - the bucket can be reached
+  - the credentials can list, write, read and delete objects (with a small
+    object under _metadata/doctor/, deleted again)
+  - this machine's clock agrees with the storage server's
+  - the key opens the files already in the bucket
+
+With --offline, only the local checks run.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r := &doctorReport{}
+			runDoctor(r, offline)
+
+			fmt.Println()
+			if r.failed > 0 {
+				return fmt.Errorf("%d check(s) failed", r.failed)
+			}
+			if r.warned > 0 {
+				fmt.Printf("%s✓%s No problems that stop syncing (%d warning(s))\n", colorGreen, colorReset, r.warned)
+				return nil
+			}
+			fmt.Printf("%s✓%s Everything looks good\n", colorGreen, colorReset)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&offline, "offline", false, "Skip the checks that connect to storage")
+	return cmd
+}
+
+func runDoctor(r *doctorReport, offline bool) {
+	if claudeDir, err := config.ClaudeDirE(); err != nil {
+		r.fail("Set HOME, or point claude_dir (or CLAUDE_SYNC_CLAUDE_DIR) at the Claude directory", "Claude directory: %v", err)
+	} else if info, err := os.Stat(claudeDir); err != nil || !info.IsDir() {
+		r.fail("Start Claude Code once to create it, or set claude_dir if it lives elsewhere", "Claude directory %s not found", claudeDir)
+	} else {
+		r.ok("Claude directory %s", claudeDir)
+	}
+
+	if !config.Exists() {
+		r.fail("Run 'claude-sync init'", "No config at %s", config.ConfigFilePath())
+		return
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		r.fail("Fix the file by hand, or run 'claude-sync init --force' to start over", "Config can't be loaded: %v", err)
+		return
+	}
+	problems := configProblems(cfg)
+	for _, problem := range problems {
+		r.fail(fmt.Sprintf("Fix it with 'claude-sync config set' or in %s", config.ConfigFilePath()), "%v", problem)
+	}
+	if len(problems) == 0 {
+		r.ok("Config is valid (%s)", cfg.GetStorageConfig().Provider)
+	}
+	if info, err := os.Stat(config.ConfigFilePath()); err == nil && runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		r.warn(fmt.Sprintf("chmod 600 %s", config.ConfigFilePath()), "Config is readable by other users (mode %04o) and may hold credentials", info.Mode().Perm())
+	}
+
+	checkStateFile(r)
+
+	if offline {
+		r.skip("Storage checks skipped (--offline)")
+		return
+	}
+	storageCfg := cfg.GetStorageConfig()
+	if storageCfg.Validate() != nil {
+		r.skip("Storage checks skipped until the storage settings are fixed")
+		return
+	}
+	store, err := storage.New(storageCfg)
+	if err != nil {
+		r.fail("Check the storage settings with 'claude-sync config get storage'", "Storage client: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	exists, err := store.BucketExists(ctx)
+	switch {
+	case err != nil:
+		r.fail("Check the network, the endpoint and the credentials; run with -v to see the requests", "Bucket %s can't be reached: %v", storageCfg.Bucket, err)
+		return
+	case !exists:
+		r.fail("Create the bucket, or fix storage.bucket with 'claude-sync config set storage.bucket NAME'", "Bucket %s does not exist", storageCfg.Bucket)
+		return
+	}
+	r.ok("Bucket %s is reachable", storageCfg.Bucket)
+
+	checkAccess(ctx, r, store)
+	checkRemoteKey(ctx, r, cfg, store)
+}
+
+func checkStateFile(r *doctorReport) {
+	state, err := sync.CheckStateFile(config.StateFilePath())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		r.ok("No state file yet; the first push or pull creates it")
+	case err != nil:
+		r.warn("The next push or pull sets it aside and rescans; nothing on the remote is lost", "State file: %v", err)
+	default:
+		line := fmt.Sprintf("State file tracks %d file(s)", len(state.Files))
+		if last := state.LastPush; state.LastPull.After(last) {
+			line += ", last synced " + formatTime(state.LastPull)
+		} else if !last.IsZero() {
+			line += ", last synced " + formatTime(last)
+		}
+		r.ok("%s", line)
+	}
+}
+
+func checkAccess(ctx context.Context, r *doctorReport, store storage.Storage) {
+	device, _ := os.Hostname()
+	probe := sync.ProbeStorage(ctx, store, device)
+	permission := func(op string, done bool, err error) {
+		switch {
+		case done:
+			r.ok("Credentials can %s objects", op)
+		case err != nil:
+			r.fail(fmt.Sprintf("Give the credentials permission to %s objects in the bucket (on R2, an 'Object Read & Write' token)", op),
+				"Credentials can't %s objects: %v", op, err)
+		}
+	}
+	permission("list", probe.Listed, probe.ListErr)
+	permission("write", probe.Wrote, probe.WriteErr)
+	permission("read", probe.Read, probe.ReadErr)
+	permission("delete", probe.Deleted, probe.DeleteErr)
+
+	if !probe.SkewKnown {
+		return
+	}
+	skew := probe.ClockSkew.Round(time.Second)
+	abs := max(skew, -skew)
+	switch {
+	case abs >= skewFail:
+		r.fail("Set the clock from the network (enable NTP); storage rejects requests once it's 15 minutes off", "Clock is %s off the storage server's", abs)
+	case abs >= skewWarn:
+		r.warn("Set the clock from the network (enable NTP); conflict detection compares times across devices", "Clock is %s off the storage server's", abs)
+	default:
+		r.ok("Clock agrees with the storage server")
+	}
+}
+
+func checkRemoteKey(ctx context.Context, r *doctorReport, cfg *config.Config, store storage.Storage) {
+	if !crypto.KeyExists(cfg.EncryptionKey) {
+		return
+	}
+	if cfg.DeviceKeys {
+		device, err := crypto.NewEncryptor(cfg.EncryptionKey)
+		var registry *sync.Registry
+		if err == nil {
+			registry, err = sync.LoadRegistry(ctx, store, device)
+		}
+		if err == nil && registry == nil {
+			err = fmt.Errorf("the bucket has no device registry")
+		}
+		if err != nil {
+			r.fail("Run 'claude-sync device init' here, then 'claude-sync device add' on a registered device", "This device's key doesn't open the device registry: %v", err)
+			return
+		}
+		r.ok("This device is registered with the bucket")
+		return
+	}
+	if err := verifyKeyMatchesRemote(ctx, store, cfg.Encryption, cfg.EncryptionKey); err != nil {
+		r.fail("Run 'claude-sync init --passphrase' with the passphrase your other devices use, or copy their key file", "Key doesn't open the files in the bucket: %v", err)
+		return
+	}
+	r.ok("Key opens the files in the bucket")
+}
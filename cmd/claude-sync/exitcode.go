@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/storage"
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+// Exit codes. Scripts depend on them, so never renumber one; the README
+// lists them.
+const (
+	exitOK            = 0
+	exitError         = 1 // Anything not listed below
+	exitConflicts     = 2 // A pull finished but saved conflicts
+	exitAuth          = 3 // Storage refused the credentials
+	exitKeyMismatch   = 4 // The key doesn't open the bucket
+	exitPartial       = 5 // Some files failed; the rest synced
+	exitNotConfigured = 6 // No config yet; run 'claude-sync init'
+	exitLocked        = 7 // Another claude-sync process is syncing
+)
+
+// errKeyMismatch is init's answer when the key can't decrypt the remote.
+var errKeyMismatch = errors.New("encryption key mismatch - cannot sync with remote")
+
+// exitStatus ends a command that did its work but must exit non-zero, like a
+// pull that saved conflicts. Its output already says why, so cobra prints
+// nothing more for it (see quietExitStatus).
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// quietExitStatus makes cmd and its subcommands end without cobra's error
+// message and usage when they return an exitStatus.
+func quietExitStatus(cmd *cobra.Command) {
+	if run := cmd.RunE; run != nil {
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args)
+			var status exitStatus
+			if errors.As(err, &status) {
+				cmd.SilenceErrors, cmd.SilenceUsage = true, true
+			}
+			return err
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		quietExitStatus(sub)
+	}
+}
+
+// exitCode is the process exit code for err, the error a command returned.
+func exitCode(err error) int {
+	var status exitStatus
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &status):
+		return int(status)
+	case isKeyMismatch(err):
+		return exitKeyMismatch
+	case storage.IsAuthError(err):
+		return exitAuth
+	case errors.Is(err, config.ErrNotConfigured):
+		return exitNotConfigured
+	case errors.As(err, new(*sync.ProcessLockedError)):
+		return exitLocked
+	}
+	return exitError
+}
+
+// isKeyMismatch reports whether err means this device's key isn't the one
+// the bucket is encrypted to.
+func isKeyMismatch(err error) bool {
+	var mismatch *sync.KeyMismatchError
+	return errors.As(err, &mismatch) ||
+		errors.Is(err, errKeyMismatch) ||
+		errors.Is(err, sync.ErrCanaryMismatch) ||
+		errors.Is(err, sync.ErrKeyringLocked) ||
+		errors.Is(err, sync.ErrNotRegistered) ||
+		crypto.IsWrongKey(err)
+}
+
+// syncStatus is what push and pull return after reporting result: nil when
+// everything synced, otherwise an exitStatus. Failed files outrank
+// conflicts, and when every failure has the same cause (the wrong key,
+// refused credentials) the exit code names it.
+func syncStatus(result *sync.SyncResult) error {
+	if result == nil {
+		return nil
+	}
+	if len(result.Errors) > 0 {
+		code := exitCode(result.Errors[0])
+		for _, err := range result.Errors[1:] {
+			if exitCode(err) != code {
+				code = exitError
+				break
+			}
+		}
+		if code == exitError {
+			code = exitPartial
+		}
+		return exitStatus(code)
+	}
+	if len(result.Conflicts) > 0 {
+		return exitStatus(exitConflicts)
+	}
+	return nil
+}
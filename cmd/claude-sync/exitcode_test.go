@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, exitOK},
+		{"other error", errors.New("boom"), exitError},
+		{"exit status", fmt.Errorf("pull: %w", exitStatus(exitConflicts)), exitConflicts},
+		{"not configured", config.ErrNotConfigured, exitNotConfigured},
+		{"fingerprint", &sync.KeyMismatchError{Local: "A", Remote: "B"}, exitKeyMismatch},
+		{"canary", fmt.Errorf("init: %w", sync.ErrCanaryMismatch), exitKeyMismatch},
+		{"init", errKeyMismatch, exitKeyMismatch},
+		{"auth", fmt.Errorf("failed to list: %w", apiError("InvalidAccessKeyId")), exitAuth},
+		{"locked", &sync.ProcessLockedError{PID: 42, Command: "push"}, exitLocked},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSyncStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *sync.SyncResult
+		want   int
+	}{
+		{"synced", &sync.SyncResult{Downloaded: []string{"a"}}, exitOK},
+		{"conflicts", &sync.SyncResult{Conflicts: []string{"a"}}, exitConflicts},
+		{"failed", &sync.SyncResult{Conflicts: []string{"a"}, Errors: []error{errors.New("timeout")}}, exitPartial},
+		{"auth", &sync.SyncResult{Errors: []error{apiError("AccessDenied"), apiError("AccessDenied")}}, exitAuth},
+		{"mixed", &sync.SyncResult{Errors: []error{apiError("AccessDenied"), errors.New("timeout")}}, exitPartial},
+	}
+	for _, tt := range tests {
+		if got := exitCode(syncStatus(tt.result)); got != tt.want {
+			t.Errorf("%s: exit code = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// apiError is an S3-style API error with a code.
+type apiError string
+
+func (e apiError) Error() string     { return "api error " + string(e) }
+func (e apiError) ErrorCode() string { return string(e) }
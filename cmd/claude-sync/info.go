@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/storage"
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+// infoJSON is 'claude-sync info --json'.
+type infoJSON struct {
+	DeviceID  string `json:"device_id"`
+	Config    string `json:"config"`
+	StateFile string `json:"state_file"`
+	ClaudeDir string `json:"claude_dir"`
+
+	Provider  string `json:"provider"`
+	Bucket    string `json:"bucket"`
+	Endpoint  string `json:"endpoint,omitempty"`
+	KeyPrefix string `json:"key_prefix,omitempty"`
+
+	// Scope and Hosts say which settings apply here: the sync scope and
+	// the hosts entries matching this machine
+	Scope string   `json:"scope"`
+	Hosts []string `json:"hosts,omitempty"`
+
+	Encryption     string `json:"encryption"`
+	KeyFile        string `json:"key_file"`
+	KeySource      string `json:"key_source"`
+	KeyFingerprint string `json:"key_fingerprint,omitempty"`
+}
+
+func infoCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "info",
+		Aliases: []string{"whoami"},
+		Short:   "Show this device's setup: bucket, device ID, key and file locations",
+		Long: `Print what this device is set up to sync with: the config and state file
+locations, the Claude directory, the storage provider and bucket, the
+device ID, the sync scope and the hosts entries that apply here, and where
+the key came from (random, a passphrase, an SSH key, ...) with its
+fingerprint. Compare the output of two devices to see why they don't sync.
+
+Nothing is read from storage. A key file protected by a passphrase isn't
+opened, so no fingerprint is shown for it; 'claude-sync key fingerprint'
+asks for the passphrase and checks the key against the bucket.
+
+Examples:
+  claude-sync info
+  claude-sync whoami --json | jq -r .key_fingerprint`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			state, err := sync.LoadState()
+			if err != nil {
+				return err
+			}
+
+			info := deviceInfo(cfg, state.DeviceID)
+			if jsonOutput {
+				return printJSON(info)
+			}
+
+			row := func(label, value string) {
+				if value != "" {
+					fmt.Printf("%-17s %s\n", label+":", value)
+				}
+			}
+			row("Device ID", info.DeviceID)
+			row("Provider", info.Provider)
+			row("Bucket", info.Bucket)
+			row("Endpoint", info.Endpoint)
+			row("Key prefix", info.KeyPrefix)
+			row("Scope", info.Scope)
+			row("Host overrides", strings.Join(info.Hosts, ", "))
+			fmt.Println()
+			row("Encryption", info.Encryption)
+			row("Key source", info.KeySource)
+			row("Key fingerprint", info.KeyFingerprint)
+			row("Key file", info.KeyFile)
+			fmt.Println()
+			row("Config", info.Config)
+			row("State file", info.StateFile)
+			row("Claude directory", info.ClaudeDir)
+			return nil
+		},
+	}
+}
+
+// deviceInfo gathers what info prints, without touching storage.
+func deviceInfo(cfg *config.Config, deviceID string) infoJSON {
+	storageCfg := cfg.GetStorageConfig()
+	info := infoJSON{
+		DeviceID:   deviceID,
+		Config:     config.ConfigFilePath(),
+		StateFile:  config.StateFilePath(),
+		ClaudeDir:  config.ClaudeDir(),
+		Provider:   string(storageCfg.Provider),
+		Bucket:     storageCfg.Bucket,
+		Endpoint:   storageCfg.Endpoint,
+		KeyPrefix:  storageCfg.KeyPrefix,
+		Scope:      cfg.Scope,
+		Encryption: cfg.Encryption,
+		KeyFile:    cfg.EncryptionKey,
+		KeySource:  keySource(cfg),
+	}
+	if storageCfg.Provider == storage.ProviderWebDAV {
+		info.Endpoint = storageCfg.WebDAVURL
+	}
+	if info.Scope == "" {
+		info.Scope = config.ScopeFull
+	}
+	if info.Encryption == "" {
+		info.Encryption = crypto.SchemeAge
+	}
+	for _, h := range cfg.HostOverrides() {
+		info.Hosts = append(info.Hosts, h.Host)
+	}
+
+	// Keys that need a passphrase or a plugin to open are left alone:
+	// without KeyPassphrase, opening a protected key fails instead of asking
+	if key := cfg.EncryptionKey; crypto.KeyExists(key) && !crypto.IsPluginKeyFile(key) {
+		askPassphrase := crypto.KeyPassphrase
+		crypto.KeyPassphrase = nil
+		if c, err := crypto.NewCipher(cfg.Encryption, key, nil); err == nil {
+			info.KeyFingerprint = c.KeyID()
+		}
+		crypto.KeyPassphrase = askPassphrase
+	}
+	return info
+}
+
+// keySource describes where cfg's key came from and how it is kept.
+func keySource(cfg *config.Config) string {
+	key := cfg.EncryptionKey
+	var source string
+	switch {
+	case !crypto.KeyExists(key):
+		return "missing (run 'claude-sync init')"
+	case crypto.IsPluginKeyFile(key):
+		source = "age plugin identity"
+	case crypto.IsSSHKeyFile(key):
+		source = "SSH key"
+	case crypto.IsScryptKeyFile(key):
+		source = "passphrase (age scrypt)"
+	case cfg.KDF != nil:
+		source = "passphrase (Argon2id)"
+	default:
+		// Passphrase keys from before KDF settings were recorded look the
+		// same as random ones
+		source = "random, or a passphrase with the default KDF settings"
+	}
+
+	var notes []string
+	if cfg.DeviceKeys {
+		notes = append(notes, "this device's own key; files use the registry's data key")
+	}
+	if cfg.Keyring {
+		notes = append(notes, "files use the keyring's data key")
+	}
+	if crypto.IsProtectedKeyFile(key) {
+		notes = append(notes, "file protected by a passphrase")
+	}
+	if crypto.IsKeychainKeyFile(key) {
+		notes = append(notes, "kept in the OS keychain")
+	}
+	if kms := cfg.GetStorageConfig().KMS; kms != nil {
+		notes = append(notes, "file keys also wrapped with "+kms.Provider+" KMS")
+	}
+	if len(notes) > 0 {
+		source += " (" + strings.Join(notes, "; ") + ")"
+	}
+	return source
+}
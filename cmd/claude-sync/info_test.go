@@ -0,0 +1,56 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+func TestDeviceInfo(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(config.EnvClaudeDir, "")
+	keyPath := filepath.Join(home, "age-key.txt")
+	if err := crypto.GenerateKey(keyPath); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := crypto.NewEncryptor(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Storage:       &storage.StorageConfig{Provider: storage.ProviderS3, Bucket: "team-bucket", KeyPrefix: "alice/"},
+		EncryptionKey: keyPath,
+	}
+	info := deviceInfo(cfg, "laptop")
+	if info.DeviceID != "laptop" || info.Provider != "s3" || info.Bucket != "team-bucket" || info.KeyPrefix != "alice/" {
+		t.Errorf("deviceInfo = %+v, want the device and storage settings", info)
+	}
+	if info.Scope != config.ScopeFull || info.Encryption != crypto.SchemeAge {
+		t.Errorf("Scope, Encryption = %q, %q, want the defaults", info.Scope, info.Encryption)
+	}
+	if info.KeyFingerprint != enc.Fingerprint() {
+		t.Errorf("KeyFingerprint = %q, want %q", info.KeyFingerprint, enc.Fingerprint())
+	}
+	if info.Config != filepath.Join(home, ".claude-sync", "config.yaml") || info.ClaudeDir != filepath.Join(home, ".claude") {
+		t.Errorf("Config, ClaudeDir = %q, %q", info.Config, info.ClaudeDir)
+	}
+	if !strings.HasPrefix(info.KeySource, "random") {
+		t.Errorf("KeySource = %q, want random", info.KeySource)
+	}
+
+	cfg.KDF = &crypto.KDFParams{}
+	cfg.Keyring = true
+	if got := keySource(cfg); got != "passphrase (Argon2id) (files use the keyring's data key)" {
+		t.Errorf("keySource = %q", got)
+	}
+	cfg.EncryptionKey = filepath.Join(home, "missing.txt")
+	if got := keySource(cfg); !strings.HasPrefix(got, "missing") {
+		t.Errorf("keySource without a key = %q, want missing", got)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+)
+
+// noInputEnv disables prompts like --no-input, for cron jobs and CI.
+const noInputEnv = "CLAUDE_SYNC_NO_INPUT"
+
+// noInput is set by --no-input.
+var noInput bool
+
+// inputDisabled reports whether prompts must fail instead of waiting for an
+// answer.
+func inputDisabled() bool {
+	if noInput {
+		return true
+	}
+	switch strings.ToLower(os.Getenv(noInputEnv)) {
+	case "", "0", "false", "no":
+		return false
+	}
+	return true
+}
+
+// noInputError is returned in place of asking message.
+func noInputError(message string) error {
+	message = strings.TrimSuffix(strings.TrimSpace(message), ":")
+	return fmt.Errorf("input is disabled (--no-input or %s), but this needs an answer to %q; pass the answer as a flag (e.g. --force) or run it interactively", noInputEnv, message)
+}
+
+// askOne is survey.AskOne, failing fast when input is disabled. Every
+// prompt goes through it or ask.
+func askOne(p survey.Prompt, response interface{}, opts ...survey.AskOpt) error {
+	if inputDisabled() {
+		return noInputError(promptMessage(p))
+	}
+	return survey.AskOne(p, response, opts...)
+}
+
+// ask is survey.Ask, failing fast when input is disabled.
+func ask(qs []*survey.Question, response interface{}, opts ...survey.AskOpt) error {
+	if inputDisabled() {
+		message := ""
+		if len(qs) > 0 {
+			message = promptMessage(qs[0].Prompt)
+		}
+		return noInputError(message)
+	}
+	return survey.Ask(qs, response, opts...)
+}
+
+func promptMessage(p survey.Prompt) string {
+	switch p := p.(type) {
+	case *survey.Confirm:
+		return p.Message
+	case *survey.Input:
+		return p.Message
+	case *survey.Password:
+		return p.Message
+	case *survey.Select:
+		return p.Message
+	case *survey.MultiSelect:
+		return p.Message
+	case *survey.Multiline:
+		return p.Message
+	case *survey.Editor:
+		return p.Message
+	}
+	return "a prompt"
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/AlecAivazis/survey/v2"
+)
+
+func TestInputDisabled(t *testing.T) {
+	for _, tc := range []struct {
+		env  string
+		want bool
+	}{
+		{"", false},
+		{"0", false},
+		{"false", false},
+		{"1", true},
+		{"yes", true},
+	} {
+		t.Setenv(noInputEnv, tc.env)
+		if got := inputDisabled(); got != tc.want {
+			t.Errorf("%s=%q: inputDisabled() = %v, want %v", noInputEnv, tc.env, got, tc.want)
+		}
+	}
+
+	t.Setenv(noInputEnv, "")
+	noInput = true
+	defer func() { noInput = false }()
+	if !inputDisabled() {
+		t.Error("Expected --no-input to disable input")
+	}
+}
+
+func TestAskOneFailsWithoutInput(t *testing.T) {
+	t.Setenv(noInputEnv, "1")
+
+	var confirmed bool
+	err := askOne(&survey.Confirm{Message: "Delete everything?"}, &confirmed)
+	if err == nil || !strings.Contains(err.Error(), "Delete everything?") {
+		t.Errorf("Expected an error naming the prompt, got %v", err)
+	}
+	if isInteractiveTerminal() {
+		t.Error("Expected no interactive terminal with input disabled")
+	}
+}
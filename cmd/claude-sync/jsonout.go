@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+// jsonOutput is set by --json.
+var jsonOutput bool
+
+// jsonAnnotation marks the commands that support --json.
+const jsonAnnotation = "json"
+
+// supportsJSON marks cmd as printing a JSON document with --json.
+func supportsJSON(cmd *cobra.Command) *cobra.Command {
+	if cmd.Annotations == nil {
+		cmd.Annotations = map[string]string{}
+	}
+	cmd.Annotations[jsonAnnotation] = "true"
+	return cmd
+}
+
+// setupJSONOutput checks that cmd supports --json and, if it's given,
+// silences the usual output and prompts so stdout holds only the document.
+func setupJSONOutput(cmd *cobra.Command) error {
+	if !jsonOutput {
+		return nil
+	}
+	if cmd.Annotations[jsonAnnotation] == "" {
+		return fmt.Errorf("'%s' has no --json output", cmd.CommandPath())
+	}
+	quiet = true
+	noInput = true
+	return nil
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// statusJSON is 'claude-sync status --json'.
+type statusJSON struct {
+	Changes  []sync.FileChange `json:"changes"`
+	Paused   []sync.PausedPath `json:"paused"`
+	LastPush time.Time         `json:"last_push,omitzero"`
+	LastPull time.Time         `json:"last_pull,omitzero"`
+
+	// StalePush is when this device last pushed, set when that was longer
+	// ago than stale_after and there are changes waiting
+	StalePush time.Time `json:"stale_push,omitzero"`
+
+	// Incoming is what the next pull would do, with --remote
+	Incoming *sync.PullPreview `json:"incoming,omitempty"`
+}
+
+// emptyIfNil returns list, or an empty list for nil, so it marshals as []
+// rather than null.
+func emptyIfNil[T any](list []T) []T {
+	if list == nil {
+		return []T{}
+	}
+	return list
+}
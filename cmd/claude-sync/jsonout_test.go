@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestSetupJSONOutput(t *testing.T) {
+	defer func(q, n bool) { jsonOutput, quiet, noInput = false, q, n }(quiet, noInput)
+
+	jsonOutput = true
+	if err := setupJSONOutput(&cobra.Command{Use: "gc"}); err == nil {
+		t.Error("--json on a command without JSON output succeeded")
+	}
+	if err := setupJSONOutput(supportsJSON(&cobra.Command{Use: "status"})); err != nil {
+		t.Fatal(err)
+	}
+	if !quiet || !noInput {
+		t.Error("--json left human output or prompts on")
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+// daemonLockWait is how long the daemon's runs wait for a sync started by
+// hand to finish before giving up on the round.
+const daemonLockWait = 10 * time.Minute
+
+// holdsLock makes cmd hold the process lock while it runs, so it never
+// changes the state or the bucket alongside another claude-sync, and gives
+// it a --wait flag. Without --wait, a locked run fails at once.
+func holdsLock(cmd *cobra.Command) *cobra.Command {
+	var wait time.Duration
+	run := cmd.RunE
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		// e.g. "trash restore", for the message another run shows
+		name := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+		lock, err := sync.LockProcess(name, 0)
+		var locked *sync.ProcessLockedError
+		if errors.As(err, &locked) && wait > 0 {
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "%sWaiting for another claude-sync process to finish...%s\n", colorDim, colorReset)
+			}
+			lock, err = sync.LockProcess(name, wait)
+		}
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+		return run(cmd, args)
+	}
+	cmd.Flags().DurationVar(&wait, "wait", 0, "Wait up to this long for another claude-sync process to finish (e.g. 30s, 5m)")
+	return cmd
+}
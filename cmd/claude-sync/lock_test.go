@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+func TestHoldsLock(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	quiet = true
+	defer func() { quiet = false }()
+
+	ran := 0
+	cmd := holdsLock(&cobra.Command{
+		Use: "push",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ran++
+			return nil
+		},
+	})
+	cmd.SilenceErrors, cmd.SilenceUsage = true, true
+
+	held, err := sync.LockProcess("pull", 0)
+	if err != nil {
+		t.Fatalf("LockProcess failed: %v", err)
+	}
+	cmd.SetArgs(nil)
+	if err := cmd.Execute(); exitCode(err) != exitLocked {
+		t.Fatalf("Locked run = %v, want exit code %d", err, exitLocked)
+	}
+	if ran != 0 {
+		t.Fatal("Command ran without the lock")
+	}
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		_ = held.Release()
+	}()
+	cmd.SetArgs([]string{"--wait", "5s"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Waiting run failed: %v", err)
+	}
+	if ran != 1 {
+		t.Errorf("Command ran %d times, want 1", ran)
+	}
+
+	// It let the lock go when it finished
+	lock, err := sync.LockProcess("pull", 0)
+	if err != nil {
+		t.Fatalf("Lock still held after the run: %v", err)
+	}
+	lock.Release()
+}
@@ -2,33 +2,43 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
-	"sort"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"filippo.io/age/plugin"
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 
 	"github.com/tawanorg/claude-sync/internal/claudesettings"
 	"github.com/tawanorg/claude-sync/internal/config"
 	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/diff"
+	"github.com/tawanorg/claude-sync/internal/logging"
 	"github.com/tawanorg/claude-sync/internal/paths"
+	"github.com/tawanorg/claude-sync/internal/report"
 	"github.com/tawanorg/claude-sync/internal/storage"
 	"github.com/tawanorg/claude-sync/internal/sync"
 	"github.com/tawanorg/claude-sync/internal/util"
 
-	// Register storage adapters
+	// Register notifiers and storage adapters
+	_ "github.com/tawanorg/claude-sync/internal/notify"
 	_ "github.com/tawanorg/claude-sync/internal/storage/gcs"
 	_ "github.com/tawanorg/claude-sync/internal/storage/r2"
 	_ "github.com/tawanorg/claude-sync/internal/storage/s3"
@@ -38,6 +48,11 @@ import (
 var (
 	version = "dev" // Set via ldflags at build time: -ldflags "-X main.version=x.x.x"
 	quiet   bool
+	verbose bool
+
+	// utcTimes and isoTimes change how formatTime shows timestamps
+	utcTimes bool
+	isoTimes bool
 )
 
 // ANSI color codes
@@ -59,26 +74,156 @@ func main() {
 	}
 
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Suppress output")
+	// No -v shorthand: cobra gives it to --version
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Show extra detail, including storage request counts and debug logs")
+	rootCmd.PersistentFlags().BoolVar(&utcTimes, "utc", false, "Show times in UTC instead of the local timezone")
+	rootCmd.PersistentFlags().BoolVar(&isoTimes, "iso", false, "Show times as RFC 3339 timestamps, without relative times")
+	rootCmd.PersistentFlags().BoolVar(&noInput, "no-input", false, "Fail instead of prompting for input (also "+noInputEnv+"=1)")
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Print the result as JSON (push, pull, status, diff, ls, stats, info, conflicts)")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		setupLogging()
+		return setupJSONOutput(cmd)
+	}
 
 	rootCmd.AddCommand(
-		initCmd(),
-		pushCmd(),
-		pullCmd(),
-		statusCmd(),
-		diffCmd(),
-		conflictsCmd(),
-		rebuildHistoryCmd(),
-		resetCmd(),
-		migrateCmd(),
+		holdsLock(initCmd()),
+		supportsJSON(holdsLock(pushCmd())),
+		supportsJSON(holdsLock(pullCmd())),
+		supportsJSON(statusCmd()),
+		supportsJSON(diffCmd()),
+		supportsJSON(lsCmd()),
+		catCmd(),
+		supportsJSON(statsCmd()),
+		supportsJSON(infoCmd()),
+		planCmd(),
+		holdsLock(restoreCmd()),
+		snapshotCmd(),
+		holdsLock(rollbackCmd()),
+		historyCmd(),
+		holdsLock(pruneVersionsCmd()),
+		holdsLock(gcCmd()),
+		holdsLock(pruneCmd()),
+		holdsLock(obfuscateKeysCmd()),
+		verifyCmd(),
+		trashCmd(),
+		backupsCmd(),
+		holdsLock(pauseCmd()),
+		holdsLock(resumeCmd()),
+		daemonCmd(),
+		proposalsCmd(),
+		holdsLock(approveCmd()),
+		holdsLock(rejectCmd()),
+		reportCmd(),
+		remoteCmd(),
+		exportCmd(),
+		holdsLock(importCmd()),
+		supportsJSON(holdsLock(conflictsCmd())),
+		holdsLock(rebuildHistoryCmd()),
+		holdsLock(resetCmd()),
+		holdsLock(rekeyCmd()),
+		keyCmd(),
+		deviceCmd(),
+		credentialsCmd(),
+		configCmd(),
+		doctorCmd(),
+		holdsLock(migrateCmd()),
+		holdsLock(adoptCmd()),
 		updateCmd(),
 		changelogCmd(),
 		mcpCmd(),
 		autoCmd(),
 		pathsCmd(),
+		completionCmd(),
 	)
+	quietExitStatus(rootCmd)
+
+	crypto.KeyPassphrase = promptKeyPassphrase
+	crypto.PluginUI = pluginTerminalUI()
+
+	if execPath, err := currentExecutable(); err == nil {
+		cleanupUpdateLeftovers(execPath)
+	}
+
+	cmd, err := rootCmd.ExecuteC()
+	reportStorageRequests(rootCmd, cmd)
+	// logCloser is nil when the command never started, e.g. an unknown one
+	var status exitStatus
+	if err != nil && logCloser != nil && !errors.As(err, &status) {
+		slog.Error("command failed", "command", cmd.CommandPath(), "error", err)
+	}
+	if logCloser != nil {
+		logCloser.Close()
+	}
+	os.Exit(exitCode(err))
+}
+
+// logCloser closes the log file setupLogging opened.
+var logCloser io.Closer
+
+// setupLogging sends debug logs to stderr with --verbose and, when the
+// config's log.file is set, records to the rotating log file. A log file
+// that can't be opened is reported, not fatal.
+func setupLogging() {
+	opts := logging.Options{Verbose: verbose}
+	if settings := config.LogSettings(); settings != nil && settings.File {
+		level, err := logging.ParseLevel(settings.Level)
+		if err != nil {
+			printWarning(fmt.Sprintf("log.level: %v; logging at info", err))
+		}
+		opts.File = config.LogFilePath()
+		opts.FileLevel = level
+		opts.MaxSize = int64(settings.MaxSizeMB) << 20
+		opts.MaxFiles = settings.MaxFiles
+	}
+	closer, err := logging.Setup(opts)
+	if err != nil {
+		printWarning(fmt.Sprintf("Not logging to a file: %v", err))
+	}
+	logCloser = closer
+	slog.Debug("command started", "args", os.Args[1:], "version", version)
+}
+
+// recordActivity adds a push or pull to the activity log read by
+// 'claude-sync report'. Failing to record is not worth failing the sync over.
+func recordActivity(command string, result *sync.SyncResult, runErr error) {
+	if err := sync.RecordActivity(sync.NewActivityEntry(command, result, runErr)); err != nil && verbose {
+		fmt.Fprintf(os.Stderr, "%s⚠%s Failed to record activity: %v\n", colorYellow, colorReset, err)
+	}
+}
+
+// recordSyncStatus updates the status file read by 'status --porcelain'
+// after a push or pull. Like the activity log, it's not worth failing over.
+func recordSyncStatus(syncer *sync.Syncer, command string, result *sync.SyncResult, runErr error) {
+	if err := syncer.RecordSyncStatus(context.Background(), command, result, runErr); err != nil && verbose {
+		fmt.Fprintf(os.Stderr, "%s⚠%s Failed to update the status file: %v\n", colorYellow, colorReset, err)
+	}
+}
+
+// recountConflicts updates the conflict count in the status file once
+// 'claude-sync conflicts' is done.
+func recountConflicts(claudeDir string) {
+	if err := sync.RecountConflicts(claudeDir); err != nil && verbose {
+		fmt.Fprintf(os.Stderr, "%s⚠%s Failed to update the status file: %v\n", colorYellow, colorReset, err)
+	}
+}
+
+// reportStorageRequests adds the storage requests this run made to the request
+// log and, with --verbose, prints them. Requests are counted by the metered
+// wrapper storage.New puts around every adapter.
+func reportStorageRequests(rootCmd, cmd *cobra.Command) {
+	stats := storage.ProcessRequestStats()
+	if stats.Total() == 0 || cmd == nil {
+		return
+	}
 
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	name := strings.TrimPrefix(cmd.CommandPath(), rootCmd.Name()+" ")
+	if err := sync.RecordRequests(name, stats); err != nil && verbose {
+		fmt.Fprintf(os.Stderr, "%s⚠%s Failed to record storage requests: %v\n", colorYellow, colorReset, err)
+	}
+
+	if verbose {
+		fmt.Fprintf(os.Stderr, "%s⋯%s Storage requests: %s (%s up, %s down)\n",
+			colorDim, colorReset, stats, util.FormatSize(stats.BytesUp), util.FormatSize(stats.BytesDown))
 	}
 }
 
@@ -102,6 +247,20 @@ func printBanner() {
 	fmt.Println()
 }
 
+// formatTime shows a timestamp in the local timezone (UTC with --utc) along
+// with how long ago it was, or as a plain RFC 3339 timestamp with --iso.
+func formatTime(t time.Time) string {
+	if utcTimes {
+		t = t.UTC()
+	} else {
+		t = t.Local()
+	}
+	if isoTimes {
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%s (%s)", t.Format("2006-01-02 15:04 MST"), util.FormatRelativeTime(t, time.Now()))
+}
+
 func printStep(step int, total int, text string) {
 	fmt.Printf("\n%s[%d/%d]%s %s%s%s\n", colorCyan, step, total, colorReset, colorBold, text, colorReset)
 }
@@ -120,14 +279,16 @@ func printWarning(text string) {
 
 func initCmd() *cobra.Command {
 	var provider, bucket string
-	var scope string
-	var usePassphrase, force bool
+	var scope, sshKey, pluginIdentity, identityFile, keyPrefix string
+	var usePassphrase, ageScrypt, protectKey, noKeychain, force bool
+	var kdf kdfCosts
+	var recipients []string
 
 	// R2 flags
-	var accountID, accessKey, secretKey string
+	var accountID, accessKey, secretKey, r2Jurisdiction, r2CustomDomain string
 
 	// S3 flags
-	var s3Region string
+	var s3Region, awsProfile string
 
 	// S3-compatible (custom endpoint) flags
 	var s3Endpoint string
@@ -154,7 +315,16 @@ Supported providers:
 Examples:
   claude-sync init                # Full setup wizard
   claude-sync init --passphrase   # Re-enter passphrase only (keeps storage config)
+  claude-sync init --key-file ~/.config/age/keys.txt   # Use an existing age identity file
+  claude-sync init --ssh-key ~/.ssh/id_ed25519   # Encrypt with an existing SSH key
+  claude-sync init --plugin-identity ~/.config/age/yubikey.txt   # Key on a YubiKey (age-plugin-yubikey)
+  claude-sync init --protect-key  # Encrypt the key file at rest with a passphrase
+  claude-sync init --age-passphrase   # Encrypt each file to a passphrase that stock 'age -d' opens
+  claude-sync init --passphrase --kdf-memory 32   # Less Argon2 memory (new buckets only)
+  claude-sync init --no-keychain  # Keep the key in a file (headless machines)
   claude-sync init --force        # Reset everything, start fresh
+  claude-sync init --key-prefix laptops/tew/   # Share a bucket: keep this setup under a prefix
+  claude-sync init --provider r2 --r2-jurisdiction eu   # R2 bucket in the EU jurisdiction
   claude-sync init --provider s3-compatible --endpoint https://s3.us-west-004.backblazeb2.com   # Backblaze B2`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Show banner
@@ -165,11 +335,30 @@ Examples:
 
 			// Special case: --passphrase with existing config = just regenerate key
 			if usePassphrase && config.Exists() && !force {
-				return initPassphraseOnly(ctx, keyPath)
+				return initPassphraseOnly(ctx, keyPath, kdf, noKeychain)
+			}
+			if ageScrypt && (usePassphrase || sshKey != "" || pluginIdentity != "" || identityFile != "") {
+				return fmt.Errorf("--age-passphrase can't be combined with --passphrase, --key-file, --ssh-key or --plugin-identity")
+			}
+
+			if pluginIdentity != "" {
+				if sshKey != "" {
+					return fmt.Errorf("use --ssh-key or --plugin-identity, not both")
+				}
+				if !crypto.IsPluginKeyFile(expandHome(pluginIdentity)) {
+					return fmt.Errorf("%s is not an age plugin identity file", pluginIdentity)
+				}
+				sshKey = pluginIdentity
+			}
+			if identityFile != "" {
+				if sshKey != "" {
+					return fmt.Errorf("use only one of --key-file, --ssh-key and --plugin-identity")
+				}
+				sshKey = identityFile
 			}
 
 			// Normal flow: full setup
-			return initFullSetup(ctx, keyPath, provider, bucket, accountID, accessKey, secretKey, s3Region, s3Endpoint, s3UsePathStyle, gcsProjectID, gcsCredentialsFile, webdavURL, webdavUsername, webdavPassword, webdavPathPrefix, scope, usePassphrase, force)
+			return initFullSetup(ctx, keyPath, provider, bucket, accountID, r2Jurisdiction, r2CustomDomain, accessKey, secretKey, s3Region, awsProfile, s3Endpoint, s3UsePathStyle, gcsProjectID, gcsCredentialsFile, webdavURL, webdavUsername, webdavPassword, webdavPathPrefix, keyPrefix, scope, recipients, sshKey, kdf, usePassphrase, ageScrypt, protectKey, noKeychain, force)
 		},
 	}
 
@@ -177,16 +366,30 @@ Examples:
 	cmd.Flags().StringVar(&provider, "provider", "", "Storage provider: r2, s3, gcs, s3-compatible, or webdav")
 	cmd.Flags().StringVar(&scope, "scope", "", "Sync scope: 'full' (default, everything) or 'sessions' (conversation history only)")
 	cmd.Flags().StringVar(&bucket, "bucket", "", "Bucket name")
+	cmd.Flags().StringVar(&keyPrefix, "key-prefix", "", "Keep everything under this prefix of the bucket, e.g. laptops/tew/, to share a bucket (every setup in a shared bucket needs one)")
 	cmd.Flags().BoolVar(&usePassphrase, "passphrase", false, "Derive encryption key from passphrase")
+	cmd.Flags().BoolVar(&ageScrypt, "age-passphrase", false, "Encrypt each file to an age scrypt passphrase instead of a key, for recovery with stock 'age -d'")
 	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite existing config/key without prompting")
+	cmd.Flags().StringArrayVar(&recipients, "recipient", nil, "Extra age or SSH public key to encrypt to, e.g. a recovery key (repeatable)")
+	cmd.Flags().StringVar(&sshKey, "ssh-key", "", "Use an existing ssh-ed25519 or ssh-rsa private key instead of an age key")
+	cmd.Flags().StringVar(&identityFile, "key-file", "", "Use an existing age identity file, e.g. from age-keygen, instead of generating a key")
+	cmd.Flags().StringVar(&pluginIdentity, "plugin-identity", "", "Use an age plugin identity file, e.g. from age-plugin-yubikey, instead of an age key")
+	cmd.Flags().Uint32Var(&kdf.memoryMiB, "kdf-memory", 0, "Argon2id memory in MiB for a new passphrase bucket (default 64; lower for low-RAM devices)")
+	cmd.Flags().Uint32Var(&kdf.time, "kdf-time", 0, "Argon2id iterations for a new passphrase bucket (default 3)")
+	cmd.Flags().Uint8Var(&kdf.threads, "kdf-threads", 0, "Argon2id threads for a new passphrase bucket (default 4)")
+	cmd.Flags().BoolVar(&protectKey, "protect-key", false, "Encrypt the age key file at rest with a passphrase")
+	cmd.Flags().BoolVar(&noKeychain, "no-keychain", false, "Keep the age key in a file instead of the OS keychain (headless machines)")
 
 	// R2 flags
 	cmd.Flags().StringVar(&accountID, "account-id", "", "Cloudflare Account ID (R2)")
+	cmd.Flags().StringVar(&r2Jurisdiction, "r2-jurisdiction", "", "Jurisdiction of the R2 bucket: eu or fedramp (default: global)")
+	cmd.Flags().StringVar(&r2CustomDomain, "r2-custom-domain", "", "S3 API endpoint on your own domain in front of R2, e.g. r2.example.com")
 	cmd.Flags().StringVar(&accessKey, "access-key", "", "Access Key ID (R2/S3)")
 	cmd.Flags().StringVar(&secretKey, "secret-key", "", "Secret Access Key (R2/S3)")
 
 	// S3 flags
 	cmd.Flags().StringVar(&s3Region, "region", "", "Region (S3 / S3-compatible)")
+	cmd.Flags().StringVar(&awsProfile, "aws-profile", "", "Use this profile from ~/.aws/credentials instead of access keys (S3)")
 
 	// S3-compatible flags
 	cmd.Flags().StringVar(&s3Endpoint, "endpoint", "", "Custom S3-compatible endpoint URL (e.g. https://s3.us-west-004.backblazeb2.com)")
@@ -207,7 +410,7 @@ Examples:
 
 // initPassphraseOnly handles the case where user just wants to re-enter passphrase
 // keeping existing storage configuration
-func initPassphraseOnly(ctx context.Context, keyPath string) error {
+func initPassphraseOnly(ctx context.Context, keyPath string, kdf kdfCosts, noKeychain bool) error {
 	existingCfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load existing config: %w", err)
@@ -222,23 +425,50 @@ func initPassphraseOnly(ctx context.Context, keyPath string) error {
 	fmt.Printf("  %sUsing existing storage config:%s %s/%s\n\n",
 		colorDim, colorReset, storageCfg.Provider, storageCfg.Bucket)
 
+	store, fixed, err := fixBucketRegion(ctx, storageCfg, store)
+	if err != nil {
+		return err
+	}
+	if fixed {
+		if err := config.Save(existingCfg); err != nil {
+			return err
+		}
+	}
+
 	printInfo("Use the SAME passphrase on all devices.")
 
-	shouldClearRemote, err := enterPassphraseAndVerify(ctx, store, keyPath)
-	if err != nil {
+	var shouldClearRemote bool
+	var kdfParams *crypto.KDFParams
+	if existingCfg.Encryption == crypto.SchemeScrypt {
+		// The passphrase is the key; there is nothing to derive or to put
+		// in the keychain
+		if shouldClearRemote, kdfParams, err = enterScryptPassphraseAndVerify(ctx, store, keyPath); err != nil {
+			return err
+		}
+		noKeychain = true
+	} else if shouldClearRemote, kdfParams, err = enterPassphraseAndVerify(ctx, store, keyPath, kdf); err != nil {
 		return err
 	}
+	if setConfigKDF(existingCfg, kdfParams) {
+		if err := config.Save(existingCfg); err != nil {
+			return err
+		}
+	}
 
 	// Clear remote if user chose to start fresh
 	if shouldClearRemote {
 		fmt.Printf("%s⋯%s Clearing remote files...\n", colorDim, colorReset)
-		if err := clearRemoteStorage(ctx, store); err != nil {
+		if err := clearRemoteStorage(ctx, store, existingCfg.Trash); err != nil {
 			printWarning("Failed to clear remote: " + err.Error())
 		} else {
 			printSuccess("Remote files cleared")
 		}
 	}
 
+	if !noKeychain {
+		storeKeyInKeychain(keyPath)
+	}
+
 	fmt.Println()
 	fmt.Println(colorGreen + "  Passphrase updated!" + colorReset)
 	fmt.Println()
@@ -262,7 +492,7 @@ func resolveScope(scope string) (string, error) {
 			},
 		}
 		var c int
-		if err := survey.AskOne(prompt, &c); err != nil {
+		if err := askOne(prompt, &c); err != nil {
 			return "", err
 		}
 		if c == 0 {
@@ -275,14 +505,17 @@ func resolveScope(scope string) (string, error) {
 }
 
 // initFullSetup handles the full init wizard
-func initFullSetup(ctx context.Context, keyPath, provider, bucket, accountID, accessKey, secretKey, s3Region, s3Endpoint string, s3UsePathStyle bool, gcsProjectID, gcsCredentialsFile, webdavURL, webdavUsername, webdavPassword, webdavPathPrefix, scope string, usePassphrase, force bool) error {
+func initFullSetup(ctx context.Context, keyPath, provider, bucket, accountID, r2Jurisdiction, r2CustomDomain, accessKey, secretKey, s3Region, awsProfile, s3Endpoint string, s3UsePathStyle bool, gcsProjectID, gcsCredentialsFile, webdavURL, webdavUsername, webdavPassword, webdavPathPrefix, keyPrefix, scope string, recipients []string, keyFile string, kdf kdfCosts, usePassphrase, ageScrypt, protectKey, noKeychain, force bool) error {
 	if config.Exists() && !force {
 		var overwrite bool
 		prompt := &survey.Confirm{
 			Message: "Configuration already exists. Overwrite?",
 			Default: false,
 		}
-		if err := survey.AskOne(prompt, &overwrite); err != nil || !overwrite {
+		if err := askOne(prompt, &overwrite); err != nil {
+			return err
+		}
+		if !overwrite {
 			fmt.Println("  Aborted.")
 			return nil
 		}
@@ -305,7 +538,7 @@ func initFullSetup(ctx context.Context, keyPath, provider, bucket, accountID, ac
 			},
 		}
 		var choice int
-		if err := survey.AskOne(prompt, &choice); err != nil {
+		if err := askOne(prompt, &choice); err != nil {
 			return err
 		}
 		switch choice {
@@ -328,9 +561,9 @@ func initFullSetup(ctx context.Context, keyPath, provider, bucket, accountID, ac
 
 	switch provider {
 	case "r2":
-		storageCfg, err = runR2Wizard(accountID, accessKey, secretKey, bucket)
+		storageCfg, err = runR2Wizard(accountID, r2Jurisdiction, r2CustomDomain, accessKey, secretKey, bucket)
 	case "s3":
-		storageCfg, err = runS3Wizard(accessKey, secretKey, s3Region, bucket)
+		storageCfg, err = runS3Wizard(accessKey, secretKey, s3Region, bucket, awsProfile)
 	case "gcs":
 		storageCfg, err = runGCSWizard(gcsProjectID, gcsCredentialsFile, bucket)
 	case "s3-compatible":
@@ -347,6 +580,7 @@ func initFullSetup(ctx context.Context, keyPath, provider, bucket, accountID, ac
 	if storageCfg == nil {
 		return fmt.Errorf("setup cancelled")
 	}
+	storageCfg.KeyPrefix = storage.NormalizeKeyPrefix(keyPrefix)
 
 	// Step 2: Encryption setup
 	fmt.Println()
@@ -360,19 +594,31 @@ func initFullSetup(ctx context.Context, keyPath, provider, bucket, accountID, ac
 	}
 
 	// Check if we should use passphrase mode
-	if !usePassphrase && !crypto.KeyExists(keyPath) {
+	if keyFile == "" && !usePassphrase && !ageScrypt && !crypto.KeyExists(keyPath) {
 		prompt := &survey.Select{
 			Message: "Choose encryption key method:",
 			Options: []string{
 				"Passphrase (recommended) - same key on all devices",
 				"Random key - must copy key file to other devices",
+				"SSH key - use your existing ~/.ssh key",
+				"age passphrase - each file opens with stock 'age -d' (slower)",
 			},
 		}
 		var choice int
-		if err := survey.AskOne(prompt, &choice); err != nil {
+		if err := askOne(prompt, &choice); err != nil {
 			return err
 		}
 		usePassphrase = choice == 0
+		ageScrypt = choice == 3
+		if choice == 2 {
+			pathPrompt := &survey.Input{
+				Message: "SSH private key:",
+				Default: "~/.ssh/id_ed25519",
+			}
+			if err := askOne(pathPrompt, &keyFile); err != nil {
+				return err
+			}
+		}
 		fmt.Println()
 	}
 
@@ -390,24 +636,74 @@ func initFullSetup(ctx context.Context, keyPath, provider, bucket, accountID, ac
 		}
 		return fmt.Errorf("failed to create storage client: %w", err)
 	}
+	store, _, err = fixBucketRegion(ctx, storageCfg, store)
+	if err != nil {
+		return err
+	}
+	if err := checkKeyPrefix(ctx, storageCfg); err != nil {
+		return err
+	}
 
 	shouldClearRemote := false
+	var kdfParams *crypto.KDFParams
+	scheme := crypto.SchemeAge
 
-	if usePassphrase {
+	if keyFile != "" {
+		keyPath = expandHome(keyFile)
+		if _, err := crypto.NewEncryptor(keyPath); err != nil {
+			return err
+		}
+		switch {
+		case crypto.IsPluginKeyFile(keyPath):
+			printSuccess("Using age plugin identity: " + keyFile)
+		case crypto.IsSSHKeyFile(keyPath):
+			printSuccess("Using SSH key: " + keyFile)
+		default:
+			printSuccess("Using age identity: " + keyFile)
+		}
+	} else if usePassphrase {
 		if crypto.KeyExists(keyPath) && !force {
 			var overwriteKey bool
 			prompt := &survey.Confirm{
 				Message: "Encryption key already exists. Overwrite?",
 				Default: false,
 			}
-			if err := survey.AskOne(prompt, &overwriteKey); err != nil || !overwriteKey {
+			if err := askOne(prompt, &overwriteKey); err != nil {
+				return err
+			}
+			if !overwriteKey {
 				printSuccess("Using existing key")
 				goto skipKeyGen
 			}
 		}
 
 		printInfo("Use the SAME passphrase on all devices.")
-		shouldClearRemote, err = enterPassphraseAndVerify(ctx, store, keyPath)
+		shouldClearRemote, kdfParams, err = enterPassphraseAndVerify(ctx, store, keyPath, kdf)
+		if err != nil {
+			return err
+		}
+
+	} else if ageScrypt {
+		scheme = crypto.SchemeScrypt
+		if len(recipients) > 0 {
+			return fmt.Errorf("--age-passphrase encrypts to the passphrase alone, so it can't have --recipient")
+		}
+		if crypto.KeyExists(keyPath) && !crypto.IsScryptKeyFile(keyPath) && !force {
+			var overwriteKey bool
+			prompt := &survey.Confirm{
+				Message: "Encryption key already exists. Replace it with an age passphrase?",
+				Default: false,
+			}
+			if err := askOne(prompt, &overwriteKey); err != nil {
+				return err
+			}
+			if !overwriteKey {
+				return fmt.Errorf("setup cancelled")
+			}
+		}
+		printInfo("Every file is encrypted to this passphrase; 'age -d' opens any of them.")
+		printInfo("Use the SAME passphrase on all devices.")
+		shouldClearRemote, kdfParams, err = enterScryptPassphraseAndVerify(ctx, store, keyPath)
 		if err != nil {
 			return err
 		}
@@ -444,17 +740,34 @@ skipKeyGen:
 
 	// Clear remote if user chose to start fresh
 	if shouldClearRemote {
+		// Honour the trash setting of the config being replaced, if any
+		trash := false
+		if prevCfg, err := config.Load(); err == nil {
+			trash = prevCfg.Trash
+		}
 		fmt.Printf("%s⋯%s Clearing remote files...\n", colorDim, colorReset)
-		if err := clearRemoteStorage(ctx, store); err != nil {
+		if err := clearRemoteStorage(ctx, store, trash); err != nil {
 			printWarning("Failed to clear remote: " + err.Error())
 		} else {
 			printSuccess("Remote files cleared")
 		}
 	}
 
-	// Verify encryption key can decrypt remote files (if any exist)
+	// A device key registered with the bucket opens the device registry
+	// rather than the files themselves
+	var registry *sync.Registry
 	if !shouldClearRemote {
-		if err := verifyKeyMatchesRemote(ctx, store, keyPath); err != nil {
+		if device, err := crypto.NewEncryptor(keyPath); err == nil {
+			registry, _ = sync.LoadRegistry(ctx, store, device)
+		}
+		if registry != nil {
+			printSuccess("This device is registered with the bucket")
+		}
+	}
+
+	// Verify encryption key can decrypt remote files (if any exist)
+	if !shouldClearRemote && registry == nil {
+		if err := verifyKeyMatchesRemote(ctx, store, scheme, keyPath); err != nil {
 			fmt.Println()
 			printWarning("Encryption key cannot decrypt remote files!")
 			printInfo("The remote bucket has files encrypted with a different key.")
@@ -464,21 +777,89 @@ skipKeyGen:
 			printInfo("  2. Copy the age-key.txt from your original device")
 			printInfo("  3. Run 'claude-sync reset --remote' to clear remote and start fresh")
 			fmt.Println()
-			return fmt.Errorf("encryption key mismatch - cannot sync with remote")
+			return errKeyMismatch
 		}
 		printSuccess("Encryption key verified")
 	}
 
+	// The key may open a keyring, which holds the key files are encrypted to
+	var keyring *sync.Keyring
+	if !shouldClearRemote && registry == nil {
+		if wrap, err := crypto.NewEncryptor(keyPath); err == nil {
+			keyring, _ = sync.LoadKeyring(ctx, store, wrap)
+		}
+	}
+
+	// Other devices check their key against this before they sync
+	var fingerprint string
+	if registry != nil {
+		dataEnc, err := registry.DataEncryptor(nil)
+		if err != nil {
+			return err
+		}
+		fingerprint = dataEnc.Fingerprint()
+	} else if keyring != nil {
+		dataEnc, err := keyring.DataEncryptor(nil)
+		if err != nil {
+			return err
+		}
+		fingerprint = dataEnc.Fingerprint()
+	} else if fingerprint, err = recordKeyFingerprint(ctx, store, scheme, keyPath); err != nil {
+		return err
+	}
+
 	// Resolve sync scope (prompts if not provided via --scope)
 	scope, err = resolveScope(scope)
 	if err != nil {
 		return err
 	}
 
+	if scheme != crypto.SchemeScrypt {
+		recipients, err = resolveRecipients(recipients)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Save config
 	cfg := &config.Config{
 		Storage:       storageCfg,
 		EncryptionKey: "~/.claude-sync/age-key.txt",
+		Recipients:    recipients,
+		DeviceKeys:    registry != nil,
+		Keyring:       keyring != nil,
+	}
+	if keyFile != "" {
+		cfg.EncryptionKey = keyFile
+	}
+	if scheme != crypto.SchemeAge {
+		cfg.Encryption = scheme
+	}
+	setConfigKDF(cfg, kdfParams)
+
+	switch {
+	case scheme == crypto.SchemeScrypt:
+		// The file holds the passphrase itself, which the keychain and
+		// --protect-key don't handle
+	case keyFile != "" || crypto.IsProtectedKeyFile(keyPath) || crypto.IsKeychainKeyFile(keyPath):
+		// Nothing to store: the key is already protected one way or another
+	case protectKey:
+		fmt.Println()
+		printInfo("Choose a passphrase to protect the key file on this device.")
+		passphrase, err := promptNewPassphrase()
+		if err != nil {
+			return err
+		}
+		if err := crypto.ProtectKey(keyPath, passphrase); err != nil {
+			return err
+		}
+		printSuccess("Key protected: " + keyPath)
+	case !noKeychain:
+		fmt.Println()
+		storeKeyInKeychain(keyPath)
+		if !usePassphrase && crypto.IsKeychainKeyFile(keyPath) {
+			printInfo("Run 'claude-sync key file' to write the key out when copying it to another device.")
+		}
 	}
 	if scope == config.ScopeSessions {
 		cfg.Scope = config.ScopeSessions
@@ -492,6 +873,9 @@ skipKeyGen:
 	fmt.Println()
 	fmt.Println(colorGreen + "  Setup complete!" + colorReset)
 	fmt.Println()
+	printInfo("Key fingerprint: " + fingerprint)
+	printInfo("Every device sharing this key shows the same one ('claude-sync key fingerprint').")
+	fmt.Println()
 	printInfo("Run 'claude-sync push' to upload your sessions")
 	printInfo("Run 'claude-sync pull' on other devices to sync")
 	fmt.Println()
@@ -499,49 +883,190 @@ skipKeyGen:
 	return nil
 }
 
-// enterPassphraseAndVerify prompts for passphrase and verifies against remote
-// Returns shouldClearRemote flag
-func enterPassphraseAndVerify(ctx context.Context, store storage.Storage, keyPath string) (bool, error) {
+// resolveRecipients validates --recipient values, asking for them when none
+// were given. Extra recipients can decrypt everything this device pushes.
+func resolveRecipients(recipients []string) ([]string, error) {
+	if len(recipients) == 0 {
+		var answer string
+		prompt := &survey.Input{
+			Message: "Extra recipients (optional):",
+			Help:    "Comma-separated age public keys (age1...) that can also decrypt your files, e.g. a recovery key kept offline or a teammate's key. Leave blank for none.",
+		}
+		if err := askOne(prompt, &answer); err != nil {
+			return nil, err
+		}
+		for _, r := range strings.Split(answer, ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				recipients = append(recipients, r)
+			}
+		}
+	}
+	for _, r := range recipients {
+		if _, err := crypto.ParseRecipient(r); err != nil {
+			return nil, err
+		}
+	}
+	return recipients, nil
+}
+
+// fixBucketRegion checks whether the bucket is in a different region from
+// the configured one and offers to switch storageCfg to the bucket's region.
+// It returns the store to use from then on and whether the region changed.
+// Other BucketExists failures are left for the caller's own check to report.
+func fixBucketRegion(ctx context.Context, storageCfg *storage.StorageConfig, store storage.Storage) (storage.Storage, bool, error) {
+	_, err := store.BucketExists(ctx)
+	var regionErr *storage.BucketRegionError
+	if !errors.As(err, &regionErr) {
+		return store, false, nil
+	}
+
+	printWarning(regionErr.Error())
+	fix := true
+	prompt := &survey.Confirm{
+		Message: fmt.Sprintf("Switch to region %s?", regionErr.Region),
+		Default: true,
+	}
+	if err := askOne(prompt, &fix); err != nil {
+		return nil, false, err
+	}
+	if !fix {
+		return store, false, nil
+	}
+
+	storageCfg.Region = regionErr.Region
+	fixedStore, err := storage.New(storageCfg)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create storage client: %w", err)
+	}
+	printSuccess("Region set to " + regionErr.Region)
+	return fixedStore, true, nil
+}
+
+// checkKeyPrefix refuses a key prefix that would overlap another setup in a
+// shared bucket. A bucket that can't be listed is left for the connection
+// test to report.
+func checkKeyPrefix(ctx context.Context, storageCfg *storage.StorageConfig) error {
+	unprefixed := *storageCfg
+	unprefixed.KeyPrefix = ""
+	bucket, err := storage.New(&unprefixed)
+	if err != nil {
+		return nil
+	}
+	setups, err := sync.BucketSetups(ctx, bucket)
+	if err != nil {
+		return nil
+	}
+	return sync.CheckKeyPrefix(setups, storageCfg.KeyPrefix)
+}
+
+// promptKeyPassphrase asks for the passphrase of a protected key file.
+func promptKeyPassphrase(keyPath string) ([]byte, error) {
+	var passphrase string
+	prompt := &survey.Password{
+		Message: "Passphrase for " + keyPath + ":",
+	}
+	if err := askOne(prompt, &passphrase); err != nil {
+		return nil, err
+	}
+	return []byte(passphrase), nil
+}
+
+// pluginTerminalUI shows age plugin messages on stderr and asks for PINs
+// on the terminal, so hardware tokens work even when output is piped.
+func pluginTerminalUI() *plugin.ClientUI {
+	ui := plugin.NewTerminalUI(
+		func(format string, v ...any) {
+			fmt.Fprintf(os.Stderr, "%s⋯%s "+format+"\n", append([]any{colorDim, colorReset}, v...)...)
+		},
+		func(format string, v ...any) {
+			fmt.Fprintf(os.Stderr, "%s⚠%s "+format+"\n", append([]any{colorYellow, colorReset}, v...)...)
+		},
+	)
+	ui.WaitTimer = func(name string) {
+		fmt.Fprintf(os.Stderr, "%s⋯%s Waiting for age-plugin-%s (touch your token if it is blinking)\n", colorDim, colorReset, name)
+	}
+	if inputDisabled() {
+		ui.RequestValue = func(name, prompt string, secret bool) (string, error) {
+			return "", noInputError(prompt)
+		}
+		ui.Confirm = func(name, prompt, yes, no string) (bool, error) {
+			return false, noInputError(prompt)
+		}
+	}
+	return ui
+}
+
+// promptNewPassphrase asks for a passphrase twice until it is strong enough
+// and both entries match.
+func promptNewPassphrase() (string, error) {
 	for {
 		var passphrase string
-		for {
-			prompt := &survey.Password{
-				Message: "Passphrase (min 8 chars):",
-			}
-			if err := survey.AskOne(prompt, &passphrase); err != nil {
-				return false, err
-			}
+		prompt := &survey.Password{
+			Message: "Passphrase (min 8 chars):",
+		}
+		if err := askOne(prompt, &passphrase); err != nil {
+			return "", err
+		}
 
-			if err := crypto.ValidatePassphraseStrength(passphrase); err != nil {
-				printWarning(err.Error())
-				continue
-			}
+		if err := crypto.ValidatePassphraseStrength(passphrase); err != nil {
+			printWarning(err.Error())
+			continue
+		}
 
-			var confirm string
-			confirmPrompt := &survey.Password{
-				Message: "Confirm passphrase:",
-			}
-			if err := survey.AskOne(confirmPrompt, &confirm); err != nil {
-				return false, err
-			}
+		var confirm string
+		confirmPrompt := &survey.Password{
+			Message: "Confirm passphrase:",
+		}
+		if err := askOne(confirmPrompt, &confirm); err != nil {
+			return "", err
+		}
 
-			if passphrase != confirm {
-				printWarning("Passphrases don't match.")
-				continue
-			}
-			break
+		if passphrase != confirm {
+			printWarning("Passphrases don't match.")
+			continue
+		}
+		return passphrase, nil
+	}
+}
+
+// kdfCosts are the --kdf-* init flags: Argon2id costs for a bucket's first
+// passphrase key. Zero means the default.
+type kdfCosts struct {
+	memoryMiB, time uint32
+	threads         uint8
+}
+
+// enterPassphraseAndVerify prompts for passphrase and verifies against remote.
+// The key is derived with the bucket's KDF parameters, which are returned;
+// parameters the bucket doesn't record yet (new ones, or the legacy defaults
+// its files use) are stored with it once the key is accepted.
+// Returns shouldClearRemote flag
+func enterPassphraseAndVerify(ctx context.Context, store storage.Storage, keyPath string, kdf kdfCosts) (bool, *crypto.KDFParams, error) {
+	params, stored, err := sync.ResolveKDFParams(ctx, store, kdf.memoryMiB, kdf.time, kdf.threads)
+	if err != nil {
+		return false, nil, err
+	}
+	if !params.IsLegacy() {
+		printInfo("Key derivation: " + params.String())
+	}
+
+	for {
+		passphrase, err := promptNewPassphrase()
+		if err != nil {
+			return false, nil, err
 		}
 
-		if err := crypto.GenerateKeyFromPassphrase(keyPath, passphrase); err != nil {
-			return false, fmt.Errorf("failed to generate key: %w", err)
+		if err := crypto.GenerateKeyFromPassphraseWithParams(keyPath, passphrase, params); err != nil {
+			return false, nil, fmt.Errorf("failed to generate key: %w", err)
 		}
 
+		shouldClearRemote := false
 		// Verify the key matches existing remote files (if any)
-		if err := verifyKeyMatchesRemote(ctx, store, keyPath); err != nil {
+		if err := verifyKeyMatchesRemote(ctx, store, crypto.SchemeAge, keyPath); err != nil {
 			// Key mismatch detected - ask user what to do
 			action, actionErr := handleKeyMismatch()
 			if actionErr != nil {
-				return false, actionErr
+				return false, nil, actionErr
 			}
 
 			switch action {
@@ -551,21 +1076,92 @@ func enterPassphraseAndVerify(ctx context.Context, store storage.Storage, keyPat
 				printInfo("Enter a different passphrase:")
 				continue
 			case actionClearRemote:
-				printSuccess("Key derived from passphrase")
 				printInfo("Remote files will be cleared...")
-				return true, nil
+				shouldClearRemote = true
 			case actionAbort:
 				_ = os.Remove(keyPath)
-				return false, fmt.Errorf("setup aborted")
+				return false, nil, fmt.Errorf("setup aborted")
 			}
 		}
 
+		if !stored {
+			if err := sync.SaveKDFParams(ctx, store, params); err != nil {
+				return false, nil, err
+			}
+		}
 		printSuccess("Key derived from passphrase")
-		return false, nil
+		return shouldClearRemote, &params, nil
+	}
+}
+
+// enterScryptPassphraseAndVerify is enterPassphraseAndVerify for the
+// age-scrypt scheme: the passphrase itself is saved as the key, along with
+// the bucket's salt for its key ID and signing key. Returns shouldClearRemote
+// flag and the KDF parameters the salt came from.
+func enterScryptPassphraseAndVerify(ctx context.Context, store storage.Storage, keyPath string) (bool, *crypto.KDFParams, error) {
+	params, stored, err := sync.ResolveKDFParams(ctx, store, 0, 0, 0)
+	if err != nil {
+		return false, nil, err
+	}
+
+	for {
+		passphrase, err := promptNewPassphrase()
+		if err != nil {
+			return false, nil, err
+		}
+		if err := crypto.SaveScryptKey(keyPath, passphrase, params); err != nil {
+			return false, nil, err
+		}
+
+		shouldClearRemote := false
+		if err := verifyKeyMatchesRemote(ctx, store, crypto.SchemeScrypt, keyPath); err != nil {
+			action, actionErr := handleKeyMismatch()
+			if actionErr != nil {
+				return false, nil, actionErr
+			}
+
+			switch action {
+			case actionRetryPassphrase:
+				_ = os.Remove(keyPath)
+				fmt.Println()
+				printInfo("Enter a different passphrase:")
+				continue
+			case actionClearRemote:
+				printInfo("Remote files will be cleared...")
+				shouldClearRemote = true
+			case actionAbort:
+				_ = os.Remove(keyPath)
+				return false, nil, fmt.Errorf("setup aborted")
+			}
+		}
+
+		if !stored {
+			if err := sync.SaveKDFParams(ctx, store, params); err != nil {
+				return false, nil, err
+			}
+		}
+		printSuccess("Passphrase saved")
+		return shouldClearRemote, &params, nil
+	}
+}
+
+// setConfigKDF records the KDF parameters a passphrase key was derived with,
+// reporting whether the config changed. The fixed-salt defaults aren't
+// recorded.
+func setConfigKDF(cfg *config.Config, params *crypto.KDFParams) bool {
+	if params == nil {
+		return false
+	}
+	if params.IsLegacy() {
+		changed := cfg.KDF != nil
+		cfg.KDF = nil
+		return changed
 	}
+	cfg.KDF = params
+	return true
 }
 
-func runR2Wizard(accountID, accessKey, secretKey, bucket string) (*storage.StorageConfig, error) {
+func runR2Wizard(accountID, jurisdiction, customDomain, accessKey, secretKey, bucket string) (*storage.StorageConfig, error) {
 	fmt.Printf("  %sCloudflare R2 Setup%s\n\n", colorBold, colorReset)
 	printInfo("You need a Cloudflare R2 bucket and API token.")
 	printInfo("R2 free tier includes 10GB storage.")
@@ -624,20 +1220,58 @@ func runR2Wizard(accountID, accessKey, secretKey, bucket string) (*storage.Stora
 		},
 	}
 
-	if err := survey.Ask(questions, &answers); err != nil {
+	if err := ask(questions, &answers); err != nil {
 		return nil, err
 	}
 
-	return &storage.StorageConfig{
+	// Flags settle where the bucket is; otherwise ask, since an EU or
+	// FedRAMP bucket isn't reachable at the global endpoint
+	if jurisdiction == "" && customDomain == "" {
+		prompt := &survey.Select{
+			Message: "Data location:",
+			Help:    "Shown as the bucket's jurisdiction in the R2 dashboard; EU keeps data in the EU",
+			Options: []string{
+				"Automatic (global endpoint)",
+				"EU jurisdiction",
+				"FedRAMP jurisdiction",
+				"Custom domain (S3 API on your own domain)",
+			},
+		}
+		var choice int
+		if err := askOne(prompt, &choice); err != nil {
+			return nil, err
+		}
+		switch choice {
+		case 1:
+			jurisdiction = storage.R2JurisdictionEU
+		case 2:
+			jurisdiction = storage.R2JurisdictionFedRAMP
+		case 3:
+			if err := askOne(&survey.Input{Message: "Custom domain:", Help: "e.g. r2.example.com"}, &customDomain, survey.WithValidator(survey.Required)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	storageCfg := &storage.StorageConfig{
 		Provider:        storage.ProviderR2,
 		Bucket:          answers.Bucket,
 		AccountID:       answers.AccountID,
 		AccessKeyID:     answers.AccessKey,
 		SecretAccessKey: answers.SecretKey,
-	}, nil
+		Jurisdiction:    strings.ToLower(strings.TrimSpace(jurisdiction)),
+		CustomDomain:    strings.TrimSpace(customDomain),
+	}
+	if storageCfg.Jurisdiction == storage.R2JurisdictionDefault {
+		storageCfg.Jurisdiction = ""
+	}
+	if err := storageCfg.Validate(); err != nil {
+		return nil, err
+	}
+	return storageCfg, nil
 }
 
-func runS3Wizard(accessKey, secretKey, region, bucket string) (*storage.StorageConfig, error) {
+func runS3Wizard(accessKey, secretKey, region, bucket, profile string) (*storage.StorageConfig, error) {
 	fmt.Printf("  %sAmazon S3 Setup%s\n\n", colorBold, colorReset)
 	printInfo("You need an AWS S3 bucket and IAM credentials.")
 	fmt.Println()
@@ -645,8 +1279,34 @@ func runS3Wizard(accessKey, secretKey, region, bucket string) (*storage.StorageC
 		colorCyan, colorReset, colorDim, colorReset)
 	fmt.Printf("  %s2.%s Create access keys: %shttps://console.aws.amazon.com/iam/home#/security_credentials%s\n",
 		colorCyan, colorReset, colorDim, colorReset)
+	printInfo("   or use a profile from ~/.aws/credentials (aws configure)")
 	fmt.Println()
 
+	// Access keys given as flags settle the method, as does --aws-profile
+	useProfile := profile != ""
+	if !useProfile && accessKey == "" {
+		prompt := &survey.Select{
+			Message: "Authentication method:",
+			Options: []string{
+				"Access keys - stored in claude-sync's config",
+				"AWS shared credentials - ~/.aws/credentials, AWS_PROFILE, SSO",
+			},
+		}
+		var choice int
+		if err := askOne(prompt, &choice); err != nil {
+			return nil, err
+		}
+		if useProfile = choice == 1; useProfile {
+			prompt := &survey.Input{
+				Message: "AWS profile (optional):",
+				Help:    "A profile name from ~/.aws/config or ~/.aws/credentials. Leave blank for AWS_PROFILE or the default profile.",
+			}
+			if err := askOne(prompt, &profile); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	answers := struct {
 		AccessKey string
 		SecretKey string
@@ -659,22 +1319,24 @@ func runS3Wizard(accessKey, secretKey, region, bucket string) (*storage.StorageC
 		Bucket:    bucket,
 	}
 
-	questions := []*survey.Question{
-		{
+	var questions []*survey.Question
+	if !useProfile {
+		questions = append(questions, &survey.Question{
 			Name: "AccessKey",
 			Prompt: &survey.Input{
 				Message: "Access Key ID:",
 				Default: accessKey,
 			},
 			Validate: survey.Required,
-		},
-		{
+		}, &survey.Question{
 			Name: "SecretKey",
 			Prompt: &survey.Password{
 				Message: "Secret Access Key:",
 			},
 			Validate: survey.Required,
-		},
+		})
+	}
+	questions = append(questions, []*survey.Question{
 		{
 			Name: "Region",
 			Prompt: &survey.Select{
@@ -717,12 +1379,21 @@ func runS3Wizard(accessKey, secretKey, region, bucket string) (*storage.StorageC
 			},
 			Validate: survey.Required,
 		},
-	}
+	}...)
 
-	if err := survey.Ask(questions, &answers); err != nil {
+	if err := ask(questions, &answers); err != nil {
 		return nil, err
 	}
 
+	if useProfile {
+		return &storage.StorageConfig{
+			Provider:              storage.ProviderS3,
+			Bucket:                answers.Bucket,
+			Region:                answers.Region,
+			UseDefaultCredentials: true,
+			Profile:               profile,
+		}, nil
+	}
 	return &storage.StorageConfig{
 		Provider:        storage.ProviderS3,
 		Bucket:          answers.Bucket,
@@ -750,7 +1421,7 @@ func runS3CompatibleWizard(endpoint, accessKey, secretKey, region, bucket string
 			Message: "S3 endpoint URL:",
 			Help:    "e.g. https://s3.us-west-004.backblazeb2.com (Backblaze B2)",
 		}
-		if err := survey.AskOne(q, &endpoint, survey.WithValidator(survey.Required)); err != nil {
+		if err := askOne(q, &endpoint, survey.WithValidator(survey.Required)); err != nil {
 			return nil, err
 		}
 	}
@@ -816,7 +1487,7 @@ func runS3CompatibleWizard(endpoint, accessKey, secretKey, region, bucket string
 		},
 	}
 
-	if err := survey.Ask(questions, &answers); err != nil {
+	if err := ask(questions, &answers); err != nil {
 		return nil, err
 	}
 
@@ -880,7 +1551,7 @@ func runGCSWizard(projectID, credentialsFile, bucket string) (*storage.StorageCo
 		},
 	}
 
-	if err := survey.Ask(questions, &answers); err != nil {
+	if err := ask(questions, &answers); err != nil {
 		return nil, err
 	}
 
@@ -900,7 +1571,7 @@ func runGCSWizard(projectID, credentialsFile, bucket string) (*storage.StorageCo
 				return files
 			},
 		}
-		if err := survey.AskOne(prompt, &credPath, survey.WithValidator(func(ans interface{}) error {
+		if err := askOne(prompt, &credPath, survey.WithValidator(func(ans interface{}) error {
 			path := ans.(string)
 			if path == "" {
 				return fmt.Errorf("credentials file path is required")
@@ -987,7 +1658,7 @@ func runWebDAVWizard(webdavURL, username, password, pathPrefix string) (*storage
 		},
 	}
 
-	if err := survey.Ask(questions, &answers); err != nil {
+	if err := ask(questions, &answers); err != nil {
 		return nil, err
 	}
 
@@ -1011,24 +1682,72 @@ func runWebDAVWizard(webdavURL, username, password, pathPrefix string) (*storage
 }
 
 func pushCmd() *cobra.Command {
-	var includeMCP bool
+	var includeMCP, confirmDeletes, ignoreWindows, force, review, changedOnly bool
 
 	cmd := &cobra.Command{
-		Use:   "push",
+		Use:   "push [path...]",
 		Short: "Upload local changes to cloud storage",
-		Long:  `Encrypt and upload changed files from ~/.claude to cloud storage.`,
+		Long: `Encrypt and upload changed files from ~/.claude to cloud storage.
+
+Given paths (files or directories, relative to ~/.claude or absolute), only
+the changes at or under them are pushed; the rest wait for the next push.
+
+A push that would delete more remote files than delete_threshold (25 by
+default) asks first, or fails when it can't ask; --confirm-deletes allows it.
+This stops a directory that is briefly missing, like an unmounted volume,
+from wiping the remote copy.
+
+Paths with sync_windows are held back outside their windows; --ignore-windows
+pushes them anyway.
+
+--force encrypts and uploads every file in the sync paths, changed or not,
+to repair a corrupt remote (see 'claude-sync verify'), re-encrypt files after
+a key change, or recover from a sync state that no longer matches the files.
+Local copies win: pull first, or changes other devices pushed since this
+device last pulled are overwritten.
+
+--review lists the changes as a checklist before anything is uploaded;
+unchecked ones stay local and are offered again on the next push.
+
+--changed-only first compares the local files with the sync state, without
+contacting storage, and stops there when nothing changed. The auto-sync hooks
+(see 'claude-sync auto') push this way, since Claude Code runs them after
+every response.`,
+		ValidArgsFunction: completeSyncPaths,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if review && jsonOutput {
+				return fmt.Errorf("--review can't be combined with --json")
+			}
+			if len(args) > 0 && (force || review) {
+				return fmt.Errorf("paths can't be combined with --force or --review")
+			}
+			if changedOnly && (force || review) {
+				return fmt.Errorf("--changed-only can't be combined with --force or --review")
+			}
 			cfg, err := config.Load()
 			if err != nil {
 				return err
 			}
+			if changedOnly && !localChangesPending(cfg, includeMCP) {
+				if jsonOutput {
+					return printJSON(&sync.SyncResult{})
+				}
+				if !quiet {
+					fmt.Printf("%s✓%s No changes to push\n", colorGreen, colorReset)
+				}
+				return nil
+			}
 
 			syncer, err := sync.NewSyncer(cfg, quiet)
 			if err != nil {
 				return err
 			}
+			if force && !quiet {
+				printWarning("Uploading every file; remote changes not pulled yet are overwritten")
+			}
 
 			if !quiet {
+				bar := newTransferProgress("↑", colorCyan)
 				syncer.SetProgressFunc(func(event sync.ProgressEvent) {
 					if event.Error != nil {
 						fmt.Printf("\r%s✗%s %s: %v\n", colorYellow, colorReset, event.Path, event.Error)
@@ -1043,17 +1762,8 @@ func pushCmd() *cobra.Command {
 							fmt.Printf("%s⋯%s %s\n", colorDim, colorReset, event.Path)
 						}
 					case "upload":
-						if event.Complete {
-							// Final newline after progress
-						} else {
-							// Clear line and show progress
-							progress := fmt.Sprintf("[%d/%d]", event.Current, event.Total)
-							shortPath := util.TruncatePath(event.Path, 50)
-							fmt.Printf("\r%s↑%s %s%s%s %s (%s)%s",
-								colorCyan, colorReset,
-								colorDim, progress, colorReset,
-								shortPath, util.FormatSize(event.Size),
-								strings.Repeat(" ", 10))
+						if !event.Complete {
+							bar.print(event)
 						}
 					case "delete":
 						shortPath := util.TruncatePath(event.Path, 50)
@@ -1067,7 +1777,39 @@ func pushCmd() *cobra.Command {
 			}
 
 			ctx := context.Background()
-			result, err := syncer.Push(ctx)
+			syncer.SetConfirmDeletes(confirmDeletes)
+			syncer.SetIgnoreWindows(ignoreWindows)
+			syncer.SetForceUpload(force)
+			push := syncer.Push
+			if review || len(args) > 0 {
+				var selected []string
+				if review {
+					selected, err = reviewPush(syncer)
+				} else {
+					selected, err = pendingUnder(syncer, args)
+				}
+				if err != nil || selected == nil {
+					return err
+				}
+				push = func(ctx context.Context) (*sync.SyncResult, error) {
+					return syncer.PushSelected(ctx, selected)
+				}
+			}
+			result, err := push(ctx)
+			var moved *sync.ClaudeDirMovedError
+			if errors.As(err, &moved) && !quiet && isInteractiveTerminal() && confirmAdopt(moved.Move) {
+				if err := adoptMove(syncer, moved.Move); err != nil {
+					return err
+				}
+				result, err = push(ctx)
+			}
+			var tooMany *sync.TooManyDeletesError
+			if errors.As(err, &tooMany) && !quiet && isInteractiveTerminal() && confirmManyDeletes(tooMany) {
+				syncer.SetConfirmDeletes(true)
+				result, err = push(ctx)
+			}
+			recordActivity("push", result, err)
+			recordSyncStatus(syncer, "push", result, err)
 			if err != nil {
 				return err
 			}
@@ -1100,6 +1842,40 @@ func pushCmd() *cobra.Command {
 						}
 					}
 				}
+				printJSONLIssues(result.InvalidJSONL)
+				if len(result.Paused) > 0 {
+					fmt.Printf("%s%d change(s) held back by paused paths (see 'claude-sync pause')%s\n",
+						colorDim, len(result.Paused), colorReset)
+				}
+				if len(result.Deferred) > 0 {
+					fmt.Printf("%s%d change(s) held back until their sync window opens (--ignore-windows pushes them now)%s\n",
+						colorDim, len(result.Deferred), colorReset)
+				}
+				if len(result.Skipped) > 0 {
+					fmt.Printf("%s%d change(s) not selected; the next push offers them again.%s\n",
+						colorDim, len(result.Skipped), colorReset)
+				}
+				if result.Proposal != nil {
+					fmt.Printf("%s!%s %d change(s) to reviewed command sets proposed as %s; another device must run 'claude-sync approve %s'\n",
+						colorYellow, colorReset, len(result.Proposal.Changes), result.Proposal.ID, result.Proposal.ID)
+				}
+				if len(result.AwaitingReview) > 0 {
+					fmt.Printf("%s%d change(s) still waiting for review (see 'claude-sync proposals')%s\n",
+						colorDim, len(result.AwaitingReview), colorReset)
+				}
+				for _, path := range result.Rejected {
+					printWarning(fmt.Sprintf("Proposed change to %s was rejected; it will be proposed again on the next push", path))
+				}
+			}
+			if jsonOutput {
+				if err := printJSON(result); err != nil {
+					return err
+				}
+			}
+			if result.BucketMoved != nil {
+				if err := followBucketMove(cfg, result.BucketMoved); err != nil {
+					return err
+				}
 			}
 
 			// MCP sync if enabled
@@ -1109,16 +1885,161 @@ func pushCmd() *cobra.Command {
 				}
 			}
 
-			return nil
+			return syncStatus(result)
 		},
 	}
 
 	cmd.Flags().BoolVar(&includeMCP, "include-mcp", false, "Also sync MCP server configs from ~/.claude.json")
+	cmd.Flags().BoolVar(&confirmDeletes, "confirm-deletes", false, "Allow deleting more remote files than delete_threshold")
+	cmd.Flags().BoolVar(&ignoreWindows, "ignore-windows", false, "Push paths whose sync window is closed")
+	cmd.Flags().BoolVar(&force, "force", false, "Upload every file, changed or not, to repair the remote")
+	cmd.Flags().BoolVar(&review, "review", false, "Choose which changes to push before uploading")
+	cmd.Flags().BoolVar(&changedOnly, "changed-only", false, "Return at once when no local file changed since the last sync")
 	return cmd
 }
 
+// localChangesPending reports whether a push could have anything to do,
+// judged from the local files and sync state alone. When that can't be told,
+// it says yes and leaves the push to report the problem.
+func localChangesPending(cfg *config.Config, includeMCP bool) bool {
+	var state *sync.SyncState
+	var err error
+	if cfg.StateDirOverride != "" {
+		state, err = sync.LoadStateFromDir(cfg.StateDirOverride)
+	} else {
+		state, err = sync.LoadState()
+	}
+	if err != nil {
+		return true
+	}
+	claudeDir := config.ClaudeDir()
+	if cfg.ClaudeDirOverride != "" {
+		claudeDir = cfg.ClaudeDirOverride
+	}
+	pending, err := sync.LocalChangesPending(cfg, state, claudeDir, includeMCP || cfg.IsMCPSyncEnabled())
+	return pending || err != nil
+}
+
+// reviewPush lists the changes a push would make as a checklist and returns
+// the paths chosen, or nil when there is nothing to push or nothing was
+// chosen.
+func reviewPush(syncer *sync.Syncer) ([]string, error) {
+	changes, err := syncer.PendingPush()
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		fmt.Printf("%s✓%s No changes to push\n", colorGreen, colorReset)
+		return nil, nil
+	}
+
+	options := pushChoices(changes)
+	prompt := &survey.MultiSelect{
+		Message:  fmt.Sprintf("Push which changes? (%d pending)", len(options)),
+		Options:  options,
+		Default:  options,
+		PageSize: 15,
+		Help:     "+ new file, ~ changed file, - deleted here (the remote copy is deleted too)",
+	}
+	var chosen []int
+	if err := askOne(prompt, &chosen); err != nil {
+		return nil, err
+	}
+	if len(chosen) == 0 {
+		fmt.Println("  Nothing selected; no files pushed.")
+		return nil, nil
+	}
+
+	selected := make([]string, len(chosen))
+	for i, index := range chosen {
+		selected[i] = changes[index].Path
+	}
+	fmt.Println()
+	return selected, nil
+}
+
+// pendingUnder returns the pending changes at or under paths, for
+// 'push <path>...', or nil after saying there are none.
+func pendingUnder(syncer *sync.Syncer, paths []string) ([]string, error) {
+	var roots []string
+	for _, path := range paths {
+		rel, err := claudeRelPath(config.ClaudeDir(), path)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, rel)
+	}
+
+	changes, err := syncer.PendingPush()
+	if err != nil {
+		return nil, err
+	}
+	var selected []string
+	for _, c := range changes {
+		for _, root := range roots {
+			if root == "." || c.Path == root || strings.HasPrefix(c.Path, root+"/") {
+				selected = append(selected, c.Path)
+				break
+			}
+		}
+	}
+	if len(selected) == 0 {
+		if jsonOutput {
+			return nil, printJSON(&sync.SyncResult{})
+		}
+		if !quiet {
+			fmt.Printf("%s✓%s No changes to push under %s\n", colorGreen, colorReset, strings.Join(paths, ", "))
+		}
+		return nil, nil
+	}
+	return selected, nil
+}
+
+// pushChoices lists changes as checklist options, in the same order.
+func pushChoices(changes []sync.FileChange) []string {
+	options := make([]string, len(changes))
+	for i, c := range changes {
+		switch c.Action {
+		case "add":
+			options[i] = fmt.Sprintf("+ %s (%s)", c.Path, util.FormatSize(c.LocalSize))
+		case "delete":
+			options[i] = fmt.Sprintf("- %s (deleted)", c.Path)
+		default:
+			options[i] = fmt.Sprintf("~ %s (%s, modified %s)", c.Path, util.FormatSize(c.LocalSize),
+				util.FormatRelativeTime(c.LocalTime, time.Now()))
+		}
+	}
+	return options
+}
+
+// confirmManyDeletes lists the remote deletions a push was stopped for and
+// asks whether to go ahead.
+func confirmManyDeletes(e *sync.TooManyDeletesError) bool {
+	fmt.Printf("\r%s!%s This push would delete %d remote files:\n", colorYellow, colorReset, len(e.Paths))
+	const shown = 10
+	for i, path := range e.Paths {
+		if i == shown {
+			fmt.Printf("  %s... and %d more%s\n", colorDim, len(e.Paths)-shown, colorReset)
+			break
+		}
+		fmt.Printf("  %s-%s %s\n", colorYellow, colorReset, path)
+	}
+	fmt.Printf("%sIf a directory is missing (e.g. an unmounted volume), answer no and restore it first.%s\n", colorDim, colorReset)
+
+	var confirmed bool
+	prompt := &survey.Confirm{
+		Message: fmt.Sprintf("Delete these %d files from the remote?", len(e.Paths)),
+		Default: false,
+	}
+	if err := askOne(prompt, &confirmed); err != nil {
+		return false
+	}
+	return confirmed
+}
+
 func pullCmd() *cobra.Command {
-	var dryRun, force, includeMCP, rebuildHistory bool
+	var dryRun, force, includeMCP, rebuildHistory, repairJSONL, ignoreWindows, interactive bool
+	var planID string
 
 	cmd := &cobra.Command{
 		Use:   "pull",
@@ -1131,20 +2052,53 @@ before any files are overwritten. Use --dry-run to preview changes first.
 Examples:
   claude-sync pull              # Pull with safety prompts
   claude-sync pull --dry-run    # Preview what would be changed
-  claude-sync pull --force      # Skip confirmation prompts`,
+  claude-sync pull --plan ID    # Apply exactly what --dry-run previewed
+  claude-sync pull -i           # Choose which files to pull
+  claude-sync pull --force      # Skip confirmation prompts
+
+Paths with sync_windows are left alone outside their windows; --ignore-windows
+pulls them anyway.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := config.Load()
 			if err != nil {
 				return err
 			}
+			if repairJSONL {
+				cfg.RepairJSONL = true
+			}
 
 			syncer, err := sync.NewSyncer(cfg, quiet)
 			if err != nil {
 				return err
 			}
+			syncer.SetIgnoreWindows(ignoreWindows)
 
 			ctx := context.Background()
 
+			if interactive && (dryRun || planID != "" || jsonOutput) {
+				return fmt.Errorf("--interactive can't be combined with --dry-run, --plan or --json")
+			}
+
+			// Apply a plan a dry run saved, and nothing else
+			if planID != "" {
+				if dryRun {
+					return fmt.Errorf("--plan can't be combined with --dry-run")
+				}
+				plan, err := syncer.LoadPullPlan(planID)
+				if err != nil {
+					return err
+				}
+				err = executePull(ctx, syncer, plan, nil)
+				var status exitStatus
+				if err != nil && !errors.As(err, &status) {
+					return err
+				}
+				if rmErr := syncer.RemovePullPlan(); rmErr != nil {
+					return rmErr
+				}
+				return err
+			}
+
 			// Check for first pull with existing local files
 			if !syncer.HasState() {
 				hasExisting, err := hasExistingClaudeFiles(cfg.Scope)
@@ -1162,7 +2116,12 @@ Examples:
 				return showPullPreview(ctx, syncer)
 			}
 
+			if interactive {
+				return runInteractivePull(ctx, syncer)
+			}
+
 			if !quiet {
+				bar := newTransferProgress("↓", colorGreen)
 				syncer.SetProgressFunc(func(event sync.ProgressEvent) {
 					if event.Error != nil {
 						fmt.Printf("\r%s✗%s %s: %v\n", colorYellow, colorReset, event.Path, event.Error)
@@ -1177,17 +2136,8 @@ Examples:
 							fmt.Printf("%s⋯%s %s\n", colorDim, colorReset, event.Path)
 						}
 					case "download":
-						if event.Complete {
-							// Final newline after progress
-						} else {
-							// Clear line and show progress
-							progress := fmt.Sprintf("[%d/%d]", event.Current, event.Total)
-							shortPath := util.TruncatePath(event.Path, 50)
-							fmt.Printf("\r%s↓%s %s%s%s %s (%s)%s",
-								colorGreen, colorReset,
-								colorDim, progress, colorReset,
-								shortPath, util.FormatSize(event.Size),
-								strings.Repeat(" ", 10))
+						if !event.Complete {
+							bar.print(event)
 						}
 					case "conflict":
 						fmt.Printf("\r%s⚠%s Conflict: %s (saved as .conflict)\n",
@@ -1197,6 +2147,15 @@ Examples:
 			}
 
 			result, err := syncer.Pull(ctx)
+			var moved *sync.ClaudeDirMovedError
+			if errors.As(err, &moved) && !quiet && isInteractiveTerminal() && confirmAdopt(moved.Move) {
+				if err := adoptMove(syncer, moved.Move); err != nil {
+					return err
+				}
+				result, err = syncer.Pull(ctx)
+			}
+			recordActivity("pull", result, err)
+			recordSyncStatus(syncer, "pull", result, err)
 			if err != nil {
 				return err
 			}
@@ -1221,6 +2180,9 @@ Examples:
 					if len(parts) > 0 {
 						fmt.Printf("%s✓%s Pull complete: %s\n", colorGreen, colorReset, strings.Join(parts, ", "))
 					}
+					if result.Backup != "" {
+						fmt.Printf("%sReplaced files backed up to %s%s\n", colorDim, result.Backup, colorReset)
+					}
 
 					if len(result.Conflicts) > 0 {
 						fmt.Printf("\n%sConflicts (both local and remote changed):%s\n", colorYellow, colorReset)
@@ -1238,6 +2200,27 @@ Examples:
 						}
 					}
 				}
+				printJSONLIssues(result.InvalidJSONL)
+				printSettingsIssues(result.InvalidSettings)
+				printShadowedCommands(result.ShadowedCommands)
+			}
+			if jsonOutput {
+				if err := printJSON(result); err != nil {
+					return err
+				}
+			}
+
+			// Tampered objects weren't written, but the pull must not look fine
+			for _, e := range result.Errors {
+				if errors.Is(e, sync.ErrIntegrity) {
+					return fmt.Errorf("some files failed the signed manifest check and were not pulled")
+				}
+			}
+
+			if result.BucketMoved != nil {
+				if err := followBucketMove(cfg, result.BucketMoved); err != nil {
+					return err
+				}
 			}
 
 			// MCP sync if enabled
@@ -1254,24 +2237,51 @@ Examples:
 				}
 			}
 
-			return nil
+			return syncStatus(result)
 		},
 	}
 
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be changed without making changes")
+	cmd.Flags().StringVar(&planID, "plan", "", "Apply exactly the plan a dry run printed, skipping files changed since")
 	cmd.Flags().BoolVar(&force, "force", false, "Overwrite existing files without confirmation")
 	cmd.Flags().BoolVar(&includeMCP, "include-mcp", false, "Also sync MCP server configs from ~/.claude.json")
 	cmd.Flags().BoolVar(&rebuildHistory, "rebuild-history", false, "Rebuild ~/.claude/history.jsonl from session files after pulling")
+	cmd.Flags().BoolVar(&repairJSONL, "repair-jsonl", false, "Cut corrupt trailing lines off pulled .jsonl files (kept as .corrupt files)")
+	cmd.Flags().BoolVar(&ignoreWindows, "ignore-windows", false, "Pull paths whose sync window is closed")
+	cmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Choose which new and changed remote files to pull")
 
 	return cmd
 }
 
 func statusCmd() *cobra.Command {
-	return &cobra.Command{
+	var remote, porcelain bool
+
+	cmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show pending local changes",
-		Long:  `Display files that have been added, modified, or deleted locally.`,
+		Long: `Display files that have been added, modified, or deleted locally.
+
+With --remote, also check the bucket and list what the next pull would
+download, overwrite, or turn into a conflict, so you can tell whether
+another device has pushed.
+
+--porcelain prints one line from the status file that push, pull and the
+daemon keep up to date, without loading keys, scanning files or contacting
+storage, for shell prompts and status bars:
+
+  state=pending pending=3 conflicts=0 last_sync=1760601600
+
+state is error (the last push or pull failed), conflicts, pending, ok, or
+unknown before the first sync; last_sync is the Unix time of the last push
+or pull that succeeded, 0 if none has.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if porcelain {
+				if remote || jsonOutput {
+					return fmt.Errorf("--porcelain can't be combined with --remote or --json")
+				}
+				return printPorcelainStatus()
+			}
+
 			cfg, err := config.Load()
 			if err != nil {
 				return err
@@ -1287,12 +2297,67 @@ func statusCmd() *cobra.Command {
 			if err != nil {
 				return err
 			}
+			if err := syncer.RefreshSyncStatus(changes); err != nil && verbose {
+				fmt.Fprintf(os.Stderr, "%s⚠%s Failed to update the status file: %v\n", colorYellow, colorReset, err)
+			}
+
+			var incoming *sync.PullPreview
+			if remote {
+				if incoming, err = syncer.PreviewPull(ctx); err != nil {
+					return fmt.Errorf("failed to check remote: %w", err)
+				}
+			}
+
+			if jsonOutput {
+				state := syncer.GetState()
+				out := statusJSON{
+					Changes:  emptyIfNil(changes),
+					Paused:   emptyIfNil(state.PausedPaths(time.Now())),
+					LastPush: state.LastPush,
+					LastPull: state.LastPull,
+					Incoming: incoming,
+				}
+				if stale, err := syncer.CheckStale(changes, time.Now()); err == nil && stale != nil {
+					out.StalePush = stale.LastPush
+				}
+				return printJSON(out)
+			}
+
+			if env := cfg.EnvOverrides(); verbose && len(env) > 0 {
+				fmt.Printf("%sStorage settings from the environment: %s%s\n\n", colorDim, strings.Join(env, ", "), colorReset)
+			}
+
+			if fsType := syncer.NetworkFS(); fsType != "" {
+				fmt.Printf("%s~/.claude is on a network filesystem (%s): pull compares contents instead of mtimes.%s\n\n",
+					colorDim, fsType, colorReset)
+			}
+
+			if paused := syncer.GetState().PausedPaths(time.Now()); len(paused) > 0 {
+				fmt.Println("Paused (not pushed or pulled):")
+				for _, p := range paused {
+					fmt.Printf("  %s %s%s%s\n", p.Path, colorDim, describePause(p.Pause), colorReset)
+				}
+				fmt.Println()
+			}
 
 			if len(changes) == 0 {
 				fmt.Println("No local changes")
+				if incoming != nil {
+					fmt.Println()
+					printIncoming(incoming)
+				}
 				return nil
 			}
 
+			stale, err := syncer.CheckStale(changes, time.Now())
+			if err != nil {
+				printWarning(err.Error())
+			} else if stale != nil {
+				days := int(time.Since(stale.LastPush).Hours() / 24)
+				fmt.Printf("%s⚠%s You haven't pushed in %d days and have %d unpushed change(s). Run 'claude-sync push' to keep your devices in step.\n\n",
+					colorYellow, colorReset, days, stale.Pending)
+			}
+
 			fmt.Printf("%d change(s):\n\n", len(changes))
 
 			var added, modified, deleted []sync.FileChange
@@ -1331,25 +2396,115 @@ func statusCmd() *cobra.Command {
 				fmt.Println()
 			}
 
+			if incoming != nil {
+				printIncoming(incoming)
+				fmt.Println()
+			}
+
 			state := syncer.GetState()
 			if !state.LastPush.IsZero() {
-				fmt.Printf("Last push: %s\n", state.LastPush.Format(time.RFC3339))
+				fmt.Printf("Last push: %s\n", formatTime(state.LastPush))
 			}
 			if !state.LastPull.IsZero() {
-				fmt.Printf("Last pull: %s\n", state.LastPull.Format(time.RFC3339))
+				fmt.Printf("Last pull: %s\n", formatTime(state.LastPull))
 			}
 
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&remote, "remote", false, "Also list what the next pull would bring in")
+	cmd.Flags().BoolVar(&porcelain, "porcelain", false, "Print the last recorded sync state on one line, for shell prompts")
+	return cmd
+}
+
+// printPorcelainStatus prints the status file as one line of key=value
+// fields for 'status --porcelain'. It reads nothing else, so it's fast.
+func printPorcelainStatus() error {
+	st, err := sync.LoadSyncStatus()
+	if err != nil {
+		return err
+	}
+	var lastSync int64
+	if !st.LastSync.IsZero() {
+		lastSync = st.LastSync.Unix()
+	}
+	fmt.Printf("state=%s pending=%d conflicts=%d last_sync=%d\n", st.State(), st.Pending, st.Conflicts, lastSync)
+	return nil
+}
+
+// printIncoming lists what a previewed pull would change locally, for
+// 'status --remote'.
+func printIncoming(preview *sync.PullPreview) {
+	total := len(preview.WouldDownload) + len(preview.WouldOverwrite) + len(preview.WouldConflict)
+	if total == 0 {
+		fmt.Println("No incoming changes")
+		return
+	}
+
+	fmt.Printf("%d incoming change(s):\n\n", total)
+	if len(preview.WouldDownload) > 0 {
+		fmt.Println("New remote files:")
+		for _, f := range preview.WouldDownload {
+			fmt.Printf("  %s+%s %s (%s)\n", colorGreen, colorReset, f.Path, util.FormatSize(f.RemoteSize))
+		}
+		fmt.Println()
+	}
+	if len(preview.WouldOverwrite) > 0 {
+		fmt.Println("Changed remotely (pull overwrites the local copy):")
+		for _, f := range preview.WouldOverwrite {
+			fmt.Printf("  %s~%s %s (remote pushed %s)\n", colorYellow, colorReset, f.Path, formatTime(f.RemoteTime))
+		}
+		fmt.Println()
+	}
+	if len(preview.WouldConflict) > 0 {
+		fmt.Println("Changed on both sides (pull saves a conflict):")
+		for _, f := range preview.WouldConflict {
+			fmt.Printf("  %s!%s %s\n", colorYellow, colorReset, f.Path)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%s%s to download; run 'claude-sync pull' to bring them in.%s\n", colorDim, util.FormatSize(preview.DownloadBytes), colorReset)
 }
 
 func diffCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "diff",
+	var from, to string
+	var unified int
+
+	cmd := &cobra.Command{
+		Use:   "diff [path]",
 		Short: "Show differences between local and remote",
-		Long:  `Compare local ~/.claude with remote cloud storage.`,
+		Long: `Compare local ~/.claude with remote cloud storage.
+
+Given a path, show how the file's content differs instead: the remote copy
+is downloaded and decrypted in memory and compared line by line, as a
+unified diff from the local file (---) to the remote one (+++). Nothing is
+written.
+
+With --from, compare two remote states instead. Every push records a
+snapshot of the remote file set; refer to one by ID, by date or timestamp
+(the latest snapshot at or before it), or by age such as 7d or 12h.
+--to defaults to the current remote state.
+
+Examples:
+  claude-sync diff                          # Local vs remote
+  claude-sync diff CLAUDE.md                # What a pull would change in CLAUDE.md
+  claude-sync diff --from 2024-05-01        # What changed since May 1
+  claude-sync diff --from 14d --to 7d       # What changed the week before last
+  claude-sync diff --from 20240501T120000Z-laptop --to current`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeSyncPaths,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 && (from != "" || to != "") {
+				return fmt.Errorf("--from and --to compare the whole remote; they can't be used with a path")
+			}
+			if len(args) == 1 && jsonOutput {
+				return fmt.Errorf("--json isn't supported with a path; use 'claude-sync cat' to read the remote copy")
+			}
+			if unified < 0 {
+				return fmt.Errorf("--unified must be 0 or more")
+			}
+
 			cfg, err := config.Load()
 			if err != nil {
 				return err
@@ -1361,10 +2516,28 @@ func diffCmd() *cobra.Command {
 			}
 
 			ctx := context.Background()
+
+			if len(args) == 1 {
+				relPath, err := claudeRelPath(config.ClaudeDir(), args[0])
+				if err != nil {
+					return err
+				}
+				return runContentDiff(ctx, syncer, relPath, unified)
+			}
+			if from != "" {
+				return runSnapshotDiff(ctx, syncer, from, to)
+			}
+			if to != "" {
+				return fmt.Errorf("--to requires --from")
+			}
+
 			entries, err := syncer.Diff(ctx)
 			if err != nil {
 				return err
 			}
+			if jsonOutput {
+				return printJSON(emptyIfNil(entries))
+			}
 
 			if len(entries) == 0 {
 				fmt.Println("No files found")
@@ -1405,6 +2578,7 @@ func diffCmd() *cobra.Command {
 				fmt.Printf("Modified (%d files):\n", len(modified))
 				for _, e := range modified {
 					fmt.Printf("  ~ %s (local: %s, remote: %s)\n", e.Path, util.FormatSize(e.LocalSize), util.FormatSize(e.RemoteSize))
+					fmt.Printf("    %slocal modified %s, remote pushed %s%s\n", colorDim, formatTime(e.LocalTime), formatTime(e.RemoteTime), colorReset)
 				}
 				fmt.Println()
 			}
@@ -1415,443 +2589,3936 @@ func diffCmd() *cobra.Command {
 			return nil
 		},
 	}
-}
 
-type conflictFile struct {
-	ConflictPath string
-	OriginalPath string
-	Timestamp    string
-}
+	cmd.Flags().StringVar(&from, "from", "", "Compare remote snapshots starting at this snapshot ID, date, or age (e.g. 7d)")
+	cmd.Flags().StringVar(&to, "to", "", "End snapshot for --from (default: current remote state)")
+	cmd.Flags().IntVarP(&unified, "unified", "U", 3, "Lines of context around each change, with a path")
 
-func conflictsCmd() *cobra.Command {
-	var listOnly bool
-	var resolveAll string
+	return cmd
+}
 
-	cmd := &cobra.Command{
-		Use:   "conflicts",
-		Short: "List and resolve sync conflicts",
-		Long: `Find and resolve conflicts from sync operations.
+// runContentDiff prints a unified diff from the local copy of relPath to
+// the remote one. A file missing on one side diffs against /dev/null.
+func runContentDiff(ctx context.Context, syncer *sync.Syncer, relPath string, contextLines int) error {
+	local, err := os.ReadFile(filepath.Join(config.ClaudeDir(), relPath))
+	localMissing := os.IsNotExist(err)
+	if err != nil && !localMissing {
+		return err
+	}
+	remote, err := syncer.ReadRemote(ctx, relPath, 0)
+	remoteMissing := errors.Is(err, sync.ErrNotRemote)
+	if err != nil && !remoteMissing {
+		return err
+	}
+	if localMissing && remoteMissing {
+		return fmt.Errorf("%s is neither in ~/.claude nor in remote storage", relPath)
+	}
 
-When both local and remote files change, the remote version is saved
-as a .conflict file. Use this command to review and resolve them.
+	if diff.IsBinary(local) || diff.IsBinary(remote) {
+		if bytes.Equal(local, remote) {
+			fmt.Printf("%s✓%s %s is the same locally and remotely\n", colorGreen, colorReset, relPath)
+		} else {
+			fmt.Printf("Binary files differ: %s (local: %s, remote: %s)\n", relPath, util.FormatSize(int64(len(local))), util.FormatSize(int64(len(remote))))
+		}
+		return nil
+	}
+
+	localName, remoteName := "local/"+relPath, "remote/"+relPath
+	if localMissing {
+		localName = "/dev/null"
+	}
+	if remoteMissing {
+		remoteName = "/dev/null"
+	}
+	out := diff.Compute(local, remote).Unified(localName, remoteName, contextLines)
+	if out == "" {
+		fmt.Printf("%s✓%s %s is the same locally and remotely\n", colorGreen, colorReset, relPath)
+		return nil
+	}
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		out = colorizeDiff(out)
+	}
+	fmt.Print(out)
+	return nil
+}
+
+// colorizeDiff colors the lines of a unified diff for a terminal.
+func colorizeDiff(unified string) string {
+	lines := strings.SplitAfter(unified, "\n")
+	for i, line := range lines {
+		color := ""
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			color = colorBold
+		case strings.HasPrefix(line, "@@"):
+			color = colorCyan
+		case strings.HasPrefix(line, "-"):
+			color = colorYellow
+		case strings.HasPrefix(line, "+"):
+			color = colorGreen
+		}
+		if color != "" {
+			lines[i] = color + strings.TrimSuffix(line, "\n") + colorReset + "\n"
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+func runSnapshotDiff(ctx context.Context, syncer *sync.Syncer, from, to string) error {
+	fromSnap, err := syncer.LoadSnapshot(ctx, from)
+	if err != nil {
+		return err
+	}
+	toSnap, err := syncer.LoadSnapshot(ctx, to)
+	if err != nil {
+		return err
+	}
+
+	describe := func(snap *sync.Snapshot) string {
+		if snap.ID == sync.CurrentSnapshotRef {
+			return "current remote state"
+		}
+		return fmt.Sprintf("%s (%s, %s)", snap.ID, snap.DeviceID, formatTime(snap.CreatedAt))
+	}
+
+	changes := sync.DiffSnapshots(fromSnap, toSnap)
+	if jsonOutput {
+		return printJSON(emptyIfNil(changes))
+	}
+
+	fmt.Printf("%sFrom:%s %s\n", colorDim, colorReset, describe(fromSnap))
+	fmt.Printf("%sTo:%s   %s\n\n", colorDim, colorReset, describe(toSnap))
+
+	if len(changes) == 0 {
+		fmt.Printf("%s✓%s No changes between these snapshots\n", colorGreen, colorReset)
+		return nil
+	}
+
+	var added, removed, modified int
+	for _, c := range changes {
+		switch c.Status {
+		case "added":
+			added++
+			fmt.Printf("  %s+%s %s (%s)\n", colorGreen, colorReset, c.Path, util.FormatSize(c.ToSize))
+		case "removed":
+			removed++
+			fmt.Printf("  %s-%s %s\n", colorYellow, colorReset, c.Path)
+		case "modified":
+			modified++
+			fmt.Printf("  %s~%s %s (%s → %s)\n", colorCyan, colorReset, c.Path, util.FormatSize(c.FromSize), util.FormatSize(c.ToSize))
+		}
+	}
+
+	fmt.Printf("\nSummary: %d added, %d removed, %d modified\n", added, removed, modified)
+	return nil
+}
+
+func planCmd() *cobra.Command {
+	var offline bool
+
+	cmd := &cobra.Command{
+		Use:   "plan [file...]",
+		Short: "Show what push and pull would do",
+		Long: `Compute what 'claude-sync push' and 'claude-sync pull' would do, without
+changing anything. Name files (relative to ~/.claude, or absolute) to plan
+just those: their remote copies are looked up directly instead of listing the
+whole bucket.
+
+With --offline, the remote side comes from the listing cached by the last
+online plan, pull, or push on this device, and no network requests are made.
+Use it on a plane or while the provider is down; changes pushed from other
+devices since that listing won't show.
 
 Examples:
-  claude-sync conflicts              # Interactive resolution
-  claude-sync conflicts --list       # Just list conflicts
-  claude-sync conflicts --keep local # Keep all local versions
-  claude-sync conflicts --keep remote # Keep all remote versions`,
+  claude-sync plan
+  claude-sync plan CLAUDE.md agents/reviewer.md`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			claudeDir := config.ClaudeDir()
-
-			// Find all .conflict files
-			conflicts, err := findConflicts(claudeDir)
+			paths, err := claudeRelPaths(args)
 			if err != nil {
 				return err
 			}
 
-			if len(conflicts) == 0 {
-				fmt.Printf("%s✓%s No conflicts found\n", colorGreen, colorReset)
-				return nil
-			}
-
-			fmt.Printf("%sFound %d conflict(s):%s\n\n", colorYellow, len(conflicts), colorReset)
-
-			for i, c := range conflicts {
-				relOriginal, _ := filepath.Rel(claudeDir, c.OriginalPath)
-				fmt.Printf("  %s%d.%s %s\n", colorCyan, i+1, colorReset, relOriginal)
-				fmt.Printf("     %sConflict from: %s%s\n", colorDim, c.Timestamp, colorReset)
+			cfg, err := config.Load()
+			if err != nil {
+				return err
 			}
-			fmt.Println()
 
-			// List only mode
-			if listOnly {
-				return nil
+			// Offline, storage isn't opened at all: it may be unreachable,
+			// or not even configured on this device
+			newSyncer := sync.NewSyncer
+			if offline {
+				newSyncer = sync.NewOfflineSyncer
 			}
-
-			// Load sync state to update after resolution
-			state, err := sync.LoadState()
+			syncer, err := newSyncer(cfg, quiet)
 			if err != nil {
-				return fmt.Errorf("failed to load sync state: %w", err)
+				return err
 			}
 
-			// Batch resolve mode
-			if resolveAll != "" {
-				return batchResolveConflicts(conflicts, resolveAll, claudeDir, state)
+			plan, err := syncer.Plan(context.Background(), offline, paths)
+			if err != nil {
+				return err
 			}
 
-			// Interactive mode
-			return interactiveResolveConflicts(conflicts, claudeDir, state)
+			printPlan(plan)
+			return nil
 		},
 	}
 
-	cmd.Flags().BoolVarP(&listOnly, "list", "l", false, "Only list conflicts, don't resolve")
-	cmd.Flags().StringVar(&resolveAll, "keep", "", "Resolve all conflicts: 'local' or 'remote'")
+	cmd.Flags().BoolVar(&offline, "offline", false, "Use the cached remote listing; make no network requests")
 
 	return cmd
 }
 
-func findConflicts(claudeDir string) ([]conflictFile, error) {
-	var conflicts []conflictFile
+func printPlan(plan *sync.Plan) {
+	if plan.Offline {
+		fmt.Printf("%sOffline: remote listing from %s%s\n\n", colorDim, formatTime(plan.ListedAt), colorReset)
+	}
 
-	err := filepath.Walk(claudeDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip errors
+	if len(plan.Push) == 0 {
+		fmt.Printf("%s✓%s Push: nothing to upload\n", colorGreen, colorReset)
+	} else {
+		fmt.Printf("%sPush (%d):%s\n", colorBold, len(plan.Push), colorReset)
+		for _, c := range plan.Push {
+			switch c.Action {
+			case "add":
+				fmt.Printf("  %s+%s %s (%s)\n", colorGreen, colorReset, c.Path, util.FormatSize(c.LocalSize))
+			case "modify":
+				fmt.Printf("  %s~%s %s (%s)\n", colorCyan, colorReset, c.Path, util.FormatSize(c.LocalSize))
+			case "delete":
+				fmt.Printf("  %s-%s %s\n", colorYellow, colorReset, c.Path)
+			}
 		}
-		if info.IsDir() {
-			return nil
+	}
+	fmt.Println()
+
+	pull := plan.Pull
+	pulls := len(pull.WouldDownload) + len(pull.WouldOverwrite) + len(pull.WouldConflict)
+	if pulls == 0 {
+		fmt.Printf("%s✓%s Pull: already up to date\n", colorGreen, colorReset)
+	} else {
+		fmt.Printf("%sPull (%d):%s\n", colorBold, pulls, colorReset)
+		for _, f := range pull.WouldDownload {
+			fmt.Printf("  %s+%s %s (%s)\n", colorGreen, colorReset, f.Path, util.FormatSize(f.RemoteSize))
+		}
+		for _, f := range pull.WouldOverwrite {
+			fmt.Printf("  %s~%s %s (local: %s, remote: %s)\n", colorYellow, colorReset, f.Path,
+				util.FormatSize(f.LocalSize), util.FormatSize(f.RemoteSize))
+		}
+		for _, f := range pull.WouldConflict {
+			fmt.Printf("  %s!%s %s (both local and remote changed)\n", colorYellow, colorReset, f.Path)
 		}
+	}
 
-		// Look for .conflict. pattern
-		if strings.Contains(filepath.Base(path), ".conflict.") {
-			// Extract original path and timestamp
-			// Format: filename.ext.conflict.20260208-095132
-			parts := strings.Split(path, ".conflict.")
-			if len(parts) == 2 {
-				conflicts = append(conflicts, conflictFile{
-					ConflictPath: path,
-					OriginalPath: parts[0],
-					Timestamp:    parts[1],
-				})
+	fmt.Printf("\n%sSummary:%s %d to push, %d to pull, %d conflicts\n", colorBold, colorReset,
+		len(plan.Push), len(pull.WouldDownload)+len(pull.WouldOverwrite), len(pull.WouldConflict))
+}
+
+func restoreCmd() *cobra.Command {
+	var at string
+	var version int
+	var all, dryRun, force bool
+
+	cmd := &cobra.Command{
+		Use:   "restore [path]",
+		Short: "Restore earlier content from the remote version history",
+		Long: `Restore a file, or everything, from the versions kept on push.
+
+Versions are only stored while 'versioning: true' is set in the config.
+Without --at or --version, a single file is restored to the version before
+its latest push, undoing that push. --version 1 is the latest version.
+
+With --all, every file is restored as it was in the snapshot at --at (a
+snapshot ID, date, or age). Files created since then are left alone.
+
+Restored files are written to ~/.claude only; run 'claude-sync push'
+afterwards to make them current on your other devices.
+
+Examples:
+  claude-sync restore settings.json                 # Undo the last push
+  claude-sync restore settings.json --at 2024-05-01 # As it was on May 1
+  claude-sync restore agents/reviewer.md --version 3
+  claude-sync restore --all --at 2d --dry-run       # Preview a full restore`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeSyncPaths,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all && len(args) > 0 {
+				return fmt.Errorf("use either a path or --all, not both")
+			}
+			if !all && len(args) == 0 {
+				return fmt.Errorf("specify a path to restore, or --all with --at")
+			}
+			if all && (at == "" || version != 0) {
+				return fmt.Errorf("--all needs --at and can't be used with --version")
 			}
-		}
-		return nil
-	})
 
-	// Sort by timestamp (newest first)
-	sort.Slice(conflicts, func(i, j int) bool {
-		return conflicts[i].Timestamp > conflicts[j].Timestamp
-	})
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+
+			var plan *sync.RestorePlan
+			if all {
+				plan, err = syncer.PlanRestoreAll(ctx, at)
+			} else {
+				var relPath string
+				if relPath, err = claudeRelPath(config.ClaudeDir(), args[0]); err != nil {
+					return err
+				}
+				plan, err = syncer.PlanRestore(ctx, relPath, at, version)
+			}
+			if err != nil {
+				return err
+			}
+
+			printRestorePlan(plan)
+			if len(plan.Items) == 0 || dryRun {
+				return nil
+			}
+
+			if !force {
+				var confirm bool
+				prompt := &survey.Confirm{
+					Message: fmt.Sprintf("Overwrite %d local file(s) with these versions?", len(plan.Items)),
+					Default: false,
+				}
+				if err := askOne(prompt, &confirm); err != nil {
+					return err
+				}
+				if !confirm {
+					fmt.Println("  Cancelled.")
+					return nil
+				}
+			}
+
+			restored, err := syncer.Restore(ctx, plan)
+			if len(restored) > 0 {
+				printSuccess(fmt.Sprintf("Restored %d file(s)", len(restored)))
+				fmt.Printf("%sRun 'claude-sync push' to make them current on your other devices.%s\n", colorDim, colorReset)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&at, "at", "", "Restore as of this date, timestamp, age (e.g. 2d), or snapshot ID with --all")
+	cmd.Flags().IntVar(&version, "version", 0, "Restore the Nth newest stored version (1 is the latest)")
+	cmd.Flags().BoolVar(&all, "all", false, "Restore every file from the snapshot at --at")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be restored without changing anything")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation")
 
-	return conflicts, err
+	return cmd
 }
 
-func batchResolveConflicts(conflicts []conflictFile, keep string, claudeDir string, state *sync.SyncState) error {
-	keep = strings.ToLower(keep)
-	if keep != "local" && keep != "remote" {
-		return fmt.Errorf("--keep must be 'local' or 'remote'")
+func snapshotCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Create, list, and restore named checkpoints of the remote",
+		Long: `A named snapshot freezes the current remote manifest, so everything can
+be put back as it was at that point, e.g. before an experiment. Restoring
+uses the versions kept on push, so it needs 'versioning: true'; versions a
+named snapshot needs are exempt from versions_keep and versions_max_age.`,
 	}
+	cmd.AddCommand(
+		snapshotCreateCmd(),
+		snapshotListCmd(),
+		holdsLock(snapshotRestoreCmd()),
+		snapshotDeleteCmd(),
+	)
+	return cmd
+}
 
-	resolved := 0
-	for _, c := range conflicts {
-		if keep == "local" {
-			// Delete conflict file, keep local
-			if err := os.Remove(c.ConflictPath); err != nil {
-				fmt.Printf("%s✗%s Failed to remove %s: %v\n", colorYellow, colorReset, c.ConflictPath, err)
-				continue
+func snapshotCreateCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Freeze the current remote state under a name",
+		Long: `Freeze the current remote state under a name. Push first if you have
+local changes you want included.
+
+Examples:
+  claude-sync snapshot create before-experiment`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
 			}
-			fmt.Printf("%s✓%s Kept local: %s\n", colorGreen, colorReset, filepath.Base(c.OriginalPath))
-		} else {
-			// Replace local with conflict, delete conflict
-			if err := os.Rename(c.ConflictPath, c.OriginalPath); err != nil {
-				fmt.Printf("%s✗%s Failed to replace %s: %v\n", colorYellow, colorReset, c.OriginalPath, err)
-				continue
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
 			}
-			fmt.Printf("%s✓%s Kept remote: %s\n", colorGreen, colorReset, filepath.Base(c.OriginalPath))
-		}
 
-		// Update state with the resolved file's hash
-		relPath, _ := filepath.Rel(claudeDir, c.OriginalPath)
-		if info, err := os.Stat(c.OriginalPath); err == nil {
-			if hash, err := sync.HashFile(c.OriginalPath); err == nil {
-				state.UpdateFile(relPath, info, hash)
-				state.MarkUploaded(relPath)
+			snap, err := syncer.CreateNamedSnapshot(context.Background(), args[0], force)
+			if err != nil {
+				return err
 			}
-		}
-		resolved++
+			fmt.Printf("%s✓%s Created snapshot %s (%d files)\n", colorGreen, colorReset, snap.ID, len(snap.Files))
+			if !cfg.Versioning {
+				printWarning("versioning is off, so this snapshot can't be restored from. Set 'versioning: true' in the config and push.")
+			}
+			return nil
+		},
 	}
 
-	// Save updated state
-	if resolved > 0 {
-		if err := state.Save(); err != nil {
-			fmt.Printf("%s⚠%s Warning: failed to save state: %v\n", colorYellow, colorReset, err)
-		}
-	}
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Replace an existing snapshot with the same name")
 
-	fmt.Printf("\n%s✓%s Resolved %d conflict(s)\n", colorGreen, colorReset, resolved)
-	return nil
+	return cmd
 }
 
-func interactiveResolveConflicts(conflicts []conflictFile, claudeDir string, state *sync.SyncState) error {
-	reader := bufio.NewReader(os.Stdin)
+func snapshotListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List named snapshots",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
 
-	fmt.Println("For each conflict, choose how to resolve:")
-	fmt.Printf("  %s[l]%s Keep local  %s[r]%s Keep remote  %s[d]%s Show diff  %s[s]%s Skip  %s[q]%s Quit\n\n",
-		colorCyan, colorReset,
-		colorCyan, colorReset,
-		colorCyan, colorReset,
-		colorCyan, colorReset,
-		colorCyan, colorReset)
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
 
-	resolved := 0
-	for i, c := range conflicts {
-		relOriginal, _ := filepath.Rel(claudeDir, c.OriginalPath)
+			snaps, err := syncer.ListNamedSnapshots(context.Background())
+			if err != nil {
+				return err
+			}
+			if len(snaps) == 0 {
+				fmt.Println("No named snapshots")
+				fmt.Printf("%sCreate one with: claude-sync snapshot create <name>%s\n", colorDim, colorReset)
+				return nil
+			}
+			for _, snap := range snaps {
+				fmt.Printf("  %s  %s%s%s\n", snap.ID, colorDim, formatTime(snap.CreatedAt), colorReset)
+			}
+			return nil
+		},
+	}
+}
 
-		// Get file sizes for context
-		localInfo, _ := os.Stat(c.OriginalPath)
-		conflictInfo, _ := os.Stat(c.ConflictPath)
+func snapshotRestoreCmd() *cobra.Command {
+	var dryRun, force bool
 
-		localSize := int64(0)
-		conflictSize := int64(0)
-		if localInfo != nil {
-			localSize = localInfo.Size()
-		}
-		if conflictInfo != nil {
-			conflictSize = conflictInfo.Size()
-		}
+	cmd := &cobra.Command{
+		Use:   "restore <name>",
+		Short: "Restore every file as it was in a named snapshot",
+		Long: `Restore every file as it was when the snapshot was created. Files
+created since then are left alone: a restore only writes content back, it
+never deletes.
+
+Restored files are written to ~/.claude only; run 'claude-sync push'
+afterwards to make them current on your other devices.
+
+Examples:
+  claude-sync snapshot restore before-experiment --dry-run
+  claude-sync snapshot restore before-experiment`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+			plan, err := syncer.PlanRestoreNamed(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			printRestorePlan(plan)
+			if len(plan.Items) == 0 || dryRun {
+				return nil
+			}
+
+			if !force {
+				var confirm bool
+				prompt := &survey.Confirm{
+					Message: fmt.Sprintf("Overwrite %d local file(s) with snapshot %s?", len(plan.Items), args[0]),
+					Default: false,
+				}
+				if err := askOne(prompt, &confirm); err != nil {
+					return err
+				}
+				if !confirm {
+					fmt.Println("  Cancelled.")
+					return nil
+				}
+			}
+
+			restored, err := syncer.Restore(ctx, plan)
+			if len(restored) > 0 {
+				fmt.Printf("%s✓%s Restored %d file(s)\n", colorGreen, colorReset, len(restored))
+				fmt.Printf("%sRun 'claude-sync push' to make them current on your other devices.%s\n", colorDim, colorReset)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be restored without changing anything")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation")
+
+	return cmd
+}
+
+func snapshotDeleteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <name>",
+		Short: "Delete a named snapshot",
+		Long: `Delete a named snapshot. The versions it kept become subject to
+retention again; no file content is deleted by this command.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			if err := syncer.DeleteNamedSnapshot(context.Background(), args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("%s✓%s Deleted snapshot %s\n", colorGreen, colorReset, args[0])
+			return nil
+		},
+	}
+}
+
+func printRestorePlan(plan *sync.RestorePlan) {
+	if plan.Snapshot != "" {
+		fmt.Printf("%sSnapshot:%s %s, %s\n\n", colorDim, colorReset, plan.Snapshot, formatTime(plan.At))
+	}
+
+	for _, item := range plan.Items {
+		from := fmt.Sprintf("version from %s", formatTime(item.Version.CreatedAt))
+		if item.Version.Device != "" {
+			from += " on " + item.Version.Device
+		}
+		switch item.Action {
+		case "create":
+			fmt.Printf("  %s+%s %s (%s)\n", colorGreen, colorReset, item.Path, from)
+		case "modify":
+			fmt.Printf("  %s~%s %s (local: %s, %s)\n", colorYellow, colorReset, item.Path, util.FormatSize(item.LocalSize), from)
+		}
+	}
+	for _, path := range plan.Missing {
+		fmt.Printf("  %s!%s %s (no stored version; left as is)\n", colorYellow, colorReset, path)
+	}
+
+	if len(plan.Items) == 0 && len(plan.Missing) == 0 {
+		fmt.Printf("%s✓%s Nothing to restore: local files already match\n", colorGreen, colorReset)
+		return
+	}
+	fmt.Printf("\nSummary: %d to restore, %d unchanged, %d without a stored version\n",
+		len(plan.Items), len(plan.Unchanged), len(plan.Missing))
+}
+
+func rollbackCmd() *cobra.Command {
+	var pushes int
+	var dryRun, force bool
+
+	cmd := &cobra.Command{
+		Use:   "rollback",
+		Short: "Undo this device's last push on the remote",
+		Long: `Put the remote back as it was before this device's last push (or last
+--pushes N pushes), for when a bad local state got pushed.
+
+Files those pushes modified or deleted are put back from the versions kept
+on push, so this needs 'versioning: true'; files they added are deleted
+(to the trash, if enabled). Files another device has pushed since are left
+alone.
+
+Only the remote changes. Run 'claude-sync pull' afterwards to bring this
+device's ~/.claude back in line; files the pushes added stay on disk.
+
+Examples:
+  claude-sync rollback --dry-run     # Show what the last push changed
+  claude-sync rollback --pushes 2    # Undo the last two pushes`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+			plan, err := syncer.PlanRollback(ctx, pushes)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("%sRolling back to:%s %s, %s\n\n", colorDim, colorReset,
+				plan.Before.ID, formatTime(plan.Before.CreatedAt))
+			for _, item := range plan.Items {
+				switch item.Action {
+				case "revert":
+					fmt.Printf("  %s~%s %s (version from %s)\n", colorYellow, colorReset, item.Path,
+						formatTime(item.Version.CreatedAt))
+				case "delete":
+					fmt.Printf("  %s-%s %s\n", colorYellow, colorReset, item.Path)
+				}
+			}
+			for _, path := range plan.Skipped {
+				fmt.Printf("  %s!%s %s (changed by another device since; left as is)\n", colorYellow, colorReset, path)
+			}
+			for _, path := range plan.Missing {
+				fmt.Printf("  %s!%s %s (no stored version; left as is)\n", colorYellow, colorReset, path)
+			}
+			if len(plan.Items) == 0 {
+				fmt.Printf("%s✓%s Nothing to roll back\n", colorGreen, colorReset)
+				return nil
+			}
+			fmt.Printf("\nSummary: %d to roll back, %d skipped, %d without a stored version\n",
+				len(plan.Items), len(plan.Skipped), len(plan.Missing))
+			if dryRun {
+				return nil
+			}
+
+			if !force {
+				var confirm bool
+				prompt := &survey.Confirm{
+					Message: fmt.Sprintf("Change %d remote file(s)?", len(plan.Items)),
+					Default: false,
+				}
+				if err := askOne(prompt, &confirm); err != nil {
+					return err
+				}
+				if !confirm {
+					fmt.Println("  Cancelled.")
+					return nil
+				}
+			}
+
+			done, err := syncer.Rollback(ctx, plan)
+			if len(done) > 0 {
+				fmt.Printf("%s✓%s Rolled back %d file(s)\n", colorGreen, colorReset, len(done))
+				fmt.Printf("%sRun 'claude-sync pull' to bring ~/.claude back in line.%s\n", colorDim, colorReset)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().IntVar(&pushes, "pushes", 1, "Number of this device's pushes to undo")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be rolled back without changing anything")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation")
+
+	return cmd
+}
+
+func historyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history <path>",
+		Short: "List the stored versions of a file",
+		Long: `List every version of a file kept on push, newest first, with when and
+where it was pushed, its size, and a content hash. The version matching the
+local file is marked, so you can pick one for 'claude-sync restore'.
+
+Versions are only stored while 'versioning: true' is set in the config.
+Each version is downloaded to compute its hash.
+
+Examples:
+  claude-sync history settings.json
+  claude-sync history ~/.claude/agents/reviewer.md`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeSyncPaths,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			claudeDir := config.ClaudeDir()
+			relPath, err := claudeRelPath(claudeDir, args[0])
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+			versions, err := syncer.ListVersions(ctx, relPath)
+			if err != nil {
+				return err
+			}
+			if len(versions) == 0 {
+				fmt.Printf("No stored versions of %s\n", relPath)
+				if !cfg.Versioning {
+					fmt.Printf("%sSet 'versioning: true' in the config to keep versions on push.%s\n", colorDim, colorReset)
+				}
+				return nil
+			}
+
+			details, err := syncer.DescribeVersions(ctx, versions)
+			if err != nil {
+				return err
+			}
+
+			localHash := syncer.LocalHash(relPath)
+
+			fmt.Printf("%sVersions of %s (%d):%s\n", colorBold, relPath, len(details), colorReset)
+			for i, d := range details {
+				marker := ""
+				if d.Hash == localHash {
+					marker = fmt.Sprintf("  %s← local%s", colorGreen, colorReset)
+				}
+				fmt.Printf("  %3d  %-38s  %-16s %9s  %s%s\n", i+1,
+					formatTime(d.CreatedAt),
+					util.TruncatePath(d.Device, 16), util.FormatSize(d.ContentSize), sync.ShortHash(d.Hash), marker)
+			}
+			fmt.Printf("\n%sRestore one with 'claude-sync restore %s --version N'.%s\n", colorDim, relPath, colorReset)
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func lsCmd() *cobra.Command {
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "ls [prefix]",
+		Short: "List the files in remote storage",
+		Long: `List the files in the bucket with their size and when they were last
+pushed, by the local path each syncs to. Give a prefix to list only the
+paths under it.
+
+Keys this device can't map to a local path (a path_map token it doesn't
+define, say) are listed by their raw key at the end. With --all, the
+bucket's own claude-sync data (metadata, versions, trash) is listed too.
+
+Examples:
+  claude-sync ls
+  claude-sync ls projects/
+  claude-sync ls ~/.claude/agents`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeSyncPaths,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			prefix := ""
+			if len(args) == 1 {
+				if prefix, err = claudeRelPath(config.ClaudeDir(), args[0]); err != nil {
+					return err
+				}
+				if prefix == "." {
+					prefix = ""
+				} else if strings.HasSuffix(args[0], "/") {
+					prefix += "/"
+				}
+			}
+
+			entries, err := syncer.ListRemote(context.Background(), prefix, all)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(emptyIfNil(entries))
+			}
+
+			if len(entries) == 0 {
+				if prefix == "" {
+					fmt.Println("No remote files")
+				} else {
+					fmt.Printf("No remote files under %s\n", prefix)
+				}
+				return nil
+			}
+
+			var total int64
+			for _, e := range entries {
+				total += e.Size
+				name := e.Path
+				if name == "" {
+					name = fmt.Sprintf("%s%s%s", colorDim, e.Key, colorReset)
+				}
+				fmt.Printf("%9s  %-38s  %s\n", util.FormatSize(e.Size), formatTime(e.LastModified), name)
+			}
+			fmt.Printf("\n%d object(s), %s (encrypted)\n", len(entries), util.FormatSize(total))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "Also list claude-sync's own objects (metadata, versions, trash)")
+	return cmd
+}
+
+func catCmd() *cobra.Command {
+	var version int
+
+	cmd := &cobra.Command{
+		Use:   "cat <path>",
+		Short: "Print a remote file",
+		Long: `Download, decrypt and print a file from remote storage to stdout, without
+writing anything under ~/.claude. Use it to check what a pull would bring
+in, or what an earlier version held.
+
+--version N prints the Nth newest stored version instead (1 is the latest);
+versions are only stored while 'versioning: true' is set.
+
+Examples:
+  claude-sync cat CLAUDE.md
+  claude-sync cat settings.json --version 2 | diff ~/.claude/settings.json -`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeSyncPaths,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if version < 0 {
+				return fmt.Errorf("--version must be 1 or more")
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			// The file's content is the only thing printed on stdout
+			syncer, err := sync.NewSyncer(cfg, true)
+			if err != nil {
+				return err
+			}
+
+			relPath, err := claudeRelPath(config.ClaudeDir(), args[0])
+			if err != nil {
+				return err
+			}
+
+			data, err := syncer.ReadRemote(context.Background(), relPath, version)
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(data)
+			return err
+		},
+	}
+
+	cmd.Flags().IntVar(&version, "version", 0, "Print the Nth newest stored version (1 is the latest)")
+	return cmd
+}
+
+func pruneVersionsCmd() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "prune-versions",
+		Short: "Delete stored versions outside the retention policy",
+		Long: `Delete stored file versions beyond versions_keep or older than
+versions_max_age. The newest version of each file is always kept. Pushes
+prune automatically when a retention limit is set; run this to apply a new
+policy right away.
+
+Example config:
+  versioning: true
+  versions_keep: 10
+  versions_max_age: 30d`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			result, err := syncer.PruneVersions(context.Background(), dryRun)
+			if err != nil {
+				return err
+			}
+
+			if len(result.Pruned) == 0 {
+				fmt.Printf("%s✓%s Nothing to prune (%d versions kept)\n", colorGreen, colorReset, result.Kept)
+				return nil
+			}
+
+			verb := "Pruned"
+			if dryRun {
+				verb = "Would prune"
+				for _, v := range result.Pruned {
+					fmt.Printf("  %s-%s %s (%s, %s)\n", colorYellow, colorReset, v.Path,
+						formatTime(v.CreatedAt), v.Device)
+				}
+				fmt.Println()
+			}
+			fmt.Printf("%s✓%s %s %d version(s), %d kept\n", colorGreen, colorReset, verb, len(result.Pruned), result.Kept)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List versions that would be deleted without deleting them")
+
+	return cmd
+}
+
+// followBucketMove switches this device to the bucket another device moved
+// the remote to with 'claude-sync remote move'.
+func followBucketMove(cfg *config.Config, move *sync.BucketMove) error {
+	if cfg.GetStorageConfig().Bucket == move.Bucket {
+		return nil
+	}
+	cfg.SetBucket(move.Bucket)
+	if err := config.Save(cfg); err != nil {
+		return fmt.Errorf("failed to switch to bucket %s: %w", move.Bucket, err)
+	}
+	if !quiet {
+		fmt.Printf("%s✓%s Bucket moved to %s by %s; this device now uses it\n",
+			colorGreen, colorReset, move.Bucket, move.Device)
+	}
+	return nil
+}
+
+func gcCmd() *cobra.Command {
+	var minAge string
+	var dryRun, force bool
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Delete remote files that no device tracks any more",
+		Long: `Find remote files left behind by failed pushes, renamed paths, or old
+conflict copies, and delete them. A file counts as orphaned when it is a
+leftover duplicate of a renamed key, or when it is in neither this device's
+sync state, the remote manifest, nor ~/.claude.
+
+Files changed within --min-age (7 days by default) are kept, since another
+device may still be pushing them, and so is anything outside this device's
+scope or excludes. With 'trash: true', orphans go to the trash.
+
+Examples:
+  claude-sync gc --dry-run
+  claude-sync gc --min-age 30d`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			age, err := config.ParseAge(minAge)
+			if err != nil {
+				return fmt.Errorf("invalid --min-age: %w", err)
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+			orphans, err := syncer.GC(ctx, age, true)
+			if err != nil {
+				return err
+			}
+			if len(orphans) == 0 {
+				fmt.Printf("%s✓%s No orphaned files\n", colorGreen, colorReset)
+				return nil
+			}
+
+			var total int64
+			for _, o := range orphans {
+				total += o.Size
+				fmt.Printf("  %s-%s %s (%s) %s%s%s\n", colorYellow, colorReset, o.Path,
+					util.FormatSize(o.Size), colorDim, o.Reason, colorReset)
+			}
+			fmt.Println()
+			if dryRun {
+				fmt.Printf("%s✓%s Would delete %d file(s), %s\n", colorGreen, colorReset, len(orphans), util.FormatSize(total))
+				return nil
+			}
+
+			if !force {
+				var confirmed bool
+				prompt := &survey.Confirm{
+					Message: fmt.Sprintf("Delete %d orphaned file(s) from the remote?", len(orphans)),
+					Default: false,
+				}
+				if err := askOne(prompt, &confirmed); err != nil {
+					return err
+				}
+				if !confirmed {
+					fmt.Println("Aborted.")
+					return nil
+				}
+			}
+
+			deleted, err := syncer.GC(ctx, age, false)
+			if err != nil {
+				return err
+			}
+			if cfg.Trash {
+				fmt.Printf("%s✓%s Moved %d orphaned file(s) to the trash\n", colorGreen, colorReset, len(deleted))
+			} else {
+				fmt.Printf("%s✓%s Deleted %d orphaned file(s)\n", colorGreen, colorReset, len(deleted))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&minAge, "min-age", "7d", "Keep files changed more recently than this (e.g. 30d, 12h)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List orphaned files without deleting them")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+
+	return cmd
+}
+
+func obfuscateKeysCmd() *cobra.Command {
+	var dryRun, force bool
+
+	cmd := &cobra.Command{
+		Use:   "obfuscate-keys",
+		Short: "Move files stored under readable keys to opaque ones",
+		Long: `With 'obfuscate_keys: true' in the config, pushes store files under opaque
+keys (o/<hmac>) instead of their paths. Files pushed before it was turned on
+keep their readable keys until they change; this moves them, and their
+versions, now. Files in the trash keep their keys until it is emptied.
+
+Turn obfuscate_keys on for every device before running this.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+			renames, err := syncer.ObfuscateKeys(ctx, true)
+			if err != nil {
+				return err
+			}
+			if len(renames) == 0 {
+				fmt.Printf("%s✓%s No readable keys left\n", colorGreen, colorReset)
+				return nil
+			}
+			for _, r := range renames {
+				fmt.Printf("  %s→%s %s %s(%s)%s\n", colorCyan, colorReset, r.From, colorDim, r.To, colorReset)
+			}
+			fmt.Println()
+			if dryRun {
+				fmt.Printf("%s✓%s Would move %d object(s)\n", colorGreen, colorReset, len(renames))
+				return nil
+			}
+
+			if !force {
+				var confirmed bool
+				prompt := &survey.Confirm{
+					Message: fmt.Sprintf("Move %d object(s) to opaque keys?", len(renames)),
+					Default: false,
+				}
+				if err := askOne(prompt, &confirmed); err != nil {
+					return err
+				}
+				if !confirmed {
+					fmt.Println("Aborted.")
+					return nil
+				}
+			}
+
+			renames, err = syncer.ObfuscateKeys(ctx, false)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s✓%s Moved %d object(s) to opaque keys\n", colorGreen, colorReset, len(renames))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List objects that would be moved without moving them")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+
+	return cmd
+}
+
+func verifyCmd() *cobra.Command {
+	var deep bool
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check the remote for missing, corrupt or foreign files and tampering",
+		Long: `Cross-check the bucket with the remote manifest (and this device's state, for
+files the manifest doesn't list) before a pull runs into trouble:
+
+  - files the manifest lists that storage doesn't hold
+  - empty objects left by interrupted uploads
+  - objects no device tracks, leftover duplicates, and foreign objects
+
+Only the listing and the manifest are downloaded. With --deep, every synced
+file is also downloaded and decrypted and compared with its recorded hashes,
+to find corrupt, truncated or replaced objects; where no object hash was
+recorded, the object is checked against its ETag on R2 and S3.
+
+With 'attestations: true' in the config, every push appends a signed summary
+of the bucket (object count, total size, manifest hash) under _attestations/,
+each naming the one before it. verify also checks every signature and link,
+then compares the bucket with the latest summary. Signatures are trusted from
+this device's key and from any listed under 'attestation_signers'.
+
+Fails when files are missing, corrupt or unreadable, or the chain shows
+tampering. Stray objects and differences since the latest attestation are
+only reported: a push from a device without attestations, gc, or
+'trash empty' cause them too.
+
+Name files (relative to ~/.claude, or absolute) to check just those, looked
+up directly instead of listing the whole bucket; strays and the attestation
+chain are then left out.
+
+Examples:
+  claude-sync verify
+  claude-sync verify --deep
+  claude-sync verify --deep CLAUDE.md settings.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			paths, err := claudeRelPaths(args)
+			if err != nil {
+				return err
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+			if deep && !quiet {
+				syncer.SetProgressFunc(func(event sync.ProgressEvent) {
+					switch {
+					case event.Action != "verify":
+					case !event.Complete:
+						fmt.Printf("\r%s→%s %s[%d/%d]%s %s%s",
+							colorGreen, colorReset,
+							colorDim, event.Current, event.Total, colorReset,
+							util.TruncatePath(event.Path, 50), strings.Repeat(" ", 10))
+					case event.Total > 0:
+						fmt.Println()
+					}
+				})
+			}
+
+			ctx := context.Background()
+			integrity, err := syncer.VerifyIntegrity(ctx, deep, paths)
+			if err != nil {
+				return err
+			}
+
+			checked := fmt.Sprintf("%d file(s) checked", integrity.Files)
+			if deep {
+				checked += fmt.Sprintf(", %s downloaded and decrypted", util.FormatSize(integrity.Downloaded))
+			}
+			fmt.Println(checked)
+			for _, p := range integrity.Problems {
+				fmt.Printf("  %s✗%s %s: %s\n", colorYellow, colorReset, integrityName(p), p.Problem)
+			}
+			for _, p := range integrity.Strays {
+				fmt.Printf("  %s!%s %s: %s\n", colorYellow, colorReset, p.Key, p.Problem)
+			}
+			if len(integrity.Strays) > 0 {
+				fmt.Printf("%s'claude-sync gc' deletes untracked objects and leftover duplicates.%s\n", colorDim, colorReset)
+			}
+
+			// The chain summarizes the whole bucket, not a few files
+			report := &sync.AttestationReport{}
+			if len(paths) == 0 {
+				if report, err = syncer.VerifyAttestations(ctx); err != nil {
+					return err
+				}
+			}
+			if latest := report.Latest(); latest != nil {
+				fmt.Printf("%d attestation(s); latest #%d from %s, %s\n", len(report.Chain), latest.Seq,
+					latest.Device, formatTime(latest.CreatedAt))
+				for _, p := range report.Problems {
+					fmt.Printf("  %s✗%s %s\n", colorYellow, colorReset, p)
+				}
+				for _, d := range report.Drift {
+					fmt.Printf("  %s!%s %s\n", colorYellow, colorReset, d)
+				}
+				if report.SignerKey != "" {
+					fmt.Printf("%sThis device signs as %s%s\n", colorDim, report.SignerKey, colorReset)
+				}
+			} else if cfg.Attestations && len(paths) == 0 {
+				fmt.Println("No attestations")
+			}
+
+			if len(integrity.Problems) > 0 {
+				return fmt.Errorf("remote failed verification (%d problem(s))", len(integrity.Problems))
+			}
+			if len(report.Problems) > 0 {
+				return fmt.Errorf("attestation chain failed verification (%d problem(s))", len(report.Problems))
+			}
+			if deep {
+				printSuccess("Remote files verified")
+			} else {
+				printSuccess("Remote files present (use --deep to check their contents)")
+			}
+			if report.Latest() != nil {
+				printSuccess("Attestation chain verified")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&deep, "deep", false, "Download and decrypt every file to check its contents")
+
+	return cmd
+}
+
+// integrityName is how verify names the file of a problem: by its local path,
+// or by its key when it has none.
+func integrityName(issue sync.IntegrityIssue) string {
+	if issue.Path == "" {
+		return issue.Key
+	}
+	return issue.Path
+}
+
+func trashCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trash",
+		Short: "List, restore, or empty remote files deleted by push",
+		Long: `With 'trash: true' in the config, files a push deletes (and everything
+'reset --remote' clears) are moved under _trash/ on the remote instead of
+being deleted. Each push's deletions form one batch, named by when it ran.`,
+	}
+	cmd.AddCommand(
+		trashListCmd(),
+		holdsLock(trashRestoreCmd()),
+		holdsLock(trashEmptyCmd()),
+	)
+	return cmd
+}
+
+func trashListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List files in the remote trash",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			items, err := syncer.ListTrash(context.Background())
+			if err != nil {
+				return err
+			}
+			if len(items) == 0 {
+				fmt.Println("Trash is empty")
+				if !cfg.Trash {
+					fmt.Printf("%sSet 'trash: true' in the config to keep files deleted by push.%s\n", colorDim, colorReset)
+				}
+				return nil
+			}
+
+			batch := ""
+			for _, item := range items {
+				if item.Batch != batch {
+					batch = item.Batch
+					fmt.Printf("%s%s%s  %sdeleted %s%s\n", colorBold, batch, colorReset,
+						colorDim, formatTime(item.DeletedAt), colorReset)
+				}
+				fmt.Printf("  %s-%s %s (%s)\n", colorYellow, colorReset, item.Path, util.FormatSize(item.Size))
+			}
+			fmt.Println()
+			fmt.Printf("%sRestore with: claude-sync trash restore <path|batch>%s\n", colorDim, colorReset)
+			return nil
+		},
+	}
+}
+
+func trashRestoreCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "restore <path|batch>",
+		Short: "Move trashed files back into place on the remote",
+		Long: `Move trashed files back to where they were on the remote. Give a file, a
+directory, or a batch ID from 'claude-sync trash list'. If a file was deleted
+more than once, its most recent deletion is restored.
+
+Files that have been pushed again since they were deleted are skipped unless
+--force is given. Run 'claude-sync pull' afterwards to bring them back locally.
+
+Examples:
+  claude-sync trash restore agents/reviewer.md
+  claude-sync trash restore projects/my-app
+  claude-sync trash restore 20260314T101502Z`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			ref, err := claudeRelPath(config.ClaudeDir(), args[0])
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+			items, err := syncer.ListTrash(ctx)
+			if err != nil {
+				return err
+			}
+			matched := sync.MatchTrash(items, ref)
+			if len(matched) == 0 {
+				return fmt.Errorf("nothing in the trash matches %s", args[0])
+			}
+
+			result, err := syncer.RestoreTrash(ctx, matched, force)
+			if result != nil {
+				for _, item := range result.Restored {
+					fmt.Printf("  %s+%s %s\n", colorGreen, colorReset, item.Path)
+				}
+			}
+			if err != nil {
+				return err
+			}
+			for _, item := range result.Skipped {
+				fmt.Printf("  %s!%s %s %s(pushed again since; use --force to overwrite)%s\n",
+					colorYellow, colorReset, item.Path, colorDim, colorReset)
+			}
+
+			fmt.Println()
+			fmt.Printf("%s✓%s Restored %d file(s) on the remote\n", colorGreen, colorReset, len(result.Restored))
+			if len(result.Restored) > 0 {
+				fmt.Printf("%sRun 'claude-sync pull' to bring them back locally.%s\n", colorDim, colorReset)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite files pushed again since they were deleted")
+
+	return cmd
+}
+
+func trashEmptyCmd() *cobra.Command {
+	var olderThan string
+	var dryRun, force bool
+
+	cmd := &cobra.Command{
+		Use:   "empty",
+		Short: "Permanently delete files in the remote trash",
+		Long: `Permanently delete files in the remote trash. With --older-than, only
+files deleted longer ago than that are removed.
+
+Examples:
+  claude-sync trash empty
+  claude-sync trash empty --older-than 30d`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			var age time.Duration
+			if olderThan != "" {
+				if age, err = config.ParseAge(olderThan); err != nil {
+					return fmt.Errorf("invalid --older-than: %w", err)
+				}
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			ctx := context.Background()
+			expired, err := syncer.EmptyTrash(ctx, age, true)
+			if err != nil {
+				return err
+			}
+			if len(expired) == 0 {
+				fmt.Printf("%s✓%s Nothing to delete\n", colorGreen, colorReset)
+				return nil
+			}
+			if dryRun {
+				for _, item := range expired {
+					fmt.Printf("  %s-%s %s (%s)\n", colorYellow, colorReset, item.Path, item.Batch)
+				}
+				fmt.Println()
+				fmt.Printf("%s✓%s Would delete %d file(s)\n", colorGreen, colorReset, len(expired))
+				return nil
+			}
+
+			if !force {
+				var confirmed bool
+				prompt := &survey.Confirm{
+					Message: fmt.Sprintf("Permanently delete %d file(s) from the trash?", len(expired)),
+					Default: false,
+				}
+				if err := askOne(prompt, &confirmed); err != nil {
+					return err
+				}
+				if !confirmed {
+					fmt.Println("Aborted.")
+					return nil
+				}
+			}
+
+			deleted, err := syncer.EmptyTrash(ctx, age, false)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s✓%s Deleted %d file(s) from the trash\n", colorGreen, colorReset, len(deleted))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Only delete files trashed longer ago than this (e.g. 30d, 12h)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List files that would be deleted without deleting them")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+
+	return cmd
+}
+
+func backupsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backups",
+		Short: "List or restore local backups of ~/.claude",
+		Long: `Backups are copies of local files kept next to ~/.claude. Manual ones
+(~/.claude.backup.<timestamp>) are made when you choose to back up before a
+first pull; automatic ones (~/.claude.backup.auto.<timestamp>) hold the files
+each pull replaced, when 'pull_backups: N' is set in the config. Only the
+newest N automatic backups are kept; manual backups stay until you delete
+them with 'claude-sync prune --backups'.`,
+	}
+	cmd.AddCommand(
+		backupsListCmd(),
+		holdsLock(backupsRestoreCmd()),
+	)
+	return cmd
+}
+
+func pauseCmd() *cobra.Command {
+	var duration string
+
+	cmd := &cobra.Command{
+		Use:   "pause [path]",
+		Short: "Hold a file or directory back from sync for a while",
+		Long: `Hold a file or directory under ~/.claude back from sync until you resume
+it, or until --for has passed. While paused, push neither uploads nor
+deletes anything under the path, and pull leaves the local copies alone, so
+a half-finished change doesn't reach your other devices. The pause is
+recorded on this device only. Without a path, lists the current pauses.
+
+Examples:
+  claude-sync pause agents             # Until 'claude-sync resume agents'
+  claude-sync pause agents --for 2h    # Resumes by itself after two hours
+  claude-sync pause                    # List paused paths`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeSyncPaths,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state, err := sync.LoadState()
+			if err != nil {
+				return fmt.Errorf("failed to load sync state: %w", err)
+			}
+
+			if len(args) == 0 {
+				paused := state.PausedPaths(time.Now())
+				if len(paused) == 0 {
+					fmt.Println("Nothing is paused")
+					return nil
+				}
+				for _, p := range paused {
+					fmt.Printf("  %s %s%s%s\n", p.Path, colorDim, describePause(p.Pause), colorReset)
+				}
+				return nil
+			}
+
+			relPath, err := claudeRelPath(config.ClaudeDir(), args[0])
+			if err != nil {
+				return err
+			}
+			if relPath == "." {
+				return fmt.Errorf("pause a path inside ~/.claude, not the whole directory")
+			}
+
+			var until time.Time
+			if duration != "" {
+				d, err := config.ParseAge(duration)
+				if err != nil || d == 0 {
+					return fmt.Errorf("invalid --for %q (use e.g. 2h, 90m, 3d)", duration)
+				}
+				until = time.Now().Add(d)
+			}
+
+			state.Pause(relPath, until)
+			if err := state.Save(); err != nil {
+				return fmt.Errorf("failed to save sync state: %w", err)
+			}
+
+			if !quiet {
+				if until.IsZero() {
+					printSuccess(fmt.Sprintf("Paused %s until you run 'claude-sync resume %s'", relPath, relPath))
+				} else {
+					printSuccess(fmt.Sprintf("Paused %s until %s", relPath, until.Format("2006-01-02 15:04")))
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&duration, "for", "", "Resume automatically after this long (e.g. 2h, 3d)")
+	return cmd
+}
+
+func resumeCmd() *cobra.Command {
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "resume [path]",
+		Short: "Resume syncing a paused path",
+		Long: `Lift a pause set with 'claude-sync pause'. The path's pending changes are
+pushed on the next push, and remote changes to it arrive on the next pull.`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completePausedPaths,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) == 1) {
+				return fmt.Errorf("give a path or --all")
+			}
+
+			state, err := sync.LoadState()
+			if err != nil {
+				return fmt.Errorf("failed to load sync state: %w", err)
+			}
+
+			var resumed []string
+			if all {
+				for _, p := range state.PausedPaths(time.Now()) {
+					state.Resume(p.Path)
+					resumed = append(resumed, p.Path)
+				}
+			} else {
+				relPath, err := claudeRelPath(config.ClaudeDir(), args[0])
+				if err != nil {
+					return err
+				}
+				if !state.Resume(relPath) {
+					return fmt.Errorf("%s is not paused", relPath)
+				}
+				resumed = append(resumed, relPath)
+			}
+
+			if err := state.Save(); err != nil {
+				return fmt.Errorf("failed to save sync state: %w", err)
+			}
+			if !quiet {
+				if len(resumed) == 0 {
+					fmt.Println("Nothing is paused")
+				}
+				for _, path := range resumed {
+					printSuccess("Resumed " + path)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Resume every paused path")
+	return cmd
+}
+
+// describePause says how long a pause lasts.
+func describePause(p sync.Pause) string {
+	if p.Until.IsZero() {
+		return "(until resumed)"
+	}
+	return fmt.Sprintf("(until %s)", formatTime(p.Until))
+}
+
+func proposalsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "proposals",
+		Short: "List changes to reviewed command sets waiting for approval",
+		Long: `With 'review_namespaces' in the config, pushes don't change those shared
+command sets directly. Each push stages its changes as a proposal that another
+device must approve with 'claude-sync approve <id>' (or discard with
+'claude-sync reject <id>') before the shared copy changes.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			proposals, err := syncer.ListProposals(context.Background())
+			if err != nil {
+				return err
+			}
+			if len(proposals) == 0 {
+				fmt.Println("No proposals waiting for review")
+				return nil
+			}
+
+			for _, p := range proposals {
+				printProposal(p)
+			}
+			fmt.Printf("%sApprove with: claude-sync approve <id>%s\n", colorDim, colorReset)
+			return nil
+		},
+	}
+}
+
+func approveCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "approve <id>",
+		Short: "Apply a proposed change to a reviewed command set",
+		Long: `Make a proposal's files the shared copy on the remote. Proposals must be
+approved from a device other than the one that made them. Run
+'claude-sync pull' afterwards to bring the changes in locally.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return reviewProposal(args[0], true, force)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+	return cmd
+}
+
+func rejectCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "reject <id>",
+		Short: "Discard a proposed change to a reviewed command set",
+		Long: `Discard a proposal without applying it. The proposing device is told on its
+next push, which proposes the change again if it is still there locally.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return reviewProposal(args[0], false, force)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+	return cmd
+}
+
+// reviewProposal approves or rejects a proposal after showing it.
+func reviewProposal(id string, approve, force bool) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	syncer, err := sync.NewSyncer(cfg, quiet)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if !force {
+		proposals, err := syncer.ListProposals(ctx)
+		if err != nil {
+			return err
+		}
+		for _, p := range proposals {
+			if p.ID == id {
+				printProposal(p)
+			}
+		}
+
+		verb := "Reject"
+		if approve {
+			verb = "Approve"
+		}
+		var confirmed bool
+		prompt := &survey.Confirm{
+			Message: fmt.Sprintf("%s proposal %s?", verb, id),
+			Default: false,
+		}
+		if err := askOne(prompt, &confirmed); err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Aborted.")
+			return nil
+		}
+	}
+
+	if !approve {
+		if _, err := syncer.RejectProposal(ctx, id); err != nil {
+			return err
+		}
+		printSuccess("Rejected proposal " + id)
+		return nil
+	}
+
+	p, err := syncer.ApproveProposal(ctx, id)
+	if err != nil {
+		return err
+	}
+	printSuccess(fmt.Sprintf("Approved proposal %s (%d file(s))", p.ID, len(p.Changes)))
+	fmt.Printf("%sRun 'claude-sync pull' to bring the changes in locally.%s\n", colorDim, colorReset)
+	return nil
+}
+
+// printProposal lists a proposal's changes.
+func printProposal(p sync.Proposal) {
+	fmt.Printf("%s%s%s  %sfrom %s, %s%s\n", colorBold, p.ID, colorReset,
+		colorDim, p.Device, formatTime(p.CreatedAt), colorReset)
+	for _, c := range p.Changes {
+		switch c.Action {
+		case "delete":
+			fmt.Printf("  %s-%s %s\n", colorYellow, colorReset, c.Path)
+		case "add":
+			fmt.Printf("  %s+%s %s (%s)\n", colorGreen, colorReset, c.Path, util.FormatSize(c.Size))
+		default:
+			fmt.Printf("  %s~%s %s (%s)\n", colorCyan, colorReset, c.Path, util.FormatSize(c.Size))
+		}
+	}
+	fmt.Println()
+}
+
+func reportCmd() *cobra.Command {
+	var period string
+	var send bool
+
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Summarize recent sync activity, conflicts and failures",
+		Long: `Summarize the pushes and pulls this device ran over the last day or week,
+the conflicts waiting to be resolved, and any runs that failed or pulled
+invalid files. With --send, the report goes to the webhook and/or SMTP
+server set under 'report:' in the config instead of being printed, which
+suits devices you rarely log into. Run it from cron.
+
+Examples:
+  claude-sync report                   # Print the last day's activity
+  claude-sync report --period weekly   # Print the last week's activity
+  claude-sync report --send            # Send it to the configured destinations`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			reportCfg := cfg.Report
+			if period != "" {
+				override := config.ReportConfig{Period: period}
+				if reportCfg != nil {
+					override = *reportCfg
+					override.Period = period
+				}
+				reportCfg = &override
+			}
+			span, err := reportCfg.ReportPeriod()
+			if err != nil {
+				return err
+			}
+
+			state, err := sync.LoadState()
+			if err != nil {
+				return fmt.Errorf("failed to load sync state: %w", err)
+			}
+			entries, err := sync.LoadActivity()
+			if err != nil {
+				return err
+			}
+			conflicts, err := sync.FindConflicts(config.ClaudeDir(), state)
+			if err != nil {
+				return err
+			}
+
+			now := time.Now()
+			r := report.Build(state.DeviceID, now.Add(-span), now, entries, conflicts)
+
+			if !send {
+				fmt.Print(r.Text())
+				return nil
+			}
+			if err := report.Send(context.Background(), reportCfg, r); err != nil {
+				return err
+			}
+			if !quiet {
+				printSuccess("Report sent")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&period, "period", "", "Span to cover: daily or weekly (default from config, else daily)")
+	cmd.Flags().BoolVar(&send, "send", false, "Send the report to the configured webhook and/or SMTP server")
+	return cmd
+}
+
+func backupsListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List manual and automatic backups",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			backups, err := sync.ListBackups(config.ClaudeDir())
+			if err != nil {
+				return err
+			}
+			if len(backups) == 0 {
+				fmt.Println("No backups")
+				if cfg, err := config.Load(); err == nil && cfg.PullBackups == 0 {
+					fmt.Printf("%sSet 'pull_backups: 5' in the config to back up files each pull replaces.%s\n", colorDim, colorReset)
+				}
+				return nil
+			}
+
+			for _, b := range backups {
+				kind := "manual"
+				if b.Automatic {
+					kind = "automatic"
+				}
+				fmt.Printf("  %s  %s %s(%s)%s\n", formatTime(b.CreatedAt), b.Name, colorDim, kind, colorReset)
+			}
+			fmt.Println()
+			fmt.Printf("%sRestore with: claude-sync backups restore <name|latest>%s\n", colorDim, colorReset)
+			return nil
+		},
+	}
+}
+
+func backupsRestoreCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "restore <name|latest>",
+		Short: "Copy a backup's files back into ~/.claude",
+		Long: `Copy every file in a backup back into ~/.claude, overwriting the local
+copies. Files that aren't in the backup are left alone. Give a backup name
+or its timestamp from 'claude-sync backups list', or 'latest'. The restored
+files are pushed on your next push.
+
+Examples:
+  claude-sync backups restore latest
+  claude-sync backups restore .claude.backup.auto.20260314-101502`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			claudeDir := config.ClaudeDir()
+			backups, err := sync.ListBackups(claudeDir)
+			if err != nil {
+				return err
+			}
+
+			var chosen *sync.Backup
+			for i, b := range backups {
+				if args[0] == "latest" || args[0] == b.Name || strings.HasSuffix(b.Name, "."+args[0]) {
+					chosen = &backups[i]
+					break
+				}
+			}
+			if chosen == nil {
+				return fmt.Errorf("no backup matches %s (see 'claude-sync backups list')", args[0])
+			}
+
+			if !force {
+				var confirm bool
+				prompt := &survey.Confirm{
+					Message: fmt.Sprintf("Overwrite local files with %s?", chosen.Name),
+					Default: false,
+				}
+				if err := askOne(prompt, &confirm); err != nil {
+					return err
+				}
+				if !confirm {
+					fmt.Println("  Aborted.")
+					return nil
+				}
+			}
+
+			restored, err := sync.RestoreBackup(claudeDir, *chosen)
+			for _, path := range restored {
+				fmt.Printf("  %s+%s %s\n", colorGreen, colorReset, path)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Println()
+			fmt.Printf("%s✓%s Restored %d file(s) from %s\n", colorGreen, colorReset, len(restored), chosen.Name)
+			fmt.Printf("%sRun 'claude-sync push' to share them with your other devices.%s\n", colorDim, colorReset)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+
+	return cmd
+}
+
+func remoteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "remote",
+		Short: "Manage the remote bucket",
+	}
+	cmd.AddCommand(holdsLock(remoteMoveCmd()))
+	return cmd
+}
+
+func remoteMoveCmd() *cobra.Command {
+	var bucket, grace string
+	var finish, force bool
+
+	cmd := &cobra.Command{
+		Use:   "move --bucket <name>",
+		Short: "Move synced data to another bucket without resetting devices",
+		Long: `Copy everything to another bucket of the same provider and switch this
+device to it. The copy is server-side where the provider supports it, and
+nothing is decrypted.
+
+The old bucket is kept current for a grace window (7 days by default): this
+device writes to both. Other devices switch over on their next push or pull,
+when they find the marker the move leaves in the old bucket, and record in it
+that they have. Once every device has, run 'claude-sync remote move --finish'
+to empty the old bucket; it refuses while a device that pushed to the old
+bucket hasn't switched, unless --force is given.
+
+The new bucket must already exist. WebDAV is not supported.
+
+Examples:
+  claude-sync remote move --bucket claude-sync-new
+  claude-sync remote move --bucket claude-sync-new --grace 14d
+  claude-sync remote move --finish`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			storageCfg := cfg.GetStorageConfig()
+			if storageCfg.Provider == storage.ProviderWebDAV {
+				return fmt.Errorf("remote move is not supported for WebDAV")
+			}
+
+			ctx := context.Background()
+			if finish {
+				return finishBucketMove(ctx, cfg, storageCfg, force)
+			}
+
+			if bucket == "" {
+				return fmt.Errorf("--bucket is required")
+			}
+			if bucket == storageCfg.Bucket {
+				return fmt.Errorf("already using bucket %s", bucket)
+			}
+			graceWindow, err := config.ParseAge(grace)
+			if err != nil {
+				return fmt.Errorf("invalid --grace: %w", err)
+			}
+
+			newCfg := *storageCfg
+			newCfg.Bucket = bucket
+			dst, err := storage.New(&newCfg)
+			if err != nil {
+				return err
+			}
+			exists, err := dst.BucketExists(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to check bucket %s: %w", bucket, err)
+			}
+			if !exists {
+				return fmt.Errorf("bucket %s does not exist; create it first", bucket)
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+			if !quiet {
+				syncer.SetProgressFunc(func(event sync.ProgressEvent) {
+					if event.Action == "upload" && !event.Complete {
+						fmt.Printf("\r%s→%s %s[%d/%d]%s %s%s",
+							colorGreen, colorReset,
+							colorDim, event.Current, event.Total, colorReset,
+							util.TruncatePath(event.Path, 50), strings.Repeat(" ", 10))
+					}
+				})
+			}
+
+			result, err := syncer.CopyBucket(ctx, dst, bucket)
+			if err != nil {
+				return err
+			}
+
+			old := storageCfg.Bucket
+			cfg.PreviousBucket = old
+			cfg.PreviousBucketUntil = time.Now().Add(graceWindow)
+			cfg.SetBucket(bucket)
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+
+			if !quiet {
+				if result.Copied > 0 {
+					fmt.Println()
+				}
+				fmt.Printf("%s✓%s Copied %d object(s) to %s (%d already there)\n",
+					colorGreen, colorReset, result.Copied, bucket, result.Unchanged)
+				fmt.Printf("%s✓%s This device now uses %s; writes also go to %s until %s\n",
+					colorGreen, colorReset, bucket, old, cfg.PreviousBucketUntil.Local().Format("2006-01-02"))
+				fmt.Println()
+				fmt.Printf("%sOther devices switch on their next push or pull. Once they all have, run:%s\n", colorDim, colorReset)
+				fmt.Printf("  claude-sync remote move --finish\n")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&bucket, "bucket", "", "Bucket to move to")
+	cmd.Flags().StringVar(&grace, "grace", "7d", "How long to keep writing to the old bucket")
+	cmd.Flags().BoolVar(&finish, "finish", false, "Empty the old bucket and stop writing to it")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt, and with --finish, devices that haven't switched")
+
+	return cmd
+}
+
+// finishBucketMove empties the bucket a move left behind, keeping only the
+// marker that redirects devices still pointing at it. Without force, it
+// refuses while a device that pushed to the old bucket hasn't switched.
+func finishBucketMove(ctx context.Context, cfg *config.Config, storageCfg *storage.StorageConfig, force bool) error {
+	if cfg.PreviousBucket == "" {
+		return fmt.Errorf("no bucket move to finish")
+	}
+
+	oldCfg := *storageCfg
+	oldCfg.Bucket = cfg.PreviousBucket
+	old, err := storage.New(&oldCfg)
+	if err != nil {
+		return err
+	}
+
+	// Emptying the old bucket strands devices that still sync with it
+	syncer, err := sync.NewSyncer(cfg, quiet)
+	if err != nil {
+		return err
+	}
+	waiting, err := syncer.UnswitchedDevices(ctx, old)
+	if err != nil {
+		return err
+	}
+	if len(waiting) > 0 && !force {
+		return fmt.Errorf("%d device(s) haven't switched to %s yet: %s; run 'claude-sync pull' on them first, or --force to finish anyway",
+			len(waiting), storageCfg.Bucket, strings.Join(waiting, ", "))
+	}
+
+	if !force {
+		var confirmed bool
+		prompt := &survey.Confirm{
+			Message: fmt.Sprintf("Permanently delete everything in %s?", cfg.PreviousBucket),
+			Default: false,
+		}
+		if err := askOne(prompt, &confirmed); err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Aborted.")
+			return nil
+		}
+	}
+
+	deleted, err := sync.ClearMovedBucket(ctx, old)
+	if err != nil {
+		return err
+	}
+
+	previous := cfg.PreviousBucket
+	cfg.PreviousBucket = ""
+	cfg.PreviousBucketUntil = time.Time{}
+	if err := config.Save(cfg); err != nil {
+		return err
+	}
+	if !quiet {
+		fmt.Printf("%s✓%s Deleted %d object(s) from %s\n", colorGreen, colorReset, deleted, previous)
+	}
+	return nil
+}
+
+func exportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export <file>",
+		Short: "Write all synced files and state to one encrypted archive",
+		Long: `Write every synced file under ~/.claude, plus the sync state, to a single
+archive encrypted with your sync key. Use it for cold backups or to move to
+another storage provider. Use - to write to stdout.
+
+Importing needs the same key (or passphrase).
+
+Examples:
+  claude-sync export ~/backups/claude-$(date +%F).age
+  claude-sync export - | ssh nas 'cat > claude.age'`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			if args[0] == "-" {
+				_, err := syncer.Export(os.Stdout)
+				return err
+			}
+
+			// Write next to the target and rename, so a failed export never
+			// leaves a truncated archive behind under the real name
+			dest := args[0]
+			tmp, err := os.CreateTemp(filepath.Dir(dest), ".claude-sync-export-*")
+			if err != nil {
+				return fmt.Errorf("failed to create archive: %w", err)
+			}
+			defer func() { _ = os.Remove(tmp.Name()) }()
+
+			info, err := syncer.Export(tmp)
+			if closeErr := tmp.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+			if err := os.Chmod(tmp.Name(), 0600); err != nil {
+				return fmt.Errorf("failed to set archive permissions: %w", err)
+			}
+			if err := os.Rename(tmp.Name(), dest); err != nil {
+				return fmt.Errorf("failed to write archive: %w", err)
+			}
+
+			if !quiet {
+				fmt.Printf("%s✓%s Exported %d files to %s\n", colorGreen, colorReset, info.Files, dest)
+			}
+			return nil
+		},
+	}
+
+	return cmd
+}
+
+func importCmd() *cobra.Command {
+	var dryRun, withState, force bool
+
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Restore files from an archive made by export",
+		Long: `Restore the files in an archive made by 'claude-sync export' into
+~/.claude. Local files not in the archive are left alone. You'll be asked
+before local files are overwritten.
+
+The sync state is kept as is, so a following 'claude-sync push' uploads
+the imported files; that's what you want when moving to a new storage
+provider. Use --state to restore the archived sync state too, for an exact
+cold-backup restore against the same storage.
+
+Examples:
+  claude-sync import backup.age --dry-run   # Preview
+  claude-sync import backup.age             # Restore files, then push
+  claude-sync import backup.age --state     # Also restore sync state`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			importFrom := func(opts sync.ImportOptions) (*sync.ImportResult, error) {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return nil, fmt.Errorf("failed to open archive: %w", err)
+				}
+				defer func() { _ = f.Close() }()
+				return syncer.Import(f, opts)
+			}
+
+			preview, err := importFrom(sync.ImportOptions{DryRun: true})
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("%sArchive:%s %d files from %s, exported %s\n\n", colorDim, colorReset,
+				preview.Info.Files, preview.Info.DeviceID, formatTime(preview.Info.CreatedAt))
+			for _, path := range preview.Created {
+				fmt.Printf("  %s+%s %s\n", colorGreen, colorReset, path)
+			}
+			for _, path := range preview.Modified {
+				fmt.Printf("  %s~%s %s\n", colorYellow, colorReset, path)
+			}
+			fmt.Printf("\nSummary: %d new, %d overwritten, %d unchanged\n",
+				len(preview.Created), len(preview.Modified), preview.Unchanged)
+
+			changes := len(preview.Created) + len(preview.Modified)
+			if dryRun || (changes == 0 && !withState) {
+				return nil
+			}
+
+			if len(preview.Modified) > 0 && !force {
+				var confirm bool
+				prompt := &survey.Confirm{
+					Message: fmt.Sprintf("Overwrite %d local file(s)?", len(preview.Modified)),
+					Default: false,
+				}
+				if err := askOne(prompt, &confirm); err != nil {
+					return err
+				}
+				if !confirm {
+					fmt.Println("  Cancelled.")
+					return nil
+				}
+			}
+
+			result, err := importFrom(sync.ImportOptions{RestoreState: withState})
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("%s✓%s Imported %d file(s)\n", colorGreen, colorReset, len(result.Created)+len(result.Modified))
+			if result.StateRestored {
+				fmt.Printf("%s✓%s Restored sync state\n", colorGreen, colorReset)
+			} else if changes > 0 {
+				fmt.Printf("%sRun 'claude-sync push' to upload them.%s\n", colorDim, colorReset)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be restored without changing anything")
+	cmd.Flags().BoolVar(&withState, "state", false, "Also restore the archived sync state")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation")
+
+	return cmd
+}
+
+func conflictsCmd() *cobra.Command {
+	var listOnly bool
+	var resolveAll string
+	var openPairs bool
+
+	cmd := &cobra.Command{
+		Use:   "conflicts",
+		Short: "List and resolve sync conflicts",
+		Long: `Find and resolve conflicts from sync operations.
+
+When both local and remote files change, the remote version is saved
+as a .conflict file. Use this command to review and resolve them.
+
+On a terminal, conflicts open in a full-screen resolver showing local and
+remote side by side. Each differing hunk can be kept from either side:
+  ←/→      keep the selected hunk's local/remote lines
+  tab/⇧tab next/previous hunk      ↑/↓, PgUp/PgDn  scroll
+  L/R      keep all local/remote   enter           apply and move on
+  [/]      previous/next conflict  s               skip
+  o        open in $EDITOR         q               quit
+Choosing hunks from both sides writes the merged result, which the next
+push uploads.
+
+Examples:
+  claude-sync conflicts              # Interactive resolution (side-by-side on a terminal)
+  claude-sync conflicts --list       # Just list conflicts
+  claude-sync conflicts --keep local # Keep all local versions
+  claude-sync conflicts --keep remote # Keep all remote versions
+  claude-sync conflicts --open       # Open each pair in $EDITOR or the file manager`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			claudeDir := config.ClaudeDir()
+			defer recountConflicts(claudeDir)
+
+			// Load sync state for conflict origins and to update after resolution
+			state, err := sync.LoadState()
+			if err != nil {
+				return fmt.Errorf("failed to load sync state: %w", err)
+			}
+
+			// Find all .conflict files
+			conflicts, err := sync.FindConflicts(claudeDir, state)
+			if err != nil {
+				return err
+			}
+
+			// Listing is all --json does; resolving needs --keep or a terminal
+			if jsonOutput {
+				if resolveAll != "" || openPairs {
+					return fmt.Errorf("--json only lists conflicts; resolve them without it")
+				}
+				return printJSON(emptyIfNil(conflicts))
+			}
+
+			if len(conflicts) == 0 {
+				fmt.Printf("%s✓%s No conflicts found\n", colorGreen, colorReset)
+				return nil
+			}
+
+			fmt.Printf("%sFound %d conflict(s):%s\n\n", colorYellow, len(conflicts), colorReset)
+
+			for i, c := range conflicts {
+				fmt.Printf("  %s%d.%s %s\n", colorCyan, i+1, colorReset, c.Path)
+				fmt.Printf("     %sConflict from: %s%s\n", colorDim, conflictTime(c), colorReset)
+				if origin := describeConflictOrigin(c); origin != "" {
+					fmt.Printf("     %sRemote version: %s%s\n", colorDim, origin, colorReset)
+				}
+			}
+			fmt.Println()
+
+			// List only mode
+			if listOnly {
+				return nil
+			}
+
+			// Open mode: hand each pair to the editor or file manager for review
+			if openPairs {
+				for _, c := range conflicts {
+					if err := openConflictPair(claudeDir, c); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			// Batch resolve mode
+			if resolveAll != "" {
+				return batchResolveConflicts(conflicts, resolveAll, claudeDir, state)
+			}
+
+			// Interactive mode: full-screen resolver on a terminal, prompts otherwise
+			if isInteractiveTerminal() {
+				return tuiResolveConflicts(conflicts, claudeDir, state)
+			}
+			return interactiveResolveConflicts(conflicts, claudeDir, state)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&listOnly, "list", "l", false, "Only list conflicts, don't resolve")
+	cmd.Flags().StringVar(&resolveAll, "keep", "", "Resolve all conflicts: 'local' or 'remote'")
+	cmd.Flags().BoolVar(&openPairs, "open", false, "Open each conflicting pair in $EDITOR (or reveal it in the file manager)")
+
+	cmd.AddCommand(holdsLock(conflictsResolveCmd()))
+
+	return cmd
+}
+
+func conflictsResolveCmd() *cobra.Command {
+	var keep, mergedFile string
+
+	cmd := &cobra.Command{
+		Use:   "resolve <path>",
+		Short: "Resolve one conflict non-interactively",
+		Long: `Resolve the conflict for a single file without prompting, for scripts
+and editor integrations.
+
+The path may be relative to ~/.claude or absolute, and may name either the
+local file or a specific .conflict copy. Use --merged to supply the final
+content from a file (or - for stdin), e.g. after merging by hand.
+
+Examples:
+  claude-sync conflicts resolve settings.json --keep local
+  claude-sync conflicts resolve agents/reviewer.md --keep remote
+  claude-sync conflicts resolve CLAUDE.md --merged /tmp/CLAUDE.merged.md`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeConflictPaths,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (keep == "") == (mergedFile == "") {
+				return fmt.Errorf("specify exactly one of --keep local|remote or --merged <file>")
+			}
+
+			choice := sync.KeepMerged
+			var merged []byte
+			if keep != "" {
+				var err error
+				choice, err = sync.ParseResolution(keep)
+				if err != nil || choice == sync.KeepMerged {
+					return fmt.Errorf("--keep must be 'local' or 'remote' (use --merged for merged content)")
+				}
+			} else {
+				var err error
+				if mergedFile == "-" {
+					merged, err = io.ReadAll(os.Stdin)
+				} else {
+					merged, err = os.ReadFile(mergedFile)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to read merged content: %w", err)
+				}
+			}
+
+			claudeDir := config.ClaudeDir()
+			relPath, err := claudeRelPath(claudeDir, args[0])
+			if err != nil {
+				return err
+			}
+
+			state, err := sync.LoadState()
+			if err != nil {
+				return fmt.Errorf("failed to load sync state: %w", err)
+			}
+
+			defer recountConflicts(claudeDir)
+			c, err := sync.ResolveConflictPath(claudeDir, state, relPath, choice, merged)
+			if err != nil {
+				return err
+			}
+
+			if !quiet {
+				fmt.Printf("%s✓%s Resolved %s (kept %s)\n", colorGreen, colorReset, c.Path, choice)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&keep, "keep", "", "Version to keep: 'local' or 'remote'")
+	cmd.Flags().StringVar(&mergedFile, "merged", "", "File with the merged content to keep ('-' for stdin)")
+
+	return cmd
+}
+
+// expandHome expands a leading ~/ to the user's home directory.
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+// claudeRelPath turns a user-supplied path (absolute, ~-prefixed, or already
+// relative to claudeDir) into a slash-separated path relative to claudeDir.
+func claudeRelPath(claudeDir, path string) (string, error) {
+	path = expandHome(path)
+	if !filepath.IsAbs(path) {
+		return filepath.ToSlash(filepath.Clean(path)), nil
+	}
+	rel, err := filepath.Rel(claudeDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not inside %s", path, claudeDir)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// claudeRelPaths is claudeRelPath for each of args.
+func claudeRelPaths(args []string) ([]string, error) {
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		rel, err := claudeRelPath(config.ClaudeDir(), arg)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, rel)
+	}
+	return paths, nil
+}
+
+// conflictTime shows when a conflict was saved, falling back to the raw
+// timestamp from its file name when that doesn't parse.
+func conflictTime(c sync.Conflict) string {
+	if c.DetectedAt.IsZero() {
+		return c.Timestamp
+	}
+	return formatTime(c.DetectedAt)
+}
+
+// describeConflictOrigin summarizes which device pushed the remote side of a
+// conflict and when, or returns "" if that wasn't recorded.
+func describeConflictOrigin(c sync.Conflict) string {
+	var parts []string
+	if c.RemoteDevice != "" {
+		parts = append(parts, "from "+c.RemoteDevice)
+	}
+	if !c.RemotePushedAt.IsZero() {
+		parts = append(parts, "pushed "+formatTime(c.RemotePushedAt))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func batchResolveConflicts(conflicts []sync.Conflict, keep string, claudeDir string, state *sync.SyncState) error {
+	choice, err := sync.ParseResolution(keep)
+	if err != nil || choice == sync.KeepMerged {
+		return fmt.Errorf("--keep must be 'local' or 'remote'")
+	}
+
+	result, err := sync.ResolveConflicts(claudeDir, state, conflicts, choice)
+	if result == nil {
+		return err
+	}
+
+	for _, c := range result.Resolved {
+		if choice == sync.KeepLocal {
+			fmt.Printf("%s✓%s Kept local: %s\n", colorGreen, colorReset, filepath.Base(c.Path))
+		} else {
+			fmt.Printf("%s✓%s Kept remote: %s\n", colorGreen, colorReset, filepath.Base(c.Path))
+		}
+	}
+	for _, f := range result.Failed {
+		fmt.Printf("%s✗%s Failed to resolve %s: %v\n", colorYellow, colorReset, f.Conflict.Path, f.Err)
+	}
+	if err != nil {
+		fmt.Printf("%s⚠%s Warning: %v\n", colorYellow, colorReset, err)
+	}
+
+	fmt.Printf("\n%s✓%s Resolved %d conflict(s)\n", colorGreen, colorReset, len(result.Resolved))
+	return nil
+}
+
+func interactiveResolveConflicts(conflicts []sync.Conflict, claudeDir string, state *sync.SyncState) error {
+	if inputDisabled() {
+		return noInputError("how to resolve each conflict (pass --keep local or --keep remote)")
+	}
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Println("For each conflict, choose how to resolve:")
+	fmt.Printf("  %s[l]%s Keep local  %s[r]%s Keep remote  %s[d]%s Show diff  %s[o]%s Open  %s[s]%s Skip  %s[q]%s Quit\n\n",
+		colorCyan, colorReset,
+		colorCyan, colorReset,
+		colorCyan, colorReset,
+		colorCyan, colorReset,
+		colorCyan, colorReset,
+		colorCyan, colorReset)
+
+	resolved := 0
+	for i, c := range conflicts {
+		fmt.Printf("%s[%d/%d]%s %s\n", colorCyan, i+1, len(conflicts), colorReset, c.Path)
+		fmt.Printf("        Local: %s  |  Remote: %s  |  Conflict from: %s\n",
+			util.FormatSize(c.LocalSize), util.FormatSize(c.RemoteSize), conflictTime(c))
+		if origin := describeConflictOrigin(c); origin != "" {
+			fmt.Printf("        %sRemote version: %s%s\n", colorDim, origin, colorReset)
+		}
+
+	promptLoop:
+		for {
+			fmt.Printf("        %sResolve [l/r/d/o/s/q]:%s ", colorDim, colorReset)
+			input, _ := reader.ReadString('\n')
+			input = strings.TrimSpace(strings.ToLower(input))
+
+			switch input {
+			case "l", "local":
+				if err := sync.ResolveConflict(claudeDir, state, c, sync.KeepLocal, nil); err != nil {
+					fmt.Printf("        %s✗%s Error: %v\n", colorYellow, colorReset, err)
+				} else {
+					fmt.Printf("        %s✓%s Kept local version\n\n", colorGreen, colorReset)
+					resolved++
+				}
+				break promptLoop
+
+			case "r", "remote":
+				if err := sync.ResolveConflict(claudeDir, state, c, sync.KeepRemote, nil); err != nil {
+					fmt.Printf("        %s✗%s Error: %v\n", colorYellow, colorReset, err)
+				} else {
+					fmt.Printf("        %s✓%s Replaced with remote version\n\n", colorGreen, colorReset)
+					resolved++
+				}
+				break promptLoop
+
+			case "d", "diff":
+				// Show diff
+				showDiff(filepath.Join(claudeDir, c.Path), filepath.Join(claudeDir, c.ConflictPath))
+
+			case "o", "open":
+				if err := openConflictPair(claudeDir, c); err != nil {
+					fmt.Printf("        %s✗%s Error: %v\n", colorYellow, colorReset, err)
+				}
+
+			case "s", "skip":
+				fmt.Printf("        %s→%s Skipped\n\n", colorDim, colorReset)
+				break promptLoop
+
+			case "q", "quit":
+				// Save state before quitting if any conflicts were resolved
+				if resolved > 0 {
+					if err := state.Save(); err != nil {
+						fmt.Printf("%s⚠%s Warning: failed to save state: %v\n", colorYellow, colorReset, err)
+					}
+				}
+				fmt.Printf("\n%s✓%s Resolved %d of %d conflict(s)\n", colorGreen, colorReset, resolved, len(conflicts))
+				return nil
+
+			default:
+				fmt.Printf("        %sInvalid choice. Use l/r/d/o/s/q%s\n", colorDim, colorReset)
+			}
+		}
+	}
+
+	// Save updated state
+	if resolved > 0 {
+		if err := state.Save(); err != nil {
+			fmt.Printf("%s⚠%s Warning: failed to save state: %v\n", colorYellow, colorReset, err)
+		}
+	}
+
+	fmt.Printf("%s✓%s Resolved %d of %d conflict(s)\n", colorGreen, colorReset, resolved, len(conflicts))
+	return nil
+}
+
+func showDiff(localPath, conflictPath string) {
+	// Try to use diff command
+	cmd := exec.Command("diff", "-u", "--color=always", localPath, conflictPath)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	fmt.Println()
+	fmt.Printf("        %s--- Local%s\n", colorGreen, colorReset)
+	fmt.Printf("        %s+++ Remote (conflict)%s\n", colorCyan, colorReset)
+	fmt.Println()
+
+	if err := cmd.Run(); err != nil {
+		// diff returns exit code 1 when files differ, which is expected
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			// Files differ, this is normal
+		} else if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 2 {
+			// diff command failed
+			fmt.Printf("        %sCould not run diff command%s\n", colorDim, colorReset)
+
+			// Fall back to showing file sizes
+			localInfo, _ := os.Stat(localPath)
+			conflictInfo, _ := os.Stat(conflictPath)
+			if localInfo != nil && conflictInfo != nil {
+				fmt.Printf("        Local:  %s (%s)\n", localPath, util.FormatSize(localInfo.Size()))
+				fmt.Printf("        Remote: %s (%s)\n", conflictPath, util.FormatSize(conflictInfo.Size()))
+			}
+		}
+	}
+	fmt.Println()
+}
+
+// openConflictPair opens the local file and its .conflict counterpart for review.
+// With $VISUAL or $EDITOR set, both files open side by side in the editor and
+// the call blocks until it exits; otherwise the pair is revealed in the OS file
+// manager (Finder, Explorer, or whatever xdg-open launches).
+func openConflictPair(claudeDir string, c sync.Conflict) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	localPath := filepath.Join(claudeDir, filepath.FromSlash(c.Path))
+	conflictPath := filepath.Join(claudeDir, filepath.FromSlash(c.ConflictPath))
+	name, args := conflictOpenCommand(runtime.GOOS, editor, localPath, conflictPath)
+	if name == "" {
+		return fmt.Errorf("don't know how to open files on %s; set $EDITOR", runtime.GOOS)
+	}
+
+	cmd := exec.Command(name, args...)
+	if editor != "" {
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to run %s: %w", name, err)
+		}
+		return nil
+	}
+
+	// File managers detach; don't wait for them, but reap the process
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to open file manager: %w", err)
+	}
+	go func() { _ = cmd.Wait() }()
+	fmt.Printf("%s✓%s Revealed %s\n", colorGreen, colorReset, filepath.Base(c.ConflictPath))
+	return nil
+}
+
+// conflictOpenCommand builds the command used to review a conflicting pair.
+// Editors known to have a diff mode get it; other editors just receive both
+// files. Without an editor it falls back to the platform's file manager.
+func conflictOpenCommand(goos, editor, localPath, conflictPath string) (string, []string) {
+	if fields := strings.Fields(editor); len(fields) > 0 {
+		name, args := fields[0], fields[1:]
+		switch strings.TrimSuffix(filepath.Base(name), ".exe") {
+		case "vim", "nvim", "gvim", "mvim": // Plain vi has no diff mode
+			args = append(args, "-d")
+		case "code", "code-insiders", "codium", "cursor", "windsurf":
+			args = append(args, "--diff")
+		}
+		return name, append(args, localPath, conflictPath)
+	}
+
+	switch goos {
+	case "darwin":
+		return "open", []string{"-R", localPath, conflictPath}
+	case "windows":
+		return "explorer", []string{"/select," + conflictPath}
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return "xdg-open", []string{filepath.Dir(conflictPath)}
+	}
+	return "", nil
+}
+
+func rekeyCmd() *cobra.Command {
+	var usePassphrase, force bool
+	var kdf kdfCosts
+
+	cmd := &cobra.Command{
+		Use:   "rekey",
+		Short: "Switch to a new encryption key, re-encrypting the remote",
+		Long: `Generate a new key (or derive one from a new passphrase with --passphrase)
+and re-encrypt every remote file with it: synced files, versions, trash,
+and claude-sync's own metadata. Nothing has to be re-pushed.
+
+The new key is kept in age-key.txt.new until every object has been
+re-encrypted; if the run is interrupted, run 'claude-sync rekey' again and
+it carries on with the same key, skipping what is already done. Only then
+does it replace age-key.txt, keeping the old key as age-key.txt.old.
+
+A new passphrase key gets a new random salt, stored with the bucket once
+the key is installed; --kdf-* change the Argon2id costs at the same time.
+
+With a keyring ('claude-sync key keyring'), files are encrypted to the
+bucket's data key, and only the keyring is re-encrypted to the new key.
+
+Stop syncing on other devices until they have the new key: files they push
+in the meantime are encrypted with the old one.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if cfg.DeviceKeys {
+				return fmt.Errorf("this device uses device keys; run 'claude-sync device rotate' to change the data key")
+			}
+			keyPath := cfg.EncryptionKey
+			pendingPath := keyPath + ".new"
+			pendingKDFPath := pendingPath + ".kdf.json"
+			if crypto.IsSSHKeyFile(keyPath) {
+				return fmt.Errorf("%s is an SSH key; rekey only replaces age keys. Run 'claude-sync init' to switch keys, then 'claude-sync reset --remote' and push again", keyPath)
+			}
+			if crypto.IsPluginKeyFile(keyPath) {
+				return fmt.Errorf("%s is an age plugin identity; rekey only replaces age keys. Run 'claude-sync init' to switch keys, then 'claude-sync reset --remote' and push again", keyPath)
+			}
+
+			if err := checkOwnKeyFile(keyPath); err != nil {
+				return fmt.Errorf("%w; point encryption_key_path at a copy in ~/.claude-sync to rekey", err)
+			}
+
+			if crypto.KeyExists(pendingPath) {
+				printInfo("Resuming with the new key from an earlier run (" + pendingPath + ")")
+			} else {
+				if usePassphrase {
+					printInfo("Enter the new passphrase. Use it on all devices.")
+					params, err := newRekeyKDFParams(cfg.KDF, kdf)
+					if err != nil {
+						return err
+					}
+					passphrase, err := promptNewPassphrase()
+					if err != nil {
+						return err
+					}
+					data, err := json.Marshal(params)
+					if err != nil {
+						return err
+					}
+					if err := os.WriteFile(pendingKDFPath, data, 0600); err != nil {
+						return fmt.Errorf("failed to save KDF parameters: %w", err)
+					}
+					err = crypto.GenerateKeyFromPassphraseWithParams(pendingPath, passphrase, params)
+				} else {
+					err = crypto.GenerateKey(pendingPath)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to generate key: %w", err)
+				}
+			}
+
+			oldEnc, err := crypto.NewEncryptor(keyPath)
+			if err != nil {
+				return err
+			}
+			newEnc, err := crypto.NewEncryptorWithRecipients(pendingPath, cfg.Recipients)
+			if err != nil {
+				return err
+			}
+			if newEnc.PublicKey() == oldEnc.PublicKey() {
+				_ = os.Remove(pendingPath)
+				_ = os.Remove(pendingKDFPath)
+				return fmt.Errorf("the new key is the same as the current one")
+			}
+
+			message := "Re-encrypt every remote file with the new key?"
+			if cfg.Keyring {
+				message = "Re-encrypt the bucket's keyring with the new key?"
+			}
+			if !force {
+				var confirm bool
+				prompt := &survey.Confirm{
+					Message: message,
+					Default: false,
+				}
+				if err := askOne(prompt, &confirm); err != nil {
+					return err
+				}
+				if !confirm {
+					fmt.Println("  Cancelled. The new key stays in " + pendingPath + " for the next run.")
+					return nil
+				}
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if cfg.Keyring && errors.Is(err, sync.ErrKeyringLocked) {
+				// An earlier run already moved the keyring to the new key
+				pending := *cfg
+				pending.EncryptionKey = pendingPath
+				syncer, err = sync.NewSyncer(&pending, quiet)
+			}
+			if err != nil {
+				return err
+			}
+			showEncryptProgress(syncer)
+
+			ctx := context.Background()
+			var result *sync.RekeyResult
+			if cfg.Keyring {
+				// Files are encrypted to the keyring's data key: only the
+				// keyring changes
+				if err := syncer.RewrapKeyring(ctx, newEnc); err != nil {
+					return fmt.Errorf("%w; run 'claude-sync rekey' again to continue", err)
+				}
+			} else {
+				result, err = syncer.Rekey(ctx, newEnc)
+				if !quiet && result != nil && result.Rekeyed+result.AlreadyNew+len(result.Failed) > 0 {
+					fmt.Println()
+				}
+				if err != nil {
+					return fmt.Errorf("%w; run 'claude-sync rekey' again to continue", err)
+				}
+				if len(result.Failed) > 0 {
+					for _, key := range result.Failed {
+						fmt.Printf("  %s!%s %s\n", colorYellow, colorReset, key)
+					}
+					return fmt.Errorf("%d object(s) can't be decrypted with the old or the new key; the new key was not installed", len(result.Failed))
+				}
+			}
+
+			if err := os.Rename(keyPath, keyPath+".old"); err != nil {
+				return fmt.Errorf("failed to back up the old key: %w", err)
+			}
+			if err := os.Rename(pendingPath, keyPath); err != nil {
+				return fmt.Errorf("failed to install the new key: %w", err)
+			}
+			if cfg.EncryptCredentials {
+				// Re-encrypt the credentials for the new key
+				if err := config.Save(cfg); err != nil {
+					return err
+				}
+			}
+			if err := installRekeyKDFParams(ctx, syncer, cfg, pendingKDFPath); err != nil {
+				return err
+			}
+
+			if result != nil {
+				fmt.Printf("%s✓%s Re-encrypted %d object(s) (%d already done)\n",
+					colorGreen, colorReset, result.Rekeyed, result.AlreadyNew)
+			} else {
+				fmt.Printf("%s✓%s Re-encrypted the keyring; files are unchanged\n", colorGreen, colorReset)
+			}
+			printSuccess("New key installed: " + keyPath)
+			printInfo("Old key kept as " + keyPath + ".old; delete it once every device has switched.")
+			if crypto.IsProtectedKeyFile(keyPath + ".old") {
+				printInfo("The old key was passphrase-protected; run 'claude-sync key protect' to protect the new one.")
+			} else if crypto.IsKeychainKeyFile(keyPath + ".old") {
+				printInfo("The old key is in the OS keychain; run 'claude-sync key keychain' to move the new one there.")
+			}
+			fmt.Println()
+			if usePassphrase {
+				printInfo("On each other device, run 'claude-sync init --passphrase' with the new passphrase.")
+			} else {
+				printInfo("Copy " + keyPath + " to each other device.")
+				printWarning("Back up this file! You need it on other devices.")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&usePassphrase, "passphrase", false, "Derive the new key from a new passphrase")
+	cmd.Flags().Uint32Var(&kdf.memoryMiB, "kdf-memory", 0, "Argon2id memory in MiB for the new passphrase key (default: current)")
+	cmd.Flags().Uint32Var(&kdf.time, "kdf-time", 0, "Argon2id iterations for the new passphrase key (default: current)")
+	cmd.Flags().Uint8Var(&kdf.threads, "kdf-threads", 0, "Argon2id threads for the new passphrase key (default: current)")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+
+	return cmd
+}
+
+// newRekeyKDFParams returns KDF parameters for a new passphrase key: a new
+// salt, with the costs from the flags or else the current ones.
+func newRekeyKDFParams(current *crypto.KDFParams, kdf kdfCosts) (crypto.KDFParams, error) {
+	base := crypto.DefaultKDFParams()
+	if current != nil {
+		base = *current
+	}
+	if kdf.memoryMiB == 0 {
+		kdf.memoryMiB = base.MemoryKiB / 1024
+	}
+	if kdf.time == 0 {
+		kdf.time = base.Time
+	}
+	if kdf.threads == 0 {
+		kdf.threads = base.Threads
+	}
+	return crypto.NewKDFParams(kdf.memoryMiB, kdf.time, kdf.threads)
+}
+
+// installRekeyKDFParams stores the KDF parameters a rekeyed passphrase key
+// was derived with, in the bucket and the config, once the key is in place.
+// Does nothing for random keys.
+func installRekeyKDFParams(ctx context.Context, syncer *sync.Syncer, cfg *config.Config, path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		// A random key: the old parameters no longer describe it
+		if setConfigKDF(cfg, &crypto.KDFParams{}) {
+			return config.Save(cfg)
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var params crypto.KDFParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := syncer.SaveKDFParams(ctx, params); err != nil {
+		return err
+	}
+	setConfigKDF(cfg, &params)
+	if err := config.Save(cfg); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+func keyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "key",
+		Short: "Manage how the age key is stored on this device",
+		Long: `By default 'claude-sync init' stores the age key in the OS keychain (macOS
+Keychain, Secret Service on Linux, Windows Credential Manager), leaving only
+a pointer in ~/.claude-sync/age-key.txt. Where there is no keychain, or with
+'init --no-keychain', the key is kept in the file in plaintext, so anyone
+with a copy of it (a stolen laptop backup, say) can read your synced data.
+
+'claude-sync key keychain' and 'claude-sync key file' move the key between
+the two. 'claude-sync key protect' instead encrypts the file with a
+passphrase (age's scrypt mode); claude-sync then asks for the passphrase
+each time it needs the key, so automatic sync from Claude Code hooks stops
+working until you unprotect it. 'age -d' opens the protected file too.
+
+'claude-sync key fingerprint' shows a short fingerprint of the key to compare
+across devices. 'claude-sync key keyring' moves the bucket to a random data
+key wrapped by this key, so changing the passphrase is quick.`,
+	}
+	cmd.AddCommand(
+		keyKeychainCmd(),
+		keyFileCmd(),
+		keyProtectCmd(),
+		keyUnprotectCmd(),
+		keyFingerprintCmd(),
+		keyKeyringCmd(),
+	)
+	return cmd
+}
+
+func keyFingerprintCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "fingerprint",
+		Short: "Show the key's fingerprint and check it against the bucket's",
+		Long: `Print a short fingerprint of this device's key. Devices sharing a key, or
+a passphrase, show the same fingerprint; a different one on a device set up
+with a passphrase means the passphrase was mistyped.
+
+The bucket records the fingerprint of the key its files are encrypted to, and
+push and pull refuse to run with a key that doesn't match it.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			local, remote, err := syncer.KeyFingerprints(context.Background())
+			fmt.Println(local)
+			if err != nil {
+				return err
+			}
+			switch {
+			case remote == "":
+				fmt.Printf("%sThe bucket has no fingerprint yet; the next push records it.%s\n", colorDim, colorReset)
+			case remote == local:
+				fmt.Printf("%s✓%s Matches the bucket\n", colorGreen, colorReset)
+			case slices.ContainsFunc(cfg.Recipients, func(r string) bool { return crypto.KeyFingerprint(r) == remote }):
+				fmt.Printf("%s✓%s The bucket's key (%s) is one of this device's recipients\n", colorGreen, colorReset, remote)
+			default:
+				fmt.Printf("%s!%s The bucket's key is %s\n", colorYellow, colorReset, remote)
+				fmt.Printf("%sIf this device was set up with a passphrase, run 'claude-sync init --passphrase' to re-enter it.%s\n", colorDim, colorReset)
+			}
+			return nil
+		},
+	}
+}
+
+func keyKeyringCmd() *cobra.Command {
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "keyring",
+		Short: "Encrypt files with a random data key, so passphrase changes are quick",
+		Long: `Move the bucket to envelope encryption: files are encrypted with a random
+data key, kept in the bucket's keyring (_metadata/keyring.age) encrypted to
+this device's key. 'claude-sync rekey' then only rewrites the keyring
+instead of re-encrypting and re-uploading every file.
+
+Every remote file is re-encrypted to the data key once. If the run is
+interrupted, run 'claude-sync key keyring' again and it carries on with the
+same data key. Stop syncing on other devices until it finishes, then run
+'claude-sync init' on each with the same key or passphrase.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if cfg.Keyring {
+				return fmt.Errorf("the keyring is already enabled")
+			}
+			if cfg.DeviceKeys {
+				return fmt.Errorf("this device uses device keys, which already keep the data key in the device registry")
+			}
+
+			if !force {
+				var confirm bool
+				prompt := &survey.Confirm{
+					Message: "Re-encrypt every remote file with a new data key?",
+					Default: false,
+				}
+				if err := askOne(prompt, &confirm); err != nil {
+					return err
+				}
+				if !confirm {
+					fmt.Println("  Cancelled.")
+					return nil
+				}
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+			showEncryptProgress(syncer)
+
+			result, err := syncer.EnableKeyring(context.Background())
+			if !quiet && result != nil && result.Rekeyed+result.AlreadyNew+len(result.Failed) > 0 {
+				fmt.Println()
+			}
+			if err != nil {
+				return fmt.Errorf("%w; run 'claude-sync key keyring' again to continue", err)
+			}
+			if len(result.Failed) > 0 {
+				for _, key := range result.Failed {
+					fmt.Printf("  %s!%s %s\n", colorYellow, colorReset, key)
+				}
+				return fmt.Errorf("%d object(s) can't be decrypted with the current key; the keyring was not enabled", len(result.Failed))
+			}
+
+			cfg.Keyring = true
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+			fmt.Printf("%s✓%s Re-encrypted %d object(s) (%d already done)\n",
+				colorGreen, colorReset, result.Rekeyed, result.AlreadyNew)
+			printSuccess("Keyring enabled")
+			printInfo("'claude-sync rekey' now only rewrites the keyring.")
+			printInfo("On each other device, run 'claude-sync init' again with the same key or passphrase.")
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+	return cmd
+}
+
+// checkOwnKeyFile refuses to rewrite a key file outside ~/.claude-sync, such
+// as an identity file the user also uses with age directly.
+func checkOwnKeyFile(keyPath string) error {
+	rel, err := filepath.Rel(config.ConfigDirPath(), keyPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside ~/.claude-sync and may be used by other tools; claude-sync won't modify it", keyPath)
+	}
+	return nil
+}
+
+func keyKeychainCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "keychain",
+		Short: "Move the age key into the OS keychain",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if err := checkOwnKeyFile(cfg.EncryptionKey); err != nil {
+				return err
+			}
+			if err := crypto.StoreKeyInKeychain(cfg.EncryptionKey); err != nil {
+				return err
+			}
+			printSuccess("Key stored in the OS keychain")
+			printInfo(cfg.EncryptionKey + " now only points to it.")
+			return nil
+		},
+	}
+}
+
+func keyFileCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "file",
+		Short: "Move the age key out of the OS keychain into the key file",
+		Long: `Write the age key from the OS keychain back into the key file, in
+plaintext, and remove it from the keychain. Use this to copy a random key to
+another device, or before moving to a machine without a keychain.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if err := crypto.RestoreKeyFromKeychain(cfg.EncryptionKey); err != nil {
+				return err
+			}
+			printSuccess("Key written to " + cfg.EncryptionKey)
+			return nil
+		},
+	}
+}
+
+// storeKeyInKeychain moves the key at keyPath into the OS keychain, keeping
+// it in the file when there is no keychain to use.
+func storeKeyInKeychain(keyPath string) {
+	if err := crypto.StoreKeyInKeychain(keyPath); err != nil {
+		printWarning("OS keychain not available; key kept in " + keyPath)
+		printInfo("(" + err.Error() + ")")
+		return
+	}
+	printSuccess("Key stored in the OS keychain")
+}
+
+func keyProtectCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "protect",
+		Short: "Encrypt the age key file with a passphrase",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			if err := checkOwnKeyFile(cfg.EncryptionKey); err != nil {
+				return err
+			}
+			printInfo("Choose a passphrase to protect " + cfg.EncryptionKey + ".")
+			passphrase, err := promptNewPassphrase()
+			if err != nil {
+				return err
+			}
+			if err := crypto.ProtectKey(cfg.EncryptionKey, passphrase); err != nil {
+				return err
+			}
+			printSuccess("Key protected: " + cfg.EncryptionKey)
+			printInfo("claude-sync will ask for the passphrase whenever it uses the key.")
+			return nil
+		},
+	}
+}
+
+func keyUnprotectCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unprotect",
+		Short: "Store the age key file in plaintext again",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if !crypto.IsProtectedKeyFile(cfg.EncryptionKey) {
+				return fmt.Errorf("%s is not protected", cfg.EncryptionKey)
+			}
+
+			passphrase, err := promptKeyPassphrase(cfg.EncryptionKey)
+			if err != nil {
+				return err
+			}
+			if err := crypto.UnprotectKey(cfg.EncryptionKey, string(passphrase)); err != nil {
+				return err
+			}
+			printSuccess("Key stored in plaintext: " + cfg.EncryptionKey)
+			return nil
+		},
+	}
+}
+
+func resetCmd() *cobra.Command {
+	var clearRemote, clearLocal, force bool
+
+	cmd := &cobra.Command{
+		Use:   "reset",
+		Short: "Reset claude-sync (clear data and start fresh)",
+		Long: `Reset claude-sync configuration and optionally clear remote/local data.
+
+Use this if you forgot your passphrase or want to start fresh.
+
+Examples:
+  claude-sync reset                    # Clear local config only
+  claude-sync reset --remote           # Also delete all files from cloud storage
+  claude-sync reset --local            # Also clear local sync state
+  claude-sync reset --remote --local   # Full reset (nuclear option)`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reader := bufio.NewReader(os.Stdin)
+
+			fmt.Println()
+			printWarning("This will reset claude-sync:")
+			fmt.Println()
+
+			if clearRemote {
+				fmt.Printf("  %s•%s Delete ALL files from cloud storage bucket\n", colorYellow, colorReset)
+			}
+			if clearLocal {
+				fmt.Printf("  %s•%s Clear local sync state\n", colorYellow, colorReset)
+			}
+			fmt.Printf("  %s•%s Delete local config and encryption key\n", colorYellow, colorReset)
+			fmt.Println()
+
+			if !force && inputDisabled() {
+				return noInputError("Type 'reset' to confirm (or pass --force)")
+			}
+			if !force {
+				fmt.Printf("%sType 'reset' to confirm:%s ", colorYellow, colorReset)
+				confirm, _ := reader.ReadString('\n')
+				if strings.TrimSpace(confirm) != "reset" {
+					fmt.Println("Aborted.")
+					return nil
+				}
+				fmt.Println()
+			}
+
+			// Clear remote if requested
+			if clearRemote {
+				fmt.Printf("%s⋯%s Deleting remote files...\n", colorDim, colorReset)
+
+				cfg, err := config.Load()
+				if err != nil {
+					printWarning("Could not load config: " + err.Error())
+				} else {
+					storageCfg := cfg.GetStorageConfig()
+					store, err := storage.New(storageCfg)
+					if err != nil {
+						printWarning("Could not connect to storage: " + err.Error())
+					} else {
+						ctx := context.Background()
+						objects, err := store.List(ctx, "")
+						if err != nil {
+							printWarning("Could not list objects: " + err.Error())
+						} else {
+							keys := make([]string, len(objects))
+							for i, obj := range objects {
+								keys[i] = obj.Key
+							}
+							if cfg.Trash {
+								moved, err := sync.MoveToTrash(ctx, store, keys, time.Now())
+								if err != nil {
+									printWarning("Could not move files to the trash: " + err.Error())
+								} else {
+									printSuccess(fmt.Sprintf("Moved %d files to the remote trash (see 'claude-sync trash')", len(moved)))
+								}
+							} else if err := store.DeleteBatch(ctx, keys); err != nil {
+								printWarning("Could not delete remote files: " + err.Error())
+							} else {
+								printSuccess(fmt.Sprintf("Deleted %d files from storage", len(keys)))
+							}
+						}
+					}
+				}
+			}
+
+			// Clear local state if requested
+			if clearLocal {
+				statePath := config.StateFilePath()
+				if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+					printWarning("Could not remove state file: " + err.Error())
+				} else {
+					printSuccess("Cleared local sync state")
+				}
+			}
+
+			// Always clear config and key
+			configDir := config.ConfigDirPath()
+			if err := os.RemoveAll(configDir); err != nil {
+				return fmt.Errorf("failed to remove config directory: %w", err)
+			}
+			printSuccess("Removed " + configDir)
+
+			fmt.Println()
+			printSuccess("Reset complete!")
+			fmt.Println()
+			printInfo("Run 'claude-sync init' to set up again with a new passphrase.")
+			fmt.Println()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&clearRemote, "remote", false, "Delete all files from cloud storage bucket")
+	cmd.Flags().BoolVar(&clearLocal, "local", false, "Clear local sync state")
+	cmd.Flags().BoolVar(&force, "force", false, "Skip confirmation prompt")
+
+	return cmd
+}
+
+func rebuildHistoryCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rebuild-history",
+		Short: "Rebuild ~/.claude/history.jsonl from session files",
+		Long: `Reconstruct the prompt history by merging the current history.jsonl with
+user prompts extracted from session files under ~/.claude/projects/.
+
+history.jsonl is synced as a single file, so pushes from two devices are
+last-writer-wins and one device's entries can be lost, breaking the /resume
+session picker. Session files sync cleanly (one file per session), so the
+full history can always be rebuilt from them.
+
+Existing entries are preserved as-is; recovered entries are merged in,
+deduplicated, and sorted by timestamp. The previous file is kept as
+history.jsonl.bak.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHistoryRebuild()
+		},
+	}
+}
+
+// runHistoryRebuild rebuilds history.jsonl and reports how many prompts were
+// recovered from session files.
+func runHistoryRebuild() error {
+	claudeDir, err := config.ClaudeDirE()
+	if err != nil {
+		return err
+	}
+	result, err := sync.RebuildHistory(claudeDir)
+	if err != nil {
+		return err
+	}
+	recovered := result.Merged - result.Existing
+	printSuccess(fmt.Sprintf("Rebuilt history.jsonl: %d entries (%d existing + %d recovered from sessions)",
+		result.Merged, result.Existing, recovered))
+	return nil
+}
+
+func credentialsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "credentials",
+		Short: "Encrypt the storage credentials in config.yaml",
+		Long: `By default the storage keys and passwords in ~/.claude-sync/config.yaml are
+protected only by the file's permissions. 'claude-sync credentials encrypt'
+stores them encrypted to the age key instead (wherever it is kept, the OS
+keychain included), so a copy of config.yaml alone doesn't give access to the
+bucket. claude-sync decrypts them each time it loads the config; with a
+passphrase-protected key, that means every command asks for the passphrase.`,
+	}
+	cmd.AddCommand(
+		credentialsEncryptCmd(true),
+		credentialsEncryptCmd(false),
+	)
+	return cmd
+}
+
+func credentialsEncryptCmd(encrypt bool) *cobra.Command {
+	use, short := "encrypt", "Encrypt the credentials in config.yaml with the age key"
+	if !encrypt {
+		use, short = "decrypt", "Store the credentials in config.yaml in plaintext again"
+	}
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if cfg.EncryptCredentials == encrypt {
+				return fmt.Errorf("credentials are already %sed", use)
+			}
+			cfg.EncryptCredentials = encrypt
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+			if encrypt {
+				printSuccess("Credentials encrypted with " + cfg.EncryptionKey)
+				printInfo("Keep the key: without it the credentials have to be entered again with 'claude-sync init'.")
+			} else {
+				printSuccess("Credentials stored in plaintext: " + config.ConfigFilePath())
+			}
+			return nil
+		},
+	}
+}
+
+func configCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Read and change settings in config.yaml",
+		Long: `Settings are named by their path in ~/.claude-sync/config.yaml, with dots
+between sections:
+
+  claude-sync config get storage.bucket
+  claude-sync config set storage.region eu-west-1
+  claude-sync config set exclude "plugins/**/node_modules,*.log"
+  claude-sync config set delete_threshold ""     back to the default
+  claude-sync config validate
+
+Lists are comma-separated, switches take true or false, and an empty value
+clears a setting. Sections such as notify or path_map are edited in the file
+itself. 'config validate' checks the settings and that the bucket can be
+reached with them.`,
+	}
+	cmd.AddCommand(
+		configGetCmd(),
+		configSetCmd(),
+		configValidateCmd(),
+	)
+	return cmd
+}
+
+func configGetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <setting>",
+		Short: "Print a setting",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			value, err := cfg.Get(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Println(value)
+			return nil
+		},
+	}
+}
+
+func configSetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <setting> <value>",
+		Short: "Change a setting",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if err := cfg.Set(args[0], args[1]); err != nil {
+				return err
+			}
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+			value, _ := cfg.Get(args[0])
+			fmt.Printf("%s✓%s %s = %s\n", colorGreen, colorReset, args[0], value)
+
+			// Changing provider or credentials often takes several sets, so
+			// an incomplete storage config is only a warning
+			if strings.HasPrefix(args[0], "storage.") {
+				if err := cfg.GetStorageConfig().Validate(); err != nil {
+					fmt.Printf("%s!%s Storage settings are incomplete: %v\n", colorYellow, colorReset, err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func configValidateCmd() *cobra.Command {
+	var offline bool
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the settings and that the bucket can be reached",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			problems := configProblems(cfg)
+			for _, problem := range problems {
+				fmt.Printf("%s✗%s %v\n", colorYellow, colorReset, problem)
+			}
+			if len(problems) > 0 {
+				return fmt.Errorf("%d problem(s) in %s", len(problems), config.ConfigFilePath())
+			}
+			fmt.Printf("%s✓%s Settings are valid\n", colorGreen, colorReset)
+			if offline {
+				return nil
+			}
+
+			storageCfg := cfg.GetStorageConfig()
+			store, err := storage.New(storageCfg)
+			if err != nil {
+				return err
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			exists, err := store.BucketExists(ctx)
+			if err != nil {
+				return fmt.Errorf("could not reach bucket %s: %w", storageCfg.Bucket, err)
+			}
+			if !exists {
+				if storageCfg.Provider == storage.ProviderWebDAV {
+					return fmt.Errorf("WebDAV path %s does not exist", storageCfg.PathPrefix)
+				}
+				return fmt.Errorf("bucket %s does not exist", storageCfg.Bucket)
+			}
+			fmt.Printf("%s✓%s Connected to %s (%s)\n", colorGreen, colorReset, storageCfg.Bucket, storageCfg.Provider)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&offline, "offline", false, "Check the settings only, without connecting")
+	return cmd
+}
+
+// configProblems checks the settings that are parsed when used, so 'config
+// validate' reports them all up front.
+func configProblems(cfg *config.Config) []error {
+	var problems []error
+	check := func(err error) {
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+	check(cfg.GetStorageConfig().Validate())
+	if !crypto.KeyExists(cfg.EncryptionKey) {
+		check(fmt.Errorf("encryption key %s not found", cfg.EncryptionKey))
+	}
+	_, err := cfg.StaleAfterDuration()
+	check(err)
+	_, err = cfg.VersionsMaxAgeDuration()
+	check(err)
+	_, err = cfg.HashAlgorithmName()
+	check(err)
+	_, err = cfg.CommandPrecedence()
+	check(err)
+	_, err = cfg.ReviewedCommandSets()
+	check(err)
+	check(cfg.CheckPingURL())
+	if cfg.Report != nil {
+		_, err = cfg.Report.ReportPeriod()
+		check(err)
+	}
+	return problems
+}
+
+func deviceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "device",
+		Short: "Give each device its own key, registered with the bucket",
+		Long: `Instead of sharing one key (or passphrase) between devices, each device can
+have its own key. The bucket keeps a registry of devices, encrypted to every
+registered device's key, holding the data key files are encrypted to; a
+device reads the data key from it each time it syncs.
+
+  claude-sync device init           on a device with the bucket's key
+  claude-sync device id             on a new device: prints its public key
+  claude-sync device add NAME KEY   on a registered device
+  claude-sync init --key-file ~/.claude-sync/device-key.txt
+                                    on the new device, to finish setting up
+
+'claude-sync device revoke NAME' removes a device and rotates the data key,
+re-encrypting every remote file, so the revoked device can't read anything
+pushed afterwards even if it kept a copy of the old key. Revoke its storage
+credentials as well: the registry can't stop it deleting objects.`,
+	}
+	cmd.AddCommand(
+		deviceIDCmd(),
+		deviceInitCmd(),
+		deviceListCmd(),
+		deviceAddCmd(),
+		holdsLock(deviceRevokeCmd()),
+		holdsLock(deviceRotateCmd()),
+	)
+	return cmd
+}
+
+// ensureDeviceKey returns this device's own key, generating it on first use.
+func ensureDeviceKey() (string, *crypto.Encryptor, error) {
+	keyPath := config.DeviceKeyFilePath()
+	if cfg, err := config.Load(); err == nil && cfg.DeviceKeys {
+		keyPath = cfg.EncryptionKey
+	}
+	if !crypto.KeyExists(keyPath) {
+		if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+			return "", nil, err
+		}
+		if err := crypto.GenerateKey(keyPath); err != nil {
+			return "", nil, err
+		}
+	}
+	enc, err := crypto.NewEncryptor(keyPath)
+	return keyPath, enc, err
+}
+
+func deviceIDCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "id",
+		Short: "Print this device's public key, creating its key if needed",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keyPath, enc, err := ensureDeviceKey()
+			if err != nil {
+				return err
+			}
+			fmt.Println(enc.PublicKey())
+			if !quiet {
+				fmt.Printf("%sKey: %s. On a registered device, run 'claude-sync device add <name> <this key>'.%s\n",
+					colorDim, keyPath, colorReset)
+			}
+			return nil
+		},
+	}
+}
+
+func deviceInitCmd() *cobra.Command {
+	var name string
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create the device registry, registering this device",
+		Long: `Create the bucket's device registry and register this device with a key of
+its own (~/.claude-sync/device-key.txt), switching it over to device keys.
+
+The bucket's current key becomes the data key, so nothing is re-encrypted.
+Devices still using that key directly keep syncing until the next
+'claude-sync device revoke' or 'claude-sync device rotate'.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if cfg.DeviceKeys {
+				return fmt.Errorf("this device already uses device keys")
+			}
+			if name == "" {
+				name, _ = os.Hostname()
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+			keyPath, device, err := ensureDeviceKey()
+			if err != nil {
+				return err
+			}
+			if err := syncer.EnableDeviceKeys(context.Background(), device, name); err != nil {
+				return err
+			}
+
+			cfg.DeviceKeys = true
+			cfg.EncryptionKey = keyPath
+			if err := config.Save(cfg); err != nil {
+				return err
+			}
+			printSuccess("Registered this device as " + name)
+			printInfo("Add other devices with 'claude-sync device add'.")
+			printWarning("Back up " + keyPath + ", or register a second device: the registry can only be opened with a registered device's key.")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "Name for this device (default: hostname)")
+	return cmd
+}
+
+func deviceListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List registered devices",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+			reg, err := syncer.Devices(context.Background())
+			if err != nil {
+				return err
+			}
+
+			for _, d := range reg.Devices {
+				fmt.Printf("  %s%-20s%s %s %s(added %s)%s\n",
+					colorBold, d.Name, colorReset, crypto.KeyFingerprint(d.Recipient),
+					colorDim, formatTime(d.AddedAt), colorReset)
+			}
+			if reg.PendingKey != "" {
+				printWarning("A data key rotation is unfinished; run 'claude-sync device rotate' to complete it.")
+			}
+			return nil
+		},
+	}
+}
+
+func deviceAddCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add NAME PUBLIC_KEY",
+		Short: "Register another device by its public key",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+			if err := syncer.AddDevice(context.Background(), args[0], args[1]); err != nil {
+				return err
+			}
+			printSuccess("Registered " + args[0])
+			printInfo("On that device, run 'claude-sync init --key-file " + config.DeviceKeyFilePath() + "'.")
+			return nil
+		},
+	}
+}
 
-		fmt.Printf("%s[%d/%d]%s %s\n", colorCyan, i+1, len(conflicts), colorReset, relOriginal)
-		fmt.Printf("        Local: %s  |  Remote: %s  |  Conflict from: %s\n",
-			util.FormatSize(localSize), util.FormatSize(conflictSize), c.Timestamp)
+func deviceRevokeCmd() *cobra.Command {
+	var force bool
 
-	promptLoop:
-		for {
-			fmt.Printf("        %sResolve [l/r/d/s/q]:%s ", colorDim, colorReset)
-			input, _ := reader.ReadString('\n')
-			input = strings.TrimSpace(strings.ToLower(input))
+	cmd := &cobra.Command{
+		Use:   "revoke NAME",
+		Short: "Remove a device and rotate the data key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if ok, err := confirmRotation(force, "Revoke "+args[0]+" and re-encrypt every remote file with a new data key?"); err != nil || !ok {
+				return err
+			}
+			return runRotation(func(ctx context.Context, syncer *sync.Syncer) (*sync.RekeyResult, error) {
+				return syncer.RevokeDevice(ctx, args[0])
+			})
+		},
+	}
 
-			switch input {
-			case "l", "local":
-				// Keep local, delete conflict
-				if err := os.Remove(c.ConflictPath); err != nil {
-					fmt.Printf("        %s✗%s Error: %v\n", colorYellow, colorReset, err)
-				} else {
-					fmt.Printf("        %s✓%s Kept local version\n\n", colorGreen, colorReset)
-					// Update state with the kept file's hash
-					if info, err := os.Stat(c.OriginalPath); err == nil {
-						if hash, err := sync.HashFile(c.OriginalPath); err == nil {
-							state.UpdateFile(relOriginal, info, hash)
-							state.MarkUploaded(relOriginal)
-						}
-					}
-					resolved++
-				}
-				break promptLoop
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+	return cmd
+}
 
-			case "r", "remote":
-				// Replace local with conflict
-				if err := os.Rename(c.ConflictPath, c.OriginalPath); err != nil {
-					fmt.Printf("        %s✗%s Error: %v\n", colorYellow, colorReset, err)
-				} else {
-					fmt.Printf("        %s✓%s Replaced with remote version\n\n", colorGreen, colorReset)
-					// Update state with the new file's hash
-					if info, err := os.Stat(c.OriginalPath); err == nil {
-						if hash, err := sync.HashFile(c.OriginalPath); err == nil {
-							state.UpdateFile(relOriginal, info, hash)
-							state.MarkUploaded(relOriginal)
-						}
-					}
-					resolved++
-				}
-				break promptLoop
+func deviceRotateCmd() *cobra.Command {
+	var force bool
 
-			case "d", "diff":
-				// Show diff
-				showDiff(c.OriginalPath, c.ConflictPath)
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Re-encrypt every remote file with a new data key",
+		Long: `Generate a new data key and re-encrypt every remote file with it, or finish
+a rotation that was interrupted. Devices using the bucket's key directly
+rather than through the registry stop being able to sync.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if ok, err := confirmRotation(force, "Re-encrypt every remote file with a new data key?"); err != nil || !ok {
+				return err
+			}
+			return runRotation(func(ctx context.Context, syncer *sync.Syncer) (*sync.RekeyResult, error) {
+				return syncer.RotateDataKey(ctx)
+			})
+		},
+	}
 
-			case "s", "skip":
-				fmt.Printf("        %s→%s Skipped\n\n", colorDim, colorReset)
-				break promptLoop
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation prompt")
+	return cmd
+}
 
-			case "q", "quit":
-				// Save state before quitting if any conflicts were resolved
-				if resolved > 0 {
-					if err := state.Save(); err != nil {
-						fmt.Printf("%s⚠%s Warning: failed to save state: %v\n", colorYellow, colorReset, err)
-					}
-				}
-				fmt.Printf("\n%s✓%s Resolved %d of %d conflict(s)\n", colorGreen, colorReset, resolved, len(conflicts))
-				return nil
+func confirmRotation(force bool, message string) (bool, error) {
+	if force {
+		return true, nil
+	}
+	var confirm bool
+	prompt := &survey.Confirm{Message: message, Default: false}
+	if err := askOne(prompt, &confirm); err != nil {
+		return false, err
+	}
+	if !confirm {
+		fmt.Println("  Aborted.")
+	}
+	return confirm, nil
+}
 
-			default:
-				fmt.Printf("        %sInvalid choice. Use l/r/d/s/q%s\n", colorDim, colorReset)
-			}
-		}
+// runRotation runs a data key rotation with progress output.
+func runRotation(rotate func(context.Context, *sync.Syncer) (*sync.RekeyResult, error)) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	syncer, err := sync.NewSyncer(cfg, quiet)
+	if err != nil {
+		return err
 	}
+	showEncryptProgress(syncer)
 
-	// Save updated state
-	if resolved > 0 {
-		if err := state.Save(); err != nil {
-			fmt.Printf("%s⚠%s Warning: failed to save state: %v\n", colorYellow, colorReset, err)
+	result, err := rotate(context.Background(), syncer)
+	if !quiet && result != nil && result.Rekeyed+result.AlreadyNew+len(result.Failed) > 0 {
+		fmt.Println()
+	}
+	if result != nil {
+		for _, key := range result.Failed {
+			fmt.Printf("  %s!%s %s\n", colorYellow, colorReset, key)
 		}
 	}
-
-	fmt.Printf("%s✓%s Resolved %d of %d conflict(s)\n", colorGreen, colorReset, resolved, len(conflicts))
+	if err != nil {
+		return fmt.Errorf("%w; run 'claude-sync device rotate' to finish", err)
+	}
+	fmt.Printf("%s✓%s Re-encrypted %d object(s) (%d already done)\n",
+		colorGreen, colorReset, result.Rekeyed, result.AlreadyNew)
+	printInfo("Registered devices pick up the new data key on their next sync.")
 	return nil
 }
 
-func showDiff(localPath, conflictPath string) {
-	// Try to use diff command
-	cmd := exec.Command("diff", "-u", "--color=always", localPath, conflictPath)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	fmt.Println()
-	fmt.Printf("        %s--- Local%s\n", colorGreen, colorReset)
-	fmt.Printf("        %s+++ Remote (conflict)%s\n", colorCyan, colorReset)
-	fmt.Println()
-
-	if err := cmd.Run(); err != nil {
-		// diff returns exit code 1 when files differ, which is expected
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-			// Files differ, this is normal
-		} else if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 2 {
-			// diff command failed
-			fmt.Printf("        %sCould not run diff command%s\n", colorDim, colorReset)
-
-			// Fall back to showing file sizes
-			localInfo, _ := os.Stat(localPath)
-			conflictInfo, _ := os.Stat(conflictPath)
-			if localInfo != nil && conflictInfo != nil {
-				fmt.Printf("        Local:  %s (%s)\n", localPath, util.FormatSize(localInfo.Size()))
-				fmt.Printf("        Remote: %s (%s)\n", conflictPath, util.FormatSize(conflictInfo.Size()))
-			}
+// showEncryptProgress prints each object as it is re-encrypted, unless quiet.
+func showEncryptProgress(syncer *sync.Syncer) {
+	if quiet {
+		return
+	}
+	syncer.SetProgressFunc(func(event sync.ProgressEvent) {
+		if event.Action == "encrypt" && !event.Complete {
+			fmt.Printf("\r%s→%s %s[%d/%d]%s %s%s",
+				colorGreen, colorReset,
+				colorDim, event.Current, event.Total, colorReset,
+				util.TruncatePath(event.Path, 50), strings.Repeat(" ", 10))
 		}
-	}
-	fmt.Println()
+	})
 }
 
-func resetCmd() *cobra.Command {
-	var clearRemote, clearLocal, force bool
+func adoptCmd() *cobra.Command {
+	var force bool
 
 	cmd := &cobra.Command{
-		Use:   "reset",
-		Short: "Reset claude-sync (clear data and start fresh)",
-		Long: `Reset claude-sync configuration and optionally clear remote/local data.
+		Use:   "adopt",
+		Short: "Carry sync state over after ~/.claude moved",
+		Long: `Adopt this device's sync state after its home or Claude directory moved, e.g.
+after a username change or migrating to a new machine.
 
-Use this if you forgot your passphrase or want to start fresh.
+Session directories named for the old home (projects/-Users-old-...) are
+renamed for the new one so Claude Code finds them, and this device's state
+follows them. Tracked files that are gone are forgotten, so the next pull
+restores them instead of the next push deleting them remotely.
 
-Examples:
-  claude-sync reset                    # Clear local config only
-  claude-sync reset --remote           # Also delete all files from cloud storage
-  claude-sync reset --local            # Also clear local sync state
-  claude-sync reset --remote --local   # Full reset (nuclear option)`,
+Push and pull refuse to run until a move is adopted.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			reader := bufio.NewReader(os.Stdin)
-
-			fmt.Println()
-			printWarning("This will reset claude-sync:")
-			fmt.Println()
-
-			if clearRemote {
-				fmt.Printf("  %s•%s Delete ALL files from cloud storage bucket\n", colorYellow, colorReset)
-			}
-			if clearLocal {
-				fmt.Printf("  %s•%s Clear local sync state\n", colorYellow, colorReset)
+			cfg, err := config.Load()
+			if err != nil {
+				return err
 			}
-			fmt.Printf("  %s•%s Delete local config and encryption key\n", colorYellow, colorReset)
-			fmt.Println()
 
-			if !force {
-				fmt.Printf("%sType 'reset' to confirm:%s ", colorYellow, colorReset)
-				confirm, _ := reader.ReadString('\n')
-				if strings.TrimSpace(confirm) != "reset" {
-					fmt.Println("Aborted.")
-					return nil
-				}
-				fmt.Println()
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
 			}
 
-			// Clear remote if requested
-			if clearRemote {
-				fmt.Printf("%s⋯%s Deleting remote files...\n", colorDim, colorReset)
-
-				cfg, err := config.Load()
-				if err != nil {
-					printWarning("Could not load config: " + err.Error())
-				} else {
-					storageCfg := cfg.GetStorageConfig()
-					store, err := storage.New(storageCfg)
-					if err != nil {
-						printWarning("Could not connect to storage: " + err.Error())
-					} else {
-						ctx := context.Background()
-						objects, err := store.List(ctx, "")
-						if err != nil {
-							printWarning("Could not list objects: " + err.Error())
-						} else {
-							deleted := 0
-							for _, obj := range objects {
-								if err := store.Delete(ctx, obj.Key); err != nil {
-									printWarning("Failed to delete " + obj.Key)
-								} else {
-									deleted++
-								}
-							}
-							printSuccess(fmt.Sprintf("Deleted %d files from storage", deleted))
-						}
-					}
-				}
+			move := syncer.DetectMove()
+			if move == nil || (len(move.Projects) == 0 && move.Missing == 0) {
+				fmt.Println("Nothing to adopt")
+				return nil
 			}
-
-			// Clear local state if requested
-			if clearLocal {
-				statePath := config.StateFilePath()
-				if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
-					printWarning("Could not remove state file: " + err.Error())
-				} else {
-					printSuccess("Cleared local sync state")
-				}
+			if !force && inputDisabled() {
+				return noInputError("Adopt the new location? (pass --force)")
 			}
-
-			// Always clear config and key
-			configDir := config.ConfigDirPath()
-			if err := os.RemoveAll(configDir); err != nil {
-				return fmt.Errorf("failed to remove config directory: %w", err)
+			if !force && !confirmAdopt(move) {
+				fmt.Println("Aborted.")
+				return nil
 			}
-			printSuccess("Removed " + configDir)
-
-			fmt.Println()
-			printSuccess("Reset complete!")
-			fmt.Println()
-			printInfo("Run 'claude-sync init' to set up again with a new passphrase.")
-			fmt.Println()
-
-			return nil
+			return adoptMove(syncer, move)
 		},
 	}
 
-	cmd.Flags().BoolVar(&clearRemote, "remote", false, "Delete all files from cloud storage bucket")
-	cmd.Flags().BoolVar(&clearLocal, "local", false, "Clear local sync state")
-	cmd.Flags().BoolVar(&force, "force", false, "Skip confirmation prompt")
-
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation")
 	return cmd
 }
 
-func rebuildHistoryCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "rebuild-history",
-		Short: "Rebuild ~/.claude/history.jsonl from session files",
-		Long: `Reconstruct the prompt history by merging the current history.jsonl with
-user prompts extracted from session files under ~/.claude/projects/.
-
-history.jsonl is synced as a single file, so pushes from two devices are
-last-writer-wins and one device's entries can be lost, breaking the /resume
-session picker. Session files sync cleanly (one file per session), so the
-full history can always be rebuilt from them.
+// confirmAdopt describes a moved Claude directory and asks to adopt it.
+func confirmAdopt(move *sync.ClaudeDirMove) bool {
+	fmt.Printf("\r%s!%s %s has moved to %s since the last sync\n", colorYellow, colorReset, move.OldClaudeDir, move.NewClaudeDir)
+	if len(move.Projects) > 0 {
+		fmt.Printf("Session directories to rename for %s:\n", move.NewHome)
+		for _, p := range move.Projects {
+			fmt.Printf("  %s→%s %s %s(was %s)%s\n", colorCyan, colorReset, p.To, colorDim, p.From, colorReset)
+		}
+	}
+	fmt.Printf("%s%d tracked file(s) found unchanged", colorDim, move.Matched)
+	if move.Missing > 0 {
+		fmt.Printf(", %d missing (they'll be pulled again, not deleted remotely)", move.Missing)
+	}
+	fmt.Printf("%s\n", colorReset)
 
-Existing entries are preserved as-is; recovered entries are merged in,
-deduplicated, and sorted by timestamp. The previous file is kept as
-history.jsonl.bak.`,
-		RunE: func(cmd *cobra.Command, args []string) error {
-			return runHistoryRebuild()
-		},
+	var confirmed bool
+	prompt := &survey.Confirm{
+		Message: "Adopt the new location?",
+		Default: true,
 	}
+	if err := askOne(prompt, &confirmed); err != nil {
+		return false
+	}
+	return confirmed
 }
 
-// runHistoryRebuild rebuilds history.jsonl and reports how many prompts were
-// recovered from session files.
-func runHistoryRebuild() error {
-	claudeDir, err := config.ClaudeDirE()
-	if err != nil {
-		return err
-	}
-	result, err := sync.RebuildHistory(claudeDir)
+func adoptMove(syncer *sync.Syncer, move *sync.ClaudeDirMove) error {
+	result, err := syncer.AdoptMove(context.Background(), move)
 	if err != nil {
 		return err
 	}
-	recovered := result.Merged - result.Existing
-	printSuccess(fmt.Sprintf("Rebuilt history.jsonl: %d entries (%d existing + %d recovered from sessions)",
-		result.Merged, result.Existing, recovered))
+	fmt.Printf("%s✓%s Adopted %s (%d directories renamed, %d files carried over, %d to pull again)\n",
+		colorGreen, colorReset, move.NewClaudeDir, len(result.Renamed), result.Moved, result.Dropped)
 	return nil
 }
 
@@ -1952,6 +6619,30 @@ type GitHubRelease struct {
 	} `json:"assets"`
 }
 
+// notifyUpdateAvailable tells the configured notifiers about a new
+// release. Failures are only printed: the check itself succeeded.
+func notifyUpdateAvailable(latestVersion string) {
+	cfg, err := config.Load()
+	if err != nil {
+		return
+	}
+	notifier, err := sync.NewNotifier(cfg.Notify)
+	if err != nil || notifier == nil {
+		return
+	}
+	hostname, _ := os.Hostname()
+	event := sync.Event{
+		Kind:    sync.EventUpdateAvailable,
+		Time:    time.Now(),
+		Device:  hostname,
+		Version: latestVersion,
+		Message: "claude-sync v" + latestVersion + " is available; run 'claude-sync update' to install it",
+	}
+	if err := notifier.Notify(context.Background(), event); err != nil {
+		printWarning("Notification failed: " + err.Error())
+	}
+}
+
 func updateCmd() *cobra.Command {
 	var checkOnly bool
 
@@ -1960,14 +6651,32 @@ func updateCmd() *cobra.Command {
 		Short: "Update claude-sync to the latest version",
 		Long: `Check for updates and automatically download the latest version.
 
+Releases come from GitHub unless the config's update section names another
+source (GitHub Enterprise or an HTTPS mirror), whose releases must be signed.
+
 Examples:
   claude-sync update          # Update to latest version
   claude-sync update --check  # Only check for updates, don't install`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			fmt.Printf("%s⋯%s Checking for updates...\n", colorDim, colorReset)
 
-			// Get latest release from GitHub
-			release, err := getLatestRelease()
+			if !checkOnly {
+				execPath, err := currentExecutable()
+				if err != nil {
+					return err
+				}
+				unlock, err := acquireUpdateLock(execPath)
+				if err != nil {
+					return err
+				}
+				defer unlock()
+			}
+
+			source, err := updateSource()
+			if err != nil {
+				return err
+			}
+			release, err := source.latestRelease()
 			if err != nil {
 				return fmt.Errorf("failed to check for updates: %w", err)
 			}
@@ -1992,20 +6701,14 @@ Examples:
 				colorGreen, latestVersion, colorReset)
 
 			if checkOnly {
+				notifyUpdateAvailable(latestVersion)
 				fmt.Printf("\n%sRun 'claude-sync update' to install%s\n", colorDim, colorReset)
 				return nil
 			}
 
 			// Find the right asset for this OS/arch
 			assetName := util.GetBinaryName(latestVersion)
-			var downloadURL string
-			for _, asset := range release.Assets {
-				if asset.Name == assetName {
-					downloadURL = asset.BrowserDownloadURL
-					break
-				}
-			}
-
+			downloadURL := releaseAssetURL(release, assetName)
 			if downloadURL == "" {
 				return fmt.Errorf("no binary available for %s/%s", runtime.GOOS, runtime.GOARCH)
 			}
@@ -2018,24 +6721,20 @@ Examples:
 				return fmt.Errorf("failed to download update: %w", err)
 			}
 
-			// Verify against the release's published checksums
-			if err := verifyChecksum(release, assetName, newBinary); err != nil {
+			// Verify against the release's published (and signed) checksums
+			if err := source.verify(release, assetName, newBinary); err != nil {
 				return fmt.Errorf("refusing to install update: %w", err)
 			}
 
 			// Get current executable path
-			execPath, err := os.Executable()
-			if err != nil {
-				return fmt.Errorf("failed to get executable path: %w", err)
-			}
-			execPath, err = filepath.EvalSymlinks(execPath)
+			execPath, err := currentExecutable()
 			if err != nil {
-				return fmt.Errorf("failed to resolve executable path: %w", err)
+				return err
 			}
 
 			// Replace the current binary
 			fmt.Printf("%s⋯%s Installing update...\n", colorDim, colorReset)
-			if err := replaceBinary(execPath, newBinary); err != nil {
+			if err := replaceBinary(execPath, newBinary, latestVersion); err != nil {
 				return fmt.Errorf("failed to install update: %w", err)
 			}
 
@@ -2051,33 +6750,22 @@ Examples:
 	return cmd
 }
 
-func getLatestRelease() (*GitHubRelease, error) {
-	url := "https://api.github.com/repos/tawanorg/claude-sync/releases/latest"
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("User-Agent", "claude-sync/"+version)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
-	}
+// Release files holding the binaries' checksums and, for signed releases,
+// their Ed25519 signature
+const (
+	checksumsAsset    = "checksums.txt"
+	checksumsSigAsset = "checksums.txt.sig"
+)
 
-	var release GitHubRelease
-	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
-		return nil, err
+// releaseAssetURL returns the download URL of the release file name, or ""
+// when the release doesn't have it.
+func releaseAssetURL(release *GitHubRelease, name string) string {
+	for _, asset := range release.Assets {
+		if asset.Name == name {
+			return asset.BrowserDownloadURL
+		}
 	}
-
-	return &release, nil
+	return ""
 }
 
 // verifyChecksum validates the downloaded binary against the checksums.txt
@@ -2085,13 +6773,7 @@ func getLatestRelease() (*GitHubRelease, error) {
 // warn instead of failing; once checksums.txt is present, a missing entry or
 // a mismatch aborts the update.
 func verifyChecksum(release *GitHubRelease, assetName string, data []byte) error {
-	var checksumsURL string
-	for _, asset := range release.Assets {
-		if asset.Name == "checksums.txt" {
-			checksumsURL = asset.BrowserDownloadURL
-			break
-		}
-	}
+	checksumsURL := releaseAssetURL(release, checksumsAsset)
 	if checksumsURL == "" {
 		fmt.Printf("%s!%s Release has no checksums.txt; skipping integrity verification\n", colorYellow, colorReset)
 		return nil
@@ -2101,11 +6783,15 @@ func verifyChecksum(release *GitHubRelease, assetName string, data []byte) error
 	if err != nil {
 		return fmt.Errorf("failed to download checksums.txt: %w", err)
 	}
+	return matchChecksum(body, assetName, data)
+}
 
+// matchChecksum checks data against assetName's entry in checksums.
+func matchChecksum(checksums []byte, assetName string, data []byte) error {
 	sum := sha256.Sum256(data)
 	got := hex.EncodeToString(sum[:])
 
-	for _, line := range strings.Split(string(body), "\n") {
+	for _, line := range strings.Split(string(checksums), "\n") {
 		fields := strings.Fields(line)
 		if len(fields) != 2 {
 			continue
@@ -2152,7 +6838,77 @@ func downloadBinary(url string) ([]byte, error) {
 	return data, nil
 }
 
-func replaceBinary(execPath string, newBinary []byte) error {
+// currentExecutable returns the path of the running binary, with symlinks
+// resolved.
+func currentExecutable() (string, error) {
+	execPath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to get executable path: %w", err)
+	}
+	execPath, err = filepath.EvalSymlinks(execPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve executable path: %w", err)
+	}
+	return execPath, nil
+}
+
+// updateLockStale is how old an update lock has to be before it's taken to
+// be left by an update that crashed rather than one still running.
+const updateLockStale = 10 * time.Minute
+
+// updateLocked reports whether an update of execPath is running now.
+func updateLocked(execPath string) bool {
+	info, err := os.Stat(execPath + ".lock")
+	return err == nil && time.Since(info.ModTime()) < updateLockStale
+}
+
+// acquireUpdateLock stops two updates replacing the same binary at once. The
+// lock is a file next to the binary holding the owner's PID; unlock removes
+// it.
+func acquireUpdateLock(execPath string) (unlock func(), err error) {
+	lockPath := execPath + ".lock"
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, _ = fmt.Fprintf(f, "%d\n", os.Getpid())
+			_ = f.Close()
+			return func() { _ = os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create update lock: %w", err)
+		}
+		if updateLocked(execPath) {
+			break
+		}
+		// Left by an update that crashed
+		_ = os.Remove(lockPath)
+	}
+	return nil, fmt.Errorf("another update is already running (remove %s if it isn't)", lockPath)
+}
+
+// cleanupUpdateLeftovers removes the .new and .old files an interrupted
+// update leaves next to the binary, unless an update is running now.
+func cleanupUpdateLeftovers(execPath string) {
+	if updateLocked(execPath) {
+		return
+	}
+	_ = os.Remove(execPath + ".new")
+	_ = os.Remove(execPath + ".old")
+}
+
+// runVersion runs a binary with --version and returns its output, replaced
+// in tests.
+var runVersion = func(path string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").CombinedOutput()
+	return string(out), err
+}
+
+// replaceBinary installs newBinary in place of execPath. The old binary is
+// kept as execPath.old until the new one has run and reported wantVersion,
+// and put back if it doesn't.
+func replaceBinary(execPath string, newBinary []byte, wantVersion string) error {
 	// Write to a temporary file first
 	tmpPath := execPath + ".new"
 	if err := os.WriteFile(tmpPath, newBinary, 0755); err != nil {
@@ -2173,6 +6929,18 @@ func replaceBinary(execPath string, newBinary []byte) error {
 		return fmt.Errorf("failed to install new binary: %w", err)
 	}
 
+	// Make sure it runs before throwing the old one away
+	out, err := runVersion(execPath)
+	if err == nil && !strings.Contains(out, wantVersion) {
+		err = fmt.Errorf("it reported %q, not v%s", strings.TrimSpace(out), wantVersion)
+	}
+	if err != nil {
+		if restoreErr := os.Rename(backupPath, execPath); restoreErr != nil {
+			return fmt.Errorf("the new binary doesn't run (%v), and restoring the old one failed: %w; it is at %s", err, restoreErr, backupPath)
+		}
+		return fmt.Errorf("the new binary doesn't run (%v); kept the current version", err)
+	}
+
 	// Remove backup
 	_ = os.Remove(backupPath)
 
@@ -2182,13 +6950,53 @@ func replaceBinary(execPath string, newBinary []byte) error {
 // verifyKeyMatchesRemote checks if the encryption key can decrypt existing remote files.
 // Returns nil if no files exist or if decryption succeeds.
 // Returns an error if files exist but cannot be decrypted with the current key.
-func verifyKeyMatchesRemote(ctx context.Context, store storage.Storage, keyPath string) error {
-	// List remote files
+func verifyKeyMatchesRemote(ctx context.Context, store storage.Storage, scheme, keyPath string) error {
+	enc, err := crypto.NewCipher(scheme, keyPath, nil)
+	if err != nil {
+		return fmt.Errorf("failed to load encryption key: %w", err)
+	}
+
+	// With a keyring, files are encrypted to the data key it holds
+	keyring, err := sync.LoadKeyring(ctx, store, enc)
+	if errors.Is(err, sync.ErrKeyringLocked) {
+		return fmt.Errorf("key_mismatch: cannot decrypt remote files with current key")
+	}
+	if keyring != nil {
+		if enc, err = keyring.DataEncryptor(nil); err != nil {
+			return err
+		}
+	}
+
+	// The canary settles it when the bucket has one
+	found, err := sync.VerifyCanary(ctx, store, enc)
+	if errors.Is(err, sync.ErrCanaryMismatch) {
+		return fmt.Errorf("key_mismatch: cannot decrypt remote files with current key")
+	}
+	if found && err == nil {
+		return nil
+	}
+
+	// Otherwise try a data file
 	objects, err := store.List(ctx, "")
 	if err != nil || len(objects) == 0 {
 		return nil // No files to verify, or error listing (will fail later anyway)
 	}
 
+	// Trashed files may be from before a reset with a different key, the
+	// KDF parameters and key fingerprint aren't encrypted, and the device
+	// registry and keyring are encrypted to other keys
+	live := objects[:0]
+	for _, obj := range objects {
+		if !strings.HasPrefix(obj.Key, sync.TrashPrefix) && obj.Key != sync.KDFParamsKey && obj.Key != sync.FingerprintKey && obj.Key != sync.RegistryKey && obj.Key != sync.KeyringKey && obj.Key != sync.CanaryKey {
+			live = append(live, obj)
+		}
+	}
+	objects = live
+	if len(objects) == 0 {
+		// Nothing pushed yet, but another device may have recorded its key
+		return checkKeyFingerprint(ctx, store, scheme, keyPath)
+	}
+
 	// Find a small file to test with (prefer smaller files for faster verification)
 	var testObj storage.ObjectInfo
 	for _, obj := range objects {
@@ -2211,11 +7019,6 @@ func verifyKeyMatchesRemote(ctx context.Context, store storage.Storage, keyPath
 	}
 
 	// Try to decrypt with current key
-	enc, err := crypto.NewEncryptor(keyPath)
-	if err != nil {
-		return fmt.Errorf("failed to load encryption key: %w", err)
-	}
-
 	_, err = enc.Decrypt(encrypted)
 	if err != nil {
 		return fmt.Errorf("key_mismatch: cannot decrypt remote files with current key")
@@ -2224,6 +7027,51 @@ func verifyKeyMatchesRemote(ctx context.Context, store storage.Storage, keyPath
 	return nil
 }
 
+// checkKeyFingerprint compares the key with the fingerprint the bucket
+// records, if any.
+func checkKeyFingerprint(ctx context.Context, store storage.Storage, scheme, keyPath string) error {
+	remote, err := sync.LoadFingerprint(ctx, store)
+	if err != nil || remote == "" {
+		return nil
+	}
+	enc, err := crypto.NewCipher(scheme, keyPath, nil)
+	if err != nil {
+		return fmt.Errorf("failed to load encryption key: %w", err)
+	}
+	if enc.KeyID() != remote {
+		return fmt.Errorf("key_mismatch: key fingerprint %s is not the bucket's %s", enc.KeyID(), remote)
+	}
+	return nil
+}
+
+// recordKeyFingerprint stores the key's fingerprint and a canary with the
+// bucket unless it already has them, and returns the fingerprint.
+func recordKeyFingerprint(ctx context.Context, store storage.Storage, scheme, keyPath string) (string, error) {
+	enc, err := crypto.NewCipher(scheme, keyPath, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to load encryption key: %w", err)
+	}
+	remote, err := sync.LoadFingerprint(ctx, store)
+	if err != nil {
+		return "", err
+	}
+	if remote == "" {
+		if err := sync.SaveFingerprint(ctx, store, enc.KeyID()); err != nil {
+			return "", err
+		}
+	}
+	found, err := sync.VerifyCanary(ctx, store, enc)
+	if !found {
+		if err != nil {
+			return "", err
+		}
+		if err := sync.SaveCanary(ctx, store, enc); err != nil {
+			return "", err
+		}
+	}
+	return enc.KeyID(), nil
+}
+
 // keyMismatchAction represents the user's choice when a key mismatch is detected
 type keyMismatchAction int
 
@@ -2253,7 +7101,7 @@ func handleKeyMismatch() (keyMismatchAction, error) {
 		},
 	}
 	var choice int
-	if err := survey.AskOne(prompt, &choice); err != nil {
+	if err := askOne(prompt, &choice); err != nil {
 		return actionAbort, err
 	}
 
@@ -2267,22 +7115,29 @@ func handleKeyMismatch() (keyMismatchAction, error) {
 	}
 }
 
-// clearRemoteStorage deletes all files from the remote storage bucket
-func clearRemoteStorage(ctx context.Context, store storage.Storage) error {
+// clearRemoteStorage empties the bucket for a fresh start, moving everything
+// to the remote trash when trash is set. The trash itself is left alone.
+func clearRemoteStorage(ctx context.Context, store storage.Storage, trash bool) error {
 	objects, err := store.List(ctx, "")
 	if err != nil {
 		return fmt.Errorf("failed to list remote files: %w", err)
 	}
 
-	if len(objects) == 0 {
+	// Keep the KDF parameters: the new key was derived with them
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		if obj.Key != sync.KDFParamsKey && !strings.HasPrefix(obj.Key, sync.TrashPrefix) {
+			keys = append(keys, obj.Key)
+		}
+	}
+	if len(keys) == 0 {
 		return nil
 	}
 
-	keys := make([]string, len(objects))
-	for i, obj := range objects {
-		keys[i] = obj.Key
+	if trash {
+		_, err := sync.MoveToTrash(ctx, store, keys, time.Now())
+		return err
 	}
-
 	return store.DeleteBatch(ctx, keys)
 }
 
@@ -2312,11 +7167,26 @@ func handleFirstPullWithExistingFiles(ctx context.Context, syncer *sync.Syncer,
 
 	// If nothing would be affected, proceed normally
 	if len(preview.WouldOverwrite) == 0 && len(preview.WouldDownload) == 0 && len(preview.WouldConflict) == 0 {
+		if jsonOutput {
+			if dryRun {
+				return printJSON(preview)
+			}
+			return printJSON(&sync.SyncResult{})
+		}
 		if !quiet {
 			fmt.Printf("%s✓%s Already up to date\n", colorGreen, colorReset)
 		}
 		return nil
 	}
+	if jsonOutput {
+		if !dryRun {
+			return fmt.Errorf("this first pull would replace existing local files; preview it with --dry-run, then apply it with --plan or --force")
+		}
+		if err := syncer.SavePullPlan(preview); err != nil {
+			return err
+		}
+		return printJSON(preview)
+	}
 
 	// Show warning
 	fmt.Println()
@@ -2325,10 +7195,11 @@ func handleFirstPullWithExistingFiles(ctx context.Context, syncer *sync.Syncer,
 
 	// Show files that would be overwritten
 	for _, f := range preview.WouldOverwrite {
-		localTime := f.LocalTime.Format("2006-01-02")
-		remoteTime := f.RemoteTime.Format("2006-01-02")
+		localTime := formatTime(f.LocalTime)
+		remoteTime := formatTime(f.RemoteTime)
 		fmt.Printf("  %sOVERWRITE%s  %s\n", colorYellow, colorReset, f.Path)
 		fmt.Printf("            %s(local: %s, remote: %s)%s\n", colorDim, localTime, remoteTime, colorReset)
+		printSettingsChanges(f.SettingsChanges, "            ")
 	}
 
 	// Show files that would be downloaded (new)
@@ -2338,7 +7209,7 @@ func handleFirstPullWithExistingFiles(ctx context.Context, syncer *sync.Syncer,
 
 	// Show files that would be kept
 	for _, f := range preview.WouldKeep {
-		fmt.Printf("  %sKEEP%s       %s %s(local newer)%s\n", colorCyan, colorReset, f.Path, colorDim, colorReset)
+		fmt.Printf("  %sKEEP%s       %s %s(%s)%s\n", colorCyan, colorReset, f.Path, colorDim, f.Reason, colorReset)
 	}
 
 	// Show local-only files
@@ -2348,15 +7219,20 @@ func handleFirstPullWithExistingFiles(ctx context.Context, syncer *sync.Syncer,
 
 	// Show conflicts
 	for _, f := range preview.WouldConflict {
-		fmt.Printf("  %sCONFLICT%s   %s %s(both changed)%s\n", colorYellow, colorReset, f.Path, colorDim, colorReset)
+		fmt.Printf("  %sCONFLICT%s   %s %s(%s)%s\n", colorYellow, colorReset, f.Path, colorDim, f.Reason, colorReset)
+		printSettingsChanges(f.SettingsChanges, "            ")
 	}
 
 	fmt.Println()
+	printPreviewBytes(preview)
 
 	// If dry-run, stop here
 	if dryRun {
 		fmt.Printf("%sDry run complete. No changes were made.%s\n", colorDim, colorReset)
-		fmt.Printf("%sRun 'claude-sync pull' to apply changes, or 'claude-sync pull --force' to skip this prompt.%s\n", colorDim, colorReset)
+		if err := syncer.SavePullPlan(preview); err != nil {
+			return err
+		}
+		fmt.Printf("%sRun 'claude-sync pull --plan %s' to apply exactly these changes, or 'claude-sync pull --force' to skip this prompt.%s\n", colorDim, preview.PlanID, colorReset)
 		return nil
 	}
 
@@ -2370,7 +7246,7 @@ func handleFirstPullWithExistingFiles(ctx context.Context, syncer *sync.Syncer,
 		},
 	}
 	var choice int
-	if err := survey.AskOne(prompt, &choice); err != nil {
+	if err := askOne(prompt, &choice); err != nil {
 		return err
 	}
 
@@ -2383,12 +7259,12 @@ func handleFirstPullWithExistingFiles(ctx context.Context, syncer *sync.Syncer,
 		}
 		printSuccess("Backup created: " + backupDir)
 		fmt.Println()
-		return executePull(ctx, syncer)
+		return executePull(ctx, syncer, preview, nil)
 
 	case 1:
 		// Proceed without backup
 		fmt.Println()
-		return executePull(ctx, syncer)
+		return executePull(ctx, syncer, preview, nil)
 
 	default:
 		// Abort
@@ -2399,43 +7275,23 @@ func handleFirstPullWithExistingFiles(ctx context.Context, syncer *sync.Syncer,
 
 // createBackup creates a backup of the current ~/.claude directory
 func createBackup(scope string) (string, error) {
-	claudeDir := config.ClaudeDir()
-	timestamp := time.Now().Format("20060102-150405")
-	backupDir := claudeDir + ".backup." + timestamp
-
-	// Create backup directory
-	if err := os.MkdirAll(backupDir, 0700); err != nil {
-		return "", fmt.Errorf("failed to create backup directory: %w", err)
-	}
-
-	// Copy all syncable files to backup
-	files, err := sync.GetLocalFiles(claudeDir, config.ScopedSyncPaths(scope))
-	if err != nil {
-		return "", fmt.Errorf("failed to list files: %w", err)
-	}
-
-	for relPath := range files {
-		srcPath := filepath.Join(claudeDir, relPath)
-		dstPath := filepath.Join(backupDir, relPath)
-
-		// Ensure destination directory exists
-		dstDir := filepath.Dir(dstPath)
-		if err := os.MkdirAll(dstDir, 0700); err != nil {
-			return "", fmt.Errorf("failed to create directory %s: %w", dstDir, err)
-		}
-
-		// Copy file
-		data, err := os.ReadFile(srcPath)
-		if err != nil {
-			return "", fmt.Errorf("failed to read %s: %w", relPath, err)
-		}
+	return sync.CreateBackup(config.ClaudeDir(), config.ScopedSyncPaths(scope))
+}
 
-		if err := os.WriteFile(dstPath, data, 0600); err != nil {
-			return "", fmt.Errorf("failed to write %s: %w", relPath, err)
+// printSettingsChanges lists the settings a pull would change, local value
+// first.
+func printSettingsChanges(changes []claudesettings.Change, indent string) {
+	const width = 60
+	for _, c := range changes {
+		switch c.Kind {
+		case claudesettings.ChangeAdded:
+			fmt.Printf("%s%s+%s %s: %s\n", indent, colorGreen, colorReset, c.Key, truncateCell(c.To, width))
+		case claudesettings.ChangeRemoved:
+			fmt.Printf("%s%s-%s %s: %s\n", indent, colorYellow, colorReset, c.Key, truncateCell(c.From, width))
+		default:
+			fmt.Printf("%s%s~%s %s: %s → %s\n", indent, colorYellow, colorReset, c.Key, truncateCell(c.From, width), truncateCell(c.To, width))
 		}
 	}
-
-	return backupDir, nil
 }
 
 // showPullPreview shows what would happen during a pull without making changes
@@ -2444,6 +7300,12 @@ func showPullPreview(ctx context.Context, syncer *sync.Syncer) error {
 	if err != nil {
 		return fmt.Errorf("failed to preview pull: %w", err)
 	}
+	if jsonOutput {
+		if err := syncer.SavePullPlan(preview); err != nil {
+			return err
+		}
+		return printJSON(preview)
+	}
 
 	// If nothing would happen
 	total := len(preview.WouldDownload) + len(preview.WouldOverwrite) + len(preview.WouldConflict)
@@ -2471,6 +7333,7 @@ func showPullPreview(ctx context.Context, syncer *sync.Syncer) error {
 			fmt.Printf("  %s~%s %s (local: %s, remote: %s)\n",
 				colorYellow, colorReset, f.Path,
 				util.FormatSize(f.LocalSize), util.FormatSize(f.RemoteSize))
+			printSettingsChanges(f.SettingsChanges, "      ")
 		}
 		fmt.Println()
 	}
@@ -2480,6 +7343,7 @@ func showPullPreview(ctx context.Context, syncer *sync.Syncer) error {
 		fmt.Printf("Would create conflicts (%d files):\n", len(preview.WouldConflict))
 		for _, f := range preview.WouldConflict {
 			fmt.Printf("  %s!%s %s (both local and remote changed)\n", colorYellow, colorReset, f.Path)
+			printSettingsChanges(f.SettingsChanges, "      ")
 		}
 		fmt.Println()
 	}
@@ -2493,6 +7357,15 @@ func showPullPreview(ctx context.Context, syncer *sync.Syncer) error {
 		fmt.Println()
 	}
 
+	// Show commands a higher-ranked command set shadows
+	if len(preview.ShadowedCommands) > 0 {
+		fmt.Printf("Would skip (%d shadowed commands):\n", len(preview.ShadowedCommands))
+		for _, c := range preview.ShadowedCommands {
+			fmt.Printf("  %s=%s %s (shadowed by %s)\n", colorDim, colorReset, c.Path, c.By)
+		}
+		fmt.Println()
+	}
+
 	// Summary
 	fmt.Printf("%sSummary:%s %d would download, %d would overwrite, %d conflicts, %d unchanged\n",
 		colorBold, colorReset,
@@ -2500,15 +7373,91 @@ func showPullPreview(ctx context.Context, syncer *sync.Syncer) error {
 		len(preview.WouldOverwrite),
 		len(preview.WouldConflict),
 		len(preview.WouldKeep))
+	printPreviewBytes(preview)
 	fmt.Println()
-	fmt.Printf("%sRun 'claude-sync pull' to apply these changes.%s\n", colorDim, colorReset)
+
+	if err := syncer.SavePullPlan(preview); err != nil {
+		return err
+	}
+	fmt.Printf("%sRun 'claude-sync pull --plan %s' to apply exactly these changes, or 'claude-sync pull' for whatever has changed by then.%s\n", colorDim, preview.PlanID, colorReset)
 
 	return nil
 }
 
-// executePull performs the actual pull operation with progress output
-func executePull(ctx context.Context, syncer *sync.Syncer) error {
+// printPreviewBytes shows how much a previewed pull would download and how
+// much local data it would replace.
+func printPreviewBytes(preview *sync.PullPreview) {
+	line := fmt.Sprintf("%s to download", util.FormatSize(preview.DownloadBytes))
+	if preview.ReplacedBytes > 0 {
+		line += fmt.Sprintf(", replacing %s of local files", util.FormatSize(preview.ReplacedBytes))
+	}
+	fmt.Printf("%s%s (plan %s)%s\n", colorDim, line, preview.PlanID, colorReset)
+}
+
+// executePull performs the actual pull operation with progress output. With
+// a plan, it pulls exactly what the plan previewed (see sync.PullPlan).
+// runInteractivePull previews the pull and pulls only the files picked from
+// a checklist of it. Everything starts checked; unchecked files are left
+// for the next pull.
+func runInteractivePull(ctx context.Context, syncer *sync.Syncer) error {
+	preview, err := syncer.PreviewPull(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to preview pull: %w", err)
+	}
+	options, paths := pullChoices(preview)
+	if len(options) == 0 {
+		fmt.Printf("%s✓%s Already up to date\n", colorGreen, colorReset)
+		return nil
+	}
+
+	prompt := &survey.MultiSelect{
+		Message:  fmt.Sprintf("Pull which files? (%d incoming)", len(options)),
+		Options:  options,
+		Default:  options,
+		PageSize: 15,
+		Help:     "+ new remote file, ~ overwrites the local copy, ! changed on both sides (saved as .conflict)",
+	}
+	var chosen []int
+	if err := askOne(prompt, &chosen); err != nil {
+		return err
+	}
+	if len(chosen) == 0 {
+		fmt.Println("  Nothing selected; no files pulled.")
+		return nil
+	}
+
+	selected := make([]string, len(chosen))
+	for i, index := range chosen {
+		selected[i] = paths[index]
+	}
+	fmt.Println()
+	return executePull(ctx, syncer, preview, selected)
+}
+
+// pullChoices lists what preview would pull as checklist options, with the
+// path each one stands for.
+func pullChoices(preview *sync.PullPreview) (options, paths []string) {
+	for _, f := range preview.WouldDownload {
+		options = append(options, fmt.Sprintf("+ %s (%s)", f.Path, util.FormatSize(f.RemoteSize)))
+		paths = append(paths, f.Path)
+	}
+	for _, f := range preview.WouldOverwrite {
+		options = append(options, fmt.Sprintf("~ %s (%s, pushed %s)", f.Path, util.FormatSize(f.RemoteSize),
+			util.FormatRelativeTime(f.RemoteTime, time.Now())))
+		paths = append(paths, f.Path)
+	}
+	for _, f := range preview.WouldConflict {
+		options = append(options, fmt.Sprintf("! %s (changed on both sides)", f.Path))
+		paths = append(paths, f.Path)
+	}
+	return options, paths
+}
+
+// executePull pulls and reports the result: everything, or with plan, what
+// it previewed, or with selected as well, only those files of it.
+func executePull(ctx context.Context, syncer *sync.Syncer, plan *sync.PullPreview, selected []string) error {
 	if !quiet {
+		bar := newTransferProgress("↓", colorGreen)
 		syncer.SetProgressFunc(func(event sync.ProgressEvent) {
 			if event.Error != nil {
 				fmt.Printf("\r%s✗%s %s: %v\n", colorYellow, colorReset, event.Path, event.Error)
@@ -2523,16 +7472,8 @@ func executePull(ctx context.Context, syncer *sync.Syncer) error {
 					fmt.Printf("%s⋯%s %s\n", colorDim, colorReset, event.Path)
 				}
 			case "download":
-				if event.Complete {
-					// Final newline after progress
-				} else {
-					progress := fmt.Sprintf("[%d/%d]", event.Current, event.Total)
-					shortPath := util.TruncatePath(event.Path, 50)
-					fmt.Printf("\r%s↓%s %s%s%s %s (%s)%s",
-						colorGreen, colorReset,
-						colorDim, progress, colorReset,
-						shortPath, util.FormatSize(event.Size),
-						strings.Repeat(" ", 10))
+				if !event.Complete {
+					bar.print(event)
 				}
 			case "conflict":
 				fmt.Printf("\r%s⚠%s Conflict: %s (saved as .conflict)\n",
@@ -2541,7 +7482,18 @@ func executePull(ctx context.Context, syncer *sync.Syncer) error {
 		})
 	}
 
-	result, err := syncer.Pull(ctx)
+	var result *sync.SyncResult
+	var err error
+	switch {
+	case plan != nil && selected != nil:
+		result, err = syncer.PullSelected(ctx, plan, selected)
+	case plan != nil:
+		result, err = syncer.PullPlan(ctx, plan)
+	default:
+		result, err = syncer.Pull(ctx)
+	}
+	recordActivity("pull", result, err)
+	recordSyncStatus(syncer, "pull", result, err)
 	if err != nil {
 		return err
 	}
@@ -2565,6 +7517,9 @@ func executePull(ctx context.Context, syncer *sync.Syncer) error {
 			if len(parts) > 0 {
 				fmt.Printf("%s✓%s Pull complete: %s\n", colorGreen, colorReset, strings.Join(parts, ", "))
 			}
+			if result.Backup != "" {
+				fmt.Printf("%sReplaced files backed up to %s%s\n", colorDim, result.Backup, colorReset)
+			}
 
 			if len(result.Conflicts) > 0 {
 				fmt.Printf("\n%sConflicts (both local and remote changed):%s\n", colorYellow, colorReset)
@@ -2582,9 +7537,89 @@ func executePull(ctx context.Context, syncer *sync.Syncer) error {
 				}
 			}
 		}
+		printJSONLIssues(result.InvalidJSONL)
+		printSettingsIssues(result.InvalidSettings)
+		printShadowedCommands(result.ShadowedCommands)
+		if len(result.PlanChanged) > 0 {
+			fmt.Printf("\n%sLeft alone (changed since the preview):%s\n", colorYellow, colorReset)
+			for _, path := range result.PlanChanged {
+				fmt.Printf("  %s•%s %s\n", colorYellow, colorReset, path)
+			}
+			fmt.Printf("\n%sRun 'claude-sync pull' again to pick them up.%s\n", colorDim, colorReset)
+		}
+		if len(result.Skipped) > 0 {
+			fmt.Printf("%s%d file(s) not selected; the next pull offers them again.%s\n", colorDim, len(result.Skipped), colorReset)
+		}
+	}
+	if jsonOutput {
+		if err := printJSON(result); err != nil {
+			return err
+		}
+	}
+	if result.BucketMoved != nil {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if err := followBucketMove(cfg, result.BucketMoved); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return syncStatus(result)
+}
+
+// printShadowedCommands reports remote slash commands pull skipped because
+// a higher-ranked command set defines the same name.
+func printShadowedCommands(shadowed []sync.ShadowedCommand) {
+	if len(shadowed) == 0 {
+		return
+	}
+	fmt.Printf("\n%sSkipped commands (name taken by a higher-ranked command set):%s\n", colorYellow, colorReset)
+	for _, c := range shadowed {
+		fmt.Printf("  %s•%s %s (shadowed by %s)\n", colorYellow, colorReset, c.Path, c.By)
+	}
+	fmt.Printf("\n%sRename one of them, or reorder 'command_namespaces' in the config.%s\n", colorDim, colorReset)
+}
+
+// printSettingsIssues reports pulled settings files that failed validation.
+func printSettingsIssues(issues []sync.SettingsIssue) {
+	if len(issues) == 0 {
+		return
+	}
+	fmt.Printf("\n%sInvalid settings pulled:%s\n", colorYellow, colorReset)
+	for _, issue := range issues {
+		fmt.Printf("  %s•%s %s: %v\n", colorYellow, colorReset, issue.Path, issue.Err)
+		if issue.SetAside != "" {
+			fmt.Printf("    %sKept the local copy; remote content saved to %s%s\n", colorDim, issue.SetAside, colorReset)
+		} else {
+			fmt.Printf("    %sNo valid local copy to keep; written as pulled%s\n", colorDim, colorReset)
+		}
+	}
+}
+
+// printJSONLIssues reports pulled .jsonl files with lines that don't parse.
+func printJSONLIssues(issues []sync.JSONLIssue) {
+	if len(issues) == 0 {
+		return
+	}
+	fmt.Printf("\n%sInvalid JSONL lines:%s\n", colorYellow, colorReset)
+	var unrepaired int
+	for _, issue := range issues {
+		lines := make([]string, 0, len(issue.BadLines))
+		for _, n := range issue.BadLines {
+			lines = append(lines, strconv.Itoa(n))
+		}
+		fmt.Printf("  %s•%s %s (line %s)\n", colorYellow, colorReset, issue.Path, strings.Join(lines, ", "))
+		if issue.Quarantined != "" {
+			fmt.Printf("    %sTrailing lines moved to %s%s\n", colorDim, issue.Quarantined, colorReset)
+		} else {
+			unrepaired++
+		}
+	}
+	if unrepaired > 0 {
+		fmt.Printf("\n%sPull with --repair-jsonl (or set 'repair_jsonl: true') to cut off corrupt trailing lines.%s\n", colorDim, colorReset)
+	}
 }
 
 func changelogCmd() *cobra.Command {
@@ -2601,7 +7636,11 @@ Examples:
 		RunE: func(cmd *cobra.Command, args []string) error {
 			fmt.Printf("%s⋯%s Fetching releases...\n\n", colorDim, colorReset)
 
-			releases, err := getAllReleases(limit)
+			source, err := updateSource()
+			if err != nil {
+				return err
+			}
+			releases, err := source.releases(limit)
 			if err != nil {
 				return fmt.Errorf("failed to fetch changelog: %w", err)
 			}
@@ -2673,35 +7712,6 @@ type GitHubReleaseWithBody struct {
 	} `json:"assets"`
 }
 
-func getAllReleases(limit int) ([]GitHubReleaseWithBody, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/tawanorg/claude-sync/releases?per_page=%d", limit)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-	req.Header.Set("User-Agent", "claude-sync/"+version)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
-	}
-
-	var releases []GitHubReleaseWithBody
-	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
-		return nil, err
-	}
-
-	return releases, nil
-}
-
 func printReleaseBody(body string) {
 	lines := strings.Split(body, "\n")
 	for _, line := range lines {
@@ -2996,10 +8006,15 @@ func runMCPPull(ctx context.Context, syncer *sync.Syncer) error {
 // autoCmd manages auto-sync hooks in Claude Code settings
 func autoCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "auto",
-		Short: "Manage auto-sync hooks for Claude Code",
+		Use:     "auto",
+		Aliases: []string{"hooks"},
+		Short:   "Manage auto-sync hooks for Claude Code",
 		Long: `Install or remove claude-sync hooks that automatically pull on session start
-and push on session end. Hooks are stored in ~/.claude/settings.json.`,
+and push when Claude finishes responding, or when the session ends. Hooks are
+stored in ~/.claude/settings.json.
+
+'claude-sync hooks install' and 'claude-sync hooks uninstall' are the same as
+'claude-sync auto enable' and 'claude-sync auto disable'.`,
 	}
 
 	cmd.AddCommand(
@@ -3013,16 +8028,33 @@ and push on session end. Hooks are stored in ~/.claude/settings.json.`,
 
 func autoEnableCmd() *cobra.Command {
 	var dryRun bool
+	var pushOn string
 
 	cmd := &cobra.Command{
-		Use:   "enable",
-		Short: "Install auto-sync hooks into Claude Code",
+		Use:     "enable",
+		Aliases: []string{"install"},
+		Short:   "Install auto-sync hooks into Claude Code",
 		Long: `Adds hooks to ~/.claude/settings.json:
-  - SessionStart: runs "claude-sync pull -q" when a session begins
-  - Stop: runs "claude-sync push -q" when a session ends
+  - SessionStart: runs "` + claudesettings.HookCommandPull + `" when a session begins
+  - Stop: runs "` + claudesettings.HookCommandPush + `" each time Claude
+    finishes responding (SessionEnd with --push-on session-end)
 
-Existing hooks are preserved. This command is idempotent.`,
+--changed-only makes the push hook return at once, without contacting
+storage, when no local file changed since the last sync.
+
+Existing hooks are preserved. This command is idempotent; claude-sync hooks
+installed by an earlier version are updated to the current commands.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			autoCfg := claudesettings.DefaultAutoSyncConfig()
+			other := claudesettings.EventSessionEnd
+			switch pushOn {
+			case "stop":
+			case "session-end":
+				autoCfg.PushEvent, other = claudesettings.EventSessionEnd, claudesettings.EventStop
+			default:
+				return fmt.Errorf("invalid --push-on %q: must be stop or session-end", pushOn)
+			}
+
 			path := claudesettings.SettingsPath("")
 
 			settings, err := claudesettings.Load(path)
@@ -3030,7 +8062,9 @@ Existing hooks are preserved. This command is idempotent.`,
 				return fmt.Errorf("failed to load settings: %w", err)
 			}
 
-			changed := settings.EnableAutoSync()
+			// Only one of Stop and SessionEnd pushes
+			moved := settings.RemoveAutoSyncHooks(other)
+			changed := settings.EnableAutoSyncWithConfig(autoCfg) || moved
 
 			if !changed {
 				if !quiet {
@@ -3041,8 +8075,7 @@ Existing hooks are preserved. This command is idempotent.`,
 
 			if dryRun {
 				fmt.Printf("%s⋯%s Dry run: would install auto-sync hooks:\n", colorDim, colorReset)
-				fmt.Printf("    SessionStart → %s\n", claudesettings.HookCommandPull)
-				fmt.Printf("    Stop → %s\n", claudesettings.HookCommandPush)
+				printAutoSyncHooks(settings)
 				return nil
 			}
 
@@ -3052,8 +8085,7 @@ Existing hooks are preserved. This command is idempotent.`,
 
 			if !quiet {
 				fmt.Printf("%s✓%s Auto-sync hooks installed:\n", colorGreen, colorReset)
-				fmt.Printf("    SessionStart → %s\n", claudesettings.HookCommandPull)
-				fmt.Printf("    Stop → %s\n", claudesettings.HookCommandPush)
+				printAutoSyncHooks(settings)
 			}
 
 			return nil
@@ -3061,6 +8093,7 @@ Existing hooks are preserved. This command is idempotent.`,
 	}
 
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be changed without modifying files")
+	cmd.Flags().StringVar(&pushOn, "push-on", "stop", "When to push: stop (after each response) or session-end")
 
 	return cmd
 }
@@ -3069,8 +8102,9 @@ func autoDisableCmd() *cobra.Command {
 	var dryRun bool
 
 	cmd := &cobra.Command{
-		Use:   "disable",
-		Short: "Remove auto-sync hooks from Claude Code",
+		Use:     "disable",
+		Aliases: []string{"uninstall"},
+		Short:   "Remove auto-sync hooks from Claude Code",
 		Long: `Removes claude-sync hooks from ~/.claude/settings.json.
 Other hooks are preserved. This command is idempotent.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -3128,12 +8162,7 @@ func autoStatusCmd() *cobra.Command {
 
 			if status.Enabled {
 				fmt.Printf("%s✓%s Auto-sync: %senabled%s\n", colorGreen, colorReset, colorGreen, colorReset)
-				if status.HasSessionStart {
-					fmt.Printf("    SessionStart → %s\n", claudesettings.HookCommandPull)
-				}
-				if status.HasStop {
-					fmt.Printf("    Stop → %s\n", claudesettings.HookCommandPush)
-				}
+				printAutoSyncHooks(settings)
 			} else {
 				fmt.Printf("%s⋯%s Auto-sync: %snot installed%s\n", colorDim, colorReset, colorDim, colorReset)
 				fmt.Printf("    Run '%sclaude-sync auto enable%s' to install hooks\n", colorCyan, colorReset)
@@ -3144,6 +8173,15 @@ func autoStatusCmd() *cobra.Command {
 	}
 }
 
+// printAutoSyncHooks lists the claude-sync hooks in settings by event
+func printAutoSyncHooks(settings *claudesettings.Settings) {
+	for _, event := range []string{claudesettings.EventSessionStart, claudesettings.EventStop, claudesettings.EventSessionEnd} {
+		for _, command := range settings.AutoSyncCommands(event) {
+			fmt.Printf("    %s → %s\n", event, command)
+		}
+	}
+}
+
 // pathsCmd manages sync paths and exclude filters
 func pathsCmd() *cobra.Command {
 	cmd := &cobra.Command{
@@ -3211,6 +8249,24 @@ func runPathsList() error {
 		}
 	}
 
+	if overrides := cfg.HostOverrides(); len(overrides) > 0 {
+		fmt.Printf("\n%sOn This Host%s:\n", colorBold, colorReset)
+		for _, h := range overrides {
+			for _, p := range h.AddPaths {
+				fmt.Printf("  %s+%s %s (%s)\n", colorGreen, colorReset, p, h.Host)
+			}
+			for _, p := range h.RemovePaths {
+				fmt.Printf("  %s-%s %s (not synced, %s)\n", colorYellow, colorReset, p, h.Host)
+			}
+			for _, e := range h.Exclude {
+				fmt.Printf("  %s-%s %s (%s)\n", colorYellow, colorReset, e, h.Host)
+			}
+			for _, e := range h.Unexclude {
+				fmt.Printf("  %s+%s %s (not excluded, %s)\n", colorGreen, colorReset, e, h.Host)
+			}
+		}
+	}
+
 	fmt.Println()
 	return nil
 }
@@ -3286,7 +8342,10 @@ Custom paths are simply removed from the list.`,
 					Message: fmt.Sprintf("Remove %q from sync?", args[0]),
 					Default: false,
 				}
-				if err := survey.AskOne(prompt, &confirm); err != nil || !confirm {
+				if err := askOne(prompt, &confirm); err != nil {
+					return err
+				}
+				if !confirm {
 					fmt.Println("  Cancelled.")
 					return nil
 				}
@@ -3394,7 +8453,10 @@ func pathsResetCmd() *cobra.Command {
 					Message: "Reset all sync paths and filters to defaults?",
 					Default: false,
 				}
-				if err := survey.AskOne(prompt, &confirm); err != nil || !confirm {
+				if err := askOne(prompt, &confirm); err != nil {
+					return err
+				}
+				if !confirm {
 					fmt.Println("  Cancelled.")
 					return nil
 				}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	gosync "sync"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+// daemonMetrics counts the daemon's runs for its /metrics endpoint, in the
+// Prometheus text format. Counters start at zero when the daemon starts.
+type daemonMetrics struct {
+	mu          gosync.Mutex
+	runs        map[[2]string]int64 // op, result ("ok" or "failed")
+	durationSum map[string]float64  // op → seconds
+	lastSuccess map[string]time.Time
+	files       map[string]int64 // uploaded, downloaded, deleted
+	bytes       map[string]int64 // up, down
+	errors      map[string]int64 // op → failed files and failed runs
+	conflicts   int64
+}
+
+func newDaemonMetrics() *daemonMetrics {
+	return &daemonMetrics{
+		runs:        make(map[[2]string]int64),
+		durationSum: make(map[string]float64),
+		lastSuccess: make(map[string]time.Time),
+		files:       make(map[string]int64),
+		bytes:       make(map[string]int64),
+		errors:      make(map[string]int64),
+	}
+}
+
+// record adds one run of op that ended with exit code and took duration.
+// entry is the run's activity log entry, nil when it wrote none (it failed
+// before syncing, or couldn't be read).
+func (m *daemonMetrics) record(op string, code int, duration time.Duration, entry *sync.ActivityEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// A pull that saved conflicts still synced
+	result := "ok"
+	if code != exitOK && code != exitConflicts {
+		result = "failed"
+		if entry == nil || len(entry.Errors) == 0 {
+			m.errors[op]++
+		}
+	} else {
+		m.lastSuccess[op] = time.Now()
+	}
+	m.runs[[2]string{op, result}]++
+	m.durationSum[op] += duration.Seconds()
+
+	if entry == nil {
+		return
+	}
+	m.files["uploaded"] += int64(entry.Uploaded)
+	m.files["downloaded"] += int64(entry.Downloaded)
+	m.files["deleted"] += int64(entry.Deleted)
+	m.bytes["up"] += entry.BytesUp
+	m.bytes["down"] += entry.BytesDown
+	m.errors[op] += int64(len(entry.Errors))
+	m.conflicts += int64(entry.Conflicts)
+}
+
+// write prints the metrics in the Prometheus text exposition format.
+func (m *daemonMetrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ops := []string{"pull", "push"}
+	fmt.Fprintln(w, "# HELP claude_sync_runs_total Sync runs by operation and result.")
+	fmt.Fprintln(w, "# TYPE claude_sync_runs_total counter")
+	for _, op := range ops {
+		for _, result := range []string{"ok", "failed"} {
+			fmt.Fprintf(w, "claude_sync_runs_total{op=%q,result=%q} %d\n", op, result, m.runs[[2]string{op, result}])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP claude_sync_run_duration_seconds Time sync runs took.")
+	fmt.Fprintln(w, "# TYPE claude_sync_run_duration_seconds summary")
+	for _, op := range ops {
+		count := m.runs[[2]string{op, "ok"}] + m.runs[[2]string{op, "failed"}]
+		fmt.Fprintf(w, "claude_sync_run_duration_seconds_sum{op=%q} %g\n", op, m.durationSum[op])
+		fmt.Fprintf(w, "claude_sync_run_duration_seconds_count{op=%q} %d\n", op, count)
+	}
+
+	fmt.Fprintln(w, "# HELP claude_sync_last_success_timestamp_seconds When a run last succeeded, 0 if none has since the daemon started.")
+	fmt.Fprintln(w, "# TYPE claude_sync_last_success_timestamp_seconds gauge")
+	for _, op := range ops {
+		var ts int64
+		if t, ok := m.lastSuccess[op]; ok {
+			ts = t.Unix()
+		}
+		fmt.Fprintf(w, "claude_sync_last_success_timestamp_seconds{op=%q} %d\n", op, ts)
+	}
+
+	fmt.Fprintln(w, "# HELP claude_sync_files_total Files synced, by action.")
+	fmt.Fprintln(w, "# TYPE claude_sync_files_total counter")
+	for _, action := range []string{"uploaded", "downloaded", "deleted"} {
+		fmt.Fprintf(w, "claude_sync_files_total{action=%q} %d\n", action, m.files[action])
+	}
+
+	fmt.Fprintln(w, "# HELP claude_sync_transferred_bytes_total Bytes sent to and received from storage.")
+	fmt.Fprintln(w, "# TYPE claude_sync_transferred_bytes_total counter")
+	for _, direction := range []string{"up", "down"} {
+		fmt.Fprintf(w, "claude_sync_transferred_bytes_total{direction=%q} %d\n", direction, m.bytes[direction])
+	}
+
+	fmt.Fprintln(w, "# HELP claude_sync_errors_total Files that failed to sync, plus runs that failed outright.")
+	fmt.Fprintln(w, "# TYPE claude_sync_errors_total counter")
+	for _, op := range ops {
+		fmt.Fprintf(w, "claude_sync_errors_total{op=%q} %d\n", op, m.errors[op])
+	}
+
+	fmt.Fprintln(w, "# HELP claude_sync_conflicts_total Conflicts pulls saved.")
+	fmt.Fprintln(w, "# TYPE claude_sync_conflicts_total counter")
+	fmt.Fprintf(w, "claude_sync_conflicts_total %d\n", m.conflicts)
+}
+
+func (m *daemonMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// serveMetrics serves m on addr at /metrics until ctx is done.
+func serveMetrics(ctx context.Context, addr string, m *daemonMetrics) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "%s %s✗%s Metrics server stopped: %v\n", time.Now().Format(time.DateTime), colorYellow, colorReset, err)
+		}
+	}()
+	return nil
+}
+
+// runActivity returns the activity log entry of the run of op that began at
+// began, or nil when there is none.
+func runActivity(op string, began time.Time) *sync.ActivityEntry {
+	entries, err := sync.LoadActivity()
+	if err != nil {
+		return nil
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Time.Before(began) {
+			break
+		}
+		if entries[i].Command == op {
+			return &entries[i]
+		}
+	}
+	return nil
+}
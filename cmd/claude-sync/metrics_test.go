@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+func TestDaemonMetrics(t *testing.T) {
+	m := newDaemonMetrics()
+	m.record("pull", exitConflicts, 2*time.Second, &sync.ActivityEntry{Command: "pull", Downloaded: 3, Conflicts: 1, BytesDown: 4096})
+	m.record("push", exitOK, time.Second, &sync.ActivityEntry{Command: "push", Uploaded: 2, BytesUp: 1024})
+	m.record("push", exitPartial, time.Second, &sync.ActivityEntry{Command: "push", Uploaded: 1, Errors: []string{"a", "b"}})
+	m.record("pull", exitError, 500*time.Millisecond, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`claude_sync_runs_total{op="pull",result="ok"} 1`,
+		`claude_sync_runs_total{op="pull",result="failed"} 1`,
+		`claude_sync_runs_total{op="push",result="failed"} 1`,
+		`claude_sync_run_duration_seconds_sum{op="pull"} 2.5`,
+		`claude_sync_run_duration_seconds_count{op="push"} 2`,
+		`claude_sync_files_total{action="uploaded"} 3`,
+		`claude_sync_files_total{action="downloaded"} 3`,
+		`claude_sync_transferred_bytes_total{direction="up"} 1024`,
+		`claude_sync_transferred_bytes_total{direction="down"} 4096`,
+		`claude_sync_errors_total{op="pull"} 1`,
+		`claude_sync_errors_total{op="push"} 2`,
+		`claude_sync_conflicts_total 1`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("Missing %s in:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `claude_sync_last_success_timestamp_seconds{op="push"} 0`) {
+		t.Error("Last push success not recorded")
+	}
+}
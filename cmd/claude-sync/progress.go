@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/sync"
+	"github.com/tawanorg/claude-sync/internal/util"
+)
+
+const progressBarWidth = 20
+
+// transferProgress draws the progress line of a push or pull: how many
+// files have started, a bar of the bytes done overall with the throughput
+// and time left, and the file last started, finished or moving data, with
+// how much of it is done while it moves. Events arrive from
+// several workers at once.
+type transferProgress struct {
+	arrow, color string
+	now          func() time.Time
+
+	mu      gosync.Mutex
+	started time.Time
+}
+
+func newTransferProgress(arrow, color string) *transferProgress {
+	return &transferProgress{arrow: arrow, color: color, now: time.Now}
+}
+
+// print redraws the line for an upload or download event.
+func (p *transferProgress) print(event sync.ProgressEvent) {
+	fmt.Print(p.line(event))
+}
+
+func (p *transferProgress) line(event sync.ProgressEvent) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	if p.started.IsZero() {
+		p.started = now
+	}
+
+	line := fmt.Sprintf("\r%s%s%s %s[%d/%d]%s", p.color, p.arrow, colorReset, colorDim, event.Current, event.Total, colorReset)
+	if event.TotalBytes > 0 {
+		line += fmt.Sprintf(" %s %s/%s", progressBar(event.Bytes, event.TotalBytes),
+			util.FormatSize(event.Bytes), util.FormatSize(event.TotalBytes))
+		if elapsed := now.Sub(p.started); elapsed >= time.Second && event.Bytes > 0 {
+			rate := float64(event.Bytes) / elapsed.Seconds()
+			left := time.Duration(float64(event.TotalBytes-event.Bytes) / rate * float64(time.Second))
+			line += fmt.Sprintf(" %s%s/s, %s left%s", colorDim, util.FormatSize(int64(rate)), left.Round(time.Second), colorReset)
+		}
+	}
+	size := util.FormatSize(event.Size)
+	if event.InFlight {
+		size = util.FormatSize(event.FileBytes) + "/" + size
+	}
+	return line + fmt.Sprintf(" %s (%s)%s", util.TruncatePath(event.Path, 40), size, strings.Repeat(" ", 10))
+}
+
+// progressBar draws done out of total as a fixed-width bar.
+func progressBar(done, total int64) string {
+	filled := progressBarWidth
+	if done < total {
+		filled = int(done * progressBarWidth / total)
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", progressBarWidth-filled)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+func TestTransferProgressLine(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	p := newTransferProgress("↑", colorCyan)
+	p.now = func() time.Time { return now }
+
+	event := sync.ProgressEvent{Action: "upload", Path: "projects/a.jsonl", Size: 1 << 20, Current: 1, Total: 4, TotalBytes: 4 << 20}
+	line := p.line(event)
+	if !strings.Contains(line, "[1/4]") || !strings.Contains(line, "projects/a.jsonl") {
+		t.Errorf("line = %q, want the count and path", line)
+	}
+	if strings.Contains(line, "/s") {
+		t.Errorf("line = %q, shows a rate before any time has passed", line)
+	}
+
+	now = now.Add(2 * time.Second)
+	event.Finished, event.Bytes = true, 2<<20
+	line = p.line(event)
+	if !strings.Contains(line, strings.Repeat("█", 10)+strings.Repeat("░", 10)) {
+		t.Errorf("line = %q, want a half-full bar", line)
+	}
+	if !strings.Contains(line, "1.0 MB/s, 2s left") {
+		t.Errorf("line = %q, want 1.0 MB/s and 2s left", line)
+	}
+
+	// A file still moving shows how much of it is done
+	event.Finished, event.InFlight, event.FileBytes = false, true, 512<<10
+	if line = p.line(event); !strings.Contains(line, "(512.0 KB/1.0 MB)") {
+		t.Errorf("line = %q, want the file's bytes done", line)
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	if got := progressBar(0, 10); got != strings.Repeat("░", progressBarWidth) {
+		t.Errorf("empty bar = %q", got)
+	}
+	if got := progressBar(10, 10); got != strings.Repeat("█", progressBarWidth) {
+		t.Errorf("full bar = %q", got)
+	}
+}
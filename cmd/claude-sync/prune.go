@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/sync"
+	"github.com/tawanorg/claude-sync/internal/util"
+)
+
+func pruneCmd() *cobra.Command {
+	var backups, conflicts, state, force bool
+	var olderThan string
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Clean up old backups, .conflict files and stale sync state",
+		Long: `Delete local leftovers of past syncs:
+
+  --backups     ~/.claude.backup.* directories, manual and automatic
+  --conflicts   saved .conflict files; the local version stays as it is
+  --state       sync state entries for files this device no longer syncs
+                (outside the scope or excluded) and records of .conflict
+                files that are gone
+
+Without any of them, all three are pruned. Backups and conflict files newer
+than --older-than (30 days by default) are kept. Nothing in storage is
+touched; 'claude-sync gc' cleans up the remote.
+
+Without --force, prune only lists what it would delete. A pruned state entry
+for a file outside the scope no longer makes the next push delete the
+file's remote copy.
+
+Examples:
+  claude-sync prune                           # What would be deleted
+  claude-sync prune --force
+  claude-sync prune --backups --older-than 7d --force`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			age, err := config.ParseAge(olderThan)
+			if err != nil {
+				return fmt.Errorf("invalid --older-than: %w", err)
+			}
+			if !backups && !conflicts && !state {
+				backups, conflicts, state = true, true, true
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			syncState, err := sync.LoadState()
+			if err != nil {
+				return fmt.Errorf("failed to load sync state: %w", err)
+			}
+
+			result, err := sync.Prune(cfg, syncState, config.ClaudeDir(), sync.PruneOptions{
+				Backups:   backups,
+				Conflicts: conflicts,
+				State:     state,
+				OlderThan: age,
+				DryRun:    !force,
+			})
+			if err != nil {
+				return err
+			}
+			if result.Empty() {
+				fmt.Printf("%s✓%s Nothing to prune\n", colorGreen, colorReset)
+				return nil
+			}
+
+			for _, b := range result.Backups {
+				kind := "manual"
+				if b.Automatic {
+					kind = "automatic"
+				}
+				fmt.Printf("  %s-%s %s %s(%s backup, %s)%s\n", colorYellow, colorReset, b.Name,
+					colorDim, kind, formatTime(b.CreatedAt), colorReset)
+			}
+			for _, c := range result.Conflicts {
+				fmt.Printf("  %s-%s %s %s(%s)%s\n", colorYellow, colorReset, c.ConflictPath,
+					colorDim, formatTime(c.DetectedAt), colorReset)
+			}
+			for _, entry := range result.State {
+				fmt.Printf("  %s-%s %s %s(state entry)%s\n", colorYellow, colorReset, entry, colorDim, colorReset)
+			}
+			fmt.Println()
+
+			verb := "Deleted"
+			if !force {
+				verb = "Would delete"
+			}
+			fmt.Printf("%s✓%s %s %s, freeing %s\n", colorGreen, colorReset, verb, pruneSummary(result),
+				util.FormatSize(result.Size))
+			if !force {
+				fmt.Printf("%sRun with --force to delete them.%s\n", colorDim, colorReset)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&backups, "backups", false, "Prune backups of ~/.claude")
+	cmd.Flags().BoolVar(&conflicts, "conflicts", false, "Prune saved .conflict files")
+	cmd.Flags().BoolVar(&state, "state", false, "Prune orphaned sync state entries")
+	cmd.Flags().StringVar(&olderThan, "older-than", "30d", "Keep backups and conflict files newer than this (e.g. 7d, 12h)")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Delete them; without it prune only lists what it would delete")
+
+	return cmd
+}
+
+// pruneSummary counts what result holds, e.g. "2 backup(s) and 1 state
+// entry", leaving out the kinds it has none of.
+func pruneSummary(result *sync.PruneResult) string {
+	var parts []string
+	if n := len(result.Backups); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d backup(s)", n))
+	}
+	if n := len(result.Conflicts); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d conflict file(s)", n))
+	}
+	if n := len(result.State); n == 1 {
+		parts = append(parts, "1 state entry")
+	} else if n > 1 {
+		parts = append(parts, fmt.Sprintf("%d state entries", n))
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+func TestPruneSummary(t *testing.T) {
+	for _, tt := range []struct {
+		result *sync.PruneResult
+		want   string
+	}{
+		{&sync.PruneResult{Backups: make([]sync.Backup, 2)}, "2 backup(s)"},
+		{&sync.PruneResult{Conflicts: make([]sync.Conflict, 1), State: []string{"a"}}, "1 conflict file(s) and 1 state entry"},
+		{&sync.PruneResult{Backups: make([]sync.Backup, 1), Conflicts: make([]sync.Conflict, 3), State: []string{"a", "b"}},
+			"1 backup(s), 3 conflict file(s) and 2 state entries"},
+	} {
+		if got := pruneSummary(tt.result); got != tt.want {
+			t.Errorf("pruneSummary = %q, want %q", got, tt.want)
+		}
+	}
+}
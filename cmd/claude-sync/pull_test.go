@@ -0,0 +1,31 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+func TestPullChoices(t *testing.T) {
+	preview := &sync.PullPreview{
+		WouldDownload:  []sync.FilePreview{{Path: "agents/new.md", RemoteSize: 2048}},
+		WouldOverwrite: []sync.FilePreview{{Path: "CLAUDE.md", RemoteTime: time.Now().Add(-time.Hour)}},
+		WouldConflict:  []sync.FilePreview{{Path: "settings.json"}},
+		WouldKeep:      []sync.FilePreview{{Path: "rules/a.md"}},
+	}
+	options, paths := pullChoices(preview)
+	if want := []string{"agents/new.md", "CLAUDE.md", "settings.json"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+	if len(options) != 3 || !strings.HasPrefix(options[0], "+ agents/new.md (2.0 KB") ||
+		!strings.HasPrefix(options[1], "~ CLAUDE.md") || !strings.HasPrefix(options[2], "! settings.json") {
+		t.Errorf("options = %q", options)
+	}
+
+	if options, _ := pullChoices(&sync.PullPreview{}); len(options) != 0 {
+		t.Errorf("options for an empty preview = %q", options)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+func TestPushChoices(t *testing.T) {
+	changes := []sync.FileChange{
+		{Path: "agents/new.md", Action: "add", LocalSize: 2048},
+		{Path: "CLAUDE.md", Action: "modify", LocalSize: 10, LocalTime: time.Now().Add(-time.Hour)},
+		{Path: "rules/old.md", Action: "delete"},
+	}
+	options := pushChoices(changes)
+	if len(options) != 3 || options[0] != "+ agents/new.md (2.0 KB)" ||
+		!strings.HasPrefix(options[1], "~ CLAUDE.md (10 B, modified ") || options[2] != "- rules/old.md (deleted)" {
+		t.Errorf("options = %q", options)
+	}
+}
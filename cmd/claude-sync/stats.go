@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/storage"
+	"github.com/tawanorg/claude-sync/internal/sync"
+	"github.com/tawanorg/claude-sync/internal/util"
+)
+
+// freeTier is a provider's free storage allowance, as the README's Cost
+// table lists it.
+type freeTier struct {
+	Provider string `json:"provider"`
+	Limit    int64  `json:"limit"`
+	Used     int64  `json:"used"`
+	Left     int64  `json:"left"`
+	Note     string `json:"note,omitempty"`
+}
+
+// freeTierFor returns the free allowance of the storage cfg describes with
+// used bytes stored, or nil when the provider has none we know of (WebDAV,
+// S3-compatible services other than AWS).
+func freeTierFor(cfg *storage.StorageConfig, used int64) *freeTier {
+	const gb = 1 << 30
+	var tier freeTier
+	switch {
+	case cfg.Provider == storage.ProviderR2:
+		tier = freeTier{Provider: "Cloudflare R2", Limit: 10 * gb}
+	case cfg.Provider == storage.ProviderS3 && cfg.Endpoint == "":
+		tier = freeTier{Provider: "AWS S3", Limit: 5 * gb, Note: "first 12 months only"}
+	case cfg.Provider == storage.ProviderGCS:
+		tier = freeTier{Provider: "Google Cloud Storage", Limit: 5 * gb, Note: "us-west1, us-central1 and us-east1 only"}
+	default:
+		return nil
+	}
+	tier.Used = used
+	tier.Left = max(tier.Limit-used, 0)
+	return &tier
+}
+
+// statsJSON is 'claude-sync stats --json'.
+type statsJSON struct {
+	*sync.RemoteStats
+
+	// FreeTier is set when the provider has a known free allowance
+	FreeTier *freeTier `json:"free_tier,omitempty"`
+}
+
+func statsCmd() *cobra.Command {
+	var top int
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show how much remote storage is used",
+		Long: `Show what the bucket holds: its total size and object count, a
+breakdown by sync path, the largest files, when each device last pushed
+and pulled, and how much of the provider's free tier is left.
+
+Sizes are of the encrypted, compressed objects, which is what the provider
+bills. A device's last push is that of the newest file it pushed that is
+still current; pulls leave nothing in the bucket, so only this device's
+last pull is known.
+
+Examples:
+  claude-sync stats
+  claude-sync stats --top 25
+  claude-sync stats --json | jq .free_tier.left`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if top < 0 {
+				return fmt.Errorf("--top must be 0 or more")
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+
+			syncer, err := sync.NewSyncer(cfg, quiet)
+			if err != nil {
+				return err
+			}
+
+			stats, err := syncer.Stats(context.Background(), top)
+			if err != nil {
+				return err
+			}
+			tier := freeTierFor(cfg.GetStorageConfig(), stats.Size)
+			if jsonOutput {
+				stats.Areas = emptyIfNil(stats.Areas)
+				stats.Largest = emptyIfNil(stats.Largest)
+				stats.Devices = emptyIfNil(stats.Devices)
+				return printJSON(statsJSON{RemoteStats: stats, FreeTier: tier})
+			}
+
+			printStats(stats, tier)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVar(&top, "top", 10, "Number of largest files to list")
+	return cmd
+}
+
+func printStats(stats *sync.RemoteStats, tier *freeTier) {
+	fmt.Printf("%sRemote storage:%s %s in %d object(s) (encrypted)\n", colorBold, colorReset, util.FormatSize(stats.Size), stats.Objects)
+
+	if len(stats.Areas) > 0 || stats.Internal.Objects > 0 || stats.Unknown.Objects > 0 {
+		fmt.Println("\nBy path:")
+		for _, a := range stats.Areas {
+			fmt.Printf("  %-22s %9s  %d file(s)\n", a.Path, util.FormatSize(a.Size), a.Objects)
+		}
+		if stats.Internal.Objects > 0 {
+			fmt.Printf("  %s%-22s %9s  %d object(s)%s\n", colorDim, "claude-sync data", util.FormatSize(stats.Internal.Size), stats.Internal.Objects, colorReset)
+		}
+		if stats.Unknown.Objects > 0 {
+			fmt.Printf("  %s%-22s %9s  %d object(s)%s\n", colorDim, "unmapped keys", util.FormatSize(stats.Unknown.Size), stats.Unknown.Objects, colorReset)
+		}
+	}
+
+	if len(stats.Largest) > 0 {
+		fmt.Println("\nLargest files:")
+		for _, e := range stats.Largest {
+			fmt.Printf("  %9s  %s\n", util.FormatSize(e.Size), e.Path)
+		}
+	}
+
+	if len(stats.Devices) > 0 {
+		fmt.Println("\nDevices:")
+		for _, d := range stats.Devices {
+			name := d.Device
+			if d.Current {
+				name += " (this device)"
+			}
+			fmt.Printf("  %s%s%s\n", colorCyan, name, colorReset)
+			if !d.LastPush.IsZero() {
+				fmt.Printf("    Last push: %s\n", formatTime(d.LastPush))
+			}
+			if !d.LastPull.IsZero() {
+				fmt.Printf("    Last pull: %s\n", formatTime(d.LastPull))
+			}
+		}
+	}
+
+	fmt.Println()
+	if tier == nil {
+		fmt.Printf("%sNo known free tier for this provider.%s\n", colorDim, colorReset)
+		return
+	}
+	note := ""
+	if tier.Note != "" {
+		note = ", " + tier.Note
+	}
+	fmt.Printf("Free tier (%s, %s%s): %s left, %.1f%% used\n", tier.Provider, util.FormatSize(tier.Limit), note,
+		util.FormatSize(tier.Left), float64(tier.Used)*100/float64(tier.Limit))
+	if tier.Left == 0 {
+		printWarning("Storage is over the free tier; the provider bills the rest.")
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+func TestFreeTierFor(t *testing.T) {
+	tier := freeTierFor(&storage.StorageConfig{Provider: storage.ProviderR2}, 1<<30)
+	if tier == nil || tier.Limit != 10<<30 || tier.Left != 9<<30 {
+		t.Errorf("R2 = %+v, want 10 GB with 9 GB left", tier)
+	}
+	if tier := freeTierFor(&storage.StorageConfig{Provider: storage.ProviderGCS}, 6<<30); tier == nil || tier.Left != 0 {
+		t.Errorf("GCS over the limit = %+v, want 0 left", tier)
+	}
+	if tier := freeTierFor(&storage.StorageConfig{Provider: storage.ProviderS3}, 0); tier == nil || tier.Limit != 5<<30 {
+		t.Errorf("AWS S3 = %+v, want 5 GB", tier)
+	}
+	if tier := freeTierFor(&storage.StorageConfig{Provider: storage.ProviderS3, Endpoint: "https://s3.us-west-002.backblazeb2.com"}, 0); tier != nil {
+		t.Errorf("S3-compatible = %+v, want no known tier", tier)
+	}
+	if tier := freeTierFor(&storage.StorageConfig{Provider: storage.ProviderWebDAV}, 0); tier != nil {
+		t.Errorf("WebDAV = %+v, want no known tier", tier)
+	}
+}
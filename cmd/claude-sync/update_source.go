@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/util"
+)
+
+const (
+	defaultGitHubAPI  = "https://api.github.com"
+	defaultRepository = "tawanorg/claude-sync"
+)
+
+// releaseSource is where update and changelog find releases: the public
+// GitHub releases unless the config's update section says otherwise.
+type releaseSource struct {
+	kind      string // config.UpdateSourceGitHub or config.UpdateSourceHTTPS
+	apiURL    string // GitHub API base URL
+	repo      string
+	baseURL   string
+	publicKey string // Signs checksums.txt; required for custom sources
+}
+
+// updateSource returns the configured release source. update works before
+// init, so a missing config means the public releases.
+func updateSource() (*releaseSource, error) {
+	var uc config.UpdateConfig
+	if cfg, err := config.Load(); err == nil && cfg.Update != nil {
+		uc = *cfg.Update
+	}
+	return newReleaseSource(uc)
+}
+
+func newReleaseSource(uc config.UpdateConfig) (*releaseSource, error) {
+	src := &releaseSource{
+		kind:      uc.Source,
+		apiURL:    strings.TrimSuffix(uc.GitHubAPI, "/"),
+		repo:      uc.Repository,
+		baseURL:   strings.TrimSuffix(uc.BaseURL, "/"),
+		publicKey: uc.PublicKey,
+	}
+	switch src.kind {
+	case "", config.UpdateSourceGitHub:
+		src.kind = config.UpdateSourceGitHub
+		if src.apiURL == "" {
+			src.apiURL = defaultGitHubAPI
+		}
+		if src.repo == "" {
+			src.repo = defaultRepository
+		}
+		if strings.Count(src.repo, "/") != 1 {
+			return nil, fmt.Errorf("update.repository must be owner/name, not %q", src.repo)
+		}
+	case config.UpdateSourceHTTPS:
+		if src.baseURL == "" {
+			return nil, fmt.Errorf("update.base_url is required for an https update source")
+		}
+		if err := checkHTTPS(src.baseURL); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown update source %q (use %q or %q)", src.kind, config.UpdateSourceGitHub, config.UpdateSourceHTTPS)
+	}
+
+	if src.publicKey == "" && !src.official() {
+		return nil, fmt.Errorf("update.public_key is required to update from %s, so releases can be verified", src.describe())
+	}
+	if src.publicKey != "" {
+		if key, err := base64.StdEncoding.DecodeString(src.publicKey); err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("update.public_key must be a base64 Ed25519 public key")
+		}
+	}
+	return src, nil
+}
+
+// checkHTTPS refuses plain HTTP except to this machine.
+func checkHTTPS(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid update.base_url: %w", err)
+	}
+	switch {
+	case u.Scheme == "https":
+		return nil
+	case u.Scheme == "http" && (u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1" || u.Hostname() == "::1"):
+		return nil
+	}
+	return fmt.Errorf("update.base_url must be an https:// URL")
+}
+
+// official reports whether src is the public GitHub releases.
+func (src *releaseSource) official() bool {
+	return src.kind == config.UpdateSourceGitHub && src.apiURL == defaultGitHubAPI && src.repo == defaultRepository
+}
+
+func (src *releaseSource) describe() string {
+	if src.kind == config.UpdateSourceHTTPS {
+		return src.baseURL
+	}
+	if src.apiURL == defaultGitHubAPI {
+		return "github.com/" + src.repo
+	}
+	return src.repo + " on " + src.apiURL
+}
+
+// latestRelease returns the newest release. An https source's release
+// lists the files every release has; they are only fetched when used.
+func (src *releaseSource) latestRelease() (*GitHubRelease, error) {
+	if src.kind == config.UpdateSourceGitHub {
+		var release GitHubRelease
+		if err := src.githubGet("/releases/latest", &release); err != nil {
+			return nil, err
+		}
+		return &release, nil
+	}
+
+	body, err := downloadBinary(src.baseURL + "/latest")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s/latest: %w", src.baseURL, err)
+	}
+	latest := strings.TrimPrefix(strings.TrimSpace(string(body)), "v")
+	if latest == "" || strings.ContainsAny(latest, "/ \t\n") {
+		return nil, fmt.Errorf("%s/latest doesn't hold a version", src.baseURL)
+	}
+
+	release := &GitHubRelease{TagName: "v" + latest, Name: "v" + latest}
+	for _, name := range []string{util.GetBinaryName(latest), checksumsAsset, checksumsSigAsset} {
+		release.Assets = append(release.Assets, struct {
+			Name               string `json:"name"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+		}{name, src.baseURL + "/v" + latest + "/" + name})
+	}
+	return release, nil
+}
+
+// releases returns up to limit releases with their notes, newest first.
+func (src *releaseSource) releases(limit int) ([]GitHubReleaseWithBody, error) {
+	if src.kind != config.UpdateSourceGitHub {
+		return nil, fmt.Errorf("release notes aren't available from %s", src.describe())
+	}
+	var releases []GitHubReleaseWithBody
+	if err := src.githubGet(fmt.Sprintf("/releases?per_page=%d", limit), &releases); err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+func (src *releaseSource) githubGet(path string, out interface{}) error {
+	req, err := http.NewRequest("GET", src.apiURL+"/repos/"+src.repo+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("User-Agent", "claude-sync/"+version)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub API returned status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// verify checks a downloaded binary against the release's checksums. With
+// a public key, checksums.txt must also carry its signature, and nothing
+// unsigned is installed.
+func (src *releaseSource) verify(release *GitHubRelease, assetName string, data []byte) error {
+	if src.publicKey == "" {
+		return verifyChecksum(release, assetName, data)
+	}
+
+	checksumsURL := releaseAssetURL(release, checksumsAsset)
+	sigURL := releaseAssetURL(release, checksumsSigAsset)
+	if checksumsURL == "" || sigURL == "" {
+		return fmt.Errorf("release has no signed %s", checksumsAsset)
+	}
+	checksums, err := downloadBinary(checksumsURL)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", checksumsAsset, err)
+	}
+	sig, err := downloadBinary(sigURL)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", checksumsSigAsset, err)
+	}
+	if !crypto.VerifySignature(src.publicKey, checksums, decodeSignature(sig)) {
+		return fmt.Errorf("%s isn't signed by update.public_key", checksumsAsset)
+	}
+	fmt.Printf("%s✓%s Signature verified\n", colorGreen, colorReset)
+	return matchChecksum(checksums, assetName, data)
+}
+
+// decodeSignature accepts a raw signature or a base64-encoded one.
+func decodeSignature(sig []byte) []byte {
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig))); err == nil {
+		return decoded
+	}
+	return sig
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/util"
 )
 
 func TestVerifyChecksum(t *testing.T) {
@@ -179,3 +187,183 @@ func containsString(s, substr string) bool {
 	}
 	return false
 }
+
+func TestReplaceBinary(t *testing.T) {
+	execPath := filepath.Join(t.TempDir(), "claude-sync")
+	if err := os.WriteFile(execPath, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(orig func(string) (string, error)) { runVersion = orig }(runVersion)
+
+	// A binary that doesn't run is rolled back
+	runVersion = func(path string) (string, error) { return "", fmt.Errorf("exec format error") }
+	if err := replaceBinary(execPath, []byte("broken"), "2.0.0"); err == nil {
+		t.Fatal("Expected a binary that doesn't run refused")
+	}
+	if data, _ := os.ReadFile(execPath); string(data) != "old" {
+		t.Errorf("Expected the old binary restored, got %q", data)
+	}
+
+	// So is one reporting another version
+	runVersion = func(path string) (string, error) { return "claude-sync version 1.0.0\n", nil }
+	if err := replaceBinary(execPath, []byte("wrong"), "2.0.0"); err == nil {
+		t.Fatal("Expected a binary with the wrong version refused")
+	}
+
+	runVersion = func(path string) (string, error) { return "claude-sync version 2.0.0\n", nil }
+	if err := replaceBinary(execPath, []byte("new"), "2.0.0"); err != nil {
+		t.Fatalf("replaceBinary failed: %v", err)
+	}
+	if data, _ := os.ReadFile(execPath); string(data) != "new" {
+		t.Errorf("Expected the new binary installed, got %q", data)
+	}
+	for _, leftover := range []string{execPath + ".new", execPath + ".old"} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("Expected %s removed", leftover)
+		}
+	}
+}
+
+func TestUpdateLock(t *testing.T) {
+	execPath := filepath.Join(t.TempDir(), "claude-sync")
+	for _, leftover := range []string{execPath + ".new", execPath + ".old"} {
+		if err := os.WriteFile(leftover, []byte("x"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	unlock, err := acquireUpdateLock(execPath)
+	if err != nil {
+		t.Fatalf("acquireUpdateLock failed: %v", err)
+	}
+	if _, err := acquireUpdateLock(execPath); err == nil {
+		t.Error("Expected a second update refused")
+	}
+	cleanupUpdateLeftovers(execPath)
+	if _, err := os.Stat(execPath + ".old"); err != nil {
+		t.Error("Expected leftovers kept while an update runs")
+	}
+
+	unlock()
+	cleanupUpdateLeftovers(execPath)
+	for _, leftover := range []string{execPath + ".new", execPath + ".old"} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("Expected %s removed", leftover)
+		}
+	}
+
+	// A lock left by a crashed update is taken over
+	if err := os.WriteFile(execPath+".lock", []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-2 * updateLockStale)
+	if err := os.Chtimes(execPath+".lock", stale, stale); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err = acquireUpdateLock(execPath)
+	if err != nil {
+		t.Fatalf("Expected a stale lock taken over, got %v", err)
+	}
+	unlock()
+}
+
+func TestNewReleaseSource(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(nil)
+	key := base64.StdEncoding.EncodeToString(pub)
+
+	tests := []struct {
+		name        string
+		cfg         config.UpdateConfig
+		errContains string
+		official    bool
+	}{
+		{name: "default", cfg: config.UpdateConfig{}, official: true},
+		{name: "enterprise needs a key", cfg: config.UpdateConfig{GitHubAPI: "https://github.example.com/api/v3"}, errContains: "public_key is required"},
+		{name: "enterprise", cfg: config.UpdateConfig{GitHubAPI: "https://github.example.com/api/v3", Repository: "tools/claude-sync", PublicKey: key}},
+		{name: "fork needs a key", cfg: config.UpdateConfig{Repository: "someone/claude-sync"}, errContains: "public_key is required"},
+		{name: "bad repository", cfg: config.UpdateConfig{Repository: "claude-sync", PublicKey: key}, errContains: "owner/name"},
+		{name: "https", cfg: config.UpdateConfig{Source: "https", BaseURL: "https://mirror.example.com/claude-sync/", PublicKey: key}},
+		{name: "https needs base_url", cfg: config.UpdateConfig{Source: "https", PublicKey: key}, errContains: "base_url is required"},
+		{name: "plain http", cfg: config.UpdateConfig{Source: "https", BaseURL: "http://mirror.example.com", PublicKey: key}, errContains: "https:// URL"},
+		{name: "bad key", cfg: config.UpdateConfig{Source: "https", BaseURL: "https://mirror.example.com", PublicKey: "not-a-key"}, errContains: "Ed25519"},
+		{name: "unknown source", cfg: config.UpdateConfig{Source: "ftp"}, errContains: "unknown update source"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := newReleaseSource(tt.cfg)
+			if tt.errContains != "" {
+				if err == nil || !containsString(err.Error(), tt.errContains) {
+					t.Fatalf("newReleaseSource() error = %v, want %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newReleaseSource() error = %v", err)
+			}
+			if src.official() != tt.official {
+				t.Errorf("official() = %v, want %v", src.official(), tt.official)
+			}
+		})
+	}
+}
+
+func TestHTTPSReleaseSource(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	binary := []byte("new binary")
+	assetName := util.GetBinaryName("2.0.0")
+	sum := sha256.Sum256(binary)
+	checksums := []byte(hex.EncodeToString(sum[:]) + "  " + assetName + "\n")
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, checksums))
+
+	files := map[string]string{
+		"/latest":                   "v2.0.0\n",
+		"/v2.0.0/" + assetName:      string(binary),
+		"/v2.0.0/checksums.txt":     string(checksums),
+		"/v2.0.0/checksums.txt.sig": sig,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	src, err := newReleaseSource(config.UpdateConfig{Source: "https", BaseURL: server.URL, PublicKey: base64.StdEncoding.EncodeToString(pub)})
+	if err != nil {
+		t.Fatalf("newReleaseSource() error = %v", err)
+	}
+	release, err := src.latestRelease()
+	if err != nil {
+		t.Fatalf("latestRelease() error = %v", err)
+	}
+	if release.TagName != "v2.0.0" {
+		t.Errorf("TagName = %q, want v2.0.0", release.TagName)
+	}
+	if got := releaseAssetURL(release, assetName); got != server.URL+"/v2.0.0/"+assetName {
+		t.Errorf("asset URL = %q", got)
+	}
+
+	if err := src.verify(release, assetName, binary); err != nil {
+		t.Errorf("verify() error = %v", err)
+	}
+	if err := src.verify(release, assetName, []byte("tampered")); err == nil || !containsString(err.Error(), "mismatch") {
+		t.Errorf("verify() of a tampered binary = %v, want a mismatch", err)
+	}
+
+	// checksums.txt rewritten to match a tampered binary no longer verifies
+	tampered := sha256.Sum256([]byte("tampered"))
+	files["/v2.0.0/checksums.txt"] = hex.EncodeToString(tampered[:]) + "  " + assetName + "\n"
+	if err := src.verify(release, assetName, []byte("tampered")); err == nil || !containsString(err.Error(), "isn't signed") {
+		t.Errorf("verify() with re-written checksums = %v, want a signature error", err)
+	}
+
+	// Nor does a release without a signature
+	delete(files, "/v2.0.0/checksums.txt.sig")
+	files["/v2.0.0/checksums.txt"] = string(checksums)
+	if err := src.verify(release, assetName, binary); err == nil {
+		t.Error("verify() of an unsigned release succeeded")
+	}
+}
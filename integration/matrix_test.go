@@ -0,0 +1,347 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/storage"
+	"github.com/tawanorg/claude-sync/internal/sync"
+
+	// Register storage adapters
+	_ "github.com/tawanorg/claude-sync/internal/storage/gcs"
+	_ "github.com/tawanorg/claude-sync/internal/storage/s3"
+	_ "github.com/tawanorg/claude-sync/internal/storage/webdav"
+)
+
+// paginationObjects is enough objects to need more than one List page on
+// every provider (S3 and MinIO return 1000 per page) and more than one
+// DeleteObjects call.
+const paginationObjects = 1050
+
+type matrixBackend struct {
+	name string
+	cfg  *storage.StorageConfig
+}
+
+// matrixBackends returns the backends configured in the environment. The
+// provider matrix in docker-compose.matrix.yml sets all of them; outside it,
+// any one can be pointed at a local server.
+func matrixBackends() []matrixBackend {
+	bucket := getEnvOrDefault("CLAUDE_SYNC_MATRIX_BUCKET", "claude-sync-test")
+	var backends []matrixBackend
+
+	if endpoint := os.Getenv("CLAUDE_SYNC_S3_ENDPOINT"); endpoint != "" {
+		backends = append(backends, matrixBackend{name: "s3", cfg: &storage.StorageConfig{
+			Provider:        storage.ProviderS3,
+			Bucket:          bucket,
+			Endpoint:        endpoint,
+			Region:          getEnvOrDefault("CLAUDE_SYNC_S3_REGION", "us-east-1"),
+			UsePathStyle:    true,
+			AccessKeyID:     os.Getenv("CLAUDE_SYNC_S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("CLAUDE_SYNC_S3_SECRET_ACCESS_KEY"),
+		}})
+	}
+	// The GCS client talks to STORAGE_EMULATOR_HOST without authentication
+	if os.Getenv("STORAGE_EMULATOR_HOST") != "" {
+		backends = append(backends, matrixBackend{name: "gcs", cfg: &storage.StorageConfig{
+			Provider:  storage.ProviderGCS,
+			Bucket:    bucket,
+			ProjectID: "claude-sync-test",
+		}})
+	}
+	if url := os.Getenv("CLAUDE_SYNC_WEBDAV_URL"); url != "" {
+		backends = append(backends, matrixBackend{name: "webdav", cfg: &storage.StorageConfig{
+			Provider:       storage.ProviderWebDAV,
+			WebDAVURL:      url,
+			WebDAVUsername: os.Getenv("CLAUDE_SYNC_WEBDAV_USERNAME"),
+			WebDAVPassword: os.Getenv("CLAUDE_SYNC_WEBDAV_PASSWORD"),
+			PathPrefix:     bucket,
+		}})
+	}
+	return backends
+}
+
+// TestProviderMatrix runs the storage contract and the cross-device
+// scenarios against every configured backend.
+func TestProviderMatrix(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+	backends := matrixBackends()
+	if len(backends) == 0 {
+		t.Skip("no matrix backends configured - see integration/README.md")
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			store, err := storage.New(backend.cfg)
+			if err != nil {
+				t.Fatalf("failed to create %s storage: %v", backend.name, err)
+			}
+			clearStore(t, store)
+			t.Cleanup(func() { clearStore(t, store) })
+
+			t.Run("Contract", func(t *testing.T) { testStorageContract(t, store) })
+			t.Run("Pagination", func(t *testing.T) { testPagination(t, store) })
+			t.Run("CrossDevice", func(t *testing.T) { testMatrixCrossDevice(t, backend.cfg) })
+			clearStore(t, store)
+			t.Run("Conflict", func(t *testing.T) { testMatrixConflict(t, backend.cfg) })
+		})
+	}
+}
+
+func testStorageContract(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	before := time.Now()
+
+	if err := store.Upload(ctx, "contract/a.age", []byte("alpha")); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if err := store.Upload(ctx, "contract/nested/b.age", []byte("bravo!")); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if err := store.Upload(ctx, "contractual.age", []byte("x")); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	data, err := store.Download(ctx, "contract/a.age")
+	if err != nil || string(data) != "alpha" {
+		t.Fatalf("download = %q, %v; want alpha", data, err)
+	}
+
+	info, err := store.Head(ctx, "contract/nested/b.age")
+	if err != nil {
+		t.Fatalf("head failed: %v", err)
+	}
+	if info.Size != 6 {
+		t.Errorf("head size = %d, want 6", info.Size)
+	}
+	// Sync decisions compare LastModified with local clocks: it must be in
+	// UTC-comparable form and close to now
+	if skew := info.LastModified.Sub(before); skew < -2*time.Minute || skew > 2*time.Minute {
+		t.Errorf("head LastModified %v is %v away from the upload time", info.LastModified, skew)
+	}
+
+	objects, err := store.List(ctx, "contract/")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	keys := make(map[string]int64)
+	for _, obj := range objects {
+		keys[obj.Key] = obj.Size
+	}
+	if len(keys) != 2 || keys["contract/a.age"] != 5 || keys["contract/nested/b.age"] != 6 {
+		t.Errorf("list contract/ = %v, want a.age (5) and nested/b.age (6)", keys)
+	}
+
+	heads, err := store.HeadBatch(ctx, []string{"contract/a.age", "contract/missing.age"})
+	if err != nil {
+		t.Fatalf("head batch failed: %v", err)
+	}
+	if heads["contract/a.age"] == nil || heads["contract/missing.age"] != nil {
+		t.Errorf("head batch = %v, want only contract/a.age", heads)
+	}
+
+	if err := store.DeleteBatch(ctx, []string{"contract/a.age", "contract/nested/b.age", "contractual.age"}); err != nil {
+		t.Fatalf("delete batch failed: %v", err)
+	}
+	if objects, _ := store.List(ctx, "contract"); len(objects) != 0 {
+		t.Errorf("expected no objects after delete, got %d", len(objects))
+	}
+}
+
+func testPagination(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	var g errgroup.Group
+	g.SetLimit(20)
+	keys := make([]string, paginationObjects)
+	for i := range keys {
+		key := fmt.Sprintf("pages/%04d.age", i)
+		keys[i] = key
+		g.Go(func() error { return store.Upload(ctx, key, []byte{byte(i)}) })
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+
+	objects, err := store.List(ctx, "pages/")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(objects) != paginationObjects {
+		t.Errorf("list returned %d objects, want %d", len(objects), paginationObjects)
+	}
+
+	if err := store.DeleteBatch(ctx, keys); err != nil {
+		t.Fatalf("delete batch failed: %v", err)
+	}
+	if objects, _ := store.List(ctx, "pages/"); len(objects) != 0 {
+		t.Errorf("expected no objects after delete, got %d", len(objects))
+	}
+}
+
+func testMatrixCrossDevice(t *testing.T, storageCfg *storage.StorageConfig) {
+	ctx := context.Background()
+	deviceA := newMatrixDevice(t, storageCfg)
+	deviceB := newMatrixDevice(t, storageCfg)
+
+	writeClaudeFile(t, deviceA, "CLAUDE.md", "from A")
+	writeClaudeFile(t, deviceA, "projects/app/session.jsonl", `{"type":"user"}`+"\n")
+	if result, err := newMatrixSyncer(t, deviceA).Push(ctx); err != nil || len(result.Uploaded) != 2 {
+		t.Fatalf("push = %v, %v; want 2 uploads", result, err)
+	}
+
+	if result, err := newMatrixSyncer(t, deviceB).Pull(ctx); err != nil || len(result.Downloaded) != 2 {
+		t.Fatalf("pull = %v, %v; want 2 downloads", result, err)
+	}
+	if got := readClaudeFile(t, deviceB, "CLAUDE.md"); got != "from A" {
+		t.Errorf("device B CLAUDE.md = %q, want %q", got, "from A")
+	}
+
+	// Providers with second-precision timestamps (WebDAV) must still see an
+	// update pushed right after the last pull as newer
+	time.Sleep(1100 * time.Millisecond)
+	writeClaudeFile(t, deviceA, "CLAUDE.md", "updated on A")
+	if _, err := newMatrixSyncer(t, deviceA).Push(ctx); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	result, err := newMatrixSyncer(t, deviceB).Pull(ctx)
+	if err != nil {
+		t.Fatalf("pull failed: %v", err)
+	}
+	if len(result.Downloaded) != 1 || len(result.Conflicts) != 0 {
+		t.Errorf("pull downloaded %v with conflicts %v, want just CLAUDE.md", result.Downloaded, result.Conflicts)
+	}
+	if got := readClaudeFile(t, deviceB, "CLAUDE.md"); got != "updated on A" {
+		t.Errorf("device B CLAUDE.md = %q, want the update", got)
+	}
+
+	// Nothing changed since: a second pull is a no-op
+	if result, err := newMatrixSyncer(t, deviceB).Pull(ctx); err != nil || len(result.Downloaded) != 0 {
+		t.Errorf("repeat pull = %v, %v; want nothing downloaded", result, err)
+	}
+}
+
+func testMatrixConflict(t *testing.T, storageCfg *storage.StorageConfig) {
+	ctx := context.Background()
+	deviceA := newMatrixDevice(t, storageCfg)
+	deviceB := newMatrixDevice(t, storageCfg)
+
+	writeClaudeFile(t, deviceA, "settings.json", `{"v":0}`)
+	if _, err := newMatrixSyncer(t, deviceA).Push(ctx); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if _, err := newMatrixSyncer(t, deviceB).Pull(ctx); err != nil {
+		t.Fatalf("pull failed: %v", err)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	writeClaudeFile(t, deviceA, "settings.json", `{"v":"a"}`)
+	writeClaudeFile(t, deviceB, "settings.json", `{"v":"b"}`)
+	if _, err := newMatrixSyncer(t, deviceA).Push(ctx); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	result, err := newMatrixSyncer(t, deviceB).Pull(ctx)
+	if err != nil {
+		t.Fatalf("pull failed: %v", err)
+	}
+	if len(result.Conflicts) != 1 {
+		t.Fatalf("conflicts = %v, want settings.json", result.Conflicts)
+	}
+	if got := readClaudeFile(t, deviceB, "settings.json"); got != `{"v":"b"}` {
+		t.Errorf("local settings.json = %q, want device B's version kept", got)
+	}
+}
+
+// newMatrixDevice sets up an isolated device home using storageCfg and
+// returns its config.
+func newMatrixDevice(t *testing.T, storageCfg *storage.StorageConfig) *config.Config {
+	t.Helper()
+	baseDir := t.TempDir()
+	configDir := filepath.Join(baseDir, ".claude-sync")
+	claudeDir := filepath.Join(baseDir, ".claude")
+	for _, dir := range []string{configDir, claudeDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+	}
+
+	keyPath := filepath.Join(configDir, "age-key.txt")
+	if err := crypto.GenerateKeyFromPassphrase(keyPath, getTestPassphrase()); err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	deviceCfg := *storageCfg
+	return &config.Config{
+		Storage:           &deviceCfg,
+		EncryptionKey:     keyPath,
+		ClaudeDirOverride: claudeDir,
+		StateDirOverride:  configDir,
+	}
+}
+
+// newMatrixSyncer opens a syncer for a device. Each device gets its own
+// device ID, since all of them run on one host.
+func newMatrixSyncer(t *testing.T, cfg *config.Config) *sync.Syncer {
+	t.Helper()
+	syncer, err := sync.NewSyncer(cfg, true)
+	if err != nil {
+		t.Fatalf("failed to create syncer: %v", err)
+	}
+	syncer.GetState().DeviceID = filepath.Base(filepath.Dir(cfg.ClaudeDirOverride))
+	return syncer
+}
+
+func writeClaudeFile(t *testing.T, cfg *config.Config, rel, content string) {
+	t.Helper()
+	path := filepath.Join(cfg.ClaudeDirOverride, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readClaudeFile(t *testing.T, cfg *config.Config, rel string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(cfg.ClaudeDirOverride, filepath.FromSlash(rel)))
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", rel, err)
+	}
+	return string(data)
+}
+
+// clearStore deletes every object in the store.
+func clearStore(t *testing.T, store storage.Storage) {
+	t.Helper()
+	ctx := context.Background()
+	objects, err := store.List(ctx, "")
+	if err != nil {
+		t.Fatalf("failed to list objects for cleanup: %v", err)
+	}
+	if len(objects) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		if !strings.HasSuffix(obj.Key, "/") {
+			keys = append(keys, obj.Key)
+		}
+	}
+	if err := store.DeleteBatch(ctx, keys); err != nil {
+		t.Fatalf("failed to clear store: %v", err)
+	}
+}
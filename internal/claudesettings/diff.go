@@ -0,0 +1,130 @@
+package claudesettings
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Change kinds reported by Diff.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// Change is one setting that differs between two settings files.
+type Change struct {
+	Key  string // Dotted path, e.g. "model" or "permissions.allow"
+	Kind string // ChangeAdded, ChangeRemoved or ChangeChanged
+	From string // Compact JSON of the old value; empty when added
+	To   string // Compact JSON of the new value; empty when removed
+}
+
+// Diff returns the settings that differ between from and to, sorted by key.
+// Objects such as permissions and env are compared key by key; lists of
+// strings such as permissions.allow are compared entry by entry, so adding
+// one rule reads as one added entry rather than a changed list. Other values
+// are compared whole.
+func Diff(from, to []byte) ([]Change, error) {
+	var a, b map[string]any
+	if err := unmarshalObject(from, &a); err != nil {
+		return nil, fmt.Errorf("old settings: %w", err)
+	}
+	if err := unmarshalObject(to, &b); err != nil {
+		return nil, fmt.Errorf("new settings: %w", err)
+	}
+	var changes []Change
+	diffObjects("", a, b, &changes)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes, nil
+}
+
+func unmarshalObject(data []byte, v *map[string]any) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		*v = map[string]any{}
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+func diffObjects(prefix string, a, b map[string]any, changes *[]Change) {
+	for key, av := range a {
+		bv, ok := b[key]
+		if !ok {
+			*changes = append(*changes, Change{Key: prefix + key, Kind: ChangeRemoved, From: compactJSON(av)})
+			continue
+		}
+		diffValues(prefix+key, av, bv, changes)
+	}
+	for key, bv := range b {
+		if _, ok := a[key]; !ok {
+			*changes = append(*changes, Change{Key: prefix + key, Kind: ChangeAdded, To: compactJSON(bv)})
+		}
+	}
+}
+
+func diffValues(key string, a, b any, changes *[]Change) {
+	if ao, ok := a.(map[string]any); ok {
+		if bo, ok := b.(map[string]any); ok {
+			diffObjects(key+".", ao, bo, changes)
+			return
+		}
+	}
+	if as, ok := stringList(a); ok {
+		if bs, ok := stringList(b); ok {
+			diffStringLists(key, as, bs, changes)
+			return
+		}
+	}
+	if from, to := compactJSON(a), compactJSON(b); from != to {
+		*changes = append(*changes, Change{Key: key, Kind: ChangeChanged, From: from, To: to})
+	}
+}
+
+func diffStringLists(key string, a, b []string, changes *[]Change) {
+	inA := make(map[string]bool, len(a))
+	for _, s := range a {
+		inA[s] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+	}
+	for _, s := range a {
+		if !inB[s] {
+			*changes = append(*changes, Change{Key: key, Kind: ChangeRemoved, From: compactJSON(s)})
+		}
+	}
+	for _, s := range b {
+		if !inA[s] {
+			*changes = append(*changes, Change{Key: key, Kind: ChangeAdded, To: compactJSON(s)})
+		}
+	}
+}
+
+// stringList returns v as a list of strings, if it is one.
+func stringList(v any) ([]string, bool) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, false
+	}
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, false
+		}
+		out = append(out, s)
+	}
+	return out, true
+}
+
+func compactJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,54 @@
+package claudesettings
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	from := `{
+		"model": "opus",
+		"includeCoAuthoredBy": true,
+		"permissions": {"allow": ["Bash(ls)", "Read"], "deny": []},
+		"env": {"DEBUG": "1"}
+	}`
+	to := `{
+		"model": "sonnet",
+		"permissions": {"allow": ["Read", "Bash(git status)"], "deny": []},
+		"env": {"DEBUG": "1", "TZ": "UTC"},
+		"statusLine": {"type": "command"}
+	}`
+
+	changes, err := Diff([]byte(from), []byte(to))
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	want := []Change{
+		{Key: "env.TZ", Kind: ChangeAdded, To: `"UTC"`},
+		{Key: "includeCoAuthoredBy", Kind: ChangeRemoved, From: "true"},
+		{Key: "model", Kind: ChangeChanged, From: `"opus"`, To: `"sonnet"`},
+		{Key: "permissions.allow", Kind: ChangeRemoved, From: `"Bash(ls)"`},
+		{Key: "permissions.allow", Kind: ChangeAdded, To: `"Bash(git status)"`},
+		{Key: "statusLine", Kind: ChangeAdded, To: `{"type":"command"}`},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Diff =\n%+v\nwant\n%+v", changes, want)
+	}
+}
+
+func TestDiffEdgeCases(t *testing.T) {
+	if changes, err := Diff([]byte(`{"a":1}`), []byte(`{"a":1}`)); err != nil || len(changes) != 0 {
+		t.Errorf("identical: %+v, %v", changes, err)
+	}
+	if changes, err := Diff(nil, []byte(`{"a":1}`)); err != nil || len(changes) != 1 || changes[0].Kind != ChangeAdded {
+		t.Errorf("from empty: %+v, %v", changes, err)
+	}
+	// A type change is compared whole
+	changes, err := Diff([]byte(`{"hooks":{}}`), []byte(`{"hooks":[]}`))
+	if err != nil || len(changes) != 1 || changes[0].Kind != ChangeChanged {
+		t.Errorf("type change: %+v, %v", changes, err)
+	}
+	if _, err := Diff([]byte(`{`), []byte(`{}`)); err == nil {
+		t.Error("Expected an error for invalid JSON")
+	}
+}
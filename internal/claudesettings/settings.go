@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -20,16 +21,20 @@ import (
 const (
 	// HookCommandPull is the command installed for SessionStart.
 	HookCommandPull = "claude-sync pull -q"
-	// HookCommandPush is the command installed for Stop.
-	HookCommandPush = "claude-sync push -q"
+	// HookCommandPush is the command installed for Stop or SessionEnd.
+	// --changed-only returns at once when no local file changed, since Stop
+	// fires each time Claude finishes responding.
+	HookCommandPush = "claude-sync push -q --changed-only"
 
 	// HookTypeCommand is the standard hook type for shell commands.
 	HookTypeCommand = "command"
 
 	// EventSessionStart is the hook event for session start.
 	EventSessionStart = "SessionStart"
-	// EventStop is the hook event for session stop.
+	// EventStop is the hook event for Claude finishing a response.
 	EventStop = "Stop"
+	// EventSessionEnd is the hook event for session end.
+	EventSessionEnd = "SessionEnd"
 )
 
 // SettingsRepository defines the interface for settings persistence.
@@ -72,6 +77,7 @@ type AutoSyncStatus struct {
 	Enabled         bool
 	HasSessionStart bool
 	HasStop         bool
+	HasSessionEnd   bool
 }
 
 // AutoSyncConfig defines the hooks to install for auto-sync.
@@ -328,6 +334,45 @@ func AddHook(groups []HookGroup, command string) []HookGroup {
 	})
 }
 
+// outdatedHookCommands are the hooks earlier versions installed, by the
+// command that has since replaced them.
+var outdatedHookCommands = map[string][]string{
+	HookCommandPush: {"claude-sync push -q"},
+}
+
+// UpdateHookCommands rewrites hooks an earlier version installed in place of
+// command (e.g. "claude-sync push -q" for HookCommandPush) to run command,
+// keeping the path they call claude-sync by. Hooks the user has changed, with
+// other flags or a redirection, are left alone like any other hook.
+// Returns true if any hook changed.
+func UpdateHookCommands(groups []HookGroup, command string) bool {
+	want := strings.Fields(command)
+	changed := false
+	for i := range groups {
+		for j := range groups[i].Hooks {
+			h := &groups[i].Hooks[j]
+			fields := strings.Fields(h.Command)
+			if !defaultMatcher.Matches(h.Command) || len(fields) == 0 || !isOutdatedHook(fields[1:], command) {
+				continue
+			}
+			h.Command = strings.Join(append([]string{fields[0]}, want[1:]...), " ")
+			changed = true
+		}
+	}
+	return changed
+}
+
+// isOutdatedHook reports whether args, a hook's arguments after the
+// claude-sync binary, are exactly those of a hook command replaced.
+func isOutdatedHook(args []string, command string) bool {
+	for _, old := range outdatedHookCommands[command] {
+		if slices.Equal(args, strings.Fields(old)[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
 // RemoveClaudeSyncHooks removes all claude-sync hook entries.
 // Groups that become empty are dropped entirely.
 // Non-claude-sync hooks are preserved.
@@ -365,27 +410,64 @@ func (s *Settings) EnableAutoSync() bool {
 }
 
 // EnableAutoSyncWithConfig adds hooks using the specified configuration.
-// This allows customizing the commands and events used.
+// This allows customizing the commands and events used. Hooks an earlier
+// version installed are brought up to date in place.
 func (s *Settings) EnableAutoSyncWithConfig(cfg AutoSyncConfig) bool {
 	changed := false
 
 	if !HasClaudeSyncHook(s.Hooks[cfg.PullEvent]) {
 		s.Hooks[cfg.PullEvent] = AddHook(s.Hooks[cfg.PullEvent], cfg.PullCommand)
 		changed = true
+	} else if UpdateHookCommands(s.Hooks[cfg.PullEvent], cfg.PullCommand) {
+		changed = true
 	}
 
 	if !HasClaudeSyncHook(s.Hooks[cfg.PushEvent]) {
 		s.Hooks[cfg.PushEvent] = AddHook(s.Hooks[cfg.PushEvent], cfg.PushCommand)
 		changed = true
+	} else if UpdateHookCommands(s.Hooks[cfg.PushEvent], cfg.PushCommand) {
+		changed = true
 	}
 
 	return changed
 }
 
-// DisableAutoSync removes all claude-sync hooks from SessionStart and Stop events.
+// DisableAutoSync removes all claude-sync hooks from SessionStart, Stop and
+// SessionEnd events.
 // Returns true if any changes were made, false if no hooks were present.
 func (s *Settings) DisableAutoSync() bool {
-	return s.DisableAutoSyncWithConfig(DefaultAutoSyncConfig())
+	changed := s.DisableAutoSyncWithConfig(DefaultAutoSyncConfig())
+	// The push hook may have been installed for SessionEnd instead of Stop
+	if s.RemoveAutoSyncHooks(EventSessionEnd) {
+		changed = true
+	}
+	return changed
+}
+
+// RemoveAutoSyncHooks removes the claude-sync hooks of a single event.
+// Returns true if any changes were made.
+func (s *Settings) RemoveAutoSyncHooks(event string) bool {
+	if !HasClaudeSyncHook(s.Hooks[event]) {
+		return false
+	}
+	s.Hooks[event] = RemoveClaudeSyncHooks(s.Hooks[event])
+	if len(s.Hooks[event]) == 0 {
+		delete(s.Hooks, event)
+	}
+	return true
+}
+
+// AutoSyncCommands returns the claude-sync commands installed for an event.
+func (s *Settings) AutoSyncCommands(event string) []string {
+	var commands []string
+	for _, g := range s.Hooks[event] {
+		for _, h := range g.Hooks {
+			if defaultMatcher.Matches(h.Command) {
+				commands = append(commands, h.Command)
+			}
+		}
+	}
+	return commands
 }
 
 // DisableAutoSyncWithConfig removes hooks from the specified events.
@@ -415,9 +497,11 @@ func (s *Settings) DisableAutoSyncWithConfig(cfg AutoSyncConfig) bool {
 func (s *Settings) AutoSyncStatus() AutoSyncStatus {
 	hasStart := HasClaudeSyncHook(s.Hooks[EventSessionStart])
 	hasStop := HasClaudeSyncHook(s.Hooks[EventStop])
+	hasEnd := HasClaudeSyncHook(s.Hooks[EventSessionEnd])
 	return AutoSyncStatus{
-		Enabled:         hasStart || hasStop,
+		Enabled:         hasStart || hasStop || hasEnd,
 		HasSessionStart: hasStart,
 		HasStop:         hasStop,
+		HasSessionEnd:   hasEnd,
 	}
 }
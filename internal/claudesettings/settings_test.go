@@ -730,6 +730,88 @@ func TestAutoSyncStatus(t *testing.T) {
 	}
 }
 
+func TestAutoSyncOnSessionEnd(t *testing.T) {
+	settings := NewSettings()
+	settings.Hooks[EventSessionEnd] = []HookGroup{
+		{Hooks: []HookEntry{{Type: "command", Command: "echo bye"}}},
+	}
+
+	cfg := DefaultAutoSyncConfig()
+	cfg.PushEvent = EventSessionEnd
+	if !settings.EnableAutoSyncWithConfig(cfg) {
+		t.Fatal("EnableAutoSyncWithConfig should return true for fresh settings")
+	}
+	status := settings.AutoSyncStatus()
+	if !status.Enabled || status.HasStop || !status.HasSessionEnd {
+		t.Errorf("Status = %+v, want the push hook on SessionEnd only", status)
+	}
+	if got := settings.AutoSyncCommands(EventSessionEnd); len(got) != 1 || got[0] != HookCommandPush {
+		t.Errorf("AutoSyncCommands(SessionEnd) = %v, want [%s]", got, HookCommandPush)
+	}
+
+	if !settings.DisableAutoSync() {
+		t.Error("DisableAutoSync should return true with a SessionEnd hook")
+	}
+	if settings.AutoSyncStatus().Enabled {
+		t.Error("SessionEnd hook should be removed")
+	}
+	if len(settings.Hooks[EventSessionEnd]) != 1 {
+		t.Error("Other SessionEnd hooks should be preserved")
+	}
+	if settings.RemoveAutoSyncHooks(EventSessionEnd) {
+		t.Error("RemoveAutoSyncHooks should return false with no claude-sync hook")
+	}
+}
+
+func TestEnableAutoSyncUpdatesOldHooks(t *testing.T) {
+	settings := NewSettings()
+	settings.Hooks[EventSessionStart] = []HookGroup{
+		{Hooks: []HookEntry{{Type: "command", Command: HookCommandPull}}},
+	}
+	settings.Hooks[EventStop] = []HookGroup{
+		{Hooks: []HookEntry{
+			{Type: "command", Command: "echo done"},
+			{Type: "command", Command: "/usr/local/bin/claude-sync push -q"},
+		}},
+	}
+
+	if !settings.EnableAutoSync() {
+		t.Fatal("EnableAutoSync should return true when a hook is out of date")
+	}
+	want := "/usr/local/bin/claude-sync push -q --changed-only"
+	if got := settings.AutoSyncCommands(EventStop); len(got) != 1 || got[0] != want {
+		t.Errorf("AutoSyncCommands(Stop) = %v, want [%s]", got, want)
+	}
+	if got := settings.Hooks[EventStop][0].Hooks[0].Command; got != "echo done" {
+		t.Errorf("Other Stop hook = %q, want it preserved", got)
+	}
+	if got := settings.AutoSyncCommands(EventSessionStart); len(got) != 1 || got[0] != HookCommandPull {
+		t.Errorf("AutoSyncCommands(SessionStart) = %v, want [%s]", got, HookCommandPull)
+	}
+
+	if settings.EnableAutoSync() {
+		t.Error("EnableAutoSync should return false once hooks are up to date")
+	}
+}
+
+func TestEnableAutoSyncKeepsCustomizedHooks(t *testing.T) {
+	custom := "claude-sync push -q --wait 30s >> /tmp/claude-sync.log 2>&1"
+	settings := NewSettings()
+	settings.Hooks[EventSessionStart] = []HookGroup{
+		{Hooks: []HookEntry{{Type: "command", Command: HookCommandPull}}},
+	}
+	settings.Hooks[EventStop] = []HookGroup{
+		{Hooks: []HookEntry{{Type: "command", Command: custom}}},
+	}
+
+	if settings.EnableAutoSync() {
+		t.Error("EnableAutoSync should not rewrite a customized hook")
+	}
+	if got := settings.AutoSyncCommands(EventStop); len(got) != 1 || got[0] != custom {
+		t.Errorf("AutoSyncCommands(Stop) = %v, want [%s]", got, custom)
+	}
+}
+
 func TestIntegrationRoundTrip(t *testing.T) {
 	tmpDir := t.TempDir()
 	path := filepath.Join(tmpDir, "settings.json")
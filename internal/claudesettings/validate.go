@@ -0,0 +1,100 @@
+package claudesettings
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// jsonKind is the JSON type a well-known settings field must have.
+type jsonKind string
+
+const (
+	kindObject jsonKind = "an object"
+	kindArray  jsonKind = "an array"
+	kindString jsonKind = "a string"
+	kindBool   jsonKind = "a boolean"
+	kindNumber jsonKind = "a number"
+)
+
+// knownFields are settings Claude Code reads, with the type each must have.
+// Fields not listed here are accepted as they are.
+var knownFields = map[string]jsonKind{
+	"hooks":                      kindObject,
+	"permissions":                kindObject,
+	"env":                        kindObject,
+	"statusLine":                 kindObject,
+	"enabledPlugins":             kindObject,
+	"model":                      kindString,
+	"apiKeyHelper":               kindString,
+	"outputStyle":                kindString,
+	"includeCoAuthoredBy":        kindBool,
+	"alwaysThinkingEnabled":      kindBool,
+	"cleanupPeriodDays":          kindNumber,
+	"enableAllProjectMcpServers": kindBool,
+	"enabledMcpjsonServers":      kindArray,
+	"disabledMcpjsonServers":     kindArray,
+}
+
+// Validate checks that data is a settings file Claude Code can load: a JSON
+// object whose well-known fields have the expected types. An empty file is
+// valid, as Load treats it as empty settings.
+func Validate(data []byte) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("not a JSON object: %w", err)
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		want, known := knownFields[name]
+		if !known {
+			continue
+		}
+		if got := kindOf(fields[name]); got != want {
+			return fmt.Errorf("%q must be %s", name, want)
+		}
+	}
+
+	if env, ok := fields["env"]; ok {
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(env, &values); err == nil {
+			for name, v := range values {
+				if kindOf(v) != kindString {
+					return fmt.Errorf("env %q must be a string", name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// kindOf returns the JSON type of a raw value.
+func kindOf(raw json.RawMessage) jsonKind {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return ""
+	}
+	switch raw[0] {
+	case '{':
+		return kindObject
+	case '[':
+		return kindArray
+	case '"':
+		return kindString
+	case 't', 'f':
+		return kindBool
+	case 'n':
+		return ""
+	}
+	return kindNumber
+}
@@ -0,0 +1,29 @@
+package claudesettings
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"empty", "", false},
+		{"empty object", "{}", false},
+		{"typical", `{"model":"opus","permissions":{"allow":["Bash"]},"env":{"FOO":"1"}}`, false},
+		{"unknown fields", `{"somethingNew":[1,2],"other":null}`, false},
+		{"truncated", `{"model":"opus"`, true},
+		{"not an object", `["model"]`, true},
+		{"wrong type", `{"hooks":"none"}`, true},
+		{"wrong bool", `{"includeCoAuthoredBy":"yes"}`, true},
+		{"non-string env", `{"env":{"DEBUG":1}}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%s) error = %v, wantErr %v", tt.data, err, tt.wantErr)
+			}
+		})
+	}
+}
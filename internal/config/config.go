@@ -1,12 +1,17 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
+	"github.com/tawanorg/claude-sync/internal/crypto"
 	"github.com/tawanorg/claude-sync/internal/storage"
 	"gopkg.in/yaml.v3"
 )
@@ -17,6 +22,41 @@ const (
 	StateFile  = "state.json"
 	AgeKeyFile = "age-key.txt"
 
+	// DeviceKeyFile is this device's own identity when device_keys is set.
+	DeviceKeyFile = "device-key.txt"
+
+	// RemoteCacheFile holds the last remote listing, for offline planning.
+	RemoteCacheFile = "remote-cache.json"
+
+	// PullPlanFile holds the last pull plan 'pull --dry-run' previewed.
+	PullPlanFile = "pull-plan.json"
+
+	// RequestsFile accumulates storage request counts per command.
+	RequestsFile = "requests.json"
+
+	// ActivityFile logs each push and pull, for 'claude-sync report'.
+	ActivityFile = "activity.jsonl"
+
+	// StatusFile summarizes the last sync for shell prompts and status bars
+	// ('claude-sync status --porcelain').
+	StatusFile = "status.json"
+
+	// LockFile is held by the claude-sync process syncing, so that a second
+	// one waits or stops instead of running alongside it.
+	LockFile = "sync.lock"
+
+	// QuarantineDir holds the corrupt lines repair_jsonl cuts off pulled
+	// .jsonl files, outside the synced directory so they aren't pushed.
+	QuarantineDir = "quarantine"
+
+	// NotificationsFile is where "log" notifiers append events by default.
+	NotificationsFile = "notifications.log"
+
+	// LogsDir holds the debug log, LogFile, and its rotated copies when
+	// log.file is set.
+	LogsDir = "logs"
+	LogFile = "claude-sync.log"
+
 	// MCPRemoteKey is the remote storage key for synced MCP server configs.
 	// The _external/ prefix separates it from ~/.claude/-relative files.
 	MCPRemoteKey = "_external/mcp-servers.json"
@@ -26,6 +66,20 @@ const (
 	// syncing to portable conversation data only.
 	ScopeFull     = "full"
 	ScopeSessions = "sessions"
+
+	// DefaultStaleAfter is the stale_after used when none is configured.
+	DefaultStaleAfter = 14 * 24 * time.Hour
+
+	// DefaultDeleteThreshold is the delete_threshold used when none is
+	// configured.
+	DefaultDeleteThreshold = 25
+
+	// Hash algorithms for detecting file changes. HashSHA256 is the default.
+	HashSHA256 = "sha256"
+	HashBLAKE3 = "blake3"
+	// CommandSetPersonal names the commands not in any command_namespaces
+	// entry when ordering command sets.
+	CommandSetPersonal = "personal"
 )
 
 type Config struct {
@@ -40,8 +94,48 @@ type Config struct {
 	Endpoint        string `yaml:"endpoint,omitempty"`
 
 	// Common fields
+	// EncryptionKey is an age identity file, or an ssh-ed25519/ssh-rsa
+	// private key to use in its place.
 	EncryptionKey string `yaml:"encryption_key_path"`
 
+	// Recipients are extra age ("age1...") or SSH ("ssh-ed25519 ...") public
+	// keys every file is also encrypted to, such as a recovery key or
+	// teammates reading the bucket.
+	// Files pushed from then on include them; 'claude-sync rekey' re-encrypts
+	// everything already there.
+	Recipients []string `yaml:"recipients,omitempty"`
+
+	// Encryption names the encryption scheme objects are encrypted with:
+	// crypto.SchemeAge (the default) or one registered with
+	// crypto.RegisterScheme. Device keys and KMS need age.
+	Encryption string `yaml:"encryption,omitempty"`
+
+	// DeviceKeys makes EncryptionKey this device's own identity rather than
+	// the bucket's key: the key files are encrypted to (the data key) is
+	// read from the bucket's device registry, which only registered devices
+	// can open. See 'claude-sync device'.
+	DeviceKeys bool `yaml:"device_keys,omitempty"`
+
+	// Keyring encrypts files with a random data key kept in the bucket's
+	// keyring, which is encrypted to EncryptionKey, so changing the
+	// passphrase only rewrites the keyring. See 'claude-sync key keyring'.
+	Keyring bool `yaml:"keyring,omitempty"`
+
+	// EncryptCredentials stores the storage keys and passwords in this file
+	// encrypted to the age key, so the file alone doesn't give access to
+	// the bucket. Load decrypts them.
+	EncryptCredentials bool `yaml:"encrypt_credentials,omitempty"`
+
+	// KDF records the Argon2id parameters a passphrase key was derived with,
+	// as stored with the bucket. Nil for random keys and for setups from
+	// before KDF parameters were recorded, which use the fixed-salt defaults.
+	KDF *crypto.KDFParams `yaml:"kdf,omitempty"`
+
+	// ClaudeDir is where the Claude directory is when it isn't ~/.claude,
+	// such as the directory CLAUDE_CONFIG_DIR points Claude Code at.
+	// CLAUDE_SYNC_CLAUDE_DIR overrides it. See ClaudeDirE.
+	ClaudeDir string `yaml:"claude_dir,omitempty"`
+
 	// Exclude patterns (glob-style) for paths to skip during sync
 	Exclude []string `yaml:"exclude,omitempty"`
 
@@ -53,6 +147,10 @@ type Config struct {
 	// Use GetEffectiveSyncPaths() to get the actual paths to sync.
 	SyncPaths []string `yaml:"sync_paths,omitempty"`
 
+	// Hosts adds or removes sync paths and exclude patterns on particular
+	// machines only. See HostOverride.
+	Hosts []HostOverride `yaml:"hosts,omitempty"`
+
 	// MCPSync enables syncing MCP server configs from ~/.claude.json.
 	// Pointer type allows distinguishing between unset (nil), enabled (true),
 	// and explicitly disabled (false). Nil is treated as disabled for backward
@@ -70,6 +168,123 @@ type Config struct {
 	//     ~/Projects: WORK
 	PathMap map[string]string `yaml:"path_map,omitempty"`
 
+	// Versioning keeps a copy of every pushed file under _versions/ so an
+	// accidental push can be undone.
+	Versioning bool `yaml:"versioning,omitempty"`
+
+	// VersionsKeep and VersionsMaxAge bound the versions kept per file: at
+	// most VersionsKeep of them, none older than VersionsMaxAge (e.g. "30d").
+	// Unset means unbounded. The newest version of a file is always kept.
+	// Limits are enforced after each push and by 'claude-sync prune-versions'.
+	VersionsKeep   int    `yaml:"versions_keep,omitempty"`
+	VersionsMaxAge string `yaml:"versions_max_age,omitempty"`
+
+	// RepairJSONL cuts invalid trailing lines (torn writes) off pulled .jsonl
+	// files and keeps them in a .corrupt.<timestamp> file under
+	// ~/.claude-sync/quarantine. Without it, pull only reports them.
+	RepairJSONL bool `yaml:"repair_jsonl,omitempty"`
+
+	// PullBackups makes pull copy the local files it is about to overwrite
+	// into ~/.claude.backup.auto.<timestamp> first, keeping the newest
+	// PullBackups such backups. 0 (default) turns automatic backups off.
+	PullBackups int `yaml:"pull_backups,omitempty"`
+
+	// StaleAfter is how long local changes may wait unpushed before status
+	// warns, as an age like "14d" or "36h". Empty means DefaultStaleAfter;
+	// "0" turns the warning off.
+	StaleAfter string `yaml:"stale_after,omitempty"`
+
+	// PushLeases makes push claim a short-lived lease on each top-level area
+	// it changes (projects, agents, ...) under _locks/, so devices pushing the
+	// same area don't interleave while pushes of disjoint areas still run
+	// side by side.
+	PushLeases bool `yaml:"push_leases,omitempty"`
+
+	// DeleteThreshold is how many remote files one push may delete before it
+	// needs confirming (interactively or with --confirm-deletes), so a
+	// directory that is briefly missing, like an unmounted volume, can't wipe
+	// the remote copy. Nil means DefaultDeleteThreshold; 0 turns it off.
+	DeleteThreshold *int `yaml:"delete_threshold,omitempty"`
+
+	// SyncWindows limit when some paths sync, for capped connections: big
+	// directories like projects/ only overnight, everything else any time.
+	SyncWindows []SyncWindow `yaml:"sync_windows,omitempty"`
+
+	// Trash makes push and 'reset --remote' move deleted remote objects under
+	// _trash/ instead of deleting them. 'claude-sync trash' lists, restores,
+	// and empties it.
+	Trash bool `yaml:"trash,omitempty"`
+
+	// HashAlgorithm is how file contents are hashed to detect changes:
+	// "sha256" (default) or "blake3", which is much faster on large trees.
+	// Switching re-hashes tracked files once; nothing is re-uploaded.
+	HashAlgorithm string `yaml:"hash_algorithm,omitempty"`
+
+	// ObfuscateKeys stores files under opaque keys (o/<hmac>) instead of
+	// their paths, so the bucket listing doesn't reveal project or file
+	// names. The real paths are kept in the encrypted key index. Every device
+	// syncing the bucket needs it on.
+	ObfuscateKeys bool `yaml:"obfuscate_keys,omitempty"`
+
+	// PreviousBucket is the bucket 'claude-sync remote move' moved away from.
+	// Until PreviousBucketUntil, writes go to it as well, so devices that
+	// haven't switched yet keep seeing changes.
+	PreviousBucket      string    `yaml:"previous_bucket,omitempty"`
+	PreviousBucketUntil time.Time `yaml:"previous_bucket_until,omitempty"`
+
+	// NetworkFS says whether ~/.claude is on a network filesystem (NFS, SMB),
+	// where pull compares contents instead of trusting mtimes. Nil means
+	// detect it; set it when detection gets it wrong.
+	NetworkFS *bool `yaml:"network_fs,omitempty"`
+
+	// CommandNamespaces lists subdirectories of commands/ that hold shared
+	// command sets, e.g. "team", in precedence order. Every other command is
+	// personal, and ranks first unless "personal" is listed too. Pull skips
+	// a command when a higher-ranked set defines one with the same name, so
+	// a pulled team command can't shadow a personal slash command.
+	CommandNamespaces []string `yaml:"command_namespaces,omitempty"`
+
+	// ReviewNamespaces lists command sets from command_namespaces whose
+	// changes need review: push stages them as a proposal that another
+	// device must 'claude-sync approve' before they replace the shared copy.
+	ReviewNamespaces []string `yaml:"review_namespaces,omitempty"`
+
+	// Attestations makes every push append a signed summary of the bucket
+	// (object count, total size, manifest hash) to a chain under
+	// _attestations/, which 'claude-sync verify' checks for tampering.
+	// AttestationSigners lists the signing keys of other identities sharing
+	// the bucket whose attestations (and signed manifests) are accepted; its
+	// own is always trusted.
+	Attestations       bool     `yaml:"attestations,omitempty"`
+	AttestationSigners []string `yaml:"attestation_signers,omitempty"`
+
+	// SignManifest makes push sign the remote manifest, which then records
+	// the hash of every stored object, and makes pull refuse a manifest
+	// without a trusted signature or an object that doesn't match it.
+	SignManifest bool `yaml:"sign_manifest,omitempty"`
+
+	// Notify lists the channels sync events (a sync starting or finishing,
+	// conflicts, errors, an available update) are sent to.
+	Notify []NotifierConfig `yaml:"notify,omitempty"`
+
+	// Report configures where 'claude-sync report --send' delivers its
+	// summary of sync activity, for devices nobody logs into.
+	Report *ReportConfig `yaml:"report,omitempty"`
+
+	// PingURL is a cron-monitoring check (Healthchecks.io and the like) that
+	// 'claude-sync daemon' requests after each successful pull and push, and
+	// with "/fail" appended after a failed one, so a daemon that stops
+	// syncing raises an alert.
+	PingURL string `yaml:"ping_url,omitempty"`
+
+	// Update points 'claude-sync update' at another release source, such
+	// as GitHub Enterprise or an internal mirror.
+	Update *UpdateConfig `yaml:"update,omitempty"`
+
+	// Log keeps a log of what each run did, for looking into a sync that
+	// hung or went wrong after the fact. See LogConfig.
+	Log *LogConfig `yaml:"log,omitempty"`
+
 	// ClaudeDirOverride allows overriding the default ~/.claude path (for testing)
 	ClaudeDirOverride string `yaml:"-"`
 
@@ -78,6 +293,108 @@ type Config struct {
 
 	// ClaudeJSONOverride allows overriding the ~/.claude.json path (for testing)
 	ClaudeJSONOverride string `yaml:"-"`
+
+	// envOverrides are the settings Load took from the environment (see
+	// EnvProvider) or credentials_command, which Save leaves as they are in
+	// the file
+	envOverrides []envOverride
+	// credentialsRan is set once credentials_command has run
+	credentialsRan bool
+}
+
+// SyncWindow lets Paths (files or directories under ~/.claude) sync only
+// between Start and End, local "HH:MM" times; an End before Start spans
+// midnight. Outside every window listing it, push and pull hold a path back
+// as if it were paused.
+type SyncWindow struct {
+	Paths []string `yaml:"paths"`
+	Start string   `yaml:"start"`
+	End   string   `yaml:"end"`
+}
+
+// ReportConfig says where activity reports are sent. Either or both of
+// Webhook and SMTP may be set.
+type ReportConfig struct {
+	// Period is the span a report covers: "daily" (default) or "weekly".
+	Period string `yaml:"period,omitempty"`
+
+	// Webhook is a URL the report is POSTed to as JSON.
+	Webhook string `yaml:"webhook,omitempty"`
+
+	// SMTP sends the report as a plain-text email.
+	SMTP *SMTPConfig `yaml:"smtp,omitempty"`
+}
+
+// Update sources
+const (
+	UpdateSourceGitHub = "github"
+	UpdateSourceHTTPS  = "https"
+)
+
+// UpdateConfig is where updates come from. Source "github" (the default)
+// reads releases from a GitHub repository, on github.com or, with
+// GitHubAPI, a GitHub Enterprise server; "https" reads them from BaseURL,
+// which serves the version in "latest" and each release's files under
+// "v<version>/". Any source other than the public releases must be signed:
+// checksums.txt.sig holds an Ed25519 signature of checksums.txt by
+// PublicKey.
+type UpdateConfig struct {
+	Source     string `yaml:"source,omitempty"`
+	GitHubAPI  string `yaml:"github_api,omitempty"` // e.g. https://github.example.com/api/v3
+	Repository string `yaml:"repository,omitempty"` // owner/name
+	BaseURL    string `yaml:"base_url,omitempty"`
+	PublicKey  string `yaml:"public_key,omitempty"` // Base64 Ed25519 public key
+}
+
+// LogConfig configures the log file. With File set, every run appends
+// records at Level ("debug", "info" (default), "warn" or "error") to
+// ~/.claude-sync/logs/claude-sync.log, which is rotated once it passes
+// MaxSizeMB (default 5), keeping MaxFiles files (default 5). Debug records
+// include every storage request.
+type LogConfig struct {
+	File      bool   `yaml:"file,omitempty"`
+	Level     string `yaml:"level,omitempty"`
+	MaxSizeMB int    `yaml:"max_size_mb,omitempty"`
+	MaxFiles  int    `yaml:"max_files,omitempty"`
+}
+
+// NotifierConfig is a notification channel. Type is "desktop", "webhook"
+// (posting JSON to URL), "email" (through SMTP) or "log" (appending JSON
+// lines to Path, by default ~/.claude-sync/notifications.log). Events
+// limits the event kinds it gets; by default it gets conflicts, errors and
+// available updates.
+type NotifierConfig struct {
+	Type   string      `yaml:"type"`
+	Events []string    `yaml:"events,omitempty"`
+	URL    string      `yaml:"url,omitempty"`
+	SMTP   *SMTPConfig `yaml:"smtp,omitempty"`
+	Path   string      `yaml:"path,omitempty"`
+}
+
+// SMTPConfig is an SMTP server to send reports through. Port defaults to 587;
+// STARTTLS is used when the server offers it.
+type SMTPConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port,omitempty"`
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// ReportPeriod returns how long a report covers.
+func (r *ReportConfig) ReportPeriod() (time.Duration, error) {
+	period := ""
+	if r != nil {
+		period = r.Period
+	}
+	switch period {
+	case "", "daily":
+		return 24 * time.Hour, nil
+	case "weekly":
+		return 7 * 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("invalid report period %q (use \"daily\" or \"weekly\")", period)
 }
 
 // SyncPaths defines which paths under ~/.claude to sync in the default "full" scope.
@@ -142,17 +459,57 @@ func StateFilePath() string {
 	return filepath.Join(ConfigDirPath(), StateFile)
 }
 
+func RequestsFilePath() string {
+	return filepath.Join(ConfigDirPath(), RequestsFile)
+}
+
+func ActivityFilePath() string {
+	return filepath.Join(ConfigDirPath(), ActivityFile)
+}
+
+func StatusFilePath() string {
+	return filepath.Join(ConfigDirPath(), StatusFile)
+}
+
+func LockFilePath() string {
+	return filepath.Join(ConfigDirPath(), LockFile)
+}
+
+func NotificationsFilePath() string {
+	return filepath.Join(ConfigDirPath(), NotificationsFile)
+}
+
+func LogFilePath() string {
+	return filepath.Join(ConfigDirPath(), LogsDir, LogFile)
+}
+
 func AgeKeyFilePath() string {
 	return filepath.Join(ConfigDirPath(), AgeKeyFile)
 }
 
+func DeviceKeyFilePath() string {
+	return filepath.Join(ConfigDirPath(), DeviceKeyFile)
+}
+
 func ClaudeDir() string {
 	path, _ := ClaudeDirE()
 	return path
 }
 
-// ClaudeDirE returns the Claude directory path or an error if home dir is unavailable.
+// ClaudeDirE returns the Claude directory path or an error if home dir is
+// unavailable. It is, in order of precedence, CLAUDE_SYNC_CLAUDE_DIR,
+// claude_dir in config.yaml, Claude Code's own CLAUDE_CONFIG_DIR, or
+// ~/.claude.
 func ClaudeDirE() (string, error) {
+	if dir := os.Getenv(EnvClaudeDir); dir != "" {
+		return expandPath(dir)
+	}
+	if dir := claudeDirSetting(); dir != "" {
+		return expandPath(dir)
+	}
+	if dir := os.Getenv(EnvClaudeConfigDir); dir != "" {
+		return expandPath(dir)
+	}
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", ErrNoHomeDir
@@ -160,22 +517,77 @@ func ClaudeDirE() (string, error) {
 	return filepath.Join(home, ".claude"), nil
 }
 
-// ClaudeJSONPath returns the path to ~/.claude.json where global MCP servers are configured.
+// claudeDirSetting reads claude_dir from config.yaml on its own, so commands
+// that never load the whole config (and open its credentials) agree on the
+// Claude directory with those that do.
+func claudeDirSetting() string {
+	data, err := os.ReadFile(ConfigFilePath())
+	if err != nil {
+		return ""
+	}
+	var setting struct {
+		ClaudeDir string `yaml:"claude_dir"`
+	}
+	if yaml.Unmarshal(data, &setting) != nil {
+		return ""
+	}
+	return setting.ClaudeDir
+}
+
+// LogSettings reads the log section from config.yaml on its own, as
+// claudeDirSetting does, so logging can start before a command loads the
+// config. It returns nil when there is none.
+func LogSettings() *LogConfig {
+	data, err := os.ReadFile(ConfigFilePath())
+	if err != nil {
+		return nil
+	}
+	var settings struct {
+		Log *LogConfig `yaml:"log"`
+	}
+	if yaml.Unmarshal(data, &settings) != nil {
+		return nil
+	}
+	return settings.Log
+}
+
+// expandPath expands a leading ~ and makes path absolute.
+func expandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", ErrNoHomeDir
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	return filepath.Abs(path)
+}
+
+// ClaudeJSONPath returns the path to ~/.claude.json where global MCP servers
+// are configured. With the Claude directory elsewhere (CLAUDE_CONFIG_DIR,
+// claude_dir or CLAUDE_SYNC_CLAUDE_DIR) it is inside that directory, as
+// Claude Code keeps it there.
 func ClaudeJSONPath() string {
-	home, err := os.UserHomeDir()
+	dir, err := ClaudeDirE()
 	if err != nil {
 		return ""
 	}
-	return filepath.Join(home, ".claude.json")
+	if home, err := os.UserHomeDir(); err == nil && dir == filepath.Join(home, ".claude") {
+		return filepath.Join(home, ".claude.json")
+	}
+	return filepath.Join(dir, ".claude.json")
 }
 
+// ErrNotConfigured is what Load returns when there is no config file.
+var ErrNotConfigured = errors.New("config not found: run 'claude-sync init' first")
+
 func Load() (*Config, error) {
 	configPath := ConfigFilePath()
 
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("config not found: run 'claude-sync init' first")
+			return nil, ErrNotConfigured
 		}
 		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
@@ -204,9 +616,20 @@ func Load() (*Config, error) {
 		cfg.PathMap = expanded
 	}
 
+	// Environment values replace the file's before sealed credentials are
+	// opened, so a CI job with CLAUDE_SYNC_SECRET_ACCESS_KEY needs no key
+	applyEnv(&cfg)
+	if cfg.Storage != nil && cfg.Storage.CredentialsCommand != "" {
+		cfg.Storage.SetCredentialsResolver(cfg.commandCredentials)
+	}
+
+	if err := openCredentials(&cfg); err != nil {
+		return nil, err
+	}
+
 	// Set default endpoint for Cloudflare R2
 	if cfg.Endpoint == "" && cfg.AccountID != "" {
-		cfg.Endpoint = fmt.Sprintf("https://%s.r2.cloudflarestorage.com", cfg.AccountID)
+		cfg.Endpoint = storage.R2Endpoint(cfg.AccountID, "")
 	}
 
 	return &cfg, nil
@@ -218,6 +641,15 @@ func Save(cfg *Config) error {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
+	cfg = cfg.withoutEnv()
+	if cfg.EncryptCredentials {
+		sealed, err := sealCredentials(cfg)
+		if err != nil {
+			return err
+		}
+		cfg = sealed
+	}
+
 	data, err := yaml.Marshal(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to serialize config: %w", err)
@@ -254,18 +686,44 @@ func (c *Config) GetStorageConfig() *storage.StorageConfig {
 	}
 }
 
+// SetBucket changes the configured bucket, in whichever format the config
+// uses.
+func (c *Config) SetBucket(bucket string) {
+	if c.Storage != nil && c.Storage.Provider != "" {
+		c.Storage.Bucket = bucket
+		return
+	}
+	c.Bucket = bucket
+}
+
+// MirroredBucket returns the previous bucket writes should still go to at
+// now, or "" once the grace window of a bucket move is over.
+func (c *Config) MirroredBucket(now time.Time) string {
+	if c.PreviousBucket == "" || !now.Before(c.PreviousBucketUntil) {
+		return ""
+	}
+	return c.PreviousBucket
+}
+
 // IsLegacyConfig returns true if using the legacy R2-only config format
 func (c *Config) IsLegacyConfig() bool {
 	return c.Storage == nil && c.AccountID != ""
 }
 
 // GetEffectiveSyncPaths returns the paths to sync: custom SyncPaths if set,
-// otherwise the scope-based defaults.
+// otherwise the scope-based defaults, with this machine's hosts overrides
+// applied.
 func (c *Config) GetEffectiveSyncPaths() []string {
 	if len(c.SyncPaths) > 0 {
-		return c.SyncPaths
+		return c.hostSyncPaths(c.SyncPaths)
 	}
-	return ScopedSyncPaths(c.Scope)
+	return c.ScopeSyncPaths()
+}
+
+// ScopeSyncPaths returns the paths the configured scope syncs, with this
+// machine's hosts overrides applied.
+func (c *Config) ScopeSyncPaths() []string {
+	return c.hostSyncPaths(ScopedSyncPaths(c.Scope))
 }
 
 // IsMCPSyncEnabled returns true if MCP sync is explicitly enabled.
@@ -279,7 +737,130 @@ func (c *Config) SetMCPSync(enabled bool) {
 	c.MCPSync = &enabled
 }
 
-// IsExcluded returns true if the given relative path matches any exclude pattern.
+// PushDeleteThreshold returns the configured delete_threshold, or
+// DefaultDeleteThreshold when unset. Zero means no limit.
+func (c *Config) PushDeleteThreshold() int {
+	if c.DeleteThreshold == nil {
+		return DefaultDeleteThreshold
+	}
+	if *c.DeleteThreshold < 0 {
+		return 0
+	}
+	return *c.DeleteThreshold
+}
+
+// StaleAfterDuration returns the configured stale_after, or DefaultStaleAfter
+// when unset. Zero means the warning is off.
+func (c *Config) StaleAfterDuration() (time.Duration, error) {
+	if c.StaleAfter == "" {
+		return DefaultStaleAfter, nil
+	}
+	d, err := ParseAge(c.StaleAfter)
+	if err != nil {
+		return 0, fmt.Errorf("invalid stale_after: %w", err)
+	}
+	return d, nil
+}
+
+// CheckPingURL reports whether ping_url, when set, is an http or https URL.
+func (c *Config) CheckPingURL() error {
+	if c.PingURL == "" {
+		return nil
+	}
+	u, err := url.Parse(c.PingURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid ping_url %q: want an http or https URL", c.PingURL)
+	}
+	return nil
+}
+
+// HashAlgorithmName returns the configured hash_algorithm, or HashSHA256 when
+// unset.
+func (c *Config) HashAlgorithmName() (string, error) {
+	switch c.HashAlgorithm {
+	case "":
+		return HashSHA256, nil
+	case HashSHA256, HashBLAKE3:
+		return c.HashAlgorithm, nil
+	}
+	return "", fmt.Errorf("invalid hash_algorithm %q: must be %s or %s", c.HashAlgorithm, HashSHA256, HashBLAKE3)
+}
+
+// CommandPrecedence returns the command sets in precedence order, highest
+// first, with CommandSetPersonal placed first unless command_namespaces
+// lists it. It returns nil when no namespaces are configured.
+func (c *Config) CommandPrecedence() ([]string, error) {
+	if len(c.CommandNamespaces) == 0 {
+		return nil, nil
+	}
+	seen := make(map[string]bool, len(c.CommandNamespaces))
+	for _, ns := range c.CommandNamespaces {
+		if ns == "" || ns == "." || ns == ".." || strings.ContainsAny(ns, `/\`) {
+			return nil, fmt.Errorf("invalid command_namespaces entry %q: must be a directory name under commands/", ns)
+		}
+		if seen[ns] {
+			return nil, fmt.Errorf("command_namespaces lists %q twice", ns)
+		}
+		seen[ns] = true
+	}
+	if seen[CommandSetPersonal] {
+		return c.CommandNamespaces, nil
+	}
+	return append([]string{CommandSetPersonal}, c.CommandNamespaces...), nil
+}
+
+// ReviewedCommandSets returns review_namespaces as a set, checking that each
+// is one of command_namespaces.
+func (c *Config) ReviewedCommandSets() (map[string]bool, error) {
+	if len(c.ReviewNamespaces) == 0 {
+		return nil, nil
+	}
+	shared := make(map[string]bool, len(c.CommandNamespaces))
+	for _, ns := range c.CommandNamespaces {
+		shared[ns] = true
+	}
+	reviewed := make(map[string]bool, len(c.ReviewNamespaces))
+	for _, ns := range c.ReviewNamespaces {
+		if !shared[ns] || ns == CommandSetPersonal {
+			return nil, fmt.Errorf("invalid review_namespaces entry %q: must be a shared set listed in command_namespaces", ns)
+		}
+		reviewed[ns] = true
+	}
+	return reviewed, nil
+}
+
+// VersionsMaxAgeDuration returns versions_max_age, or zero when unset.
+func (c *Config) VersionsMaxAgeDuration() (time.Duration, error) {
+	if c.VersionsMaxAge == "" {
+		return 0, nil
+	}
+	d, err := ParseAge(c.VersionsMaxAge)
+	if err != nil {
+		return 0, fmt.Errorf("invalid versions_max_age: %w", err)
+	}
+	return d, nil
+}
+
+// ParseAge parses an age such as "30d", "36h", or "90m". Days are whole
+// 24-hour days; anything else uses time.ParseDuration syntax.
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a valid age (use e.g. 30d, 36h)", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%q is not a valid age (use e.g. 30d, 36h)", s)
+	}
+	return d, nil
+}
+
+// IsExcluded returns true if the given relative path matches any exclude
+// pattern, including this machine's hosts overrides.
 // Patterns support:
 //   - Full doublestar glob syntax including ** for recursive matching
 //   - Examples: "**/.git/**", "*.tmp", "plugins/cache/**", "projects/*/node_modules/**"
@@ -289,7 +870,7 @@ func (c *Config) IsExcluded(relPath string) bool {
 	// Normalize path separators for consistent matching
 	relPath = filepath.ToSlash(relPath)
 
-	for _, pattern := range c.Exclude {
+	for _, pattern := range c.excludePatterns() {
 		// Normalize pattern separators
 		pattern = filepath.ToSlash(pattern)
 
@@ -4,9 +4,12 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/tawanorg/claude-sync/internal/crypto"
 	"github.com/tawanorg/claude-sync/internal/storage"
 )
 
@@ -79,6 +82,9 @@ func TestAgeKeyFilePath(t *testing.T) {
 }
 
 func TestClaudeDir(t *testing.T) {
+	t.Setenv(EnvClaudeDir, "")
+	t.Setenv(EnvClaudeConfigDir, "")
+	t.Setenv("HOME", t.TempDir())
 	path := ClaudeDir()
 	if path == "" {
 		t.Fatal("ClaudeDir should not return empty string")
@@ -89,6 +95,46 @@ func TestClaudeDir(t *testing.T) {
 	}
 }
 
+func TestClaudeDirOverrides(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv(EnvClaudeDir, "")
+	t.Setenv(EnvClaudeConfigDir, "")
+
+	if got := ClaudeDir(); got != filepath.Join(tmpDir, ".claude") {
+		t.Errorf("ClaudeDir() = %q, want ~/.claude", got)
+	}
+	if got := ClaudeJSONPath(); got != filepath.Join(tmpDir, ".claude.json") {
+		t.Errorf("ClaudeJSONPath() = %q, want ~/.claude.json", got)
+	}
+
+	t.Setenv(EnvClaudeConfigDir, filepath.Join(tmpDir, "claude-code"))
+	if got := ClaudeDir(); got != filepath.Join(tmpDir, "claude-code") {
+		t.Errorf("ClaudeDir() = %q, want CLAUDE_CONFIG_DIR", got)
+	}
+	if got := ClaudeJSONPath(); got != filepath.Join(tmpDir, "claude-code", ".claude.json") {
+		t.Errorf("ClaudeJSONPath() = %q, want it inside CLAUDE_CONFIG_DIR", got)
+	}
+
+	if err := os.MkdirAll(filepath.Join(tmpDir, ConfigDir), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ConfigFilePath(), []byte("claude_dir: ~/sync/claude\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got := ClaudeDir(); got != filepath.Join(tmpDir, "sync", "claude") {
+		t.Errorf("ClaudeDir() = %q, want claude_dir from config.yaml", got)
+	}
+	if got := ClaudeJSONPath(); got != filepath.Join(tmpDir, "sync", "claude", ".claude.json") {
+		t.Errorf("ClaudeJSONPath() = %q, want it inside claude_dir", got)
+	}
+
+	t.Setenv(EnvClaudeDir, filepath.Join(tmpDir, "env"))
+	if got := ClaudeDir(); got != filepath.Join(tmpDir, "env") {
+		t.Errorf("ClaudeDir() = %q, want %s", got, EnvClaudeDir)
+	}
+}
+
 func TestSaveAndLoad(t *testing.T) {
 	// Create a temporary directory to use as home
 	tmpDir := t.TempDir()
@@ -158,6 +204,127 @@ encryption_key_path: ~/.claude-sync/age-key.txt
 	}
 }
 
+func TestEncryptCredentials(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	if err := os.MkdirAll(filepath.Join(tmpDir, ConfigDir), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := crypto.GenerateKey(AgeKeyFilePath()); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{
+		EncryptionKey:      "~/.claude-sync/age-key.txt",
+		EncryptCredentials: true,
+		Storage: &storage.StorageConfig{
+			Provider:        storage.ProviderS3,
+			Bucket:          "test-bucket",
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "top-secret",
+		},
+		Report: &ReportConfig{SMTP: &SMTPConfig{Host: "smtp.example.com", Password: "mail-secret"}},
+	}
+	if err := Save(cfg); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if cfg.Storage.SecretAccessKey != "top-secret" {
+		t.Error("Expected Save to leave the config in memory alone")
+	}
+
+	data, err := os.ReadFile(ConfigFilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"top-secret", "mail-secret"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected %q encrypted in the file", secret)
+		}
+	}
+	if !strings.Contains(string(data), "AKIDEXAMPLE") {
+		t.Error("Expected the access key ID left readable")
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Storage.SecretAccessKey != "top-secret" || loaded.Report.SMTP.Password != "mail-secret" {
+		t.Errorf("Expected credentials decrypted, got %q, %q", loaded.Storage.SecretAccessKey, loaded.Report.SMTP.Password)
+	}
+
+	// Another key can't open them
+	if err := crypto.GenerateKey(AgeKeyFilePath()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(); err == nil {
+		t.Error("Expected Load to fail with another key")
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	if err := os.MkdirAll(filepath.Join(tmpDir, ConfigDir), 0700); err != nil {
+		t.Fatal(err)
+	}
+	// A legacy R2 config without secrets, as a CI job might ship
+	data := `account_id: test-account-id
+bucket: file-bucket
+encryption_key_path: ~/.claude-sync/age-key.txt
+`
+	if err := os.WriteFile(ConfigFilePath(), []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvBucket, "env-bucket")
+	t.Setenv(EnvAccessKeyID, "AKIDENV")
+	t.Setenv(EnvSecretAccessKey, "env-secret")
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	got := loaded.GetStorageConfig()
+	if got.Provider != storage.ProviderR2 || got.Bucket != "env-bucket" || got.AccessKeyID != "AKIDENV" || got.SecretAccessKey != "env-secret" {
+		t.Errorf("GetStorageConfig() = %+v, want the environment's bucket and keys", got)
+	}
+	if got.AccountID != "test-account-id" {
+		t.Errorf("AccountID = %q, want the file's", got.AccountID)
+	}
+	if names := loaded.EnvOverrides(); !reflect.DeepEqual(names, []string{EnvBucket, EnvAccessKeyID, EnvSecretAccessKey}) {
+		t.Errorf("EnvOverrides() = %v", names)
+	}
+
+	// Saving writes the file's values back, not the environment's
+	loaded.Storage.Region = "auto"
+	if err := Save(loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Storage.SecretAccessKey != "env-secret" {
+		t.Error("Expected Save to leave the config in memory alone")
+	}
+	saved, err := os.ReadFile(ConfigFilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"env-bucket", "AKIDENV", "env-secret"} {
+		if strings.Contains(string(saved), value) {
+			t.Errorf("Expected %q not written to the config file", value)
+		}
+	}
+
+	os.Unsetenv(EnvBucket)
+	os.Unsetenv(EnvAccessKeyID)
+	os.Unsetenv(EnvSecretAccessKey)
+	reloaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.GetStorageConfig(); got.Bucket != "file-bucket" || got.Region != "auto" || got.SecretAccessKey != "" {
+		t.Errorf("after Save, GetStorageConfig() = %+v", got)
+	}
+}
+
 func TestLoadNotFound(t *testing.T) {
 	tmpDir := t.TempDir()
 	t.Setenv("HOME", tmpDir)
@@ -227,6 +394,7 @@ func TestSyncPaths(t *testing.T) {
 }
 
 func TestClaudeJSONPath(t *testing.T) {
+	t.Setenv(EnvClaudeConfigDir, "")
 	path := ClaudeJSONPath()
 	if path == "" {
 		t.Fatal("ClaudeJSONPath should not return empty string")
@@ -514,3 +682,330 @@ func TestSetMCPSync(t *testing.T) {
 func boolPtr(b bool) *bool {
 	return &b
 }
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"14d", 14 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"0", 0, false},
+		{"-1d", 0, true},
+		{"soon", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v, err %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestStaleAfterDuration(t *testing.T) {
+	cfg := &Config{}
+	if d, err := cfg.StaleAfterDuration(); err != nil || d != DefaultStaleAfter {
+		t.Errorf("Expected default, got %v (%v)", d, err)
+	}
+	cfg.StaleAfter = "7d"
+	if d, err := cfg.StaleAfterDuration(); err != nil || d != 7*24*time.Hour {
+		t.Errorf("Expected 7 days, got %v (%v)", d, err)
+	}
+}
+
+func TestCheckPingURL(t *testing.T) {
+	for _, tc := range []struct {
+		url string
+		ok  bool
+	}{
+		{"", true},
+		{"https://hc-ping.com/0e4c1a2e-5b7d-4f3a-9c1e-2d8f6a7b9c0d", true},
+		{"http://monitor.lan:8000/ping/abc", true},
+		{"hc-ping.com/abc", false},
+		{"ftp://example.com/abc", false},
+	} {
+		cfg := &Config{PingURL: tc.url}
+		if err := cfg.CheckPingURL(); (err == nil) != tc.ok {
+			t.Errorf("CheckPingURL(%q) = %v, want ok %v", tc.url, err, tc.ok)
+		}
+	}
+}
+
+func TestPushDeleteThreshold(t *testing.T) {
+	cfg := &Config{}
+	if n := cfg.PushDeleteThreshold(); n != DefaultDeleteThreshold {
+		t.Errorf("Expected the default, got %d", n)
+	}
+	for _, tc := range []struct{ set, want int }{{5, 5}, {0, 0}, {-1, 0}} {
+		cfg.DeleteThreshold = &tc.set
+		if n := cfg.PushDeleteThreshold(); n != tc.want {
+			t.Errorf("delete_threshold %d: got %d, want %d", tc.set, n, tc.want)
+		}
+	}
+}
+
+func TestHashAlgorithmName(t *testing.T) {
+	cfg := &Config{}
+	if alg, err := cfg.HashAlgorithmName(); err != nil || alg != HashSHA256 {
+		t.Errorf("Expected sha256 by default, got %q (%v)", alg, err)
+	}
+	cfg.HashAlgorithm = HashBLAKE3
+	if alg, err := cfg.HashAlgorithmName(); err != nil || alg != HashBLAKE3 {
+		t.Errorf("Expected blake3, got %q (%v)", alg, err)
+	}
+	cfg.HashAlgorithm = "md5"
+	if _, err := cfg.HashAlgorithmName(); err == nil {
+		t.Error("Expected error for unknown algorithm")
+	}
+}
+
+func TestCommandPrecedence(t *testing.T) {
+	cfg := &Config{}
+	if order, err := cfg.CommandPrecedence(); err != nil || order != nil {
+		t.Errorf("Expected no precedence without namespaces, got %v, %v", order, err)
+	}
+
+	cfg.CommandNamespaces = []string{"team", "vendor"}
+	order, err := cfg.CommandPrecedence()
+	if err != nil || strings.Join(order, ",") != "personal,team,vendor" {
+		t.Errorf("Expected personal first, got %v, %v", order, err)
+	}
+
+	cfg.CommandNamespaces = []string{"team", "personal"}
+	order, err = cfg.CommandPrecedence()
+	if err != nil || strings.Join(order, ",") != "team,personal" {
+		t.Errorf("Expected the listed order, got %v, %v", order, err)
+	}
+
+	for _, bad := range [][]string{{"team/sub"}, {".."}, {"team", "team"}} {
+		cfg.CommandNamespaces = bad
+		if _, err := cfg.CommandPrecedence(); err == nil {
+			t.Errorf("Expected an error for %v", bad)
+		}
+	}
+}
+
+func TestSetBucketAndMirroredBucket(t *testing.T) {
+	legacy := &Config{AccountID: "acct", Bucket: "old"}
+	legacy.SetBucket("new")
+	if got := legacy.GetStorageConfig().Bucket; got != "new" {
+		t.Errorf("Legacy bucket = %q, want new", got)
+	}
+
+	cfg := &Config{Storage: &storage.StorageConfig{Provider: storage.ProviderS3, Bucket: "old"}}
+	cfg.SetBucket("new")
+	if got := cfg.GetStorageConfig().Bucket; got != "new" {
+		t.Errorf("Bucket = %q, want new", got)
+	}
+
+	now := time.Now()
+	if got := cfg.MirroredBucket(now); got != "" {
+		t.Errorf("Expected no mirrored bucket, got %q", got)
+	}
+	cfg.PreviousBucket = "old"
+	cfg.PreviousBucketUntil = now.Add(time.Hour)
+	if got := cfg.MirroredBucket(now); got != "old" {
+		t.Errorf("MirroredBucket = %q, want old", got)
+	}
+	if got := cfg.MirroredBucket(now.Add(2 * time.Hour)); got != "" {
+		t.Errorf("Expected grace window to end, got %q", got)
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	cfg := &Config{AccountID: "acct", Bucket: "old", EncryptionKey: "/k"}
+
+	if got, err := cfg.Get("storage.bucket"); err != nil || got != "old" {
+		t.Errorf("Get(storage.bucket) on a legacy config = %q, %v", got, err)
+	}
+	if err := cfg.Set("storage.region", "eu-west-1"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Storage == nil || cfg.Storage.Provider != storage.ProviderR2 || cfg.Storage.Bucket != "old" || cfg.Storage.Region != "eu-west-1" {
+		t.Errorf("Set(storage.region) left storage = %+v, want the legacy config migrated", cfg.Storage)
+	}
+	if cfg.Bucket != "" || cfg.AccountID != "" {
+		t.Error("Expected the legacy fields cleared once migrated")
+	}
+
+	for key, value := range map[string]string{
+		"versioning":           "true",
+		"versions_keep":        "5",
+		"delete_threshold":     "0",
+		"exclude":              "plugins/**/node_modules, *.log",
+		"storage.kms.provider": "aws",
+	} {
+		if err := cfg.Set(key, value); err != nil {
+			t.Errorf("Set(%s, %q): %v", key, value, err)
+		}
+	}
+	if !cfg.Versioning || cfg.VersionsKeep != 5 || cfg.PushDeleteThreshold() != 0 || cfg.Storage.KMS == nil || cfg.Storage.KMS.Provider != "aws" {
+		t.Errorf("Set didn't take: %+v", cfg)
+	}
+	if got, _ := cfg.Get("exclude"); got != "plugins/**/node_modules,*.log" {
+		t.Errorf("Get(exclude) = %q", got)
+	}
+	if got, _ := cfg.Get("delete_threshold"); got != "0" {
+		t.Errorf("Get(delete_threshold) = %q, want 0", got)
+	}
+	if err := cfg.Set("delete_threshold", ""); err != nil || cfg.DeleteThreshold != nil {
+		t.Errorf("Set(delete_threshold, \"\") = %v, left %v", err, cfg.DeleteThreshold)
+	}
+	if got, err := cfg.Get("report.period"); err != nil || got != "" {
+		t.Errorf("Get of an unset section = %q, %v", got, err)
+	}
+
+	for key, value := range map[string]string{
+		"bucketz":       "x",
+		"storage.nope":  "x",
+		"versioning.on": "x",
+		"versions_keep": "many",
+		"trash":         "maybe",
+		"path_map":      "x",
+		"storage":       "x",
+	} {
+		if err := cfg.Set(key, value); err == nil {
+			t.Errorf("Set(%s, %q) succeeded", key, value)
+		}
+	}
+}
+
+func TestHostOverrides(t *testing.T) {
+	defer func(h func() (string, error)) { hostname = h }(hostname)
+	hostname = func() (string, error) { return "Tiny-Laptop.local", nil }
+
+	cfg := &Config{
+		Scope:   ScopeSessions,
+		Exclude: []string{"*.tmp", "plans/big"},
+		Hosts: []HostOverride{
+			{Host: "desktop", AddPaths: []string{"skills"}},
+			{Host: "tiny-*", RemovePaths: []string{"projects"}, AddPaths: []string{"agents"},
+				Exclude: []string{"history.jsonl"}, Unexclude: []string{"plans/big"}},
+		},
+	}
+
+	if got := cfg.HostOverrides(); len(got) != 1 || got[0].Host != "tiny-*" {
+		t.Fatalf("HostOverrides() = %+v, want the tiny-* entry", got)
+	}
+	want := []string{"history.jsonl", "tasks", "plans", "agents"}
+	if got := cfg.ScopeSyncPaths(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ScopeSyncPaths() = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(SessionSyncPaths, []string{"projects", "history.jsonl", "tasks", "plans"}) {
+		t.Error("Expected the default session paths left alone")
+	}
+	if !cfg.IsExcluded("history.jsonl") || !cfg.IsExcluded("a.tmp") || cfg.IsExcluded("plans/big/x.md") {
+		t.Error("Expected the host's exclude and unexclude applied on top of exclude")
+	}
+
+	hostname = func() (string, error) { return "desktop", nil }
+	if got := cfg.ScopeSyncPaths(); !reflect.DeepEqual(got, []string{"projects", "history.jsonl", "tasks", "plans", "skills"}) {
+		t.Errorf("ScopeSyncPaths() on desktop = %v", got)
+	}
+	if cfg.IsExcluded("history.jsonl") || !cfg.IsExcluded("plans/big/x.md") {
+		t.Error("Expected another host's overrides not to apply")
+	}
+}
+
+func TestCredentialsCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv(EnvSecretAccessKey, "")
+	t.Setenv(EnvAccessKeyID, "")
+	if err := os.MkdirAll(filepath.Join(tmpDir, ConfigDir), 0700); err != nil {
+		t.Fatal(err)
+	}
+	write := func(command string) {
+		t.Helper()
+		cfg := &Config{
+			Storage: &storage.StorageConfig{
+				Provider:           storage.ProviderR2,
+				Bucket:             "bucket",
+				AccountID:          "acct",
+				AccessKeyID:        "AKIDFILE",
+				CredentialsCommand: command,
+			},
+			EncryptionKey: "~/.claude-sync/age-key.txt",
+		}
+		if err := Save(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Plain output is the secret, once storage is opened
+	marker := filepath.Join(tmpDir, "ran")
+	write("touch " + marker + "; echo s3cret")
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if _, err := os.Stat(marker); err == nil || loaded.Storage.SecretAccessKey != "" {
+		t.Fatal("Expected Load not to run the command")
+	}
+	if err := loaded.Storage.Validate(); err != nil {
+		t.Errorf("Validate before the command ran: %v", err)
+	}
+	storageCopy := *loaded.Storage
+	if err := storageCopy.ResolveCredentials(); err != nil {
+		t.Fatalf("ResolveCredentials failed: %v", err)
+	}
+	if err := loaded.Storage.ResolveCredentials(); err != nil {
+		t.Fatalf("ResolveCredentials failed: %v", err)
+	}
+	for _, s := range []*storage.StorageConfig{&storageCopy, loaded.Storage} {
+		if s.SecretAccessKey != "s3cret" || s.AccessKeyID != "AKIDFILE" {
+			t.Errorf("storage = %+v, want the command's secret and the file's key ID", s)
+		}
+	}
+	if got, _ := loaded.Get("storage.secret_access_key"); got != hiddenCredential {
+		t.Errorf("config get printed the secret: %q", got)
+	}
+	if got, _ := loaded.Get("storage"); !strings.Contains(got, "secret_access_key: "+hiddenCredential) {
+		t.Errorf("config get storage printed the secret:\n%s", got)
+	}
+	if err := Save(loaded); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := os.ReadFile(ConfigFilePath()); strings.Contains(string(saved), "secret_access_key") {
+		t.Error("Expected the command's secret not written to the config file")
+	}
+
+	// JSON, as from an AWS credential_process
+	write(`echo '{"Version": "1", "AccessKeyId": "AKIDCMD", "SecretAccessKey": "cmd-secret"}'`)
+	loaded, err = Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := loaded.Storage.ResolveCredentials(); err != nil {
+		t.Fatalf("ResolveCredentials failed: %v", err)
+	}
+	if loaded.Storage.SecretAccessKey != "cmd-secret" || loaded.Storage.AccessKeyID != "AKIDCMD" {
+		t.Errorf("storage = %+v, want the command's keys", loaded.Storage)
+	}
+
+	// The environment wins, without running the command
+	t.Setenv(EnvSecretAccessKey, "env-secret")
+	write("exit 1")
+	if loaded, err = Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := loaded.Storage.ResolveCredentials(); err != nil {
+		t.Fatalf("Ran the command: %v", err)
+	}
+	if loaded.Storage.SecretAccessKey != "env-secret" {
+		t.Errorf("SecretAccessKey = %q, want the environment's", loaded.Storage.SecretAccessKey)
+	}
+	t.Setenv(EnvSecretAccessKey, "")
+	if loaded, err = Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if err := loaded.Storage.ResolveCredentials(); err == nil || !strings.Contains(err.Error(), "credentials_command") {
+		t.Errorf("ResolveCredentials with a failing command = %v", err)
+	}
+}
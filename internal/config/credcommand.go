@@ -0,0 +1,121 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// credentialsCommandTimeout bounds how long credentials_command may take,
+// including any prompt it shows.
+const credentialsCommandTimeout = 2 * time.Minute
+
+// commandCredentials maps the names credentials_command may print in a JSON
+// object to the settings they supply. AccessKeyId and SecretAccessKey are
+// what an AWS credential_process prints.
+var commandCredentials = map[string]func(*storage.StorageConfig) *string{
+	"access_key_id":     func(s *storage.StorageConfig) *string { return &s.AccessKeyID },
+	"AccessKeyId":       func(s *storage.StorageConfig) *string { return &s.AccessKeyID },
+	"secret_access_key": func(s *storage.StorageConfig) *string { return &s.SecretAccessKey },
+	"SecretAccessKey":   func(s *storage.StorageConfig) *string { return &s.SecretAccessKey },
+	"webdav_username":   func(s *storage.StorageConfig) *string { return &s.WebDAVUsername },
+	"webdav_password":   func(s *storage.StorageConfig) *string { return &s.WebDAVPassword },
+	"credentials_json":  func(s *storage.StorageConfig) *string { return &s.CredentialsJSON },
+}
+
+// runCredentialsCommand runs storage.credentials_command and takes the
+// credentials it prints: either a JSON object with any of the names in
+// commandCredentials, or just the secret (the secret access key, WebDAV
+// password, or GCS service account JSON) on its own. Like environment
+// overrides, Save never writes them out, and a setting the environment
+// already overrides is left to it; if the secret is one, the command isn't
+// run at all.
+func runCredentialsCommand(c *Config) error {
+	if c.Storage == nil || c.Storage.CredentialsCommand == "" {
+		return nil
+	}
+	secret := secretField(c.Storage.Provider)
+	if c.overridden(secret) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), credentialsCommandTimeout)
+	defer cancel()
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	cmd := exec.CommandContext(ctx, shell, flag, c.Storage.CredentialsCommand)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("credentials_command failed: %w", err)
+	}
+
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return fmt.Errorf("credentials_command printed nothing")
+	}
+	values := map[string]string{}
+	if out[0] != '{' || json.Unmarshal(out, &values) != nil {
+		c.override("", secret, string(out))
+		return nil
+	}
+	set := 0
+	for name, value := range values {
+		field, ok := commandCredentials[name]
+		if !ok || value == "" {
+			continue
+		}
+		if !c.overridden(field) {
+			c.override("", field, value)
+		}
+		set++
+	}
+	if set > 0 {
+		return nil
+	}
+	// A GCS service account key is a JSON object of its own
+	if c.Storage.Provider == storage.ProviderGCS {
+		c.override("", secret, string(out))
+		return nil
+	}
+	return fmt.Errorf("credentials_command printed JSON without credentials (expected access_key_id, secret_access_key, webdav_username, webdav_password or credentials_json)")
+}
+
+// commandCredentials resolves the storage config's credentials when storage
+// is first opened: it runs credentials_command once, into c.Storage, and
+// gives target, which may be a copy, what the command supplied.
+func (c *Config) commandCredentials(target *storage.StorageConfig) error {
+	if !c.credentialsRan {
+		if err := runCredentialsCommand(c); err != nil {
+			return err
+		}
+		c.credentialsRan = true
+	}
+	for _, o := range c.envOverrides {
+		if o.env == "" {
+			*o.field(target) = o.used
+		}
+	}
+	return nil
+}
+
+// secretField returns the setting holding provider's secret.
+func secretField(provider storage.Provider) func(*storage.StorageConfig) *string {
+	switch provider {
+	case storage.ProviderWebDAV:
+		return func(s *storage.StorageConfig) *string { return &s.WebDAVPassword }
+	case storage.ProviderGCS:
+		return func(s *storage.StorageConfig) *string { return &s.CredentialsJSON }
+	}
+	return func(s *storage.StorageConfig) *string { return &s.SecretAccessKey }
+}
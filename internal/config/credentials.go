@@ -0,0 +1,126 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+)
+
+// sealedPrefix marks a credential encrypted with the age key
+// (encrypt_credentials).
+const sealedPrefix = "age:"
+
+// credentials returns the config's secrets: storage keys and passwords.
+func (c *Config) credentials() []*string {
+	fields := []*string{&c.SecretAccessKey}
+	if c.Storage != nil {
+		fields = append(fields, &c.Storage.SecretAccessKey, &c.Storage.CredentialsJSON, &c.Storage.WebDAVPassword)
+	}
+	if c.Report != nil && c.Report.SMTP != nil {
+		fields = append(fields, &c.Report.SMTP.Password)
+	}
+	for i := range c.Notify {
+		if c.Notify[i].SMTP != nil {
+			fields = append(fields, &c.Notify[i].SMTP.Password)
+		}
+	}
+	return fields
+}
+
+// keyEncryptor opens the age key credentials are sealed with: this device's
+// key file, wherever it keeps the key (including the OS keychain).
+func (c *Config) keyEncryptor() (*crypto.Encryptor, error) {
+	keyPath := c.EncryptionKey
+	if strings.HasPrefix(keyPath, "~") {
+		home, _ := os.UserHomeDir()
+		keyPath = filepath.Join(home, keyPath[1:])
+	}
+	enc, err := crypto.NewEncryptor(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt_credentials needs the age key: %w", err)
+	}
+	return enc, nil
+}
+
+// credentialsCopy returns a copy of c whose credentials can be changed
+// without changing c's.
+func (c *Config) credentialsCopy() *Config {
+	cp := *c
+	if c.Storage != nil {
+		storageCfg := *c.Storage
+		cp.Storage = &storageCfg
+	}
+	if c.Report != nil {
+		report := *c.Report
+		if c.Report.SMTP != nil {
+			smtp := *c.Report.SMTP
+			report.SMTP = &smtp
+		}
+		cp.Report = &report
+	}
+	cp.Notify = make([]NotifierConfig, len(c.Notify))
+	for i, n := range c.Notify {
+		if n.SMTP != nil {
+			smtp := *n.SMTP
+			n.SMTP = &smtp
+		}
+		cp.Notify[i] = n
+	}
+	return &cp
+}
+
+// sealCredentials returns a copy of c, to be written out, with each
+// credential encrypted to the age key.
+func sealCredentials(c *Config) (*Config, error) {
+	sealed := c.credentialsCopy()
+
+	var enc *crypto.Encryptor
+	for _, field := range sealed.credentials() {
+		if *field == "" || strings.HasPrefix(*field, sealedPrefix) {
+			continue
+		}
+		if enc == nil {
+			var err error
+			if enc, err = c.keyEncryptor(); err != nil {
+				return nil, err
+			}
+		}
+		ciphertext, err := enc.Encrypt([]byte(*field))
+		if err != nil {
+			return nil, err
+		}
+		*field = sealedPrefix + base64.StdEncoding.EncodeToString(ciphertext)
+	}
+	return sealed, nil
+}
+
+// openCredentials decrypts the sealed credentials in c in place.
+func openCredentials(c *Config) error {
+	var enc *crypto.Encryptor
+	for _, field := range c.credentials() {
+		encoded, ok := strings.CutPrefix(*field, sealedPrefix)
+		if !ok {
+			continue
+		}
+		if enc == nil {
+			var err error
+			if enc, err = c.keyEncryptor(); err != nil {
+				return err
+			}
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("invalid encrypted credential: %w", err)
+		}
+		plaintext, err := enc.Decrypt(ciphertext)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt credentials (was the age key replaced?): %w", err)
+		}
+		*field = string(plaintext)
+	}
+	return nil
+}
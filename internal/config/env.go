@@ -0,0 +1,111 @@
+package config
+
+import (
+	"os"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// Environment variables that override the storage settings in config.yaml
+// when it is loaded, so CI jobs and containers can push and pull with a
+// config file that holds no secrets. Save never writes their values out.
+const (
+	EnvProvider        = "CLAUDE_SYNC_PROVIDER"
+	EnvBucket          = "CLAUDE_SYNC_BUCKET"
+	EnvAccessKeyID     = "CLAUDE_SYNC_ACCESS_KEY_ID"
+	EnvSecretAccessKey = "CLAUDE_SYNC_SECRET_ACCESS_KEY"
+)
+
+// EnvClaudeDir overrides claude_dir, the Claude directory to sync.
+// EnvClaudeConfigDir is Claude Code's own setting for where that directory
+// is, followed when neither is set.
+const (
+	EnvClaudeDir       = "CLAUDE_SYNC_CLAUDE_DIR"
+	EnvClaudeConfigDir = "CLAUDE_CONFIG_DIR"
+)
+
+// envField is a storage setting an environment variable overrides.
+type envField struct {
+	env   string
+	field func(*storage.StorageConfig) *string
+}
+
+var envFields = []envField{
+	{EnvProvider, func(s *storage.StorageConfig) *string { return (*string)(&s.Provider) }},
+	{EnvBucket, func(s *storage.StorageConfig) *string { return &s.Bucket }},
+	{EnvAccessKeyID, func(s *storage.StorageConfig) *string { return &s.AccessKeyID }},
+	{EnvSecretAccessKey, func(s *storage.StorageConfig) *string { return &s.SecretAccessKey }},
+}
+
+// envOverride is a setting replaced at load time, by an environment
+// variable (env) or credentials_command (env empty): its value in the file
+// and the value it was given.
+type envOverride struct {
+	env        string
+	field      func(*storage.StorageConfig) *string
+	file, used string
+}
+
+// override sets field to value, recording the file's value for Save.
+func (c *Config) override(env string, field func(*storage.StorageConfig) *string, value string) {
+	target := field(c.Storage)
+	c.envOverrides = append(c.envOverrides, envOverride{env: env, field: field, file: *target, used: value})
+	*target = value
+}
+
+// overridden reports whether field has been replaced already.
+func (c *Config) overridden(field func(*storage.StorageConfig) *string) bool {
+	for _, o := range c.envOverrides {
+		if o.field(c.Storage) == field(c.Storage) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyEnv overrides c's storage settings with the environment variables
+// that are set, recording what it replaced in c.envOverrides. A legacy
+// R2-only config is moved into the storage block first.
+func applyEnv(c *Config) {
+	for _, f := range envFields {
+		value := os.Getenv(f.env)
+		if value == "" {
+			continue
+		}
+		if c.Storage == nil || c.Storage.Provider == "" {
+			c.Storage = c.GetStorageConfig()
+		}
+		c.override(f.env, f.field, value)
+	}
+}
+
+// EnvOverrides returns the environment variables that replaced settings
+// from config.yaml, for commands to mention.
+func (c *Config) EnvOverrides() []string {
+	names := make([]string, 0, len(c.envOverrides))
+	for _, o := range c.envOverrides {
+		if o.env != "" {
+			names = append(names, o.env)
+		}
+	}
+	return names
+}
+
+// withoutEnv returns c as it should be written back: a copy in which each
+// setting still holding its environment (or credentials_command) value has
+// its file value again.
+// Settings changed since loading are written as they are.
+func (c *Config) withoutEnv() *Config {
+	if len(c.envOverrides) == 0 {
+		return c
+	}
+	restored := *c
+	storageCfg := *c.Storage
+	restored.Storage = &storageCfg
+	for _, o := range c.envOverrides {
+		if field := o.field(restored.Storage); *field == o.used {
+			*field = o.file
+		}
+	}
+	return &restored
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// HostOverride changes what one machine syncs, for devices that shouldn't
+// hold everything (a small laptop skipping projects/, say). Host is matched
+// against this machine's hostname, which is also its device ID in the sync
+// state, with or without the domain; it may be a glob such as "laptop-*".
+// Every matching entry applies, in order.
+type HostOverride struct {
+	Host string `yaml:"host"`
+
+	// AddPaths and RemovePaths add paths under ~/.claude to the sync paths
+	// and take them out
+	AddPaths    []string `yaml:"add_paths,omitempty"`
+	RemovePaths []string `yaml:"remove_paths,omitempty"`
+
+	// Exclude adds exclude patterns; Unexclude drops patterns from the
+	// top-level exclude list
+	Exclude   []string `yaml:"exclude,omitempty"`
+	Unexclude []string `yaml:"unexclude,omitempty"`
+}
+
+// hostname is os.Hostname, replaced in tests.
+var hostname = os.Hostname
+
+// Matches reports whether the override applies to the machine named host.
+func (h HostOverride) Matches(host string) bool {
+	pattern := strings.ToLower(h.Host)
+	host = strings.ToLower(host)
+	short, _, _ := strings.Cut(host, ".")
+	for _, name := range []string{host, short} {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// HostOverrides returns the hosts entries that apply to this machine.
+func (c *Config) HostOverrides() []HostOverride {
+	if len(c.Hosts) == 0 {
+		return nil
+	}
+	host, err := hostname()
+	if err != nil || host == "" {
+		return nil
+	}
+	var matched []HostOverride
+	for _, h := range c.Hosts {
+		if h.Matches(host) {
+			matched = append(matched, h)
+		}
+	}
+	return matched
+}
+
+// hostSyncPaths returns paths with this machine's overrides applied.
+func (c *Config) hostSyncPaths(paths []string) []string {
+	overrides := c.HostOverrides()
+	if len(overrides) == 0 {
+		return paths
+	}
+	result := slices.Clone(paths)
+	for _, h := range overrides {
+		result = slices.DeleteFunc(result, func(p string) bool {
+			return slices.Contains(h.RemovePaths, p)
+		})
+		for _, p := range h.AddPaths {
+			if !slices.Contains(result, p) {
+				result = append(result, p)
+			}
+		}
+	}
+	return result
+}
+
+// excludePatterns returns the exclude patterns with this machine's
+// overrides applied.
+func (c *Config) excludePatterns() []string {
+	overrides := c.HostOverrides()
+	if len(overrides) == 0 {
+		return c.Exclude
+	}
+	patterns := slices.Clone(c.Exclude)
+	for _, h := range overrides {
+		patterns = slices.DeleteFunc(patterns, func(p string) bool {
+			return slices.Contains(h.Unexclude, p)
+		})
+		patterns = append(patterns, h.Exclude...)
+	}
+	return patterns
+}
+
+// SkippedOnHost reports whether relPath is synced by the config but not on
+// this machine because of its hosts overrides. Push leaves the remote copy
+// of such a path alone rather than deleting it, and pull doesn't fetch it.
+func (c *Config) SkippedOnHost(relPath string) bool {
+	if len(c.HostOverrides()) == 0 {
+		return false
+	}
+	shared := *c
+	shared.Hosts = nil
+	return shared.syncs(relPath) && !c.syncs(relPath)
+}
+
+// syncs reports whether relPath is under the sync paths and not excluded.
+func (c *Config) syncs(relPath string) bool {
+	if c.IsExcluded(relPath) {
+		return false
+	}
+	relPath = filepath.ToSlash(relPath)
+	for _, p := range c.ScopeSyncPaths() {
+		if relPath == p || strings.HasPrefix(relPath, p+"/") {
+			return true
+		}
+	}
+	return false
+}
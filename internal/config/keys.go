@@ -0,0 +1,215 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// hiddenCredential stands in for a credential's value in Get.
+const hiddenCredential = "(hidden)"
+
+// Get returns the setting at key, a dotted path of config.yaml names such as
+// "storage.bucket", for 'claude-sync config get'. Lists are comma-separated,
+// sections and maps come back as YAML, and unset settings are "". Storage
+// settings of a legacy R2-only config are read as if it had been migrated.
+// Credentials that are set read as hiddenCredential, alone or in a section.
+func (c *Config) Get(key string) (string, error) {
+	view := c.credentialsCopy()
+	if strings.HasPrefix(key, "storage.") || key == "storage" {
+		view.Storage = view.GetStorageConfig()
+	}
+	for _, field := range view.credentials() {
+		if *field != "" {
+			*field = hiddenCredential
+		}
+	}
+	v, err := lookupKey(reflect.ValueOf(view).Elem(), key, false)
+	if err != nil || !v.IsValid() {
+		return "", err
+	}
+	return formatValue(v)
+}
+
+// Set changes the setting at key from its string form, for 'claude-sync
+// config set': "true"/"false" for switches, a comma-separated list for lists,
+// and "" to clear. Sections and maps can't be set this way. Setting a
+// storage value migrates a legacy R2-only config to the storage section.
+func (c *Config) Set(key, value string) error {
+	if strings.HasPrefix(key, "storage.") && (c.Storage == nil || c.Storage.Provider == "") {
+		c.Storage = c.GetStorageConfig()
+		if c.Storage.Endpoint == storage.R2Endpoint(c.AccountID, "") {
+			// Load's default, which would pin the global endpoint over a
+			// jurisdiction or custom_domain set later
+			c.Storage.Endpoint = ""
+		}
+		c.AccountID, c.AccessKeyID, c.SecretAccessKey, c.Bucket, c.Endpoint = "", "", "", "", ""
+	}
+	v, err := lookupKey(reflect.ValueOf(c).Elem(), key, true)
+	if err != nil {
+		return err
+	}
+	if err := parseValue(v, value); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// lookupKey walks key's segments down from v by yaml names. With create,
+// nil sections on the way are allocated; without, an unset one ends the walk
+// with an invalid Value.
+func lookupKey(v reflect.Value, key string, create bool) (reflect.Value, error) {
+	if key == "" {
+		return reflect.Value{}, fmt.Errorf("no setting given")
+	}
+	walked := ""
+	for _, name := range strings.Split(key, ".") {
+		if v.Kind() == reflect.Pointer && v.Type().Elem().Kind() == reflect.Struct {
+			if v.IsNil() {
+				if !create {
+					return reflect.Value{}, nil
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct || v.Type() == timeType {
+			return reflect.Value{}, fmt.Errorf("%s is not a section", walked)
+		}
+		field, ok := yamlField(v, name)
+		if !ok {
+			if walked == "" {
+				return reflect.Value{}, fmt.Errorf("unknown setting %q", key)
+			}
+			return reflect.Value{}, fmt.Errorf("unknown setting %q: %s has no %s", key, walked, name)
+		}
+		v = field
+		walked = strings.TrimPrefix(walked+"."+name, ".")
+	}
+	return v, nil
+}
+
+// yamlField returns the field of struct v that config.yaml calls name.
+func yamlField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if tag != "-" && tag != "" && tag == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func formatValue(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil
+		}
+		if v.Elem().Kind() != reflect.Struct {
+			v = v.Elem()
+		}
+	}
+	switch {
+	case v.Type() == timeType:
+		if t := v.Interface().(time.Time); !t.IsZero() {
+			return t.Format(time.RFC3339), nil
+		}
+		return "", nil
+	case v.Kind() == reflect.String:
+		return v.String(), nil
+	case v.Kind() == reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case v.Kind() == reflect.Int:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = v.Index(i).String()
+		}
+		return strings.Join(items, ","), nil
+	}
+	data, err := yaml.Marshal(v.Interface())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+func parseValue(v reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+	if v.Kind() == reflect.Pointer && v.Type().Elem().Kind() != reflect.Struct {
+		if value == "" {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		elem := reflect.New(v.Type().Elem())
+		if err := parseValue(elem.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	switch {
+	case v.Type() == timeType:
+		var t time.Time
+		if value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return fmt.Errorf("%q is not an RFC 3339 time", value)
+			}
+			t = parsed
+		}
+		v.Set(reflect.ValueOf(t))
+	case v.Kind() == reflect.String:
+		v.SetString(value)
+	case v.Kind() == reflect.Bool:
+		b := false
+		if value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%q is not true or false", value)
+			}
+			b = parsed
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n := 0
+		if value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%q is not a number", value)
+			}
+			n = parsed
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		list := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			list.Index(i).SetString(item)
+		}
+		v.Set(list)
+	default:
+		return fmt.Errorf("it can't be set from the command line; edit %s", ConfigFile)
+	}
+	return nil
+}
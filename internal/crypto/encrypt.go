@@ -2,45 +2,251 @@ package crypto
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
 
 	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"filippo.io/age/plugin"
 	"github.com/btcsuite/btcd/btcutil/bech32"
 	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/curve25519"
 )
 
 type Encryptor struct {
-	identity  *age.X25519Identity
-	recipient *age.X25519Recipient
+	identity  age.Identity
+	recipient age.Recipient
+	publicKey string
+
+	// extra are additional recipients every object is also encrypted to,
+	// such as a recovery key or teammates sharing the bucket
+	extra []age.Recipient
+
+	// others are further identities from the key file, tried when
+	// decrypting; files are only encrypted to the first one
+	others []age.Identity
+
+	// services are key management services file keys are also wrapped
+	// with (envelope encryption)
+	services []KeyService
+
+	// signer is derived from the identity's secret; nil for plugin identities
+	signer ed25519.PrivateKey
 }
 
+// KeyPassphrase is asked for the passphrase of a protected key file: an age
+// key encrypted at rest (ProtectKey) or a passphrase-protected SSH key. The
+// CLI sets it to a terminal prompt; when nil, protected keys can't be used.
+var KeyPassphrase func(keyPath string) ([]byte, error)
+
 func NewEncryptor(keyPath string) (*Encryptor, error) {
 	data, err := os.ReadFile(keyPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read age key: %w", err)
 	}
+	if isSSHPrivateKey(data) {
+		return newSSHEncryptor(keyPath, data)
+	}
+	if isProtectedKey(data) {
+		if data, err = unlockKey(keyPath, data); err != nil {
+			return nil, err
+		}
+	}
+	if account, ok := isKeychainStub(data); ok {
+		if data, err = keychainKey(account); err != nil {
+			return nil, err
+		}
+	}
+	if _, _, ok := parsePluginKeyFile(data); ok {
+		return newPluginEncryptor(data)
+	}
+
+	identities, err := parseAgeIdentities(data)
+	if err != nil {
+		return nil, err
+	}
+	e := newAgeEncryptor(identities[0])
+	for _, identity := range identities[1:] {
+		e.others = append(e.others, identity)
+	}
+	return e, nil
+}
 
-	// Parse the identity (private key)
-	identity, err := age.ParseX25519Identity(strings.TrimSpace(string(data)))
+// parseAgeIdentities parses an age identity file as age-keygen writes it:
+// one or more AGE-SECRET-KEY-1 lines, with # comments and blank lines.
+func parseAgeIdentities(data []byte) ([]*age.X25519Identity, error) {
+	parsed, err := age.ParseIdentities(bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse age identity: %w", err)
 	}
+	identities := make([]*age.X25519Identity, 0, len(parsed))
+	for _, identity := range parsed {
+		x, ok := identity.(*age.X25519Identity)
+		if !ok {
+			return nil, fmt.Errorf("failed to parse age identity: unsupported identity type %T", identity)
+		}
+		identities = append(identities, x)
+	}
+	return identities, nil
+}
 
+func newAgeEncryptor(identity *age.X25519Identity) *Encryptor {
 	return &Encryptor{
 		identity:  identity,
 		recipient: identity.Recipient(),
-	}, nil
+		publicKey: identity.Recipient().String(),
+		signer:    newSigningKey([]byte(identity.String())),
+	}
+}
+
+// NewEncryptorWithRecipients is NewEncryptor, also encrypting to each of
+// recipients (age public keys). Decryption still uses the key at keyPath.
+func NewEncryptorWithRecipients(keyPath string, recipients []string) (*Encryptor, error) {
+	e, err := NewEncryptor(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.addRecipients(recipients); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// NewEncryptorFromIdentity is NewEncryptorWithRecipients for an age identity
+// ("AGE-SECRET-KEY-1...") held in memory rather than in a key file.
+func NewEncryptorFromIdentity(secret string, recipients []string) (*Encryptor, error) {
+	identity, err := age.ParseX25519Identity(strings.TrimSpace(secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age identity: %w", err)
+	}
+	e := newAgeEncryptor(identity)
+	if err := e.addRecipients(recipients); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *Encryptor) addRecipients(recipients []string) error {
+	for _, r := range recipients {
+		recipient, err := ParseRecipient(r)
+		if err != nil {
+			return err
+		}
+		e.extra = append(e.extra, recipient)
+	}
+	return nil
+}
+
+// GenerateIdentity returns a new random age identity as a string.
+func GenerateIdentity() (string, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate age key: %w", err)
+	}
+	return identity.String(), nil
+}
+
+// SecretKey returns the key as an age identity string. Only age keys have
+// one: SSH and plugin identities can't be handed to another device.
+func (e *Encryptor) SecretKey() (string, error) {
+	identity, ok := e.identity.(*age.X25519Identity)
+	if !ok {
+		return "", fmt.Errorf("only age keys can be shared, not SSH or plugin identities")
+	}
+	return identity.String(), nil
+}
+
+// EncryptTo encrypts plaintext to recipients (see ParseRecipient) alone.
+func EncryptTo(plaintext []byte, recipients []string) ([]byte, error) {
+	e := &Encryptor{}
+	if err := e.addRecipients(recipients); err != nil {
+		return nil, err
+	}
+	if len(e.extra) == 0 {
+		return nil, fmt.Errorf("no recipients to encrypt to")
+	}
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, e.extra...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encryption writer: %w", err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, fmt.Errorf("failed to write encrypted data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize encryption: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseRecipient parses an age public key ("age1..."), an age plugin
+// recipient ("age1yubikey1...") or an SSH public key ("ssh-ed25519 ..." or
+// "ssh-rsa ...", as in an authorized_keys line).
+func ParseRecipient(s string) (age.Recipient, error) {
+	if isPluginRecipient(strings.TrimSpace(s)) {
+		r, err := plugin.NewRecipient(strings.TrimSpace(s), pluginUI())
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", s, err)
+		}
+		return serialRecipient{r}, nil
+	}
+	if strings.HasPrefix(strings.TrimSpace(s), "ssh-") {
+		r, err := agessh.ParseRecipient(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", s, err)
+		}
+		return r, nil
+	}
+	r, err := age.ParseX25519Recipient(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", s, err)
+	}
+	return r, nil
+}
+
+// KeyService is a key management service such as AWS KMS (see
+// internal/kms) that wraps file keys alongside the age key.
+type KeyService interface {
+	age.Recipient
+	age.Identity
+}
+
+// WithKeyService returns a copy of e that also wraps each file's key with
+// ks, and lets ks unwrap it when the age key can't. Files stay readable
+// with the age key alone, so a service that can't be reached only matters
+// to devices without the key.
+func (e *Encryptor) WithKeyService(ks KeyService) *Encryptor {
+	c := *e
+	c.services = append(append([]KeyService(nil), e.services...), ks)
+	return &c
+}
+
+func (e *Encryptor) recipients() []age.Recipient {
+	recipients := append([]age.Recipient{e.recipient}, e.extra...)
+	for _, ks := range e.services {
+		recipients = append(recipients, ks)
+	}
+	return recipients
+}
+
+func (e *Encryptor) identities() []age.Identity {
+	identities := append([]age.Identity{e.identity}, e.others...)
+	for _, ks := range e.services {
+		identities = append(identities, ks)
+	}
+	return identities
 }
 
 func (e *Encryptor) Encrypt(plaintext []byte) ([]byte, error) {
 	var buf bytes.Buffer
 
-	w, err := age.Encrypt(&buf, e.recipient)
+	w, err := age.Encrypt(&buf, e.recipients()...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create encryption writer: %w", err)
 	}
@@ -57,7 +263,7 @@ func (e *Encryptor) Encrypt(plaintext []byte) ([]byte, error) {
 }
 
 func (e *Encryptor) Decrypt(ciphertext []byte) ([]byte, error) {
-	r, err := age.Decrypt(bytes.NewReader(ciphertext), e.identity)
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), e.identities()...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt: %w", err)
 	}
@@ -70,8 +276,53 @@ func (e *Encryptor) Decrypt(ciphertext []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
+// IsWrongKey reports whether err is a decryption failing because the data
+// wasn't encrypted to this key, rather than being damaged.
+func IsWrongKey(err error) bool {
+	var noMatch *age.NoIdentityMatchError
+	return errors.As(err, &noMatch)
+}
+
+// EncryptWriter returns a writer that encrypts everything written to it into
+// w, for data too large to hold in memory. Close must be called to finish.
+func (e *Encryptor) EncryptWriter(w io.Writer) (io.WriteCloser, error) {
+	ew, err := age.Encrypt(w, e.recipients()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encryption writer: %w", err)
+	}
+	return ew, nil
+}
+
+// DecryptReader returns a reader of the plaintext of the encrypted stream r.
+func (e *Encryptor) DecryptReader(r io.Reader) (io.Reader, error) {
+	dr, err := age.Decrypt(r, e.identities()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return dr, nil
+}
+
 func (e *Encryptor) PublicKey() string {
-	return e.recipient.String()
+	return e.publicKey
+}
+
+// Fingerprint returns a short digest of the public key for people to compare
+// across devices, e.g. "3F2A-91C0-7B44-E1D2". Devices sharing a key (or a
+// passphrase) show the same one.
+func (e *Encryptor) Fingerprint() string {
+	return KeyFingerprint(e.publicKey)
+}
+
+// KeyFingerprint returns the fingerprint of a public key or recipient string;
+// see Fingerprint.
+func KeyFingerprint(publicKey string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(publicKey)))
+	digits := strings.ToUpper(hex.EncodeToString(sum[:8]))
+	groups := make([]string, 0, 4)
+	for i := 0; i < len(digits); i += 4 {
+		groups = append(groups, digits[i:i+4])
+	}
+	return strings.Join(groups, "-")
 }
 
 func GenerateKey(keyPath string) error {
@@ -91,13 +342,32 @@ func GenerateKey(keyPath string) error {
 // The same passphrase will always generate the same key, allowing sync across devices
 // without copying key files.
 func GenerateKeyFromPassphrase(keyPath, passphrase string) error {
-	// Use a fixed salt derived from "claude-sync" - this is intentional
-	// so the same passphrase produces the same key on any device
-	salt := sha256.Sum256([]byte("claude-sync-v1"))
+	// DefaultKDFParams use a fixed salt derived from "claude-sync" - this is
+	// intentional so the same passphrase produces the same key on any device
+	return GenerateKeyFromPassphraseWithParams(keyPath, passphrase, DefaultKDFParams())
+}
+
+// GenerateKeyFromPassphraseWithParams is GenerateKeyFromPassphrase with the
+// Argon2id salt and costs given by params. Devices sharing a bucket must use
+// the same params to derive the same key.
+func GenerateKeyFromPassphraseWithParams(keyPath, passphrase string, params KDFParams) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	salt, err := params.salt()
+	if err != nil {
+		return err
+	}
+
+	// KDFVersion1, the only scheme so far. A new one gets its own case here
+	// and a new KDFVersion, so older builds refuse it instead of deriving
+	// a different key.
+	if params.SchemeVersion() != KDFVersion1 {
+		return &UnsupportedKDFError{Version: params.Version}
+	}
 
 	// Derive 32 bytes using Argon2id (memory-hard, resistant to GPU attacks)
-	// Parameters: 64MB memory, 3 iterations, 4 threads
-	key := argon2.IDKey([]byte(passphrase), salt[:], 3, 64*1024, 4, 32)
+	key := argon2.IDKey([]byte(passphrase), salt, params.Time, params.MemoryKiB, params.Threads, 32)
 
 	// Clamp the scalar for X25519 (per RFC 7748)
 	key[0] &= 248
@@ -1,6 +1,8 @@
 package crypto
 
 import (
+	"bytes"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -283,6 +285,37 @@ func TestEncryptDecryptWithPassphraseKey(t *testing.T) {
 	}
 }
 
+func TestIsWrongKey(t *testing.T) {
+	tmpDir := t.TempDir()
+	keyA := filepath.Join(tmpDir, "a.txt")
+	keyB := filepath.Join(tmpDir, "b.txt")
+	if err := GenerateKey(keyA); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateKey(keyB); err != nil {
+		t.Fatal(err)
+	}
+	encA, err := NewEncryptor(keyA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encB, err := NewEncryptor(keyB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, err := encA.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := encB.Decrypt(ciphertext); !IsWrongKey(err) {
+		t.Errorf("Decrypt with another key: IsWrongKey(%v) = false", err)
+	}
+	if _, err := encA.Decrypt([]byte("not age data")); IsWrongKey(err) {
+		t.Errorf("Decrypt of garbage: IsWrongKey(%v) = true", err)
+	}
+}
+
 func TestEncryptDecryptLargeData(t *testing.T) {
 	tmpDir := t.TempDir()
 	keyPath := filepath.Join(tmpDir, "age-key.txt")
@@ -325,6 +358,166 @@ func TestEncryptDecryptLargeData(t *testing.T) {
 	}
 }
 
+func TestEncryptDecryptStream(t *testing.T) {
+	tmpDir := t.TempDir()
+	keyPath := filepath.Join(tmpDir, "age-key.txt")
+
+	if err := GenerateKey(keyPath); err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	enc, err := NewEncryptor(keyPath)
+	if err != nil {
+		t.Fatalf("NewEncryptor failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w, err := enc.EncryptWriter(&buf)
+	if err != nil {
+		t.Fatalf("EncryptWriter failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := w.Write([]byte("chunk ")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Streams are ordinary age files, readable by Decrypt
+	plaintext, err := enc.Decrypt(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if string(plaintext) != "chunk chunk chunk " {
+		t.Errorf("Expected round-trip, got %q", plaintext)
+	}
+
+	r, err := enc.DecryptReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("DecryptReader failed: %v", err)
+	}
+	streamed, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if string(streamed) != string(plaintext) {
+		t.Errorf("Expected %q from DecryptReader, got %q", plaintext, streamed)
+	}
+}
+
+func TestEncryptToExtraRecipients(t *testing.T) {
+	tmpDir := t.TempDir()
+	keyPath := filepath.Join(tmpDir, "age-key.txt")
+	recoveryPath := filepath.Join(tmpDir, "recovery.txt")
+	for _, p := range []string{keyPath, recoveryPath} {
+		if err := GenerateKey(p); err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+	}
+	recovery, err := NewEncryptor(recoveryPath)
+	if err != nil {
+		t.Fatalf("NewEncryptor failed: %v", err)
+	}
+
+	enc, err := NewEncryptorWithRecipients(keyPath, []string{recovery.PublicKey()})
+	if err != nil {
+		t.Fatalf("NewEncryptorWithRecipients failed: %v", err)
+	}
+	plaintext := []byte("shared with the recovery key")
+	ciphertext, err := enc.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	// Both the own key and the recovery key open it
+	for name, e := range map[string]*Encryptor{"own": enc, "recovery": recovery} {
+		decrypted, err := e.Decrypt(ciphertext)
+		if err != nil || string(decrypted) != string(plaintext) {
+			t.Errorf("%s key: Decrypt = %q, %v", name, decrypted, err)
+		}
+	}
+
+	if _, err := NewEncryptorWithRecipients(keyPath, []string{"age1notakey"}); err == nil {
+		t.Error("Expected an error for an invalid recipient")
+	}
+}
+
+func TestEncryptorFromIdentity(t *testing.T) {
+	secret, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+	enc, err := NewEncryptorFromIdentity(secret, nil)
+	if err != nil {
+		t.Fatalf("NewEncryptorFromIdentity failed: %v", err)
+	}
+	if got, err := enc.SecretKey(); err != nil || got != secret {
+		t.Errorf("SecretKey = %q, %v", got, err)
+	}
+
+	other, _ := GenerateIdentity()
+	otherEnc, _ := NewEncryptorFromIdentity(other, nil)
+	ciphertext, err := EncryptTo([]byte("for both"), []string{enc.PublicKey(), otherEnc.PublicKey()})
+	if err != nil {
+		t.Fatalf("EncryptTo failed: %v", err)
+	}
+	for _, e := range []*Encryptor{enc, otherEnc} {
+		if got, err := e.Decrypt(ciphertext); err != nil || string(got) != "for both" {
+			t.Errorf("Decrypt = %q, %v", got, err)
+		}
+	}
+
+	if _, err := EncryptTo([]byte("x"), nil); err == nil {
+		t.Error("Expected an error without recipients")
+	}
+	if _, err := NewEncryptorFromIdentity("AGE-SECRET-KEY-1NOTAKEY", nil); err == nil {
+		t.Error("Expected an error for an invalid identity")
+	}
+}
+
+func TestNewEncryptorKeygenFile(t *testing.T) {
+	first, _ := GenerateIdentity()
+	second, _ := GenerateIdentity()
+	firstEnc, _ := NewEncryptorFromIdentity(first, nil)
+	secondEnc, _ := NewEncryptorFromIdentity(second, nil)
+
+	// As age-keygen writes it, with a second key appended
+	keyPath := filepath.Join(t.TempDir(), "keys.txt")
+	data := "# created: 2024-01-01T00:00:00Z\n# public key: " + firstEnc.PublicKey() + "\n" + first + "\n\n" +
+		"# created: 2025-01-01T00:00:00Z\n# public key: " + secondEnc.PublicKey() + "\n" + second + "\n"
+	if err := os.WriteFile(keyPath, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := NewEncryptor(keyPath)
+	if err != nil {
+		t.Fatalf("NewEncryptor failed: %v", err)
+	}
+	if enc.PublicKey() != firstEnc.PublicKey() {
+		t.Errorf("Expected files encrypted to the first key, got %s", enc.PublicKey())
+	}
+
+	// Files encrypted to either key open
+	for _, e := range []*Encryptor{firstEnc, secondEnc} {
+		ciphertext, err := e.Encrypt([]byte("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := enc.Decrypt(ciphertext); err != nil || string(got) != "hello" {
+			t.Errorf("Decrypt = %q, %v", got, err)
+		}
+	}
+
+	if err := os.WriteFile(keyPath, []byte("# only a comment\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewEncryptor(keyPath); err == nil {
+		t.Error("Expected an error for a file without identities")
+	}
+}
+
 func TestValidatePassphraseStrength(t *testing.T) {
 	tests := []struct {
 		passphrase string
@@ -420,3 +613,29 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+func TestFingerprint(t *testing.T) {
+	tmpDir := t.TempDir()
+	fingerprint := func(name, passphrase string) string {
+		keyPath := filepath.Join(tmpDir, name)
+		if err := GenerateKeyFromPassphrase(keyPath, passphrase); err != nil {
+			t.Fatal(err)
+		}
+		enc, err := NewEncryptor(keyPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return enc.Fingerprint()
+	}
+
+	a := fingerprint("a.txt", "passphrase-one-secure")
+	if len(a) != 19 || strings.Count(a, "-") != 3 {
+		t.Errorf("Unexpected fingerprint format %q", a)
+	}
+	if b := fingerprint("b.txt", "passphrase-one-secure"); b != a {
+		t.Errorf("Expected the same passphrase to give the same fingerprint, got %q and %q", a, b)
+	}
+	if c := fingerprint("c.txt", "passphrase-one-securd"); c == a {
+		t.Error("Expected a mistyped passphrase to give a different fingerprint")
+	}
+}
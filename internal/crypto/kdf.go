@@ -0,0 +1,139 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// KDFParams are the Argon2id settings a passphrase key is derived with. Every
+// device has to use the same ones to arrive at the same key, so they are kept
+// with the bucket (see sync.LoadKDFParams) as well as in the config.
+type KDFParams struct {
+	// Salt is base64. Empty means the fixed legacy salt, sha256("claude-sync-v1"),
+	// which every bucket set up before KDF parameters existed relies on.
+	Salt string `json:"salt,omitempty" yaml:"salt,omitempty"`
+
+	Time      uint32 `json:"time" yaml:"time"`
+	MemoryKiB uint32 `json:"memory_kib" yaml:"memory_kib"`
+	Threads   uint8  `json:"threads" yaml:"threads"`
+
+	// Version is the derivation scheme: how the passphrase, salt and costs
+	// become an age key. Zero means KDFVersion1, which parameters saved
+	// before the version was recorded use.
+	Version int `json:"version,omitempty" yaml:"version,omitempty"`
+}
+
+// Key derivation schemes. A device refuses parameters with a version newer
+// than KDFVersion rather than deriving a different key from them.
+const (
+	// KDFVersion1 is Argon2id over the passphrase, clamped for X25519 and
+	// encoded as an age identity.
+	KDFVersion1 = 1
+
+	// KDFVersion is the newest scheme this build derives keys with.
+	KDFVersion = KDFVersion1
+)
+
+// UnsupportedKDFError is returned for parameters from a newer claude-sync,
+// whose derivation scheme this one doesn't know.
+type UnsupportedKDFError struct {
+	Version int
+}
+
+func (e *UnsupportedKDFError) Error() string {
+	return fmt.Sprintf("keys are derived with key derivation version %d, but this claude-sync only supports up to version %d; run 'claude-sync update' first", e.Version, KDFVersion)
+}
+
+// Lower bounds accepted for KDF parameters, to stop a typo from producing a
+// trivially brute-forced key.
+const (
+	minKDFMemoryKiB = 8 * 1024
+	minKDFTime      = 1
+)
+
+// DefaultKDFParams returns the parameters keys have always been derived
+// with: the fixed salt, 64 MiB of memory, 3 iterations and 4 threads.
+func DefaultKDFParams() KDFParams {
+	return KDFParams{Time: 3, MemoryKiB: 64 * 1024, Threads: 4, Version: KDFVersion1}
+}
+
+// NewKDFParams returns params with the given costs and a new random salt, for
+// a bucket that has no passphrase-encrypted data yet. Zero costs take the
+// default.
+func NewKDFParams(memoryMiB, time uint32, threads uint8) (KDFParams, error) {
+	p := DefaultKDFParams()
+	if memoryMiB != 0 {
+		p.MemoryKiB = memoryMiB * 1024
+	}
+	if time != 0 {
+		p.Time = time
+	}
+	if threads != 0 {
+		p.Threads = threads
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return KDFParams{}, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	p.Salt = base64.StdEncoding.EncodeToString(salt)
+	return p, p.Validate()
+}
+
+// SchemeVersion returns p's derivation scheme, taking an unrecorded version
+// as KDFVersion1.
+func (p KDFParams) SchemeVersion() int {
+	if p.Version == 0 {
+		return KDFVersion1
+	}
+	return p.Version
+}
+
+// IsLegacy reports whether p uses the fixed salt.
+func (p KDFParams) IsLegacy() bool {
+	return p.Salt == ""
+}
+
+// Validate checks that p is usable and not too weak.
+func (p KDFParams) Validate() error {
+	if p.Version < 0 || p.Version > KDFVersion {
+		return &UnsupportedKDFError{Version: p.Version}
+	}
+	if p.MemoryKiB < minKDFMemoryKiB {
+		return fmt.Errorf("KDF memory must be at least %d MiB", minKDFMemoryKiB/1024)
+	}
+	if p.Time < minKDFTime {
+		return fmt.Errorf("KDF time must be at least %d", minKDFTime)
+	}
+	if p.Threads == 0 {
+		return fmt.Errorf("KDF threads must be at least 1")
+	}
+	if _, err := p.salt(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p KDFParams) salt() ([]byte, error) {
+	if p.IsLegacy() {
+		// Fixed on purpose: see GenerateKeyFromPassphrase
+		salt := sha256.Sum256([]byte("claude-sync-v1"))
+		return salt[:], nil
+	}
+	salt, err := base64.StdEncoding.DecodeString(p.Salt)
+	if err != nil || len(salt) < 8 {
+		return nil, fmt.Errorf("invalid KDF salt %q", p.Salt)
+	}
+	return salt, nil
+}
+
+// String summarizes the costs, e.g. "argon2id, 64 MiB, 3 iterations, 4 threads".
+func (p KDFParams) String() string {
+	salt := "random salt"
+	if p.IsLegacy() {
+		salt = "fixed salt"
+	}
+	return fmt.Sprintf("argon2id, %d MiB, %d iterations, %d threads, %s", p.MemoryKiB/1024, p.Time, p.Threads, salt)
+}
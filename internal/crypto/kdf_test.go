@@ -0,0 +1,126 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func readKey(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// The default parameters must keep deriving the key every existing
+// passphrase bucket was encrypted with.
+func TestDefaultKDFParamsMatchLegacyKey(t *testing.T) {
+	passphrase := "legacy-passphrase-123"
+	salt := sha256.Sum256([]byte("claude-sync-v1"))
+	key := argon2.IDKey([]byte(passphrase), salt[:], 3, 64*1024, 4, 32)
+	key[0] &= 248
+	key[31] &= 127
+	key[31] |= 64
+	want, err := encodeAgeIdentity(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyPath := filepath.Join(t.TempDir(), "age-key.txt")
+	if err := GenerateKeyFromPassphraseWithParams(keyPath, passphrase, DefaultKDFParams()); err != nil {
+		t.Fatalf("GenerateKeyFromPassphraseWithParams failed: %v", err)
+	}
+	if got := readKey(t, keyPath); got != want {
+		t.Error("Default KDF parameters no longer derive the legacy key")
+	}
+}
+
+func TestKDFParamsSalt(t *testing.T) {
+	a, err := NewKDFParams(8, 1, 1)
+	if err != nil {
+		t.Fatalf("NewKDFParams failed: %v", err)
+	}
+	b, err := NewKDFParams(8, 1, 1)
+	if err != nil {
+		t.Fatalf("NewKDFParams failed: %v", err)
+	}
+	if a.Salt == b.Salt || a.IsLegacy() {
+		t.Fatal("Expected distinct random salts")
+	}
+
+	dir := t.TempDir()
+	derive := func(name string, p KDFParams) string {
+		path := filepath.Join(dir, name)
+		if err := GenerateKeyFromPassphraseWithParams(path, "same-passphrase-123", p); err != nil {
+			t.Fatalf("GenerateKeyFromPassphraseWithParams failed: %v", err)
+		}
+		return readKey(t, path)
+	}
+	if derive("a1", a) != derive("a2", a) {
+		t.Error("Expected the same params to derive the same key")
+	}
+	if derive("a", a) == derive("b", b) {
+		t.Error("Expected different salts to derive different keys")
+	}
+}
+
+func TestKDFParamsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		params KDFParams
+	}{
+		{"too little memory", KDFParams{Time: 3, MemoryKiB: 1024, Threads: 4}},
+		{"zero time", KDFParams{Time: 0, MemoryKiB: 64 * 1024, Threads: 4}},
+		{"zero threads", KDFParams{Time: 3, MemoryKiB: 64 * 1024}},
+		{"bad salt", KDFParams{Salt: "!!", Time: 3, MemoryKiB: 64 * 1024, Threads: 4}},
+		{"newer version", KDFParams{Time: 3, MemoryKiB: 64 * 1024, Threads: 4, Version: KDFVersion + 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.params.Validate(); err == nil {
+				t.Error("Expected a validation error")
+			}
+		})
+	}
+	if err := DefaultKDFParams().Validate(); err != nil {
+		t.Errorf("Default params invalid: %v", err)
+	}
+}
+
+func TestKDFParamsVersion(t *testing.T) {
+	// Parameters saved before versions were recorded are version 1
+	unversioned := DefaultKDFParams()
+	unversioned.Version = 0
+	if unversioned.SchemeVersion() != KDFVersion1 {
+		t.Errorf("Expected an unrecorded version to be %d, got %d", KDFVersion1, unversioned.SchemeVersion())
+	}
+	dir := t.TempDir()
+	if err := GenerateKeyFromPassphraseWithParams(filepath.Join(dir, "a.txt"), "passphrase-1", unversioned); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateKeyFromPassphraseWithParams(filepath.Join(dir, "b.txt"), "passphrase-1", DefaultKDFParams()); err != nil {
+		t.Fatal(err)
+	}
+	if readKey(t, filepath.Join(dir, "a.txt")) != readKey(t, filepath.Join(dir, "b.txt")) {
+		t.Error("Expected the same key with and without a recorded version")
+	}
+
+	newer := DefaultKDFParams()
+	newer.Version = KDFVersion + 1
+	err := GenerateKeyFromPassphraseWithParams(filepath.Join(dir, "c.txt"), "passphrase-1", newer)
+	var unsupported *UnsupportedKDFError
+	if !errors.As(err, &unsupported) || unsupported.Version != KDFVersion+1 {
+		t.Errorf("Expected UnsupportedKDFError, got %v", err)
+	}
+	if KeyExists(filepath.Join(dir, "c.txt")) {
+		t.Error("Expected no key written for an unknown version")
+	}
+}
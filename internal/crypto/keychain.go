@@ -0,0 +1,109 @@
+package crypto
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+// KeychainService is the service name age keys are stored under in the OS
+// keychain (macOS Keychain, Secret Service, Windows Credential Manager).
+const KeychainService = "claude-sync"
+
+// keychainStubPrefix starts a key file whose key lives in the OS keychain.
+// The rest of the line is the keychain account: the key's public key, so
+// old and new keys (after a rekey) never share an entry.
+const keychainStubPrefix = "claude-sync-keychain:"
+
+// isKeychainStub reports whether key file contents point into the keychain,
+// returning the account.
+func isKeychainStub(data []byte) (string, bool) {
+	for _, line := range strings.Split(string(data), "\n") {
+		if account, ok := strings.CutPrefix(strings.TrimSpace(line), keychainStubPrefix); ok {
+			return strings.TrimSpace(account), true
+		}
+	}
+	return "", false
+}
+
+// IsKeychainKeyFile reports whether the key file at keyPath holds its key in
+// the OS keychain.
+func IsKeychainKeyFile(keyPath string) bool {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return false
+	}
+	_, ok := isKeychainStub(data)
+	return ok
+}
+
+// StoreKeyInKeychain moves the age key at keyPath into the OS keychain,
+// leaving a stub in the file that names the keychain entry. It fails, leaving
+// the file untouched, where no keychain is available (e.g. headless Linux).
+func StoreKeyInKeychain(keyPath string) error {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read age key: %w", err)
+	}
+	if _, ok := isKeychainStub(data); ok {
+		return fmt.Errorf("%s is already in the keychain", keyPath)
+	}
+	if isSSHPrivateKey(data) || isProtectedKey(data) {
+		return fmt.Errorf("%s is not a plaintext age key", keyPath)
+	}
+	identities, err := parseAgeIdentities(data)
+	if err != nil {
+		return err
+	}
+	secrets := make([]string, len(identities))
+	for i, identity := range identities {
+		secrets[i] = identity.String()
+	}
+
+	account := identities[0].Recipient().String()
+	if err := keyring.Set(KeychainService, account, strings.Join(secrets, "\n")); err != nil {
+		return fmt.Errorf("failed to store key in keychain: %w", err)
+	}
+	stub := "# The age key for claude-sync is in the OS keychain.\n" + keychainStubPrefix + " " + account + "\n"
+	if err := replaceKeyFile(keyPath, []byte(stub)); err != nil {
+		_ = keyring.Delete(KeychainService, account)
+		return err
+	}
+	return nil
+}
+
+// RestoreKeyFromKeychain writes the age key back into the file at keyPath
+// and removes it from the OS keychain.
+func RestoreKeyFromKeychain(keyPath string) error {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read age key: %w", err)
+	}
+	account, ok := isKeychainStub(data)
+	if !ok {
+		return fmt.Errorf("%s is not in the keychain", keyPath)
+	}
+	identity, err := keychainKey(account)
+	if err != nil {
+		return err
+	}
+	if err := replaceKeyFile(keyPath, append(bytes.TrimSpace(identity), '\n')); err != nil {
+		return err
+	}
+	if err := keyring.Delete(KeychainService, account); err != nil {
+		return fmt.Errorf("key written to %s, but failed to remove it from the keychain: %w", keyPath, err)
+	}
+	return nil
+}
+
+// keychainKey reads the age identity stored under account.
+func keychainKey(account string) ([]byte, error) {
+	secret, err := keyring.Get(KeychainService, account)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s from keychain: %w", account, err)
+	}
+	return []byte(secret), nil
+}
@@ -0,0 +1,81 @@
+package crypto
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestKeychainKey(t *testing.T) {
+	keyring.MockInit()
+
+	keyPath := filepath.Join(t.TempDir(), "age-key.txt")
+	if err := GenerateKey(keyPath); err != nil {
+		t.Fatal(err)
+	}
+	plain, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := NewEncryptor(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := StoreKeyInKeychain(keyPath); err != nil {
+		t.Fatalf("StoreKeyInKeychain failed: %v", err)
+	}
+	stub, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(stub), "AGE-SECRET-KEY-") || !IsKeychainKeyFile(keyPath) {
+		t.Fatalf("Expected only a keychain pointer in the key file, got %q", stub)
+	}
+	if err := StoreKeyInKeychain(keyPath); err == nil {
+		t.Error("Expected an error storing a key that is already in the keychain")
+	}
+	if err := ProtectKey(keyPath, "correct horse battery"); err == nil {
+		t.Error("Expected an error protecting a key kept in the keychain")
+	}
+
+	// The key is read from the keychain transparently
+	fromKeychain, err := NewEncryptor(keyPath)
+	if err != nil {
+		t.Fatalf("NewEncryptor failed: %v", err)
+	}
+	if fromKeychain.PublicKey() != enc.PublicKey() {
+		t.Errorf("Keychain key %s, want %s", fromKeychain.PublicKey(), enc.PublicKey())
+	}
+
+	if err := RestoreKeyFromKeychain(keyPath); err != nil {
+		t.Fatalf("RestoreKeyFromKeychain failed: %v", err)
+	}
+	if data, _ := os.ReadFile(keyPath); string(data) != string(plain) {
+		t.Errorf("Restored key = %q, want the original", data)
+	}
+	if _, err := keyring.Get(KeychainService, enc.PublicKey()); err == nil {
+		t.Error("Expected the keychain entry removed")
+	}
+}
+
+func TestKeychainUnavailable(t *testing.T) {
+	keyring.MockInitWithError(errors.New("no secret service"))
+
+	keyPath := filepath.Join(t.TempDir(), "age-key.txt")
+	if err := GenerateKey(keyPath); err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := os.ReadFile(keyPath)
+
+	if err := StoreKeyInKeychain(keyPath); err == nil {
+		t.Fatal("Expected an error without a keychain")
+	}
+	if data, _ := os.ReadFile(keyPath); string(data) != string(plain) {
+		t.Error("Key file should be left untouched when the keychain is unavailable")
+	}
+}
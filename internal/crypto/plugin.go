@@ -0,0 +1,133 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"filippo.io/age/plugin"
+)
+
+// PluginUI handles messages, PIN prompts and touch reminders from age
+// plugins such as age-plugin-yubikey. The CLI sets it to a terminal UI; when
+// nil, plugins can't ask for anything and fail if they need to.
+var PluginUI *plugin.ClientUI
+
+func pluginUI() *plugin.ClientUI {
+	if PluginUI != nil {
+		return PluginUI
+	}
+	return &plugin.ClientUI{}
+}
+
+// pluginMu runs one plugin call at a time. Sync encrypts and decrypts files
+// on parallel workers, and a hardware token can't serve them at once: each
+// call would start its own plugin process, fight over the device and ask for
+// the PIN or touch again. Each file has its own file key, so there is no
+// single unwrap to reuse; taking turns lets the plugin cache the PIN between
+// calls as it does for a single age process.
+var pluginMu sync.Mutex
+
+// serialIdentity is a plugin identity whose unwraps take turns on pluginMu.
+type serialIdentity struct {
+	*plugin.Identity
+}
+
+func (i serialIdentity) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	return i.Identity.Unwrap(stanzas)
+}
+
+// serialRecipient is a plugin recipient whose wraps take turns on pluginMu.
+type serialRecipient struct {
+	*plugin.Recipient
+}
+
+func (r serialRecipient) Wrap(fileKey []byte) ([]*age.Stanza, error) {
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	return r.Recipient.Wrap(fileKey)
+}
+
+func (r serialRecipient) WrapWithLabels(fileKey []byte) ([]*age.Stanza, []string, error) {
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	return r.Recipient.WrapWithLabels(fileKey)
+}
+
+// pluginIdentityPrefix starts an age plugin identity ("AGE-PLUGIN-YUBIKEY-1...").
+const pluginIdentityPrefix = "AGE-PLUGIN-"
+
+// parsePluginKeyFile returns the plugin identity in an identity file, as
+// written by e.g. age-plugin-yubikey, and the recipient from its
+// "# Recipient: age1..." comment, if it has one.
+func parsePluginKeyFile(data []byte) (identity, recipient string, ok bool) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if comment, isComment := strings.CutPrefix(line, "#"); isComment {
+			if r, found := strings.CutPrefix(strings.TrimSpace(comment), "Recipient:"); found {
+				recipient = strings.TrimSpace(r)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, pluginIdentityPrefix) && identity == "" {
+			identity = line
+		}
+	}
+	return identity, recipient, identity != ""
+}
+
+// IsPluginKeyFile reports whether the key file at keyPath holds an age
+// plugin identity, for a key kept on a hardware token.
+func IsPluginKeyFile(keyPath string) bool {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return false
+	}
+	_, _, ok := parsePluginKeyFile(data)
+	return ok
+}
+
+// newPluginEncryptor uses an age plugin identity. Decrypting runs the plugin
+// binary (age-plugin-<name>, found on $PATH), which may ask for a PIN or a
+// touch through PluginUI. Files are encrypted to the recipient named in the
+// identity file's comments, or else to the identity itself, which plugins
+// that support it turn into their recipient.
+func newPluginEncryptor(data []byte) (*Encryptor, error) {
+	encoded, recipientStr, _ := parsePluginKeyFile(data)
+	ui := pluginUI()
+
+	identity, err := plugin.NewIdentity(encoded, ui)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age plugin identity: %w", err)
+	}
+
+	recipient := identity.Recipient()
+	publicKey := recipientStr
+	if recipientStr != "" {
+		if recipient, err = plugin.NewRecipient(recipientStr, ui); err != nil {
+			return nil, fmt.Errorf("invalid recipient in identity file: %w", err)
+		}
+	} else {
+		publicKey = encoded
+	}
+
+	return &Encryptor{
+		identity:  serialIdentity{identity},
+		recipient: serialRecipient{recipient},
+		publicKey: publicKey,
+	}, nil
+}
+
+// isPluginRecipient reports whether s is an age plugin recipient
+// ("age1yubikey1..."), as opposed to a native X25519 one ("age1...").
+func isPluginRecipient(s string) bool {
+	name, _, err := plugin.ParseRecipient(s)
+	return err == nil && name != ""
+}
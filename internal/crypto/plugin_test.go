@@ -0,0 +1,210 @@
+package crypto
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/plugin"
+)
+
+// testPluginName is the fake plugin the test binary acts as when run as
+// age-plugin-cstest. Its identity and recipient data is an X25519 scalar.
+const testPluginName = "cstest"
+
+var testPluginScalar = bytes.Repeat([]byte{7}, 32)
+
+func TestMain(m *testing.M) {
+	if filepath.Base(os.Args[0]) == "age-plugin-"+testPluginName {
+		os.Exit(runTestPlugin())
+	}
+	os.Exit(m.Run())
+}
+
+func runTestPlugin() int {
+	p, err := plugin.New(testPluginName)
+	if err != nil {
+		return 1
+	}
+	identity := func(data []byte) (*age.X25519Identity, error) {
+		s, err := encodeAgeIdentity(data)
+		if err != nil {
+			return nil, err
+		}
+		return age.ParseX25519Identity(s)
+	}
+	p.HandleIdentity(func(data []byte) (age.Identity, error) {
+		id, err := identity(data)
+		if err != nil {
+			return nil, err
+		}
+		if lock := os.Getenv("CSTEST_PLUGIN_LOCK"); lock != "" {
+			return exclusiveIdentity{id, lock}, nil
+		}
+		return id, nil
+	})
+	p.HandleRecipient(func(data []byte) (age.Recipient, error) {
+		id, err := identity(data)
+		if err != nil {
+			return nil, err
+		}
+		return id.Recipient(), nil
+	})
+	return p.Main()
+}
+
+// exclusiveIdentity makes the test plugin fail when another copy of it is
+// unwrapping at the same time, as a hardware token would.
+type exclusiveIdentity struct {
+	age.Identity
+	lock string
+}
+
+func (i exclusiveIdentity) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	f, err := os.OpenFile(i.lock, os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("device busy: %w", err)
+	}
+	f.Close()
+	defer os.Remove(i.lock)
+	time.Sleep(20 * time.Millisecond)
+	return i.Identity.Unwrap(stanzas)
+}
+
+// installTestPlugin puts the test binary on $PATH as age-plugin-cstest.
+func installTestPlugin(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin test binary is not set up on Windows")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Symlink(exe, filepath.Join(dir, "age-plugin-"+testPluginName)); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func writePluginKey(t *testing.T, withRecipient bool) string {
+	t.Helper()
+	content := "# Created by a hardware token plugin\n"
+	if withRecipient {
+		content += "#    Recipient: " + plugin.EncodeRecipient(testPluginName, testPluginScalar) + "\n"
+	}
+	content += plugin.EncodeIdentity(testPluginName, testPluginScalar) + "\n"
+	keyPath := filepath.Join(t.TempDir(), "plugin-key.txt")
+	if err := os.WriteFile(keyPath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return keyPath
+}
+
+func TestPluginKeyEncryptor(t *testing.T) {
+	installTestPlugin(t)
+	keyPath := writePluginKey(t, true)
+
+	if !IsPluginKeyFile(keyPath) {
+		t.Fatal("Expected a plugin key file")
+	}
+	enc, err := NewEncryptor(keyPath)
+	if err != nil {
+		t.Fatalf("NewEncryptor failed: %v", err)
+	}
+	if enc.PublicKey() != plugin.EncodeRecipient(testPluginName, testPluginScalar) {
+		t.Errorf("Expected the recipient from the comment, got %s", enc.PublicKey())
+	}
+
+	ciphertext, err := enc.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	plaintext, err := enc.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if string(plaintext) != "secret" {
+		t.Errorf("Got %q", plaintext)
+	}
+}
+
+func TestPluginRecipient(t *testing.T) {
+	installTestPlugin(t)
+	keyPath := writePluginKey(t, false)
+	other := filepath.Join(t.TempDir(), "age-key.txt")
+	if err := GenerateKey(other); err != nil {
+		t.Fatal(err)
+	}
+
+	// Encrypt with a plain key that also has the plugin as a recipient
+	enc, err := NewEncryptorWithRecipients(other, []string{plugin.EncodeRecipient(testPluginName, testPluginScalar)})
+	if err != nil {
+		t.Fatalf("NewEncryptorWithRecipients failed: %v", err)
+	}
+	ciphertext, err := enc.Encrypt([]byte("shared"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	dec, err := NewEncryptor(keyPath)
+	if err != nil {
+		t.Fatalf("NewEncryptor failed: %v", err)
+	}
+	plaintext, err := dec.Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if string(plaintext) != "shared" {
+		t.Errorf("Got %q", plaintext)
+	}
+}
+
+func TestPluginCallsTakeTurns(t *testing.T) {
+	installTestPlugin(t)
+	t.Setenv("CSTEST_PLUGIN_LOCK", filepath.Join(t.TempDir(), "busy"))
+	enc, err := NewEncryptor(writePluginKey(t, true))
+	if err != nil {
+		t.Fatalf("NewEncryptor failed: %v", err)
+	}
+	ciphertext, err := enc.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	// Parallel sync workers decrypting at once must not reach the device together
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := enc.Decrypt(ciphertext); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Decrypt failed: %v", err)
+	}
+}
+
+func TestPluginMissing(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	enc, err := NewEncryptor(writePluginKey(t, true))
+	if err != nil {
+		t.Fatalf("NewEncryptor failed: %v", err)
+	}
+	if _, err := enc.Encrypt([]byte("x")); err == nil {
+		t.Error("Expected an error without the plugin binary")
+	}
+}
@@ -0,0 +1,147 @@
+package crypto
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// protectedKeyHeader starts an age key file encrypted at rest by ProtectKey.
+const protectedKeyHeader = armor.Header
+
+// protectWorkFactor is the scrypt work factor (log2 N) ProtectKey uses.
+var protectWorkFactor = 18
+
+// isProtectedKey reports whether key file contents are encrypted at rest.
+func isProtectedKey(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte(protectedKeyHeader))
+}
+
+// IsProtectedKeyFile reports whether the key file at keyPath is encrypted at
+// rest with a passphrase.
+func IsProtectedKeyFile(keyPath string) bool {
+	data, err := os.ReadFile(keyPath)
+	return err == nil && isProtectedKey(data)
+}
+
+// ProtectKey encrypts the age key file at keyPath in place with an age
+// scrypt passphrase, so a copy of the file alone doesn't expose the key. The
+// result is an armored age file that 'age -d' also opens.
+func ProtectKey(keyPath, passphrase string) error {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read age key: %w", err)
+	}
+	if isProtectedKey(data) {
+		return fmt.Errorf("%s is already protected", keyPath)
+	}
+	if isSSHPrivateKey(data) {
+		return fmt.Errorf("%s is an SSH key; protect it with 'ssh-keygen -p' instead", keyPath)
+	}
+	if _, ok := isKeychainStub(data); ok {
+		return fmt.Errorf("%s is in the OS keychain, which already protects it", keyPath)
+	}
+	if _, err := parseAgeIdentities(data); err != nil {
+		return err
+	}
+
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return err
+	}
+	recipient.SetWorkFactor(protectWorkFactor)
+
+	var buf bytes.Buffer
+	aw := armor.NewWriter(&buf)
+	w, err := age.Encrypt(aw, recipient)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt age key: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to encrypt age key: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt age key: %w", err)
+	}
+	if err := aw.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt age key: %w", err)
+	}
+	return replaceKeyFile(keyPath, buf.Bytes())
+}
+
+// UnprotectKey decrypts a key file protected by ProtectKey back to a
+// plaintext age identity.
+func UnprotectKey(keyPath, passphrase string) error {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read age key: %w", err)
+	}
+	if !isProtectedKey(data) {
+		return fmt.Errorf("%s is not protected", keyPath)
+	}
+	plaintext, err := openProtectedKey(data, []byte(passphrase))
+	if err != nil {
+		return err
+	}
+	return replaceKeyFile(keyPath, plaintext)
+}
+
+// openProtectedKey decrypts protected key file contents.
+func openProtectedKey(data, passphrase []byte) ([]byte, error) {
+	identity, err := age.NewScryptIdentity(string(passphrase))
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(data)), identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock age key (wrong passphrase?): %w", err)
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock age key: %w", err)
+	}
+	return plaintext, nil
+}
+
+// unlockKey asks for the passphrase of a protected key file and returns the
+// plaintext identity.
+func unlockKey(keyPath string, data []byte) ([]byte, error) {
+	if KeyPassphrase == nil {
+		return nil, fmt.Errorf("age key %s is passphrase-protected", keyPath)
+	}
+	passphrase, err := KeyPassphrase(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	return openProtectedKey(data, passphrase)
+}
+
+// replaceKeyFile atomically replaces keyPath with data, user-only.
+func replaceKeyFile(keyPath string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(keyPath), filepath.Base(keyPath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write age key: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write age key: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write age key: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write age key: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), keyPath); err != nil {
+		return fmt.Errorf("failed to write age key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package crypto
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProtectKey(t *testing.T) {
+	defer func(prev int) { protectWorkFactor = prev }(protectWorkFactor)
+	protectWorkFactor = 10
+	defer func(prev func(string) ([]byte, error)) { KeyPassphrase = prev }(KeyPassphrase)
+
+	keyPath := filepath.Join(t.TempDir(), "age-key.txt")
+	if err := GenerateKey(keyPath); err != nil {
+		t.Fatal(err)
+	}
+	plain, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := NewEncryptor(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ProtectKey(keyPath, "correct horse battery"); err != nil {
+		t.Fatalf("ProtectKey failed: %v", err)
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "AGE-SECRET-KEY-") || !IsProtectedKeyFile(keyPath) {
+		t.Fatalf("Expected the key encrypted at rest, got %q", data)
+	}
+	if info, err := os.Stat(keyPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Protected key mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+	if err := ProtectKey(keyPath, "again"); err == nil {
+		t.Error("Expected an error protecting an already protected key")
+	}
+
+	// Using the key asks for the passphrase
+	KeyPassphrase = nil
+	if _, err := NewEncryptor(keyPath); err == nil {
+		t.Error("Expected an error without a passphrase prompt")
+	}
+	KeyPassphrase = func(string) ([]byte, error) { return []byte("wrong"), nil }
+	if _, err := NewEncryptor(keyPath); err == nil {
+		t.Error("Expected an error for a wrong passphrase")
+	}
+	KeyPassphrase = func(string) ([]byte, error) { return []byte("correct horse battery"), nil }
+	unlocked, err := NewEncryptor(keyPath)
+	if err != nil {
+		t.Fatalf("NewEncryptor failed: %v", err)
+	}
+	if unlocked.PublicKey() != enc.PublicKey() {
+		t.Errorf("Unlocked key %s, want %s", unlocked.PublicKey(), enc.PublicKey())
+	}
+
+	if err := UnprotectKey(keyPath, "wrong"); err == nil {
+		t.Error("Expected an error unprotecting with a wrong passphrase")
+	}
+	if err := UnprotectKey(keyPath, "correct horse battery"); err != nil {
+		t.Fatalf("UnprotectKey failed: %v", err)
+	}
+	if data, _ := os.ReadFile(keyPath); string(data) != string(plain) {
+		t.Errorf("Unprotected key = %q, want the original", data)
+	}
+}
@@ -0,0 +1,140 @@
+package crypto
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Cipher is what the Syncer encrypts and decrypts objects with. Encryptor
+// (age) is the built-in scheme; others register with RegisterScheme.
+type Cipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+
+	// KeyID identifies the key for people and devices to compare, such as
+	// Encryptor's fingerprint. It must not reveal the key.
+	KeyID() string
+}
+
+// StreamCipher is a Cipher that can encrypt data too large to hold in
+// memory. Ciphers without it are buffered (see EncryptWriter).
+type StreamCipher interface {
+	Cipher
+	EncryptWriter(w io.Writer) (io.WriteCloser, error)
+	DecryptReader(r io.Reader) (io.Reader, error)
+}
+
+// Signer is a Cipher whose key also signs, for signed manifests and
+// attestations.
+type Signer interface {
+	Sign(msg []byte) ([]byte, error)
+	SigningPublicKey() string
+}
+
+// SchemeAge is the built-in age scheme, used when none is configured.
+const SchemeAge = "age"
+
+// SchemeFactory creates a scheme's Cipher from the configured key path and
+// extra recipients.
+type SchemeFactory func(keyPath string, recipients []string) (Cipher, error)
+
+var (
+	schemesMu sync.RWMutex
+	schemes   = map[string]SchemeFactory{
+		SchemeAge: func(keyPath string, recipients []string) (Cipher, error) {
+			return NewEncryptorWithRecipients(keyPath, recipients)
+		},
+	}
+)
+
+// RegisterScheme makes an encryption scheme available by name, usually from
+// the init function of the package implementing it. It panics if the name
+// is taken.
+func RegisterScheme(name string, factory SchemeFactory) {
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+	if _, ok := schemes[name]; ok {
+		panic("crypto: encryption scheme " + name + " registered twice")
+	}
+	schemes[name] = factory
+}
+
+// Schemes returns the registered scheme names, sorted.
+func Schemes() []string {
+	schemesMu.RLock()
+	defer schemesMu.RUnlock()
+	names := make([]string, 0, len(schemes))
+	for name := range schemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewCipher creates a Cipher with the named scheme; "" means SchemeAge.
+func NewCipher(scheme, keyPath string, recipients []string) (Cipher, error) {
+	if scheme == "" {
+		scheme = SchemeAge
+	}
+	schemesMu.RLock()
+	factory, ok := schemes[scheme]
+	schemesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown encryption scheme %q (available: %s)", scheme, strings.Join(Schemes(), ", "))
+	}
+	return factory(keyPath, recipients)
+}
+
+// KeyID implements Cipher with the key's fingerprint.
+func (e *Encryptor) KeyID() string {
+	return e.Fingerprint()
+}
+
+// EncryptWriter returns c's streaming writer into w if it has one.
+// Otherwise everything written is held in memory and encrypted on Close.
+func EncryptWriter(c Cipher, w io.Writer) (io.WriteCloser, error) {
+	if sc, ok := c.(StreamCipher); ok {
+		return sc.EncryptWriter(w)
+	}
+	return &bufferedWriter{cipher: c, w: w}, nil
+}
+
+// DecryptReader returns c's streaming reader of r if it has one. Otherwise
+// r is read to the end and decrypted at once.
+func DecryptReader(c Cipher, r io.Reader) (io.Reader, error) {
+	if sc, ok := c.(StreamCipher); ok {
+		return sc.DecryptReader(r)
+	}
+	ciphertext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := c.Decrypt(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(plaintext), nil
+}
+
+type bufferedWriter struct {
+	cipher Cipher
+	w      io.Writer
+	buf    bytes.Buffer
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	return b.buf.Write(p)
+}
+
+func (b *bufferedWriter) Close() error {
+	ciphertext, err := b.cipher.Encrypt(b.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = b.w.Write(ciphertext)
+	return err
+}
@@ -0,0 +1,95 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// xorCipher stands in for another scheme: no streaming, no signing.
+type xorCipher struct{ key byte }
+
+func (c xorCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	out := make([]byte, len(plaintext))
+	for i, b := range plaintext {
+		out[i] = b ^ c.key
+	}
+	return out, nil
+}
+
+func (c xorCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) == 0 {
+		return nil, errors.New("empty ciphertext")
+	}
+	return c.Encrypt(ciphertext)
+}
+
+func (c xorCipher) KeyID() string { return "xor" }
+
+func TestNewCipher(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "age-key.txt")
+	if err := GenerateKey(keyPath); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewCipher("", keyPath, nil)
+	if err != nil {
+		t.Fatalf("NewCipher(age): %v", err)
+	}
+	enc, ok := c.(*Encryptor)
+	if !ok {
+		t.Fatalf("default scheme is %T, want *Encryptor", c)
+	}
+	if c.KeyID() != enc.Fingerprint() {
+		t.Errorf("KeyID = %q, want the fingerprint %q", c.KeyID(), enc.Fingerprint())
+	}
+
+	if _, err := NewCipher("rot13", keyPath, nil); err == nil || !strings.Contains(err.Error(), "unknown encryption scheme") {
+		t.Errorf("NewCipher(rot13) error = %v", err)
+	}
+
+	RegisterScheme("test-xor", func(keyPath string, recipients []string) (Cipher, error) {
+		return xorCipher{key: 0x5a}, nil
+	})
+	if c, err := NewCipher("test-xor", keyPath, nil); err != nil || c.KeyID() != "xor" {
+		t.Errorf("NewCipher(test-xor) = %v, %v", c, err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a scheme twice didn't panic")
+		}
+	}()
+	RegisterScheme("test-xor", nil)
+}
+
+func TestBufferedStreams(t *testing.T) {
+	c := xorCipher{key: 0x5a}
+	var sealed bytes.Buffer
+	w, err := EncryptWriter(c, &sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("hello "))
+	_, _ = w.Write([]byte("world"))
+	if sealed.Len() != 0 {
+		t.Error("buffered writer wrote before Close")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := DecryptReader(c, &sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plain bytes.Buffer
+	_, _ = plain.ReadFrom(r)
+	if plain.String() != "hello world" {
+		t.Errorf("round trip = %q", plain.String())
+	}
+	if _, err := DecryptReader(c, &bytes.Buffer{}); err == nil {
+		t.Error("DecryptReader didn't report the cipher's error")
+	}
+}
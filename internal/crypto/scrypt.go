@@ -0,0 +1,209 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/scrypt"
+)
+
+// SchemeScrypt encrypts every object to an age scrypt (passphrase) recipient
+// rather than to an X25519 key, so stock 'age -d' opens any object with the
+// passphrase alone. Each object pays a scrypt derivation both ways.
+const SchemeScrypt = "age-scrypt"
+
+// scryptKeyHeader starts a key file holding an age-scrypt passphrase.
+const scryptKeyHeader = "# claude-sync age-scrypt passphrase"
+
+// scryptWorkFactor is the scrypt work factor (log2 N) objects are encrypted
+// with. age's default of 18 takes about a second per object; 16 keeps a
+// full push bearable while staying well within what 'age -d' accepts.
+var scryptWorkFactor = 16
+
+// scryptIDContext and scryptIDWorkFactor derive the secret behind KeyID and
+// the signing key. The cost matches the objects', so the fingerprint stored
+// with the bucket is no cheaper to guess against than the objects are.
+const (
+	scryptIDContext    = "claude-sync age-scrypt id v1"
+	scryptIDWorkFactor = 16
+)
+
+// scryptSaltPrefix starts the key file line with the bucket's salt for that
+// derivation, the salt of its KDF parameters. Without one, as in key files
+// saved before buckets had a salt, the derivation uses a fixed salt.
+const scryptSaltPrefix = "# salt: "
+
+func init() {
+	RegisterScheme(SchemeScrypt, func(keyPath string, recipients []string) (Cipher, error) {
+		return NewScryptCipher(keyPath, recipients)
+	})
+}
+
+// ScryptCipher is the SchemeScrypt Cipher.
+type ScryptCipher struct {
+	passphrase string
+	keyID      string
+	signer     ed25519.PrivateKey
+}
+
+// SaveScryptKey writes passphrase to keyPath as an age-scrypt key file,
+// user-only, with the salt of the bucket's params (see sync.ResolveKDFParams)
+// for KeyID and the signing key. Like a plaintext age key, the file alone
+// opens the bucket.
+func SaveScryptKey(keyPath, passphrase string, params KDFParams) error {
+	if passphrase == "" || strings.ContainsAny(passphrase, "\r\n") {
+		return errors.New("age-scrypt passphrase must be a single non-empty line")
+	}
+	if _, err := params.salt(); err != nil {
+		return err
+	}
+	data := scryptKeyHeader + "\n"
+	if !params.IsLegacy() {
+		data += scryptSaltPrefix + params.Salt + "\n"
+	}
+	data += passphrase + "\n"
+	if err := os.WriteFile(keyPath, []byte(data), 0600); err != nil {
+		return fmt.Errorf("failed to write age-scrypt key: %w", err)
+	}
+	return nil
+}
+
+// IsScryptKeyFile reports whether the key file at keyPath holds an
+// age-scrypt passphrase.
+func IsScryptKeyFile(keyPath string) bool {
+	data, err := os.ReadFile(keyPath)
+	return err == nil && bytes.HasPrefix(data, []byte(scryptKeyHeader+"\n"))
+}
+
+// NewScryptCipher reads the passphrase saved by SaveScryptKey. age only
+// allows a scrypt recipient on its own, so there can be no recipients.
+func NewScryptCipher(keyPath string, recipients []string) (*ScryptCipher, error) {
+	if len(recipients) > 0 {
+		return nil, errors.New("age-scrypt encrypts to the passphrase alone; remove recipients from the config")
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read age-scrypt key: %w", err)
+	}
+	rest, ok := bytes.CutPrefix(data, []byte(scryptKeyHeader+"\n"))
+	if !ok {
+		return nil, fmt.Errorf("%s is not an age-scrypt key file", keyPath)
+	}
+	var params KDFParams
+	if line, after, found := bytes.Cut(rest, []byte("\n")); found && bytes.HasPrefix(line, []byte(scryptSaltPrefix)) {
+		params.Salt = strings.TrimSpace(string(line[len(scryptSaltPrefix):]))
+		rest = after
+	}
+	return newScryptCipher(strings.TrimRight(string(rest), "\r\n"), params)
+}
+
+func newScryptCipher(passphrase string, params KDFParams) (*ScryptCipher, error) {
+	if passphrase == "" {
+		return nil, errors.New("age-scrypt passphrase is empty")
+	}
+	salt := []byte(scryptIDContext)
+	if !params.IsLegacy() {
+		bucketSalt, err := params.salt()
+		if err != nil {
+			return nil, err
+		}
+		salt = append(salt, bucketSalt...)
+	}
+	sum := sha256.Sum256(salt)
+	secret, err := scrypt.Key([]byte(passphrase), sum[:], 1<<scryptIDWorkFactor, 8, 1, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive age-scrypt key ID: %w", err)
+	}
+	return &ScryptCipher{
+		passphrase: passphrase,
+		keyID:      KeyFingerprint(SchemeScrypt + ":" + hex.EncodeToString(secret)),
+		signer:     newSigningKey(secret),
+	}, nil
+}
+
+func (c *ScryptCipher) recipient() (*age.ScryptRecipient, error) {
+	r, err := age.NewScryptRecipient(c.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	r.SetWorkFactor(scryptWorkFactor)
+	return r, nil
+}
+
+func (c *ScryptCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := c.EncryptWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, fmt.Errorf("failed to write encrypted data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize encryption: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *ScryptCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	r, err := c.DecryptReader(bytes.NewReader(ciphertext))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read decrypted data: %w", err)
+	}
+	return plaintext, nil
+}
+
+// EncryptWriter implements StreamCipher.
+func (c *ScryptCipher) EncryptWriter(w io.Writer) (io.WriteCloser, error) {
+	r, err := c.recipient()
+	if err != nil {
+		return nil, err
+	}
+	ew, err := age.Encrypt(w, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encryption writer: %w", err)
+	}
+	return ew, nil
+}
+
+// DecryptReader implements StreamCipher.
+func (c *ScryptCipher) DecryptReader(r io.Reader) (io.Reader, error) {
+	identity, err := age.NewScryptIdentity(c.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	dr, err := age.Decrypt(r, identity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return dr, nil
+}
+
+// KeyID implements Cipher with a fingerprint of a scrypt derivation of the
+// passphrase, the same on every device using it.
+func (c *ScryptCipher) KeyID() string {
+	return c.keyID
+}
+
+// Sign implements Signer with a key derived from the passphrase.
+func (c *ScryptCipher) Sign(msg []byte) ([]byte, error) {
+	return ed25519.Sign(c.signer, msg), nil
+}
+
+// SigningPublicKey implements Signer.
+func (c *ScryptCipher) SigningPublicKey() string {
+	return base64.StdEncoding.EncodeToString(c.signer.Public().(ed25519.PublicKey))
+}
@@ -0,0 +1,158 @@
+package crypto
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestScryptCipher(t *testing.T) {
+	defer func(wf int) { scryptWorkFactor = wf }(scryptWorkFactor)
+	scryptWorkFactor = 10
+
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "age-key.txt")
+	if err := SaveScryptKey(keyPath, "correct horse battery staple", KDFParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if !IsScryptKeyFile(keyPath) {
+		t.Error("IsScryptKeyFile = false for a saved key")
+	}
+
+	c, err := NewCipher(SchemeScrypt, keyPath, nil)
+	if err != nil {
+		t.Fatalf("NewCipher(age-scrypt): %v", err)
+	}
+	sealed, err := c.Encrypt([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stock age opens it with the passphrase alone
+	identity, err := age.NewScryptIdentity("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := age.Decrypt(bytes.NewReader(sealed), identity)
+	if err != nil {
+		t.Fatalf("age.Decrypt: %v", err)
+	}
+	if plain, _ := io.ReadAll(r); string(plain) != "hello" {
+		t.Errorf("age.Decrypt = %q", plain)
+	}
+	if plain, err := c.Decrypt(sealed); err != nil || string(plain) != "hello" {
+		t.Errorf("Decrypt = %q, %v", plain, err)
+	}
+
+	// The same passphrase gives the same key ID and signer on another device
+	otherPath := filepath.Join(dir, "other.txt")
+	if err := SaveScryptKey(otherPath, "correct horse battery staple", KDFParams{}); err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewScryptCipher(otherPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.KeyID() != c.KeyID() || other.SigningPublicKey() != c.(Signer).SigningPublicKey() {
+		t.Error("same passphrase gave a different key ID or signing key")
+	}
+	if strings.Contains(c.KeyID(), "horse") {
+		t.Errorf("KeyID %q reveals the passphrase", c.KeyID())
+	}
+
+	wrongPath := filepath.Join(dir, "wrong.txt")
+	if err := SaveScryptKey(wrongPath, "a different passphrase", KDFParams{}); err != nil {
+		t.Fatal(err)
+	}
+	wrong, err := NewScryptCipher(wrongPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wrong.KeyID() == c.KeyID() {
+		t.Error("different passphrases share a key ID")
+	}
+	if _, err := wrong.Decrypt(sealed); err == nil {
+		t.Error("a different passphrase decrypted the object")
+	}
+}
+
+func TestScryptCipherSalt(t *testing.T) {
+	dir := t.TempDir()
+	keyID := func(name string, params KDFParams) string {
+		t.Helper()
+		keyPath := filepath.Join(dir, name)
+		if err := SaveScryptKey(keyPath, "correct horse battery staple", params); err != nil {
+			t.Fatal(err)
+		}
+		c, err := NewScryptCipher(keyPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.KeyID()
+	}
+	bucketA, err := NewKDFParams(0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucketB, err := NewKDFParams(0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each bucket's salt gives the passphrase its own key ID, the same on
+	// every device of that bucket
+	legacy := keyID("legacy.txt", KDFParams{})
+	a := keyID("a.txt", bucketA)
+	if a == legacy || a == keyID("b.txt", bucketB) {
+		t.Error("buckets with different salts share a key ID")
+	}
+	if keyID("a2.txt", bucketA) != a {
+		t.Error("the same salt gave a different key ID")
+	}
+
+	// A key file without a salt line keeps the fixed-salt key ID
+	oldPath := filepath.Join(dir, "old.txt")
+	if err := os.WriteFile(oldPath, []byte(scryptKeyHeader+"\ncorrect horse battery staple\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old, err := NewScryptCipher(oldPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.KeyID() != legacy {
+		t.Error("a key file without a salt changed its key ID")
+	}
+	if err := SaveScryptKey(filepath.Join(dir, "bad.txt"), "x", KDFParams{Salt: "!"}); err == nil {
+		t.Error("SaveScryptKey accepted an invalid salt")
+	}
+}
+
+func TestScryptCipherRejects(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "age-key.txt")
+	if err := SaveScryptKey(keyPath, "one\ntwo", KDFParams{}); err == nil {
+		t.Error("SaveScryptKey accepted a multi-line passphrase")
+	}
+	if err := SaveScryptKey(keyPath, "correct horse battery staple", KDFParams{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewScryptCipher(keyPath, []string{"age1qyqszqgpqyqszqgpqyqszqgpqyqszqgpqyqszqgpqyqszqgpqyqs3290gq"}); err == nil {
+		t.Error("NewScryptCipher accepted extra recipients")
+	}
+
+	agePath := filepath.Join(dir, "x25519.txt")
+	if err := GenerateKey(agePath); err != nil {
+		t.Fatal(err)
+	}
+	if IsScryptKeyFile(agePath) {
+		t.Error("IsScryptKeyFile = true for an age key")
+	}
+	if _, err := NewScryptCipher(agePath, nil); err == nil {
+		t.Error("NewScryptCipher accepted an age key file")
+	}
+}
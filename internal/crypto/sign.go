@@ -0,0 +1,50 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// signingKeyContext separates the signing key from anything else derived
+// from the identity.
+const signingKeyContext = "claude-sync signing key v1\n"
+
+// ErrCannotSign is returned by Sign for keys whose secret isn't available to
+// claude-sync, such as age plugin identities kept on a hardware token.
+var ErrCannotSign = errors.New("this key can't sign (age plugin identities keep their secret on the token)")
+
+// newSigningKey derives an Ed25519 key from an identity's secret, so every
+// device holding the same identity signs with the same key.
+func newSigningKey(secret []byte) ed25519.PrivateKey {
+	seed := sha256.Sum256(append([]byte(signingKeyContext), secret...))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+// Sign signs msg with the key derived from the identity.
+func (e *Encryptor) Sign(msg []byte) ([]byte, error) {
+	if e.signer == nil {
+		return nil, ErrCannotSign
+	}
+	return ed25519.Sign(e.signer, msg), nil
+}
+
+// SigningPublicKey returns the public half of the signing key, base64
+// encoded, or "" when the key can't sign.
+func (e *Encryptor) SigningPublicKey() string {
+	if e.signer == nil {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(e.signer.Public().(ed25519.PublicKey))
+}
+
+// VerifySignature reports whether sig is a valid signature of msg by the
+// signing key whose public half is publicKey (as from SigningPublicKey).
+func VerifySignature(publicKey string, msg, sig []byte) bool {
+	pub, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(pub), msg, sig)
+}
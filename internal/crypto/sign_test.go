@@ -0,0 +1,79 @@
+package crypto
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSignWithPassphraseKey(t *testing.T) {
+	dir := t.TempDir()
+	keyA := filepath.Join(dir, "a.txt")
+	keyB := filepath.Join(dir, "b.txt")
+	for _, p := range []string{keyA, keyB} {
+		if err := GenerateKeyFromPassphrase(p, "same passphrase"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := NewEncryptor(keyA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewEncryptor(keyB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := []byte("attested")
+	sig, err := a.Sign(msg)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if a.SigningPublicKey() != b.SigningPublicKey() {
+		t.Error("Expected devices with the same passphrase to share a signing key")
+	}
+	if !VerifySignature(b.SigningPublicKey(), msg, sig) {
+		t.Error("Expected the signature to verify")
+	}
+	if VerifySignature(a.SigningPublicKey(), []byte("tampered"), sig) {
+		t.Error("Expected a signature over other data to fail")
+	}
+}
+
+func TestSignKeysDiffer(t *testing.T) {
+	dir := t.TempDir()
+	keyA := filepath.Join(dir, "a.txt")
+	keyB := filepath.Join(dir, "b.txt")
+	if err := GenerateKey(keyA); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateKey(keyB); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := NewEncryptor(keyA)
+	b, _ := NewEncryptor(keyB)
+
+	sig, err := a.Sign([]byte("msg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if VerifySignature(b.SigningPublicKey(), []byte("msg"), sig) {
+		t.Error("Expected another key's signature to fail")
+	}
+	if VerifySignature("not base64!", []byte("msg"), sig) {
+		t.Error("Expected a malformed public key to fail")
+	}
+}
+
+func TestSignWithSSHKey(t *testing.T) {
+	enc, err := NewEncryptor(writeSSHKey(t, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := enc.Sign([]byte("msg"))
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if !VerifySignature(enc.SigningPublicKey(), []byte("msg"), sig) {
+		t.Error("Expected the signature to verify")
+	}
+}
@@ -0,0 +1,88 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"golang.org/x/crypto/ssh"
+)
+
+// isSSHPrivateKey reports whether a key file holds an SSH private key, such
+// as ~/.ssh/id_ed25519, rather than an age identity.
+func isSSHPrivateKey(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) && !isProtectedKey(data)
+}
+
+// IsSSHKeyFile reports whether the key file at keyPath is an SSH private key.
+func IsSSHKeyFile(keyPath string) bool {
+	data, err := os.ReadFile(keyPath)
+	return err == nil && isSSHPrivateKey(data)
+}
+
+// newSSHEncryptor uses an ssh-ed25519 or ssh-rsa private key as the identity,
+// encrypting to its public key the way age's SSH support does.
+func newSSHEncryptor(keyPath string, pemBytes []byte) (*Encryptor, error) {
+	key, err := ssh.ParseRawPrivateKey(pemBytes)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if KeyPassphrase == nil {
+			return nil, fmt.Errorf("SSH key %s is passphrase-protected", keyPath)
+		}
+		passphrase, perr := KeyPassphrase(keyPath)
+		if perr != nil {
+			return nil, perr
+		}
+		key, err = ssh.ParseRawPrivateKeyWithPassphrase(pemBytes, passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH key: %w", err)
+	}
+
+	var identity age.Identity
+	switch k := key.(type) {
+	case *ed25519.PrivateKey:
+		identity, err = agessh.NewEd25519Identity(*k)
+	case ed25519.PrivateKey:
+		identity, err = agessh.NewEd25519Identity(k)
+	case *rsa.PrivateKey:
+		identity, err = agessh.NewRSAIdentity(k)
+	default:
+		return nil, fmt.Errorf("unsupported SSH key type %T (use ssh-ed25519 or ssh-rsa)", key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to use SSH key: %w", err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use SSH key: %w", err)
+	}
+	publicKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+	recipient, err := agessh.ParseRecipient(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use SSH key: %w", err)
+	}
+
+	if k, ok := key.(*ed25519.PrivateKey); ok {
+		key = *k
+	}
+	secret, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use SSH key: %w", err)
+	}
+
+	return &Encryptor{
+		identity:  identity,
+		recipient: recipient,
+		publicKey: publicKey,
+		signer:    newSigningKey(secret),
+	}, nil
+}
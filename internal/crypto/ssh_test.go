@@ -0,0 +1,117 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// writeSSHKey writes a new ssh-ed25519 private key, protected when
+// passphrase is non-empty, and returns its path.
+func writeSSHKey(t *testing.T, passphrase string) string {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return keyPath
+}
+
+func TestSSHKeyEncryptor(t *testing.T) {
+	keyPath := writeSSHKey(t, "")
+	if !IsSSHKeyFile(keyPath) {
+		t.Fatal("Expected an SSH key file")
+	}
+
+	enc, err := NewEncryptor(keyPath)
+	if err != nil {
+		t.Fatalf("NewEncryptor failed: %v", err)
+	}
+	if !strings.HasPrefix(enc.PublicKey(), "ssh-ed25519 ") {
+		t.Errorf("PublicKey = %q, want an ssh-ed25519 key", enc.PublicKey())
+	}
+
+	plaintext := []byte("encrypted to an SSH key")
+	ciphertext, err := enc.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	decrypted, err := enc.Decrypt(ciphertext)
+	if err != nil || string(decrypted) != string(plaintext) {
+		t.Errorf("Decrypt = %q, %v", decrypted, err)
+	}
+}
+
+func TestSSHKeyAsRecipient(t *testing.T) {
+	sshEnc, err := NewEncryptor(writeSSHKey(t, ""))
+	if err != nil {
+		t.Fatalf("NewEncryptor failed: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "age-key.txt")
+	if err := GenerateKey(keyPath); err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := NewEncryptorWithRecipients(keyPath, []string{sshEnc.PublicKey() + " user@laptop"})
+	if err != nil {
+		t.Fatalf("NewEncryptorWithRecipients failed: %v", err)
+	}
+	ciphertext, err := enc.Encrypt([]byte("for the SSH key too"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if _, err := sshEnc.Decrypt(ciphertext); err != nil {
+		t.Errorf("SSH key can't decrypt: %v", err)
+	}
+
+	if _, err := ParseRecipient("ssh-ed25519 notakey"); err == nil {
+		t.Error("Expected an error for an invalid SSH recipient")
+	}
+}
+
+func TestProtectedSSHKey(t *testing.T) {
+	keyPath := writeSSHKey(t, "correct horse")
+	defer func(prev func(string) ([]byte, error)) { KeyPassphrase = prev }(KeyPassphrase)
+
+	KeyPassphrase = nil
+	if _, err := NewEncryptor(keyPath); err == nil {
+		t.Error("Expected an error without a passphrase prompt")
+	}
+
+	KeyPassphrase = func(string) ([]byte, error) { return []byte("wrong"), nil }
+	if _, err := NewEncryptor(keyPath); err == nil {
+		t.Error("Expected an error for a wrong passphrase")
+	}
+
+	KeyPassphrase = func(string) ([]byte, error) { return []byte("correct horse"), nil }
+	enc, err := NewEncryptor(keyPath)
+	if err != nil {
+		t.Fatalf("NewEncryptor failed: %v", err)
+	}
+	ciphertext, err := enc.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if got, err := enc.Decrypt(ciphertext); err != nil || string(got) != "secret" {
+		t.Errorf("Decrypt = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,223 @@
+// Package diff computes line-based differences between two versions of a
+// file, grouped into hunks that can be accepted individually and merged.
+package diff
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Hunk is a run of differing lines. LocalStart and RemoteStart are 0-based
+// line indexes where the hunk begins in each version; either side may be empty.
+type Hunk struct {
+	LocalStart  int
+	RemoteStart int
+	Local       []string
+	Remote      []string
+}
+
+// Diff holds both versions split into lines and the hunks between them.
+// Lines keep their trailing "\n" so that merging is plain concatenation and a
+// missing final newline shows up as a difference.
+type Diff struct {
+	Local  []string
+	Remote []string
+	Hunks  []Hunk
+}
+
+// Row is one line of a side-by-side rendering. LocalNo and RemoteNo are
+// 1-based line numbers, 0 where that side has no line. Hunk is the index of
+// the hunk the row belongs to, or -1 for unchanged lines.
+type Row struct {
+	Local    string
+	Remote   string
+	LocalNo  int
+	RemoteNo int
+	Hunk     int
+}
+
+// SplitLines splits data into lines, each keeping its "\n" terminator.
+func SplitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lines = append(lines, string(data))
+			break
+		}
+		lines = append(lines, string(data[:i+1]))
+		data = data[i+1:]
+	}
+	return lines
+}
+
+// IsBinary reports whether data looks like a binary file (contains NUL bytes
+// in its first 8KB), for which a line diff is meaningless.
+func IsBinary(data []byte) bool {
+	if len(data) > 8192 {
+		data = data[:8192]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// Compute diffs two file contents line by line, using difflib's sequence
+// matcher (the algorithm of Python's difflib).
+func Compute(local, remote []byte) *Diff {
+	d := &Diff{Local: SplitLines(local), Remote: SplitLines(remote)}
+	for _, op := range difflib.NewMatcher(d.Local, d.Remote).GetOpCodes() {
+		if op.Tag == 'e' {
+			continue
+		}
+		h := Hunk{LocalStart: op.I1, RemoteStart: op.J1}
+		if op.I2 > op.I1 {
+			h.Local = d.Local[op.I1:op.I2]
+		}
+		if op.J2 > op.J1 {
+			h.Remote = d.Remote[op.J1:op.J2]
+		}
+		d.Hunks = append(d.Hunks, h)
+	}
+	return d
+}
+
+// Merge rebuilds the file taking each hunk from the remote side where
+// useRemote[i] is true and from the local side otherwise. Unchanged lines are
+// kept as-is.
+func (d *Diff) Merge(useRemote []bool) []byte {
+	var buf bytes.Buffer
+	pos := 0 // next local line to copy
+	for i, h := range d.Hunks {
+		for _, line := range d.Local[pos:h.LocalStart] {
+			buf.WriteString(line)
+		}
+		side := h.Local
+		if i < len(useRemote) && useRemote[i] {
+			side = h.Remote
+		}
+		for _, line := range side {
+			buf.WriteString(line)
+		}
+		pos = h.LocalStart + len(h.Local)
+	}
+	for _, line := range d.Local[pos:] {
+		buf.WriteString(line)
+	}
+	return buf.Bytes()
+}
+
+// Rows lays the diff out side by side. Within a hunk, local and remote lines
+// are paired in order and the shorter side is padded with empty cells.
+func (d *Diff) Rows() []Row {
+	var rows []Row
+	pos := 0
+	for hi, h := range d.Hunks {
+		for ; pos < h.LocalStart; pos++ {
+			rows = append(rows, equalRow(d.Local[pos], pos, pos-h.LocalStart+h.RemoteStart))
+		}
+		n := len(h.Local)
+		if len(h.Remote) > n {
+			n = len(h.Remote)
+		}
+		for k := 0; k < n; k++ {
+			row := Row{Hunk: hi}
+			if k < len(h.Local) {
+				row.Local = trimEOL(h.Local[k])
+				row.LocalNo = h.LocalStart + k + 1
+			}
+			if k < len(h.Remote) {
+				row.Remote = trimEOL(h.Remote[k])
+				row.RemoteNo = h.RemoteStart + k + 1
+			}
+			rows = append(rows, row)
+		}
+		pos = h.LocalStart + len(h.Local)
+	}
+
+	// Remote index follows local index by a fixed offset after the last hunk
+	shift := len(d.Remote) - len(d.Local)
+	for ; pos < len(d.Local); pos++ {
+		rows = append(rows, equalRow(d.Local[pos], pos, pos+shift))
+	}
+	return rows
+}
+
+func equalRow(line string, localIdx, remoteIdx int) Row {
+	text := trimEOL(line)
+	return Row{Local: text, Remote: text, LocalNo: localIdx + 1, RemoteNo: remoteIdx + 1, Hunk: -1}
+}
+
+func trimEOL(line string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+}
+
+// Unified renders the diff in unified format, local as the old file and
+// remote as the new one, with context unchanged lines around each hunk.
+// It returns "" when the versions are identical.
+func (d *Diff) Unified(localName, remoteName string, context int) string {
+	if len(d.Hunks) == 0 {
+		return ""
+	}
+	var buf strings.Builder
+	buf.WriteString("--- " + localName + "\n")
+	buf.WriteString("+++ " + remoteName + "\n")
+
+	// Hunks closer than twice the context share one @@ block
+	for first := 0; first < len(d.Hunks); {
+		last := first
+		for last+1 < len(d.Hunks) && d.Hunks[last+1].LocalStart-localEnd(d.Hunks[last]) <= 2*context {
+			last++
+		}
+		start := max(d.Hunks[first].LocalStart-context, 0)
+		end := min(localEnd(d.Hunks[last])+context, len(d.Local))
+		shiftStart := d.Hunks[first].RemoteStart - d.Hunks[first].LocalStart
+		shiftEnd := d.Hunks[last].RemoteStart + len(d.Hunks[last].Remote) - localEnd(d.Hunks[last])
+		buf.WriteString("@@ -" + hunkRange(start, end-start) + " +" + hunkRange(start+shiftStart, end-start+shiftEnd-shiftStart) + " @@\n")
+
+		pos := start
+		for _, h := range d.Hunks[first : last+1] {
+			for ; pos < h.LocalStart; pos++ {
+				writeLine(&buf, ' ', d.Local[pos])
+			}
+			for _, line := range h.Local {
+				writeLine(&buf, '-', line)
+			}
+			for _, line := range h.Remote {
+				writeLine(&buf, '+', line)
+			}
+			pos = localEnd(h)
+		}
+		for ; pos < end; pos++ {
+			writeLine(&buf, ' ', d.Local[pos])
+		}
+		first = last + 1
+	}
+	return buf.String()
+}
+
+func localEnd(h Hunk) int {
+	return h.LocalStart + len(h.Local)
+}
+
+// hunkRange formats the 0-based start and length of one side of an @@
+// header the way diff -u does: 1-based, ",1" left out, and an empty range
+// named by the line before it.
+func hunkRange(start, length int) string {
+	switch length {
+	case 0:
+		return strconv.Itoa(start) + ",0"
+	case 1:
+		return strconv.Itoa(start + 1)
+	}
+	return strconv.Itoa(start+1) + "," + strconv.Itoa(length)
+}
+
+func writeLine(buf *strings.Builder, prefix byte, line string) {
+	buf.WriteByte(prefix)
+	buf.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		buf.WriteString("\n\\ No newline at end of file\n")
+	}
+}
@@ -0,0 +1,128 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitLines(t *testing.T) {
+	got := SplitLines([]byte("a\nb\n\nc"))
+	want := []string{"a\n", "b\n", "\n", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitLines = %q, want %q", got, want)
+	}
+	if got := SplitLines(nil); len(got) != 0 {
+		t.Errorf("SplitLines(nil) = %q, want empty", got)
+	}
+}
+
+func TestComputeHunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		local  string
+		remote string
+		want   []Hunk
+	}{
+		{"identical", "a\nb\n", "a\nb\n", nil},
+		{"one change", "a\nb\nc\n", "a\nB\nc\n", []Hunk{
+			{LocalStart: 1, RemoteStart: 1, Local: []string{"b\n"}, Remote: []string{"B\n"}},
+		}},
+		{"insert and delete", "a\nb\nc\nd\n", "a\nc\nd\ne\n", []Hunk{
+			{LocalStart: 1, RemoteStart: 1, Local: []string{"b\n"}},
+			{LocalStart: 4, RemoteStart: 3, Remote: []string{"e\n"}},
+		}},
+		{"missing final newline", "a\nb\n", "a\nb", []Hunk{
+			{LocalStart: 1, RemoteStart: 1, Local: []string{"b\n"}, Remote: []string{"b"}},
+		}},
+		{"local empty", "", "x\n", []Hunk{
+			{LocalStart: 0, RemoteStart: 0, Remote: []string{"x\n"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Compute([]byte(tt.local), []byte(tt.remote))
+			if !reflect.DeepEqual(d.Hunks, tt.want) {
+				t.Errorf("Hunks = %+v, want %+v", d.Hunks, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	local := "title\nlocal one\nshared\nlocal two\nend\n"
+	remote := "title\nremote one\nshared\nremote two\nend\n"
+	d := Compute([]byte(local), []byte(remote))
+	if len(d.Hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %d", len(d.Hunks))
+	}
+
+	tests := []struct {
+		useRemote []bool
+		want      string
+	}{
+		{nil, local},
+		{[]bool{true, true}, remote},
+		{[]bool{true, false}, "title\nremote one\nshared\nlocal two\nend\n"},
+		{[]bool{false, true}, "title\nlocal one\nshared\nremote two\nend\n"},
+	}
+	for _, tt := range tests {
+		if got := string(d.Merge(tt.useRemote)); got != tt.want {
+			t.Errorf("Merge(%v) = %q, want %q", tt.useRemote, got, tt.want)
+		}
+	}
+}
+
+func TestRows(t *testing.T) {
+	d := Compute([]byte("a\nb\nc\n"), []byte("a\nx\ny\nc\n"))
+	rows := d.Rows()
+	want := []Row{
+		{Local: "a", Remote: "a", LocalNo: 1, RemoteNo: 1, Hunk: -1},
+		{Local: "b", Remote: "x", LocalNo: 2, RemoteNo: 2, Hunk: 0},
+		{Remote: "y", RemoteNo: 3, Hunk: 0},
+		{Local: "c", Remote: "c", LocalNo: 3, RemoteNo: 4, Hunk: -1},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Rows = %+v, want %+v", rows, want)
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	if IsBinary([]byte("plain text\n")) {
+		t.Error("Expected text not to be binary")
+	}
+	if !IsBinary([]byte{'P', 'K', 0, 1}) {
+		t.Error("Expected NUL bytes to mark binary")
+	}
+}
+
+func TestUnified(t *testing.T) {
+	local := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	remote := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13"
+	got := Compute([]byte(local), []byte(remote)).Unified("local/a", "remote/a", 2)
+	want := `--- local/a
++++ remote/a
+@@ -1,5 +1,5 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+@@ -11,2 +11,3 @@
+ 11
+ 12
++13
+\ No newline at end of file
+`
+	if got != want {
+		t.Errorf("Unified =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := Compute([]byte(local), []byte(local)).Unified("a", "b", 3); got != "" {
+		t.Errorf("Unified of identical files = %q, want empty", got)
+	}
+	if got := Compute(nil, []byte("x\n")).Unified("a", "b", 3); got != "--- a\n+++ b\n@@ -0,0 +1 @@\n+x\n" {
+		t.Errorf("Unified of a new file = %q", got)
+	}
+}
@@ -0,0 +1,241 @@
+// Package awskms wraps file keys with an AWS KMS key. It speaks the KMS
+// JSON API directly, signed with the SDK's SigV4 signer, rather than pulling
+// in the KMS client for two calls.
+package awskms
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/kms"
+	"github.com/tawanorg/claude-sync/internal/storage"
+	"github.com/tawanorg/claude-sync/internal/storage/s3"
+)
+
+func init() {
+	kms.NewAWS = New
+}
+
+// stanzaType marks the header stanza holding a file key wrapped by KMS. Its
+// argument is the key's ARN and its body the KMS ciphertext.
+const stanzaType = "aws-kms"
+
+// encryptionContext is bound to every wrapped key, so CloudTrail shows what
+// a KMS call was for and ciphertexts can't be passed off as anything else.
+var encryptionContext = map[string]string{"claude-sync": "file-key"}
+
+// requestTimeout bounds each KMS call; age's Wrap and Unwrap take no
+// context.
+const requestTimeout = 30 * time.Second
+
+// Service wraps and unwraps age file keys with one KMS key.
+type Service struct {
+	keyID    string
+	region   string
+	endpoint string
+	creds    aws.CredentialsProvider
+	signer   *v4.Signer
+	client   *http.Client
+}
+
+// New creates a key service for cfg.KMS. An S3 bucket's access key (or AWS
+// profile, or assumed role) is used for KMS too; other providers' keys aren't AWS keys, so
+// they use the default credential chain (environment, shared config,
+// instance role).
+func New(cfg *storage.StorageConfig) (crypto.KeyService, error) {
+	k := cfg.KMS
+	region := k.Region
+	if region == "" {
+		region = regionFromARN(k.KeyID)
+	}
+	if region == "" && cfg.Provider == storage.ProviderS3 {
+		region = cfg.Region
+	}
+
+	var opts []func(*config.LoadOptions) error
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	if cfg.Provider == storage.ProviderS3 && cfg.UseDefaultCredentials && cfg.Profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(cfg.Profile))
+	}
+	if cfg.Provider == storage.ProviderS3 && cfg.Endpoint == "" && cfg.AccessKeyID != "" && !cfg.UseDefaultCredentials {
+		opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			cfg.AccessKeyID,
+			cfg.SecretAccessKey,
+			"",
+		)))
+	}
+	awsCfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if awsCfg.Region == "" {
+		return nil, fmt.Errorf("kms region is required (set kms.region or use a key ARN)")
+	}
+	if cfg.Provider == storage.ProviderS3 && cfg.Endpoint == "" {
+		s3.AssumeRole(&awsCfg, cfg)
+	}
+	if awsCfg.Credentials == nil {
+		return nil, fmt.Errorf("no AWS credentials found for kms")
+	}
+
+	endpoint := storage.NormalizeEndpoint(k.Endpoint)
+	if endpoint == "" {
+		endpoint = "https://kms." + awsCfg.Region + ".amazonaws.com"
+	}
+	return &Service{
+		keyID:    k.KeyID,
+		region:   awsCfg.Region,
+		endpoint: endpoint,
+		creds:    awsCfg.Credentials,
+		signer:   v4.NewSigner(),
+		client:   &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// regionFromARN returns the region of a key ARN
+// (arn:aws:kms:<region>:<account>:key/<id>), or "".
+func regionFromARN(keyID string) string {
+	parts := strings.Split(keyID, ":")
+	if len(parts) < 6 || parts[0] != "arn" || parts[2] != "kms" {
+		return ""
+	}
+	return parts[3]
+}
+
+type encryptRequest struct {
+	KeyId             string            `json:"KeyId"`
+	Plaintext         []byte            `json:"Plaintext"`
+	EncryptionContext map[string]string `json:"EncryptionContext"`
+}
+
+type decryptRequest struct {
+	KeyId             string            `json:"KeyId"`
+	CiphertextBlob    []byte            `json:"CiphertextBlob"`
+	EncryptionContext map[string]string `json:"EncryptionContext"`
+}
+
+type kmsResponse struct {
+	KeyId          string `json:"KeyId"`
+	CiphertextBlob []byte `json:"CiphertextBlob"`
+	Plaintext      []byte `json:"Plaintext"`
+}
+
+// Wrap implements age.Recipient.
+func (s *Service) Wrap(fileKey []byte) ([]*age.Stanza, error) {
+	var resp kmsResponse
+	err := s.call("Encrypt", encryptRequest{
+		KeyId:             s.keyID,
+		Plaintext:         fileKey,
+		EncryptionContext: encryptionContext,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	keyID := resp.KeyId
+	if keyID == "" {
+		keyID = s.keyID
+	}
+	return []*age.Stanza{{Type: stanzaType, Args: []string{keyID}, Body: resp.CiphertextBlob}}, nil
+}
+
+// Unwrap implements age.Identity. Failures wrap age.ErrIncorrectIdentity,
+// so decryption goes on to the age key when KMS can't be reached or
+// refuses.
+func (s *Service) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	var errs []string
+	for _, st := range stanzas {
+		if st.Type != stanzaType || len(st.Args) != 1 {
+			continue
+		}
+		var resp kmsResponse
+		err := s.call("Decrypt", decryptRequest{
+			KeyId:             st.Args[0],
+			CiphertextBlob:    st.Body,
+			EncryptionContext: encryptionContext,
+		}, &resp)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		return resp.Plaintext, nil
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %s", age.ErrIncorrectIdentity, strings.Join(errs, "; "))
+	}
+	return nil, age.ErrIncorrectIdentity
+}
+
+// call makes one signed KMS JSON API request.
+func (s *Service) call(action string, in, out interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid kms endpoint: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+
+	creds, err := s.creds.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get AWS credentials for kms: %w", err)
+	}
+	hash := sha256.Sum256(body)
+	if err := s.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "kms", s.region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign kms request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("kms %s failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type         string `json:"__type"`
+			Message      string `json:"message"`
+			MessageUpper string `json:"Message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		kind := apiErr.Type
+		if i := strings.LastIndexByte(kind, '#'); i >= 0 {
+			kind = kind[i+1:]
+		}
+		if kind == "" {
+			kind = resp.Status
+		}
+		message := apiErr.Message
+		if message == "" {
+			message = apiErr.MessageUpper
+		}
+		if message == "" {
+			return fmt.Errorf("kms %s failed: %s", action, kind)
+		}
+		return fmt.Errorf("kms %s failed: %s: %s", action, kind, message)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid kms %s response: %w", action, err)
+	}
+	return nil
+}
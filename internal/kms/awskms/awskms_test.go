@@ -0,0 +1,194 @@
+package awskms
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"filippo.io/age"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// fakeKMS "encrypts" by prefixing the plaintext, checking each request is
+// signed for KMS and carries the encryption context.
+type fakeKMS struct {
+	*httptest.Server
+	decrypts atomic.Int32
+	deny     atomic.Bool
+}
+
+func newFakeKMS(t *testing.T) *fakeKMS {
+	f := &fakeKMS{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256") || !strings.Contains(auth, "/us-east-1/kms/aws4_request") {
+			t.Errorf("request isn't signed for kms in us-east-1: %q", auth)
+		}
+		var req struct {
+			KeyId             string
+			Plaintext         []byte
+			CiphertextBlob    []byte
+			EncryptionContext map[string]string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		if req.EncryptionContext["claude-sync"] != "file-key" {
+			t.Errorf("EncryptionContext = %v", req.EncryptionContext)
+		}
+		if f.deny.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.kms#AccessDeniedException","message":"not allowed"}`))
+			return
+		}
+
+		const arn = "arn:aws:kms:us-east-1:111122223333:key/test"
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.Encrypt":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"KeyId":          arn,
+				"CiphertextBlob": append([]byte("kms:"), req.Plaintext...),
+			})
+		case "TrentService.Decrypt":
+			f.decrypts.Add(1)
+			if req.KeyId != arn || !bytes.HasPrefix(req.CiphertextBlob, []byte("kms:")) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"InvalidCiphertextException"}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"KeyId":     arn,
+				"Plaintext": req.CiphertextBlob[len("kms:"):],
+			})
+		default:
+			t.Errorf("unexpected target %q", r.Header.Get("X-Amz-Target"))
+		}
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func newService(t *testing.T, endpoint string) crypto.KeyService {
+	t.Helper()
+	ks, err := New(&storage.StorageConfig{
+		Provider:        storage.ProviderS3,
+		Bucket:          "bucket",
+		AccessKeyID:     "AKIATEST",
+		SecretAccessKey: "secret",
+		Region:          "us-east-1",
+		KMS:             &storage.KMSConfig{Provider: storage.KMSProviderAWS, KeyID: "alias/claude-sync", Endpoint: endpoint},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return ks
+}
+
+func newEncryptor(t *testing.T) *crypto.Encryptor {
+	t.Helper()
+	identity, err := crypto.GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := crypto.NewEncryptorFromIdentity(identity, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc
+}
+
+func TestServiceWrapsFileKeys(t *testing.T) {
+	fake := newFakeKMS(t)
+	ks := newService(t, fake.URL)
+
+	ciphertext, err := newEncryptor(t).WithKeyService(ks).Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	// A different age key can't open the file, but KMS can
+	other := newEncryptor(t)
+	if _, err := other.Decrypt(ciphertext); err == nil {
+		t.Fatal("another age key opened the file")
+	}
+	plaintext, err := other.WithKeyService(ks).Decrypt(ciphertext)
+	if err != nil {
+		t.Fatalf("Decrypt through KMS: %v", err)
+	}
+	if string(plaintext) != "secret" {
+		t.Errorf("plaintext = %q", plaintext)
+	}
+	if fake.decrypts.Load() == 0 {
+		t.Error("KMS wasn't asked to decrypt")
+	}
+}
+
+func TestServiceFallsBackToAgeKey(t *testing.T) {
+	fake := newFakeKMS(t)
+	ks := newService(t, fake.URL)
+	enc := newEncryptor(t).WithKeyService(ks)
+
+	ciphertext, err := enc.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	// With KMS refusing, the age key still opens the file
+	fake.deny.Store(true)
+	plaintext, err := enc.Decrypt(ciphertext)
+	if err != nil || string(plaintext) != "secret" {
+		t.Fatalf("Decrypt = %q, %v", plaintext, err)
+	}
+
+	_, err = newEncryptor(t).WithKeyService(ks).Decrypt(ciphertext)
+	var noMatch *age.NoIdentityMatchError
+	if !errors.As(err, &noMatch) || !strings.Contains(errors.Join(noMatch.Errors...).Error(), "AccessDeniedException: not allowed") {
+		t.Errorf("Decrypt without the age key = %v, want the KMS error", err)
+	}
+
+	if _, err := enc.Encrypt([]byte("more")); err == nil {
+		t.Error("Encrypt succeeded with KMS refusing")
+	}
+}
+
+func TestRegion(t *testing.T) {
+	tests := []struct {
+		name string
+		kms  storage.KMSConfig
+		want string
+	}{
+		{"from ARN", storage.KMSConfig{KeyID: "arn:aws:kms:eu-west-1:111122223333:key/test"}, "eu-west-1"},
+		{"configured", storage.KMSConfig{KeyID: "alias/claude-sync", Region: "ap-south-1"}, "ap-south-1"},
+		{"bucket's", storage.KMSConfig{KeyID: "alias/claude-sync"}, "us-east-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.kms.Provider = storage.KMSProviderAWS
+			ks, err := New(&storage.StorageConfig{
+				Provider:        storage.ProviderS3,
+				Bucket:          "bucket",
+				AccessKeyID:     "AKIATEST",
+				SecretAccessKey: "secret",
+				Region:          "us-east-1",
+				KMS:             &tt.kms,
+			})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			s := ks.(*Service)
+			if s.region != tt.want {
+				t.Errorf("region = %q, want %q", s.region, tt.want)
+			}
+			if s.endpoint != "https://kms."+tt.want+".amazonaws.com" {
+				t.Errorf("endpoint = %q", s.endpoint)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+// Package gcpkms wraps file keys with a Google Cloud KMS key.
+package gcpkms
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"google.golang.org/api/cloudkms/v1"
+	"google.golang.org/api/option"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/kms"
+	"github.com/tawanorg/claude-sync/internal/storage"
+	"github.com/tawanorg/claude-sync/internal/storage/gcs"
+)
+
+func init() {
+	kms.NewGCP = New
+}
+
+// stanzaType marks the header stanza holding a file key wrapped by Cloud
+// KMS. Its argument is the key's resource name and its body the ciphertext.
+const stanzaType = "gcp-kms"
+
+// additionalData is bound to every wrapped key, so ciphertexts can't be
+// passed off as anything else encrypted with the same key.
+var additionalData = base64.StdEncoding.EncodeToString([]byte("claude-sync file key"))
+
+// requestTimeout bounds each KMS call; age's Wrap and Unwrap take no
+// context.
+const requestTimeout = 30 * time.Second
+
+// Service wraps and unwraps age file keys with one Cloud KMS key.
+type Service struct {
+	keyName string
+	keys    *cloudkms.ProjectsLocationsKeyRingsCryptoKeysService
+}
+
+// New creates a key service for cfg.KMS. A GCS bucket's credentials (and
+// impersonated service account) are used for KMS too; other providers use
+// Application Default Credentials.
+func New(cfg *storage.StorageConfig) (crypto.KeyService, error) {
+	var opts []option.ClientOption
+	if cfg.Provider == storage.ProviderGCS {
+		var err error
+		if opts, err = gcs.ClientOptions(context.Background(), cfg, cloudkms.CloudkmsScope); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.KMS.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(storage.NormalizeEndpoint(cfg.KMS.Endpoint)))
+	}
+	return newService(cfg.KMS.KeyID, opts...)
+}
+
+func newService(keyName string, opts ...option.ClientOption) (*Service, error) {
+	svc, err := cloudkms.NewService(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud KMS client: %w", err)
+	}
+	return &Service{keyName: keyName, keys: svc.Projects.Locations.KeyRings.CryptoKeys}, nil
+}
+
+// Wrap implements age.Recipient.
+func (s *Service) Wrap(fileKey []byte) ([]*age.Stanza, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	resp, err := s.keys.Encrypt(s.keyName, &cloudkms.EncryptRequest{
+		Plaintext:                   base64.StdEncoding.EncodeToString(fileKey),
+		AdditionalAuthenticatedData: additionalData,
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("kms encrypt failed: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(resp.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kms encrypt response: %w", err)
+	}
+	return []*age.Stanza{{Type: stanzaType, Args: []string{s.keyName}, Body: ciphertext}}, nil
+}
+
+// Unwrap implements age.Identity. Failures wrap age.ErrIncorrectIdentity,
+// so decryption goes on to the age key when KMS can't be reached or
+// refuses.
+func (s *Service) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	var errs []string
+	for _, st := range stanzas {
+		if st.Type != stanzaType || len(st.Args) != 1 {
+			continue
+		}
+		fileKey, err := s.decrypt(st.Args[0], st.Body)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		return fileKey, nil
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %s", age.ErrIncorrectIdentity, strings.Join(errs, "; "))
+	}
+	return nil, age.ErrIncorrectIdentity
+}
+
+func (s *Service) decrypt(keyName string, ciphertext []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	resp, err := s.keys.Decrypt(keyName, &cloudkms.DecryptRequest{
+		Ciphertext:                  base64.StdEncoding.EncodeToString(ciphertext),
+		AdditionalAuthenticatedData: additionalData,
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("kms decrypt failed: %w", err)
+	}
+	fileKey, err := base64.StdEncoding.DecodeString(resp.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kms decrypt response: %w", err)
+	}
+	return fileKey, nil
+}
@@ -0,0 +1,106 @@
+package gcpkms
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"google.golang.org/api/option"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+)
+
+const keyName = "projects/p/locations/global/keyRings/claude-sync/cryptoKeys/files"
+
+// fakeKMS "encrypts" by prefixing the plaintext, checking each request is
+// for the key and carries the additional data.
+func fakeKMS(t *testing.T, deny *atomic.Bool) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Plaintext                   string
+			Ciphertext                  string
+			AdditionalAuthenticatedData string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request body: %v", err)
+		}
+		if req.AdditionalAuthenticatedData != additionalData {
+			t.Errorf("AdditionalAuthenticatedData = %q", req.AdditionalAuthenticatedData)
+		}
+		if deny.Load() {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"code":403,"message":"Permission denied on resource","status":"PERMISSION_DENIED"}}`))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/" + keyName + ":encrypt":
+			plaintext, _ := base64.StdEncoding.DecodeString(req.Plaintext)
+			json.NewEncoder(w).Encode(map[string]string{
+				"name":       keyName + "/cryptoKeyVersions/1",
+				"ciphertext": base64.StdEncoding.EncodeToString(append([]byte("kms:"), plaintext...)),
+			})
+		case "/v1/" + keyName + ":decrypt":
+			ciphertext, _ := base64.StdEncoding.DecodeString(req.Ciphertext)
+			plaintext, ok := strings.CutPrefix(string(ciphertext), "kms:")
+			if !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":{"code":400,"message":"Decryption failed"}}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"plaintext": base64.StdEncoding.EncodeToString([]byte(plaintext))})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newEncryptor(t *testing.T) *crypto.Encryptor {
+	t.Helper()
+	identity, err := crypto.GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := crypto.NewEncryptorFromIdentity(identity, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc
+}
+
+func TestServiceWrapsFileKeys(t *testing.T) {
+	var deny atomic.Bool
+	server := fakeKMS(t, &deny)
+	ks, err := newService(keyName, option.WithEndpoint(server.URL+"/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("newService: %v", err)
+	}
+
+	enc := newEncryptor(t).WithKeyService(ks)
+	ciphertext, err := enc.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	// A different age key opens the file through KMS
+	plaintext, err := newEncryptor(t).WithKeyService(ks).Decrypt(ciphertext)
+	if err != nil || string(plaintext) != "secret" {
+		t.Fatalf("Decrypt through KMS = %q, %v", plaintext, err)
+	}
+
+	// With KMS refusing, only the age key does
+	deny.Store(true)
+	if plaintext, err := enc.Decrypt(ciphertext); err != nil || string(plaintext) != "secret" {
+		t.Errorf("Decrypt with the age key = %q, %v", plaintext, err)
+	}
+	if _, err := newEncryptor(t).WithKeyService(ks).Decrypt(ciphertext); err == nil {
+		t.Error("Decrypt succeeded with KMS refusing and another age key")
+	}
+}
@@ -0,0 +1,76 @@
+// Package kms wraps file keys with a cloud key management service, for
+// buckets whose keys have to be managed (and access to them audited) in the
+// provider's KMS. The providers live in subpackages that register
+// themselves when imported, like the storage adapters.
+package kms
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"filippo.io/age"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// New returns the key service cfg's kms section selects, or nil when it
+// has none.
+func New(cfg *storage.StorageConfig) (crypto.KeyService, error) {
+	if cfg.KMS == nil {
+		return nil, nil
+	}
+
+	var factory func(cfg *storage.StorageConfig) (crypto.KeyService, error)
+	switch cfg.KMS.Provider {
+	case storage.KMSProviderAWS:
+		factory = NewAWS
+	case storage.KMSProviderGCP:
+		factory = NewGCP
+	default:
+		return nil, fmt.Errorf("unsupported kms provider: %s", cfg.KMS.Provider)
+	}
+	if factory == nil {
+		return nil, fmt.Errorf("kms provider %s isn't built in", cfg.KMS.Provider)
+	}
+
+	ks, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up %s kms: %w", cfg.KMS.Provider, err)
+	}
+	if cfg.KMS.AgeFallback {
+		ks = &ageFallback{KeyService: ks}
+	}
+	return ks, nil
+}
+
+// Warn reports that files are being encrypted without the KMS key. It
+// prints to stderr; tests replace it.
+var Warn = func(message string) {
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", message)
+}
+
+// ageFallback wraps file keys with the KMS key when it can, and otherwise
+// leaves them to the age recipients (age_fallback).
+type ageFallback struct {
+	crypto.KeyService
+	warned sync.Once
+}
+
+func (f *ageFallback) Wrap(fileKey []byte) ([]*age.Stanza, error) {
+	stanzas, err := f.KeyService.Wrap(fileKey)
+	if err != nil {
+		f.warned.Do(func() {
+			Warn(fmt.Sprintf("KMS is unavailable (%v); encrypting with the age key only", err))
+		})
+		return nil, nil
+	}
+	return stanzas, nil
+}
+
+// NewAWS creates an AWS KMS key service (implemented in awskms/awskms.go)
+var NewAWS func(cfg *storage.StorageConfig) (crypto.KeyService, error)
+
+// NewGCP creates a Cloud KMS key service (implemented in gcpkms/gcpkms.go)
+var NewGCP func(cfg *storage.StorageConfig) (crypto.KeyService, error)
@@ -0,0 +1,89 @@
+package kms
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+func TestNew(t *testing.T) {
+	ks, err := New(&storage.StorageConfig{Provider: storage.ProviderS3})
+	if ks != nil || err != nil {
+		t.Errorf("New without kms = %v, %v; want nil, nil", ks, err)
+	}
+
+	// awskms isn't imported here, so the provider isn't registered
+	_, err = New(&storage.StorageConfig{KMS: &storage.KMSConfig{Provider: storage.KMSProviderAWS, KeyID: "key"}})
+	if err == nil || !strings.Contains(err.Error(), "isn't built in") {
+		t.Errorf("New with an unregistered provider = %v", err)
+	}
+
+	_, err = New(&storage.StorageConfig{KMS: &storage.KMSConfig{Provider: "vault", KeyID: "key"}})
+	if err == nil || !strings.Contains(err.Error(), "unsupported kms provider") {
+		t.Errorf("New with an unknown provider = %v", err)
+	}
+}
+
+// failingService is a key service that can't be reached.
+type failingService struct{}
+
+func (failingService) Wrap(fileKey []byte) ([]*age.Stanza, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (failingService) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	return nil, age.ErrIncorrectIdentity
+}
+
+func TestAgeFallback(t *testing.T) {
+	orig := NewAWS
+	defer func() { NewAWS = orig }()
+	NewAWS = func(cfg *storage.StorageConfig) (crypto.KeyService, error) {
+		return failingService{}, nil
+	}
+	origWarn := Warn
+	defer func() { Warn = origWarn }()
+	var warnings []string
+	Warn = func(message string) { warnings = append(warnings, message) }
+
+	identity, err := crypto.GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := crypto.NewEncryptorFromIdentity(identity, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &storage.StorageConfig{KMS: &storage.KMSConfig{Provider: storage.KMSProviderAWS, KeyID: "key"}}
+	ks, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := enc.WithKeyService(ks).Encrypt([]byte("secret")); err == nil {
+		t.Fatal("Encrypt succeeded without KMS or age_fallback")
+	}
+
+	cfg.KMS.AgeFallback = true
+	if ks, err = New(cfg); err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	withKMS := enc.WithKeyService(ks)
+	for i := 0; i < 2; i++ {
+		ciphertext, err := withKMS.Encrypt([]byte("secret"))
+		if err != nil {
+			t.Fatalf("Encrypt with age_fallback: %v", err)
+		}
+		if plaintext, err := enc.Decrypt(ciphertext); err != nil || string(plaintext) != "secret" {
+			t.Fatalf("Decrypt = %q, %v", plaintext, err)
+		}
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "connection refused") {
+		t.Errorf("warnings = %q, want one naming the KMS error", warnings)
+	}
+}
@@ -0,0 +1,131 @@
+// Package logging sets up the process-wide slog logger: debug records on
+// stderr with --verbose, and an opt-in log file under ~/.claude-sync/logs
+// that is rotated by size, for looking into a sync after the fact.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Defaults for the log file's rotation.
+const (
+	DefaultMaxSize  = 5 << 20
+	DefaultMaxFiles = 5
+)
+
+// Options says where log records go.
+type Options struct {
+	// Verbose writes records of every level to stderr.
+	Verbose bool
+
+	// File, if set, is the log file records at FileLevel and above are
+	// appended to. Once it passes MaxSize bytes it is renamed to File.1 (and
+	// File.1 to File.2, ...), keeping MaxFiles files in all.
+	File      string
+	FileLevel slog.Level
+	MaxSize   int64
+	MaxFiles  int
+}
+
+// Setup installs the default logger for opts. The returned Closer closes
+// the log file; with neither Verbose nor File, records are discarded.
+func Setup(opts Options) (io.Closer, error) {
+	var handlers []slog.Handler
+	var closer io.Closer = nopCloser{}
+
+	if opts.Verbose {
+		handlers = append(handlers, slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	if opts.File != "" {
+		f, err := OpenRotating(opts.File, opts.MaxSize, opts.MaxFiles)
+		if err != nil {
+			return closer, err
+		}
+		handlers = append(handlers, slog.NewJSONHandler(f, &slog.HandlerOptions{Level: opts.FileLevel}))
+		closer = f
+	}
+
+	var handler slog.Handler
+	switch len(handlers) {
+	case 0:
+		handler = discardHandler{}
+	case 1:
+		handler = handlers[0]
+	default:
+		handler = multiHandler(handlers)
+	}
+	slog.SetDefault(slog.New(handler))
+	return closer, nil
+}
+
+// ParseLevel parses a level name: "debug", "info", "warn" or "error". An
+// empty name is info.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", name)
+}
+
+// multiHandler sends each record to every handler that wants it.
+type multiHandler []slog.Handler
+
+func (m multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range m {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (m multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
+
+// discardHandler drops every record.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
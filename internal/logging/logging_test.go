@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "claude-sync.log")
+	r, err := OpenRotating(path, 10, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		path:        "dddddddd\n",
+		path + ".1": "cccccccc\n",
+		path + ".2": "bbbbbbbb\n",
+	} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(name), data, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("kept more than 3 files")
+	}
+}
+
+func TestSetupFile(t *testing.T) {
+	defer func(l *slog.Logger) { slog.SetDefault(l) }(slog.Default())
+
+	path := filepath.Join(t.TempDir(), "claude-sync.log")
+	closer, err := Setup(Options{File: path, FileLevel: slog.LevelInfo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.Debug("storage request", "op", "list")
+	slog.Info("push finished", "uploaded", 2)
+	closer.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "storage request") {
+		t.Error("debug record written at level info")
+	}
+	if !strings.Contains(string(data), `"msg":"push finished","uploaded":2`) {
+		t.Errorf("log = %s, want the info record as JSON", data)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if level, err := ParseLevel("DEBUG"); err != nil || level != slog.LevelDebug {
+		t.Errorf("ParseLevel(DEBUG) = %v, %v", level, err)
+	}
+	if level, err := ParseLevel(""); err != nil || level != slog.LevelInfo {
+		t.Errorf("ParseLevel(\"\") = %v, %v", level, err)
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("ParseLevel(loud) succeeded")
+	}
+}
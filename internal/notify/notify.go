@@ -0,0 +1,198 @@
+// Package notify provides the built-in notification channels: desktop
+// notifications, webhooks, email and a log file. Importing it registers them
+// with the sync package under the names used in the notify config.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+func init() {
+	sync.RegisterNotifier("desktop", NewDesktop)
+	sync.RegisterNotifier("webhook", NewWebhook)
+	sync.RegisterNotifier("email", NewEmail)
+	sync.RegisterNotifier("log", NewLog)
+}
+
+// Desktop shows events as desktop notifications: osascript on macOS,
+// notify-send on Linux.
+type Desktop struct{}
+
+// runCommand runs a notification command, replaced in tests.
+var runCommand = func(ctx context.Context, name string, args ...string) error {
+	return exec.CommandContext(ctx, name, args...).Run()
+}
+
+func NewDesktop(cfg config.NotifierConfig) (sync.Notifier, error) {
+	switch runtime.GOOS {
+	case "darwin", "linux":
+		return Desktop{}, nil
+	}
+	return nil, fmt.Errorf("desktop notifications aren't supported on %s", runtime.GOOS)
+}
+
+func (Desktop) Notify(ctx context.Context, event sync.Event) error {
+	var err error
+	if runtime.GOOS == "darwin" {
+		script := fmt.Sprintf("display notification %s with title %s", strconv.Quote(event.Message), strconv.Quote(event.Title()))
+		err = runCommand(ctx, "osascript", "-e", script)
+	} else {
+		err = runCommand(ctx, "notify-send", "--app-name=claude-sync", event.Title(), event.Message)
+	}
+	if err != nil {
+		return fmt.Errorf("desktop notification failed: %w", err)
+	}
+	return nil
+}
+
+// Webhook POSTs each event as JSON to a URL. Text repeats the title and
+// message, for chat webhooks that only display a "text" field.
+type Webhook struct {
+	url string
+}
+
+func NewWebhook(cfg config.NotifierConfig) (sync.Notifier, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook needs url")
+	}
+	return &Webhook{url: cfg.URL}, nil
+}
+
+type webhookPayload struct {
+	Text string `json:"text"`
+	sync.Event
+}
+
+func (w *Webhook) Notify(ctx context.Context, event sync.Event) error {
+	return PostJSON(ctx, w.url, webhookPayload{Text: event.Title() + ": " + event.Message, Event: event})
+}
+
+// webhookClient makes the webhook requests; a hung endpoint mustn't hold up
+// the sync it reports on for long.
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// PostJSON POSTs payload as JSON to url, failing on a non-2xx response.
+// Every webhook claude-sync calls goes through it.
+func PostJSON(ctx context.Context, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Email sends each event as a plain-text email through an SMTP server.
+type Email struct {
+	cfg *config.SMTPConfig
+}
+
+// sendMail is smtp.SendMail, replaced in tests.
+var sendMail = smtp.SendMail
+
+func NewEmail(cfg config.NotifierConfig) (sync.Notifier, error) {
+	if cfg.SMTP == nil || cfg.SMTP.Host == "" || cfg.SMTP.From == "" || len(cfg.SMTP.To) == 0 {
+		return nil, fmt.Errorf("email needs smtp host, from and to")
+	}
+	return &Email{cfg: cfg.SMTP}, nil
+}
+
+func (e *Email) Notify(ctx context.Context, event sync.Event) error {
+	body := event.Message + "\n"
+	if event.Device != "" {
+		body += "\nDevice: " + event.Device + "\n"
+	}
+	return SendMail(e.cfg, event.Title(), event.Time, body)
+}
+
+// SendMail sends a plain-text email through the SMTP server in cfg (port 587
+// unless set). Every email claude-sync sends goes through it.
+func SendMail(cfg *config.SMTPConfig, subject string, date time.Time, body string) error {
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := cfg.Host + ":" + strconv.Itoa(port)
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	if err := sendMail(addr, auth, cfg.From, cfg.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// Log appends each event to a file as a JSON line.
+type Log struct {
+	path string
+}
+
+func NewLog(cfg config.NotifierConfig) (sync.Notifier, error) {
+	path := cfg.Path
+	if path == "" {
+		path = config.NotificationsFilePath()
+	} else if strings.HasPrefix(path, "~/") {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, path[2:])
+	}
+	return &Log{path: path}, nil
+}
+
+func (l *Log) Notify(ctx context.Context, event sync.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open notification log: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write notification log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+func testEvent() sync.Event {
+	return sync.Event{
+		Kind:      sync.EventConflict,
+		Time:      time.Now(),
+		Device:    "laptop",
+		Operation: "pull",
+		Path:      "CLAUDE.md",
+		Message:   "Conflict in CLAUDE.md",
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	n, err := sync.NewNotifier([]config.NotifierConfig{
+		{Type: "webhook", URL: "https://example.com/hook"},
+		{Type: "log", Path: filepath.Join(t.TempDir(), "n.log")},
+	})
+	if err != nil || n == nil {
+		t.Fatalf("Expected the built-in notifiers registered, got %v", err)
+	}
+	if _, err := sync.NewNotifier([]config.NotifierConfig{{Type: "webhook"}}); err == nil {
+		t.Error("Expected a webhook without url refused")
+	}
+	if _, err := sync.NewNotifier([]config.NotifierConfig{{Type: "email"}}); err == nil {
+		t.Error("Expected email without smtp refused")
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	n, _ := NewWebhook(config.NotifierConfig{URL: srv.URL})
+	if err := n.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if got["kind"] != "conflict" || got["path"] != "CLAUDE.md" || !strings.Contains(got["text"].(string), "Conflict in CLAUDE.md") {
+		t.Errorf("Unexpected payload %v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	n, _ = NewWebhook(config.NotifierConfig{URL: failing.URL})
+	if err := n.Notify(context.Background(), testEvent()); err == nil {
+		t.Error("Expected an error for a failing webhook")
+	}
+}
+
+func TestWebhookSyncFinished(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	n, _ := NewWebhook(config.NotifierConfig{URL: srv.URL})
+	event := sync.Event{Kind: sync.EventSyncFinished, Time: time.Now(), Device: "laptop", Operation: "push",
+		Message: "push finished", Status: "failed", DurationMS: 1500, Uploaded: 3, Failed: 1}
+	if err := n.Notify(context.Background(), event); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if got["status"] != "failed" || got["duration_ms"] != 1500.0 || got["uploaded"] != 3.0 || got["failed"] != 1.0 || got["device"] != "laptop" {
+		t.Errorf("Unexpected payload %v", got)
+	}
+	if !strings.HasPrefix(got["text"].(string), "claude-sync: push failed") {
+		t.Errorf("text = %q", got["text"])
+	}
+}
+
+func TestEmail(t *testing.T) {
+	var addr, msg string
+	var to []string
+	sendMail = func(a string, _ smtp.Auth, from string, rcpt []string, body []byte) error {
+		addr, to, msg = a, rcpt, string(body)
+		return nil
+	}
+	defer func() { sendMail = smtp.SendMail }()
+
+	n, err := NewEmail(config.NotifierConfig{SMTP: &config.SMTPConfig{Host: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if len(to) != 1 || !strings.Contains(msg, "Subject: claude-sync: conflict") || !strings.Contains(msg, "Device: laptop") {
+		t.Errorf("Unexpected email to %v:\n%s", to, msg)
+	}
+	if addr != "smtp.example.com:587" {
+		t.Errorf("Expected default port 587, got %s", addr)
+	}
+}
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "n.log")
+	n, _ := NewLog(config.NotifierConfig{Path: path})
+	for i := 0; i < 2; i++ {
+		if err := n.Notify(context.Background(), testEvent()); err != nil {
+			t.Fatalf("Notify failed: %v", err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var event sync.Event
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[0]), &event) != nil || event.Path != "CLAUDE.md" {
+		t.Errorf("Expected two JSON lines, got:\n%s", data)
+	}
+}
+
+func TestDesktop(t *testing.T) {
+	var args []string
+	orig := runCommand
+	runCommand = func(ctx context.Context, name string, a ...string) error {
+		args = append([]string{name}, a...)
+		return nil
+	}
+	defer func() { runCommand = orig }()
+
+	n, err := NewDesktop(config.NotifierConfig{})
+	if err != nil {
+		t.Skip(err)
+	}
+	if err := n.Notify(context.Background(), testEvent()); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	if len(args) == 0 || !strings.Contains(strings.Join(args, " "), "Conflict in CLAUDE.md") {
+		t.Errorf("Unexpected command %v", args)
+	}
+}
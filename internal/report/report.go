@@ -0,0 +1,162 @@
+// Package report summarizes recent sync activity for devices nobody logs
+// into, and delivers the summary by webhook or email.
+package report
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/notify"
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+// Report is a summary of the pushes and pulls on one device over a period.
+type Report struct {
+	Device string    `json:"device"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+
+	Pushes     int `json:"pushes"`
+	Pulls      int `json:"pulls"`
+	Uploaded   int `json:"uploaded"`
+	Downloaded int `json:"downloaded"`
+	Deleted    int `json:"deleted"`
+
+	// Failures lists each run that failed or reported per-file errors or
+	// invalid content, newest first.
+	Failures []sync.ActivityEntry `json:"failures,omitempty"`
+
+	// Conflicts are the unresolved conflicts on the device now, whenever
+	// they were detected.
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// Build summarizes the activity entries from since onwards, together with
+// the conflicts currently waiting to be resolved.
+func Build(device string, since, until time.Time, entries []sync.ActivityEntry, conflicts []sync.Conflict) *Report {
+	r := &Report{Device: device, Since: since, Until: until}
+	for _, e := range entries {
+		if e.Time.Before(since) || e.Time.After(until) {
+			continue
+		}
+		switch e.Command {
+		case "push":
+			r.Pushes++
+		case "pull":
+			r.Pulls++
+		}
+		r.Uploaded += e.Uploaded
+		r.Downloaded += e.Downloaded
+		r.Deleted += e.Deleted
+		if len(e.Errors) > 0 || len(e.Problems) > 0 {
+			r.Failures = append(r.Failures, e)
+		}
+	}
+	sort.SliceStable(r.Failures, func(i, j int) bool { return r.Failures[i].Time.After(r.Failures[j].Time) })
+	for _, c := range conflicts {
+		r.Conflicts = append(r.Conflicts, c.Path)
+	}
+	return r
+}
+
+// NeedsAttention reports whether anything in the report needs a person to
+// look at the device.
+func (r *Report) NeedsAttention() bool {
+	return len(r.Failures) > 0 || len(r.Conflicts) > 0
+}
+
+// Subject is a one-line summary, used as the email subject.
+func (r *Report) Subject() string {
+	status := "OK"
+	if r.NeedsAttention() {
+		status = "needs attention"
+	}
+	return fmt.Sprintf("claude-sync report for %s: %s", r.Device, status)
+}
+
+// Text renders the report as plain text.
+func (r *Report) Text() string {
+	var b strings.Builder
+	const layout = "2006-01-02 15:04"
+	fmt.Fprintf(&b, "claude-sync activity on %s\n", r.Device)
+	fmt.Fprintf(&b, "%s to %s\n\n", r.Since.Local().Format(layout), r.Until.Local().Format(layout))
+
+	fmt.Fprintf(&b, "Pushes: %d (%d file(s) uploaded)\n", r.Pushes, r.Uploaded)
+	fmt.Fprintf(&b, "Pulls:  %d (%d file(s) downloaded, %d deleted)\n", r.Pulls, r.Downloaded, r.Deleted)
+	if r.Pushes+r.Pulls == 0 {
+		b.WriteString("\nNo syncs ran in this period.\n")
+	}
+
+	if len(r.Conflicts) > 0 {
+		fmt.Fprintf(&b, "\nUnresolved conflicts (%d):\n", len(r.Conflicts))
+		for _, path := range r.Conflicts {
+			fmt.Fprintf(&b, "  %s\n", path)
+		}
+		b.WriteString("Run 'claude-sync conflicts' on this device to resolve them.\n")
+	}
+
+	if len(r.Failures) > 0 {
+		fmt.Fprintf(&b, "\nRuns with problems (%d):\n", len(r.Failures))
+		for _, e := range r.Failures {
+			fmt.Fprintf(&b, "  %s %s\n", e.Time.Local().Format(layout), e.Command)
+			for _, msg := range e.Errors {
+				fmt.Fprintf(&b, "    error: %s\n", msg)
+			}
+			for _, msg := range e.Problems {
+				fmt.Fprintf(&b, "    invalid: %s\n", msg)
+			}
+		}
+	}
+	return b.String()
+}
+
+// webhookPayload is the JSON body POSTed to a report webhook. Text is
+// included so chat webhooks that only display a "text" field still work.
+type webhookPayload struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	*Report
+}
+
+// SendWebhook POSTs the report as JSON to url.
+func SendWebhook(ctx context.Context, url string, r *Report) error {
+	return notify.PostJSON(ctx, url, webhookPayload{Subject: r.Subject(), Text: r.Text(), Report: r})
+}
+
+// sendMail is notify.SendMail, replaced in tests.
+var sendMail = notify.SendMail
+
+// SendEmail sends the report as a plain-text email through cfg.
+func SendEmail(cfg *config.SMTPConfig, r *Report) error {
+	if cfg.Host == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return fmt.Errorf("smtp needs host, from and to")
+	}
+	return sendMail(cfg, r.Subject(), r.Until, r.Text())
+}
+
+// Send delivers the report through every channel configured in cfg. It
+// returns an error if none is configured or if any delivery fails.
+func Send(ctx context.Context, cfg *config.ReportConfig, r *Report) error {
+	if cfg == nil || (cfg.Webhook == "" && cfg.SMTP == nil) {
+		return fmt.Errorf("no report destination configured (set report.webhook or report.smtp in config.yaml)")
+	}
+	var errs []string
+	if cfg.Webhook != "" {
+		if err := SendWebhook(ctx, cfg.Webhook, r); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if cfg.SMTP != nil {
+		if err := SendEmail(cfg.SMTP, r); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
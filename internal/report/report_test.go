@@ -0,0 +1,116 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/notify"
+	"github.com/tawanorg/claude-sync/internal/sync"
+)
+
+func testReport(t *testing.T) *Report {
+	t.Helper()
+	now := time.Now()
+	entries := []sync.ActivityEntry{
+		{Time: now.Add(-48 * time.Hour), Command: "push", Uploaded: 9},
+		{Time: now.Add(-2 * time.Hour), Command: "push", Uploaded: 3},
+		{Time: now.Add(-time.Hour), Command: "pull", Downloaded: 2, Deleted: 1, Errors: []string{"x.md: decrypt failed"}},
+	}
+	conflicts := []sync.Conflict{{Path: "CLAUDE.md"}}
+	return Build("server-1", now.Add(-24*time.Hour), now, entries, conflicts)
+}
+
+func TestBuild(t *testing.T) {
+	r := testReport(t)
+	if r.Pushes != 1 || r.Pulls != 1 {
+		t.Errorf("Expected one push and one pull in the period, got %d and %d", r.Pushes, r.Pulls)
+	}
+	if r.Uploaded != 3 || r.Downloaded != 2 || r.Deleted != 1 {
+		t.Errorf("Unexpected totals: %+v", r)
+	}
+	if len(r.Failures) != 1 || len(r.Conflicts) != 1 {
+		t.Errorf("Expected one failure and one conflict, got %+v", r)
+	}
+	if !r.NeedsAttention() || !strings.Contains(r.Subject(), "needs attention") {
+		t.Errorf("Expected report to need attention, subject %q", r.Subject())
+	}
+
+	text := r.Text()
+	for _, want := range []string{"server-1", "CLAUDE.md", "decrypt failed", "3 file(s) uploaded"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Report text missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestBuildQuietPeriod(t *testing.T) {
+	now := time.Now()
+	r := Build("server-1", now.Add(-24*time.Hour), now, nil, nil)
+	if r.NeedsAttention() {
+		t.Error("Expected an empty report not to need attention")
+	}
+	if !strings.Contains(r.Text(), "No syncs ran") {
+		t.Errorf("Expected a note that nothing ran:\n%s", r.Text())
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Bad webhook body: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	if err := SendWebhook(context.Background(), srv.URL, testReport(t)); err != nil {
+		t.Fatalf("SendWebhook failed: %v", err)
+	}
+	if got["device"] != "server-1" || !strings.Contains(got["text"].(string), "CLAUDE.md") {
+		t.Errorf("Unexpected payload: %v", got)
+	}
+}
+
+func TestSendWebhookError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if err := SendWebhook(context.Background(), srv.URL, testReport(t)); err == nil {
+		t.Error("Expected an error for a failing webhook")
+	}
+}
+
+func TestSendEmail(t *testing.T) {
+	var subject, body string
+	sendMail = func(_ *config.SMTPConfig, s string, _ time.Time, b string) error {
+		subject, body = s, b
+		return nil
+	}
+	defer func() { sendMail = notify.SendMail }()
+
+	cfg := &config.SMTPConfig{Host: "mail.example.com", From: "sync@example.com", To: []string{"me@example.com"}}
+	if err := SendEmail(cfg, testReport(t)); err != nil {
+		t.Fatalf("SendEmail failed: %v", err)
+	}
+	if subject != "claude-sync report for server-1: needs attention" || !strings.Contains(body, "CLAUDE.md") {
+		t.Errorf("Unexpected email %q:\n%s", subject, body)
+	}
+
+	if err := SendEmail(&config.SMTPConfig{Host: "mail.example.com"}, testReport(t)); err == nil {
+		t.Error("Expected an error without from and to")
+	}
+}
+
+func TestSendNoDestination(t *testing.T) {
+	if err := Send(context.Background(), nil, testReport(t)); err == nil {
+		t.Error("Expected an error with no destination configured")
+	}
+}
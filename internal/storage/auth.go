@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"errors"
+	"net/http"
+)
+
+// authErrorCodes are the S3 API error codes for a request whose credentials
+// were refused: unknown, wrong, expired, or without permission.
+var authErrorCodes = map[string]bool{
+	"AccessDenied":                 true,
+	"AllAccessDisabled":            true,
+	"AuthorizationHeaderMalformed": true,
+	"ExpiredToken":                 true,
+	"Forbidden":                    true,
+	"InvalidAccessKeyId":           true,
+	"InvalidToken":                 true,
+	"SignatureDoesNotMatch":        true,
+	"TokenRefreshRequired":         true,
+	"Unauthorized":                 true,
+}
+
+// IsAuthError reports whether err, from any adapter, is the storage service
+// refusing the credentials. It goes by the error's API code or HTTP status
+// (401 or 403), so adapters need only keep the client's error wrapped.
+func IsAuthError(err error) bool {
+	var coded interface{ ErrorCode() string } // S3-compatible APIs
+	if errors.As(err, &coded) && authErrorCodes[coded.ErrorCode()] {
+		return true
+	}
+
+	status := 0
+	var withStatus interface{ HTTPStatusCode() int } // S3 responses, WebDAV
+	var withCode interface{ HTTPCode() int }         // Google APIs
+	switch {
+	case errors.As(err, &withStatus):
+		status = withStatus.HTTPStatusCode()
+	case errors.As(err, &withCode):
+		status = withCode.HTTPCode()
+	}
+	return status == http.StatusUnauthorized || status == http.StatusForbidden
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type codedError string
+
+func (e codedError) Error() string     { return string(e) }
+func (e codedError) ErrorCode() string { return string(e) }
+
+type statusError int
+
+func (e statusError) Error() string       { return fmt.Sprintf("HTTP %d", int(e)) }
+func (e statusError) HTTPStatusCode() int { return int(e) }
+
+type googleError int
+
+func (e googleError) Error() string { return fmt.Sprintf("googleapi: %d", int(e)) }
+func (e googleError) HTTPCode() int { return int(e) }
+
+func TestIsAuthError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("failed to upload x: %w", codedError("SignatureDoesNotMatch")), true},
+		{fmt.Errorf("failed to upload x: %w", codedError("NoSuchKey")), false},
+		{fmt.Errorf("failed to head x: %w", statusError(403)), true},
+		{fmt.Errorf("failed to head x: %w", statusError(404)), false},
+		{fmt.Errorf("failed to list: %w", googleError(401)), true},
+		{errors.New("connection refused"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsAuthError(tt.err); got != tt.want {
+			t.Errorf("IsAuthError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
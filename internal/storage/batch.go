@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// HeadBatchByList implements HeadBatch for providers without a native
+// multi-object stat. It lists the longest directory prefix shared by all keys
+// and picks out the requested ones, so N keys cost a single (paginated) List
+// instead of N Head round-trips. Keys that share no directory would list the
+// whole bucket, so they are stat'ed one by one with Head instead. Keys that
+// don't exist are absent from the result.
+func HeadBatchByList(ctx context.Context, s Storage, keys []string) (map[string]*ObjectInfo, error) {
+	result := make(map[string]*ObjectInfo, len(keys))
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	prefix := commonKeyPrefix(keys)
+	if prefix == "" {
+		for _, key := range keys {
+			info, err := s.Head(ctx, key)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if info != nil {
+				result[key] = info
+			}
+		}
+		return result, nil
+	}
+
+	wanted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		wanted[key] = true
+	}
+
+	objects, err := s.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range objects {
+		if wanted[objects[i].Key] {
+			obj := objects[i]
+			result[obj.Key] = &obj
+		}
+	}
+	return result, nil
+}
+
+// commonKeyPrefix returns the longest directory prefix ("" or ending in "/")
+// shared by all keys. Stopping at a "/" boundary matters for WebDAV, whose
+// List only accepts collection paths.
+func commonKeyPrefix(keys []string) string {
+	prefix := keys[0]
+	for _, key := range keys[1:] {
+		for !strings.HasPrefix(key, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+		if prefix == "" {
+			return ""
+		}
+	}
+
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		return prefix[:i+1]
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCommonKeyPrefix(t *testing.T) {
+	tests := []struct {
+		keys []string
+		want string
+	}{
+		{[]string{"CLAUDE.md.age"}, ""},
+		{[]string{"agents/a.md.age"}, "agents/"},
+		{[]string{"agents/a.md.age", "agents/b.md.age"}, "agents/"},
+		{[]string{"projects/x/1.jsonl.age", "projects/x/2.jsonl.age"}, "projects/x/"},
+		{[]string{"projects/x/1.jsonl.age", "projects/y/2.jsonl.age"}, "projects/"},
+		{[]string{"agents/a.md.age", "agentsx/b.md.age"}, ""},
+		{[]string{"agents/a.md.age", "CLAUDE.md.age"}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := commonKeyPrefix(tt.keys); got != tt.want {
+			t.Errorf("commonKeyPrefix(%v) = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestHeadBatchByList(t *testing.T) {
+	var listed []string
+	mock := &MockStorage{
+		ListFunc: func(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+			listed = append(listed, prefix)
+			all := []ObjectInfo{
+				{Key: "agents/a.md.age", Size: 1},
+				{Key: "agents/b.md.age", Size: 2},
+				{Key: "agents/c.md.age", Size: 3},
+			}
+			var out []ObjectInfo
+			for _, obj := range all {
+				if strings.HasPrefix(obj.Key, prefix) {
+					out = append(out, obj)
+				}
+			}
+			return out, nil
+		},
+	}
+
+	got, err := mock.HeadBatch(context.Background(), []string{"agents/a.md.age", "agents/c.md.age", "agents/missing.md.age"})
+	if err != nil {
+		t.Fatalf("HeadBatch failed: %v", err)
+	}
+	if len(listed) != 1 || listed[0] != "agents/" {
+		t.Errorf("Expected a single List of agents/, got %v", listed)
+	}
+	if len(got) != 2 || got["agents/a.md.age"].Size != 1 || got["agents/c.md.age"].Size != 3 {
+		t.Errorf("Unexpected result: %v", got)
+	}
+	if _, ok := got["agents/missing.md.age"]; ok {
+		t.Error("Expected missing key to be absent")
+	}
+
+	empty, err := mock.HeadBatch(context.Background(), nil)
+	if err != nil || len(empty) != 0 || len(listed) != 1 {
+		t.Errorf("Expected no List for an empty batch, got %v, %v", empty, err)
+	}
+}
+
+func TestHeadBatchByListAtRoot(t *testing.T) {
+	var heads []string
+	mock := &MockStorage{
+		ListFunc: func(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+			t.Errorf("Unexpected List(%q) of the whole bucket", prefix)
+			return nil, nil
+		},
+		HeadFunc: func(ctx context.Context, key string) (*ObjectInfo, error) {
+			heads = append(heads, key)
+			if key == "CLAUDE.md.age" {
+				return &ObjectInfo{Key: key, Size: 4}, nil
+			}
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+		},
+	}
+
+	got, err := HeadBatchByList(context.Background(), mock, []string{"CLAUDE.md.age", "agents/missing.md.age"})
+	if err != nil {
+		t.Fatalf("HeadBatchByList failed: %v", err)
+	}
+	if len(heads) != 2 {
+		t.Errorf("Expected a Head per key, got %v", heads)
+	}
+	if len(got) != 1 || got["CLAUDE.md.age"].Size != 4 {
+		t.Errorf("Unexpected result: %v", got)
+	}
+
+	mock.HeadFunc = func(ctx context.Context, key string) (*ObjectInfo, error) {
+		return nil, errors.New("access denied")
+	}
+	if _, err := HeadBatchByList(context.Background(), mock, []string{"CLAUDE.md.age"}); err == nil {
+		t.Error("Expected a Head failure other than a missing key to fail the batch")
+	}
+}
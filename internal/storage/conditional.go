@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ConditionalUploader is implemented by adapters that can make an upload
+// depend on what is stored, so that of two devices rewriting the same
+// object from the same starting point only one succeeds.
+type ConditionalUploader interface {
+	// UploadIfMatch stores data under key only if the object's ETag is
+	// still etag, as Head reported it, or with etag "" only if there is no
+	// object yet. Otherwise it returns an error wrapping
+	// ErrPreconditionFailed.
+	UploadIfMatch(ctx context.Context, key string, data []byte, etag string) error
+}
+
+// ErrPreconditionFailed is wrapped by the error UploadIfMatch returns when
+// the object changed since its ETag was read.
+var ErrPreconditionFailed = errors.New("object changed since it was read")
+
+// ErrConditionalUnsupported is returned by UploadIfMatch for a store that
+// can't upload conditionally. Nothing was uploaded.
+var ErrConditionalUnsupported = errors.New("storage can't upload conditionally")
+
+// UploadIfMatch uploads data under key through s's ConditionalUploader
+// (see there), or returns ErrConditionalUnsupported without uploading when
+// s doesn't have one.
+func UploadIfMatch(ctx context.Context, s Storage, key string, data []byte, etag string) error {
+	c, ok := s.(ConditionalUploader)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrConditionalUnsupported, s)
+	}
+	return c.UploadIfMatch(ctx, key, data, etag)
+}
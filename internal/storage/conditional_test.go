@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// conditionalMock is a MockStorage that can upload conditionally.
+type conditionalMock struct {
+	*MockStorage
+	etags map[string]string
+}
+
+func (c *conditionalMock) UploadIfMatch(ctx context.Context, key string, data []byte, etag string) error {
+	if c.etags[key] != etag {
+		return ErrPreconditionFailed
+	}
+	c.etags[key] = string(data)
+	return nil
+}
+
+func TestUploadIfMatchThroughWrappers(t *testing.T) {
+	ctx := context.Background()
+	inner := &conditionalMock{MockStorage: &MockStorage{}, etags: map[string]string{}}
+	store := NewMetered(NewLogged(NewPrefixed(inner, "team")))
+
+	if err := UploadIfMatch(ctx, store, "names.age", []byte("v1"), ""); err != nil {
+		t.Fatalf("UploadIfMatch(new) error = %v", err)
+	}
+	if inner.etags["team/names.age"] != "v1" {
+		t.Errorf("inner etags = %v, want the prefixed key", inner.etags)
+	}
+	if err := UploadIfMatch(ctx, store, "names.age", []byte("v2"), ""); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("UploadIfMatch(new) over an object = %v, want ErrPreconditionFailed", err)
+	}
+	if err := UploadIfMatch(ctx, store, "names.age", []byte("v2"), "v1"); err != nil {
+		t.Errorf("UploadIfMatch(current) error = %v", err)
+	}
+	if got := store.Stats(); got.Put != 3 || got.BytesUp != 4 {
+		t.Errorf("Stats() = %+v, want 3 puts of 4 bytes", got)
+	}
+
+	// A store that can't make the condition uploads nothing
+	plain := NewMetered(NewLogged(NewPrefixed(&MockStorage{
+		UploadFunc: func(ctx context.Context, key string, data []byte) error {
+			t.Errorf("Upload(%s) on an unsupported store", key)
+			return nil
+		},
+	}, "team")))
+	if err := UploadIfMatch(ctx, plain, "names.age", []byte("v1"), ""); !errors.Is(err, ErrConditionalUnsupported) {
+		t.Errorf("UploadIfMatch on a plain store = %v, want ErrConditionalUnsupported", err)
+	}
+	if got := plain.Stats(); got.Put != 0 {
+		t.Errorf("Stats() = %+v, want no puts", got)
+	}
+}
@@ -68,20 +68,145 @@ type StorageConfig struct {
 	// without wildcard DNS). Only honored when a custom Endpoint is set.
 	UsePathStyle bool `yaml:"use_path_style,omitempty"`
 
+	// Profile is the AWS shared config profile (~/.aws/config and
+	// ~/.aws/credentials) S3 reads its credentials from when
+	// UseDefaultCredentials is set. Empty means AWS_PROFILE or "default".
+	Profile string `yaml:"profile,omitempty"`
+
+	// RoleARN is an IAM role S3 assumes with the credentials it has, for
+	// cross-account buckets or a role dedicated to syncing. With
+	// WebIdentityTokenFile it is assumed with the OIDC token in that file
+	// instead (a CI job's or a Kubernetes service account's), needing no
+	// other credentials.
+	RoleARN              string `yaml:"role_arn,omitempty"`
+	WebIdentityTokenFile string `yaml:"web_identity_token_file,omitempty"`
+
 	// R2-specific
 	AccountID string `yaml:"account_id,omitempty"`
 
+	// Jurisdiction is the R2 jurisdiction the bucket was created in: "eu"
+	// or "fedramp" keep its data there and are reached at their own
+	// endpoint. Empty (or "default") is the global endpoint.
+	Jurisdiction string `yaml:"jurisdiction,omitempty"`
+
+	// CustomDomain is an S3 API endpoint on your own domain in front of R2
+	// (e.g. r2.example.com), used in place of the account's endpoint.
+	CustomDomain string `yaml:"custom_domain,omitempty"`
+
 	// GCS-specific
-	ProjectID             string `yaml:"project_id,omitempty"`
-	CredentialsFile       string `yaml:"credentials_file,omitempty"`
-	CredentialsJSON       string `yaml:"credentials_json,omitempty"`
-	UseDefaultCredentials bool   `yaml:"use_default_credentials,omitempty"`
+	ProjectID       string `yaml:"project_id,omitempty"`
+	CredentialsFile string `yaml:"credentials_file,omitempty"`
+	CredentialsJSON string `yaml:"credentials_json,omitempty"`
+
+	// ImpersonateServiceAccount is the email of a service account GCS acts
+	// as, using the other credentials (usually Application Default
+	// Credentials) only to mint its short-lived tokens. They need the
+	// Service Account Token Creator role on it.
+	ImpersonateServiceAccount string `yaml:"impersonate_service_account,omitempty"`
+
+	// UseDefaultCredentials takes credentials from the environment instead
+	// of this config: Application Default Credentials for GCS, the AWS
+	// default credential chain (environment, Profile, SSO) for S3.
+	UseDefaultCredentials bool `yaml:"use_default_credentials,omitempty"`
+
+	// CredentialsCommand is a shell command, such as "op read
+	// op://vault/r2/secret", whose output supplies the credentials when
+	// storage is opened, so they needn't be stored in the config. See
+	// config.runCredentialsCommand for the output it accepts.
+	CredentialsCommand string `yaml:"credentials_command,omitempty"`
 
 	// WebDAV-specific (Nextcloud, ownCloud, etc.)
 	WebDAVURL      string `yaml:"webdav_url,omitempty"`
 	WebDAVUsername string `yaml:"webdav_username,omitempty"`
 	WebDAVPassword string `yaml:"webdav_password,omitempty"`
 	PathPrefix     string `yaml:"path_prefix,omitempty"`
+
+	// KeyPrefix keeps every object under this prefix of the bucket, e.g.
+	// "laptops/tew/", so several users or profiles can share one bucket.
+	// Every device syncing together needs the same prefix, and in a shared
+	// bucket every setup needs one, none inside another's.
+	KeyPrefix string `yaml:"key_prefix,omitempty"`
+
+	// KMS also wraps each file's key with a cloud KMS key (envelope
+	// encryption); the age key still opens every file
+	KMS *KMSConfig `yaml:"kms,omitempty"`
+
+	// resolveCredentials fills in the credentials CredentialsCommand
+	// supplies; nil once they are, or without a command
+	resolveCredentials func(*StorageConfig) error
+}
+
+// SetCredentialsResolver defers the credentials CredentialsCommand supplies
+// to resolve, which New calls before opening storage, so commands that never
+// touch the bucket don't run it. Copies of c share it.
+func (c *StorageConfig) SetCredentialsResolver(resolve func(*StorageConfig) error) {
+	c.resolveCredentials = resolve
+}
+
+// ResolveCredentials fills in the credentials still to come from
+// CredentialsCommand, if any.
+func (c *StorageConfig) ResolveCredentials() error {
+	if c.resolveCredentials == nil {
+		return nil
+	}
+	if err := c.resolveCredentials(c); err != nil {
+		return err
+	}
+	c.resolveCredentials = nil
+	return nil
+}
+
+// credentialsPending reports whether CredentialsCommand is yet to supply
+// credentials, which Validate can't expect to be set until then.
+func (c *StorageConfig) credentialsPending() bool {
+	return c.resolveCredentials != nil
+}
+
+// KMS providers
+const (
+	KMSProviderAWS = "aws"
+	KMSProviderGCP = "gcp"
+)
+
+// KMSConfig selects the KMS key file keys are wrapped with
+type KMSConfig struct {
+	Provider string `yaml:"provider"`
+
+	// KeyID is the AWS key ID, ARN or alias ("alias/claude-sync"), or the
+	// Cloud KMS key's resource name
+	// ("projects/p/locations/l/keyRings/r/cryptoKeys/k")
+	KeyID string `yaml:"key_id"`
+
+	// AWS: the key's region, when KeyID isn't an ARN and it differs from
+	// the S3 bucket's
+	Region string `yaml:"region,omitempty"`
+
+	// Endpoint overrides the KMS API endpoint (VPC or private endpoints)
+	Endpoint string `yaml:"endpoint,omitempty"`
+
+	// AgeFallback encrypts new files to the age key alone, with a warning,
+	// while the KMS can't be reached, instead of failing the push
+	AgeFallback bool `yaml:"age_fallback,omitempty"`
+}
+
+func (k *KMSConfig) validate() error {
+	if k == nil {
+		return nil
+	}
+	switch k.Provider {
+	case KMSProviderAWS, KMSProviderGCP:
+	case "":
+		return fmt.Errorf("kms provider is required")
+	default:
+		return fmt.Errorf("unsupported kms provider: %s", k.Provider)
+	}
+	if k.KeyID == "" {
+		return fmt.Errorf("kms key_id is required")
+	}
+	if k.Provider == KMSProviderGCP && (!strings.HasPrefix(k.KeyID, "projects/") || !strings.Contains(k.KeyID, "/cryptoKeys/") || strings.Contains(k.KeyID, "/cryptoKeyVersions/")) {
+		return fmt.Errorf("kms key_id must be a Cloud KMS key name (projects/.../locations/.../keyRings/.../cryptoKeys/...)")
+	}
+	return nil
 }
 
 // Validate checks if the configuration is valid for the selected provider
@@ -89,6 +214,12 @@ func (c *StorageConfig) Validate() error {
 	if c.Provider != ProviderWebDAV && c.Bucket == "" {
 		return fmt.Errorf("bucket is required")
 	}
+	if err := c.KMS.validate(); err != nil {
+		return err
+	}
+	if err := validateKeyPrefix(c.KeyPrefix); err != nil {
+		return err
+	}
 
 	switch c.Provider {
 	case ProviderR2:
@@ -107,9 +238,20 @@ func (c *StorageConfig) Validate() error {
 }
 
 func (c *StorageConfig) validateR2() error {
-	if c.AccountID == "" {
+	switch c.Jurisdiction {
+	case "", R2JurisdictionDefault, R2JurisdictionEU, R2JurisdictionFedRAMP:
+	default:
+		return fmt.Errorf("unsupported R2 jurisdiction %q (use eu or fedramp)", c.Jurisdiction)
+	}
+	if c.CustomDomain != "" && c.Endpoint != "" {
+		return fmt.Errorf("set custom_domain or endpoint for R2, not both")
+	}
+	if c.AccountID == "" && c.CustomDomain == "" {
 		return fmt.Errorf("account_id is required for R2")
 	}
+	if c.credentialsPending() {
+		return nil
+	}
 	if c.AccessKeyID == "" {
 		return fmt.Errorf("access_key_id is required for R2")
 	}
@@ -120,15 +262,29 @@ func (c *StorageConfig) validateR2() error {
 }
 
 func (c *StorageConfig) validateS3() error {
+	if c.WebIdentityTokenFile != "" && c.RoleARN == "" {
+		return fmt.Errorf("web_identity_token_file needs role_arn for S3")
+	}
+	if c.UseDefaultCredentials || c.WebIdentityTokenFile != "" {
+		// The AWS profile or environment may supply the region too; New
+		// reports it if not
+		return nil
+	}
+	if c.Profile != "" {
+		return fmt.Errorf("profile needs use_default_credentials for S3")
+	}
+	if c.Region == "" {
+		return fmt.Errorf("region is required for S3")
+	}
+	if c.credentialsPending() {
+		return nil
+	}
 	if c.AccessKeyID == "" {
 		return fmt.Errorf("access_key_id is required for S3")
 	}
 	if c.SecretAccessKey == "" {
 		return fmt.Errorf("secret_access_key is required for S3")
 	}
-	if c.Region == "" {
-		return fmt.Errorf("region is required for S3")
-	}
 	return nil
 }
 
@@ -136,6 +292,9 @@ func (c *StorageConfig) validateGCS() error {
 	if c.ProjectID == "" {
 		return fmt.Errorf("project_id is required for GCS")
 	}
+	if sa := c.ImpersonateServiceAccount; sa != "" && !strings.Contains(sa, "@") {
+		return fmt.Errorf("impersonate_service_account must be a service account email, not %q", sa)
+	}
 	// GCS can use default credentials, credentials file, or JSON
 	// At least one auth method should be available (or use_default_credentials)
 	return nil
@@ -145,6 +304,9 @@ func (c *StorageConfig) validateWebDAV() error {
 	if c.WebDAVURL == "" {
 		return fmt.Errorf("webdav_url is required for WebDAV")
 	}
+	if c.credentialsPending() {
+		return nil
+	}
 	if c.WebDAVUsername == "" {
 		return fmt.Errorf("webdav_username is required for WebDAV")
 	}
@@ -154,8 +316,28 @@ func (c *StorageConfig) validateWebDAV() error {
 	return nil
 }
 
+// R2 jurisdictions
+const (
+	R2JurisdictionDefault = "default"
+	R2JurisdictionEU      = "eu"
+	R2JurisdictionFedRAMP = "fedramp"
+)
+
+// R2Endpoint returns the S3 API endpoint of an R2 account for buckets in
+// jurisdiction: https://<account>.eu.r2.cloudflarestorage.com for "eu", the
+// global https://<account>.r2.cloudflarestorage.com for "" or "default".
+func R2Endpoint(accountID, jurisdiction string) string {
+	if jurisdiction == "" || jurisdiction == R2JurisdictionDefault {
+		return fmt.Sprintf("https://%s.r2.cloudflarestorage.com", accountID)
+	}
+	return fmt.Sprintf("https://%s.%s.r2.cloudflarestorage.com", accountID, jurisdiction)
+}
+
 // GetEndpoint returns the endpoint URL for the storage provider
 func (c *StorageConfig) GetEndpoint() string {
+	if c.Provider == ProviderR2 && c.CustomDomain != "" {
+		return NormalizeEndpoint(c.CustomDomain)
+	}
 	if c.Endpoint != "" {
 		return c.Endpoint
 	}
@@ -163,7 +345,7 @@ func (c *StorageConfig) GetEndpoint() string {
 	switch c.Provider {
 	case ProviderR2:
 		if c.AccountID != "" {
-			return fmt.Sprintf("https://%s.r2.cloudflarestorage.com", c.AccountID)
+			return R2Endpoint(c.AccountID, c.Jurisdiction)
 		}
 	case ProviderS3:
 		if c.Region != "" {
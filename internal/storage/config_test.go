@@ -46,6 +46,19 @@ func TestStorageConfig_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "R2 with unknown jurisdiction",
+			config: StorageConfig{
+				Provider:        ProviderR2,
+				Bucket:          "test-bucket",
+				AccountID:       "account123",
+				AccessKeyID:     "access123",
+				SecretAccessKey: "secret123",
+				Jurisdiction:    "us",
+			},
+			wantErr: true,
+			errMsg:  "unsupported R2 jurisdiction",
+		},
 		{
 			name: "R2 missing account ID",
 			config: StorageConfig{
@@ -124,6 +137,29 @@ func TestStorageConfig_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "region is required",
 		},
+		{
+			name: "S3 with default credentials and a profile",
+			config: StorageConfig{
+				Provider:              ProviderS3,
+				Bucket:                "test-bucket",
+				UseDefaultCredentials: true,
+				Profile:               "work",
+			},
+			wantErr: false,
+		},
+		{
+			name: "S3 profile without default credentials",
+			config: StorageConfig{
+				Provider:        ProviderS3,
+				Bucket:          "test-bucket",
+				AccessKeyID:     "access123",
+				SecretAccessKey: "secret123",
+				Region:          "us-east-1",
+				Profile:         "work",
+			},
+			wantErr: true,
+			errMsg:  "profile needs use_default_credentials",
+		},
 		// GCS tests
 		{
 			name: "valid GCS config with ADC",
@@ -205,6 +241,62 @@ func TestStorageConfig_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		// KMS tests
+		{
+			name: "valid AWS KMS config",
+			config: StorageConfig{
+				Provider:        ProviderS3,
+				Bucket:          "test-bucket",
+				AccessKeyID:     "AKIATEST",
+				SecretAccessKey: "secret",
+				Region:          "us-east-1",
+				KMS:             &KMSConfig{Provider: KMSProviderAWS, KeyID: "alias/claude-sync"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "KMS missing key_id",
+			config: StorageConfig{
+				Provider:  ProviderGCS,
+				Bucket:    "test-bucket",
+				ProjectID: "my-project",
+				KMS:       &KMSConfig{Provider: KMSProviderAWS},
+			},
+			wantErr: true,
+			errMsg:  "kms key_id is required",
+		},
+		{
+			name: "valid GCP KMS config",
+			config: StorageConfig{
+				Provider:  ProviderGCS,
+				Bucket:    "test-bucket",
+				ProjectID: "my-project",
+				KMS:       &KMSConfig{Provider: KMSProviderGCP, KeyID: "projects/p/locations/global/keyRings/r/cryptoKeys/k"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "GCP KMS key version instead of key",
+			config: StorageConfig{
+				Provider:  ProviderGCS,
+				Bucket:    "test-bucket",
+				ProjectID: "my-project",
+				KMS:       &KMSConfig{Provider: KMSProviderGCP, KeyID: "projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"},
+			},
+			wantErr: true,
+			errMsg:  "Cloud KMS key name",
+		},
+		{
+			name: "KMS unsupported provider",
+			config: StorageConfig{
+				Provider:  ProviderGCS,
+				Bucket:    "test-bucket",
+				ProjectID: "my-project",
+				KMS:       &KMSConfig{Provider: "vault", KeyID: "key"},
+			},
+			wantErr: true,
+			errMsg:  "unsupported kms provider",
+		},
 	}
 
 	for _, tt := range tests {
@@ -250,6 +342,25 @@ func TestStorageConfig_GetEndpoint(t *testing.T) {
 			},
 			expected: "https://custom.endpoint.com",
 		},
+		{
+			name: "R2 in the EU jurisdiction",
+			config: StorageConfig{
+				Provider:     ProviderR2,
+				AccountID:    "abc123",
+				Jurisdiction: "eu",
+			},
+			expected: "https://abc123.eu.r2.cloudflarestorage.com",
+		},
+		{
+			name: "R2 with custom domain",
+			config: StorageConfig{
+				Provider:     ProviderR2,
+				AccountID:    "abc123",
+				Jurisdiction: "eu",
+				CustomDomain: "r2.example.com",
+			},
+			expected: "https://r2.example.com",
+		},
 		{
 			name: "S3 with region",
 			config: StorageConfig{
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// BucketCopier is implemented by adapters that can copy an object to another
+// bucket of the same provider server-side, without downloading it.
+type BucketCopier interface {
+	CopyToBucket(ctx context.Context, key, bucket string) error
+}
+
+// CopyObject copies key from src to dst, where dst is a store for dstBucket
+// on the same provider. It copies server-side when src supports it and
+// otherwise downloads and re-uploads the object.
+func CopyObject(ctx context.Context, src, dst Storage, key, dstBucket string) error {
+	if c, ok := unwrapCopier(src); ok {
+		return c.CopyToBucket(ctx, key, dstBucket)
+	}
+	data, err := src.Download(ctx, key)
+	if err != nil {
+		return err
+	}
+	return dst.Upload(ctx, key, data)
+}
+
+func unwrapCopier(s Storage) (BucketCopier, bool) {
+	var inner Storage
+	switch w := s.(type) {
+	case *MeteredStorage:
+		inner = w.inner
+	case *PrefixedStorage:
+		inner = w.inner
+	default:
+		c, ok := s.(BucketCopier)
+		return c, ok
+	}
+	if _, ok := unwrapCopier(inner); !ok {
+		return nil, false
+	}
+	return s.(BucketCopier), true
+}
+
+// CopySource formats bucket and key as an S3 x-amz-copy-source value, with
+// each key segment URL-escaped.
+func CopySource(bucket, key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return bucket + "/" + strings.Join(segments, "/")
+}
@@ -1,14 +1,18 @@
 package gcs
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 
 	"cloud.google.com/go/storage"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/impersonate"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 
@@ -29,10 +33,33 @@ type Client struct {
 func New(cfg *appstorage.StorageConfig) (appstorage.Storage, error) {
 	ctx := context.Background()
 
-	var opts []option.ClientOption
+	opts, err := ClientOptions(ctx, cfg, storage.ScopeReadWrite)
+	if err != nil {
+		return nil, err
+	}
 
-	// Configure authentication
-	if cfg.CredentialsFile != "" {
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	return &Client{
+		client: client,
+		bucket: cfg.Bucket,
+	}, nil
+}
+
+// ClientOptions returns the options authenticating a Google API client as
+// cfg says. Without a key (or with use_default_credentials) that is
+// Application Default Credentials, which cover the metadata server on GCE,
+// GKE workload identity, and workload identity federation (external_account)
+// files. With ImpersonateServiceAccount, those credentials only mint
+// short-lived tokens for that service account, limited to scopes.
+func ClientOptions(ctx context.Context, cfg *appstorage.StorageConfig, scopes ...string) ([]option.ClientOption, error) {
+	var opts []option.ClientOption
+	switch {
+	case cfg.UseDefaultCredentials:
+	case cfg.CredentialsFile != "":
 		// Expand ~ in path
 		credPath := cfg.CredentialsFile
 		if len(credPath) > 0 && credPath[0] == '~' {
@@ -40,28 +67,61 @@ func New(cfg *appstorage.StorageConfig) (appstorage.Storage, error) {
 			credPath = home + credPath[1:]
 		}
 		opts = append(opts, option.WithCredentialsFile(credPath))
-	} else if cfg.CredentialsJSON != "" {
+	case cfg.CredentialsJSON != "":
 		opts = append(opts, option.WithCredentialsJSON([]byte(cfg.CredentialsJSON)))
 	}
-	// If no credentials specified, the client will use Application Default Credentials
+	if cfg.ImpersonateServiceAccount == "" {
+		return opts, nil
+	}
 
-	client, err := storage.NewClient(ctx, opts...)
+	ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: cfg.ImpersonateServiceAccount,
+		Scopes:          scopes,
+	}, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+		return nil, fmt.Errorf("failed to impersonate %s: %w", cfg.ImpersonateServiceAccount, err)
 	}
-
-	return &Client{
-		client: client,
-		bucket: cfg.Bucket,
-	}, nil
+	return []option.ClientOption{option.WithTokenSource(ts)}, nil
 }
 
 // Upload stores data with the given key
 func (c *Client) Upload(ctx context.Context, key string, data []byte) error {
-	wc := c.client.Bucket(c.bucket).Object(key).NewWriter(ctx)
+	return c.write(ctx, c.client.Bucket(c.bucket).Object(key), key, data)
+}
+
+// UploadIfMatch implements appstorage.ConditionalUploader with a generation
+// precondition: the generation the ETag was read with, or none for a new
+// object.
+func (c *Client) UploadIfMatch(ctx context.Context, key string, data []byte, etag string) error {
+	obj := c.client.Bucket(c.bucket).Object(key)
+	if etag == "" {
+		obj = obj.If(storage.Conditions{DoesNotExist: true})
+	} else {
+		attrs, err := obj.Attrs(ctx)
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return fmt.Errorf("failed to upload %s: %w", key, appstorage.ErrPreconditionFailed)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %w", key, err)
+		}
+		if attrs.Etag != etag {
+			return fmt.Errorf("failed to upload %s: %w", key, appstorage.ErrPreconditionFailed)
+		}
+		obj = obj.If(storage.Conditions{GenerationMatch: attrs.Generation})
+	}
+	err := c.write(ctx, obj, key, data)
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return fmt.Errorf("failed to upload %s: %w", key, appstorage.ErrPreconditionFailed)
+	}
+	return err
+}
+
+func (c *Client) write(ctx context.Context, obj *storage.ObjectHandle, key string, data []byte) error {
+	wc := obj.NewWriter(ctx)
 	wc.ContentType = "application/octet-stream"
 
-	if _, err := wc.Write(data); err != nil {
+	if _, err := io.Copy(wc, appstorage.ProgressReader(ctx, bytes.NewReader(data), int64(len(data)))); err != nil {
 		_ = wc.Close()
 		return fmt.Errorf("failed to write %s: %w", key, err)
 	}
@@ -82,7 +142,7 @@ func (c *Client) Download(ctx context.Context, key string) ([]byte, error) {
 	defer func() { _ = rc.Close() }()
 
 	// Limit download size to prevent memory exhaustion
-	limited := io.LimitReader(rc, appstorage.MaxDownloadSize+1)
+	limited := io.LimitReader(appstorage.ProgressReader(ctx, rc, rc.Attrs.Size), appstorage.MaxDownloadSize+1)
 	data, err := io.ReadAll(limited)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read %s: %w", key, err)
@@ -160,6 +220,9 @@ func (c *Client) List(ctx context.Context, prefix string) ([]appstorage.ObjectIn
 func (c *Client) Head(ctx context.Context, key string) (*appstorage.ObjectInfo, error) {
 	attrs, err := c.client.Bucket(c.bucket).Object(key).Attrs(ctx)
 	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, fmt.Errorf("%w: %s", appstorage.ErrNotFound, key)
+		}
 		return nil, err
 	}
 
@@ -171,6 +234,20 @@ func (c *Client) Head(ctx context.Context, key string) (*appstorage.ObjectInfo,
 	}, nil
 }
 
+// CopyToBucket copies an object to the same key in another bucket server-side
+func (c *Client) CopyToBucket(ctx context.Context, key, bucket string) error {
+	src := c.client.Bucket(c.bucket).Object(key)
+	if _, err := c.client.Bucket(bucket).Object(key).CopierFrom(src).Run(ctx); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", key, bucket, err)
+	}
+	return nil
+}
+
+// HeadBatch returns metadata for many keys using a single List
+func (c *Client) HeadBatch(ctx context.Context, keys []string) (map[string]*appstorage.ObjectInfo, error) {
+	return appstorage.HeadBatchByList(ctx, c, keys)
+}
+
 // BucketExists checks if the configured bucket exists
 func (c *Client) BucketExists(ctx context.Context) (bool, error) {
 	_, err := c.client.Bucket(c.bucket).Attrs(ctx)
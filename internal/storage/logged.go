@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// LoggedStorage logs every request made through another store at debug
+// level: the operation, its key or prefix, how long it took and any error.
+// A request still running when the process is stopped shows up as a
+// "started" record with no "done" after it.
+type LoggedStorage struct {
+	inner Storage
+}
+
+// NewLogged wraps s with request logging.
+func NewLogged(s Storage) *LoggedStorage {
+	return &LoggedStorage{inner: s}
+}
+
+// Unwrap returns the underlying store.
+func (l *LoggedStorage) Unwrap() Storage {
+	return l.inner
+}
+
+// start logs that op began and returns a function that logs its end.
+func (l *LoggedStorage) start(ctx context.Context, op string, attrs ...any) func(err error, more ...any) {
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return func(error, ...any) {}
+	}
+	attrs = append([]any{"op", op}, attrs...)
+	slog.DebugContext(ctx, "storage request started", attrs...)
+	began := time.Now()
+	return func(err error, more ...any) {
+		attrs := append(attrs, more...)
+		attrs = append(attrs, "duration", time.Since(began).Round(time.Millisecond))
+		if err != nil {
+			slog.DebugContext(ctx, "storage request failed", append(attrs, "error", err)...)
+			return
+		}
+		slog.DebugContext(ctx, "storage request done", attrs...)
+	}
+}
+
+func (l *LoggedStorage) Upload(ctx context.Context, key string, data []byte) error {
+	done := l.start(ctx, "upload", "key", key, "bytes", len(data))
+	err := l.inner.Upload(ctx, key, data)
+	done(err)
+	return err
+}
+
+func (l *LoggedStorage) Download(ctx context.Context, key string) ([]byte, error) {
+	done := l.start(ctx, "download", "key", key)
+	data, err := l.inner.Download(ctx, key)
+	done(err, "bytes", len(data))
+	return data, err
+}
+
+func (l *LoggedStorage) Delete(ctx context.Context, key string) error {
+	done := l.start(ctx, "delete", "key", key)
+	err := l.inner.Delete(ctx, key)
+	done(err)
+	return err
+}
+
+func (l *LoggedStorage) DeleteBatch(ctx context.Context, keys []string) error {
+	done := l.start(ctx, "delete_batch", "keys", len(keys))
+	err := l.inner.DeleteBatch(ctx, keys)
+	done(err)
+	return err
+}
+
+func (l *LoggedStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	done := l.start(ctx, "list", "prefix", prefix)
+	objects, err := l.inner.List(ctx, prefix)
+	done(err, "objects", len(objects))
+	return objects, err
+}
+
+func (l *LoggedStorage) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	done := l.start(ctx, "head", "key", key)
+	info, err := l.inner.Head(ctx, key)
+	done(err, "found", info != nil)
+	return info, err
+}
+
+func (l *LoggedStorage) HeadBatch(ctx context.Context, keys []string) (map[string]*ObjectInfo, error) {
+	done := l.start(ctx, "head_batch", "keys", len(keys))
+	found, err := l.inner.HeadBatch(ctx, keys)
+	done(err, "found", len(found))
+	return found, err
+}
+
+func (l *LoggedStorage) BucketExists(ctx context.Context) (bool, error) {
+	done := l.start(ctx, "bucket_exists")
+	exists, err := l.inner.BucketExists(ctx)
+	done(err, "exists", exists)
+	return exists, err
+}
+
+// CopyToBucket copies key to bucket. The inner store must be a
+// BucketCopier.
+func (l *LoggedStorage) CopyToBucket(ctx context.Context, key, bucket string) error {
+	c, ok := l.inner.(BucketCopier)
+	if !ok {
+		return fmt.Errorf("%T cannot copy between buckets", l.inner)
+	}
+	done := l.start(ctx, "copy", "key", key, "bucket", bucket)
+	err := c.CopyToBucket(ctx, key, bucket)
+	done(err)
+	return err
+}
+
+// UploadIfMatch implements ConditionalUploader when the inner store does.
+func (l *LoggedStorage) UploadIfMatch(ctx context.Context, key string, data []byte, etag string) error {
+	done := l.start(ctx, "upload_if_match", "key", key, "bytes", len(data), "etag", etag)
+	err := UploadIfMatch(ctx, l.inner, key, data, etag)
+	done(err)
+	return err
+}
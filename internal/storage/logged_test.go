@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLoggedStorage(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l *slog.Logger) { slog.SetDefault(l) }(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	ctx := context.Background()
+	s := NewLogged(mapStorage(map[string][]byte{"a.age": []byte("x")}))
+	if _, err := s.List(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	failing := NewLogged(&MockStorage{UploadFunc: func(context.Context, string, []byte) error {
+		return errors.New("403 Forbidden")
+	}})
+	if err := failing.Upload(ctx, "b.age", []byte("yy")); err == nil {
+		t.Fatal("Upload succeeded")
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		`msg="storage request done" op=list prefix="" objects=1`,
+		`msg="storage request started" op=upload key=b.age bytes=2`,
+		`msg="storage request failed" op=upload key=b.age bytes=2 duration=`,
+		`error="403 Forbidden"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log lacks %s:\n%s", want, out)
+		}
+	}
+}
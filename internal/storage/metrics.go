@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// RequestStats counts storage API calls by kind. Providers bill List, Get and
+// Put requests separately, so they are kept apart. Each List call counts once
+// even when the provider pages through a large listing internally, and a
+// DeleteBatch counts as one Delete.
+type RequestStats struct {
+	List      int64 `json:"list"`
+	Get       int64 `json:"get"`
+	Put       int64 `json:"put"`
+	Delete    int64 `json:"delete"`
+	Head      int64 `json:"head"`
+	BytesUp   int64 `json:"bytes_up"`
+	BytesDown int64 `json:"bytes_down"`
+}
+
+// Total returns the number of requests of any kind.
+func (r RequestStats) Total() int64 {
+	return r.List + r.Get + r.Put + r.Delete + r.Head
+}
+
+// Add returns the sum of r and o.
+func (r RequestStats) Add(o RequestStats) RequestStats {
+	return RequestStats{
+		List:      r.List + o.List,
+		Get:       r.Get + o.Get,
+		Put:       r.Put + o.Put,
+		Delete:    r.Delete + o.Delete,
+		Head:      r.Head + o.Head,
+		BytesUp:   r.BytesUp + o.BytesUp,
+		BytesDown: r.BytesDown + o.BytesDown,
+	}
+}
+
+// Sub returns r minus o, for measuring the requests made between two points.
+func (r RequestStats) Sub(o RequestStats) RequestStats {
+	return RequestStats{
+		List:      r.List - o.List,
+		Get:       r.Get - o.Get,
+		Put:       r.Put - o.Put,
+		Delete:    r.Delete - o.Delete,
+		Head:      r.Head - o.Head,
+		BytesUp:   r.BytesUp - o.BytesUp,
+		BytesDown: r.BytesDown - o.BytesDown,
+	}
+}
+
+func (r RequestStats) String() string {
+	return fmt.Sprintf("%d list, %d get, %d put, %d delete, %d head", r.List, r.Get, r.Put, r.Delete, r.Head)
+}
+
+// Indexes into requestCounters.
+const (
+	countList = iota
+	countGet
+	countPut
+	countDelete
+	countHead
+	countBytesUp
+	countBytesDown
+	numCounters
+)
+
+// requestCounters is the concurrency-safe form of RequestStats.
+type requestCounters [numCounters]atomic.Int64
+
+func (c *requestCounters) snapshot() RequestStats {
+	return RequestStats{
+		List:      c[countList].Load(),
+		Get:       c[countGet].Load(),
+		Put:       c[countPut].Load(),
+		Delete:    c[countDelete].Load(),
+		Head:      c[countHead].Load(),
+		BytesUp:   c[countBytesUp].Load(),
+		BytesDown: c[countBytesDown].Load(),
+	}
+}
+
+// processCounters aggregates requests across every metered store in the
+// process, so the CLI can report per-command totals however many clients a
+// command creates.
+var processCounters requestCounters
+
+// ProcessRequestStats returns the requests made by all metered stores so far.
+func ProcessRequestStats() RequestStats {
+	return processCounters.snapshot()
+}
+
+// MeteredStorage wraps a Storage and counts the requests made through it.
+// Counts go to both the wrapper and the process-wide totals.
+type MeteredStorage struct {
+	inner    Storage
+	counters requestCounters
+}
+
+// NewMetered wraps s with request counting. Wrapping an already metered store
+// returns it unchanged.
+func NewMetered(s Storage) *MeteredStorage {
+	if m, ok := s.(*MeteredStorage); ok {
+		return m
+	}
+	return &MeteredStorage{inner: s}
+}
+
+// Stats returns the requests made through this store.
+func (m *MeteredStorage) Stats() RequestStats {
+	return m.counters.snapshot()
+}
+
+// Unwrap returns the underlying store.
+func (m *MeteredStorage) Unwrap() Storage {
+	return m.inner
+}
+
+func (m *MeteredStorage) count(kind int, n int64) {
+	m.counters[kind].Add(n)
+	processCounters[kind].Add(n)
+}
+
+func (m *MeteredStorage) Upload(ctx context.Context, key string, data []byte) error {
+	m.count(countPut, 1)
+	err := m.inner.Upload(ctx, key, data)
+	if err == nil {
+		m.count(countBytesUp, int64(len(data)))
+	}
+	return err
+}
+
+func (m *MeteredStorage) Download(ctx context.Context, key string) ([]byte, error) {
+	m.count(countGet, 1)
+	data, err := m.inner.Download(ctx, key)
+	m.count(countBytesDown, int64(len(data)))
+	return data, err
+}
+
+func (m *MeteredStorage) Delete(ctx context.Context, key string) error {
+	m.count(countDelete, 1)
+	return m.inner.Delete(ctx, key)
+}
+
+func (m *MeteredStorage) DeleteBatch(ctx context.Context, keys []string) error {
+	m.count(countDelete, 1)
+	return m.inner.DeleteBatch(ctx, keys)
+}
+
+func (m *MeteredStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	m.count(countList, 1)
+	return m.inner.List(ctx, prefix)
+}
+
+func (m *MeteredStorage) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	m.count(countHead, 1)
+	return m.inner.Head(ctx, key)
+}
+
+// HeadBatch counts as one List, which is what HeadBatchByList issues unless
+// the keys share no directory.
+func (m *MeteredStorage) HeadBatch(ctx context.Context, keys []string) (map[string]*ObjectInfo, error) {
+	if len(keys) > 0 {
+		m.count(countList, 1)
+	}
+	return m.inner.HeadBatch(ctx, keys)
+}
+
+func (m *MeteredStorage) BucketExists(ctx context.Context) (bool, error) {
+	m.count(countHead, 1)
+	return m.inner.BucketExists(ctx)
+}
+
+// CopyToBucket counts as one Put. The inner store must be a BucketCopier.
+func (m *MeteredStorage) CopyToBucket(ctx context.Context, key, bucket string) error {
+	c, ok := m.inner.(BucketCopier)
+	if !ok {
+		return fmt.Errorf("%T cannot copy between buckets", m.inner)
+	}
+	m.count(countPut, 1)
+	return c.CopyToBucket(ctx, key, bucket)
+}
+
+// UploadIfMatch implements ConditionalUploader when the inner store does,
+// counting as a Put.
+func (m *MeteredStorage) UploadIfMatch(ctx context.Context, key string, data []byte, etag string) error {
+	err := UploadIfMatch(ctx, m.inner, key, data, etag)
+	if errors.Is(err, ErrConditionalUnsupported) {
+		return err
+	}
+	m.count(countPut, 1)
+	if err == nil {
+		m.count(countBytesUp, int64(len(data)))
+	}
+	return err
+}
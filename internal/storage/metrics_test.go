@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMeteredStorageCounts(t *testing.T) {
+	ctx := context.Background()
+	mock := &MockStorage{
+		DownloadFunc: func(ctx context.Context, key string) ([]byte, error) {
+			return []byte("12345"), nil
+		},
+	}
+	m := NewMetered(mock)
+	before := ProcessRequestStats()
+
+	_ = m.Upload(ctx, "a.age", []byte("abc"))
+	_, _ = m.Download(ctx, "a.age")
+	_, _ = m.List(ctx, "")
+	_, _ = m.HeadBatch(ctx, []string{"a.age", "b.age"})
+	_, _ = m.Head(ctx, "a.age")
+	_ = m.Delete(ctx, "a.age")
+	_ = m.DeleteBatch(ctx, []string{"a.age", "b.age"})
+
+	want := RequestStats{List: 2, Get: 1, Put: 1, Delete: 2, Head: 1, BytesUp: 3, BytesDown: 5}
+	if got := m.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if got := ProcessRequestStats().Sub(before); got != want {
+		t.Errorf("Process stats delta = %+v, want %+v", got, want)
+	}
+	if want.Total() != 7 {
+		t.Errorf("Total() = %d, want 7", want.Total())
+	}
+}
+
+func TestNewMeteredDoesNotDoubleWrap(t *testing.T) {
+	m := NewMetered(&MockStorage{})
+	if NewMetered(m) != m {
+		t.Error("Expected wrapping a metered store to return it unchanged")
+	}
+}
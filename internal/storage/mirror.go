@@ -0,0 +1,83 @@
+package storage
+
+import "context"
+
+// MirroredStorage reads from a primary store and writes to both it and a
+// secondary one. It keeps a bucket that has been moved away from current
+// while devices still using it switch over. Writes to the secondary are
+// best-effort: their errors are ignored, since the secondary is on its way out.
+type MirroredStorage struct {
+	primary   Storage
+	secondary Storage
+}
+
+// NewMirrored returns a store that mirrors writes to secondary.
+func NewMirrored(primary, secondary Storage) *MirroredStorage {
+	return &MirroredStorage{primary: primary, secondary: secondary}
+}
+
+// Stats returns the requests made through both stores, when they are metered.
+func (m *MirroredStorage) Stats() RequestStats {
+	var total RequestStats
+	for _, s := range []Storage{m.primary, m.secondary} {
+		if metered, ok := s.(*MeteredStorage); ok {
+			total = total.Add(metered.Stats())
+		}
+	}
+	return total
+}
+
+func (m *MirroredStorage) Upload(ctx context.Context, key string, data []byte) error {
+	if err := m.primary.Upload(ctx, key, data); err != nil {
+		return err
+	}
+	// The transfer's progress was the primary's
+	_ = m.secondary.Upload(WithProgress(ctx, nil), key, data)
+	return nil
+}
+
+func (m *MirroredStorage) Download(ctx context.Context, key string) ([]byte, error) {
+	return m.primary.Download(ctx, key)
+}
+
+func (m *MirroredStorage) Delete(ctx context.Context, key string) error {
+	if err := m.primary.Delete(ctx, key); err != nil {
+		return err
+	}
+	_ = m.secondary.Delete(ctx, key)
+	return nil
+}
+
+func (m *MirroredStorage) DeleteBatch(ctx context.Context, keys []string) error {
+	if err := m.primary.DeleteBatch(ctx, keys); err != nil {
+		return err
+	}
+	_ = m.secondary.DeleteBatch(ctx, keys)
+	return nil
+}
+
+func (m *MirroredStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	return m.primary.List(ctx, prefix)
+}
+
+func (m *MirroredStorage) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	return m.primary.Head(ctx, key)
+}
+
+func (m *MirroredStorage) HeadBatch(ctx context.Context, keys []string) (map[string]*ObjectInfo, error) {
+	return m.primary.HeadBatch(ctx, keys)
+}
+
+func (m *MirroredStorage) BucketExists(ctx context.Context) (bool, error) {
+	return m.primary.BucketExists(ctx)
+}
+
+// UploadIfMatch makes the condition on the primary, when it can, and mirrors
+// a successful upload to the secondary.
+func (m *MirroredStorage) UploadIfMatch(ctx context.Context, key string, data []byte, etag string) error {
+	if err := UploadIfMatch(ctx, m.primary, key, data, etag); err != nil {
+		return err
+	}
+	_ = m.secondary.Upload(WithProgress(ctx, nil), key, data)
+	return nil
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMirroredStorageWritesBoth(t *testing.T) {
+	ctx := context.Background()
+	var primaryKeys, secondaryKeys []string
+	primary := &MockStorage{
+		UploadFunc: func(ctx context.Context, key string, data []byte) error {
+			primaryKeys = append(primaryKeys, key)
+			return nil
+		},
+		DownloadFunc: func(ctx context.Context, key string) ([]byte, error) {
+			return []byte("primary"), nil
+		},
+	}
+	secondary := &MockStorage{
+		UploadFunc: func(ctx context.Context, key string, data []byte) error {
+			secondaryKeys = append(secondaryKeys, key)
+			return errors.New("unreachable")
+		},
+		DownloadFunc: func(ctx context.Context, key string) ([]byte, error) {
+			t.Error("Reads must not go to the secondary")
+			return nil, nil
+		},
+	}
+
+	m := NewMirrored(primary, secondary)
+	if err := m.Upload(ctx, "a.age", []byte("x")); err != nil {
+		t.Fatalf("Secondary errors should be ignored, got %v", err)
+	}
+	if len(primaryKeys) != 1 || len(secondaryKeys) != 1 {
+		t.Errorf("Expected one upload to each store, got %v and %v", primaryKeys, secondaryKeys)
+	}
+	if data, _ := m.Download(ctx, "a.age"); string(data) != "primary" {
+		t.Errorf("Download = %q, want primary", data)
+	}
+
+	primary.UploadFunc = func(ctx context.Context, key string, data []byte) error {
+		return errors.New("denied")
+	}
+	if err := m.Upload(ctx, "b.age", nil); err == nil {
+		t.Error("Expected primary errors to be returned")
+	}
+	if len(secondaryKeys) != 1 {
+		t.Error("Expected no secondary write after a failed primary write")
+	}
+}
+
+func TestCopyObjectFallsBackToDownload(t *testing.T) {
+	ctx := context.Background()
+	src := NewMetered(&MockStorage{
+		DownloadFunc: func(ctx context.Context, key string) ([]byte, error) {
+			return []byte("data"), nil
+		},
+	})
+	var got string
+	dst := &MockStorage{
+		UploadFunc: func(ctx context.Context, key string, data []byte) error {
+			got = key + "=" + string(data)
+			return nil
+		},
+	}
+	if err := CopyObject(ctx, src, dst, "a.age", "other"); err != nil {
+		t.Fatalf("CopyObject failed: %v", err)
+	}
+	if got != "a.age=data" {
+		t.Errorf("Unexpected upload %q", got)
+	}
+}
+
+func TestCopySource(t *testing.T) {
+	got := CopySource("bucket", "projects/my app/a#1.age")
+	if want := "bucket/projects/my%20app/a%231.age"; got != want {
+		t.Errorf("CopySource = %q, want %q", got, want)
+	}
+}
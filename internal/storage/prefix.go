@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// PrefixedStorage keeps every key under a fixed prefix of another store, so
+// several users or profiles can share one bucket without colliding. Callers
+// see keys without the prefix, including in List results.
+type PrefixedStorage struct {
+	inner  Storage
+	prefix string
+}
+
+// NewPrefixed returns a store that keeps keys under prefix in s. prefix is
+// normalized by NormalizeKeyPrefix; an empty one returns s unchanged.
+func NewPrefixed(s Storage, prefix string) Storage {
+	prefix = NormalizeKeyPrefix(prefix)
+	if prefix == "" {
+		return s
+	}
+	return &PrefixedStorage{inner: s, prefix: prefix}
+}
+
+// NormalizeKeyPrefix returns prefix without leading slashes and with one
+// trailing slash ("laptops/tew" becomes "laptops/tew/"), or "".
+func NormalizeKeyPrefix(prefix string) string {
+	prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+func validateKeyPrefix(prefix string) error {
+	for _, segment := range strings.Split(strings.Trim(prefix, "/"), "/") {
+		if segment == "." || segment == ".." {
+			return fmt.Errorf("key_prefix %q must not contain . or .. segments", prefix)
+		}
+	}
+	if strings.Contains(strings.Trim(prefix, "/"), "//") {
+		return fmt.Errorf("key_prefix %q must not contain empty segments", prefix)
+	}
+	return nil
+}
+
+// Prefix returns the normalized prefix keys are stored under.
+func (p *PrefixedStorage) Prefix() string {
+	return p.prefix
+}
+
+// Unwrap returns the underlying store.
+func (p *PrefixedStorage) Unwrap() Storage {
+	return p.inner
+}
+
+func (p *PrefixedStorage) Upload(ctx context.Context, key string, data []byte) error {
+	return p.inner.Upload(ctx, p.prefix+key, data)
+}
+
+func (p *PrefixedStorage) Download(ctx context.Context, key string) ([]byte, error) {
+	return p.inner.Download(ctx, p.prefix+key)
+}
+
+func (p *PrefixedStorage) Delete(ctx context.Context, key string) error {
+	return p.inner.Delete(ctx, p.prefix+key)
+}
+
+func (p *PrefixedStorage) DeleteBatch(ctx context.Context, keys []string) error {
+	return p.inner.DeleteBatch(ctx, p.keys(keys))
+}
+
+func (p *PrefixedStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	objects, err := p.inner.List(ctx, p.prefix+prefix)
+	if err != nil {
+		return nil, err
+	}
+	for i := range objects {
+		objects[i].Key = strings.TrimPrefix(objects[i].Key, p.prefix)
+	}
+	return objects, nil
+}
+
+func (p *PrefixedStorage) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	info, err := p.inner.Head(ctx, p.prefix+key)
+	if err != nil || info == nil {
+		return info, err
+	}
+	unprefixed := *info
+	unprefixed.Key = strings.TrimPrefix(info.Key, p.prefix)
+	return &unprefixed, nil
+}
+
+func (p *PrefixedStorage) HeadBatch(ctx context.Context, keys []string) (map[string]*ObjectInfo, error) {
+	found, err := p.inner.HeadBatch(ctx, p.keys(keys))
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*ObjectInfo, len(found))
+	for key, info := range found {
+		key = strings.TrimPrefix(key, p.prefix)
+		if info != nil {
+			unprefixed := *info
+			unprefixed.Key = key
+			info = &unprefixed
+		}
+		result[key] = info
+	}
+	return result, nil
+}
+
+func (p *PrefixedStorage) BucketExists(ctx context.Context) (bool, error) {
+	return p.inner.BucketExists(ctx)
+}
+
+// CopyToBucket copies key to the same prefixed key in bucket. The inner
+// store must be a BucketCopier.
+func (p *PrefixedStorage) CopyToBucket(ctx context.Context, key, bucket string) error {
+	c, ok := p.inner.(BucketCopier)
+	if !ok {
+		return fmt.Errorf("%T cannot copy between buckets", p.inner)
+	}
+	return c.CopyToBucket(ctx, p.prefix+key, bucket)
+}
+
+func (p *PrefixedStorage) keys(keys []string) []string {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = p.prefix + key
+	}
+	return prefixed
+}
+
+// UploadIfMatch implements ConditionalUploader when the inner store does.
+func (p *PrefixedStorage) UploadIfMatch(ctx context.Context, key string, data []byte, etag string) error {
+	return UploadIfMatch(ctx, p.inner, p.prefix+key, data, etag)
+}
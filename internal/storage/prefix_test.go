@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// mapStorage is an in-memory bucket for exercising wrappers.
+func mapStorage(objects map[string][]byte) *MockStorage {
+	return &MockStorage{
+		UploadFunc: func(ctx context.Context, key string, data []byte) error {
+			objects[key] = data
+			return nil
+		},
+		DownloadFunc: func(ctx context.Context, key string) ([]byte, error) {
+			return objects[key], nil
+		},
+		DeleteBatchFunc: func(ctx context.Context, keys []string) error {
+			for _, key := range keys {
+				delete(objects, key)
+			}
+			return nil
+		},
+		ListFunc: func(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+			var list []ObjectInfo
+			for key, data := range objects {
+				if strings.HasPrefix(key, prefix) {
+					list = append(list, ObjectInfo{Key: key, Size: int64(len(data))})
+				}
+			}
+			sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+			return list, nil
+		},
+		HeadBatchFunc: func(ctx context.Context, keys []string) (map[string]*ObjectInfo, error) {
+			found := make(map[string]*ObjectInfo)
+			for _, key := range keys {
+				if data, ok := objects[key]; ok {
+					found[key] = &ObjectInfo{Key: key, Size: int64(len(data))}
+				}
+			}
+			return found, nil
+		},
+	}
+}
+
+func TestPrefixedStorage(t *testing.T) {
+	ctx := context.Background()
+	objects := map[string][]byte{"other/CLAUDE.md.age": []byte("theirs")}
+	s := NewPrefixed(mapStorage(objects), "/laptops/tew")
+
+	if err := s.Upload(ctx, "CLAUDE.md.age", []byte("mine")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := objects["laptops/tew/CLAUDE.md.age"]; !ok {
+		t.Fatalf("Upload stored %v, want the key under laptops/tew/", objects)
+	}
+	if data, _ := s.Download(ctx, "CLAUDE.md.age"); string(data) != "mine" {
+		t.Errorf("Download = %q, want mine", data)
+	}
+
+	list, err := s.List(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Key != "CLAUDE.md.age" {
+		t.Errorf("List = %+v, want only this prefix's object, unprefixed", list)
+	}
+
+	found, err := s.HeadBatch(ctx, []string{"CLAUDE.md.age", "missing.age"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found["CLAUDE.md.age"] == nil || found["CLAUDE.md.age"].Key != "CLAUDE.md.age" {
+		t.Errorf("HeadBatch = %v", found)
+	}
+
+	if err := s.DeleteBatch(ctx, []string{"CLAUDE.md.age"}); err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 0, len(objects))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	if !reflect.DeepEqual(keys, []string{"other/CLAUDE.md.age"}) {
+		t.Errorf("after DeleteBatch the bucket holds %v", keys)
+	}
+}
+
+func TestNewPrefixedEmpty(t *testing.T) {
+	inner := &MockStorage{}
+	if s := NewPrefixed(inner, " / "); s != Storage(inner) {
+		t.Errorf("NewPrefixed with an empty prefix = %T, want the store itself", s)
+	}
+}
+
+func TestValidateKeyPrefix(t *testing.T) {
+	for prefix, ok := range map[string]bool{
+		"":             true,
+		"laptops/tew/": true,
+		"/team":        true,
+		"a/../b":       false,
+		"./a":          false,
+		"a//b":         false,
+	} {
+		if err := validateKeyPrefix(prefix); (err == nil) != ok {
+			t.Errorf("validateKeyPrefix(%q) = %v", prefix, err)
+		}
+	}
+}
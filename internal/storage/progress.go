@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// progressInterval is how many bytes a transfer moves between reports.
+const progressInterval = 64 * 1024
+
+// progressKey is the context key of the function WithProgress sets.
+type progressKey struct{}
+
+// ProgressFunc is told how many of an object's size bytes have been sent or
+// received so far; size is -1 when the adapter doesn't know it.
+type ProgressFunc func(done, size int64)
+
+// WithProgress returns a copy of ctx under which Upload and Download report
+// their progress to fn as the object's data moves. A nil fn turns reporting
+// off again.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ProgressReader wraps r, the body of an upload or download of size bytes
+// (-1 if unknown), to report its progress to the function WithProgress set
+// on ctx. Without one, r is returned as it is. Adapters wrap every body they
+// send or receive with it.
+func ProgressReader(ctx context.Context, r io.Reader, size int64) io.Reader {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	if fn == nil {
+		return r
+	}
+	p := &progressReader{r: r, size: size, report: fn}
+	if _, ok := r.(io.ReadSeeker); ok {
+		return seekingProgressReader{p}
+	}
+	return p
+}
+
+type progressReader struct {
+	r                    io.Reader
+	size, done, reported int64
+	report               ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.done += int64(n)
+	if p.done-p.reported >= progressInterval || (err == io.EOF && p.done != p.reported) {
+		p.reported = p.done
+		p.report(p.done, p.size)
+	}
+	return n, err
+}
+
+// Len returns the bytes left to read when the reader wrapped knows them, as
+// a bytes.Reader does, so HTTP requests can still send a Content-Length.
+func (p *progressReader) Len() int {
+	if l, ok := p.r.(interface{ Len() int }); ok {
+		return l.Len()
+	}
+	return -1
+}
+
+// seekingProgressReader is a progressReader over an io.ReadSeeker, which
+// the S3 SDK needs to sign and retry uploads. A seek back, as when a body is
+// hashed before it is sent or a request is retried, counts from there again.
+type seekingProgressReader struct {
+	*progressReader
+}
+
+func (p seekingProgressReader) Seek(offset int64, whence int) (int64, error) {
+	pos, err := p.r.(io.Seeker).Seek(offset, whence)
+	if err == nil {
+		p.done, p.reported = pos, pos
+	}
+	return pos, err
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+)
+
+func TestProgressReader(t *testing.T) {
+	data := make([]byte, 3*progressInterval+10)
+	r := bytes.NewReader(data)
+	if got := ProgressReader(context.Background(), r, int64(len(data))); got != io.Reader(r) {
+		t.Error("Expected the reader unwrapped without a progress func")
+	}
+
+	var reports []int64
+	ctx := WithProgress(context.Background(), func(done, size int64) {
+		if size != int64(len(data)) {
+			t.Errorf("size = %d, want %d", size, len(data))
+		}
+		reports = append(reports, done)
+	})
+	body := ProgressReader(ctx, bytes.NewReader(data), int64(len(data)))
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) < 2 || reports[len(reports)-1] != int64(len(data)) {
+		t.Errorf("reports = %v, want several ending at %d", reports, len(data))
+	}
+
+	// A request hashing its body first counts the sending from the start
+	seeker, ok := body.(io.ReadSeeker)
+	if !ok {
+		t.Fatal("Expected a seekable body to stay seekable")
+	}
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	reports = nil
+	if _, err := io.CopyN(io.Discard, seeker, progressInterval); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0] != progressInterval {
+		t.Errorf("reports after seeking back = %v, want [%d]", reports, progressInterval)
+	}
+	if l, ok := body.(interface{ Len() int }); !ok || l.Len() != len(data)-progressInterval {
+		t.Error("Expected Len to report the bytes left")
+	}
+
+	if got := ProgressReader(WithProgress(ctx, nil), r, 0); got != io.Reader(r) {
+		t.Error("Expected WithProgress(nil) to turn reporting off")
+	}
+}
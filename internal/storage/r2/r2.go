@@ -6,8 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -57,13 +59,39 @@ func New(cfg *storage.StorageConfig) (storage.Storage, error) {
 
 // Upload stores data with the given key
 func (c *Client) Upload(ctx context.Context, key string, data []byte) error {
-	_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(c.bucket),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader(data),
-		ContentType: aws.String("application/octet-stream"),
+	return c.put(ctx, key, data, nil)
+}
+
+// UploadIfMatch implements storage.ConditionalUploader with If-Match, or
+// If-None-Match for a new object.
+func (c *Client) UploadIfMatch(ctx context.Context, key string, data []byte, etag string) error {
+	return c.put(ctx, key, data, func(in *s3.PutObjectInput) {
+		if etag == "" {
+			in.IfNoneMatch = aws.String("*")
+		} else {
+			in.IfMatch = aws.String(etag)
+		}
 	})
-	if err != nil {
+}
+
+func (c *Client) put(ctx context.Context, key string, data []byte, condition func(*s3.PutObjectInput)) error {
+	in := &s3.PutObjectInput{
+		Bucket:        aws.String(c.bucket),
+		Key:           aws.String(key),
+		Body:          storage.ProgressReader(ctx, bytes.NewReader(data), int64(len(data))),
+		ContentLength: aws.Int64(int64(len(data))),
+		ContentType:   aws.String("application/octet-stream"),
+	}
+	if condition != nil {
+		condition(in)
+	}
+	if _, err := c.client.PutObject(ctx, in); err != nil {
+		// 409 is a conditional write that raced another one in flight
+		var respErr *awshttp.ResponseError
+		if condition != nil && errors.As(err, &respErr) &&
+			(respErr.HTTPStatusCode() == http.StatusPreconditionFailed || respErr.HTTPStatusCode() == http.StatusConflict) {
+			return fmt.Errorf("failed to upload %s: %w", key, storage.ErrPreconditionFailed)
+		}
 		return fmt.Errorf("failed to upload %s: %w", key, err)
 	}
 	return nil
@@ -81,7 +109,8 @@ func (c *Client) Download(ctx context.Context, key string) ([]byte, error) {
 	defer func() { _ = result.Body.Close() }()
 
 	// Limit download size to prevent memory exhaustion
-	limited := io.LimitReader(result.Body, storage.MaxDownloadSize+1)
+	body := storage.ProgressReader(ctx, result.Body, aws.ToInt64(result.ContentLength))
+	limited := io.LimitReader(body, storage.MaxDownloadSize+1)
 	data, err := io.ReadAll(limited)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read %s: %w", key, err)
@@ -167,6 +196,9 @@ func (c *Client) List(ctx context.Context, prefix string) ([]storage.ObjectInfo,
 				Size:         aws.ToInt64(obj.Size),
 				LastModified: aws.ToTime(obj.LastModified),
 				ETag:         aws.ToString(obj.ETag),
+				// R2 has no KMS encryption, and claude-sync never uploads
+				// with SSE-C
+				MD5ETag: true,
 			})
 		}
 
@@ -186,6 +218,10 @@ func (c *Client) Head(ctx context.Context, key string) (*storage.ObjectInfo, err
 		Key:    aws.String(key),
 	})
 	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("%w: %s", storage.ErrNotFound, key)
+		}
 		return nil, err
 	}
 
@@ -194,9 +230,28 @@ func (c *Client) Head(ctx context.Context, key string) (*storage.ObjectInfo, err
 		Size:         aws.ToInt64(result.ContentLength),
 		LastModified: aws.ToTime(result.LastModified),
 		ETag:         aws.ToString(result.ETag),
+		MD5ETag:      result.SSECustomerAlgorithm == nil,
 	}, nil
 }
 
+// CopyToBucket copies an object to the same key in another bucket server-side
+func (c *Client) CopyToBucket(ctx context.Context, key, bucket string) error {
+	_, err := c.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(storage.CopySource(c.bucket, key)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", key, bucket, err)
+	}
+	return nil
+}
+
+// HeadBatch returns metadata for many keys using a single List
+func (c *Client) HeadBatch(ctx context.Context, keys []string) (map[string]*storage.ObjectInfo, error) {
+	return storage.HeadBatchByList(ctx, c, keys)
+}
+
 // BucketExists checks if the configured bucket exists
 func (c *Client) BucketExists(ctx context.Context) (bool, error) {
 	_, err := c.client.HeadBucket(ctx, &s3.HeadBucketInput{
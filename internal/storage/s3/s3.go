@@ -6,12 +6,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 
 	"github.com/tawanorg/claude-sync/internal/storage"
 )
@@ -24,30 +31,88 @@ func init() {
 type Client struct {
 	client *s3.Client
 	bucket string
+	region string
 }
 
 // New creates a new S3 storage client
 func New(cfg *storage.StorageConfig) (storage.Storage, error) {
-	awsCfg, err := config.LoadDefaultConfig(context.Background(),
-		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			cfg.AccessKeyID,
-			cfg.SecretAccessKey,
-			"",
-		)),
-		config.WithRegion(cfg.Region),
-	)
+	awsCfg, err := config.LoadDefaultConfig(context.Background(), loadOptions(cfg)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
+	if awsCfg.Region == "" {
+		return nil, fmt.Errorf("region is required for S3 (set region, or one for the AWS profile)")
+	}
+	AssumeRole(&awsCfg, cfg)
 
 	client := s3.NewFromConfig(awsCfg, buildS3Options(cfg))
 
 	return &Client{
 		client: client,
 		bucket: cfg.Bucket,
+		region: awsCfg.Region,
 	}, nil
 }
 
+// loadOptions returns how the AWS config is loaded: with the configured
+// access keys, or with UseDefaultCredentials from the default credential
+// chain (environment, Profile from the shared config files, web identity,
+// ECS or EC2 instance roles). With a web identity token, AssumeRole supplies
+// them. A configured region wins over the profile's.
+func loadOptions(cfg *storage.StorageConfig) []func(*config.LoadOptions) error {
+	var opts []func(*config.LoadOptions) error
+	switch {
+	case cfg.WebIdentityTokenFile != "":
+		// AssumeRole takes over; the token is all the role needs
+	case cfg.UseDefaultCredentials:
+		if cfg.Profile != "" {
+			opts = append(opts, config.WithSharedConfigProfile(cfg.Profile))
+		}
+	default:
+		opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			cfg.AccessKeyID,
+			cfg.SecretAccessKey,
+			"",
+		)))
+	}
+	if cfg.Region != "" {
+		opts = append(opts, config.WithRegion(cfg.Region))
+	}
+	return opts
+}
+
+// roleSessionName names the sessions of an assumed role in CloudTrail.
+const roleSessionName = "claude-sync"
+
+// AssumeRole switches awsCfg to cfg.RoleARN's credentials when one is set:
+// assumed with the credentials awsCfg has, or with the OIDC token in
+// cfg.WebIdentityTokenFile. The credentials are refreshed as they expire.
+func AssumeRole(awsCfg *aws.Config, cfg *storage.StorageConfig) {
+	if cfg.RoleARN == "" {
+		return
+	}
+	client := sts.NewFromConfig(*awsCfg)
+	var provider aws.CredentialsProvider
+	if cfg.WebIdentityTokenFile != "" {
+		provider = stscreds.NewWebIdentityRoleProvider(client, cfg.RoleARN,
+			stscreds.IdentityTokenFile(expandHome(cfg.WebIdentityTokenFile)),
+			func(o *stscreds.WebIdentityRoleOptions) { o.RoleSessionName = roleSessionName })
+	} else {
+		provider = stscreds.NewAssumeRoleProvider(client, cfg.RoleARN,
+			func(o *stscreds.AssumeRoleOptions) { o.RoleSessionName = roleSessionName })
+	}
+	awsCfg.Credentials = aws.NewCredentialsCache(provider)
+}
+
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
 // buildS3Options returns the functional options applied to the S3 client.
 // When a custom endpoint is configured (i.e. an S3-compatible provider such as
 // Backblaze B2, MinIO or Wasabi rather than AWS), it points the client at that
@@ -71,13 +136,39 @@ func buildS3Options(cfg *storage.StorageConfig) func(*s3.Options) {
 
 // Upload stores data with the given key
 func (c *Client) Upload(ctx context.Context, key string, data []byte) error {
-	_, err := c.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(c.bucket),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader(data),
-		ContentType: aws.String("application/octet-stream"),
+	return c.put(ctx, key, data, nil)
+}
+
+// UploadIfMatch implements storage.ConditionalUploader with If-Match, or
+// If-None-Match for a new object.
+func (c *Client) UploadIfMatch(ctx context.Context, key string, data []byte, etag string) error {
+	return c.put(ctx, key, data, func(in *s3.PutObjectInput) {
+		if etag == "" {
+			in.IfNoneMatch = aws.String("*")
+		} else {
+			in.IfMatch = aws.String(etag)
+		}
 	})
-	if err != nil {
+}
+
+func (c *Client) put(ctx context.Context, key string, data []byte, condition func(*s3.PutObjectInput)) error {
+	in := &s3.PutObjectInput{
+		Bucket:        aws.String(c.bucket),
+		Key:           aws.String(key),
+		Body:          storage.ProgressReader(ctx, bytes.NewReader(data), int64(len(data))),
+		ContentLength: aws.Int64(int64(len(data))),
+		ContentType:   aws.String("application/octet-stream"),
+	}
+	if condition != nil {
+		condition(in)
+	}
+	if _, err := c.client.PutObject(ctx, in); err != nil {
+		// 409 is a conditional write that raced another one in flight
+		var respErr *awshttp.ResponseError
+		if condition != nil && errors.As(err, &respErr) &&
+			(respErr.HTTPStatusCode() == http.StatusPreconditionFailed || respErr.HTTPStatusCode() == http.StatusConflict) {
+			return fmt.Errorf("failed to upload %s: %w", key, storage.ErrPreconditionFailed)
+		}
 		return fmt.Errorf("failed to upload %s: %w", key, err)
 	}
 	return nil
@@ -95,7 +186,8 @@ func (c *Client) Download(ctx context.Context, key string) ([]byte, error) {
 	defer func() { _ = result.Body.Close() }()
 
 	// Limit download size to prevent memory exhaustion
-	limited := io.LimitReader(result.Body, storage.MaxDownloadSize+1)
+	body := storage.ProgressReader(ctx, result.Body, aws.ToInt64(result.ContentLength))
+	limited := io.LimitReader(body, storage.MaxDownloadSize+1)
 	data, err := io.ReadAll(limited)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read %s: %w", key, err)
@@ -200,6 +292,10 @@ func (c *Client) Head(ctx context.Context, key string) (*storage.ObjectInfo, err
 		Key:    aws.String(key),
 	})
 	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("%w: %s", storage.ErrNotFound, key)
+		}
 		return nil, err
 	}
 
@@ -208,15 +304,41 @@ func (c *Client) Head(ctx context.Context, key string) (*storage.ObjectInfo, err
 		Size:         aws.ToInt64(result.ContentLength),
 		LastModified: aws.ToTime(result.LastModified),
 		ETag:         aws.ToString(result.ETag),
+		// SSE-S3 (AES256) keeps the MD5 ETag; SSE-KMS and SSE-C don't
+		MD5ETag: result.SSECustomerAlgorithm == nil &&
+			(result.ServerSideEncryption == "" || result.ServerSideEncryption == types.ServerSideEncryptionAes256),
 	}, nil
 }
 
+// CopyToBucket copies an object to the same key in another bucket server-side
+func (c *Client) CopyToBucket(ctx context.Context, key, bucket string) error {
+	_, err := c.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(key),
+		CopySource: aws.String(storage.CopySource(c.bucket, key)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", key, bucket, err)
+	}
+	return nil
+}
+
+// HeadBatch returns metadata for many keys using a single List
+func (c *Client) HeadBatch(ctx context.Context, keys []string) (map[string]*storage.ObjectInfo, error) {
+	return storage.HeadBatchByList(ctx, c, keys)
+}
+
 // BucketExists checks if the configured bucket exists
 func (c *Client) BucketExists(ctx context.Context) (bool, error) {
 	_, err := c.client.HeadBucket(ctx, &s3.HeadBucketInput{
 		Bucket: aws.String(c.bucket),
 	})
 	if err != nil {
+		// A bucket in another region answers 301 (or 400 when the signature
+		// region is wrong) with its real region in a header
+		if region := bucketRegionFromError(err); region != "" && region != c.region {
+			return false, &storage.BucketRegionError{Bucket: c.bucket, Region: region, ConfiguredRegion: c.region}
+		}
 		var notFound *types.NotFound
 		var noSuchBucket *types.NoSuchBucket
 		if errors.As(err, &notFound) || errors.As(err, &noSuchBucket) {
@@ -226,3 +348,13 @@ func (c *Client) BucketExists(ctx context.Context) (bool, error) {
 	}
 	return true, nil
 }
+
+// bucketRegionFromError returns the x-amz-bucket-region header of a failed
+// request, or "" if the response didn't carry one.
+func bucketRegionFromError(err error) string {
+	var respErr *awshttp.ResponseError
+	if !errors.As(err, &respErr) || respErr.Response == nil {
+		return ""
+	}
+	return respErr.Response.Header.Get("X-Amz-Bucket-Region")
+}
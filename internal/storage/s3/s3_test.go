@@ -1,6 +1,14 @@
 package s3
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -90,3 +98,211 @@ func TestBuildS3Options_AWSDefaultUnchanged(t *testing.T) {
 		t.Errorf("ResponseChecksumValidation = %v, want Unset (AWS default preserved)", opts.ResponseChecksumValidation)
 	}
 }
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) storage.Storage {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	client, err := New(&storage.StorageConfig{
+		Provider:        storage.ProviderS3,
+		Bucket:          "my-bucket",
+		Region:          "us-east-1",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+		Endpoint:        srv.URL,
+		UsePathStyle:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestBucketExists_RegionMismatch(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Amz-Bucket-Region", "eu-west-2")
+		w.WriteHeader(http.StatusMovedPermanently)
+	})
+
+	exists, err := client.BucketExists(context.Background())
+	var regionErr *storage.BucketRegionError
+	if !errors.As(err, &regionErr) {
+		t.Fatalf("BucketExists() = %v, %v; want BucketRegionError", exists, err)
+	}
+	if regionErr.Region != "eu-west-2" || regionErr.ConfiguredRegion != "us-east-1" {
+		t.Errorf("regions = %q, %q; want eu-west-2, us-east-1", regionErr.Region, regionErr.ConfiguredRegion)
+	}
+}
+
+func TestBucketExists_NotFound(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	exists, err := client.BucketExists(context.Background())
+	if err != nil || exists {
+		t.Errorf("BucketExists() = %v, %v; want false, nil", exists, err)
+	}
+}
+
+func TestBucketExists_ForbiddenIsAnError(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		// Same region as configured: not a mismatch, just no access
+		w.Header().Set("X-Amz-Bucket-Region", "us-east-1")
+		w.WriteHeader(http.StatusForbidden)
+	})
+
+	exists, err := client.BucketExists(context.Background())
+	var regionErr *storage.BucketRegionError
+	if err == nil || errors.As(err, &regionErr) {
+		t.Errorf("BucketExists() = %v, %v; want a plain error", exists, err)
+	}
+}
+
+func TestNew_SharedCredentialsProfile(t *testing.T) {
+	dir := t.TempDir()
+	credsFile := filepath.Join(dir, "credentials")
+	configFile := filepath.Join(dir, "config")
+	creds := "[default]\naws_access_key_id = AKIDDEFAULT\naws_secret_access_key = default-secret\n\n[work]\naws_access_key_id = AKIDWORK\naws_secret_access_key = work-secret\n"
+	if err := os.WriteFile(credsFile, []byte(creds), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configFile, []byte("[profile work]\nregion = eu-west-2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credsFile)
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	store, err := New(&storage.StorageConfig{
+		Provider:              storage.ProviderS3,
+		Bucket:                "test-bucket",
+		UseDefaultCredentials: true,
+		Profile:               "work",
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	c := store.(*Client)
+	if c.region != "eu-west-2" {
+		t.Errorf("region = %q, want the profile's eu-west-2", c.region)
+	}
+	got, err := c.client.Options().Credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccessKeyID != "AKIDWORK" {
+		t.Errorf("AccessKeyID = %q, want the work profile's", got.AccessKeyID)
+	}
+
+	// Without a region anywhere, New says so
+	if _, err := New(&storage.StorageConfig{
+		Provider:              storage.ProviderS3,
+		Bucket:                "test-bucket",
+		UseDefaultCredentials: true,
+	}); err == nil {
+		t.Error("New() without a region succeeded")
+	}
+}
+
+func TestAssumeRole_WebIdentity(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("oidc-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var form url.Values
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		form = r.Form
+		w.Header().Set("Content-Type", "text/xml")
+		_, _ = w.Write([]byte(`<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+<AssumeRoleWithWebIdentityResult><Credentials>
+<AccessKeyId>ASIAROLE</AccessKeyId><SecretAccessKey>role-secret</SecretAccessKey>
+<SessionToken>session</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration>
+</Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`))
+	}))
+	defer sts.Close()
+
+	cfg := &storage.StorageConfig{
+		Provider:             storage.ProviderS3,
+		Bucket:               "test-bucket",
+		Region:               "us-east-1",
+		RoleARN:              "arn:aws:iam::123456789012:role/claude-sync",
+		WebIdentityTokenFile: tokenFile,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() = %v, want a web identity config without keys accepted", err)
+	}
+	if opts := loadOptions(cfg); len(opts) != 1 {
+		t.Errorf("loadOptions() gave %d options, want only the region", len(opts))
+	}
+
+	awsCfg := aws.Config{Region: "us-east-1", BaseEndpoint: aws.String(sts.URL)}
+	AssumeRole(&awsCfg, cfg)
+	got, err := awsCfg.Credentials.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccessKeyID != "ASIAROLE" || got.SessionToken != "session" {
+		t.Errorf("credentials = %+v, want the role's", got)
+	}
+	if form.Get("Action") != "AssumeRoleWithWebIdentity" || form.Get("WebIdentityToken") != "oidc-token" ||
+		form.Get("RoleArn") != cfg.RoleARN || form.Get("RoleSessionName") != roleSessionName {
+		t.Errorf("STS request = %v", form)
+	}
+}
+
+func TestHead_MD5ETag(t *testing.T) {
+	for _, tt := range []struct {
+		encryption string
+		md5        bool
+	}{
+		{"", true},
+		{"AES256", true},
+		{"aws:kms", false},
+	} {
+		client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"9e107d9d372bb6826bd81d3542a419d6"`)
+			if tt.encryption != "" {
+				w.Header().Set("X-Amz-Server-Side-Encryption", tt.encryption)
+			}
+		})
+		info, err := client.Head(context.Background(), "a.age")
+		if err != nil {
+			t.Fatalf("Head() error = %v", err)
+		}
+		if info.MD5ETag != tt.md5 {
+			t.Errorf("Head() with encryption %q: MD5ETag = %v, want %v", tt.encryption, info.MD5ETag, tt.md5)
+		}
+	}
+}
+
+func TestUploadIfMatch(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		if r.Header.Get("If-None-Match") == "*" || r.Header.Get("If-Match") == `"stale"` {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		if r.Header.Get("If-Match") != `"current"` {
+			t.Errorf("If-Match = %q", r.Header.Get("If-Match"))
+		}
+		w.Header().Set("ETag", `"next"`)
+	})
+	cond := client.(storage.ConditionalUploader)
+	ctx := context.Background()
+
+	if err := cond.UploadIfMatch(ctx, "a.age", []byte("x"), ""); !errors.Is(err, storage.ErrPreconditionFailed) {
+		t.Errorf("UploadIfMatch(new) over an object = %v, want ErrPreconditionFailed", err)
+	}
+	if err := cond.UploadIfMatch(ctx, "a.age", []byte("x"), `"stale"`); !errors.Is(err, storage.ErrPreconditionFailed) {
+		t.Errorf("UploadIfMatch(stale) = %v, want ErrPreconditionFailed", err)
+	}
+	if err := cond.UploadIfMatch(ctx, "a.age", []byte("x"), `"current"`); err != nil {
+		t.Errorf("UploadIfMatch(current) error = %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -26,6 +27,11 @@ type ObjectInfo struct {
 	Size         int64
 	LastModified time.Time
 	ETag         string
+
+	// MD5ETag is set when the adapter knows ETag, if a plain 32-digit hex
+	// value rather than a multipart one, is the MD5 of the object: not so
+	// with SSE-KMS or SSE-C, which S3 listings don't reveal
+	MD5ETag bool
 }
 
 // Storage defines the interface for cloud storage operations
@@ -45,31 +51,65 @@ type Storage interface {
 	// List returns all objects with the given prefix
 	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
 
-	// Head returns metadata for the given key without downloading content
+	// Head returns metadata for the given key without downloading content.
+	// A missing key is an error wrapping ErrNotFound.
 	Head(ctx context.Context, key string) (*ObjectInfo, error)
 
+	// HeadBatch returns metadata for many keys at once, keyed by object key.
+	// Keys that don't exist are absent from the result rather than an error.
+	HeadBatch(ctx context.Context, keys []string) (map[string]*ObjectInfo, error)
+
 	// BucketExists checks if the configured bucket exists
 	BucketExists(ctx context.Context) (bool, error)
 }
 
+// ErrNotFound is wrapped by the error Head returns for a key that doesn't
+// exist.
+var ErrNotFound = errors.New("object not found")
+
+// BucketRegionError is returned by BucketExists when the bucket exists but
+// in a different region from the configured one.
+type BucketRegionError struct {
+	Bucket           string
+	Region           string // Where the bucket actually is
+	ConfiguredRegion string
+}
+
+func (e *BucketRegionError) Error() string {
+	return fmt.Sprintf("bucket %s exists in region %s, config says %s", e.Bucket, e.Region, e.ConfiguredRegion)
+}
+
 // New creates a new Storage instance based on the provided configuration
 func New(cfg *StorageConfig) (Storage, error) {
+	if err := cfg.ResolveCredentials(); err != nil {
+		return nil, err
+	}
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid storage config: %w", err)
 	}
 
+	var factory func(cfg *StorageConfig) (Storage, error)
 	switch cfg.Provider {
 	case ProviderR2:
-		return NewR2(cfg)
+		factory = NewR2
 	case ProviderS3:
-		return NewS3(cfg)
+		factory = NewS3
 	case ProviderGCS:
-		return NewGCS(cfg)
+		factory = NewGCS
 	case ProviderWebDAV:
-		return NewWebDAV(cfg)
+		factory = NewWebDAV
 	default:
 		return nil, fmt.Errorf("unsupported storage provider: %s", cfg.Provider)
 	}
+
+	store, err := factory(cfg)
+	if err != nil {
+		return nil, err
+	}
+	store = NewPrefixed(store, cfg.KeyPrefix)
+	store = NewLogged(store)
+	// Count requests so commands can report what they cost on request-billed providers
+	return NewMetered(store), nil
 }
 
 // NewR2 creates a new R2 storage adapter (implemented in r2/r2.go)
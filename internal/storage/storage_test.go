@@ -14,6 +14,7 @@ type MockStorage struct {
 	DeleteBatchFunc  func(ctx context.Context, keys []string) error
 	ListFunc         func(ctx context.Context, prefix string) ([]ObjectInfo, error)
 	HeadFunc         func(ctx context.Context, key string) (*ObjectInfo, error)
+	HeadBatchFunc    func(ctx context.Context, keys []string) (map[string]*ObjectInfo, error)
 	BucketExistsFunc func(ctx context.Context) (bool, error)
 }
 
@@ -59,6 +60,13 @@ func (m *MockStorage) Head(ctx context.Context, key string) (*ObjectInfo, error)
 	return nil, nil
 }
 
+func (m *MockStorage) HeadBatch(ctx context.Context, keys []string) (map[string]*ObjectInfo, error) {
+	if m.HeadBatchFunc != nil {
+		return m.HeadBatchFunc(ctx, keys)
+	}
+	return HeadBatchByList(ctx, m, keys)
+}
+
 func (m *MockStorage) BucketExists(ctx context.Context) (bool, error) {
 	if m.BucketExistsFunc != nil {
 		return m.BucketExistsFunc(ctx)
@@ -81,11 +81,27 @@ func (c *Client) collectionURL() string {
 	return c.baseURL + "/"
 }
 
+// statusError is an unexpected HTTP status from the server. Its
+// HTTPStatusCode lets storage.IsAuthError recognize rejected credentials.
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("HTTP %d", int(e))
+}
+
+func (e statusError) HTTPStatusCode() int {
+	return int(e)
+}
+
 func (c *Client) doRequest(ctx context.Context, method, url string, body io.Reader, headers map[string]string) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
+	// NewRequest only knows the length of plain byte readers
+	if sized, ok := body.(interface{ Len() int }); ok && sized.Len() >= 0 {
+		req.ContentLength = int64(sized.Len())
+	}
 	req.SetBasicAuth(c.username, c.password)
 	for k, v := range headers {
 		req.Header.Set(k, v)
@@ -95,21 +111,38 @@ func (c *Client) doRequest(ctx context.Context, method, url string, body io.Read
 
 // Upload stores data with the given key, creating parent directories as needed.
 func (c *Client) Upload(ctx context.Context, key string, data []byte) error {
+	return c.put(ctx, key, data, map[string]string{})
+}
+
+// UploadIfMatch implements storage.ConditionalUploader with an If-Match
+// header, or If-None-Match for a new object. Servers that ignore them make
+// it a plain upload.
+func (c *Client) UploadIfMatch(ctx context.Context, key string, data []byte, etag string) error {
+	if etag == "" {
+		return c.put(ctx, key, data, map[string]string{"If-None-Match": "*"})
+	}
+	return c.put(ctx, key, data, map[string]string{"If-Match": etag})
+}
+
+func (c *Client) put(ctx context.Context, key string, data []byte, headers map[string]string) error {
 	if err := c.ensureParentDirs(ctx, key); err != nil {
 		return fmt.Errorf("failed to create parent directories for %s: %w", key, err)
 	}
 
-	resp, err := c.doRequest(ctx, "PUT", c.fullURL(key), bytes.NewReader(data), map[string]string{
-		"Content-Type": "application/octet-stream",
-	})
+	headers["Content-Type"] = "application/octet-stream"
+	body := storage.ProgressReader(ctx, bytes.NewReader(data), int64(len(data)))
+	resp, err := c.doRequest(ctx, "PUT", c.fullURL(key), body, headers)
 	if err != nil {
 		return fmt.Errorf("failed to upload %s: %w", key, err)
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return fmt.Errorf("failed to upload %s: %w", key, storage.ErrPreconditionFailed)
+	}
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to upload %s: HTTP %d: %s", key, resp.StatusCode, string(body))
+		return fmt.Errorf("failed to upload %s: %w: %s", key, statusError(resp.StatusCode), string(body))
 	}
 
 	return nil
@@ -127,11 +160,11 @@ func (c *Client) Download(ctx context.Context, key string) ([]byte, error) {
 		return nil, fmt.Errorf("object not found: %s", key)
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to download %s: HTTP %d", key, resp.StatusCode)
+		return nil, fmt.Errorf("failed to download %s: %w", key, statusError(resp.StatusCode))
 	}
 
 	// Limit download size to prevent memory exhaustion
-	limited := io.LimitReader(resp.Body, storage.MaxDownloadSize+1)
+	limited := io.LimitReader(storage.ProgressReader(ctx, resp.Body, resp.ContentLength), storage.MaxDownloadSize+1)
 	data, err := io.ReadAll(limited)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read %s: %w", key, err)
@@ -152,7 +185,7 @@ func (c *Client) Delete(ctx context.Context, key string) error {
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
-		return fmt.Errorf("failed to delete %s: HTTP %d", key, resp.StatusCode)
+		return fmt.Errorf("failed to delete %s: %w", key, statusError(resp.StatusCode))
 	}
 
 	return nil
@@ -218,7 +251,7 @@ func (c *Client) List(ctx context.Context, prefix string) ([]storage.ObjectInfo,
 		// Server refuses Depth: infinity — walk the tree one level at a time.
 		return c.listRecursive(ctx, startURL)
 	default:
-		return nil, fmt.Errorf("failed to list objects: HTTP %d", status)
+		return nil, fmt.Errorf("failed to list objects: %w", statusError(status))
 	}
 }
 
@@ -256,7 +289,7 @@ func (c *Client) listRecursive(ctx context.Context, startURL string) ([]storage.
 			continue
 		}
 		if status != 207 {
-			return nil, fmt.Errorf("failed to list objects: HTTP %d", status)
+			return nil, fmt.Errorf("failed to list objects: %w", statusError(status))
 		}
 
 		for _, r := range responses {
@@ -365,11 +398,11 @@ func (c *Client) Head(ctx context.Context, key string) (*storage.ObjectInfo, err
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("object not found: %s", key)
+		return nil, fmt.Errorf("%w: %s", storage.ErrNotFound, key)
 	}
 
 	if resp.StatusCode != 207 {
-		return nil, fmt.Errorf("failed to head %s: HTTP %d", key, resp.StatusCode)
+		return nil, fmt.Errorf("failed to head %s: %w", key, statusError(resp.StatusCode))
 	}
 
 	// Limit response size to prevent memory exhaustion
@@ -400,6 +433,11 @@ func (c *Client) Head(ctx context.Context, key string) (*storage.ObjectInfo, err
 	}, nil
 }
 
+// HeadBatch returns metadata for many keys using a single List
+func (c *Client) HeadBatch(ctx context.Context, keys []string) (map[string]*storage.ObjectInfo, error) {
+	return storage.HeadBatchByList(ctx, c, keys)
+}
+
 // BucketExists checks if the configured path prefix exists and is accessible.
 // For WebDAV, the "bucket" concept maps to the path prefix directory.
 // Unlike S3/R2/GCS where buckets must be pre-created, WebDAV directories
@@ -423,7 +461,7 @@ func (c *Client) BucketExists(ctx context.Context) (bool, error) {
 		return true, nil
 	}
 	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
-		return false, fmt.Errorf("authentication failed (HTTP %d) - check your username and app password", resp.StatusCode)
+		return false, fmt.Errorf("authentication failed (%w) - check your username and app password", statusError(resp.StatusCode))
 	}
 
 	if resp.StatusCode == http.StatusNotFound && c.pathPrefix != "" {
@@ -2,6 +2,7 @@ package webdav
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"sort"
@@ -180,6 +181,40 @@ func TestUpload(t *testing.T) {
 	}
 }
 
+func TestUploadIfMatch(t *testing.T) {
+	etag := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "MKCOL" {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		if match := r.Header.Get("If-Match"); match != "" && match != etag ||
+			r.Header.Get("If-None-Match") == "*" && etag != "" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		etag = `"v2"`
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client()}
+	ctx := context.Background()
+
+	if err := client.UploadIfMatch(ctx, "names.age", []byte("a"), ""); err != nil {
+		t.Fatalf("UploadIfMatch(new) error = %v", err)
+	}
+	if err := client.UploadIfMatch(ctx, "names.age", []byte("b"), ""); !errors.Is(err, storage.ErrPreconditionFailed) {
+		t.Errorf("UploadIfMatch(new) over an object = %v, want ErrPreconditionFailed", err)
+	}
+	if err := client.UploadIfMatch(ctx, "names.age", []byte("b"), `"v1"`); !errors.Is(err, storage.ErrPreconditionFailed) {
+		t.Errorf("UploadIfMatch(stale) = %v, want ErrPreconditionFailed", err)
+	}
+	if err := client.UploadIfMatch(ctx, "names.age", []byte("b"), `"v2"`); err != nil {
+		t.Errorf("UploadIfMatch(current) error = %v", err)
+	}
+}
+
 func TestUploadError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "MKCOL" {
@@ -0,0 +1,119 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+)
+
+// activityRetention is how long entries stay in the activity log.
+const activityRetention = 60 * 24 * time.Hour
+
+// ActivityEntry records one push or pull for the activity log.
+type ActivityEntry struct {
+	Time       time.Time `json:"time"`
+	Command    string    `json:"command"` // "push" or "pull"
+	Uploaded   int       `json:"uploaded,omitempty"`
+	Downloaded int       `json:"downloaded,omitempty"`
+	Deleted    int       `json:"deleted,omitempty"`
+	Conflicts  int       `json:"conflicts,omitempty"`
+	BytesUp    int64     `json:"bytes_up,omitempty"`   // Sent to storage, encrypted
+	BytesDown  int64     `json:"bytes_down,omitempty"` // Received from storage
+
+	// Errors are failures, including files that couldn't be decrypted or
+	// whole runs that failed; Problems are files pulled with invalid content.
+	Errors   []string `json:"errors,omitempty"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// NewActivityEntry summarizes a push or pull result, and the error the run
+// ended with, if any.
+func NewActivityEntry(command string, result *SyncResult, runErr error) ActivityEntry {
+	entry := ActivityEntry{Time: time.Now(), Command: command}
+	if runErr != nil {
+		entry.Errors = append(entry.Errors, runErr.Error())
+	}
+	if result == nil {
+		return entry
+	}
+	entry.Uploaded = len(result.Uploaded)
+	entry.Downloaded = len(result.Downloaded)
+	entry.Deleted = len(result.Deleted)
+	entry.Conflicts = len(result.Conflicts)
+	entry.BytesUp = result.Requests.BytesUp
+	entry.BytesDown = result.Requests.BytesDown
+	for _, err := range result.Errors {
+		entry.Errors = append(entry.Errors, err.Error())
+	}
+	for _, issue := range result.InvalidJSONL {
+		entry.Problems = append(entry.Problems, fmt.Sprintf("%s: %d invalid JSONL line(s)", issue.Path, len(issue.BadLines)))
+	}
+	for _, issue := range result.InvalidSettings {
+		entry.Problems = append(entry.Problems, fmt.Sprintf("%s: %v", issue.Path, issue.Err))
+	}
+	return entry
+}
+
+// RecordActivity appends an entry to the activity log, dropping entries
+// older than activityRetention.
+func RecordActivity(entry ActivityEntry) error {
+	return recordActivityAt(config.ActivityFilePath(), entry)
+}
+
+func recordActivityAt(path string, entry ActivityEntry) error {
+	entries, err := loadActivityFrom(path)
+	if err != nil {
+		return err
+	}
+
+	cutoff := entry.Time.Add(-activityRetention)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range append(entries, entry) {
+		if e.Time.Before(cutoff) {
+			continue
+		}
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	return os.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// LoadActivity reads the activity log, oldest entry first.
+func LoadActivity() ([]ActivityEntry, error) {
+	return loadActivityFrom(config.ActivityFilePath())
+}
+
+func loadActivityFrom(path string) ([]ActivityEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read activity log: %w", err)
+	}
+	defer f.Close()
+
+	var entries []ActivityEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var e ActivityEntry
+		// A local record only: skip lines that don't parse
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, scanner.Err()
+}
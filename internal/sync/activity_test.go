@@ -0,0 +1,64 @@
+package sync
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+func TestRecordActivity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "activity.jsonl")
+	now := time.Now()
+
+	old := ActivityEntry{Time: now.Add(-activityRetention - time.Hour), Command: "push", Uploaded: 1}
+	if err := recordActivityAt(path, old); err != nil {
+		t.Fatalf("recordActivityAt failed: %v", err)
+	}
+	recent := ActivityEntry{Time: now, Command: "pull", Downloaded: 2, Errors: []string{"boom"}}
+	if err := recordActivityAt(path, recent); err != nil {
+		t.Fatalf("recordActivityAt failed: %v", err)
+	}
+
+	entries, err := loadActivityFrom(path)
+	if err != nil {
+		t.Fatalf("loadActivityFrom failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected the old entry to be pruned, got %+v", entries)
+	}
+	if entries[0].Command != "pull" || entries[0].Downloaded != 2 || len(entries[0].Errors) != 1 {
+		t.Errorf("Unexpected entry: %+v", entries[0])
+	}
+}
+
+func TestLoadActivityMissingFile(t *testing.T) {
+	entries, err := loadActivityFrom(filepath.Join(t.TempDir(), "activity.jsonl"))
+	if err != nil || entries != nil {
+		t.Errorf("Expected no entries and no error, got %v, %v", entries, err)
+	}
+}
+
+func TestNewActivityEntry(t *testing.T) {
+	result := &SyncResult{
+		Downloaded:      []string{"a.md", "b.md"},
+		Conflicts:       []string{"c.md"},
+		Errors:          []error{errors.New("decrypt failed")},
+		InvalidSettings: []SettingsIssue{{Path: "settings.json", Err: errors.New("not a JSON object")}},
+		Requests:        storage.RequestStats{Get: 2, BytesDown: 2048},
+	}
+	entry := NewActivityEntry("pull", result, nil)
+	if entry.Downloaded != 2 || entry.Conflicts != 1 || entry.BytesDown != 2048 {
+		t.Errorf("Unexpected counts: %+v", entry)
+	}
+	if len(entry.Errors) != 1 || len(entry.Problems) != 1 {
+		t.Errorf("Expected one error and one problem, got %+v", entry)
+	}
+
+	failed := NewActivityEntry("push", nil, errors.New("storage unreachable"))
+	if len(failed.Errors) != 1 || failed.Errors[0] != "storage unreachable" {
+		t.Errorf("Expected the run error to be recorded, got %+v", failed)
+	}
+}
@@ -0,0 +1,276 @@
+package sync
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+)
+
+// Archive entry names. Synced files live under archiveFilesDir; the header
+// comes first so an archive can be identified without reading it all.
+const (
+	archiveHeaderName = "claude-sync/archive.json"
+	archiveStateName  = "claude-sync/state.json"
+	archiveFilesDir   = "claude/"
+
+	archiveFormatVersion = 1
+)
+
+// ArchiveInfo is the header of an export archive.
+type ArchiveInfo struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	DeviceID  string    `json:"device_id"`
+	Files     int       `json:"files"`
+}
+
+// ImportOptions controls Import.
+type ImportOptions struct {
+	// DryRun reports what would change without writing anything.
+	DryRun bool
+
+	// RestoreState replaces the sync state with the archived one. Leave it
+	// off when moving to a new storage provider, so the next push uploads
+	// every imported file.
+	RestoreState bool
+}
+
+// ImportResult reports what Import did (or would do, on a dry run).
+type ImportResult struct {
+	Info      ArchiveInfo
+	Created   []string
+	Modified  []string
+	Unchanged int
+
+	StateRestored bool
+}
+
+// Export writes every synced file and the sync state to w as a single
+// encrypted archive: a gzipped tar, age-encrypted with the sync key. It is
+// streamed, so large ~/.claude trees never sit in memory.
+func (s *Syncer) Export(w io.Writer) (*ArchiveInfo, error) {
+	files, err := GetLocalFiles(s.claudeDir, s.syncPaths(), s.isExcluded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	info := &ArchiveInfo{
+		Version:   archiveFormatVersion,
+		CreatedAt: time.Now().UTC(),
+		DeviceID:  s.state.DeviceID,
+		Files:     len(paths),
+	}
+
+	ew, err := crypto.EncryptWriter(s.encryptor, w)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(ew)
+	tw := tar.NewWriter(gz)
+
+	header, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeTarEntry(tw, archiveHeaderName, header, info.CreatedAt); err != nil {
+		return nil, err
+	}
+	s.state.mu.Lock()
+	state, err := json.Marshal(s.state)
+	s.state.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize state: %w", err)
+	}
+	if err := writeTarEntry(tw, archiveStateName, state, info.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		if err := addTarFile(tw, archiveFilesDir+path, filepath.Join(s.claudeDir, path)); err != nil {
+			return nil, fmt.Errorf("failed to archive %s: %w", path, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := ew.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize encryption: %w", err)
+	}
+	return info, nil
+}
+
+// Import restores files (and optionally state) from an archive written by
+// Export. Files are only ever written, never deleted; local files that aren't
+// in the archive are left alone.
+func (s *Syncer) Import(r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	dr, err := crypto.DecryptReader(s.encryptor, r)
+	if err != nil {
+		return nil, fmt.Errorf("not a claude-sync archive for this key: %w", err)
+	}
+	gz, err := gzip.NewReader(dr)
+	if err != nil {
+		return nil, fmt.Errorf("archive is corrupt: %w", err)
+	}
+	defer func() { _ = gz.Close() }()
+	tr := tar.NewReader(gz)
+
+	result := &ImportResult{}
+	var sawHeader bool
+	var state []byte
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("archive is corrupt: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(tr, maxDecompressedSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("archive is corrupt: %w", err)
+		}
+		if len(data) > maxDecompressedSize {
+			return nil, fmt.Errorf("archive entry %s exceeds maximum size", hdr.Name)
+		}
+
+		switch {
+		case hdr.Name == archiveHeaderName:
+			if err := json.Unmarshal(data, &result.Info); err != nil {
+				return nil, fmt.Errorf("archive header is corrupt: %w", err)
+			}
+			if result.Info.Version > archiveFormatVersion {
+				return nil, fmt.Errorf("archive format %d is newer than this claude-sync supports; update first", result.Info.Version)
+			}
+			sawHeader = true
+		case hdr.Name == archiveStateName:
+			state = data
+		case strings.HasPrefix(hdr.Name, archiveFilesDir):
+			if !sawHeader {
+				return nil, fmt.Errorf("not a claude-sync archive (missing header)")
+			}
+			if err := s.importFile(strings.TrimPrefix(hdr.Name, archiveFilesDir), data, hdr.ModTime, opts.DryRun, result); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !sawHeader {
+		return nil, fmt.Errorf("not a claude-sync archive (missing header)")
+	}
+
+	if opts.RestoreState && state != nil && !opts.DryRun {
+		if err := s.restoreState(state); err != nil {
+			return nil, err
+		}
+		result.StateRestored = true
+	}
+	return result, nil
+}
+
+// importFile writes one archived file, keeping its modification time.
+func (s *Syncer) importFile(relativePath string, data []byte, modTime time.Time, dryRun bool, result *ImportResult) error {
+	if !filepath.IsLocal(filepath.FromSlash(relativePath)) {
+		return fmt.Errorf("archive entry %q points outside %s", relativePath, s.claudeDir)
+	}
+	localHash, _, exists := s.localFileInfo(relativePath)
+	switch {
+	case exists && localHash == s.state.hashBytes(data):
+		result.Unchanged++
+		return nil
+	case exists:
+		result.Modified = append(result.Modified, relativePath)
+	default:
+		result.Created = append(result.Created, relativePath)
+	}
+	if dryRun {
+		return nil
+	}
+
+	if err := s.writeClaudeFile(filepath.FromSlash(relativePath), data); err != nil {
+		return fmt.Errorf("failed to import %s: %w", relativePath, err)
+	}
+	fullPath := filepath.Join(s.claudeDir, relativePath)
+	if err := os.Chtimes(fullPath, modTime, modTime); err != nil {
+		s.log("Warning: failed to restore mtime for %s: %v", relativePath, err)
+	}
+	return nil
+}
+
+// restoreState replaces the tracked files and sync times, and the algorithm
+// their hashes were made with, with archived ones.
+// The device ID stays this device's own.
+func (s *Syncer) restoreState(data []byte) error {
+	var archived SyncState
+	if err := json.Unmarshal(data, &archived); err != nil {
+		return fmt.Errorf("archived state is corrupt: %w", err)
+	}
+	if archived.Files == nil {
+		archived.Files = make(map[string]*FileState)
+	}
+
+	s.state.mu.Lock()
+	s.state.Files = archived.Files
+	s.state.LastSync = archived.LastSync
+	s.state.LastPush = archived.LastPush
+	s.state.LastPull = archived.LastPull
+	s.state.MCPBaseline = archived.MCPBaseline
+	s.state.Conflicts = archived.Conflicts
+	s.state.HashAlgorithm = archived.HashAlgorithm
+	s.state.mu.Unlock()
+
+	return s.state.Save()
+}
+
+func writeTarEntry(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	hdr := &tar.Header{Name: name, Mode: 0600, Size: int64(len(data)), ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if _, err := io.Copy(tw, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+func addTarFile(tw *tar.Writer, name, fullPath string) error {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	// The file may have changed since it was listed: archive what is there now
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: name, Mode: 0600, Size: stat.Size(), ModTime: stat.ModTime(), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, f, stat.Size())
+	return err
+}
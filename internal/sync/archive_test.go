@@ -0,0 +1,177 @@
+package sync
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/crypto"
+)
+
+func TestExportImport(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# Rules")
+	writeFile(t, env.claudeDir, "agents/a.md", "agent")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	var archive bytes.Buffer
+	info, err := env.syncer.Export(&archive)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if info.Files != 2 {
+		t.Errorf("Expected 2 files, got %d", info.Files)
+	}
+	if bytes.Contains(archive.Bytes(), []byte("# Rules")) {
+		t.Error("Archive is not encrypted")
+	}
+
+	// A fresh device with the same key and an unrelated local file
+	dst := setupTestEnv(t)
+	dst.syncer.encryptor = env.syncer.encryptor
+	writeFile(t, dst.claudeDir, "CLAUDE.md", "# Other")
+	writeFile(t, dst.claudeDir, "agents/local.md", "keep me")
+
+	preview, err := dst.syncer.Import(bytes.NewReader(archive.Bytes()), ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Import dry run failed: %v", err)
+	}
+	if len(preview.Created) != 1 || len(preview.Modified) != 1 {
+		t.Fatalf("Expected 1 new and 1 overwritten, got %+v", preview)
+	}
+	if got := readFile(t, dst.claudeDir, "CLAUDE.md"); got != "# Other" {
+		t.Errorf("Dry run wrote files: %q", got)
+	}
+
+	result, err := dst.syncer.Import(bytes.NewReader(archive.Bytes()), ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if result.StateRestored {
+		t.Error("State restored without RestoreState")
+	}
+	if got := readFile(t, dst.claudeDir, "CLAUDE.md"); got != "# Rules" {
+		t.Errorf("Expected imported content, got %q", got)
+	}
+	if got := readFile(t, dst.claudeDir, "agents/local.md"); got != "keep me" {
+		t.Errorf("Expected local-only file untouched, got %q", got)
+	}
+
+	// Without state, every imported file is pending push
+	changes, err := dst.syncer.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Errorf("Expected 3 pending changes, got %+v", changes)
+	}
+
+	// With state, the imported files count as synced, hashed the archive's way
+	dst.syncer.state.HashAlgorithm = config.HashBLAKE3
+	result, err = dst.syncer.Import(bytes.NewReader(archive.Bytes()), ImportOptions{RestoreState: true})
+	if err != nil {
+		t.Fatalf("Import with state failed: %v", err)
+	}
+	if !result.StateRestored || result.Unchanged != 2 {
+		t.Errorf("Expected state restored and files unchanged, got %+v", result)
+	}
+	if f := dst.syncer.state.GetFile("agents/a.md"); f == nil {
+		t.Error("Expected archived state to track agents/a.md")
+	}
+	if dst.syncer.state.HashAlgorithm != env.syncer.state.HashAlgorithm {
+		t.Errorf("Expected hash algorithm %q restored, got %q", env.syncer.state.HashAlgorithm, dst.syncer.state.HashAlgorithm)
+	}
+}
+
+func TestImportRejectsBadArchives(t *testing.T) {
+	env := setupTestEnv(t)
+
+	seal := func(entries map[string]string, order ...string) []byte {
+		var plain bytes.Buffer
+		gz := gzip.NewWriter(&plain)
+		tw := tar.NewWriter(gz)
+		for _, name := range order {
+			if err := writeTarEntry(tw, name, []byte(entries[name]), env.syncer.state.LastSync); err != nil {
+				t.Fatal(err)
+			}
+		}
+		_ = tw.Close()
+		_ = gz.Close()
+		var sealed bytes.Buffer
+		w, err := crypto.EncryptWriter(env.syncer.encryptor, &sealed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write(plain.Bytes())
+		_ = w.Close()
+		return sealed.Bytes()
+	}
+
+	header := `{"version":1,"files":1}`
+	tests := map[string][]byte{
+		"missing header": seal(map[string]string{"claude/CLAUDE.md": "x"}, "claude/CLAUDE.md"),
+		"path traversal": seal(map[string]string{archiveHeaderName: header, "claude/../evil": "x"}, archiveHeaderName, "claude/../evil"),
+		"newer format":   seal(map[string]string{archiveHeaderName: `{"version":99}`}, archiveHeaderName),
+		"not encrypted":  []byte("plain text"),
+	}
+	for name, archive := range tests {
+		if _, err := env.syncer.Import(bytes.NewReader(archive), ImportOptions{DryRun: true}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		} else if name == "path traversal" && !strings.Contains(err.Error(), "outside") {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+	}
+}
+
+// reverseCipher is a toy non-age scheme, to check the Syncer only needs a
+// crypto.Cipher.
+type reverseCipher struct{}
+
+func (reverseCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	out := make([]byte, len(plaintext))
+	for i, b := range plaintext {
+		out[len(out)-1-i] = b
+	}
+	return out, nil
+}
+
+func (c reverseCipher) Decrypt(ciphertext []byte) ([]byte, error) { return c.Encrypt(ciphertext) }
+
+func (reverseCipher) KeyID() string { return "reverse" }
+
+func TestOtherEncryptionScheme(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.encryptor = reverseCipher{}
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# Rules")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	var archive bytes.Buffer
+	if _, err := env.syncer.Export(&archive); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "changed")
+	if _, err := env.syncer.Import(&archive, ImportOptions{}); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if got := readFile(t, env.claudeDir, "CLAUDE.md"); got != "# Rules" {
+		t.Errorf("CLAUDE.md = %q after import", got)
+	}
+
+	env.syncer.cfg.SignManifest = true
+	if err := env.syncer.uploadManifest(ctx, nil); err == nil || !strings.Contains(err.Error(), "can't sign") {
+		t.Errorf("signed manifest with a scheme that can't sign: err = %v", err)
+	}
+}
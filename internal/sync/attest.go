@@ -0,0 +1,251 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// AttestationPrefix holds, with 'attestations: true', a signed summary of the
+// bucket written after each push. Each names the one before it, so together
+// they form a chain 'claude-sync verify' checks. They are stored unencrypted:
+// they hold only counts and hashes.
+const AttestationPrefix = "_attestations/"
+
+// Attestation is a signed summary of the bucket right after a push.
+type Attestation struct {
+	Seq          int       `json:"seq"`
+	Previous     string    `json:"previous,omitempty"` // SHA-256 of the previous attestation object
+	Device       string    `json:"device"`
+	CreatedAt    time.Time `json:"created_at"`
+	Objects      int       `json:"objects"`
+	Bytes        int64     `json:"bytes"`
+	ManifestHash string    `json:"manifest_hash,omitempty"` // SHA-256 of the stored manifest object
+	SignerKey    string    `json:"signer_key"`
+	Signature    string    `json:"signature,omitempty"`
+
+	Key string `json:"-"`
+}
+
+// AttestationReport is the outcome of checking the attestation chain.
+type AttestationReport struct {
+	Chain     []Attestation
+	SignerKey string // This device's signing key
+
+	// Problems are signs of tampering: bad or unknown signatures, and
+	// missing or altered links. Drift lists differences between the bucket
+	// now and the latest attestation, which a push from a device without
+	// attestations, gc or 'trash empty' also cause.
+	Problems []string
+	Drift    []string
+}
+
+// Latest returns the newest attestation, or nil for an empty chain.
+func (r *AttestationReport) Latest() *Attestation {
+	if len(r.Chain) == 0 {
+		return nil
+	}
+	return &r.Chain[len(r.Chain)-1]
+}
+
+func attestationKey(seq int, deviceID string) string {
+	return fmt.Sprintf("%s%08d-%s.json", AttestationPrefix, seq, safeDeviceName(deviceID))
+}
+
+// parseAttestationSeq extracts the sequence number from an attestation key.
+func parseAttestationSeq(key string) (int, bool) {
+	name, ok := strings.CutPrefix(key, AttestationPrefix)
+	if !ok || !strings.HasSuffix(name, ".json") {
+		return 0, false
+	}
+	seq, _, _ := strings.Cut(name, "-")
+	n, err := strconv.Atoi(seq)
+	return n, err == nil
+}
+
+// payload is what the signature covers: the attestation without it.
+func (a Attestation) payload() ([]byte, error) {
+	a.Signature = ""
+	return json.Marshal(a)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// summarizeBucket counts the objects an attestation covers: everything but
+// attestations themselves and short-lived push leases.
+func summarizeBucket(objects []storage.ObjectInfo) (count int, size int64) {
+	for _, obj := range objects {
+		if strings.HasPrefix(obj.Key, AttestationPrefix) || strings.HasPrefix(obj.Key, LeasePrefix) {
+			continue
+		}
+		count++
+		size += obj.Size
+	}
+	return count, size
+}
+
+// attest signs a summary of the bucket as it is now and appends it to the
+// chain.
+func (s *Syncer) attest(ctx context.Context) (*Attestation, error) {
+	objects, err := s.storage.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote files: %w", err)
+	}
+
+	a := &Attestation{Seq: 1, Device: s.state.DeviceID, CreatedAt: time.Now().UTC()}
+	var latest string
+	latestSeq := 0
+	for _, obj := range objects {
+		if seq, ok := parseAttestationSeq(obj.Key); ok && (seq > latestSeq || seq == latestSeq && obj.Key > latest) {
+			latest, latestSeq = obj.Key, seq
+		}
+	}
+	if latest != "" {
+		prev, err := s.storage.Download(ctx, latest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download %s: %w", latest, err)
+		}
+		a.Seq, a.Previous = latestSeq+1, sha256Hex(prev)
+	}
+
+	a.Objects, a.Bytes = summarizeBucket(objects)
+	if manifest, err := s.storage.Download(ctx, ManifestKey+".age"); err == nil {
+		a.ManifestHash = sha256Hex(manifest)
+	}
+	a.SignerKey = s.signingPublicKey()
+	if err := s.signAttestation(a); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	a.Key = attestationKey(a.Seq, a.Device)
+	if err := s.storage.Upload(ctx, a.Key, data); err != nil {
+		return nil, fmt.Errorf("failed to upload attestation: %w", err)
+	}
+	return a, nil
+}
+
+func (s *Syncer) signAttestation(a *Attestation) error {
+	payload, err := a.payload()
+	if err != nil {
+		return err
+	}
+	sig, err := s.sign(payload)
+	if err != nil {
+		return err
+	}
+	a.Signature = base64.StdEncoding.EncodeToString(sig)
+	return nil
+}
+
+// attestAfterPush attests when 'attestations: true' is set. Failure only
+// warns: the push itself succeeded.
+func (s *Syncer) attestAfterPush(ctx context.Context) {
+	if !s.cfg.Attestations {
+		return
+	}
+	if _, err := s.attest(ctx); err != nil {
+		s.log("Warning: failed to attest: %v", err)
+	}
+}
+
+// VerifyAttestations checks every attestation's signature and link to the
+// one before, then compares the bucket with the latest. Signatures count only
+// from this device's signing key or one listed in attestation_signers.
+func (s *Syncer) VerifyAttestations(ctx context.Context) (*AttestationReport, error) {
+	objects, err := s.storage.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote files: %w", err)
+	}
+
+	report := &AttestationReport{SignerKey: s.signingPublicKey()}
+	trusted := s.trustedSigners()
+
+	var keys []string
+	for _, obj := range objects {
+		if _, ok := parseAttestationSeq(obj.Key); ok {
+			keys = append(keys, obj.Key)
+		}
+	}
+	sort.Strings(keys)
+
+	hashes := make(map[int][]string) // seq → hashes of the objects at it
+	for _, key := range keys {
+		data, err := s.storage.Download(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download %s: %w", key, err)
+		}
+		var a Attestation
+		if err := json.Unmarshal(data, &a); err != nil {
+			report.Problems = append(report.Problems, fmt.Sprintf("%s: unreadable: %v", key, err))
+			continue
+		}
+		a.Key = key
+		if seq, _ := parseAttestationSeq(key); seq != a.Seq {
+			report.Problems = append(report.Problems, fmt.Sprintf("%s: records sequence %d", key, a.Seq))
+		}
+		report.Problems = append(report.Problems, checkAttestationSignature(a, trusted)...)
+
+		switch {
+		case a.Seq == 1 && a.Previous != "":
+			report.Problems = append(report.Problems, fmt.Sprintf("%s: first attestation names a predecessor", key))
+		case a.Seq > 1 && len(hashes[a.Seq-1]) == 0:
+			report.Problems = append(report.Problems, fmt.Sprintf("%s: attestation %d is missing", key, a.Seq-1))
+		case a.Seq > 1 && !slices.Contains(hashes[a.Seq-1], a.Previous):
+			report.Problems = append(report.Problems, fmt.Sprintf("%s: attestation %d was altered or replaced", key, a.Seq-1))
+		}
+		if len(hashes[a.Seq]) > 0 {
+			report.Problems = append(report.Problems, fmt.Sprintf("%s: more than one attestation %d (concurrent pushes?)", key, a.Seq))
+		}
+		hashes[a.Seq] = append(hashes[a.Seq], sha256Hex(data))
+		report.Chain = append(report.Chain, a)
+	}
+	if len(report.Chain) > 0 && report.Chain[0].Seq != 1 {
+		report.Problems = append(report.Problems, fmt.Sprintf("the chain starts at attestation %d; earlier ones are missing", report.Chain[0].Seq))
+	}
+
+	if latest := report.Latest(); latest != nil {
+		count, size := summarizeBucket(objects)
+		if count != latest.Objects || size != latest.Bytes {
+			report.Drift = append(report.Drift, fmt.Sprintf("the bucket holds %d objects (%d bytes); %d (%d bytes) were attested",
+				count, size, latest.Objects, latest.Bytes))
+		}
+		manifestHash := ""
+		if manifest, err := s.storage.Download(ctx, ManifestKey+".age"); err == nil {
+			manifestHash = sha256Hex(manifest)
+		}
+		if manifestHash != latest.ManifestHash {
+			report.Drift = append(report.Drift, "the manifest changed since it was attested")
+		}
+	}
+	return report, nil
+}
+
+func checkAttestationSignature(a Attestation, trusted map[string]bool) []string {
+	sig, err := base64.StdEncoding.DecodeString(a.Signature)
+	payload, perr := a.payload()
+	switch {
+	case err != nil || perr != nil || !crypto.VerifySignature(a.SignerKey, payload, sig):
+		return []string{fmt.Sprintf("%s: invalid signature", a.Key)}
+	case !trusted[a.SignerKey]:
+		return []string{fmt.Sprintf("%s: signed by an untrusted key %s", a.Key, a.SignerKey)}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+)
+
+func pushAttested(t *testing.T, env *testEnv, path, content string) {
+	t.Helper()
+	writeFile(t, env.claudeDir, path, content)
+	if _, err := env.syncer.Push(context.Background()); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+}
+
+func verifyAttestations(t *testing.T, env *testEnv) *AttestationReport {
+	t.Helper()
+	report, err := env.syncer.VerifyAttestations(context.Background())
+	if err != nil {
+		t.Fatalf("VerifyAttestations failed: %v", err)
+	}
+	return report
+}
+
+func TestAttestationChain(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Attestations = true
+
+	pushAttested(t, env, "CLAUDE.md", "v1")
+	pushAttested(t, env, "CLAUDE.md", "v2")
+
+	report := verifyAttestations(t, env)
+	if len(report.Chain) != 2 || len(report.Problems) != 0 || len(report.Drift) != 0 {
+		t.Fatalf("Expected a clean chain of two, got %+v", report)
+	}
+	latest := report.Latest()
+	if latest.Seq != 2 || latest.Previous == "" || latest.ManifestHash == "" || latest.Objects == 0 {
+		t.Errorf("Unexpected latest attestation %+v", latest)
+	}
+
+	// An unchanged push attests nothing
+	if _, err := env.syncer.Push(context.Background()); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if report := verifyAttestations(t, env); len(report.Chain) != 2 {
+		t.Errorf("Expected no attestation for an empty push, got %d", len(report.Chain))
+	}
+}
+
+func TestAttestationTampering(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Attestations = true
+	pushAttested(t, env, "CLAUDE.md", "v1")
+	pushAttested(t, env, "CLAUDE.md", "v2")
+
+	first := attestationKey(1, env.syncer.state.DeviceID)
+	var a Attestation
+	if err := json.Unmarshal(env.store.objects[first].data, &a); err != nil {
+		t.Fatal(err)
+	}
+	a.Objects += 5
+	data, _ := json.Marshal(a)
+	obj := env.store.objects[first]
+	obj.data = data
+	env.store.objects[first] = obj
+
+	report := verifyAttestations(t, env)
+	if !hasProblem(report, "invalid signature") || !hasProblem(report, "altered or replaced") {
+		t.Errorf("Expected the edit caught, got %v", report.Problems)
+	}
+
+	delete(env.store.objects, first)
+	report = verifyAttestations(t, env)
+	if !hasProblem(report, "earlier ones are missing") {
+		t.Errorf("Expected the missing start caught, got %v", report.Problems)
+	}
+}
+
+func TestAttestationDrift(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Attestations = true
+	pushAttested(t, env, "CLAUDE.md", "v1")
+
+	// A push without attestations changes the bucket behind the chain's back
+	env.syncer.cfg.Attestations = false
+	pushAttested(t, env, "agents/a.md", "a")
+
+	report := verifyAttestations(t, env)
+	if len(report.Problems) != 0 {
+		t.Errorf("Expected no tampering, got %v", report.Problems)
+	}
+	if len(report.Drift) != 2 {
+		t.Errorf("Expected object count and manifest drift, got %v", report.Drift)
+	}
+}
+
+func TestAttestationUntrustedSigner(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Attestations = true
+	pushAttested(t, env, "CLAUDE.md", "v1")
+
+	keyPath := filepath.Join(t.TempDir(), "other.txt")
+	if err := crypto.GenerateKey(keyPath); err != nil {
+		t.Fatal(err)
+	}
+	otherEnc, err := crypto.NewEncryptor(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := sharedBucketEnv(t, env)
+	other.syncer.encryptor = otherEnc
+	other.syncer.cfg.Recipients = []string{ageCipher(env.syncer.encryptor).PublicKey()}
+	other.syncer.cfg.Attestations = true
+	pushAttested(t, other, "agents/b.md", "b")
+
+	report := verifyAttestations(t, env)
+	if !hasProblem(report, "untrusted key") {
+		t.Errorf("Expected the other identity's attestation flagged, got %v", report.Problems)
+	}
+
+	env.syncer.cfg.AttestationSigners = []string{otherEnc.SigningPublicKey()}
+	if report := verifyAttestations(t, env); len(report.Problems) != 0 {
+		t.Errorf("Expected a listed signer trusted, got %v", report.Problems)
+	}
+}
+
+func hasProblem(report *AttestationReport, substr string) bool {
+	for _, p := range report.Problems {
+		if strings.Contains(p, substr) {
+			return true
+		}
+	}
+	return false
+}
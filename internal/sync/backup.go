@@ -0,0 +1,172 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BackupTimeLayout is the timestamp format in backup directory names.
+const BackupTimeLayout = "20060102-150405"
+
+// autoBackupTag marks backups pull made on its own (pull_backups), which are
+// pruned automatically; manual backups are only deleted by Prune.
+const autoBackupTag = "auto."
+
+// Backup is a copy of local files in a sibling directory of ~/.claude.
+type Backup struct {
+	Path      string
+	Name      string // Directory name, e.g. .claude.backup.20250101-120000
+	CreatedAt time.Time
+	Automatic bool // Made by pull (pull_backups) rather than on request
+}
+
+// CreateBackup copies every syncable file under claudeDir into a sibling
+// directory named <claudeDir>.backup.<timestamp> and returns its path.
+// ~/.claude can contain API keys, prompts, and personal context, so the backup
+// is created user-only (0700 directories, 0600 files).
+func CreateBackup(claudeDir string, syncPaths []string) (string, error) {
+	files, err := GetLocalFiles(claudeDir, syncPaths)
+	if err != nil {
+		return "", fmt.Errorf("failed to list files: %w", err)
+	}
+	relPaths := make([]string, 0, len(files))
+	for relPath := range files {
+		relPaths = append(relPaths, relPath)
+	}
+	backupDir := claudeDir + ".backup." + time.Now().Format(BackupTimeLayout)
+	return backupDir, copyToBackup(claudeDir, backupDir, relPaths)
+}
+
+// createAutoBackup backs up relPaths before pull overwrites them, into
+// <claudeDir>.backup.auto.<timestamp>.
+func createAutoBackup(claudeDir string, relPaths []string) (string, error) {
+	backupDir := claudeDir + ".backup." + autoBackupTag + time.Now().Format(BackupTimeLayout)
+	return backupDir, copyToBackup(claudeDir, backupDir, relPaths)
+}
+
+// copyToBackup copies relPaths from claudeDir into backupDir.
+func copyToBackup(claudeDir, backupDir string, relPaths []string) error {
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	for _, relPath := range relPaths {
+		srcPath := filepath.Join(claudeDir, relPath)
+		dstPath := filepath.Join(backupDir, relPath)
+
+		// Ensure destination directory exists
+		dstDir := filepath.Dir(dstPath)
+		if err := os.MkdirAll(dstDir, 0700); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dstDir, err)
+		}
+
+		// Copy file
+		data, err := os.ReadFile(srcPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", relPath, err)
+		}
+
+		if err := os.WriteFile(dstPath, data, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", relPath, err)
+		}
+	}
+
+	return nil
+}
+
+// CreateBackup backs up the syncable files in this syncer's Claude directory.
+func (s *Syncer) CreateBackup() (string, error) {
+	return CreateBackup(s.claudeDir, s.syncPaths())
+}
+
+// ListBackups returns the manual and automatic backups of claudeDir, newest
+// first.
+func ListBackups(claudeDir string) ([]Backup, error) {
+	entries, err := os.ReadDir(filepath.Dir(claudeDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	prefix := filepath.Base(claudeDir) + ".backup."
+	var backups []Backup
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || !entry.IsDir() {
+			continue
+		}
+		stamp, auto := strings.CutPrefix(stamp, autoBackupTag)
+		createdAt, err := time.ParseInLocation(BackupTimeLayout, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{
+			Path:      filepath.Join(filepath.Dir(claudeDir), entry.Name()),
+			Name:      entry.Name(),
+			CreatedAt: createdAt,
+			Automatic: auto,
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// PruneBackups deletes all but the newest keep automatic backups of
+// claudeDir and returns the paths it removed. Manual backups are kept.
+func PruneBackups(claudeDir string, keep int) ([]string, error) {
+	backups, err := ListBackups(claudeDir)
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	kept := 0
+	for _, b := range backups {
+		if !b.Automatic {
+			continue
+		}
+		if kept < keep {
+			kept++
+			continue
+		}
+		if err := os.RemoveAll(b.Path); err != nil {
+			return removed, fmt.Errorf("failed to remove backup %s: %w", b.Name, err)
+		}
+		removed = append(removed, b.Path)
+	}
+	return removed, nil
+}
+
+// RestoreBackup copies every file in a backup back into claudeDir,
+// overwriting the local copies, and returns the relative paths it restored.
+// Files that aren't in the backup are left alone. Restored files differ from
+// what was last synced, so the next push uploads them.
+func RestoreBackup(claudeDir string, b Backup) ([]string, error) {
+	var restored []string
+	err := filepath.WalkDir(b.Path, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(b.Path, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", relPath, err)
+		}
+		dstPath := filepath.Join(claudeDir, relPath)
+		if err := os.MkdirAll(filepath.Dir(dstPath), 0700); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", relPath, err)
+		}
+		if err := os.WriteFile(dstPath, data, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", relPath, err)
+		}
+		restored = append(restored, filepath.ToSlash(relPath))
+		return nil
+	})
+	sort.Strings(restored)
+	return restored, err
+}
@@ -0,0 +1,168 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateBackupCopiesSyncableFiles(t *testing.T) {
+	env := setupTestEnv(t)
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# Settings")
+	writeFile(t, env.claudeDir, "agents/helper.md", "helper")
+	writeFile(t, env.claudeDir, "cache/ignored.bin", "not synced")
+
+	backupDir, err := env.syncer.CreateBackup()
+	if err != nil {
+		t.Fatalf("CreateBackup failed: %v", err)
+	}
+	if !strings.HasPrefix(filepath.Base(backupDir), ".claude.backup.") {
+		t.Errorf("Unexpected backup dir name: %s", backupDir)
+	}
+
+	if got := readFile(t, backupDir, "CLAUDE.md"); got != "# Settings" {
+		t.Errorf("CLAUDE.md backup = %q", got)
+	}
+	if got := readFile(t, backupDir, "agents/helper.md"); got != "helper" {
+		t.Errorf("agents/helper.md backup = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(backupDir, "cache")); !os.IsNotExist(err) {
+		t.Error("Non-synced paths should not be backed up")
+	}
+}
+
+func TestCreateBackupPermissions(t *testing.T) {
+	env := setupTestEnv(t)
+	writeFile(t, env.claudeDir, "agents/helper.json", `{"name":"helper"}`)
+
+	backupDir, err := CreateBackup(env.claudeDir, []string{"agents"})
+	if err != nil {
+		t.Fatalf("CreateBackup failed: %v", err)
+	}
+
+	for path, want := range map[string]os.FileMode{
+		backupDir:                          0700,
+		filepath.Join(backupDir, "agents"): 0700,
+		filepath.Join(backupDir, "agents", "helper.json"): 0600,
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Stat %s failed: %v", path, err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s: mode %o, want %o", path, got, want)
+		}
+	}
+}
+
+func TestPullMakesAutomaticBackup(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.PullBackups = 2
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "local")
+	writeFile(t, env.claudeDir, "agents/helper.md", "unchanged")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	uploadRemote(t, env, "CLAUDE.md", "remote")
+
+	result, err := env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if !strings.Contains(filepath.Base(result.Backup), ".backup.auto.") {
+		t.Fatalf("Expected an automatic backup, got %q", result.Backup)
+	}
+	if got := readFile(t, result.Backup, "CLAUDE.md"); got != "local" {
+		t.Errorf("Backup holds %q, want the replaced local content", got)
+	}
+	if _, err := os.Stat(filepath.Join(result.Backup, "agents")); !os.IsNotExist(err) {
+		t.Error("Only files the pull replaced should be backed up")
+	}
+
+	backups, err := ListBackups(env.claudeDir)
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	if len(backups) != 1 || !backups[0].Automatic || backups[0].Path != result.Backup {
+		t.Errorf("ListBackups = %+v", backups)
+	}
+}
+
+func TestPruneBackupsKeepsManualBackups(t *testing.T) {
+	claudeDir := filepath.Join(t.TempDir(), ".claude")
+	names := []string{
+		".claude.backup.20260101-100000",
+		".claude.backup.auto.20260102-100000",
+		".claude.backup.auto.20260103-100000",
+		".claude.backup.auto.20260104-100000",
+		".claude.backup.notatime",
+	}
+	for _, name := range names {
+		if err := os.MkdirAll(filepath.Join(filepath.Dir(claudeDir), name), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := PruneBackups(claudeDir, 2)
+	if err != nil {
+		t.Fatalf("PruneBackups failed: %v", err)
+	}
+	if len(removed) != 1 || filepath.Base(removed[0]) != ".claude.backup.auto.20260102-100000" {
+		t.Errorf("Removed %v, want only the oldest automatic backup", removed)
+	}
+
+	backups, err := ListBackups(claudeDir)
+	if err != nil {
+		t.Fatalf("ListBackups failed: %v", err)
+	}
+	var got []string
+	for _, b := range backups {
+		got = append(got, b.Name)
+	}
+	want := []string{
+		".claude.backup.auto.20260104-100000",
+		".claude.backup.auto.20260103-100000",
+		".claude.backup.20260101-100000",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Backups = %v, want %v", got, want)
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	env := setupTestEnv(t)
+	writeFile(t, env.claudeDir, "CLAUDE.md", "before")
+	writeFile(t, env.claudeDir, "agents/helper.md", "helper")
+
+	backupDir, err := env.syncer.CreateBackup()
+	if err != nil {
+		t.Fatalf("CreateBackup failed: %v", err)
+	}
+	writeFile(t, env.claudeDir, "CLAUDE.md", "after")
+	writeFile(t, env.claudeDir, "agents/new.md", "new")
+
+	backups, err := ListBackups(env.claudeDir)
+	if err != nil || len(backups) != 1 || backups[0].Path != backupDir {
+		t.Fatalf("ListBackups = %+v, %v", backups, err)
+	}
+	restored, err := RestoreBackup(env.claudeDir, backups[0])
+	if err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+	if strings.Join(restored, ",") != "CLAUDE.md,agents/helper.md" {
+		t.Errorf("Restored %v", restored)
+	}
+	if got := readFile(t, env.claudeDir, "CLAUDE.md"); got != "before" {
+		t.Errorf("CLAUDE.md = %q, want the backed-up content", got)
+	}
+	if got := readFile(t, env.claudeDir, "agents/new.md"); got != "new" {
+		t.Errorf("Files not in the backup should be left alone, got %q", got)
+	}
+}
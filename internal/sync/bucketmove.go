@@ -0,0 +1,219 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// BucketMovedKey marks a bucket that 'claude-sync remote move' moved away
+// from. Devices that push or pull to the old bucket find it and switch over.
+const BucketMovedKey = "_metadata/moved.json"
+
+// BucketMove is the content of the BucketMovedKey marker.
+type BucketMove struct {
+	Bucket  string    `json:"bucket"`
+	Device  string    `json:"device"`
+	MovedAt time.Time `json:"moved_at"`
+
+	// Switched records when each other device switched to Bucket.
+	Switched map[string]time.Time `json:"switched,omitempty"`
+}
+
+// CopyBucketResult reports what CopyBucket did.
+type CopyBucketResult struct {
+	Copied    int
+	Unchanged int // Already in the new bucket with the same size
+}
+
+// CopyBucket copies every object in the current bucket to dst, the store for
+// dstBucket, then leaves a BucketMovedKey marker in the current bucket.
+// Objects already in dst with the same size are skipped, so an interrupted
+// copy can be run again.
+func (s *Syncer) CopyBucket(ctx context.Context, dst storage.Storage, dstBucket string) (*CopyBucketResult, error) {
+	objects, err := s.storage.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote objects: %w", err)
+	}
+	existing, err := dst.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dstBucket, err)
+	}
+	sizes := make(map[string]int64, len(existing))
+	for _, obj := range existing {
+		sizes[obj.Key] = obj.Size
+	}
+
+	// A marker left from moving away from dst earlier would send devices back
+	if _, ok := sizes[BucketMovedKey+".age"]; ok {
+		if err := dst.Delete(ctx, BucketMovedKey+".age"); err != nil {
+			return nil, fmt.Errorf("failed to remove the old move marker from %s: %w", dstBucket, err)
+		}
+	}
+
+	result := &CopyBucketResult{}
+	var toCopy []storage.ObjectInfo
+	for _, obj := range objects {
+		if obj.Key == BucketMovedKey+".age" {
+			continue
+		}
+		if size, ok := sizes[obj.Key]; ok && size == obj.Size {
+			result.Unchanged++
+			continue
+		}
+		toCopy = append(toCopy, obj)
+	}
+
+	sem := make(chan struct{}, defaultWorkers)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	var completed atomic.Int32
+	for _, obj := range toCopy {
+		wg.Add(1)
+		go func(obj storage.ObjectInfo) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			s.progress(ProgressEvent{
+				Action:  "upload",
+				Path:    obj.Key,
+				Size:    obj.Size,
+				Current: int(completed.Add(1)),
+				Total:   len(toCopy),
+			})
+			if err := storage.CopyObject(ctx, s.storage, dst, obj.Key, dstBucket); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to copy %s: %w", obj.Key, err)
+				}
+				mu.Unlock()
+			}
+		}(obj)
+	}
+	wg.Wait()
+	s.progress(ProgressEvent{Action: "upload", Complete: true, Total: len(toCopy)})
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	result.Copied = len(toCopy)
+
+	// Running the copy again keeps the devices that already switched
+	err = updateJSON(ctx, s, BucketMovedKey+".age", func(move *BucketMove, found bool) error {
+		if move.Bucket != dstBucket {
+			move.Switched = nil
+		}
+		move.Bucket, move.Device, move.MovedAt = dstBucket, s.state.DeviceID, time.Now()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark the bucket as moved to %s: %w", dstBucket, err)
+	}
+	return result, nil
+}
+
+// ClearMovedBucket deletes everything in store, the bucket moved away from,
+// except the BucketMovedKey marker, so a device that still hasn't switched
+// is redirected instead of finding an empty bucket.
+func ClearMovedBucket(ctx context.Context, store storage.Storage) (int, error) {
+	objects, err := store.List(ctx, "")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list old bucket: %w", err)
+	}
+	var keys []string
+	for _, obj := range objects {
+		if obj.Key != BucketMovedKey+".age" {
+			keys = append(keys, obj.Key)
+		}
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if err := store.DeleteBatch(ctx, keys); err != nil {
+		return 0, fmt.Errorf("failed to clear old bucket: %w", err)
+	}
+	return len(keys), nil
+}
+
+// followBucketMove switches s to the bucket its bucket was moved to, when
+// a BucketMovedKey marker is there, and records the switch in the marker for
+// 'claude-sync remote move --finish'. It returns the move, or nil when there
+// is none. A marker that can't be read is ignored.
+func (s *Syncer) followBucketMove(ctx context.Context) (*BucketMove, error) {
+	var move BucketMove
+	err := s.downloadJSON(ctx, BucketMovedKey+".age", &move)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil || move.Bucket == "" {
+		slog.WarnContext(ctx, "ignoring unreadable bucket move marker", "error", err)
+		return nil, nil
+	}
+	if s.openBucket == nil {
+		return nil, fmt.Errorf("the remote has moved to bucket %s, which this device can't open", move.Bucket)
+	}
+	store, err := s.openBucket(move.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("the remote has moved to bucket %s: %w", move.Bucket, err)
+	}
+
+	err = updateJSON(ctx, s, BucketMovedKey+".age", func(m *BucketMove, found bool) error {
+		if m.Bucket != move.Bucket {
+			return nil // Moved again in between; the next sync follows that
+		}
+		if m.Switched == nil {
+			m.Switched = make(map[string]time.Time)
+		}
+		m.Switched[s.state.DeviceID] = time.Now()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record the switch to bucket %s: %w", move.Bucket, err)
+	}
+	s.storage = store
+	return &move, nil
+}
+
+// UnswitchedDevices returns the devices that pushed to old, the bucket s
+// moved away from, and haven't switched to the new one since, sorted. The
+// device that moved isn't one of them.
+func (s *Syncer) UnswitchedDevices(ctx context.Context, old storage.Storage) ([]string, error) {
+	encrypted, err := old.Download(ctx, BucketMovedKey+".age")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the bucket move marker: %w", err)
+	}
+	var move BucketMove
+	if err := s.decodeJSON(encrypted, &move); err != nil {
+		return nil, fmt.Errorf("failed to read the bucket move marker: %w", err)
+	}
+	// Snapshot keys carry device IDs as safeDeviceName spells them
+	switched := map[string]bool{safeDeviceName(move.Device): true}
+	for device := range move.Switched {
+		switched[safeDeviceName(device)] = true
+	}
+
+	objects, err := old.List(ctx, SnapshotPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	seen := make(map[string]bool)
+	var devices []string
+	for _, obj := range objects {
+		info, ok := parseSnapshotKey(obj.Key)
+		if !ok || switched[info.DeviceID] || seen[info.DeviceID] {
+			continue
+		}
+		seen[info.DeviceID] = true
+		devices = append(devices, info.DeviceID)
+	}
+	sort.Strings(devices)
+	return devices, nil
+}
@@ -0,0 +1,140 @@
+package sync
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+func TestCopyBucketAndFollowMove(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "agents/a.md", "agent a")
+	writeFile(t, env.claudeDir, "settings.json", `{"a":1}`)
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	dst := newMockStorage()
+	dst.objects["agents/a.md.age"] = env.store.objects["agents/a.md.age"]
+	result, err := env.syncer.CopyBucket(ctx, dst, "claude-sync-new")
+	if err != nil {
+		t.Fatalf("CopyBucket failed: %v", err)
+	}
+	if result.Unchanged != 1 || result.Copied != len(env.store.objects)-2 {
+		t.Errorf("Unexpected result %+v for %d source objects", result, len(env.store.objects))
+	}
+	for key := range env.store.objects {
+		if _, ok := dst.objects[key]; !ok && key != BucketMovedKey+".age" {
+			t.Errorf("Expected %s in the new bucket", key)
+		}
+	}
+	if _, ok := dst.objects[BucketMovedKey+".age"]; ok {
+		t.Error("Moved marker must stay in the old bucket only")
+	}
+
+	// A device still on the old bucket finds the marker on pull
+	env.syncer.openBucket = func(bucket string) (storage.Storage, error) { return dst, nil }
+	pulled, err := env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if pulled.BucketMoved == nil || pulled.BucketMoved.Bucket != "claude-sync-new" {
+		t.Fatalf("Expected pull to report the move, got %+v", pulled.BucketMoved)
+	}
+	if env.syncer.storage != dst {
+		t.Error("Expected pull to switch to the new bucket")
+	}
+
+	deleted, err := ClearMovedBucket(ctx, env.store)
+	if err != nil {
+		t.Fatalf("ClearMovedBucket failed: %v", err)
+	}
+	if deleted == 0 || len(env.store.objects) != 1 {
+		t.Fatalf("Expected only the marker to remain, got %d objects", len(env.store.objects))
+	}
+	late := sharedBucketEnv(t, env)
+	late.syncer.openBucket = env.syncer.openBucket
+	pulled, err = late.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if pulled.BucketMoved == nil || len(pulled.Downloaded) != 2 {
+		t.Errorf("Expected the marker to keep redirecting after the old bucket is emptied, got %+v", pulled)
+	}
+}
+
+func TestDevicesFollowBucketMove(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	dst := newMockStorage()
+	openDst := func(bucket string) (storage.Storage, error) { return dst, nil }
+
+	writeFile(t, env.claudeDir, "agents/a.md", "agent a")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	laptop := sharedBucketEnv(t, env)
+	laptop.syncer.state.DeviceID = "laptop"
+	laptop.syncer.openBucket = openDst
+	writeFile(t, laptop.claudeDir, "agents/b.md", "agent b")
+	if _, err := laptop.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if _, err := env.syncer.CopyBucket(ctx, dst, "claude-sync-new"); err != nil {
+		t.Fatalf("CopyBucket failed: %v", err)
+	}
+	env.syncer.storage = dst
+	devices, err := env.syncer.UnswitchedDevices(ctx, env.store)
+	if err != nil {
+		t.Fatalf("UnswitchedDevices failed: %v", err)
+	}
+	if !reflect.DeepEqual(devices, []string{"laptop"}) {
+		t.Errorf("UnswitchedDevices = %v, want [laptop]", devices)
+	}
+
+	// A push lands in the new bucket, not the old one
+	writeFile(t, laptop.claudeDir, "agents/c.md", "agent c")
+	result, err := laptop.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if result.BucketMoved == nil || result.BucketMoved.Bucket != "claude-sync-new" {
+		t.Errorf("Expected push to report the move, got %+v", result.BucketMoved)
+	}
+	if _, ok := dst.objects["agents/c.md.age"]; !ok {
+		t.Error("Expected the push in the new bucket")
+	}
+	if _, ok := env.store.objects["agents/c.md.age"]; ok {
+		t.Error("Expected nothing pushed to the old bucket")
+	}
+
+	devices, err = env.syncer.UnswitchedDevices(ctx, env.store)
+	if err != nil {
+		t.Fatalf("UnswitchedDevices failed: %v", err)
+	}
+	if len(devices) != 0 {
+		t.Errorf("UnswitchedDevices = %v after the switch, want none", devices)
+	}
+}
+
+func TestPullWithoutMoveMarker(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "agents/a.md", "agent a")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	result, err := env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if result.BucketMoved != nil {
+		t.Errorf("Unexpected move %+v", result.BucketMoved)
+	}
+}
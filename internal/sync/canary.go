@@ -0,0 +1,58 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// CanaryKey holds a short known text encrypted with the bucket's key. Init
+// checks a key (a typed passphrase, say) by decrypting it: unlike a data file
+// it is tiny, always the same, and there before anything has been pushed.
+// Rekey re-encrypts it with the rest.
+const CanaryKey = "_metadata/canary.age"
+
+var canaryText = []byte("claude-sync canary v1\n")
+
+// ErrCanaryMismatch is returned when a key can't open the bucket's canary.
+var ErrCanaryMismatch = errors.New("key_mismatch: the key doesn't decrypt the bucket's canary")
+
+// VerifyCanary checks enc against the bucket's canary. found is false when
+// the bucket has none (set up before canaries, or not set up yet).
+func VerifyCanary(ctx context.Context, store storage.Storage, enc crypto.Cipher) (found bool, err error) {
+	objects, err := store.List(ctx, CanaryKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to list remote files: %w", err)
+	}
+	for _, obj := range objects {
+		found = found || obj.Key == CanaryKey
+	}
+	if !found {
+		return false, nil
+	}
+	data, err := store.Download(ctx, CanaryKey)
+	if err != nil {
+		return true, fmt.Errorf("failed to download the key canary: %w", err)
+	}
+	plaintext, err := enc.Decrypt(data)
+	if err != nil || !bytes.Equal(plaintext, canaryText) {
+		return true, ErrCanaryMismatch
+	}
+	return true, nil
+}
+
+// SaveCanary stores the canary encrypted with enc, replacing any other.
+func SaveCanary(ctx context.Context, store storage.Storage, enc crypto.Cipher) error {
+	data, err := enc.Encrypt(canaryText)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt the key canary: %w", err)
+	}
+	if err := store.Upload(ctx, CanaryKey, data); err != nil {
+		return fmt.Errorf("failed to upload the key canary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCanary(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	if found, err := VerifyCanary(ctx, env.store, env.syncer.encryptor); found || err != nil {
+		t.Fatalf("Expected no canary in an empty bucket, got %v, %v", found, err)
+	}
+
+	// The first push records one with the fingerprint
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	pushOK(t, env)
+	if found, err := VerifyCanary(ctx, env.store, env.syncer.encryptor); !found || err != nil {
+		t.Fatalf("Expected the canary to verify, got %v, %v", found, err)
+	}
+	if _, err := VerifyCanary(ctx, env.store, newTestEncryptor(t)); !errors.Is(err, ErrCanaryMismatch) {
+		t.Errorf("Expected ErrCanaryMismatch for another key, got %v", err)
+	}
+
+	// Rekey moves it to the new key
+	newEnc := newTestEncryptor(t)
+	if result, err := env.syncer.Rekey(ctx, newEnc); err != nil || len(result.Failed) != 0 {
+		t.Fatalf("Rekey failed: %v, %+v", err, result)
+	}
+	if found, err := VerifyCanary(ctx, env.store, newEnc); !found || err != nil {
+		t.Errorf("Expected the canary rekeyed, got %v, %v", found, err)
+	}
+}
@@ -0,0 +1,43 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNotRemote is returned by ReadRemote for a file the bucket doesn't hold.
+var ErrNotRemote = errors.New("not in remote storage")
+
+// ReadRemote downloads and decrypts a file from the bucket without writing
+// it anywhere: the current remote copy, or with version set, the nth newest
+// stored version (1 is the latest).
+func (s *Syncer) ReadRemote(ctx context.Context, relativePath string, version int) ([]byte, error) {
+	if version != 0 {
+		versions, err := s.ListVersions(ctx, relativePath)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("no stored versions of %s (versions are kept on push when 'versioning: true' is set)", relativePath)
+		}
+		if version < 1 || version > len(versions) {
+			return nil, fmt.Errorf("%s has %d stored versions; version %d doesn't exist", relativePath, len(versions), version)
+		}
+		return s.FetchVersion(ctx, versions[version-1])
+	}
+
+	found, err := s.StatRemote(ctx, []string{relativePath})
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := found[relativePath]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", relativePath, ErrNotRemote)
+	}
+	data, err := s.fetchFile(ctx, relativePath, obj.Key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", relativePath, err)
+	}
+	return data, nil
+}
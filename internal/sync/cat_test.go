@@ -0,0 +1,45 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadRemote(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Versioning = true
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "v1")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond) // Distinct version timestamps
+	writeFile(t, env.claudeDir, "CLAUDE.md", "v2")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	writeFile(t, env.claudeDir, "CLAUDE.md", "local edit")
+
+	for version, want := range map[int]string{0: "v2", 1: "v2", 2: "v1"} {
+		data, err := env.syncer.ReadRemote(ctx, "CLAUDE.md", version)
+		if err != nil {
+			t.Fatalf("ReadRemote(version %d) failed: %v", version, err)
+		}
+		if string(data) != want {
+			t.Errorf("ReadRemote(version %d) = %q, want %q", version, data, want)
+		}
+	}
+	if got := readFile(t, env.claudeDir, "CLAUDE.md"); got != "local edit" {
+		t.Errorf("local file = %q, ReadRemote must not write it", got)
+	}
+
+	if _, err := env.syncer.ReadRemote(ctx, "CLAUDE.md", 3); err == nil {
+		t.Error("ReadRemote(version 3) succeeded with only 2 versions")
+	}
+	if _, err := env.syncer.ReadRemote(ctx, "missing.md", 0); !errors.Is(err, ErrNotRemote) {
+		t.Errorf("ReadRemote of a file that isn't remote = %v, want ErrNotRemote", err)
+	}
+}
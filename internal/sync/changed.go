@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+)
+
+// LocalChangesPending reports whether a push from claudeDir could have
+// anything to upload or delete, without contacting storage. A file whose
+// size and modification time match its state entry counts as unchanged; one
+// whose time alone moved is hashed, so touching a file isn't a change. With
+// mcp set, the MCP servers in ~/.claude.json are compared too.
+//
+// It errs towards true: changes a push would hold back (paused paths, sync
+// windows) still count, so the push that follows may upload nothing.
+func LocalChangesPending(cfg *config.Config, state *SyncState, claudeDir string, mcp bool) (bool, error) {
+	localFiles, err := GetLocalFiles(claudeDir, cfg.ScopeSyncPaths(), cfg.IsExcluded)
+	if err != nil {
+		return false, err
+	}
+
+	for relPath, info := range localFiles {
+		existing := state.GetFile(relPath)
+		if existing == nil || existing.Size != info.Size() {
+			return true, nil
+		}
+		if existing.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+		hash, err := state.hashFile(filepath.Join(claudeDir, relPath))
+		if err != nil {
+			return false, fmt.Errorf("failed to hash %s: %w", relPath, err)
+		}
+		if hash != existing.Hash {
+			return true, nil
+		}
+	}
+
+	state.mu.Lock()
+	knownPaths := make([]string, 0, len(state.Files))
+	for relPath := range state.Files {
+		knownPaths = append(knownPaths, relPath)
+	}
+	state.mu.Unlock()
+	for _, relPath := range knownPaths {
+		if relPath == config.MCPRemoteKey {
+			continue
+		}
+		if _, ok := localFiles[relPath]; !ok && !cfg.SkippedOnHost(relPath) {
+			return true, nil
+		}
+	}
+
+	if mcp {
+		return mcpChangesPending(cfg, state)
+	}
+	return false, nil
+}
+
+// mcpChangesPending reports whether the MCP servers differ from the ones
+// last pushed, the way PushMCP decides.
+func mcpChangesPending(cfg *config.Config, state *SyncState) (bool, error) {
+	claudeJSON := cfg.ClaudeJSONOverride
+	if claudeJSON == "" {
+		claudeJSON = config.ClaudeJSONPath()
+	}
+	servers, err := ReadMCPServers(claudeJSON)
+	if err != nil {
+		return false, fmt.Errorf("failed to read MCP servers: %w", err)
+	}
+	if len(servers) == 0 {
+		return false, nil
+	}
+
+	homeDir, _ := os.UserHomeDir()
+	normalized, err := NormalizeMCPServers(servers, homeDir)
+	if err != nil {
+		return false, fmt.Errorf("failed to normalize MCP paths: %w", err)
+	}
+	hash, err := HashMCPServers(normalized)
+	if err != nil {
+		return false, fmt.Errorf("failed to hash MCP servers: %w", err)
+	}
+	last := state.GetFile(config.MCPRemoteKey)
+	return last == nil || last.Hash != hash, nil
+}
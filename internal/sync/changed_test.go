@@ -0,0 +1,55 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLocalChangesPending(t *testing.T) {
+	env := setupTestEnv(t)
+	cfg := env.syncer.cfg
+	state := env.syncer.state
+
+	pending := func() bool {
+		t.Helper()
+		changed, err := LocalChangesPending(cfg, state, env.claudeDir, false)
+		if err != nil {
+			t.Fatalf("LocalChangesPending failed: %v", err)
+		}
+		return changed
+	}
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	writeFile(t, env.claudeDir, "agents/a.md", "agent a")
+	if !pending() {
+		t.Fatal("New files not pending")
+	}
+	pushOK(t, env)
+	if pending() {
+		t.Fatal("Pending right after a push")
+	}
+
+	// A touched file is hashed and found unchanged
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(env.claudeDir, "CLAUDE.md"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if pending() {
+		t.Error("Touched file counted as a change")
+	}
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "RULES")
+	if !pending() {
+		t.Error("Same-size edit not pending")
+	}
+	pushOK(t, env)
+
+	if err := os.Remove(filepath.Join(env.claudeDir, "agents/a.md")); err != nil {
+		t.Fatal(err)
+	}
+	if !pending() {
+		t.Error("Deleted file not pending")
+	}
+}
@@ -0,0 +1,93 @@
+package sync
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// commandsDir is where Claude Code looks for user slash commands.
+const commandsDir = "commands/"
+
+// ShadowedCommand is a remote command pull left alone because a command set
+// ranked above it defines a command of the same name.
+type ShadowedCommand struct {
+	Path string `json:"path"` // The command that wasn't pulled
+	By   string `json:"by"`   // The higher-ranked command that owns the name
+}
+
+// commandSet returns the set a path under commands/ belongs to and the slash
+// command it defines. Claude Code names a command after its file alone, so
+// commands/team/review.md and commands/review.md both define /review.
+func commandSet(relPath string, namespaces map[string]bool) (set, name string, ok bool) {
+	rest, found := strings.CutPrefix(relPath, commandsDir)
+	if !found || !strings.HasSuffix(rest, ".md") {
+		return "", "", false
+	}
+	name = strings.TrimSuffix(path.Base(rest), ".md")
+	if first, _, nested := strings.Cut(rest, "/"); nested && namespaces[first] {
+		return first, name, true
+	}
+	return config.CommandSetPersonal, name, true
+}
+
+// dropShadowedCommands removes from remoteFiles every command that a
+// higher-ranked command set, local or remote, already defines, and returns
+// what it removed. It does nothing unless command_namespaces is set.
+func (s *Syncer) dropShadowedCommands(remoteFiles map[string]storage.ObjectInfo, localFiles map[string]os.FileInfo) []ShadowedCommand {
+	order, err := s.cfg.CommandPrecedence()
+	if err != nil || len(order) == 0 {
+		return nil
+	}
+	rank := make(map[string]int, len(order))
+	namespaces := make(map[string]bool, len(order))
+	for i, set := range order {
+		rank[set] = i
+		if set != config.CommandSetPersonal {
+			namespaces[set] = true
+		}
+	}
+
+	// The highest-ranked command for each name, ties going to the first path
+	owner := make(map[string]string)
+	claim := func(relPath string) {
+		set, name, ok := commandSet(relPath, namespaces)
+		if !ok {
+			return
+		}
+		current, taken := owner[name]
+		if !taken {
+			owner[name] = relPath
+			return
+		}
+		currentSet, _, _ := commandSet(current, namespaces)
+		if rank[set] < rank[currentSet] || (rank[set] == rank[currentSet] && relPath < current) {
+			owner[name] = relPath
+		}
+	}
+	for relPath := range localFiles {
+		claim(relPath)
+	}
+	for relPath := range remoteFiles {
+		claim(relPath)
+	}
+
+	var shadowed []ShadowedCommand
+	for relPath := range remoteFiles {
+		set, name, ok := commandSet(relPath, namespaces)
+		if !ok {
+			continue
+		}
+		ownerSet, _, _ := commandSet(owner[name], namespaces)
+		if rank[ownerSet] < rank[set] {
+			shadowed = append(shadowed, ShadowedCommand{Path: relPath, By: owner[name]})
+			delete(remoteFiles, relPath)
+		}
+	}
+	sort.Slice(shadowed, func(i, j int) bool { return shadowed[i].Path < shadowed[j].Path })
+	return shadowed
+}
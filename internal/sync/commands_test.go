@@ -0,0 +1,99 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+func TestCommandSet(t *testing.T) {
+	namespaces := map[string]bool{"team": true}
+	tests := []struct {
+		path, set, name string
+		ok              bool
+	}{
+		{"commands/review.md", "personal", "review", true},
+		{"commands/team/review.md", "team", "review", true},
+		{"commands/team/git/commit.md", "team", "commit", true},
+		{"commands/mine/deploy.md", "personal", "deploy", true},
+		{"commands/notes.txt", "", "", false},
+		{"agents/review.md", "", "", false},
+	}
+	for _, tt := range tests {
+		set, name, ok := commandSet(tt.path, namespaces)
+		if set != tt.set || name != tt.name || ok != tt.ok {
+			t.Errorf("commandSet(%q) = %q, %q, %v; want %q, %q, %v", tt.path, set, name, ok, tt.set, tt.name, tt.ok)
+		}
+	}
+}
+
+func TestPullSkipsShadowedTeamCommands(t *testing.T) {
+	ctx := context.Background()
+
+	// A teammate's device publishes the team set
+	team := setupTestEnv(t)
+	writeFile(t, team.claudeDir, "commands/team/review.md", "team review")
+	writeFile(t, team.claudeDir, "commands/team/deploy.md", "team deploy")
+	if _, err := team.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	env := setupTestEnv(t)
+	env.syncer.storage = team.store
+	env.syncer.encryptor = team.syncer.encryptor
+	env.syncer.cfg.CommandNamespaces = []string{"team"}
+	writeFile(t, env.claudeDir, "commands/review.md", "my review")
+
+	preview, err := env.syncer.PreviewPull(ctx)
+	if err != nil {
+		t.Fatalf("PreviewPull failed: %v", err)
+	}
+	if len(preview.ShadowedCommands) != 1 {
+		t.Errorf("Expected one shadowed command in the preview, got %+v", preview.ShadowedCommands)
+	}
+
+	result, err := env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.ShadowedCommands) != 1 ||
+		result.ShadowedCommands[0] != (ShadowedCommand{Path: "commands/team/review.md", By: "commands/review.md"}) {
+		t.Errorf("Expected team review shadowed by the personal one, got %+v", result.ShadowedCommands)
+	}
+	if _, err := os.Stat(filepath.Join(env.claudeDir, "commands/team/review.md")); !os.IsNotExist(err) {
+		t.Error("Expected the shadowed team command not to be written")
+	}
+	if got := readFile(t, env.claudeDir, "commands/team/deploy.md"); got != "team deploy" {
+		t.Errorf("Expected the non-colliding team command pulled, got %q", got)
+	}
+
+	// Ranking the team set first lets it through
+	env.syncer.cfg.CommandNamespaces = []string{"team", "personal"}
+	result, err = env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.ShadowedCommands) != 0 {
+		t.Errorf("Expected nothing shadowed, got %+v", result.ShadowedCommands)
+	}
+	if got := readFile(t, env.claudeDir, "commands/team/review.md"); got != "team review" {
+		t.Errorf("Expected the team command pulled, got %q", got)
+	}
+}
+
+func TestDropShadowedCommandsDisabledByDefault(t *testing.T) {
+	env := setupTestEnv(t)
+	writeFile(t, env.claudeDir, "commands/review.md", "mine")
+	local, err := GetLocalFiles(env.claudeDir, []string{"commands"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := map[string]storage.ObjectInfo{"commands/team/review.md": {Key: "commands/team/review.md.age"}}
+
+	if shadowed := env.syncer.dropShadowedCommands(remote, local); shadowed != nil || len(remote) != 1 {
+		t.Errorf("Expected no filtering without command_namespaces, got %+v", shadowed)
+	}
+}
@@ -0,0 +1,243 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// conflictMarker separates the original file name from the timestamp in the
+// name of a saved remote copy: <path>.conflict.20060102-150405
+const conflictMarker = ".conflict."
+
+// conflictTimeLayout is the timestamp format used in conflict file names.
+const conflictTimeLayout = "20060102-150405"
+
+// Conflict is a local file with a remote version saved next to it by pull.
+// Paths are relative to the Claude directory, using forward slashes.
+type Conflict struct {
+	Path         string    `json:"path"`                 // The local file that was kept
+	ConflictPath string    `json:"conflict_path"`        // The saved remote version
+	Timestamp    string    `json:"timestamp"`            // Raw timestamp from the conflict file name
+	DetectedAt   time.Time `json:"detected_at,omitzero"` // Parsed Timestamp (zero if unparseable)
+	LocalSize    int64     `json:"local_size"`
+	RemoteSize   int64     `json:"remote_size"`
+	LocalMissing bool      `json:"local_missing,omitempty"` // The local file was deleted after the conflict was saved
+
+	// RemoteDevice and RemotePushedAt identify the push that produced the
+	// remote version, when known (set by pulls from this version onwards).
+	RemoteDevice   string    `json:"remote_device,omitempty"`
+	RemotePushedAt time.Time `json:"remote_pushed_at,omitzero"`
+}
+
+// Resolution says which version of a conflicting file to keep.
+type Resolution string
+
+const (
+	KeepLocal  Resolution = "local"
+	KeepRemote Resolution = "remote"
+	KeepMerged Resolution = "merged"
+)
+
+// ParseResolution validates a user-supplied resolution choice.
+func ParseResolution(s string) (Resolution, error) {
+	switch r := Resolution(strings.ToLower(strings.TrimSpace(s))); r {
+	case KeepLocal, KeepRemote, KeepMerged:
+		return r, nil
+	}
+	return "", fmt.Errorf("invalid resolution %q: must be 'local', 'remote', or 'merged'", s)
+}
+
+// FindConflicts walks claudeDir for saved remote versions, newest first.
+// If state is non-nil, each conflict is annotated with its recorded origin.
+func FindConflicts(claudeDir string, state *SyncState) ([]Conflict, error) {
+	var conflicts []Conflict
+
+	err := filepath.Walk(claudeDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if info.IsDir() || !strings.Contains(info.Name(), conflictMarker) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(claudeDir, path)
+		if err != nil {
+			return nil
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		idx := strings.LastIndex(relPath, conflictMarker)
+		c := Conflict{
+			Path:         relPath[:idx],
+			ConflictPath: relPath,
+			Timestamp:    relPath[idx+len(conflictMarker):],
+			RemoteSize:   info.Size(),
+		}
+		if t, err := time.ParseInLocation(conflictTimeLayout, c.Timestamp, time.Local); err == nil {
+			c.DetectedAt = t
+		}
+		if localInfo, err := os.Stat(filepath.Join(claudeDir, filepath.FromSlash(c.Path))); err == nil {
+			c.LocalSize = localInfo.Size()
+		} else {
+			c.LocalMissing = true
+		}
+		if state != nil {
+			if origin, ok := state.ConflictOrigin(relPath); ok {
+				c.RemoteDevice = origin.Device
+				c.RemotePushedAt = origin.PushedAt
+			}
+		}
+		conflicts = append(conflicts, c)
+		return nil
+	})
+
+	// Newest first
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Timestamp != conflicts[j].Timestamp {
+			return conflicts[i].Timestamp > conflicts[j].Timestamp
+		}
+		return conflicts[i].Path < conflicts[j].Path
+	})
+
+	return conflicts, err
+}
+
+// ResolveConflict applies a resolution to one conflict and removes the saved
+// remote copy. merged is the resolved content for KeepMerged and ignored
+// otherwise.
+//
+// The remote version becomes the sync baseline in state, so a kept local or
+// merged file shows up as a local change and the next push propagates it to
+// other devices. Keeping remote leaves the file in sync. The caller saves state.
+func ResolveConflict(claudeDir string, state *SyncState, c Conflict, choice Resolution, merged []byte) error {
+	localPath := filepath.Join(claudeDir, filepath.FromSlash(c.Path))
+	conflictPath := filepath.Join(claudeDir, filepath.FromSlash(c.ConflictPath))
+
+	remoteHash, err := state.hashFile(conflictPath)
+	if err != nil {
+		return fmt.Errorf("failed to read conflict file: %w", err)
+	}
+
+	switch choice {
+	case KeepLocal:
+		if err := os.Remove(conflictPath); err != nil {
+			return fmt.Errorf("failed to remove conflict file: %w", err)
+		}
+	case KeepRemote:
+		if err := os.Rename(conflictPath, localPath); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", c.Path, err)
+		}
+	case KeepMerged:
+		if merged == nil {
+			return fmt.Errorf("merged resolution requires content")
+		}
+		if err := os.WriteFile(localPath, merged, 0600); err != nil {
+			return fmt.Errorf("failed to write merged %s: %w", c.Path, err)
+		}
+		if err := os.Remove(conflictPath); err != nil {
+			return fmt.Errorf("failed to remove conflict file: %w", err)
+		}
+	default:
+		return fmt.Errorf("invalid resolution %q", choice)
+	}
+
+	state.ClearConflict(c.ConflictPath)
+
+	info, err := os.Stat(localPath)
+	if err != nil {
+		// Local was deleted and the user kept that: nothing to record
+		if os.IsNotExist(err) && choice == KeepLocal {
+			return nil
+		}
+		return fmt.Errorf("failed to stat %s: %w", c.Path, err)
+	}
+	state.UpdateFile(c.Path, info, remoteHash)
+	state.MarkUploaded(c.Path)
+	return nil
+}
+
+// ListConflicts returns the unresolved conflicts under the Claude directory.
+func (s *Syncer) ListConflicts() ([]Conflict, error) {
+	return FindConflicts(s.claudeDir, s.state)
+}
+
+// ResolveConflict resolves the conflict for path, which may be either the
+// local file or a specific .conflict copy. When a file has several saved
+// remote versions, the newest is used. State is saved afterwards.
+func (s *Syncer) ResolveConflict(path string, choice Resolution, merged []byte) error {
+	_, err := ResolveConflictPath(s.claudeDir, s.state, path, choice, merged)
+	return err
+}
+
+// ResolveConflictPath finds the conflict for a relative path (the local file
+// or a specific .conflict copy, newest first), resolves it, and saves state.
+func ResolveConflictPath(claudeDir string, state *SyncState, path string, choice Resolution, merged []byte) (*Conflict, error) {
+	conflicts, err := FindConflicts(claudeDir, state)
+	if err != nil {
+		return nil, err
+	}
+
+	path = filepath.ToSlash(filepath.Clean(path))
+	for _, c := range conflicts {
+		if c.Path == path || c.ConflictPath == path {
+			if err := ResolveConflict(claudeDir, state, c, choice, merged); err != nil {
+				return nil, err
+			}
+			if err := state.Save(); err != nil {
+				return nil, fmt.Errorf("failed to save state: %w", err)
+			}
+			return &c, nil
+		}
+	}
+	return nil, fmt.Errorf("no conflict found for %s", path)
+}
+
+// ConflictError pairs a conflict with the error that stopped its resolution.
+type ConflictError struct {
+	Conflict Conflict
+	Err      error
+}
+
+// BatchResolveResult reports the outcome of resolving several conflicts at once.
+type BatchResolveResult struct {
+	Resolved []Conflict
+	Failed   []ConflictError
+}
+
+// ResolveConflicts applies the same resolution to every conflict, continuing
+// past individual failures, and saves state if anything was resolved. Only
+// KeepLocal and KeepRemote make sense in bulk.
+func ResolveConflicts(claudeDir string, state *SyncState, conflicts []Conflict, choice Resolution) (*BatchResolveResult, error) {
+	if choice != KeepLocal && choice != KeepRemote {
+		return nil, fmt.Errorf("batch resolution must keep 'local' or 'remote', got %q", choice)
+	}
+
+	result := &BatchResolveResult{}
+	for _, c := range conflicts {
+		if err := ResolveConflict(claudeDir, state, c, choice, nil); err != nil {
+			result.Failed = append(result.Failed, ConflictError{Conflict: c, Err: err})
+			continue
+		}
+		result.Resolved = append(result.Resolved, c)
+	}
+
+	if len(result.Resolved) > 0 {
+		if err := state.Save(); err != nil {
+			return result, fmt.Errorf("failed to save state: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// ResolveAllConflicts resolves every pending conflict the same way.
+func (s *Syncer) ResolveAllConflicts(choice Resolution) (*BatchResolveResult, error) {
+	conflicts, err := s.ListConflicts()
+	if err != nil {
+		return nil, err
+	}
+	return ResolveConflicts(s.claudeDir, s.state, conflicts, choice)
+}
@@ -0,0 +1,184 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindConflicts(t *testing.T) {
+	env := setupTestEnv(t)
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "local")
+	writeFile(t, env.claudeDir, "CLAUDE.md.conflict.20260208-095132", "older remote")
+	writeFile(t, env.claudeDir, "agents/a.md", "local agent")
+	writeFile(t, env.claudeDir, "agents/a.md.conflict.20260209-101010", "remote agent!")
+	writeFile(t, env.claudeDir, "agents/b.md", "no conflict")
+
+	conflicts, err := env.syncer.ListConflicts()
+	if err != nil {
+		t.Fatalf("ListConflicts failed: %v", err)
+	}
+	if len(conflicts) != 2 {
+		t.Fatalf("Expected 2 conflicts, got %d: %+v", len(conflicts), conflicts)
+	}
+
+	// Newest first
+	c := conflicts[0]
+	if c.Path != "agents/a.md" || c.ConflictPath != "agents/a.md.conflict.20260209-101010" {
+		t.Errorf("Unexpected first conflict: %+v", c)
+	}
+	if c.LocalSize != int64(len("local agent")) || c.RemoteSize != int64(len("remote agent!")) {
+		t.Errorf("Unexpected sizes: local=%d remote=%d", c.LocalSize, c.RemoteSize)
+	}
+	if c.DetectedAt.IsZero() {
+		t.Error("Expected timestamp to be parsed")
+	}
+}
+
+func TestResolveConflictChoices(t *testing.T) {
+	tests := []struct {
+		choice    Resolution
+		merged    []byte
+		wantLocal string
+		// Whether the file should need a push afterwards (local differs from the remote baseline)
+		wantPending bool
+	}{
+		{choice: KeepLocal, wantLocal: "local edit", wantPending: true},
+		{choice: KeepRemote, wantLocal: "remote edit", wantPending: false},
+		{choice: KeepMerged, merged: []byte("merged edit"), wantLocal: "merged edit", wantPending: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.choice), func(t *testing.T) {
+			env := setupTestEnv(t)
+			writeFile(t, env.claudeDir, "CLAUDE.md", "local edit")
+			writeFile(t, env.claudeDir, "CLAUDE.md.conflict.20260208-095132", "remote edit")
+
+			if err := env.syncer.ResolveConflict("CLAUDE.md", tt.choice, tt.merged); err != nil {
+				t.Fatalf("ResolveConflict failed: %v", err)
+			}
+
+			if got := readFile(t, env.claudeDir, "CLAUDE.md"); got != tt.wantLocal {
+				t.Errorf("Local content = %q, want %q", got, tt.wantLocal)
+			}
+			if _, err := os.Stat(filepath.Join(env.claudeDir, "CLAUDE.md.conflict.20260208-095132")); !os.IsNotExist(err) {
+				t.Error("Expected conflict file to be removed")
+			}
+
+			changes, err := env.syncer.Status(context.Background())
+			if err != nil {
+				t.Fatalf("Status failed: %v", err)
+			}
+			if pending := len(changes) > 0; pending != tt.wantPending {
+				t.Errorf("Pending changes = %v, want %v (%v)", pending, tt.wantPending, changes)
+			}
+
+			// State is persisted
+			if _, err := os.Stat(filepath.Join(env.stateDir, "state.json")); err != nil {
+				t.Errorf("Expected state to be saved: %v", err)
+			}
+		})
+	}
+}
+
+func TestResolveConflictErrors(t *testing.T) {
+	env := setupTestEnv(t)
+	writeFile(t, env.claudeDir, "CLAUDE.md", "local")
+	writeFile(t, env.claudeDir, "CLAUDE.md.conflict.20260208-095132", "remote")
+
+	if err := env.syncer.ResolveConflict("settings.json", KeepLocal, nil); err == nil {
+		t.Error("Expected error for a path without conflicts")
+	}
+	if err := env.syncer.ResolveConflict("CLAUDE.md", KeepMerged, nil); err == nil {
+		t.Error("Expected error for merged resolution without content")
+	}
+	if _, err := ParseResolution("theirs"); err == nil {
+		t.Error("Expected error for unknown resolution")
+	}
+	if r, err := ParseResolution(" Remote "); err != nil || r != KeepRemote {
+		t.Errorf("ParseResolution(Remote) = %q, %v", r, err)
+	}
+}
+
+func TestPullRecordsConflictOrigin(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# V1")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	pushedAt := env.syncer.state.GetFile("CLAUDE.md").PushedAt
+	if pushedAt.IsZero() || env.syncer.state.GetFile("CLAUDE.md").Origin != env.syncer.state.DeviceID {
+		t.Fatalf("Expected push to record origin, got %+v", env.syncer.state.GetFile("CLAUDE.md"))
+	}
+
+	// Both sides change; the manifest still names this device as the pusher
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# local V2")
+	encrypted, err := env.syncer.encryptor.Encrypt([]byte("# remote V2"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := env.store.Upload(ctx, "CLAUDE.md.age", encrypted); err != nil {
+		t.Fatalf("Upload to mock failed: %v", err)
+	}
+
+	if _, err := env.syncer.Pull(ctx); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+
+	conflicts, err := env.syncer.ListConflicts()
+	if err != nil {
+		t.Fatalf("ListConflicts failed: %v", err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("Expected 1 conflict, got %d", len(conflicts))
+	}
+	c := conflicts[0]
+	if c.RemoteDevice != env.syncer.state.DeviceID {
+		t.Errorf("RemoteDevice = %q, want %q", c.RemoteDevice, env.syncer.state.DeviceID)
+	}
+	if !c.RemotePushedAt.Equal(pushedAt) {
+		t.Errorf("RemotePushedAt = %v, want %v", c.RemotePushedAt, pushedAt)
+	}
+
+	// Resolving forgets the origin record
+	if err := env.syncer.ResolveConflict(c.ConflictPath, KeepLocal, nil); err != nil {
+		t.Fatalf("ResolveConflict failed: %v", err)
+	}
+	if _, ok := env.syncer.state.ConflictOrigin(c.ConflictPath); ok {
+		t.Error("Expected conflict origin to be cleared after resolution")
+	}
+}
+
+func TestResolveAllConflicts(t *testing.T) {
+	env := setupTestEnv(t)
+	writeFile(t, env.claudeDir, "CLAUDE.md", "local")
+	writeFile(t, env.claudeDir, "CLAUDE.md.conflict.20260208-095132", "remote")
+	writeFile(t, env.claudeDir, "agents/a.md", "local a")
+	writeFile(t, env.claudeDir, "agents/a.md.conflict.20260208-095133", "remote a")
+
+	if _, err := env.syncer.ResolveAllConflicts(KeepMerged); err == nil {
+		t.Error("Expected merged to be rejected for batch resolution")
+	}
+
+	result, err := env.syncer.ResolveAllConflicts(KeepRemote)
+	if err != nil {
+		t.Fatalf("ResolveAllConflicts failed: %v", err)
+	}
+	if len(result.Resolved) != 2 || len(result.Failed) != 0 {
+		t.Fatalf("Expected 2 resolved, got %+v", result)
+	}
+	if got := readFile(t, env.claudeDir, "agents/a.md"); got != "remote a" {
+		t.Errorf("agents/a.md = %q, want remote content", got)
+	}
+
+	remaining, _ := env.syncer.ListConflicts()
+	if len(remaining) != 0 {
+		t.Errorf("Expected no conflicts left, got %v", remaining)
+	}
+}
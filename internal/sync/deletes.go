@@ -0,0 +1,39 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TooManyDeletesError stops a push that would delete more remote files than
+// delete_threshold allows without confirmation.
+type TooManyDeletesError struct {
+	Paths     []string
+	Threshold int
+}
+
+func (e *TooManyDeletesError) Error() string {
+	return fmt.Sprintf("push would delete %d remote files (more than delete_threshold %d); rerun with --confirm-deletes if that is intended",
+		len(e.Paths), e.Threshold)
+}
+
+// checkDeleteThreshold refuses changes that delete more remote files than
+// delete_threshold, unless SetConfirmDeletes allowed it. Deletions staged for
+// review don't count: they change nothing until approved.
+func (s *Syncer) checkDeleteThreshold(changes []FileChange) error {
+	threshold := s.cfg.PushDeleteThreshold()
+	if threshold == 0 || s.confirmDeletes {
+		return nil
+	}
+	var deletes []string
+	for _, change := range changes {
+		if change.Action == "delete" && !s.needsReview(change.Path) {
+			deletes = append(deletes, change.Path)
+		}
+	}
+	if len(deletes) <= threshold {
+		return nil
+	}
+	sort.Strings(deletes)
+	return &TooManyDeletesError{Paths: deletes, Threshold: threshold}
+}
@@ -0,0 +1,71 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPushStopsAtDeleteThreshold(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	threshold := 1
+	env.syncer.cfg.DeleteThreshold = &threshold
+
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		writeFile(t, env.claudeDir, "agents/"+name, name)
+	}
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if err := os.RemoveAll(filepath.Join(env.claudeDir, "agents")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, env.claudeDir, "CLAUDE.md", "new")
+
+	_, err := env.syncer.Push(ctx)
+	var tooMany *TooManyDeletesError
+	if !errors.As(err, &tooMany) {
+		t.Fatalf("Expected TooManyDeletesError, got %v", err)
+	}
+	if len(tooMany.Paths) != 3 || tooMany.Threshold != 1 {
+		t.Errorf("Unexpected error details %+v", tooMany)
+	}
+	if _, ok := env.store.objects["agents/a.md.age"]; !ok {
+		t.Error("Remote file deleted without confirmation")
+	}
+	if _, ok := env.store.objects["CLAUDE.md.age"]; ok {
+		t.Error("Expected nothing pushed from a stopped push")
+	}
+
+	env.syncer.SetConfirmDeletes(true)
+	result, err := env.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if len(result.Deleted) != 3 || len(result.Uploaded) != 1 {
+		t.Errorf("Expected the confirmed push to go through, got %+v", result)
+	}
+}
+
+func TestDeleteThresholdOff(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	off := 0
+	env.syncer.cfg.DeleteThreshold = &off
+
+	writeFile(t, env.claudeDir, "agents/a.md", "a")
+	writeFile(t, env.claudeDir, "agents/b.md", "b")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if err := os.RemoveAll(filepath.Join(env.claudeDir, "agents")); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := env.syncer.Push(ctx); err != nil || len(result.Deleted) != 2 {
+		t.Errorf("Expected deletes through with the check off, got %+v, %v", result, err)
+	}
+}
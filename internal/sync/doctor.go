@@ -0,0 +1,100 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// DoctorPrefix holds the objects 'claude-sync doctor' writes to check
+// access, each deleted again straight away.
+const DoctorPrefix = "_metadata/doctor/"
+
+// StorageProbe is what ProbeStorage found out about the credentials'
+// access to the bucket. An operation that wasn't tried, because an earlier
+// one failed, has a nil error and is false.
+type StorageProbe struct {
+	Listed, Wrote, Read, Deleted          bool
+	ListErr, WriteErr, ReadErr, DeleteErr error
+
+	// ClockSkew is how far the storage server's clock is ahead of this
+	// machine's (negative when behind), judged by the time it stamped on
+	// the object written; SkewKnown is false when it reported none.
+	ClockSkew time.Duration
+	SkewKnown bool
+}
+
+// ProbeStorage checks that store can be listed, written, read back and
+// deleted from, with a small object under DoctorPrefix named for device.
+func ProbeStorage(ctx context.Context, store storage.Storage, device string) *StorageProbe {
+	p := &StorageProbe{}
+	if _, err := store.List(ctx, DoctorPrefix); err != nil {
+		p.ListErr = err
+	} else {
+		p.Listed = true
+	}
+
+	key := DoctorPrefix + unsafeIDChars.ReplaceAllString(device, "_") + ".txt"
+	data := []byte("claude-sync doctor " + time.Now().UTC().Format(time.RFC3339) + "\n")
+	before := time.Now()
+	if err := store.Upload(ctx, key, data); err != nil {
+		p.WriteErr = err
+		return p
+	}
+	after := time.Now()
+	p.Wrote = true
+
+	if info, err := store.Head(ctx, key); err == nil && info != nil && !info.LastModified.IsZero() {
+		p.ClockSkew, p.SkewKnown = clockSkew(info.LastModified, before, after), true
+	}
+
+	got, err := store.Download(ctx, key)
+	switch {
+	case err != nil:
+		p.ReadErr = err
+	case !bytes.Equal(got, data):
+		p.ReadErr = fmt.Errorf("read back %d bytes that differ from the %d written", len(got), len(data))
+	default:
+		p.Read = true
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		p.DeleteErr = err
+	} else {
+		p.Deleted = true
+	}
+	return p
+}
+
+// clockSkew returns how far stamped, the server's time for a write made
+// between before and after, lies outside that span. Servers stamp whole
+// seconds, so before is rounded down first.
+func clockSkew(stamped, before, after time.Time) time.Duration {
+	before = before.Truncate(time.Second)
+	switch {
+	case stamped.Before(before):
+		return stamped.Sub(before)
+	case stamped.After(after):
+		return stamped.Sub(after)
+	}
+	return 0
+}
+
+// CheckStateFile reads the state file at path without repairing it, as
+// LoadState would, and returns it. A missing file is os.ErrNotExist.
+func CheckStateFile(path string) (*SyncState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var state SyncState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%s is corrupt: %w", path, err)
+	}
+	return &state, nil
+}
@@ -0,0 +1,76 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProbeStorage(t *testing.T) {
+	store := newMockStorage()
+	p := ProbeStorage(context.Background(), store, "my laptop")
+
+	if !p.Listed || !p.Wrote || !p.Read || !p.Deleted {
+		t.Fatalf("probe = %+v, want every operation to succeed", p)
+	}
+	if !p.SkewKnown || p.ClockSkew != 0 {
+		t.Errorf("clock skew = %v (known %v), want 0", p.ClockSkew, p.SkewKnown)
+	}
+	if objs, _ := store.List(context.Background(), ""); len(objs) != 0 {
+		t.Errorf("probe left %d object(s) behind", len(objs))
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	before := time.Date(2026, 1, 1, 12, 0, 0, 500e6, time.UTC)
+	after := before.Add(time.Second)
+
+	tests := []struct {
+		name    string
+		stamped time.Time
+		want    time.Duration
+	}{
+		{"within the request", before.Add(300 * time.Millisecond), 0},
+		{"whole second before", before.Truncate(time.Second), 0},
+		{"server ahead", after.Add(3 * time.Minute), 3 * time.Minute},
+		{"server behind", before.Truncate(time.Second).Add(-2 * time.Minute), -2 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := clockSkew(tt.stamped, before, after); got != tt.want {
+			t.Errorf("%s: clockSkew = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckStateFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+
+	if _, err := CheckStateFile(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: err = %v, want os.ErrNotExist", err)
+	}
+
+	if err := os.WriteFile(path, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckStateFile(path); err == nil {
+		t.Error("corrupt file: no error")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "{not json" {
+		t.Error("CheckStateFile changed the corrupt file")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"files":{"a.json":{"path":"a.json"}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	state, err := CheckStateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Files) != 1 {
+		t.Errorf("state has %d file(s), want 1", len(state.Files))
+	}
+}
@@ -0,0 +1,103 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// FingerprintKey holds the fingerprint of the key the bucket's files are
+// encrypted to, so a device with another key (a mistyped passphrase, say)
+// is stopped before it syncs. Like the KDF parameters it is stored
+// unencrypted: it must be readable without the right key.
+const FingerprintKey = "_metadata/fingerprint.txt"
+
+// KeyMismatchError stops a sync whose key doesn't match the bucket's.
+type KeyMismatchError struct {
+	Local, Remote string // Fingerprints
+}
+
+func (e *KeyMismatchError) Error() string {
+	return fmt.Sprintf("this device's key (fingerprint %s) is not the bucket's (%s); if you set it up with a passphrase, it may be mistyped: run 'claude-sync init --passphrase'",
+		e.Local, e.Remote)
+}
+
+// LoadFingerprint returns the bucket's key fingerprint, or "" when it has
+// none (a bucket from an older version, or one nothing was pushed to).
+func LoadFingerprint(ctx context.Context, store storage.Storage) (string, error) {
+	objects, err := store.List(ctx, FingerprintKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to list remote files: %w", err)
+	}
+	found := false
+	for _, obj := range objects {
+		found = found || obj.Key == FingerprintKey
+	}
+	if !found {
+		return "", nil
+	}
+	data, err := store.Download(ctx, FingerprintKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to download key fingerprint: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SaveFingerprint stores fingerprint as the bucket's key fingerprint.
+func SaveFingerprint(ctx context.Context, store storage.Storage, fingerprint string) error {
+	if err := store.Upload(ctx, FingerprintKey, []byte(fingerprint+"\n")); err != nil {
+		return fmt.Errorf("failed to upload key fingerprint: %w", err)
+	}
+	return nil
+}
+
+// checkFingerprint compares this device's key with the bucket's before a
+// sync. The fingerprint of any configured recipient matches too, since
+// their owners can read what this device pushes. A bucket without one gets
+// this device's, and a canary, when store is set (push). The bucket is
+// asked every time, even after a match: another device may have rekeyed it
+// or it may have been replaced since.
+func (s *Syncer) checkFingerprint(ctx context.Context, store bool) error {
+	local := s.encryptor.KeyID()
+	remote, err := LoadFingerprint(ctx, s.storage)
+	if err != nil {
+		return err
+	}
+	if remote == "" {
+		if !store {
+			return nil
+		}
+		if err := SaveFingerprint(ctx, s.storage, local); err != nil {
+			return err
+		}
+		// A bucket without a fingerprint has no canary either
+		if err := SaveCanary(ctx, s.storage, s.encryptor); err != nil {
+			return err
+		}
+		remote = local
+	}
+	if remote != local && !s.recipientFingerprint(remote) {
+		return &KeyMismatchError{Local: local, Remote: remote}
+	}
+	s.state.KeyFingerprint = local
+	return nil
+}
+
+func (s *Syncer) recipientFingerprint(fingerprint string) bool {
+	for _, r := range s.cfg.Recipients {
+		if crypto.KeyFingerprint(r) == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyFingerprints returns this device's key fingerprint and the bucket's
+// ("" when it has none).
+func (s *Syncer) KeyFingerprints(ctx context.Context) (local, remote string, err error) {
+	remote, err = LoadFingerprint(ctx, s.storage)
+	return s.encryptor.KeyID(), remote, err
+}
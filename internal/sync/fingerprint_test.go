@@ -0,0 +1,81 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPushRecordsFingerprint(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	// Pull leaves a bucket without one alone
+	if _, err := env.syncer.Pull(ctx); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if _, ok := env.store.objects[FingerprintKey]; ok {
+		t.Fatal("Expected pull not to record a fingerprint")
+	}
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	pushOK(t, env)
+	local, remote, err := env.syncer.KeyFingerprints(ctx)
+	if err != nil || remote != local {
+		t.Fatalf("Expected the bucket to record %s, got %q (%v)", local, remote, err)
+	}
+	if env.syncer.state.KeyFingerprint != local {
+		t.Error("Expected the match remembered in state")
+	}
+}
+
+func TestMismatchedKeyRefused(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	pushOK(t, env)
+
+	// As a device whose passphrase was mistyped
+	other := sharedBucketEnv(t, env)
+	other.syncer.encryptor = newTestEncryptor(t)
+	writeFile(t, other.claudeDir, "agents/a.md", "agent")
+
+	var mismatch *KeyMismatchError
+	if _, err := other.syncer.Pull(ctx); !errors.As(err, &mismatch) {
+		t.Fatalf("Expected pull refused, got %v", err)
+	}
+	if _, err := other.syncer.Push(ctx); !errors.As(err, &mismatch) {
+		t.Fatalf("Expected push refused, got %v", err)
+	}
+	if _, ok := env.store.objects["agents/a.md.age"]; ok {
+		t.Error("Expected nothing uploaded")
+	}
+
+	// A device with its own key that encrypts to the bucket's is fine
+	other.syncer.cfg.Recipients = []string{ageCipher(env.syncer.encryptor).PublicKey()}
+	if _, err := other.syncer.Push(ctx); err != nil {
+		t.Errorf("Expected a recipient's key accepted, got %v", err)
+	}
+}
+
+func TestFingerprintRecheckedAfterMatch(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	pushOK(t, env)
+
+	// Another device rekeys the bucket
+	other := newTestEncryptor(t)
+	if err := SaveFingerprint(ctx, env.store, other.KeyID()); err != nil {
+		t.Fatal(err)
+	}
+
+	var mismatch *KeyMismatchError
+	if _, err := env.syncer.Pull(ctx); !errors.As(err, &mismatch) {
+		t.Fatalf("Expected pull refused after the bucket's key changed, got %v", err)
+	}
+	writeFile(t, env.claudeDir, "CLAUDE.md", "new rules")
+	if _, err := env.syncer.Push(ctx); !errors.As(err, &mismatch) {
+		t.Fatalf("Expected push refused after the bucket's key changed, got %v", err)
+	}
+}
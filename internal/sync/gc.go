@@ -0,0 +1,145 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Orphan is a remote object that no device's sync state refers to.
+type Orphan struct {
+	Path         string
+	Key          string
+	Size         int64
+	LastModified time.Time
+	Reason       string
+}
+
+// GC finds remote objects that nothing refers to any more and deletes them,
+// through the trash when it is enabled. An object is an orphan when it is a
+// legacy duplicate of a file that also has its canonical key, or when its file
+// is in neither this device's state, the remote manifest, any device's latest
+// snapshot, nor ~/.claude. The snapshots stand in for the other devices'
+// states, since the manifest only holds what the last pusher knew of. Objects
+// modified within minAge are kept, since another device may have pushed them
+// without its manifest landing yet, and so is anything outside this device's
+// scope or excludes. With dryRun, nothing is deleted.
+func (s *Syncer) GC(ctx context.Context, minAge time.Duration, dryRun bool) ([]Orphan, error) {
+	objects, err := s.listRemote(ctx)
+	if err != nil {
+		return nil, err
+	}
+	live, err := s.livePaths(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		keys[obj.Key] = true
+	}
+
+	cutoff := time.Now().Add(-minAge)
+	var orphans []Orphan
+	for _, obj := range objects {
+		if !strings.HasSuffix(obj.Key, ".age") || isReservedKey(obj.Key) {
+			continue
+		}
+		path, ok := s.localPath(obj.Key)
+		if !ok || s.isExcluded(path) || !inSyncPaths(path, s.syncPaths()) {
+			continue
+		}
+
+		if canonical := s.remoteKey(path); canonical != obj.Key && keys[canonical] {
+			orphans = append(orphans, Orphan{
+				Path: path, Key: obj.Key, Size: obj.Size, LastModified: obj.LastModified,
+				Reason: "duplicate of " + canonical,
+			})
+			continue
+		}
+
+		if !obj.LastModified.Before(cutoff) || s.state.GetFile(path) != nil {
+			continue
+		}
+		if live[path] {
+			continue
+		}
+		if _, err := os.Lstat(filepath.Join(s.claudeDir, filepath.FromSlash(path))); err == nil {
+			continue
+		}
+		orphans = append(orphans, Orphan{
+			Path: path, Key: obj.Key, Size: obj.Size, LastModified: obj.LastModified,
+			Reason: "not tracked by any device",
+		})
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Key < orphans[j].Key })
+
+	if dryRun || len(orphans) == 0 {
+		return orphans, nil
+	}
+
+	orphanKeys := make([]string, len(orphans))
+	for i, o := range orphans {
+		orphanKeys[i] = o.Key
+	}
+	deleted, err := s.deleteRemote(ctx, orphanKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete orphans: %w", err)
+	}
+	removed := orphans[:0]
+	for _, o := range orphans {
+		if deleted[o.Key] {
+			removed = append(removed, o)
+		}
+	}
+	return removed, nil
+}
+
+// livePaths returns the paths in the remote manifest or in the latest
+// snapshot of any device that pushed.
+func (s *Syncer) livePaths(ctx context.Context) (map[string]bool, error) {
+	live := make(map[string]bool)
+	manifest, err := s.downloadManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if manifest != nil {
+		for path := range manifest.Files {
+			live[path] = true
+		}
+	}
+
+	snaps, err := s.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]SnapshotInfo)
+	for _, info := range snaps {
+		latest[info.DeviceID] = info // Sorted oldest first
+	}
+	for _, info := range latest {
+		var snap Snapshot
+		if err := s.downloadJSON(ctx, info.Key, &snap); err != nil {
+			return nil, fmt.Errorf("failed to read snapshot %s: %w", info.ID, err)
+		}
+		for path := range snap.Files {
+			live[path] = true
+		}
+	}
+	return live, nil
+}
+
+// inSyncPaths reports whether a relative path falls under one of the
+// configured sync paths.
+func inSyncPaths(path string, syncPaths []string) bool {
+	for _, p := range syncPaths {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGCFindsOrphans(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "agents/a.md", "agent a")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	env.store.objects["agents/gone.md.age"] = mockObject{data: []byte("x"), lastModified: old}
+	env.store.objects["agents/recent.md.age"] = mockObject{data: []byte("x"), lastModified: time.Now()}
+	env.store.objects["agents/a.md.conflict.20250101-120000.age"] = mockObject{data: []byte("x"), lastModified: old}
+	// Present locally but never pushed: the next push owns it
+	env.store.objects["agents/local.md.age"] = mockObject{data: []byte("x"), lastModified: old}
+	writeFile(t, env.claudeDir, "agents/local.md", "local")
+	// Outside the sync paths
+	env.store.objects["elsewhere/x.age"] = mockObject{data: []byte("x"), lastModified: old}
+
+	orphans, err := env.syncer.GC(ctx, 7*24*time.Hour, true)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	var paths []string
+	for _, o := range orphans {
+		paths = append(paths, o.Path)
+	}
+	if len(orphans) != 2 || orphans[0].Path != "agents/a.md.conflict.20250101-120000" || orphans[1].Path != "agents/gone.md" {
+		t.Fatalf("Unexpected orphans %v", paths)
+	}
+	if _, ok := env.store.objects["agents/gone.md.age"]; !ok {
+		t.Fatal("Dry run must not delete")
+	}
+
+	deleted, err := env.syncer.GC(ctx, 7*24*time.Hour, false)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if len(deleted) != 2 {
+		t.Errorf("Expected 2 deletions, got %d", len(deleted))
+	}
+	for _, key := range []string{"agents/gone.md.age", "agents/a.md.conflict.20250101-120000.age"} {
+		if _, ok := env.store.objects[key]; ok {
+			t.Errorf("Expected %s to be deleted", key)
+		}
+	}
+	for _, key := range []string{"agents/a.md.age", "agents/recent.md.age", "agents/local.md.age", "elsewhere/x.age"} {
+		if _, ok := env.store.objects[key]; !ok {
+			t.Errorf("Expected %s to be kept", key)
+		}
+	}
+}
+
+func TestGCKeepsManifestFiles(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "agents/a.md", "agent a")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	// Another device's view: the file is in the manifest but not in our state
+	env.syncer.state.RemoveFile("agents/a.md")
+	if err := os.Remove(filepath.Join(env.claudeDir, "agents/a.md")); err != nil {
+		t.Fatal(err)
+	}
+	obj := env.store.objects["agents/a.md.age"]
+	obj.lastModified = time.Now().Add(-30 * 24 * time.Hour)
+	env.store.objects["agents/a.md.age"] = obj
+
+	orphans, err := env.syncer.GC(ctx, 0, true)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if len(orphans) != 0 {
+		t.Errorf("Expected manifest files to be kept, got %+v", orphans)
+	}
+}
+
+func TestGCKeepsOtherDevicesFiles(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	other := sharedBucketEnv(t, env)
+	other.syncer.state.DeviceID = "other"
+
+	writeFile(t, other.claudeDir, "agents/b.md", "agent b")
+	if _, err := other.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	writeFile(t, env.claudeDir, "agents/a.md", "agent a")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	// A manifest written by a device that never saw b.md
+	manifest, err := env.syncer.downloadManifest(ctx)
+	if err != nil || manifest == nil {
+		t.Fatalf("downloadManifest = %v, %v", manifest, err)
+	}
+	delete(manifest.Files, "agents/b.md")
+	if err := env.syncer.uploadJSON(ctx, ManifestKey+".age", manifest); err != nil {
+		t.Fatal(err)
+	}
+	obj := env.store.objects["agents/b.md.age"]
+	obj.lastModified = time.Now().Add(-30 * 24 * time.Hour)
+	env.store.objects["agents/b.md.age"] = obj
+
+	orphans, err := env.syncer.GC(ctx, 0, true)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if len(orphans) != 0 {
+		t.Errorf("Expected the other device's files to be kept, got %+v", orphans)
+	}
+}
+
+func TestGCFindsLegacyDuplicates(t *testing.T) {
+	syncer, store, claudeDir := testSyncer(t)
+	ctx := context.Background()
+
+	relPath := "projects/-Users-alice-my-app/sess.jsonl"
+	if err := os.MkdirAll(filepath.Join(claudeDir, filepath.Dir(relPath)), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(claudeDir, relPath), []byte(`{}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	syncer.paths = mustMapper(t, "/Users/alice", nil)
+	if _, err := syncer.Push(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Left behind by a version that pushed identity keys
+	if err := store.Upload(ctx, relPath+".age", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+
+	orphans, err := syncer.GC(ctx, 7*24*time.Hour, true)
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if len(orphans) != 1 || orphans[0].Key != relPath+".age" {
+		t.Fatalf("Expected the legacy key as an orphan, got %+v", orphans)
+	}
+}
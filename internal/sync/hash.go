@@ -0,0 +1,145 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/zeebo/blake3"
+)
+
+// Hashes are stored as hex. SHA-256 hashes are bare, as they always have
+// been; other algorithms are tagged "<algorithm>:<hex>" so a hash records how
+// it was made, and hashes from devices with different settings are never
+// mistaken for each other.
+
+func newHasher(algorithm string) hash.Hash {
+	if algorithm == config.HashBLAKE3 {
+		return blake3.New()
+	}
+	return sha256.New()
+}
+
+func formatHash(algorithm string, sum []byte) string {
+	if algorithm == config.HashSHA256 || algorithm == "" {
+		return hex.EncodeToString(sum)
+	}
+	return algorithm + ":" + hex.EncodeToString(sum)
+}
+
+// hashAlgorithmOf returns the algorithm that produced a stored hash.
+func hashAlgorithmOf(h string) string {
+	if algorithm, _, ok := strings.Cut(h, ":"); ok {
+		return algorithm
+	}
+	return config.HashSHA256
+}
+
+// ShortHash abbreviates a stored hash for display: the first 12 hex digits,
+// without the algorithm tag.
+func ShortHash(h string) string {
+	if _, digits, ok := strings.Cut(h, ":"); ok {
+		h = digits
+	}
+	if len(h) > 12 {
+		return h[:12]
+	}
+	return h
+}
+
+// hashFileWith hashes a file with the given algorithm.
+func hashFileWith(algorithm, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := newHasher(algorithm)
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return formatHash(algorithm, h.Sum(nil)), nil
+}
+
+func hashBytesWith(algorithm string, data []byte) string {
+	h := newHasher(algorithm)
+	h.Write(data)
+	return formatHash(algorithm, h.Sum(nil))
+}
+
+// hashAlgorithm returns the algorithm this state's hashes are made with.
+func (s *SyncState) hashAlgorithm() string {
+	if s.HashAlgorithm == "" {
+		return config.HashSHA256
+	}
+	return s.HashAlgorithm
+}
+
+// hashFile hashes a file the way this state's hashes are made.
+func (s *SyncState) hashFile(path string) (string, error) {
+	return hashFileWith(s.hashAlgorithm(), path)
+}
+
+// hashBytes returns the same digest hashFile would for a file with this content.
+func (s *SyncState) hashBytes(data []byte) string {
+	return hashBytesWith(s.hashAlgorithm(), data)
+}
+
+// MigrateHashes switches the state to algorithm, re-hashing tracked files
+// under claudeDir. A file is only re-hashed if it still matches its recorded
+// hash; files changed since the last sync keep the old hash, so they are
+// still detected as changed. It returns how many hashes were rewritten.
+func (s *SyncState) MigrateHashes(claudeDir, algorithm string) (int, error) {
+	if s.hashAlgorithm() == algorithm {
+		return 0, nil
+	}
+
+	s.mu.Lock()
+	files := make(map[string]*FileState, len(s.Files))
+	for path, fs := range s.Files {
+		files[path] = fs
+	}
+	s.mu.Unlock()
+
+	migrated := 0
+	for relPath, fs := range files {
+		old := hashAlgorithmOf(fs.Hash)
+		if old == algorithm {
+			continue
+		}
+		fullPath := filepath.Join(claudeDir, filepath.FromSlash(relPath))
+		current, err := hashFileWith(old, fullPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return migrated, fmt.Errorf("failed to hash %s: %w", relPath, err)
+		}
+		if current != fs.Hash {
+			continue
+		}
+		rehashed, err := hashFileWith(algorithm, fullPath)
+		if err != nil {
+			return migrated, fmt.Errorf("failed to hash %s: %w", relPath, err)
+		}
+		s.mu.Lock()
+		fs.Hash = rehashed
+		s.mu.Unlock()
+		migrated++
+	}
+
+	s.mu.Lock()
+	s.HashAlgorithm = algorithm
+	if algorithm == config.HashSHA256 {
+		s.HashAlgorithm = ""
+	}
+	s.mu.Unlock()
+	return migrated, nil
+}
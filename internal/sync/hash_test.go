@@ -0,0 +1,76 @@
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+)
+
+func TestHashTags(t *testing.T) {
+	sha := hashBytesWith(config.HashSHA256, []byte("hello"))
+	if sha != hashBytes([]byte("hello")) || strings.Contains(sha, ":") {
+		t.Errorf("Expected untagged SHA-256 hash, got %q", sha)
+	}
+	b3 := hashBytesWith(config.HashBLAKE3, []byte("hello"))
+	if !strings.HasPrefix(b3, "blake3:") || len(b3) != len("blake3:")+64 {
+		t.Errorf("Expected tagged BLAKE3 hash, got %q", b3)
+	}
+	if hashAlgorithmOf(sha) != config.HashSHA256 || hashAlgorithmOf(b3) != config.HashBLAKE3 {
+		t.Errorf("Wrong algorithms for %q / %q", sha, b3)
+	}
+	if got := ShortHash(b3); got != b3[len("blake3:"):len("blake3:")+12] {
+		t.Errorf("ShortHash(%q) = %q", b3, got)
+	}
+	if got := ShortHash(sha); got != sha[:12] {
+		t.Errorf("ShortHash(%q) = %q", sha, got)
+	}
+}
+
+func TestMigrateHashes(t *testing.T) {
+	env := setupTestEnv(t)
+	writeFile(t, env.claudeDir, "CLAUDE.md", "instructions")
+	writeFile(t, env.claudeDir, "agents/a.md", "agent")
+	if _, err := env.syncer.Push(context.Background()); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	// Edited after the last push: must still show up as a change
+	writeFile(t, env.claudeDir, "agents/a.md", "agent v2")
+
+	migrated, err := env.syncer.state.MigrateHashes(env.claudeDir, config.HashBLAKE3)
+	if err != nil {
+		t.Fatalf("MigrateHashes failed: %v", err)
+	}
+	if migrated != 1 {
+		t.Errorf("Expected 1 migrated hash, got %d", migrated)
+	}
+	if env.syncer.state.HashAlgorithm != config.HashBLAKE3 {
+		t.Errorf("Expected state algorithm blake3, got %q", env.syncer.state.HashAlgorithm)
+	}
+	if h := env.syncer.state.GetFile("CLAUDE.md").Hash; !strings.HasPrefix(h, "blake3:") {
+		t.Errorf("Expected CLAUDE.md to be re-hashed, got %q", h)
+	}
+
+	changes, err := env.syncer.state.DetectChanges(env.claudeDir, env.syncer.syncPaths())
+	if err != nil {
+		t.Fatalf("DetectChanges failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Path != "agents/a.md" || changes[0].Action != "modify" {
+		t.Errorf("Expected only agents/a.md modified, got %+v", changes)
+	}
+
+	// Back to the default: hashes become bare SHA-256 again
+	if _, err := env.syncer.state.MigrateHashes(env.claudeDir, config.HashSHA256); err != nil {
+		t.Fatalf("MigrateHashes back failed: %v", err)
+	}
+	if env.syncer.state.HashAlgorithm != "" {
+		t.Errorf("Expected empty algorithm for sha256, got %q", env.syncer.state.HashAlgorithm)
+	}
+	want, _ := HashFile(filepath.Join(env.claudeDir, "CLAUDE.md"))
+	if got := env.syncer.state.GetFile("CLAUDE.md").Hash; got != want {
+		t.Errorf("Expected SHA-256 hash %q, got %q", want, got)
+	}
+}
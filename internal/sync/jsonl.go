@@ -0,0 +1,86 @@
+package sync
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+)
+
+// JSONLIssue reports lines of a pulled .jsonl file that aren't valid JSON.
+// Claude Code reads history and session files line by line, and a line torn
+// by an interrupted write breaks loading on every device that pulls it.
+type JSONLIssue struct {
+	Path     string `json:"path"`
+	BadLines []int  `json:"bad_lines"` // 1-based numbers of the lines that don't parse
+
+	// Quarantined is the path of the file the invalid trailing lines were
+	// moved to, when repair_jsonl is set. Invalid lines before the last valid one are
+	// only reported: removing them could lose real history.
+	Quarantined string `json:"quarantined,omitempty"`
+}
+
+// isJSONLPath reports whether a synced file is line-delimited JSON.
+func isJSONLPath(relativePath string) bool {
+	return strings.HasSuffix(relativePath, ".jsonl")
+}
+
+// validateJSONL returns the 1-based numbers of non-blank lines that aren't
+// valid JSON, and the offset where a trailing run of such lines starts (just
+// after the last valid line), or len(data) when the file ends cleanly.
+func validateJSONL(data []byte) (bad []int, tail int) {
+	lastValid, validEnd := 0, 0
+	for start, lineNo := 0, 1; start < len(data); lineNo++ {
+		end, next := len(data), len(data)
+		if i := bytes.IndexByte(data[start:], '\n'); i >= 0 {
+			end, next = start+i, start+i+1
+		}
+
+		line := bytes.TrimSpace(data[start:end])
+		switch {
+		case len(line) == 0:
+		case json.Valid(line):
+			lastValid, validEnd = lineNo, next
+		default:
+			bad = append(bad, lineNo)
+		}
+		start = next
+	}
+
+	if len(bad) > 0 && bad[len(bad)-1] > lastValid {
+		return bad, validEnd
+	}
+	return bad, len(data)
+}
+
+// checkJSONL validates a pulled .jsonl file before it is written. With
+// repair_jsonl set, a corrupt tail is cut off and kept as
+// <path>.corrupt.<timestamp> under the quarantine directory next to the state
+// file rather than discarded; kept next to the file, it would be pushed.
+func (s *Syncer) checkJSONL(relativePath string, data []byte) ([]byte, *JSONLIssue, error) {
+	bad, tail := validateJSONL(data)
+	if len(bad) == 0 {
+		return data, nil, nil
+	}
+
+	issue := &JSONLIssue{Path: relativePath, BadLines: bad}
+	if !s.cfg.RepairJSONL || tail == len(data) {
+		return data, issue, nil
+	}
+
+	quarantine := filepath.Join(filepath.Dir(s.state.path()), config.QuarantineDir,
+		fmt.Sprintf("%s.corrupt.%s", filepath.FromSlash(relativePath), time.Now().Format("20060102-150405")))
+	if err := os.MkdirAll(filepath.Dir(quarantine), 0700); err != nil {
+		return nil, nil, fmt.Errorf("failed to quarantine corrupt lines: %w", err)
+	}
+	if err := os.WriteFile(quarantine, data[tail:], 0600); err != nil {
+		return nil, nil, fmt.Errorf("failed to quarantine corrupt lines: %w", err)
+	}
+	issue.Quarantined = quarantine
+	return data[:tail], issue, nil
+}
@@ -0,0 +1,107 @@
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateJSONL(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantBad  []int
+		wantTail int
+	}{
+		{"clean", "{\"a\":1}\n{\"b\":2}\n", nil, 16},
+		{"empty", "", nil, 0},
+		{"blank lines", "{\"a\":1}\n\n{\"b\":2}\n", nil, 17},
+		{"torn last line", "{\"a\":1}\n{\"b\":", []int{2}, 8},
+		{"torn tail with blank", "{\"a\":1}\n{\"b\n\n{\"c", []int{2, 4}, 8},
+		{"corrupt middle", "{\"a\":1}\nnope\n{\"b\":2}\n", []int{2}, 21},
+		{"all corrupt", "nope\n", []int{1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bad, tail := validateJSONL([]byte(tt.data))
+			if !reflect.DeepEqual(bad, tt.wantBad) {
+				t.Errorf("bad lines = %v, want %v", bad, tt.wantBad)
+			}
+			if tail != tt.wantTail {
+				t.Errorf("tail = %d, want %d", tail, tt.wantTail)
+			}
+		})
+	}
+}
+
+func TestPullReportsInvalidJSONL(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	uploadRemote(t, env, "projects/p/s.jsonl", "{\"a\":1}\n{\"b\":")
+
+	result, err := env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.InvalidJSONL) != 1 || result.InvalidJSONL[0].Quarantined != "" {
+		t.Fatalf("Expected one unrepaired issue, got %+v", result.InvalidJSONL)
+	}
+	// Without repair_jsonl the file is written as pulled
+	if got := readFile(t, env.claudeDir, "projects/p/s.jsonl"); got != "{\"a\":1}\n{\"b\":" {
+		t.Errorf("Expected file untouched, got %q", got)
+	}
+}
+
+func TestPullRepairsJSONL(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.RepairJSONL = true
+	ctx := context.Background()
+
+	uploadRemote(t, env, "history.jsonl", "{\"a\":1}\n{\"b\":")
+
+	result, err := env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.InvalidJSONL) != 1 {
+		t.Fatalf("Expected one issue, got %+v", result.InvalidJSONL)
+	}
+	issue := result.InvalidJSONL[0]
+	if !strings.HasPrefix(issue.Quarantined, filepath.Join(env.stateDir, "quarantine", "history.jsonl.corrupt.")) {
+		t.Fatalf("Expected quarantine file in the state directory, got %q", issue.Quarantined)
+	}
+	if got := readFile(t, env.claudeDir, "history.jsonl"); got != "{\"a\":1}\n" {
+		t.Errorf("Expected corrupt tail removed, got %q", got)
+	}
+	if got := readFile(t, filepath.Dir(issue.Quarantined), filepath.Base(issue.Quarantined)); got != "{\"b\":" {
+		t.Errorf("Expected quarantined tail, got %q", got)
+	}
+
+	// The repaired file is pushed next, so other devices get the fix
+	changes, err := env.syncer.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Path != "history.jsonl" || changes[0].Action != "modify" {
+		t.Errorf("Expected only the repaired history.jsonl pending push, got %+v", changes)
+	}
+}
+
+// uploadRemote stores content as another device's push would.
+func uploadRemote(t *testing.T, env *testEnv, relPath, content string) {
+	t.Helper()
+	compressed, err := gzipCompress([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := env.syncer.encryptor.Encrypt(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.store.Upload(context.Background(), relPath+".age", encrypted); err != nil {
+		t.Fatal(err)
+	}
+}
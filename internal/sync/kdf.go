@@ -0,0 +1,110 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// KDFParamsKey holds the Argon2id parameters passphrase keys for the bucket
+// are derived with. It is stored unencrypted: a device needs it before it
+// has a key, and a salt is not secret.
+const KDFParamsKey = "_metadata/kdf.json"
+
+// LoadKDFParams reads the bucket's KDF parameters, or returns nil when it has
+// none. hasData reports whether the bucket holds encrypted files outside the
+// trash; without parameters, those were made with crypto.DefaultKDFParams.
+func LoadKDFParams(ctx context.Context, store storage.Storage) (params *crypto.KDFParams, hasData bool, err error) {
+	objects, err := store.List(ctx, "")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list remote files: %w", err)
+	}
+
+	found := false
+	for _, obj := range objects {
+		switch {
+		case obj.Key == KDFParamsKey:
+			found = true
+		case strings.HasSuffix(obj.Key, ".age") && !strings.HasPrefix(obj.Key, TrashPrefix):
+			hasData = true
+		}
+	}
+	if !found {
+		return nil, hasData, nil
+	}
+
+	data, err := store.Download(ctx, KDFParamsKey)
+	if err != nil {
+		return nil, hasData, fmt.Errorf("failed to download KDF parameters: %w", err)
+	}
+	var p crypto.KDFParams
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, hasData, fmt.Errorf("failed to read KDF parameters: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		var unsupported *crypto.UnsupportedKDFError
+		if errors.As(err, &unsupported) {
+			return nil, hasData, err
+		}
+		return nil, hasData, fmt.Errorf("bucket has invalid KDF parameters: %w", err)
+	}
+	p.Version = p.SchemeVersion()
+	return &p, hasData, nil
+}
+
+// SaveKDFParams stores params with the bucket for other devices to derive
+// their keys with. The derivation version is always written, so a device
+// whose claude-sync predates a later scheme can tell.
+func SaveKDFParams(ctx context.Context, store storage.Storage, params crypto.KDFParams) error {
+	params.Version = params.SchemeVersion()
+	data, err := json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := store.Upload(ctx, KDFParamsKey, data); err != nil {
+		return fmt.Errorf("failed to upload KDF parameters: %w", err)
+	}
+	return nil
+}
+
+// ResolveKDFParams picks the parameters to derive a passphrase key with for
+// the bucket. The bucket's stored parameters win (a
+// crypto.UnsupportedKDFError when a newer claude-sync wrote them); a bucket
+// with data but no parameters keeps the legacy defaults; an empty bucket
+// gets a random salt and the requested costs (zero for the default). stored reports whether the
+// parameters came from the bucket, so the caller knows to save new ones.
+//
+// Costs can't be changed on a bucket that already has data, since every
+// device has to derive the same key: that takes 'rekey --passphrase'.
+func ResolveKDFParams(ctx context.Context, store storage.Storage, memoryMiB, time uint32, threads uint8) (params crypto.KDFParams, stored bool, err error) {
+	remote, hasData, err := LoadKDFParams(ctx, store)
+	if err != nil {
+		return crypto.KDFParams{}, false, err
+	}
+	requested := memoryMiB != 0 || time != 0 || threads != 0
+
+	switch {
+	case remote != nil:
+		if requested {
+			return crypto.KDFParams{}, false, fmt.Errorf("the bucket already has KDF parameters (%s); change them with 'claude-sync rekey --passphrase'", remote)
+		}
+		return *remote, true, nil
+	case hasData:
+		if requested {
+			return crypto.KDFParams{}, false, fmt.Errorf("the bucket's files use the default KDF parameters; change them with 'claude-sync rekey --passphrase'")
+		}
+		return crypto.DefaultKDFParams(), false, nil
+	}
+	params, err = crypto.NewKDFParams(memoryMiB, time, threads)
+	return params, false, err
+}
+
+// SaveKDFParams stores params with the syncer's bucket; see SaveKDFParams.
+func (s *Syncer) SaveKDFParams(ctx context.Context, params crypto.KDFParams) error {
+	return SaveKDFParams(ctx, s.storage, params)
+}
@@ -0,0 +1,124 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+)
+
+func TestResolveKDFParams(t *testing.T) {
+	ctx := context.Background()
+
+	// An empty bucket gets a new random salt
+	store := newMockStorage()
+	params, stored, err := ResolveKDFParams(ctx, store, 16, 0, 0)
+	if err != nil {
+		t.Fatalf("ResolveKDFParams failed: %v", err)
+	}
+	if stored || params.IsLegacy() || params.MemoryKiB != 16*1024 || params.Time != 3 {
+		t.Errorf("Expected new params with 16 MiB, got %+v (stored %v)", params, stored)
+	}
+
+	// Once saved, every device gets the same params
+	if err := SaveKDFParams(ctx, store, params); err != nil {
+		t.Fatalf("SaveKDFParams failed: %v", err)
+	}
+	again, stored, err := ResolveKDFParams(ctx, store, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("ResolveKDFParams failed: %v", err)
+	}
+	if !stored || again != params {
+		t.Errorf("Expected the stored params, got %+v (stored %v)", again, stored)
+	}
+	if _, _, err := ResolveKDFParams(ctx, store, 32, 0, 0); err == nil {
+		t.Error("Expected an error changing costs on a bucket with params")
+	}
+}
+
+func TestResolveKDFParamsLegacyBucket(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStorage()
+	if err := store.Upload(ctx, "CLAUDE.md.age", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	params, stored, err := ResolveKDFParams(ctx, store, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("ResolveKDFParams failed: %v", err)
+	}
+	if stored || params != crypto.DefaultKDFParams() {
+		t.Errorf("Expected the legacy defaults, got %+v", params)
+	}
+	if _, _, err := ResolveKDFParams(ctx, store, 32, 0, 0); err == nil {
+		t.Error("Expected an error changing costs on a bucket with data")
+	}
+
+	// Trashed files don't count as data
+	trashOnly := newMockStorage()
+	if err := trashOnly.Upload(ctx, TrashPrefix+"20260101-000000/CLAUDE.md.age", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if params, _, err := ResolveKDFParams(ctx, trashOnly, 0, 0, 0); err != nil || params.IsLegacy() {
+		t.Errorf("Expected new params for a bucket with only trash, got %+v, %v", params, err)
+	}
+}
+
+func TestRekeySkipsKDFParams(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# hi")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	params, err := crypto.NewKDFParams(8, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.syncer.SaveKDFParams(ctx, params); err != nil {
+		t.Fatalf("SaveKDFParams failed: %v", err)
+	}
+
+	result, err := env.syncer.Rekey(ctx, newTestEncryptor(t))
+	if err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+	if len(result.Failed) != 0 {
+		t.Errorf("Expected the plaintext KDF params to be skipped, got failures %v", result.Failed)
+	}
+}
+
+func TestKDFParamsVersion(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStorage()
+
+	// Buckets set up before versions were recorded read as version 1
+	if err := store.Upload(ctx, KDFParamsKey, []byte(`{"time":3,"memory_kib":65536,"threads":4}`)); err != nil {
+		t.Fatal(err)
+	}
+	params, stored, err := ResolveKDFParams(ctx, store, 0, 0, 0)
+	if err != nil || !stored || params != crypto.DefaultKDFParams() {
+		t.Fatalf("Expected the legacy defaults, got %+v (stored %v, %v)", params, stored, err)
+	}
+
+	// The version is always written
+	if err := SaveKDFParams(ctx, store, crypto.KDFParams{Time: 3, MemoryKiB: 65536, Threads: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if data := store.objects[KDFParamsKey].data; !strings.Contains(string(data), `"version": 1`) {
+		t.Errorf("Expected the version saved, got %s", data)
+	}
+
+	// A newer scheme is refused rather than deriving the wrong key
+	if err := store.Upload(ctx, KDFParamsKey, []byte(`{"time":3,"memory_kib":65536,"threads":4,"version":99}`)); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = ResolveKDFParams(ctx, store, 0, 0, 0)
+	var unsupported *crypto.UnsupportedKDFError
+	if !errors.As(err, &unsupported) || unsupported.Version != 99 {
+		t.Errorf("Expected UnsupportedKDFError, got %v", err)
+	}
+}
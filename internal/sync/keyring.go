@@ -0,0 +1,154 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// KeyringKey holds the bucket's keyring: the random data key files are
+// encrypted to, itself encrypted to the key at encryption_key_path (usually
+// passphrase-derived). Changing that key only rewrites the keyring.
+const KeyringKey = "_metadata/keyring.age"
+
+// ErrKeyringLocked is returned when a key doesn't open the bucket's keyring.
+var ErrKeyringLocked = errors.New("key_mismatch: the key doesn't open the bucket's keyring")
+
+// Keyring is the decrypted keyring.
+type Keyring struct {
+	DataKey string `json:"data_key"`
+}
+
+// DataEncryptor returns an encryptor for the data key, also encrypting to
+// recipients.
+func (k *Keyring) DataEncryptor(recipients []string) (*crypto.Encryptor, error) {
+	enc, err := crypto.NewEncryptorFromIdentity(k.DataKey, recipients)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key in keyring: %w", err)
+	}
+	return enc, nil
+}
+
+// LoadKeyring downloads the keyring and opens it with wrap. It returns nil
+// when the bucket has none.
+func LoadKeyring(ctx context.Context, store storage.Storage, wrap crypto.Cipher) (*Keyring, error) {
+	objects, err := store.List(ctx, KeyringKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote files: %w", err)
+	}
+	found := false
+	for _, obj := range objects {
+		found = found || obj.Key == KeyringKey
+	}
+	if !found {
+		return nil, nil
+	}
+	encrypted, err := store.Download(ctx, KeyringKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download keyring: %w", err)
+	}
+	data, err := wrap.Decrypt(encrypted)
+	if err != nil {
+		return nil, ErrKeyringLocked
+	}
+	var k Keyring
+	if err := json.Unmarshal(data, &k); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring: %w", err)
+	}
+	return &k, nil
+}
+
+// UnlockKeyring returns an encryptor for the bucket's data key, read from
+// the keyring with wrap.
+func UnlockKeyring(ctx context.Context, store storage.Storage, wrap crypto.Cipher, recipients []string) (*crypto.Encryptor, error) {
+	k, err := LoadKeyring(ctx, store, wrap)
+	if err != nil {
+		return nil, err
+	}
+	if k == nil {
+		return nil, errors.New("keyring is set but the bucket has no keyring; run 'claude-sync key keyring' on a device with the bucket's key")
+	}
+	return k.DataEncryptor(recipients)
+}
+
+// saveKeyring encrypts k with wrap and uploads it.
+func saveKeyring(ctx context.Context, store storage.Storage, k *Keyring, wrap crypto.Cipher) error {
+	data, err := json.Marshal(k)
+	if err != nil {
+		return err
+	}
+	encrypted, err := wrap.Encrypt(data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt keyring: %w", err)
+	}
+	if err := store.Upload(ctx, KeyringKey, encrypted); err != nil {
+		return fmt.Errorf("failed to upload keyring: %w", err)
+	}
+	return nil
+}
+
+// EnableKeyring moves the bucket to a random data key kept in a keyring
+// encrypted to the current key, re-encrypting every object once (see
+// Rekey). A keyring the current key opens is reused, so an interrupted run
+// carries on where it stopped. The caller sets keyring in the config once
+// Failed is empty.
+func (s *Syncer) EnableKeyring(ctx context.Context) (*RekeyResult, error) {
+	if s.cfg.Keyring {
+		return nil, errors.New("the keyring is already enabled")
+	}
+	if s.device != nil {
+		return nil, errors.New("device keys already keep the data key in the device registry")
+	}
+	wrap := s.encryptor
+	k, err := LoadKeyring(ctx, s.storage, wrap)
+	if err != nil {
+		return nil, err
+	}
+	if k == nil {
+		secret, err := crypto.GenerateIdentity()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate data key: %w", err)
+		}
+		k = &Keyring{DataKey: secret}
+		if err := saveKeyring(ctx, s.storage, k, wrap); err != nil {
+			return nil, err
+		}
+	}
+	dataEnc, err := k.DataEncryptor(s.cfg.Recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.Rekey(ctx, dataEnc)
+	if err != nil || len(result.Failed) > 0 {
+		return result, err
+	}
+	s.encryptor = dataEnc
+	if s.keyService != nil {
+		s.encryptor = dataEnc.WithKeyService(s.keyService)
+	}
+	s.state.KeyFingerprint = ""
+	return result, nil
+}
+
+// RewrapKeyring re-encrypts the keyring to wrap, such as a key derived from
+// a new passphrase. Files stay encrypted to the data key, so nothing else
+// is uploaded.
+func (s *Syncer) RewrapKeyring(ctx context.Context, wrap crypto.Cipher) error {
+	if !s.cfg.Keyring {
+		return errors.New("the keyring isn't enabled; run 'claude-sync key keyring' first")
+	}
+	dataEnc := ageCipher(s.encryptor)
+	if dataEnc == nil {
+		return errors.New("the keyring needs the age encryption scheme")
+	}
+	secret, err := dataEnc.SecretKey()
+	if err != nil {
+		return err
+	}
+	return saveKeyring(ctx, s.storage, &Keyring{DataKey: secret}, wrap)
+}
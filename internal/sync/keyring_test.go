@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestKeyringRewrapLeavesFiles(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	pushOK(t, env)
+
+	wrap := env.syncer.encryptor
+	result, err := env.syncer.EnableKeyring(ctx)
+	if err != nil || len(result.Failed) > 0 {
+		t.Fatalf("EnableKeyring = %+v, %v", result, err)
+	}
+	if result.Rekeyed == 0 {
+		t.Error("Expected objects re-encrypted to the data key")
+	}
+	if _, err := wrap.Decrypt(env.store.objects["CLAUDE.md.age"].data); err == nil {
+		t.Error("Expected files no longer encrypted to the wrapping key")
+	}
+
+	// Run again with the wrapping key, as after an interruption
+	again := sharedBucketEnv(t, env)
+	again.syncer.encryptor = wrap
+	if result, err := again.syncer.EnableKeyring(ctx); err != nil || result.Rekeyed != 0 || result.AlreadyNew == 0 {
+		t.Fatalf("Expected a second EnableKeyring to reuse the keyring, got %+v, %v", result, err)
+	}
+	env.syncer.cfg.Keyring = true
+	if _, err := env.syncer.EnableKeyring(ctx); err == nil {
+		t.Error("Expected EnableKeyring refused once enabled")
+	}
+
+	// A device with the wrapping key gets the data key from the keyring
+	other := sharedBucketEnv(t, env)
+	other.syncer.cfg.Keyring = true
+	if other.syncer.encryptor, err = UnlockKeyring(ctx, env.store, wrap, nil); err != nil {
+		t.Fatalf("UnlockKeyring failed: %v", err)
+	}
+	if _, err := other.syncer.Pull(ctx); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if readFile(t, other.claudeDir, "CLAUDE.md") != "rules" {
+		t.Error("Expected the file pulled")
+	}
+
+	// A new passphrase only rewrites the keyring
+	before := make(map[string][]byte)
+	for key, obj := range env.store.objects {
+		before[key] = obj.data
+	}
+	newWrap := newTestEncryptor(t)
+	if err := env.syncer.RewrapKeyring(ctx, newWrap); err != nil {
+		t.Fatalf("RewrapKeyring failed: %v", err)
+	}
+	for key, obj := range env.store.objects {
+		if key != KeyringKey && !bytes.Equal(before[key], obj.data) {
+			t.Errorf("Expected %s untouched by the rewrap", key)
+		}
+	}
+	if _, err := UnlockKeyring(ctx, env.store, wrap, nil); !errors.Is(err, ErrKeyringLocked) {
+		t.Errorf("Expected the old key locked out, got %v", err)
+	}
+	dataEnc, err := UnlockKeyring(ctx, env.store, newWrap, nil)
+	if err != nil {
+		t.Fatalf("UnlockKeyring with the new key failed: %v", err)
+	}
+	if dataEnc.KeyID() != env.syncer.encryptor.KeyID() {
+		t.Error("Expected the same data key behind the new key")
+	}
+	if remote, err := LoadFingerprint(ctx, env.store); err != nil || remote != dataEnc.KeyID() {
+		t.Errorf("Expected the bucket fingerprint to be the data key's, got %q (%v)", remote, err)
+	}
+}
+
+func TestUnlockKeyringMissing(t *testing.T) {
+	env := setupTestEnv(t)
+	if _, err := UnlockKeyring(context.Background(), env.store, env.syncer.encryptor, nil); err == nil {
+		t.Error("Expected an error for a bucket without a keyring")
+	}
+	if err := env.syncer.RewrapKeyring(context.Background(), newTestEncryptor(t)); err == nil {
+		t.Error("Expected RewrapKeyring refused without the keyring enabled")
+	}
+}
@@ -0,0 +1,283 @@
+package sync
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// LeasePrefix is the remote prefix holding push leases. A lease claims one
+// top-level area of ~/.claude (projects, agents, CLAUDE.md, ...) for one
+// device's push, so two devices can push disjoint areas at the same time but
+// not interleave writes to the same one.
+const LeasePrefix = "_locks/"
+
+// leaseTTL bounds how long a crashed push can hold an area.
+const leaseTTL = 10 * time.Minute
+
+// Lease is a device's claim on an area while it pushes.
+type Lease struct {
+	Area     string    `json:"area"`
+	Device   string    `json:"device"`
+	Token    string    `json:"token"`
+	Acquired time.Time `json:"acquired"`
+	Expires  time.Time `json:"expires"`
+}
+
+// LeaseHeldError is returned when another device holds a lease a push needs.
+type LeaseHeldError struct {
+	Lease Lease
+}
+
+func (e *LeaseHeldError) Error() string {
+	return fmt.Sprintf("%s is being pushed by %s (lease expires in %s); try again shortly",
+		e.Lease.Area, e.Lease.Device, time.Until(e.Lease.Expires).Round(time.Second))
+}
+
+// leaseArea returns the top-level area a synced path belongs to.
+func leaseArea(relativePath string) string {
+	area, _, _ := strings.Cut(relativePath, "/")
+	return area
+}
+
+func leaseKey(area string) string {
+	return LeasePrefix + area + ".json.age"
+}
+
+// manifestLeaseArea is the lease a push holds while it writes the manifest,
+// which every area's push rewrites. No top-level area of ~/.claude starts
+// with an underscore.
+const manifestLeaseArea = "_manifest"
+
+// leaseRenewInterval is how often a push renews the leases it holds, well
+// within leaseTTL so a long push keeps them.
+var leaseRenewInterval = leaseTTL / 3
+
+// manifestLeaseWait bounds how long a push waits for another device to
+// finish writing the manifest.
+var manifestLeaseWait = 30 * time.Second
+
+// acquireLeases claims the areas touched by changes, in sorted order. Each
+// lease is written on the condition that the lease object is still what was
+// read (see storage.ConditionalUploader), so of two devices taking an area
+// at once one gets LeaseHeldError. Stores without conditional uploads get
+// each lease read back after writing instead, and a push that lost the race
+// backs off. On error, leases taken so far are released.
+func (s *Syncer) acquireLeases(ctx context.Context, changes []FileChange) ([]Lease, error) {
+	areaSet := make(map[string]bool)
+	for _, c := range changes {
+		areaSet[leaseArea(c.Path)] = true
+	}
+	areas := make([]string, 0, len(areaSet))
+	for area := range areaSet {
+		areas = append(areas, area)
+	}
+	sort.Strings(areas)
+	return s.takeLeases(ctx, areas)
+}
+
+func (s *Syncer) takeLeases(ctx context.Context, areas []string) ([]Lease, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to create lease token: %w", err)
+	}
+
+	var acquired, unconfirmed []Lease
+	fail := func(err error) ([]Lease, error) {
+		s.releaseLeases(ctx, acquired)
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, area := range areas {
+		var other Lease
+		etag, found, err := s.downloadJSONVersion(ctx, leaseKey(area), &other)
+		if err != nil {
+			return fail(fmt.Errorf("failed to read lease on %s: %w", area, err))
+		}
+		if found && other.Device != s.state.DeviceID && now.Before(other.Expires) {
+			return fail(&LeaseHeldError{Lease: other})
+		}
+
+		lease := Lease{
+			Area:     area,
+			Device:   s.state.DeviceID,
+			Token:    hex.EncodeToString(token),
+			Acquired: now,
+			Expires:  now.Add(leaseTTL),
+		}
+		conditional, err := s.putLease(ctx, lease, etag, found)
+		if errors.Is(err, storage.ErrPreconditionFailed) {
+			return fail(s.leaseHeld(ctx, area))
+		}
+		if err != nil {
+			return fail(fmt.Errorf("failed to take lease on %s: %w", area, err))
+		}
+		acquired = append(acquired, lease)
+		if !conditional {
+			unconfirmed = append(unconfirmed, lease)
+		}
+	}
+
+	// Read back what was written unconditionally: if another device wrote
+	// after us, it won and we back off
+	for _, lease := range unconfirmed {
+		var current Lease
+		if err := s.downloadJSON(ctx, leaseKey(lease.Area), &current); err != nil {
+			return fail(fmt.Errorf("failed to confirm lease on %s: %w", lease.Area, err))
+		}
+		if current.Token != lease.Token {
+			acquired = removeLease(acquired, lease.Area)
+			return fail(&LeaseHeldError{Lease: current})
+		}
+	}
+	return acquired, nil
+}
+
+// putLease writes lease over the lease object read with etag (found false:
+// none), conditionally where the store can. conditional reports whether it
+// could; if not, nothing stopped another device writing at the same time.
+func (s *Syncer) putLease(ctx context.Context, lease Lease, etag string, found bool) (conditional bool, err error) {
+	data, err := s.encodeJSON(lease)
+	if err != nil {
+		return false, err
+	}
+	if !found || etag != "" {
+		err = storage.UploadIfMatch(ctx, s.storage, leaseKey(lease.Area), data, etag)
+		if !errors.Is(err, storage.ErrConditionalUnsupported) {
+			return true, err
+		}
+	}
+	return false, s.storage.Upload(ctx, leaseKey(lease.Area), data)
+}
+
+// leaseHeld returns the LeaseHeldError for area, whose lease another device
+// just took.
+func (s *Syncer) leaseHeld(ctx context.Context, area string) error {
+	var current Lease
+	if err := s.downloadJSON(ctx, leaseKey(area), &current); err != nil {
+		return fmt.Errorf("lease on %s was taken by another device", area)
+	}
+	return &LeaseHeldError{Lease: current}
+}
+
+// renewLeases keeps leases from expiring while a push runs, extending each
+// every leaseRenewInterval. stop ends the renewals and returns an error if
+// a lease was lost meanwhile: renewing found another device's lease in its
+// place, so that device may have pushed to the area at the same time. stop
+// can be called more than once.
+func (s *Syncer) renewLeases(ctx context.Context, leases []Lease) (stop func() error) {
+	done := make(chan struct{})
+	var once sync.Once
+	var wg sync.WaitGroup
+	var lost error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(leaseRenewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			for i := range leases {
+				if err := s.renewLease(ctx, &leases[i]); err != nil && lost == nil {
+					lost = err
+				}
+			}
+		}
+	}()
+	return func() error {
+		once.Do(func() { close(done) })
+		wg.Wait()
+		return lost
+	}
+}
+
+// renewLease extends lease if it is still ours. A failed request is left
+// for the next renewal; a lease another device took is an error.
+func (s *Syncer) renewLease(ctx context.Context, lease *Lease) error {
+	var current Lease
+	etag, found, err := s.downloadJSONVersion(ctx, leaseKey(lease.Area), &current)
+	if err != nil {
+		return nil
+	}
+	if found && current.Token != lease.Token {
+		return fmt.Errorf("lease on %s was lost to %s during the push", lease.Area, current.Device)
+	}
+	renewed := *lease
+	renewed.Expires = time.Now().Add(leaseTTL)
+	if _, err := s.putLease(ctx, renewed, etag, found); err != nil {
+		if errors.Is(err, storage.ErrPreconditionFailed) {
+			return fmt.Errorf("lease on %s was lost during the push", lease.Area)
+		}
+		return nil
+	}
+	*lease = renewed
+	return nil
+}
+
+// withManifestLease runs write, a manifest write, holding the manifest
+// lease, so two devices that pushed different areas don't rewrite the
+// manifest at once. Another device's manifest write is waited out for up to
+// manifestLeaseWait.
+func (s *Syncer) withManifestLease(ctx context.Context, write func() error) error {
+	deadline := time.Now().Add(manifestLeaseWait)
+	for {
+		leases, err := s.takeLeases(ctx, []string{manifestLeaseArea})
+		var held *LeaseHeldError
+		if errors.As(err, &held) && time.Now().Before(deadline) {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(lockPollInterval):
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		defer s.releaseLeases(ctx, leases)
+		return write()
+	}
+}
+
+// releaseLeases deletes leases this push still holds. Failures are ignored:
+// the leases expire on their own. A lease another device has taken since is
+// left alone.
+func (s *Syncer) releaseLeases(ctx context.Context, leases []Lease) {
+	var keys []string
+	for _, lease := range leases {
+		var current Lease
+		if err := s.downloadJSON(ctx, leaseKey(lease.Area), &current); err == nil && current.Token != lease.Token {
+			continue
+		}
+		keys = append(keys, leaseKey(lease.Area))
+	}
+	if len(keys) == 0 {
+		return
+	}
+	_ = s.storage.DeleteBatch(ctx, keys)
+}
+
+func removeLease(leases []Lease, area string) []Lease {
+	out := leases[:0]
+	for _, lease := range leases {
+		if lease.Area != area {
+			out = append(out, lease)
+		}
+	}
+	return out
+}
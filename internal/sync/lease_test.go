@@ -0,0 +1,213 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func plantLease(t *testing.T, env *testEnv, area, device string, expires time.Time) {
+	t.Helper()
+	lease := Lease{Area: area, Device: device, Token: "other", Acquired: time.Now(), Expires: expires}
+	if err := env.syncer.uploadJSON(context.Background(), leaseKey(area), lease); err != nil {
+		t.Fatalf("Failed to plant lease: %v", err)
+	}
+}
+
+func TestPushLeasesDisjointAreas(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.PushLeases = true
+	env.syncer.state.DeviceID = "desktop"
+	ctx := context.Background()
+
+	// Another device is mid-push in agents/
+	plantLease(t, env, "agents", "laptop", time.Now().Add(leaseTTL))
+
+	writeFile(t, env.claudeDir, "projects/p1/session.jsonl", "{}\n")
+	result, err := env.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push of a disjoint area failed: %v", err)
+	}
+	if len(result.Uploaded) != 1 {
+		t.Fatalf("Expected 1 upload, got %d", len(result.Uploaded))
+	}
+	if _, ok := env.store.objects[leaseKey("projects")]; ok {
+		t.Error("Expected projects lease to be released after push")
+	}
+	if _, ok := env.store.objects[leaseKey("agents")]; !ok {
+		t.Error("Expected the other device's lease to be left alone")
+	}
+
+	writeFile(t, env.claudeDir, "agents/helper.md", "agent")
+	writeFile(t, env.claudeDir, "CLAUDE.md", "root")
+	_, err = env.syncer.Push(ctx)
+	var held *LeaseHeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("Expected LeaseHeldError, got %v", err)
+	}
+	if held.Lease.Device != "laptop" || held.Lease.Area != "agents" {
+		t.Errorf("Unexpected lease in error: %+v", held.Lease)
+	}
+	if _, ok := env.store.objects[env.syncer.remoteKey("agents/helper.md")]; ok {
+		t.Error("Expected nothing in agents/ to be uploaded while leased")
+	}
+	if _, ok := env.store.objects[leaseKey("CLAUDE.md")]; ok {
+		t.Error("Expected leases taken before the conflict to be released")
+	}
+}
+
+func TestPushLeasesExpiredAndOwn(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.PushLeases = true
+	env.syncer.state.DeviceID = "desktop"
+
+	// A crashed push from another device, and a stale lease of our own
+	plantLease(t, env, "agents", "laptop", time.Now().Add(-time.Minute))
+	plantLease(t, env, "projects", "desktop", time.Now().Add(leaseTTL))
+
+	writeFile(t, env.claudeDir, "agents/helper.md", "agent")
+	writeFile(t, env.claudeDir, "projects/p1/session.jsonl", "{}\n")
+	result, err := env.syncer.Push(context.Background())
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if len(result.Uploaded) != 2 {
+		t.Errorf("Expected 2 uploads, got %d", len(result.Uploaded))
+	}
+	for _, area := range []string{"agents", "projects"} {
+		if _, ok := env.store.objects[leaseKey(area)]; ok {
+			t.Errorf("Expected %s lease to be released", area)
+		}
+	}
+}
+
+func TestPushLeasesOffByDefault(t *testing.T) {
+	env := setupTestEnv(t)
+	plantLease(t, env, "agents", "someone-else", time.Now().Add(leaseTTL))
+
+	writeFile(t, env.claudeDir, "agents/helper.md", "agent")
+	if _, err := env.syncer.Push(context.Background()); err != nil {
+		t.Fatalf("Push without leases enabled failed: %v", err)
+	}
+}
+
+func TestLeaseArea(t *testing.T) {
+	tests := map[string]string{
+		"projects/p1/session.jsonl": "projects",
+		"agents/helper.md":          "agents",
+		"CLAUDE.md":                 "CLAUDE.md",
+	}
+	for path, want := range tests {
+		if got := leaseArea(path); got != want {
+			t.Errorf("leaseArea(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestPushLeasesConditionalRace(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.state.DeviceID = "desktop"
+	other := sharedBucketEnv(t, env)
+	other.syncer.state.DeviceID = "laptop"
+	ctx := context.Background()
+
+	// The laptop takes agents/ between the desktop's read and its write
+	var laptop []Lease
+	racing := &racingStorage{mockStorage: env.store, key: leaseKey("agents"), race: func() {
+		var err error
+		if laptop, err = other.syncer.takeLeases(ctx, []string{"agents"}); err != nil {
+			t.Errorf("laptop failed to take the lease: %v", err)
+		}
+	}}
+	env.syncer.storage = racing
+
+	_, err := env.syncer.acquireLeases(ctx, []FileChange{{Path: "agents/helper.md", Action: "add"}})
+	var held *LeaseHeldError
+	if !errors.As(err, &held) || held.Lease.Device != "laptop" {
+		t.Fatalf("Expected the laptop's LeaseHeldError, got %v", err)
+	}
+	var current Lease
+	if err := env.syncer.downloadJSON(ctx, leaseKey("agents"), &current); err != nil {
+		t.Fatal(err)
+	}
+	if len(laptop) != 1 || current.Token != laptop[0].Token {
+		t.Errorf("Expected the laptop's lease to stand, got %+v", current)
+	}
+}
+
+func TestRenewLeases(t *testing.T) {
+	defer func(d time.Duration) { leaseRenewInterval = d }(leaseRenewInterval)
+	leaseRenewInterval = 10 * time.Millisecond
+
+	env := setupTestEnv(t)
+	env.syncer.state.DeviceID = "desktop"
+	ctx := context.Background()
+
+	leases, err := env.syncer.takeLeases(ctx, []string{"agents", "projects"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	taken := leases[0].Expires
+	stop := env.syncer.renewLeases(ctx, leases)
+	time.Sleep(50 * time.Millisecond)
+
+	// Another device takes projects/ as if the desktop's lease had expired
+	plantLease(t, env, "projects", "laptop", time.Now().Add(leaseTTL))
+	time.Sleep(50 * time.Millisecond)
+	if err := stop(); err == nil || !strings.Contains(err.Error(), "projects") {
+		t.Errorf("Expected the lost projects lease reported, got %v", err)
+	}
+
+	var agents Lease
+	if err := env.syncer.downloadJSON(ctx, leaseKey("agents"), &agents); err != nil {
+		t.Fatal(err)
+	}
+	if !agents.Expires.After(taken) {
+		t.Errorf("Expected the agents lease renewed past %v, got %v", taken, agents.Expires)
+	}
+
+	env.syncer.releaseLeases(ctx, leases)
+	if _, ok := env.store.objects[leaseKey("agents")]; ok {
+		t.Error("Expected the agents lease released")
+	}
+	if _, ok := env.store.objects[leaseKey("projects")]; !ok {
+		t.Error("Expected the other device's projects lease left alone")
+	}
+}
+
+func TestPushLeasesCoverManifest(t *testing.T) {
+	defer func(d time.Duration) { manifestLeaseWait = d }(manifestLeaseWait)
+	manifestLeaseWait = 0
+
+	env := setupTestEnv(t)
+	env.syncer.cfg.PushLeases = true
+	env.syncer.state.DeviceID = "desktop"
+	env.syncer.quiet = true
+	ctx := context.Background()
+
+	// The laptop is writing the manifest: the push leaves it alone
+	plantLease(t, env, manifestLeaseArea, "laptop", time.Now().Add(leaseTTL))
+	writeFile(t, env.claudeDir, "agents/helper.md", "agent")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if _, ok := env.store.objects[ManifestKey+".age"]; ok {
+		t.Error("Expected no manifest written under the laptop's manifest lease")
+	}
+
+	delete(env.store.objects, leaseKey(manifestLeaseArea))
+	writeFile(t, env.claudeDir, "agents/helper.md", "agent v2")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if _, ok := env.store.objects[ManifestKey+".age"]; !ok {
+		t.Error("Expected the manifest written")
+	}
+	for key := range env.store.objects {
+		if strings.HasPrefix(key, LeasePrefix) {
+			t.Errorf("Expected every lease released, found %s", key)
+		}
+	}
+}
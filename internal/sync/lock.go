@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+)
+
+// lockPollInterval is how often a waiting LockProcess tries again.
+const lockPollInterval = 250 * time.Millisecond
+
+// errLockHeld is what the platform's tryLockFile returns when another process
+// holds the lock.
+var errLockHeld = errors.New("lock held")
+
+// ProcessLock keeps other claude-sync processes on this device from syncing
+// while it is held: two runs at once would overwrite each other's state.json
+// and upload the same files twice. It is an flock (LockFileEx on Windows),
+// so the OS releases it when the process exits, however that happens.
+type ProcessLock struct {
+	f *os.File
+}
+
+// lockHolder is written into the lock file by the process holding it.
+type lockHolder struct {
+	PID     int       `json:"pid"`
+	Command string    `json:"command"`
+	Since   time.Time `json:"since"`
+}
+
+// ProcessLockedError is returned by LockProcess while another process holds
+// the lock. Its fields are empty when the holder couldn't be read.
+type ProcessLockedError struct {
+	PID     int
+	Command string
+	Since   time.Time
+}
+
+func (e *ProcessLockedError) Error() string {
+	if e.PID == 0 {
+		return "another claude-sync process is syncing; try again when it's done, or pass --wait"
+	}
+	return fmt.Sprintf("another claude-sync process (pid %d, %s, started %s) is syncing; try again when it's done, or pass --wait",
+		e.PID, e.Command, e.Since.Local().Format(time.Kitchen))
+}
+
+// LockProcess takes the process lock at config.LockFilePath() for command,
+// waiting up to wait for another process to release it.
+func LockProcess(command string, wait time.Duration) (*ProcessLock, error) {
+	return lockProcessAt(config.LockFilePath(), command, wait)
+}
+
+func lockProcessAt(path, command string, wait time.Duration) (*ProcessLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		err = tryLockFile(f)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errLockHeld) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if !time.Now().Before(deadline) {
+			f.Close()
+			return nil, lockedError(path)
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	// Say who holds it, for the processes that find it locked
+	holder, _ := json.Marshal(lockHolder{PID: os.Getpid(), Command: command, Since: time.Now()})
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt(append(holder, '\n'), 0)
+	}
+	return &ProcessLock{f: f}, nil
+}
+
+// lockedError describes the process holding the lock at path.
+func lockedError(path string) *ProcessLockedError {
+	var holder lockHolder
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &holder)
+	}
+	return &ProcessLockedError{PID: holder.PID, Command: holder.Command, Since: holder.Since}
+}
+
+// Release lets other processes sync again.
+func (l *ProcessLock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := unlockFile(l.f)
+	if closeErr := l.f.Close(); err == nil {
+		err = closeErr
+	}
+	l.f = nil
+	return err
+}
@@ -0,0 +1,14 @@
+//go:build !unix && !windows
+
+package sync
+
+import "os"
+
+// Without file locks, concurrent runs aren't prevented.
+func tryLockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,42 @@
+package sync
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockProcess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.lock")
+
+	lock, err := lockProcessAt(path, "push", 0)
+	if err != nil {
+		t.Fatalf("lockProcessAt failed: %v", err)
+	}
+
+	_, err = lockProcessAt(path, "pull", 0)
+	var locked *ProcessLockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("Second lock = %v, want a ProcessLockedError", err)
+	}
+	if locked.PID != os.Getpid() || locked.Command != "push" {
+		t.Errorf("Holder = %+v, want this process's push", locked)
+	}
+
+	go func() {
+		time.Sleep(2 * lockPollInterval)
+		_ = lock.Release()
+	}()
+	second, err := lockProcessAt(path, "pull", 5*time.Second)
+	if err != nil {
+		t.Fatalf("Waiting lock failed: %v", err)
+	}
+	if err := second.Release(); err != nil {
+		t.Errorf("Release failed: %v", err)
+	}
+	if err := second.Release(); err != nil {
+		t.Errorf("Second Release = %v, want nil", err)
+	}
+}
@@ -0,0 +1,59 @@
+package sync
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RemoteEntry is one object in the bucket as 'claude-sync ls' shows it.
+type RemoteEntry struct {
+	// Path is the local relative path the object syncs to, or empty when
+	// this device can't decode its key (a path_map token it doesn't define,
+	// an opaque key missing from the key index) or the object is
+	// claude-sync's own data.
+	Path         string    `json:"path,omitempty"`
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// ListRemote lists the files in the bucket whose local path starts with
+// prefix, sorted by path, followed by any keys that can't be decoded (when
+// they start with prefix themselves). Excluded and paused paths are listed
+// too. claude-sync's own objects (metadata, versions, trash) are left out
+// unless all is set, in which case they are listed by key.
+func (s *Syncer) ListRemote(ctx context.Context, prefix string, all bool) ([]RemoteEntry, error) {
+	objects, err := s.listRemote(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var files, others []RemoteEntry
+	for _, obj := range objects {
+		entry := RemoteEntry{Key: obj.Key, Size: obj.Size, LastModified: obj.LastModified}
+		if isReservedKey(obj.Key) {
+			if all && strings.HasPrefix(obj.Key, prefix) {
+				others = append(others, entry)
+			}
+			continue
+		}
+		if strings.HasSuffix(obj.Key, ".age") {
+			if path, ok := s.localPath(obj.Key); ok {
+				if strings.HasPrefix(path, prefix) {
+					entry.Path = path
+					files = append(files, entry)
+				}
+				continue
+			}
+		}
+		if strings.HasPrefix(obj.Key, prefix) {
+			others = append(others, entry)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	sort.Slice(others, func(i, j int) bool { return others[i].Key < others[j].Key })
+	return append(files, others...), nil
+}
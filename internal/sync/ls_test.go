@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"context"
+	"testing"
+)
+
+func TestListRemote(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "agents/b.md", "bb")
+	writeFile(t, env.claudeDir, "agents/a.md", "a")
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# notes")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	env.store.Upload(ctx, "o/0123abcd.age", []byte("opaque"))
+
+	entries, err := env.syncer.ListRemote(ctx, "agents/", false)
+	if err != nil {
+		t.Fatalf("ListRemote failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Path != "agents/a.md" || entries[1].Path != "agents/b.md" {
+		t.Fatalf("ListRemote(agents/) = %+v, want agents/a.md and agents/b.md", entries)
+	}
+	if entries[0].Key != "agents/a.md.age" || entries[0].Size == 0 || entries[0].LastModified.IsZero() {
+		t.Errorf("entry = %+v, want its key, size and time", entries[0])
+	}
+
+	entries, err = env.syncer.ListRemote(ctx, "", false)
+	if err != nil {
+		t.Fatalf("ListRemote failed: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("ListRemote() = %+v, want 3 files and the opaque key", entries)
+	}
+	if last := entries[3]; last.Path != "" || last.Key != "o/0123abcd.age" {
+		t.Errorf("last entry = %+v, want the undecodable key without a path", last)
+	}
+
+	all, err := env.syncer.ListRemote(ctx, "", true)
+	if err != nil {
+		t.Fatalf("ListRemote failed: %v", err)
+	}
+	if len(all) <= len(entries) {
+		t.Errorf("ListRemote with all = %d entries, want claude-sync's own objects too", len(all))
+	}
+}
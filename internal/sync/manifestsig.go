@@ -0,0 +1,239 @@
+package sync
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/crypto"
+)
+
+// ErrIntegrity marks a manifest or pulled object that fails the checks
+// sign_manifest turns on.
+var ErrIntegrity = errors.New("integrity check failed")
+
+// manifestSigContext keeps manifest signatures apart from attestation ones.
+const manifestSigContext = "claude-sync manifest v1\n"
+
+func (m *FileManifest) signedPayload() ([]byte, error) {
+	files, err := json.Marshal(m.Files)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(manifestSigContext), files...), nil
+}
+
+// trustedSigners returns the signing keys whose signatures count: this
+// device's and those in attestation_signers.
+func (s *Syncer) trustedSigners() map[string]bool {
+	trusted := make(map[string]bool, len(s.cfg.AttestationSigners)+1)
+	if key := s.signingPublicKey(); key != "" {
+		trusted[key] = true
+	}
+	for _, key := range s.cfg.AttestationSigners {
+		trusted[key] = true
+	}
+	return trusted
+}
+
+// signingPublicKey returns the public half of this device's signing key, or
+// "" when the encryption scheme can't sign.
+func (s *Syncer) signingPublicKey() string {
+	if signer, ok := s.encryptor.(crypto.Signer); ok {
+		return signer.SigningPublicKey()
+	}
+	return ""
+}
+
+func (s *Syncer) sign(payload []byte) ([]byte, error) {
+	signer, ok := s.encryptor.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("the encryption scheme can't sign")
+	}
+	return signer.Sign(payload)
+}
+
+// putManifest uploads m, signed when sign_manifest is on.
+func (s *Syncer) putManifest(ctx context.Context, m *FileManifest) error {
+	m.SignerKey, m.Signature = "", ""
+	if s.cfg.SignManifest {
+		payload, err := m.signedPayload()
+		if err != nil {
+			return err
+		}
+		sig, err := s.sign(payload)
+		if err != nil {
+			return fmt.Errorf("failed to sign manifest: %w", err)
+		}
+		m.SignerKey, m.Signature = s.signingPublicKey(), base64.StdEncoding.EncodeToString(sig)
+	}
+	if err := s.uploadJSON(ctx, ManifestKey+".age", m); err != nil {
+		return fmt.Errorf("failed to upload manifest: %w", err)
+	}
+	return nil
+}
+
+// pullManifest downloads the manifest for a pull. With sign_manifest, it
+// must exist and carry a valid signature from a trusted key; otherwise it is
+// best-effort and may be nil.
+func (s *Syncer) pullManifest(ctx context.Context) (*FileManifest, error) {
+	manifest, err := s.downloadManifest(ctx)
+	if !s.cfg.SignManifest {
+		return manifest, nil
+	}
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", ErrIntegrity, err)
+	case manifest == nil:
+		return nil, fmt.Errorf("%w: the bucket has no manifest; push from a device with sign_manifest first", ErrIntegrity)
+	case manifest.Signature == "":
+		return nil, fmt.Errorf("%w: the manifest is not signed; push from a device with sign_manifest first", ErrIntegrity)
+	}
+	if err := s.verifyManifest(manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// verifyManifest checks that a signed manifest's signature is valid and
+// from a trusted key.
+func (s *Syncer) verifyManifest(manifest *FileManifest) error {
+	payload, err := manifest.signedPayload()
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(manifest.Signature)
+	if err != nil || !crypto.VerifySignature(manifest.SignerKey, payload, sig) {
+		return fmt.Errorf("%w: the manifest's signature is invalid; the bucket may have been tampered with", ErrIntegrity)
+	}
+	if !s.trustedSigners()[manifest.SignerKey] {
+		return fmt.Errorf("%w: the manifest is signed by an untrusted key %s (add it to attestation_signers if it is yours)", ErrIntegrity, manifest.SignerKey)
+	}
+	return nil
+}
+
+// mergeRemoteManifest adds to m, built from this device's state, the
+// entries of the bucket's manifest for files other devices pushed that this
+// one hasn't pulled, or has pulled an older push of; otherwise the manifest
+// would forget them, and with sign_manifest every device would refuse to
+// pull them. Paths in deleted were just deleted from the bucket and stay
+// out. With sign_manifest, only a manifest with a valid trusted signature
+// is merged, so nothing unsigned or forged gets signed along with it.
+func (s *Syncer) mergeRemoteManifest(ctx context.Context, m *FileManifest, deleted []string) error {
+	remote, err := s.downloadManifest(ctx)
+	if err != nil || remote == nil {
+		return err
+	}
+	if s.cfg.SignManifest {
+		if remote.Signature == "" {
+			return nil
+		}
+		if err := s.verifyManifest(remote); err != nil {
+			s.log("Warning: not keeping the remote manifest's entries: %v", err)
+			return nil
+		}
+	}
+	gone := make(map[string]bool, len(deleted))
+	for _, path := range deleted {
+		gone[path] = true
+	}
+	for path, meta := range remote.Files {
+		if gone[path] {
+			continue
+		}
+		if local, ok := m.Files[path]; !ok || meta.PushedAt.After(local.PushedAt) {
+			m.Files[path] = meta
+		}
+	}
+	return nil
+}
+
+// objectCheck returns a check for fetchFile that records the downloaded
+// object's hash in *hash and, with sign_manifest, compares it with the
+// signed manifest's entry for relativePath.
+func (s *Syncer) objectCheck(manifest *FileManifest, relativePath string, hash *string) func([]byte) error {
+	return func(encrypted []byte) error {
+		*hash = sha256Hex(encrypted)
+		if !s.cfg.SignManifest {
+			return nil
+		}
+		want := ""
+		if manifest != nil {
+			want = manifest.Files[relativePath].ObjectHash
+		}
+		switch want {
+		case "":
+			return fmt.Errorf("%w: not covered by the signed manifest", ErrIntegrity)
+		case *hash:
+			return nil
+		}
+		return fmt.Errorf("%w: does not match the signed manifest (tampered with, or pushed again during this pull)", ErrIntegrity)
+	}
+}
+
+// patchManifest sets the object hashes of the given paths in the remote
+// manifest and uploads it again, re-signed.
+func (s *Syncer) patchManifest(ctx context.Context, hashes map[string]string) error {
+	manifest, err := s.downloadManifest(ctx)
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		manifest = &FileManifest{Files: make(map[string]FileMetadata)}
+	}
+	for path, hash := range hashes {
+		meta := manifest.Files[path]
+		meta.ObjectHash = hash
+		manifest.Files[path] = meta
+	}
+	return s.putManifest(ctx, manifest)
+}
+
+// manifestNeedsSigning reports whether sign_manifest is on but the remote
+// manifest isn't signed or doesn't cover every tracked file yet.
+func (s *Syncer) manifestNeedsSigning(ctx context.Context) bool {
+	if !s.cfg.SignManifest {
+		return false
+	}
+	if len(s.unhashedObjects()) > 0 {
+		return true
+	}
+	manifest, err := s.downloadManifest(ctx)
+	return err == nil && (manifest == nil || manifest.Signature == "")
+}
+
+// unhashedObjects returns the tracked files whose object hash a signed
+// manifest still needs; nil when sign_manifest is off. Saved .conflict
+// copies never reach the bucket, so they don't count.
+func (s *Syncer) unhashedObjects() []string {
+	if !s.cfg.SignManifest {
+		return nil
+	}
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	var missing []string
+	for path, f := range s.state.Files {
+		if f.ObjectHash == "" && !f.Uploaded.IsZero() && path != config.MCPRemoteKey && !strings.Contains(path, conflictMarker) {
+			missing = append(missing, path)
+		}
+	}
+	return missing
+}
+
+// fillObjectHashes hashes the remote objects of tracked files whose object
+// hash isn't known yet (pushed before it was recorded), so a signed manifest
+// covers them.
+func (s *Syncer) fillObjectHashes(ctx context.Context) {
+	for _, path := range s.unhashedObjects() {
+		encrypted, err := s.storage.Download(ctx, s.remoteKey(path))
+		if err != nil {
+			// Not on the remote (yet): nothing to cover
+			continue
+		}
+		s.state.SetObjectHash(path, sha256Hex(encrypted))
+	}
+}
@@ -0,0 +1,259 @@
+package sync
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func signedPair(t *testing.T) (*testEnv, *testEnv) {
+	t.Helper()
+	env := setupTestEnv(t)
+	env.syncer.cfg.SignManifest = true
+	other := sharedBucketEnv(t, env)
+	other.syncer.cfg.SignManifest = true
+	return env, other
+}
+
+func TestSignedManifestPull(t *testing.T) {
+	env, other := signedPair(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	manifest, err := env.syncer.downloadManifest(ctx)
+	if err != nil || manifest == nil {
+		t.Fatalf("downloadManifest: %v", err)
+	}
+	if manifest.Signature == "" || manifest.Files["CLAUDE.md"].ObjectHash == "" {
+		t.Fatalf("Expected a signed manifest with object hashes, got %+v", manifest)
+	}
+
+	result, err := other.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.Errors) != 0 || readFile(t, other.claudeDir, "CLAUDE.md") != "rules" {
+		t.Errorf("Expected a clean pull, got %v", result.Errors)
+	}
+	if got := other.syncer.state.GetFile("CLAUDE.md").ObjectHash; got != manifest.Files["CLAUDE.md"].ObjectHash {
+		t.Errorf("Expected the pulled object hash recorded, got %q", got)
+	}
+}
+
+func TestSignedManifestRejectsSwappedObject(t *testing.T) {
+	env, other := signedPair(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	writeFile(t, env.claudeDir, "agents/a.md", "agent")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	// Both objects decrypt fine: only the manifest can tell them apart
+	env.store.objects["agents/a.md.age"] = env.store.objects["CLAUDE.md.age"]
+
+	result, err := other.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.Errors) != 1 || !errors.Is(result.Errors[0], ErrIntegrity) {
+		t.Fatalf("Expected one integrity error, got %v", result.Errors)
+	}
+	if _, err := os.Stat(filepath.Join(other.claudeDir, "agents", "a.md")); !os.IsNotExist(err) {
+		t.Error("Expected the swapped file not written")
+	}
+	if readFile(t, other.claudeDir, "CLAUDE.md") != "rules" {
+		t.Error("Expected the intact file pulled")
+	}
+}
+
+func TestSignedManifestRejectsTampering(t *testing.T) {
+	env, other := signedPair(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	signed := env.store.objects[ManifestKey+".age"]
+
+	// Re-encrypting an edited manifest needs only the bucket key, not the signing key
+	manifest, _ := env.syncer.downloadManifest(ctx)
+	manifest.Files["CLAUDE.md"] = FileMetadata{ObjectHash: "forged"}
+	if err := env.syncer.uploadJSON(ctx, ManifestKey+".age", manifest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.syncer.Pull(ctx); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("Expected an edited manifest refused, got %v", err)
+	}
+
+	// An unsigned manifest, as a device without sign_manifest pushes it
+	manifest.Signature = ""
+	if err := env.syncer.uploadJSON(ctx, ManifestKey+".age", manifest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.syncer.Pull(ctx); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("Expected an unsigned manifest refused, got %v", err)
+	}
+
+	delete(env.store.objects, ManifestKey+".age")
+	if _, err := other.syncer.Pull(ctx); !errors.Is(err, ErrIntegrity) {
+		t.Errorf("Expected a missing manifest refused, got %v", err)
+	}
+
+	env.store.objects[ManifestKey+".age"] = signed
+	if _, err := other.syncer.Pull(ctx); err != nil {
+		t.Errorf("Expected the original manifest accepted, got %v", err)
+	}
+}
+
+func TestSignedManifestUntrustedSigner(t *testing.T) {
+	env, other := signedPair(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	// Signed validly, but by a key nobody listed
+	otherEnc := newTestEncryptor(t)
+	manifest, _ := env.syncer.downloadManifest(ctx)
+	payload, err := manifest.signedPayload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := otherEnc.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest.SignerKey, manifest.Signature = otherEnc.SigningPublicKey(), base64.StdEncoding.EncodeToString(sig)
+	if err := env.syncer.uploadJSON(ctx, ManifestKey+".age", manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := other.syncer.Pull(ctx); !errors.Is(err, ErrIntegrity) {
+		t.Fatalf("Expected an untrusted signer refused, got %v", err)
+	}
+	other.syncer.cfg.AttestationSigners = []string{otherEnc.SigningPublicKey()}
+	if _, err := other.syncer.Pull(ctx); err != nil {
+		t.Errorf("Expected a listed signer trusted, got %v", err)
+	}
+}
+
+func TestSignManifestOnExistingBucket(t *testing.T) {
+	env, other := signedPair(t)
+	ctx := context.Background()
+
+	env.syncer.cfg.SignManifest = false
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if _, err := other.syncer.Pull(ctx); !errors.Is(err, ErrIntegrity) {
+		t.Fatalf("Expected an unsigned manifest refused, got %v", err)
+	}
+
+	// A push with nothing to upload still signs once hashes are missing
+	env.syncer.cfg.SignManifest = true
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if _, err := other.syncer.Pull(ctx); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if readFile(t, other.claudeDir, "CLAUDE.md") != "rules" {
+		t.Error("Expected the file pulled")
+	}
+}
+
+func TestRekeyResignsManifest(t *testing.T) {
+	env, other := signedPair(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	newEnc := newTestEncryptor(t)
+	if _, err := env.syncer.Rekey(ctx, newEnc); err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+	other.syncer.encryptor = newEnc
+	result, err := other.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.Errors) != 0 || readFile(t, other.claudeDir, "CLAUDE.md") != "rules" {
+		t.Errorf("Expected a clean pull after rekey, got %v", result.Errors)
+	}
+}
+
+func TestSignedManifestKeepsOtherDevicesFiles(t *testing.T) {
+	env, other := signedPair(t)
+	ctx := context.Background()
+
+	// Each device pushes its own file without pulling the other's first
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	writeFile(t, other.claudeDir, "agents/b.md", "agent")
+	if _, err := other.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	manifest, err := env.syncer.pullManifest(ctx)
+	if err != nil {
+		t.Fatalf("pullManifest: %v", err)
+	}
+	for _, path := range []string{"CLAUDE.md", "agents/b.md"} {
+		if manifest.Files[path].ObjectHash == "" {
+			t.Errorf("Expected the signed manifest to cover %s, got %+v", path, manifest.Files)
+		}
+	}
+
+	for _, device := range []*testEnv{env, other} {
+		result, err := device.syncer.Pull(ctx)
+		if err != nil {
+			t.Fatalf("Pull failed: %v", err)
+		}
+		if len(result.Errors) != 0 || len(result.Downloaded) != 1 {
+			t.Errorf("Expected the other device's file pulled cleanly, got %+v", result)
+		}
+	}
+
+	// A newer push of a file wins over the entry a device pulled earlier,
+	// and a deleted file leaves the manifest
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules v2")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if err := os.Remove(filepath.Join(other.claudeDir, "agents", "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if manifest, err = env.syncer.pullManifest(ctx); err != nil {
+		t.Fatalf("pullManifest: %v", err)
+	}
+	if _, ok := manifest.Files["agents/b.md"]; ok {
+		t.Error("Expected the deleted file dropped from the manifest")
+	}
+	result, err := other.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.Errors) != 0 || readFile(t, other.claudeDir, "CLAUDE.md") != "rules v2" {
+		t.Errorf("Expected the newer CLAUDE.md pulled cleanly, got %+v", result)
+	}
+}
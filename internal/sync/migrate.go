@@ -57,6 +57,10 @@ func (s *Syncer) MigratePaths(ctx context.Context) (*MigrateResult, error) {
 		legacyKeys = append(legacyKeys, raw)
 	}
 
+	if err := s.recordKeyNames(ctx, legacyKeys); err != nil {
+		return nil, err
+	}
+
 	total := len(legacyKeys)
 	for i, raw := range legacyKeys {
 		s.progress(ProgressEvent{Action: "upload", Path: raw, Current: i + 1, Total: total})
@@ -77,5 +81,12 @@ func (s *Syncer) MigratePaths(ctx context.Context) (*MigrateResult, error) {
 		}
 	}
 
+	// Re-uploaded objects have new hashes the signed manifest must cover
+	if s.cfg.SignManifest && len(result.Migrated) > 0 {
+		if err := s.uploadManifest(ctx, nil); err != nil {
+			return result, err
+		}
+	}
+
 	return result, nil
 }
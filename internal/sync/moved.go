@@ -0,0 +1,279 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ClaudeDirMove describes a change of home or Claude directory since this
+// device's state was recorded, e.g. after a username change or a migration
+// to a new machine. Left alone, it would make push delete and re-add every
+// file under the old paths.
+type ClaudeDirMove struct {
+	OldHome, NewHome           string
+	OldClaudeDir, NewClaudeDir string
+
+	// Projects are session directories named for the old home, which
+	// Claude Code no longer finds sessions in.
+	Projects []ProjectRename
+
+	Matched int // Tracked files found unchanged at their new location
+	Missing int // Tracked files found at neither location
+}
+
+// ProjectRename is a projects/ directory to rename for the new home.
+type ProjectRename struct {
+	From, To string // Relative to the Claude directory
+}
+
+// pathMove is a tracked file AdoptMove carries over to a new path.
+type pathMove struct {
+	from, to string
+}
+
+// ClaudeDirMovedError stops a push or pull until the move is adopted.
+type ClaudeDirMovedError struct {
+	Move *ClaudeDirMove
+}
+
+func (e *ClaudeDirMovedError) Error() string {
+	// With CLAUDE_CONFIG_DIR or claude_dir set, only the home may have moved
+	from, to := e.Move.OldClaudeDir, e.Move.NewClaudeDir
+	if from == to {
+		from, to = e.Move.OldHome, e.Move.NewHome
+	}
+	return fmt.Sprintf("%s has moved to %s since the last sync; run 'claude-sync adopt' to carry this device's sync state over",
+		from, to)
+}
+
+// AdoptResult reports what AdoptMove did.
+type AdoptResult struct {
+	Renamed []ProjectRename
+	Moved   int // State entries carried over to a new path
+	Dropped int // Missing files, left for the next pull to restore
+}
+
+// checkLocation records where state was built on the first sync, and
+// refuses to sync once the home or Claude directory has moved in a way that
+// needs adopting. A move that changes nothing is simply recorded.
+func (s *Syncer) checkLocation() error {
+	move := s.DetectMove()
+	if move == nil {
+		s.state.setLocation(s.homeDir, s.claudeDir)
+		return nil
+	}
+	if len(move.Projects) > 0 || move.Missing > 0 {
+		return &ClaudeDirMovedError{Move: move}
+	}
+	s.state.setLocation(s.homeDir, s.claudeDir)
+	return nil
+}
+
+// DetectMove compares the home and Claude directory state was recorded for
+// with the current ones. It returns nil when they match or nothing was
+// recorded yet.
+func (s *Syncer) DetectMove() *ClaudeDirMove {
+	s.state.mu.Lock()
+	oldHome, oldDir := s.state.Home, s.state.ClaudeDir
+	s.state.mu.Unlock()
+	if oldDir == "" || (oldDir == s.claudeDir && oldHome == s.homeDir) {
+		return nil
+	}
+
+	move := &ClaudeDirMove{
+		OldHome:      oldHome,
+		NewHome:      s.homeDir,
+		OldClaudeDir: oldDir,
+		NewClaudeDir: s.claudeDir,
+	}
+	renames := make(map[string]string)
+	for _, path := range s.trackedPaths() {
+		newPath := path
+		if from, to, ok := s.renamedProject(path, oldHome); ok {
+			renames[from] = to
+			newPath = to + strings.TrimPrefix(path, from)
+		}
+		if f := s.state.GetFile(path); s.fileMatches(newPath, f.Hash) || s.fileMatches(path, f.Hash) {
+			move.Matched++
+		} else if !s.fileExists(newPath) && !s.fileExists(path) {
+			move.Missing++
+		}
+	}
+	for from, to := range renames {
+		move.Projects = append(move.Projects, ProjectRename{From: from, To: to})
+	}
+	sort.Slice(move.Projects, func(i, j int) bool { return move.Projects[i].From < move.Projects[j].From })
+	return move
+}
+
+// AdoptMove carries this device's sync state over to the new location:
+// session directories named for the old home are renamed for the new one
+// (merged file by file when both exist), their remote objects and state
+// follow them, and tracked files that are gone are forgotten, so the next
+// pull restores them instead of the next push deleting them remotely.
+func (s *Syncer) AdoptMove(ctx context.Context, move *ClaudeDirMove) (*AdoptResult, error) {
+	result := &AdoptResult{}
+	for _, p := range move.Projects {
+		if err := s.mergeDir(p.From, p.To); err != nil {
+			return result, fmt.Errorf("failed to move %s to %s: %w", p.From, p.To, err)
+		}
+		result.Renamed = append(result.Renamed, p)
+	}
+
+	var moves []pathMove
+	for _, path := range s.trackedPaths() {
+		newPath := path
+		if from, to, ok := s.renamedProject(path, move.OldHome); ok {
+			newPath = to + strings.TrimPrefix(path, from)
+		}
+		switch {
+		case !s.fileExists(newPath):
+			s.state.RemoveFile(path)
+			result.Dropped++
+		case newPath != path:
+			moves = append(moves, pathMove{from: path, to: newPath})
+		}
+	}
+
+	// Unless every move is carried over, the move stays unadopted, so
+	// running adopt again finishes it
+	err := s.moveRemoteObjects(ctx, moves, result)
+	if err == nil {
+		s.state.setLocation(s.homeDir, s.claudeDir)
+	}
+	if saveErr := s.state.Save(); saveErr != nil && err == nil {
+		err = fmt.Errorf("failed to save state: %w", saveErr)
+	}
+	return result, err
+}
+
+// moveRemoteObjects moves the objects of tracked files renamed by a move to
+// their new keys before their state entries follow, so the bucket and the
+// state agree. Left under the old keys, the objects would come back at the
+// old paths on the next pull, here and on every other device.
+// An object another device has deleted meanwhile has nothing to move.
+func (s *Syncer) moveRemoteObjects(ctx context.Context, moves []pathMove, result *AdoptResult) error {
+	if len(moves) == 0 {
+		return nil
+	}
+	newPaths := make([]string, len(moves))
+	oldKeys := make([]string, len(moves))
+	for i, m := range moves {
+		newPaths[i], oldKeys[i] = m.to, s.remoteKey(m.from)
+	}
+	existing, err := s.storage.HeadBatch(ctx, oldKeys)
+	if err != nil {
+		return fmt.Errorf("failed to stat remote files: %w", err)
+	}
+	if err := s.recordKeyNames(ctx, newPaths); err != nil {
+		return err
+	}
+
+	for _, m := range moves {
+		from, to := s.remoteKey(m.from), s.remoteKey(m.to)
+		if _, ok := existing[from]; ok && from != to {
+			if err := copyObject(ctx, s.storage, from, to); err != nil {
+				return fmt.Errorf("failed to move %s to %s: %w", from, to, err)
+			}
+			if err := s.storage.Delete(ctx, from); err != nil {
+				return fmt.Errorf("failed to delete %s: %w", from, err)
+			}
+		}
+		s.state.renameFile(m.from, m.to)
+		result.Moved++
+	}
+	return nil
+}
+
+// trackedPaths returns the sorted paths of tracked files, leaving out the
+// MCP config, which isn't stored under the Claude directory.
+func (s *Syncer) trackedPaths() []string {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	paths := make([]string, 0, len(s.state.Files))
+	for path := range s.state.Files {
+		if !isReservedKey(path) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// renamedProject reports whether path is in a session directory named for
+// oldHome, and returns that directory and its name for the current home.
+func (s *Syncer) renamedProject(path, oldHome string) (from, to string, ok bool) {
+	if oldHome == "" || s.homeDir == "" || oldHome == s.homeDir {
+		return "", "", false
+	}
+	seg, _, ok := splitProjectsPath(path)
+	if !ok {
+		return "", "", false
+	}
+	oldEnc, newEnc := EncodeClaudePath(oldHome), EncodeClaudePath(s.homeDir)
+	if seg != oldEnc && !strings.HasPrefix(seg, oldEnc+"-") {
+		return "", "", false
+	}
+	return "projects/" + seg, "projects/" + newEnc + strings.TrimPrefix(seg, oldEnc), true
+}
+
+func (s *Syncer) fileExists(relativePath string) bool {
+	_, err := os.Stat(filepath.Join(s.claudeDir, relativePath))
+	return err == nil
+}
+
+func (s *Syncer) fileMatches(relativePath, hash string) bool {
+	got, err := s.state.hashFile(filepath.Join(s.claudeDir, relativePath))
+	return err == nil && got == hash
+}
+
+// mergeDir renames from to to under the Claude directory. When both exist,
+// files are moved across one by one, keeping any already at the destination.
+func (s *Syncer) mergeDir(from, to string) error {
+	src, dst := filepath.Join(s.claudeDir, from), filepath.Join(s.claudeDir, to)
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Stat(dst); os.IsNotExist(err) {
+		return os.Rename(src, dst)
+	}
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		if _, err := os.Stat(target); err == nil {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return err
+		}
+		return os.Rename(path, target)
+	})
+	if err != nil {
+		return err
+	}
+	// Leave whatever couldn't be merged where it was
+	removeEmptyDirs(src)
+	return nil
+}
+
+// removeEmptyDirs removes dir and its subdirectories that hold no files.
+func removeEmptyDirs(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			removeEmptyDirs(filepath.Join(dir, e.Name()))
+		}
+	}
+	_ = os.Remove(dir)
+}
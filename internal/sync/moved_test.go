@@ -0,0 +1,136 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func pushOK(t *testing.T, env *testEnv) *SyncResult {
+	t.Helper()
+	result, err := env.syncer.Push(context.Background())
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	return result
+}
+
+func TestLocationRecordedOnSync(t *testing.T) {
+	env := setupTestEnv(t)
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	pushOK(t, env)
+
+	if env.syncer.state.ClaudeDir != env.claudeDir || env.syncer.state.Home != env.syncer.homeDir {
+		t.Errorf("Expected the location recorded, got %q, %q", env.syncer.state.ClaudeDir, env.syncer.state.Home)
+	}
+	if env.syncer.DetectMove() != nil {
+		t.Error("Expected no move")
+	}
+}
+
+func TestHomeMoveAdoptsProjects(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.homeDir = "/Users/alice"
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	writeFile(t, env.claudeDir, "projects/-Users-alice-app/s.jsonl", `{"a":1}`+"\n")
+	pushOK(t, env)
+
+	// Same machine contents, new username
+	env.syncer.homeDir = "/Users/bob"
+	_, err := env.syncer.Push(context.Background())
+	var moved *ClaudeDirMovedError
+	if !errors.As(err, &moved) {
+		t.Fatalf("Expected ClaudeDirMovedError, got %v", err)
+	}
+	want := ProjectRename{From: "projects/-Users-alice-app", To: "projects/-Users-bob-app"}
+	if len(moved.Move.Projects) != 1 || moved.Move.Projects[0] != want || moved.Move.Matched != 2 {
+		t.Fatalf("Unexpected move %+v", moved.Move)
+	}
+	if msg := moved.Error(); !strings.Contains(msg, "/Users/alice has moved to /Users/bob") {
+		t.Errorf("Expected the old and new home in %q", msg)
+	}
+	if _, err := env.syncer.Pull(context.Background()); !errors.As(err, &moved) {
+		t.Errorf("Expected pull refused too, got %v", err)
+	}
+
+	result, err := env.syncer.AdoptMove(context.Background(), moved.Move)
+	if err != nil {
+		t.Fatalf("AdoptMove failed: %v", err)
+	}
+	if len(result.Renamed) != 1 || result.Moved != 1 || result.Dropped != 0 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if readFile(t, env.claudeDir, "projects/-Users-bob-app/s.jsonl") == "" {
+		t.Error("Expected the session directory renamed")
+	}
+	if _, err := os.Stat(filepath.Join(env.claudeDir, "projects", "-Users-alice-app")); !os.IsNotExist(err) {
+		t.Error("Expected the old session directory gone")
+	}
+
+	if _, ok := env.store.objects["projects/-Users-alice-app/s.jsonl.age"]; ok {
+		t.Error("Expected the old remote object moved away")
+	}
+	if _, ok := env.store.objects["projects/-Users-bob-app/s.jsonl.age"]; !ok {
+		t.Error("Expected the remote object under the new path")
+	}
+
+	if result := pushOK(t, env); len(result.Uploaded) != 0 || len(result.Deleted) != 0 {
+		t.Errorf("Expected nothing to push after adopting, got %v uploaded, %v deleted", result.Uploaded, result.Deleted)
+	}
+	if _, err := env.syncer.Pull(context.Background()); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(env.claudeDir, "projects", "-Users-alice-app")); !os.IsNotExist(err) {
+		t.Error("Expected pull not to bring the old session directory back")
+	}
+}
+
+func TestMovedClaudeDirRestoresMissingFiles(t *testing.T) {
+	env := setupTestEnv(t)
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	writeFile(t, env.claudeDir, "agents/a.md", "agent")
+	pushOK(t, env)
+
+	// Only part of the directory made it to the new location
+	newDir := t.TempDir()
+	writeFile(t, newDir, "CLAUDE.md", "rules")
+	env.syncer.claudeDir = newDir
+
+	_, err := env.syncer.Push(context.Background())
+	var moved *ClaudeDirMovedError
+	if !errors.As(err, &moved) || moved.Move.Missing != 1 || moved.Move.Matched != 1 {
+		t.Fatalf("Expected a move with one missing file, got %v", err)
+	}
+	result, err := env.syncer.AdoptMove(context.Background(), moved.Move)
+	if err != nil || result.Dropped != 1 {
+		t.Fatalf("AdoptMove: %+v, %v", result, err)
+	}
+
+	if result := pushOK(t, env); len(result.Deleted) != 0 {
+		t.Errorf("Expected no remote deletes, got %v", result.Deleted)
+	}
+	if _, err := env.syncer.Pull(context.Background()); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if readFile(t, newDir, "agents/a.md") != "agent" {
+		t.Error("Expected the missing file pulled back")
+	}
+}
+
+func TestCompleteMoveIsRecorded(t *testing.T) {
+	env := setupTestEnv(t)
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	pushOK(t, env)
+
+	newDir := t.TempDir()
+	writeFile(t, newDir, "CLAUDE.md", "rules")
+	env.syncer.claudeDir = newDir
+
+	pushOK(t, env)
+	if env.syncer.state.ClaudeDir != newDir {
+		t.Errorf("Expected the new location recorded, got %q", env.syncer.state.ClaudeDir)
+	}
+}
@@ -0,0 +1,172 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// NamedSnapshotPrefix holds snapshots created by 'claude-sync snapshot
+// create'. Unlike the snapshots every push records, they are kept until
+// deleted, and the versions they need are exempt from retention.
+const NamedSnapshotPrefix = SnapshotPrefix + "named/"
+
+var snapshotNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func namedSnapshotKey(name string) string {
+	return NamedSnapshotPrefix + name + ".json.age"
+}
+
+func validateSnapshotName(name string) error {
+	if !snapshotNamePattern.MatchString(name) || name == CurrentSnapshotRef {
+		return fmt.Errorf("invalid snapshot name %q (use letters, digits, '.', '_' and '-')", name)
+	}
+	return nil
+}
+
+// CreateNamedSnapshot freezes the current remote manifest under name.
+// An existing snapshot of that name is replaced only when overwrite is set.
+func (s *Syncer) CreateNamedSnapshot(ctx context.Context, name string, overwrite bool) (*Snapshot, error) {
+	if err := validateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	if !overwrite {
+		existing, err := s.ListNamedSnapshots(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range existing {
+			if info.ID == name {
+				return nil, fmt.Errorf("snapshot %q already exists", name)
+			}
+		}
+	}
+
+	current, err := s.LoadSnapshot(ctx, CurrentSnapshotRef)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	snap := &Snapshot{
+		ID:        name,
+		CreatedAt: now,
+		DeviceID:  s.state.DeviceID,
+		Files:     current.Files,
+	}
+	if err := s.uploadJSON(ctx, namedSnapshotKey(name), snap); err != nil {
+		return nil, fmt.Errorf("failed to save snapshot %s: %w", name, err)
+	}
+	return snap, nil
+}
+
+// ListNamedSnapshots returns the named snapshots, oldest first. CreatedAt is
+// when each was last written.
+func (s *Syncer) ListNamedSnapshots(ctx context.Context) ([]SnapshotInfo, error) {
+	objects, err := s.storage.List(ctx, NamedSnapshotPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	var snaps []SnapshotInfo
+	for _, obj := range objects {
+		name := strings.TrimSuffix(strings.TrimPrefix(obj.Key, NamedSnapshotPrefix), ".json.age")
+		if validateSnapshotName(name) != nil || !strings.HasSuffix(obj.Key, ".json.age") {
+			continue
+		}
+		snaps = append(snaps, SnapshotInfo{ID: name, CreatedAt: obj.LastModified, Key: obj.Key})
+	}
+	sort.Slice(snaps, func(i, j int) bool {
+		if !snaps[i].CreatedAt.Equal(snaps[j].CreatedAt) {
+			return snaps[i].CreatedAt.Before(snaps[j].CreatedAt)
+		}
+		return snaps[i].ID < snaps[j].ID
+	})
+	return snaps, nil
+}
+
+// LoadNamedSnapshot downloads the snapshot called name.
+func (s *Syncer) LoadNamedSnapshot(ctx context.Context, name string) (*Snapshot, error) {
+	if err := validateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	snaps, err := s.ListNamedSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range snaps {
+		if info.ID == name {
+			return s.downloadSnapshot(ctx, info)
+		}
+	}
+	return nil, fmt.Errorf("no snapshot named %q", name)
+}
+
+// DeleteNamedSnapshot removes the snapshot called name, releasing the
+// versions it kept from retention.
+func (s *Syncer) DeleteNamedSnapshot(ctx context.Context, name string) error {
+	if _, err := s.LoadNamedSnapshot(ctx, name); err != nil {
+		return err
+	}
+	if err := s.storage.Delete(ctx, namedSnapshotKey(name)); err != nil {
+		return fmt.Errorf("failed to delete snapshot %s: %w", name, err)
+	}
+	return nil
+}
+
+// PlanRestoreNamed plans restoring every file as it was when the snapshot
+// called name was created.
+func (s *Syncer) PlanRestoreNamed(ctx context.Context, name string) (*RestorePlan, error) {
+	snap, err := s.LoadNamedSnapshot(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.planRestoreSnapshot(ctx, snap)
+}
+
+func (s *Syncer) downloadSnapshot(ctx context.Context, info SnapshotInfo) (*Snapshot, error) {
+	var snap Snapshot
+	if err := s.downloadJSON(ctx, info.Key, &snap); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", info.ID, err)
+	}
+	if snap.Files == nil {
+		snap.Files = make(map[string]FileMetadata)
+	}
+	return &snap, nil
+}
+
+// pinnedVersions returns the keys of the versions named snapshots restore
+// from, given a listing of _versions/. Retention never deletes them.
+func (s *Syncer) pinnedVersions(ctx context.Context, versionObjects []storage.ObjectInfo) (map[string]bool, error) {
+	snaps, err := s.ListNamedSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pinned := make(map[string]bool)
+	if len(snaps) == 0 {
+		return pinned, nil
+	}
+
+	byDir := make(map[string][]storage.ObjectInfo)
+	for _, obj := range versionObjects {
+		if i := strings.LastIndex(obj.Key, "/"); i >= 0 {
+			byDir[obj.Key[:i+1]] = append(byDir[obj.Key[:i+1]], obj)
+		}
+	}
+	for _, info := range snaps {
+		snap, err := s.downloadSnapshot(ctx, info)
+		if err != nil {
+			return nil, err
+		}
+		for path := range snap.Files {
+			dir := s.versionDir(path)
+			if v, ok := versionAt(versionsIn(byDir[dir], dir, path), snap.CreatedAt); ok {
+				pinned[v.Key] = true
+			}
+		}
+	}
+	return pinned, nil
+}
@@ -0,0 +1,121 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNamedSnapshotRestore(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Versioning = true
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# Before")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	snap, err := env.syncer.CreateNamedSnapshot(ctx, "before-experiment", false)
+	if err != nil {
+		t.Fatalf("CreateNamedSnapshot failed: %v", err)
+	}
+	if len(snap.Files) != 1 {
+		t.Errorf("Expected 1 file in snapshot, got %d", len(snap.Files))
+	}
+	if _, err := env.syncer.CreateNamedSnapshot(ctx, "before-experiment", false); err == nil {
+		t.Error("Expected an error for a duplicate name")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# After")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	// Named snapshots aren't mistaken for push snapshots
+	snaps, err := env.syncer.ListSnapshots(ctx)
+	if err != nil {
+		t.Fatalf("ListSnapshots failed: %v", err)
+	}
+	for _, info := range snaps {
+		if info.ID == "before-experiment" {
+			t.Error("Named snapshot listed as a push snapshot")
+		}
+	}
+	named, err := env.syncer.ListNamedSnapshots(ctx)
+	if err != nil || len(named) != 1 || named[0].ID != "before-experiment" {
+		t.Fatalf("Unexpected named snapshots %v (%v)", named, err)
+	}
+
+	plan, err := env.syncer.PlanRestoreNamed(ctx, "before-experiment")
+	if err != nil {
+		t.Fatalf("PlanRestoreNamed failed: %v", err)
+	}
+	if _, err := env.syncer.Restore(ctx, plan); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if got := readFile(t, env.claudeDir, "CLAUDE.md"); got != "# Before" {
+		t.Errorf("Expected snapshot content, got %q", got)
+	}
+
+	if err := env.syncer.DeleteNamedSnapshot(ctx, "before-experiment"); err != nil {
+		t.Fatalf("DeleteNamedSnapshot failed: %v", err)
+	}
+	if _, err := env.syncer.PlanRestoreNamed(ctx, "before-experiment"); err == nil {
+		t.Error("Expected deleted snapshot to be gone")
+	}
+}
+
+func TestNamedSnapshotPinsVersions(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Versioning = true
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "1")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if _, err := env.syncer.CreateNamedSnapshot(ctx, "v1", false); err != nil {
+		t.Fatalf("CreateNamedSnapshot failed: %v", err)
+	}
+	for _, content := range []string{"2", "3"} {
+		time.Sleep(5 * time.Millisecond)
+		writeFile(t, env.claudeDir, "CLAUDE.md", content)
+		if _, err := env.syncer.Push(ctx); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+	}
+
+	env.syncer.cfg.VersionsKeep = 1
+	result, err := env.syncer.PruneVersions(ctx, false)
+	if err != nil {
+		t.Fatalf("PruneVersions failed: %v", err)
+	}
+	if len(result.Pruned) != 1 || result.Kept != 2 {
+		t.Fatalf("Expected 1 pruned and 2 kept, got %d and %d", len(result.Pruned), result.Kept)
+	}
+	plan, err := env.syncer.PlanRestoreNamed(ctx, "v1")
+	if err != nil {
+		t.Fatalf("PlanRestoreNamed failed: %v", err)
+	}
+	if len(plan.Missing) != 0 || len(plan.Items) != 1 {
+		t.Fatalf("Expected the pinned version to restore, got %+v", plan)
+	}
+	data, err := env.syncer.FetchVersion(ctx, plan.Items[0].Version)
+	if err != nil || string(data) != "1" {
+		t.Errorf("Expected pinned content 1, got %q (%v)", data, err)
+	}
+}
+
+func TestValidateSnapshotName(t *testing.T) {
+	for _, name := range []string{"before-experiment", "v1.2", "2024_05"} {
+		if err := validateSnapshotName(name); err != nil {
+			t.Errorf("Expected %q to be valid: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "current", "../x", "a/b", "-x", "has space"} {
+		if err := validateSnapshotName(name); err == nil {
+			t.Errorf("Expected %q to be invalid", name)
+		}
+	}
+}
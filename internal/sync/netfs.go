@@ -0,0 +1,48 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// NetworkFS returns the filesystem type of ~/.claude when it is on a network
+// filesystem (NFS, SMB, ...), or "" when it is local.
+//
+// Several change-detection heuristics assume a local disk: mtimes set by a
+// file server follow its clock rather than ours, and a file may be visible
+// before another client has finished writing it. On a network filesystem,
+// pull compares contents instead of trusting mtimes, and state is flushed to
+// disk before it replaces the previous copy.
+func (s *Syncer) NetworkFS() string {
+	return s.netFS
+}
+
+// networkFSFor decides whether claudeDir should be treated as a network
+// filesystem: override, when set, wins over detection.
+func networkFSFor(claudeDir string, override *bool) string {
+	if override != nil {
+		if !*override {
+			return ""
+		}
+		if fsType := detectNetworkFS(existingDir(claudeDir)); fsType != "" {
+			return fsType
+		}
+		return "network"
+	}
+	return detectNetworkFS(existingDir(claudeDir))
+}
+
+// existingDir returns path or its nearest existing parent, so detection
+// works before ~/.claude is created.
+func existingDir(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
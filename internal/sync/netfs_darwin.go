@@ -0,0 +1,29 @@
+package sync
+
+import "syscall"
+
+// networkFSTypes are the statfs(2) f_fstypename values of network filesystems.
+var networkFSTypes = map[string]bool{
+	"nfs":    true,
+	"smbfs":  true,
+	"afpfs":  true,
+	"webdav": true,
+}
+
+func detectNetworkFS(path string) string {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return ""
+	}
+	var name []byte
+	for _, c := range st.Fstypename {
+		if c == 0 {
+			break
+		}
+		name = append(name, byte(c))
+	}
+	if networkFSTypes[string(name)] {
+		return string(name)
+	}
+	return ""
+}
@@ -0,0 +1,24 @@
+package sync
+
+import "syscall"
+
+// Filesystem magic numbers from statfs(2) for network filesystems.
+var networkFSMagic = map[int64]string{
+	0x6969:     "nfs",
+	0x517B:     "smb",
+	0xFF534D42: "cifs",
+	0xFE534D42: "smb2",
+	0x5346414F: "afs",
+	0x73757245: "coda",
+	0x01021997: "9p",
+	0x00C36400: "ceph",
+	0x0BD00BD0: "lustre",
+}
+
+func detectNetworkFS(path string) string {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return ""
+	}
+	return networkFSMagic[int64(st.Type)]
+}
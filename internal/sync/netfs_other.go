@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !windows
+
+package sync
+
+func detectNetworkFS(path string) string {
+	return ""
+}
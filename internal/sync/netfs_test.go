@@ -0,0 +1,73 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNetworkFSOverride(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "not-created-yet")
+	on, off := true, false
+	if got := networkFSFor(dir, &off); got != "" {
+		t.Errorf("Expected override off to win, got %q", got)
+	}
+	if got := networkFSFor(dir, &on); got == "" {
+		t.Error("Expected override on to report a network filesystem")
+	}
+}
+
+func TestPullOnNetworkFSComparesContents(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "agents/same.md", "same")
+	writeFile(t, env.claudeDir, "agents/differs.md", "remote")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	// Untracked local copies: on a local disk pull would decide by mtime
+	env.syncer.state.RemoveFile("agents/same.md")
+	env.syncer.state.RemoveFile("agents/differs.md")
+	writeFile(t, env.claudeDir, "agents/differs.md", "local")
+	env.syncer.netFS = "nfs"
+
+	preview, err := env.syncer.PreviewPull(ctx)
+	if err != nil {
+		t.Fatalf("PreviewPull failed: %v", err)
+	}
+	if len(preview.WouldOverwrite) != 0 || len(preview.WouldConflict) != 2 {
+		t.Errorf("Unexpected preview: %d overwrite, %d conflict", len(preview.WouldOverwrite), len(preview.WouldConflict))
+	}
+
+	result, err := env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0] != "agents/differs.md" {
+		t.Fatalf("Expected one conflict, got %v (errors: %v)", result.Conflicts, result.Errors)
+	}
+	if got := readFile(t, env.claudeDir, "agents/differs.md"); got != "local" {
+		t.Errorf("Expected local content kept, got %q", got)
+	}
+	if env.syncer.state.GetFile("agents/same.md") == nil {
+		t.Error("Expected identical file to be tracked after pull")
+	}
+}
+
+func TestStateSaveWithSyncWrites(t *testing.T) {
+	dir := t.TempDir()
+	state, err := LoadStateFromDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.syncWrites = true
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := os.Stat(state.path()); err != nil {
+		t.Errorf("Expected state file: %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package sync
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+func detectNetworkFS(path string) string {
+	volume := filepath.VolumeName(path)
+	if strings.HasPrefix(volume, `\\`) {
+		return "smb"
+	}
+	if volume == "" {
+		return ""
+	}
+	root, err := windows.UTF16PtrFromString(volume + `\`)
+	if err != nil {
+		return ""
+	}
+	if windows.GetDriveType(root) == windows.DRIVE_REMOTE {
+		return "smb"
+	}
+	return ""
+}
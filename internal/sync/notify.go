@@ -0,0 +1,258 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+)
+
+// EventKind is the kind of a notification event.
+type EventKind string
+
+const (
+	EventSyncStarted     EventKind = "sync_started"
+	EventSyncFinished    EventKind = "sync_finished"
+	EventConflict        EventKind = "conflict"
+	EventError           EventKind = "error"
+	EventUpdateAvailable EventKind = "update_available"
+	EventStale           EventKind = "stale"
+)
+
+// defaultEvents are the events a notifier gets when it doesn't list any:
+// the ones that need the user's attention.
+var defaultEvents = []EventKind{EventConflict, EventError, EventUpdateAvailable, EventStale}
+
+var eventKinds = []EventKind{EventSyncStarted, EventSyncFinished, EventConflict, EventError, EventUpdateAvailable, EventStale}
+
+// Event is something a notifier is told about.
+type Event struct {
+	Kind      EventKind `json:"kind"`
+	Time      time.Time `json:"time"`
+	Device    string    `json:"device,omitempty"`
+	Operation string    `json:"operation,omitempty"` // "push" or "pull"
+	Path      string    `json:"path,omitempty"`      // The conflicting file
+	Version   string    `json:"version,omitempty"`   // The available update
+	Message   string    `json:"message"`
+
+	// Status is "ok" or "failed" on sync_finished, which is sent after
+	// every push and pull; a run whose files partly failed is "failed" too.
+	Status     string `json:"status,omitempty"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	Uploaded   int    `json:"uploaded,omitempty"`
+	Downloaded int    `json:"downloaded,omitempty"`
+	Deleted    int    `json:"deleted,omitempty"`
+	Conflicts  int    `json:"conflicts,omitempty"`
+	Failed     int    `json:"failed,omitempty"` // Files that failed to sync
+}
+
+// Title is a short heading for the event, as used for desktop notifications
+// and email subjects.
+func (e Event) Title() string {
+	switch e.Kind {
+	case EventSyncStarted:
+		return "claude-sync: " + e.Operation + " started"
+	case EventSyncFinished:
+		if e.Status == "failed" {
+			return "claude-sync: " + e.Operation + " failed"
+		}
+		return "claude-sync: " + e.Operation + " finished"
+	case EventConflict:
+		return "claude-sync: conflict"
+	case EventError:
+		return "claude-sync: " + e.Operation + " failed"
+	case EventUpdateAvailable:
+		return "claude-sync: update available"
+	case EventStale:
+		return "claude-sync: changes not pushed"
+	}
+	return "claude-sync"
+}
+
+// Notifier delivers events over one channel. A failed notification never
+// fails the sync it is about.
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// NotifierFactory builds a notifier from its config.
+type NotifierFactory func(cfg config.NotifierConfig) (Notifier, error)
+
+var notifierFactories = make(map[string]NotifierFactory)
+
+// RegisterNotifier makes a notifier type available to the notify config.
+// The built-in channels (internal/notify) register themselves when that
+// package is imported.
+func RegisterNotifier(kind string, factory NotifierFactory) {
+	notifierFactories[kind] = factory
+}
+
+// NewNotifier builds the notifiers in cfgs, each passed only the events it
+// asked for. It returns nil when cfgs is empty.
+func NewNotifier(cfgs []config.NotifierConfig) (Notifier, error) {
+	var notifiers multiNotifier
+	for _, cfg := range cfgs {
+		factory, ok := notifierFactories[cfg.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown notifier type %q (want one of: %s)", cfg.Type, strings.Join(notifierTypes(), ", "))
+		}
+		n, err := factory(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %s: %w", cfg.Type, err)
+		}
+
+		wanted := defaultEvents
+		if len(cfg.Events) > 0 {
+			wanted = nil
+			for _, e := range cfg.Events {
+				kind := EventKind(e)
+				if !isEventKind(kind) {
+					return nil, fmt.Errorf("notifier %s: unknown event %q", cfg.Type, e)
+				}
+				wanted = append(wanted, kind)
+			}
+		}
+		events := make(map[EventKind]bool, len(wanted))
+		for _, kind := range wanted {
+			events[kind] = true
+		}
+		notifiers = append(notifiers, filteredNotifier{Notifier: n, events: events})
+	}
+	if len(notifiers) == 0 {
+		return nil, nil
+	}
+	return notifiers, nil
+}
+
+func notifierTypes() []string {
+	types := make([]string, 0, len(notifierFactories))
+	for kind := range notifierFactories {
+		types = append(types, kind)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func isEventKind(kind EventKind) bool {
+	for _, k := range eventKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+type filteredNotifier struct {
+	Notifier
+	events map[EventKind]bool
+}
+
+type multiNotifier []filteredNotifier
+
+func (m multiNotifier) Notify(ctx context.Context, event Event) error {
+	var errs []error
+	for _, n := range m {
+		if !n.events[event.Kind] {
+			continue
+		}
+		if err := n.Notify(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SetNotifier replaces the notifiers built from the config.
+func (s *Syncer) SetNotifier(n Notifier) {
+	s.notifier = n
+}
+
+func (s *Syncer) notify(ctx context.Context, event Event) {
+	if s.notifier == nil {
+		return
+	}
+	event.Time = time.Now()
+	event.Device = s.state.DeviceID
+	if err := s.notifier.Notify(ctx, event); err != nil {
+		s.log("Notification failed: %v", err)
+	}
+}
+
+// Push uploads local changes (see push), notifying the configured
+// notifiers as it starts and finishes.
+func (s *Syncer) Push(ctx context.Context) (*SyncResult, error) {
+	return s.notifyRun(ctx, "push", s.push)
+}
+
+// PushSelected pushes the local changes at paths and holds back every other
+// one, listing them in SyncResult.Skipped; the next push offers them again.
+func (s *Syncer) PushSelected(ctx context.Context, paths []string) (*SyncResult, error) {
+	return s.notifyRun(ctx, "push", func(ctx context.Context) (*SyncResult, error) {
+		s.only = make(map[string]bool, len(paths))
+		for _, path := range paths {
+			s.only[path] = true
+		}
+		defer func() { s.only = nil }()
+		return s.push(ctx)
+	})
+}
+
+// Pull downloads remote changes (see pull), notifying the configured
+// notifiers as it starts and finishes.
+func (s *Syncer) Pull(ctx context.Context) (*SyncResult, error) {
+	return s.notifyRun(ctx, "pull", s.pull)
+}
+
+func (s *Syncer) notifyRun(ctx context.Context, op string, run func(context.Context) (*SyncResult, error)) (*SyncResult, error) {
+	s.notify(ctx, Event{Kind: EventSyncStarted, Operation: op, Message: op + " started"})
+	slog.InfoContext(ctx, "sync started", "op", op, "device", s.state.DeviceID)
+	began := time.Now()
+
+	result, err := run(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "sync failed", "op", op, "duration", time.Since(began).Round(time.Millisecond), "error", err)
+		s.notify(ctx, Event{Kind: EventError, Operation: op, Message: err.Error()})
+		s.notify(ctx, Event{
+			Kind:       EventSyncFinished,
+			Operation:  op,
+			Message:    op + " failed: " + err.Error(),
+			Status:     "failed",
+			DurationMS: time.Since(began).Milliseconds(),
+		})
+		return result, err
+	}
+	slog.InfoContext(ctx, "sync finished", "op", op, "duration", time.Since(began).Round(time.Millisecond),
+		"uploaded", len(result.Uploaded), "downloaded", len(result.Downloaded), "deleted", len(result.Deleted),
+		"conflicts", len(result.Conflicts), "errors", len(result.Errors), "requests", result.Requests.String())
+
+	for _, path := range result.Conflicts {
+		s.notify(ctx, Event{Kind: EventConflict, Operation: op, Path: path,
+			Message: "Conflict in " + path + "; run 'claude-sync conflicts' to resolve it"})
+	}
+	if len(result.Errors) > 0 {
+		s.notify(ctx, Event{Kind: EventError, Operation: op,
+			Message: fmt.Sprintf("%d file(s) failed to %s: %v", len(result.Errors), op, result.Errors[0])})
+	}
+	status := "ok"
+	if len(result.Errors) > 0 {
+		status = "failed"
+	}
+	s.notify(ctx, Event{
+		Kind:       EventSyncFinished,
+		Operation:  op,
+		Message:    fmt.Sprintf("%s finished: %d uploaded, %d downloaded, %d deleted", op, len(result.Uploaded), len(result.Downloaded), len(result.Deleted)),
+		Status:     status,
+		DurationMS: time.Since(began).Milliseconds(),
+		Uploaded:   len(result.Uploaded),
+		Downloaded: len(result.Downloaded),
+		Deleted:    len(result.Deleted),
+		Conflicts:  len(result.Conflicts),
+		Failed:     len(result.Errors),
+	})
+	return result, nil
+}
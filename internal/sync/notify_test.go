@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+)
+
+type recordingNotifier struct {
+	events []Event
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, event Event) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recordingNotifier) kinds() []EventKind {
+	kinds := make([]EventKind, len(r.events))
+	for i, e := range r.events {
+		kinds[i] = e.Kind
+	}
+	return kinds
+}
+
+func TestPushNotifies(t *testing.T) {
+	env := setupTestEnv(t)
+	rec := &recordingNotifier{}
+	env.syncer.SetNotifier(rec)
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	pushOK(t, env)
+
+	kinds := rec.kinds()
+	if len(kinds) != 2 || kinds[0] != EventSyncStarted || kinds[1] != EventSyncFinished {
+		t.Fatalf("Expected started and finished, got %v", kinds)
+	}
+	if finished := rec.events[1]; finished.Operation != "push" || finished.Uploaded != 1 || finished.Device == "" || finished.Status != "ok" {
+		t.Errorf("Unexpected finished event %+v", finished)
+	}
+}
+
+func TestNotifyRunReportsProblems(t *testing.T) {
+	env := setupTestEnv(t)
+	rec := &recordingNotifier{}
+	env.syncer.SetNotifier(rec)
+	ctx := context.Background()
+
+	_, _ = env.syncer.notifyRun(ctx, "pull", func(context.Context) (*SyncResult, error) {
+		return &SyncResult{Conflicts: []string{"CLAUDE.md"}, Errors: []error{errors.New("boom")}}, nil
+	})
+	want := []EventKind{EventSyncStarted, EventConflict, EventError, EventSyncFinished}
+	if got := rec.kinds(); len(got) != len(want) || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	if rec.events[1].Path != "CLAUDE.md" {
+		t.Errorf("Expected the conflicting path, got %q", rec.events[1].Path)
+	}
+	if finished := rec.events[3]; finished.Status != "failed" || finished.Conflicts != 1 || finished.Failed != 1 {
+		t.Errorf("Unexpected finished event %+v", finished)
+	}
+
+	rec.events = nil
+	if _, err := env.syncer.notifyRun(ctx, "push", func(context.Context) (*SyncResult, error) {
+		return nil, errors.New("offline")
+	}); err == nil {
+		t.Fatal("Expected the error returned")
+	}
+	if got := rec.kinds(); len(got) != 3 || got[1] != EventError || rec.events[1].Message != "offline" {
+		t.Errorf("Expected an error event, got %+v", rec.events)
+	}
+	if finished := rec.events[len(rec.events)-1]; finished.Kind != EventSyncFinished || finished.Status != "failed" || finished.Title() != "claude-sync: push failed" {
+		t.Errorf("Expected a failed finished event, got %+v", finished)
+	}
+}
+
+func TestNewNotifierFiltersEvents(t *testing.T) {
+	rec := &recordingNotifier{}
+	RegisterNotifier("test", func(config.NotifierConfig) (Notifier, error) { return rec, nil })
+	ctx := context.Background()
+
+	n, err := NewNotifier([]config.NotifierConfig{{Type: "test"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kind := range eventKinds {
+		_ = n.Notify(ctx, Event{Kind: kind})
+	}
+	if got := rec.kinds(); len(got) != len(defaultEvents) {
+		t.Errorf("Expected only the default events, got %v", got)
+	}
+
+	rec.events = nil
+	n, _ = NewNotifier([]config.NotifierConfig{{Type: "test", Events: []string{"sync_finished"}}})
+	_ = n.Notify(ctx, Event{Kind: EventConflict})
+	_ = n.Notify(ctx, Event{Kind: EventSyncFinished})
+	if got := rec.kinds(); len(got) != 1 || got[0] != EventSyncFinished {
+		t.Errorf("Expected only sync_finished, got %v", got)
+	}
+
+	if _, err := NewNotifier([]config.NotifierConfig{{Type: "pager"}}); err == nil {
+		t.Error("Expected an unknown type refused")
+	}
+	if _, err := NewNotifier([]config.NotifierConfig{{Type: "test", Events: []string{"everything"}}}); err == nil {
+		t.Error("Expected an unknown event refused")
+	}
+	if n, err := NewNotifier(nil); n != nil || err != nil {
+		t.Errorf("Expected no notifier without config, got %v, %v", n, err)
+	}
+}
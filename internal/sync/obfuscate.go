@@ -0,0 +1,258 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// KeyNamesKey holds the bucket's key index when obfuscate_keys is on: the
+// secret opaque object keys are derived from, and the path behind each key.
+const KeyNamesKey = "_metadata/names.json.age"
+
+// ObjectPrefix is where files are stored when obfuscate_keys is on.
+const ObjectPrefix = "o/"
+
+// keyIndex is the encrypted content of KeyNamesKey.
+type keyIndex struct {
+	Secret []byte            `json:"secret"`
+	Names  map[string]string `json:"names"` // Opaque key (without .age) → normalized path
+}
+
+// keyNamer derives opaque object keys as an HMAC-SHA256 of a file's
+// normalized path, and maps them back through the key index.
+type keyNamer struct {
+	mu     sync.Mutex
+	index  keyIndex
+	stored bool // The secret is the bucket's, not one generated on this device
+}
+
+func (n *keyNamer) name(normPath string) string {
+	mac := hmac.New(sha256.New, n.index.Secret)
+	mac.Write([]byte(normPath))
+	return ObjectPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (n *keyNamer) path(name string) (string, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	path, ok := n.index.Names[name]
+	return path, ok
+}
+
+// KeyRename is an object ObfuscateKeys moves to its opaque key.
+type KeyRename struct {
+	Path string
+	From string
+	To   string
+}
+
+// loadKeyNames reads the bucket's key index. A bucket without one gets a new
+// secret, stored by the first push.
+func (s *Syncer) loadKeyNames(ctx context.Context) error {
+	var index keyIndex
+	found, err := s.downloadKeyIndex(ctx, &index)
+	if err != nil {
+		return err
+	}
+	if !found {
+		index.Secret = make([]byte, 32)
+		if _, err := rand.Read(index.Secret); err != nil {
+			return fmt.Errorf("failed to generate key secret: %w", err)
+		}
+	}
+	if index.Names == nil {
+		index.Names = make(map[string]string)
+	}
+	s.keys = &keyNamer{index: index, stored: found}
+	return nil
+}
+
+func (s *Syncer) downloadKeyIndex(ctx context.Context, index *keyIndex) (bool, error) {
+	objects, err := s.storage.List(ctx, KeyNamesKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to list remote files: %w", err)
+	}
+	for _, obj := range objects {
+		if obj.Key != KeyNamesKey {
+			continue
+		}
+		if err := s.downloadJSON(ctx, KeyNamesKey, index); err != nil {
+			return false, fmt.Errorf("failed to read key index: %w", err)
+		}
+		if len(index.Secret) == 0 {
+			return false, errors.New("the bucket's key index has no secret")
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// recordKeyNames adds the opaque keys of paths to the bucket's key index. It
+// runs before anything is uploaded under those keys, so every object can be
+// mapped back to its path. The remote index is merged in first, and the
+// upload is conditional on it being unchanged (see updateJSON), so names
+// two devices record at once both end up in it. If another device stored
+// the bucket's first secret meanwhile, this one adopts it.
+func (s *Syncer) recordKeyNames(ctx context.Context, paths []string) error {
+	if s.keys == nil {
+		return nil
+	}
+	n := s.keys
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	missing := !n.stored
+	for _, path := range paths {
+		norm := s.paths.NormalizeRelPath(path)
+		if n.index.Names[n.name(norm)] != norm {
+			missing = true
+		}
+	}
+	if !missing {
+		return nil
+	}
+
+	err := updateJSON(ctx, s, KeyNamesKey, func(index *keyIndex, found bool) error {
+		if found {
+			if len(index.Secret) == 0 {
+				return errors.New("the bucket's key index has no secret")
+			}
+			if err := n.merge(*index); err != nil {
+				return err
+			}
+		}
+		for _, path := range paths {
+			norm := s.paths.NormalizeRelPath(path)
+			n.index.Names[n.name(norm)] = norm
+		}
+		*index = n.index
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update key index: %w", err)
+	}
+	n.stored = true
+	return nil
+}
+
+// refreshKeyNames merges in names other devices added to the key index since
+// it was loaded.
+func (s *Syncer) refreshKeyNames(ctx context.Context) error {
+	if s.keys == nil {
+		return nil
+	}
+	var remote keyIndex
+	found, err := s.downloadKeyIndex(ctx, &remote)
+	if err != nil || !found {
+		return err
+	}
+	s.keys.mu.Lock()
+	defer s.keys.mu.Unlock()
+	return s.keys.merge(remote)
+}
+
+// merge adds the names in the bucket's index to n. A secret generated on
+// this device gives way to one already in the bucket. Callers hold n.mu.
+func (n *keyNamer) merge(remote keyIndex) error {
+	switch {
+	case bytes.Equal(remote.Secret, n.index.Secret):
+		for name, norm := range remote.Names {
+			n.index.Names[name] = norm
+		}
+	case n.stored:
+		return errors.New("the bucket's key index was replaced; run the command again")
+	default:
+		n.index = remote
+		if n.index.Names == nil {
+			n.index.Names = make(map[string]string)
+		}
+	}
+	n.stored = true
+	return nil
+}
+
+// unresolvedKeyError explains why buildRemoteMap couldn't map key to a path.
+func (s *Syncer) unresolvedKeyError(key string) error {
+	switch {
+	case !strings.HasPrefix(key, ObjectPrefix):
+		return fmt.Errorf("%s: unknown path token; add the matching path_map entry on this device", key)
+	case s.keys == nil:
+		return fmt.Errorf("%s: obfuscated key; set 'obfuscate_keys: true' on this device", key)
+	default:
+		return fmt.Errorf("%s: not in the bucket's key index", key)
+	}
+}
+
+// ObfuscateKeys moves files and their versions stored under readable keys to
+// their opaque keys. Files in the trash keep their readable keys until the
+// trash is emptied. With dryRun, nothing is moved.
+func (s *Syncer) ObfuscateKeys(ctx context.Context, dryRun bool) ([]KeyRename, error) {
+	if s.keys == nil {
+		return nil, errors.New("set 'obfuscate_keys: true' in the config first")
+	}
+	objects, err := s.storage.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote files: %w", err)
+	}
+
+	var renames []KeyRename
+	var paths []string
+	for _, obj := range objects {
+		key := obj.Key
+		if !strings.HasSuffix(key, ".age") {
+			continue
+		}
+		var rename KeyRename
+		if rest, isVersion := strings.CutPrefix(key, VersionPrefix); isVersion {
+			i := strings.LastIndex(rest, "/")
+			if i < 0 || strings.HasPrefix(rest, ObjectPrefix) {
+				continue
+			}
+			path, ok := s.localPath(rest[:i] + ".age")
+			if !ok {
+				continue
+			}
+			rename = KeyRename{Path: path, From: key, To: s.versionDir(path) + rest[i+1:]}
+		} else {
+			if isReservedKey(key) || strings.HasPrefix(key, ObjectPrefix) {
+				continue
+			}
+			path, ok := s.localPath(key)
+			if !ok {
+				continue
+			}
+			rename = KeyRename{Path: path, From: key, To: s.remoteKey(path)}
+		}
+		renames = append(renames, rename)
+		paths = append(paths, rename.Path)
+	}
+	sort.Slice(renames, func(i, j int) bool { return renames[i].From < renames[j].From })
+	if dryRun || len(renames) == 0 {
+		return renames, nil
+	}
+
+	if err := s.recordKeyNames(ctx, paths); err != nil {
+		return nil, err
+	}
+	moved := make([]string, 0, len(renames))
+	for _, r := range renames {
+		if err := copyObject(ctx, s.storage, r.From, r.To); err != nil {
+			return nil, fmt.Errorf("failed to copy %s: %w", r.From, err)
+		}
+		moved = append(moved, r.From)
+	}
+	if err := s.storage.DeleteBatch(ctx, moved); err != nil {
+		return renames, fmt.Errorf("copied, but failed to delete readable keys: %w", err)
+	}
+	_, _ = s.listRemote(ctx)
+	return renames, nil
+}
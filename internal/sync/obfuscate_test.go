@@ -0,0 +1,213 @@
+package sync
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// enableObfuscation turns obfuscate_keys on for env and loads the bucket's
+// key index, as NewSyncer does.
+func enableObfuscation(t *testing.T, env *testEnv) {
+	t.Helper()
+	env.syncer.cfg.ObfuscateKeys = true
+	if err := env.syncer.loadKeyNames(context.Background()); err != nil {
+		t.Fatalf("loadKeyNames failed: %v", err)
+	}
+}
+
+// sharedBucketEnv returns a second device on env's bucket and key.
+func sharedBucketEnv(t *testing.T, env *testEnv) *testEnv {
+	t.Helper()
+	other := setupTestEnv(t)
+	other.store = env.store
+	other.syncer.storage = env.store
+	other.syncer.encryptor = env.syncer.encryptor
+	return other
+}
+
+func TestObfuscatedPushHidesPaths(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	enableObfuscation(t, env)
+
+	writeFile(t, env.claudeDir, "projects/secret-app/notes.md", "hello")
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	for key := range env.store.objects {
+		if strings.Contains(key, "secret-app") || strings.Contains(key, "CLAUDE") {
+			t.Errorf("Remote key %s reveals a path", key)
+		}
+	}
+	if _, ok := env.store.objects[KeyNamesKey]; !ok {
+		t.Fatal("Expected the key index uploaded")
+	}
+
+	other := sharedBucketEnv(t, env)
+	enableObfuscation(t, other)
+	result, err := other.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.Downloaded) != 2 || len(result.Errors) != 0 {
+		t.Fatalf("Expected both files pulled, got %+v", result)
+	}
+	if got := readFile(t, other.claudeDir, "projects/secret-app/notes.md"); got != "hello" {
+		t.Errorf("Expected pulled content, got %q", got)
+	}
+}
+
+func TestObfuscatedKeysNeedTheSetting(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	enableObfuscation(t, env)
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	other := sharedBucketEnv(t, env)
+	result, err := other.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.Downloaded) != 0 {
+		t.Errorf("Expected nothing pulled under an opaque key, got %v", result.Downloaded)
+	}
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Error(), "obfuscate_keys") {
+		t.Errorf("Expected an error pointing at obfuscate_keys, got %v", result.Errors)
+	}
+}
+
+func TestObfuscationAdoptsFirstStoredSecret(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	other := sharedBucketEnv(t, env)
+
+	// Both devices start on an empty bucket and generate a secret
+	enableObfuscation(t, env)
+	enableObfuscation(t, other)
+
+	writeFile(t, env.claudeDir, "agents/a.md", "a")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	writeFile(t, other.claudeDir, "agents/b.md", "b")
+	if _, err := other.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if env.syncer.remoteKey("agents/b.md") != other.syncer.remoteKey("agents/b.md") {
+		t.Fatal("Expected the second device to adopt the bucket's secret")
+	}
+	result, err := env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.Downloaded) != 1 || result.Downloaded[0] != "agents/b.md" || len(result.Errors) != 0 {
+		t.Errorf("Expected the other device's file pulled, got %+v", result)
+	}
+}
+
+func TestObfuscateKeysMovesReadableKeys(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	env.syncer.cfg.Versioning = true
+
+	writeFile(t, env.claudeDir, "agents/a.md", "a")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	enableObfuscation(t, env)
+	planned, err := env.syncer.ObfuscateKeys(ctx, true)
+	if err != nil {
+		t.Fatalf("ObfuscateKeys dry run failed: %v", err)
+	}
+	if len(planned) != 2 {
+		t.Fatalf("Expected the file and its version planned, got %+v", planned)
+	}
+	if _, ok := env.store.objects["agents/a.md.age"]; !ok {
+		t.Fatal("Dry run moved an object")
+	}
+
+	if _, err := env.syncer.ObfuscateKeys(ctx, false); err != nil {
+		t.Fatalf("ObfuscateKeys failed: %v", err)
+	}
+	for key := range env.store.objects {
+		if strings.Contains(key, "agents") {
+			t.Errorf("Readable key %s left behind", key)
+		}
+	}
+	versions, err := env.syncer.ListVersions(ctx, "agents/a.md")
+	if err != nil || len(versions) != 1 {
+		t.Errorf("Expected the version moved with the file, got %v, %v", versions, err)
+	}
+
+	other := sharedBucketEnv(t, env)
+	enableObfuscation(t, other)
+	result, err := other.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if got := readFile(t, other.claudeDir, "agents/a.md"); got != "a" || len(result.Errors) != 0 {
+		t.Errorf("Expected the moved file pulled, got %q, %v", got, result.Errors)
+	}
+}
+
+// racingStorage runs race before the first conditional upload of key, as if
+// another device wrote in between.
+type racingStorage struct {
+	*mockStorage
+	key  string
+	race func()
+}
+
+func (r *racingStorage) UploadIfMatch(ctx context.Context, key string, data []byte, etag string) error {
+	if key == r.key && r.race != nil {
+		race := r.race
+		r.race = nil
+		race()
+	}
+	return r.mockStorage.UploadIfMatch(ctx, key, data, etag)
+}
+
+func TestRecordKeyNamesKeepsConcurrentNames(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	enableObfuscation(t, env)
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	other := sharedBucketEnv(t, env)
+	enableObfuscation(t, other)
+
+	// The other device records its name between this one's read and write
+	racing := &racingStorage{mockStorage: env.store, key: KeyNamesKey, race: func() {
+		if err := other.syncer.recordKeyNames(ctx, []string{"agents/b.md"}); err != nil {
+			t.Errorf("recordKeyNames on the other device failed: %v", err)
+		}
+	}}
+	env.syncer.storage = racing
+	if err := env.syncer.recordKeyNames(ctx, []string{"agents/a.md"}); err != nil {
+		t.Fatalf("recordKeyNames failed: %v", err)
+	}
+	if racing.race != nil {
+		t.Fatal("Expected the key index written conditionally")
+	}
+
+	var index keyIndex
+	if err := other.syncer.downloadJSON(ctx, KeyNamesKey, &index); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"CLAUDE.md", "agents/a.md", "agents/b.md"} {
+		if index.Names[env.syncer.keys.name(path)] != path {
+			t.Errorf("Key index lost %s: %v", path, index.Names)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package sync
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Pause is a temporary exclusion recorded in state by 'claude-sync pause'.
+// Unlike an exclude pattern, a paused path is held back rather than dropped:
+// push neither uploads nor deletes it and pull leaves the local copy alone,
+// so nothing half-finished reaches other devices until it is resumed.
+type Pause struct {
+	PausedAt time.Time `json:"paused_at"`
+	Until    time.Time `json:"until,omitempty"` // Zero means until resumed
+}
+
+// PausedPath is a pause together with the path it covers.
+type PausedPath struct {
+	Path string `json:"path"`
+	Pause
+}
+
+// Pause holds back path (a file or directory relative to the Claude
+// directory) until the given time, or until resumed if until is zero.
+func (s *SyncState) Pause(path string, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Paused == nil {
+		s.Paused = make(map[string]Pause)
+	}
+	s.Paused[cleanPausePath(path)] = Pause{PausedAt: time.Now(), Until: until}
+}
+
+// Resume lifts the pause on path, reporting whether there was one.
+func (s *SyncState) Resume(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path = cleanPausePath(path)
+	if _, ok := s.Paused[path]; !ok {
+		return false
+	}
+	delete(s.Paused, path)
+	return true
+}
+
+// PausedPaths returns the pauses still in effect at now, sorted by path.
+// Expired pauses are dropped from state.
+func (s *SyncState) PausedPaths(now time.Time) []PausedPath {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var paths []PausedPath
+	for path, p := range s.Paused {
+		if !p.Until.IsZero() && !now.Before(p.Until) {
+			delete(s.Paused, path)
+			continue
+		}
+		paths = append(paths, PausedPath{Path: path, Pause: p})
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i].Path < paths[j].Path })
+	return paths
+}
+
+// IsPaused reports whether relPath is, or is inside, a path paused at now.
+func (s *SyncState) IsPaused(relPath string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for path, p := range s.Paused {
+		if !p.Until.IsZero() && !now.Before(p.Until) {
+			continue
+		}
+		if relPath == path || strings.HasPrefix(relPath, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func cleanPausePath(path string) string {
+	return strings.TrimSuffix(path, "/")
+}
+
+// isPaused reports whether relPath is held back by a pause right now.
+func (s *Syncer) isPaused(relPath string) bool {
+	return s.state.IsPaused(relPath, time.Now())
+}
+
+// dropPaused removes changes to paused paths, returning those kept and the
+// paths held back.
+func (s *Syncer) dropPaused(changes []FileChange) (kept []FileChange, paused []string) {
+	if len(s.state.Paused) == 0 {
+		return changes, nil
+	}
+	for _, change := range changes {
+		if s.isPaused(change.Path) {
+			paused = append(paused, change.Path)
+			continue
+		}
+		kept = append(kept, change)
+	}
+	sort.Strings(paused)
+	return kept, paused
+}
@@ -0,0 +1,102 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPauseHoldsBackPush(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "agents/a.md", "v1")
+	writeFile(t, env.claudeDir, "agents/b.md", "v1")
+	writeFile(t, env.claudeDir, "CLAUDE.md", "v1")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	env.syncer.state.Pause("agents/", time.Time{})
+	writeFile(t, env.claudeDir, "agents/a.md", "v2")
+	if err := os.Remove(filepath.Join(env.claudeDir, "agents", "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, env.claudeDir, "CLAUDE.md", "v2")
+
+	changes, err := env.syncer.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Path != "CLAUDE.md" {
+		t.Errorf("Expected only CLAUDE.md pending, got %+v", changes)
+	}
+
+	result, err := env.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if len(result.Uploaded) != 1 || len(result.Deleted) != 0 {
+		t.Errorf("Expected only CLAUDE.md pushed, got %+v", result)
+	}
+	if len(result.Paused) != 2 {
+		t.Errorf("Expected 2 held-back changes, got %v", result.Paused)
+	}
+	if _, ok := env.store.objects["agents/b.md.age"]; !ok {
+		t.Error("Paused deletion reached the remote")
+	}
+
+	if !env.syncer.state.Resume("agents") {
+		t.Fatal("Expected agents to be paused")
+	}
+	result, err = env.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if len(result.Uploaded) != 1 || len(result.Deleted) != 1 {
+		t.Errorf("Expected held-back changes to push after resume, got %+v", result)
+	}
+}
+
+func TestPauseHoldsBackPull(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	uploadRemote(t, env, "agents/a.md", "remote")
+	env.syncer.state.Pause("agents", time.Time{})
+
+	result, err := env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.Downloaded) != 0 {
+		t.Errorf("Expected nothing pulled into a paused path, got %v", result.Downloaded)
+	}
+	if _, err := os.Stat(filepath.Join(env.claudeDir, "agents", "a.md")); !os.IsNotExist(err) {
+		t.Error("Paused file was written locally")
+	}
+}
+
+func TestPauseExpires(t *testing.T) {
+	state := &SyncState{Files: make(map[string]*FileState)}
+	now := time.Now()
+	state.Pause("agents", now.Add(time.Hour))
+	state.Pause("skills", time.Time{})
+
+	if !state.IsPaused("agents/a.md", now) || state.IsPaused("agentsx/a.md", now) {
+		t.Error("Expected agents/ paused and agentsx/ not")
+	}
+	if state.IsPaused("agents/a.md", now.Add(2*time.Hour)) {
+		t.Error("Expected the pause to expire")
+	}
+
+	paused := state.PausedPaths(now.Add(2 * time.Hour))
+	if len(paused) != 1 || paused[0].Path != "skills" {
+		t.Errorf("Expected only skills left paused, got %+v", paused)
+	}
+	if _, ok := state.Paused["agents"]; ok {
+		t.Error("Expected the expired pause to be dropped")
+	}
+}
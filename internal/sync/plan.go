@@ -0,0 +1,248 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// ErrNoRemoteCache is returned by offline planning before any remote listing
+// has been cached on this device.
+var ErrNoRemoteCache = errors.New("no cached remote listing yet; run 'claude-sync plan' or 'claude-sync pull' while online first")
+
+// RemoteCache is the last remote listing seen by this device. Keys and sizes
+// are the same information the state file already holds, so it is stored in
+// plain JSON next to it.
+type RemoteCache struct {
+	ListedAt time.Time            `json:"listed_at"`
+	Objects  []storage.ObjectInfo `json:"objects"`
+
+	// Names maps opaque keys to paths with obfuscate_keys, as the key index
+	// does, so an offline plan can place the objects. The index's secret
+	// stays in the bucket.
+	Names map[string]string `json:"names,omitempty"`
+}
+
+// Plan is what push and pull would do right now.
+type Plan struct {
+	Push []FileChange // Local changes a push would upload or delete
+	Pull *PullPreview // What a pull would do with the remote files
+
+	// Offline is set when the remote side came from the cached listing;
+	// ListedAt says how old that listing is.
+	Offline  bool
+	ListedAt time.Time
+}
+
+// Plan computes what push and pull would do, for every synced file or, with
+// paths, just for those files. Offline, the remote side comes from the
+// listing cached by the last online plan, pull, or push on this device, and
+// no network requests are made, so it works on a Syncer from
+// NewOfflineSyncer; changes pushed from other devices since then are not
+// visible. Online with paths, their objects are stat'ed in one
+// HeadBatch instead of listing the bucket.
+func (s *Syncer) Plan(ctx context.Context, offline bool, paths []string) (*Plan, error) {
+	plan := &Plan{Offline: offline}
+
+	var remoteObjects []storage.ObjectInfo
+	switch {
+	case offline:
+		cache, err := s.loadRemoteCache()
+		if err != nil {
+			return nil, err
+		}
+		remoteObjects, plan.ListedAt = cache.Objects, cache.ListedAt
+		if s.cfg.ObfuscateKeys && s.keys == nil {
+			s.keys = &keyNamer{index: keyIndex{Names: cache.Names}}
+		}
+	case len(paths) > 0:
+		found, err := s.StatRemote(ctx, paths)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range found {
+			remoteObjects = append(remoteObjects, *obj)
+		}
+		plan.ListedAt = time.Now()
+	default:
+		objects, err := s.listRemote(ctx)
+		if err != nil {
+			return nil, err
+		}
+		remoteObjects, plan.ListedAt = objects, time.Now()
+	}
+
+	var err error
+	if plan.Push, err = s.PendingPush(); err != nil {
+		return nil, err
+	}
+
+	plan.Pull, err = s.previewPullFrom(remoteObjects)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) > 0 {
+		plan.only(paths)
+	}
+	return plan, nil
+}
+
+// only narrows the plan down to paths.
+func (p *Plan) only(paths []string) {
+	wanted := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		wanted[path] = true
+	}
+
+	push := p.Push[:0]
+	for _, c := range p.Push {
+		if wanted[c.Path] {
+			push = append(push, c)
+		}
+	}
+	p.Push = push
+
+	pull := p.Pull
+	for _, list := range []*[]FilePreview{&pull.WouldDownload, &pull.WouldOverwrite, &pull.WouldKeep, &pull.WouldConflict, &pull.LocalOnlyFiles} {
+		kept := (*list)[:0]
+		for _, fp := range *list {
+			if wanted[fp.Path] {
+				kept = append(kept, fp)
+			}
+		}
+		*list = kept
+	}
+	shadowed := pull.ShadowedCommands[:0]
+	for _, c := range pull.ShadowedCommands {
+		if wanted[c.Path] {
+			shadowed = append(shadowed, c)
+		}
+	}
+	pull.ShadowedCommands = shadowed
+	pull.finish()
+}
+
+// PendingPush returns the local changes a push would upload or delete now,
+// sorted by path, leaving out those it would hold back (paused paths, closed
+// sync windows). Nothing is read from the remote.
+func (s *Syncer) PendingPush() ([]FileChange, error) {
+	changes, err := s.state.DetectChanges(s.claudeDir, s.syncPaths(), s.isExcluded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect changes: %w", err)
+	}
+	if s.forceUpload {
+		if changes, err = s.addUnchanged(changes); err != nil {
+			return nil, fmt.Errorf("failed to list local files: %w", err)
+		}
+	}
+	changes, _ = s.dropPaused(s.dropHostSkipped(changes))
+	changes, _ = s.dropOutsideWindows(changes)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// listRemote lists every remote object, picks up new names in the key index,
+// and caches the listing for offline planning. Cache write failures are ignored: the cache is an optimisation.
+func (s *Syncer) listRemote(ctx context.Context) ([]storage.ObjectInfo, error) {
+	objects, err := s.storage.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote objects: %w", err)
+	}
+	if err := s.refreshKeyNames(ctx); err != nil {
+		return nil, err
+	}
+	_ = s.saveRemoteCache(&RemoteCache{ListedAt: time.Now(), Objects: objects, Names: s.keyNames()})
+	return objects, nil
+}
+
+// keyNames copies the key index's names for the remote cache, or returns nil
+// without obfuscate_keys.
+func (s *Syncer) keyNames() map[string]string {
+	if s.keys == nil {
+		return nil
+	}
+	s.keys.mu.Lock()
+	defer s.keys.mu.Unlock()
+	names := make(map[string]string, len(s.keys.index.Names))
+	for name, path := range s.keys.index.Names {
+		names[name] = path
+	}
+	return names
+}
+
+func (s *Syncer) remoteCachePath() string {
+	return filepath.Join(filepath.Dir(s.state.path()), config.RemoteCacheFile)
+}
+
+func (s *Syncer) loadRemoteCache() (*RemoteCache, error) {
+	data, err := os.ReadFile(s.remoteCachePath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoRemoteCache
+		}
+		return nil, fmt.Errorf("failed to read remote cache: %w", err)
+	}
+	var cache RemoteCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("remote cache is corrupt (run 'claude-sync plan' online to refresh it): %w", err)
+	}
+	return &cache, nil
+}
+
+func (s *Syncer) saveRemoteCache(cache *RemoteCache) error {
+	path := s.remoteCachePath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// updateRemoteCache applies this device's own push to the cached listing, so
+// an offline plan right after a push doesn't offer to re-download files that
+// were just deleted. Without a cache there is nothing to keep current.
+func (s *Syncer) updateRemoteCache(uploaded, deleted []string) {
+	cache, err := s.loadRemoteCache()
+	if err != nil {
+		return
+	}
+
+	changed := make(map[string]bool, len(uploaded)+len(deleted))
+	for _, rel := range uploaded {
+		changed[s.remoteKey(rel)] = true
+	}
+	for _, rel := range deleted {
+		changed[s.remoteKey(rel)] = true
+	}
+
+	objects := cache.Objects[:0]
+	for _, obj := range cache.Objects {
+		if !changed[obj.Key] {
+			objects = append(objects, obj)
+		}
+	}
+	for _, rel := range uploaded {
+		// Stamp with the recorded upload time so the plan sees it as in sync
+		obj := storage.ObjectInfo{Key: s.remoteKey(rel), LastModified: time.Now()}
+		if f := s.state.GetFile(rel); f != nil {
+			obj.Size, obj.LastModified = f.Size, f.Uploaded
+		}
+		objects = append(objects, obj)
+	}
+	cache.Objects = objects
+	if names := s.keyNames(); names != nil {
+		cache.Names = names
+	}
+	_ = s.saveRemoteCache(cache)
+}
@@ -0,0 +1,150 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+func TestPlanOffline(t *testing.T) {
+	env := setupTestEnv(t)
+	meter := storage.NewMetered(env.store)
+	env.syncer.storage = meter
+	ctx := context.Background()
+
+	if _, err := env.syncer.Plan(ctx, true, nil); !errors.Is(err, ErrNoRemoteCache) {
+		t.Fatalf("Expected ErrNoRemoteCache before any listing, got %v", err)
+	}
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# V1")
+	writeFile(t, env.claudeDir, "agents/old.md", "old")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if _, err := env.syncer.Plan(ctx, false, nil); err != nil {
+		t.Fatalf("Online plan failed: %v", err)
+	}
+
+	// Another device adds a file after our listing
+	encrypted, err := env.syncer.encryptor.Encrypt([]byte("remote"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if err := env.store.Upload(ctx, "agents/remote.md.age", encrypted); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	// A local delete is pushed, and a local edit is left pending
+	if err := os.Remove(filepath.Join(env.claudeDir, "agents", "old.md")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# V2")
+
+	// Offline must not touch storage at all
+	before := meter.Stats()
+	plan, err := env.syncer.Plan(ctx, true, nil)
+	if err != nil {
+		t.Fatalf("Offline plan failed: %v", err)
+	}
+	if used := meter.Stats().Sub(before); used.Total() != 0 {
+		t.Errorf("Offline plan made storage requests: %s", used)
+	}
+	if !plan.Offline || plan.ListedAt.IsZero() {
+		t.Errorf("Expected offline plan with listing time, got %+v", plan)
+	}
+	if len(plan.Push) != 1 || plan.Push[0].Path != "CLAUDE.md" {
+		t.Errorf("Expected CLAUDE.md pending push, got %+v", plan.Push)
+	}
+	if len(plan.Pull.WouldDownload) != 0 {
+		t.Errorf("Expected nothing to download from the cached listing, got %+v", plan.Pull.WouldDownload)
+	}
+
+	// Online sees the other device's file
+	plan, err = env.syncer.Plan(ctx, false, nil)
+	if err != nil {
+		t.Fatalf("Online plan failed: %v", err)
+	}
+	if len(plan.Pull.WouldDownload) != 1 || plan.Pull.WouldDownload[0].Path != "agents/remote.md" {
+		t.Errorf("Expected agents/remote.md to download, got %+v", plan.Pull.WouldDownload)
+	}
+}
+
+func TestPlanPaths(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# V1")
+	writeFile(t, env.claudeDir, "agents/a.md", "a")
+	pushOK(t, env)
+
+	// Another device adds two files; this one edits two
+	for _, key := range []string{"agents/remote.md.age", "agents/other.md.age"} {
+		encrypted, err := env.syncer.encryptor.Encrypt([]byte("remote"))
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		if err := env.store.Upload(ctx, key, encrypted); err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+	}
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# V2")
+	writeFile(t, env.claudeDir, "agents/a.md", "a2")
+
+	plan, err := env.syncer.Plan(ctx, false, []string{"CLAUDE.md", "agents/remote.md"})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if len(plan.Push) != 1 || plan.Push[0].Path != "CLAUDE.md" {
+		t.Errorf("Expected only CLAUDE.md pending push, got %+v", plan.Push)
+	}
+	if len(plan.Pull.WouldDownload) != 1 || plan.Pull.WouldDownload[0].Path != "agents/remote.md" {
+		t.Errorf("Expected only agents/remote.md to download, got %+v", plan.Pull.WouldDownload)
+	}
+	if len(plan.Pull.LocalOnlyFiles) != 0 {
+		t.Errorf("Expected no local-only files outside the paths, got %+v", plan.Pull.LocalOnlyFiles)
+	}
+}
+
+func TestPlanOfflineWithoutStorage(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	claudeDir := filepath.Join(tmpDir, ".claude")
+	stateDir := filepath.Join(tmpDir, ".claude-sync")
+	cfg := &config.Config{ClaudeDirOverride: claudeDir, StateDirOverride: stateDir}
+	writeFile(t, claudeDir, "CLAUDE.md", "# Rules")
+
+	if _, err := NewSyncer(cfg, true); err == nil {
+		t.Fatal("Expected NewSyncer to fail with no storage configured")
+	}
+	syncer, err := NewOfflineSyncer(cfg, true)
+	if err != nil {
+		t.Fatalf("NewOfflineSyncer failed: %v", err)
+	}
+	if _, err := syncer.Plan(context.Background(), true, nil); !errors.Is(err, ErrNoRemoteCache) {
+		t.Fatalf("Expected ErrNoRemoteCache, got %v", err)
+	}
+
+	cache := &RemoteCache{ListedAt: time.Now(), Objects: []storage.ObjectInfo{{Key: "agents/remote.md.age", Size: 5}}}
+	if err := syncer.saveRemoteCache(cache); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := syncer.Plan(context.Background(), true, nil)
+	if err != nil {
+		t.Fatalf("Offline plan failed: %v", err)
+	}
+	if len(plan.Push) != 1 || plan.Push[0].Path != "CLAUDE.md" {
+		t.Errorf("Expected CLAUDE.md pending push, got %+v", plan.Push)
+	}
+	if len(plan.Pull.WouldDownload) != 1 || plan.Pull.WouldDownload[0].Path != "agents/remote.md" {
+		t.Errorf("Expected agents/remote.md to download, got %+v", plan.Pull.WouldDownload)
+	}
+}
@@ -0,0 +1,279 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// pullAction is what pull does with a remote file.
+type pullAction int
+
+const (
+	pullKeep pullAction = iota
+	pullDownload
+	pullOverwrite
+	pullConflict
+)
+
+var pullActionNames = map[pullAction]string{
+	pullKeep:      "keep",
+	pullDownload:  "download",
+	pullOverwrite: "overwrite",
+	pullConflict:  "conflict",
+}
+
+// previewFile decides what pull would do with one remote file, with the
+// same rules pull applies.
+func (s *Syncer) previewFile(localPath string, remoteObj storage.ObjectInfo, localInfo os.FileInfo, localExists bool) (FilePreview, pullAction) {
+	fp := FilePreview{
+		Path:       localPath,
+		RemoteKey:  remoteObj.Key,
+		RemoteTime: remoteObj.LastModified,
+		RemoteSize: remoteObj.Size,
+	}
+	if !localExists {
+		// New file from remote
+		fp.RemoteOnly = true
+		fp.Reason = "new remote file"
+		return fp, pullDownload
+	}
+	fp.LocalTime = localInfo.ModTime()
+	fp.LocalSize = localInfo.Size()
+
+	stateFile := s.state.GetFile(localPath)
+	switch {
+	case stateFile != nil:
+		// Check if remote is newer than our last known state
+		if !remoteObj.LastModified.After(stateFile.Uploaded) {
+			fp.Reason = "unchanged remotely since the last sync"
+			return fp, pullKeep
+		}
+		// Remote was updated after we last uploaded
+		localHash, _ := s.state.hashFile(filepath.Join(s.claudeDir, localPath))
+		if localHash != stateFile.Hash {
+			fp.Reason = "changed both locally and remotely"
+			return fp, pullConflict
+		}
+		fp.Reason = "changed remotely since the last sync"
+		return fp, pullOverwrite
+	case s.netFS != "":
+		// No state on a network filesystem: pull compares contents and
+		// keeps the local file, saving a differing remote as .conflict
+		fp.Reason = "no sync state on a network filesystem; contents are compared"
+		return fp, pullConflict
+	case localInfo.ModTime().Before(remoteObj.LastModified):
+		// No state - compare timestamps
+		fp.Reason = "no sync state and the remote copy is newer"
+		return fp, pullOverwrite
+	}
+	fp.Reason = "no sync state and the local copy is newer"
+	return fp, pullKeep
+}
+
+// planKey identifies one decision about one version of a file: the same key
+// means the same remote object and local file, handled the same way.
+func planKey(action pullAction, fp FilePreview) string {
+	fields := []string{
+		pullActionNames[action],
+		fp.Path,
+		fp.RemoteKey,
+		strconv.FormatInt(fp.RemoteSize, 10),
+		strconv.FormatInt(fp.RemoteTime.UnixNano(), 10),
+	}
+	if !fp.RemoteOnly {
+		fields = append(fields, strconv.FormatInt(fp.LocalSize, 10), strconv.FormatInt(fp.LocalTime.UnixNano(), 10))
+	}
+	return strings.Join(fields, "\x00")
+}
+
+// planKeys returns the keys of every file p would download, overwrite or
+// conflict on.
+func (p *PullPreview) planKeys() map[string]bool {
+	keys := make(map[string]bool)
+	for _, fp := range p.WouldDownload {
+		keys[planKey(pullDownload, fp)] = true
+	}
+	for _, fp := range p.WouldOverwrite {
+		keys[planKey(pullOverwrite, fp)] = true
+	}
+	for _, fp := range p.WouldConflict {
+		keys[planKey(pullConflict, fp)] = true
+	}
+	return keys
+}
+
+// computePlanID hashes the plan's decisions, including the files it keeps:
+// a kept file whose remote copy changes would be pulled after all.
+func (p *PullPreview) computePlanID() string {
+	var keys []string
+	for key := range p.planKeys() {
+		keys = append(keys, key)
+	}
+	for _, fp := range p.WouldKeep {
+		keys = append(keys, planKey(pullKeep, fp))
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, key := range keys {
+		h.Write([]byte(key))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// finish sorts the preview's lists and fills in the totals and plan ID.
+func (p *PullPreview) finish() {
+	for _, list := range [][]FilePreview{p.WouldDownload, p.WouldOverwrite, p.WouldKeep, p.WouldConflict, p.LocalOnlyFiles} {
+		sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	}
+	p.DownloadBytes, p.ReplacedBytes = 0, 0
+	for _, fp := range p.WouldDownload {
+		p.DownloadBytes += fp.RemoteSize
+	}
+	for _, fp := range p.WouldOverwrite {
+		p.DownloadBytes += fp.RemoteSize
+		p.ReplacedBytes += fp.LocalSize
+	}
+	p.PlanID = p.computePlanID()
+}
+
+// ErrPlanNotFound is returned by LoadPullPlan when the saved plan is missing
+// or has a different ID.
+var ErrPlanNotFound = errors.New("no saved pull plan with that ID; preview again with 'claude-sync pull --dry-run'")
+
+// PullPlan pulls exactly what plan previewed. A file whose remote object or
+// local copy has changed since the preview, and any remote file the preview
+// didn't include, is left alone and listed in SyncResult.PlanChanged for
+// the next pull. plan must be unmodified since PreviewPull (or LoadPullPlan)
+// returned it.
+func (s *Syncer) PullPlan(ctx context.Context, plan *PullPreview) (*SyncResult, error) {
+	if plan == nil || plan.PlanID == "" || plan.PlanID != plan.computePlanID() {
+		return nil, fmt.Errorf("the pull plan doesn't match its ID; preview again")
+	}
+	return s.notifyRun(ctx, "pull", func(ctx context.Context) (*SyncResult, error) {
+		s.plan = plan.planKeys()
+		defer func() { s.plan = nil }()
+		return s.pull(ctx)
+	})
+}
+
+// PullSelected pulls the files of plan at paths, as PullPlan would, and
+// leaves the rest of the plan alone, listing them in SyncResult.Skipped.
+// Files the plan doesn't include are left alone as with PullPlan.
+func (s *Syncer) PullSelected(ctx context.Context, plan *PullPreview, paths []string) (*SyncResult, error) {
+	if plan == nil || plan.PlanID == "" || plan.PlanID != plan.computePlanID() {
+		return nil, fmt.Errorf("the pull plan doesn't match its ID; preview again")
+	}
+	selected := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		selected[path] = true
+	}
+	return s.notifyRun(ctx, "pull", func(ctx context.Context) (*SyncResult, error) {
+		s.plan = make(map[string]bool)
+		s.skip = make(map[string]bool)
+		for key := range plan.planKeys() {
+			if path := strings.SplitN(key, "\x00", 3)[1]; selected[path] {
+				s.plan[key] = true
+			} else {
+				s.skip[path] = true
+			}
+		}
+		defer func() { s.plan, s.skip = nil, nil }()
+		return s.pull(ctx)
+	})
+}
+
+// plannedAction reports whether pull, running a plan, may go ahead with a
+// remote file. Files pull would leave alone anyway are always fine; anything
+// else has to match the plan exactly. seen collects the planned files found.
+func (s *Syncer) plannedAction(localPath string, remoteObj storage.ObjectInfo, localInfo os.FileInfo, localExists bool, seen map[string]bool) bool {
+	fp, action := s.previewFile(localPath, remoteObj, localInfo, localExists)
+	if action == pullKeep {
+		return true
+	}
+	key := planKey(action, fp)
+	if !s.plan[key] {
+		return false
+	}
+	seen[key] = true
+	return true
+}
+
+// planChanged adds the planned files pull didn't come across, because they
+// were removed from the remote or changed locally, to changed, and sorts
+// it.
+func (s *Syncer) planChanged(changed []string, seen map[string]bool) []string {
+	listed := make(map[string]bool, len(changed))
+	for _, path := range changed {
+		listed[path] = true
+	}
+	for key := range s.plan {
+		path := strings.SplitN(key, "\x00", 3)[1]
+		if !seen[key] && !listed[path] {
+			listed[path] = true
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func (s *Syncer) pullPlanPath() string {
+	return filepath.Join(filepath.Dir(s.state.path()), config.PullPlanFile)
+}
+
+// SavePullPlan keeps plan for a later LoadPullPlan, e.g. from another
+// command. Only the most recent plan is kept.
+func (s *Syncer) SavePullPlan(plan *PullPreview) error {
+	path := s.pullPlanPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to save pull plan: %w", err)
+	}
+	return nil
+}
+
+// LoadPullPlan returns the plan SavePullPlan saved under id.
+func (s *Syncer) LoadPullPlan(id string) (*PullPreview, error) {
+	data, err := os.ReadFile(s.pullPlanPath())
+	if os.IsNotExist(err) {
+		return nil, ErrPlanNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read pull plan: %w", err)
+	}
+	var plan PullPreview
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to read pull plan: %w", err)
+	}
+	if plan.PlanID != id {
+		return nil, ErrPlanNotFound
+	}
+	return &plan, nil
+}
+
+// RemovePullPlan deletes the saved plan once it has been pulled.
+func (s *Syncer) RemovePullPlan() error {
+	if err := os.Remove(s.pullPlanPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestPreviewPullPlan(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	writeFile(t, env.claudeDir, "agents/helper.md", "helper")
+	pushOK(t, env)
+
+	other := sharedBucketEnv(t, env)
+	preview, err := other.syncer.PreviewPull(ctx)
+	if err != nil {
+		t.Fatalf("PreviewPull failed: %v", err)
+	}
+	if len(preview.WouldDownload) != 2 || preview.WouldDownload[0].Path != "CLAUDE.md" || preview.WouldDownload[0].Reason != "new remote file" {
+		t.Fatalf("Expected two sorted new files, got %+v", preview.WouldDownload)
+	}
+	if preview.DownloadBytes != preview.WouldDownload[0].RemoteSize+preview.WouldDownload[1].RemoteSize {
+		t.Errorf("Expected the download total to add up, got %d", preview.DownloadBytes)
+	}
+	again, err := other.syncer.PreviewPull(ctx)
+	if err != nil || preview.PlanID == "" || again.PlanID != preview.PlanID {
+		t.Errorf("Expected a stable plan ID, got %q and %q (%v)", preview.PlanID, again.PlanID, err)
+	}
+
+	// Changed after the preview: one file updated, one added
+	time.Sleep(10 * time.Millisecond)
+	writeFile(t, env.claudeDir, "agents/helper.md", "helper v2")
+	writeFile(t, env.claudeDir, "agents/new.md", "new")
+	pushOK(t, env)
+
+	result, err := other.syncer.PullPlan(ctx, preview)
+	if err != nil {
+		t.Fatalf("PullPlan failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Downloaded, []string{"CLAUDE.md"}) {
+		t.Errorf("Expected only the unchanged file pulled, got %v", result.Downloaded)
+	}
+	if !reflect.DeepEqual(result.PlanChanged, []string{"agents/helper.md", "agents/new.md"}) {
+		t.Errorf("Expected the changed files reported, got %v", result.PlanChanged)
+	}
+	if _, err := os.Stat(filepath.Join(other.claudeDir, "agents/new.md")); !os.IsNotExist(err) {
+		t.Error("Expected a file added after the preview not pulled")
+	}
+
+	// A normal pull picks up the rest
+	if _, err := other.syncer.Pull(ctx); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if readFile(t, other.claudeDir, "agents/helper.md") != "helper v2" {
+		t.Error("Expected the updated file on the next pull")
+	}
+}
+
+func TestPullPlanSaved(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	pushOK(t, env)
+
+	other := sharedBucketEnv(t, env)
+	writeFile(t, other.claudeDir, "CLAUDE.md", "older local rules")
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(other.claudeDir, "CLAUDE.md"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	preview, err := other.syncer.PreviewPull(ctx)
+	if err != nil {
+		t.Fatalf("PreviewPull failed: %v", err)
+	}
+	if len(preview.WouldOverwrite) != 1 || preview.ReplacedBytes != int64(len("older local rules")) {
+		t.Fatalf("Expected one overwrite replacing the local file, got %+v", preview)
+	}
+	if err := other.syncer.SavePullPlan(preview); err != nil {
+		t.Fatalf("SavePullPlan failed: %v", err)
+	}
+
+	if _, err := other.syncer.LoadPullPlan("0000000000000000"); !errors.Is(err, ErrPlanNotFound) {
+		t.Errorf("Expected ErrPlanNotFound for another ID, got %v", err)
+	}
+	plan, err := other.syncer.LoadPullPlan(preview.PlanID)
+	if err != nil {
+		t.Fatalf("LoadPullPlan failed: %v", err)
+	}
+
+	tampered := *plan
+	tampered.WouldKeep = append(tampered.WouldKeep, FilePreview{Path: "extra.md"})
+	if _, err := other.syncer.PullPlan(ctx, &tampered); err == nil {
+		t.Error("Expected a plan that doesn't match its ID refused")
+	}
+
+	result, err := other.syncer.PullPlan(ctx, plan)
+	if err != nil {
+		t.Fatalf("PullPlan failed: %v", err)
+	}
+	if len(result.Downloaded) != 1 || len(result.PlanChanged) != 0 {
+		t.Errorf("Expected the saved plan pulled as previewed, got %+v", result)
+	}
+	if readFile(t, other.claudeDir, "CLAUDE.md") != "rules" {
+		t.Error("Expected the remote copy pulled")
+	}
+	if err := other.syncer.RemovePullPlan(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.syncer.LoadPullPlan(preview.PlanID); !errors.Is(err, ErrPlanNotFound) {
+		t.Errorf("Expected the plan removed, got %v", err)
+	}
+}
+
+func TestPullSelected(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	writeFile(t, env.claudeDir, "agents/junk.md", "junk")
+	writeFile(t, env.claudeDir, "agents/helper.md", "helper")
+	pushOK(t, env)
+
+	other := sharedBucketEnv(t, env)
+	preview, err := other.syncer.PreviewPull(ctx)
+	if err != nil {
+		t.Fatalf("PreviewPull failed: %v", err)
+	}
+
+	result, err := other.syncer.PullSelected(ctx, preview, []string{"CLAUDE.md", "agents/helper.md"})
+	if err != nil {
+		t.Fatalf("PullSelected failed: %v", err)
+	}
+	downloaded := append([]string(nil), result.Downloaded...)
+	sort.Strings(downloaded)
+	if !reflect.DeepEqual(downloaded, []string{"CLAUDE.md", "agents/helper.md"}) {
+		t.Errorf("Downloaded = %v, want the selected files", result.Downloaded)
+	}
+	if !reflect.DeepEqual(result.Skipped, []string{"agents/junk.md"}) || len(result.PlanChanged) != 0 {
+		t.Errorf("Skipped = %v, PlanChanged = %v, want only the unselected file skipped", result.Skipped, result.PlanChanged)
+	}
+	if _, err := os.Stat(filepath.Join(other.claudeDir, "agents/junk.md")); !os.IsNotExist(err) {
+		t.Error("Expected the unselected file not pulled")
+	}
+
+	// Still offered next time
+	again, err := other.syncer.PreviewPull(ctx)
+	if err != nil {
+		t.Fatalf("PreviewPull failed: %v", err)
+	}
+	if len(again.WouldDownload) != 1 || again.WouldDownload[0].Path != "agents/junk.md" {
+		t.Errorf("WouldDownload = %+v, want the skipped file again", again.WouldDownload)
+	}
+}
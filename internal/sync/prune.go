@@ -0,0 +1,163 @@
+package sync
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+)
+
+// PruneOptions says which local leftovers Prune removes.
+type PruneOptions struct {
+	Backups   bool // ~/.claude.backup.* directories, manual and automatic
+	Conflicts bool // Saved .conflict files
+	State     bool // Sync state entries nothing refers to any more
+
+	// OlderThan keeps backups and conflict files newer than this. State
+	// entries don't age; an orphaned one is removed whenever State is set.
+	OlderThan time.Duration
+
+	DryRun bool
+}
+
+// PruneResult lists what Prune removed, or would remove on a dry run.
+type PruneResult struct {
+	Backups   []Backup
+	Conflicts []Conflict
+
+	// State lists the orphaned state entries: files outside this device's
+	// sync paths or excludes, and records of .conflict files that are gone.
+	State []string
+
+	// Size is the disk space the backups and conflict files take up.
+	Size int64
+}
+
+// Empty reports whether there was nothing to prune.
+func (r *PruneResult) Empty() bool {
+	return len(r.Backups) == 0 && len(r.Conflicts) == 0 && len(r.State) == 0
+}
+
+// Prune removes local leftovers of past syncs from around claudeDir: old
+// backups, old .conflict files, and state entries for files this device no
+// longer syncs. Nothing in storage is touched.
+//
+// A state entry for a file outside the sync paths makes the next push
+// delete the file's remote copy, as if it had been deleted here; pruning the
+// entry leaves the remote copy to the devices that still sync it.
+func Prune(cfg *config.Config, state *SyncState, claudeDir string, opts PruneOptions) (*PruneResult, error) {
+	result := &PruneResult{}
+	cutoff := time.Now().Add(-opts.OlderThan)
+
+	if opts.Backups {
+		backups, err := ListBackups(claudeDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range backups {
+			if !b.CreatedAt.Before(cutoff) {
+				continue
+			}
+			result.Size += dirSize(b.Path)
+			if !opts.DryRun {
+				if err := os.RemoveAll(b.Path); err != nil {
+					return result, fmt.Errorf("failed to remove backup %s: %w", b.Name, err)
+				}
+			}
+			result.Backups = append(result.Backups, b)
+		}
+	}
+
+	stateChanged := false
+	if opts.Conflicts {
+		conflicts, err := FindConflicts(claudeDir, state)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range conflicts {
+			// Conflict files with a name we can't date are left alone
+			if c.DetectedAt.IsZero() || !c.DetectedAt.Before(cutoff) {
+				continue
+			}
+			result.Size += c.RemoteSize
+			if !opts.DryRun {
+				if err := os.Remove(filepath.Join(claudeDir, filepath.FromSlash(c.ConflictPath))); err != nil {
+					return result, fmt.Errorf("failed to remove %s: %w", c.ConflictPath, err)
+				}
+				state.ClearConflict(c.ConflictPath)
+				stateChanged = true
+			}
+			result.Conflicts = append(result.Conflicts, c)
+		}
+	}
+
+	if opts.State {
+		result.State = orphanedStateEntries(cfg, state, claudeDir)
+		if !opts.DryRun {
+			for _, entry := range result.State {
+				state.RemoveFile(entry)
+				state.ClearConflict(entry)
+			}
+			stateChanged = stateChanged || len(result.State) > 0
+		}
+	}
+
+	if stateChanged {
+		if err := state.Save(); err != nil {
+			return result, fmt.Errorf("failed to save state: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// orphanedStateEntries lists, sorted, the files in state this device no
+// longer syncs and the conflict records whose .conflict file is gone.
+func orphanedStateEntries(cfg *config.Config, state *SyncState, claudeDir string) []string {
+	syncPaths := cfg.ScopeSyncPaths()
+
+	state.mu.Lock()
+	var orphans []string
+	for path := range state.Files {
+		// _external/ entries (MCP servers) live outside ~/.claude
+		if strings.HasPrefix(path, "_external/") {
+			continue
+		}
+		if !inSyncPaths(path, syncPaths) || cfg.IsExcluded(path) {
+			orphans = append(orphans, path)
+		}
+	}
+	conflictPaths := make([]string, 0, len(state.Conflicts))
+	for path := range state.Conflicts {
+		conflictPaths = append(conflictPaths, path)
+	}
+	state.mu.Unlock()
+
+	for _, path := range conflictPaths {
+		if _, err := os.Lstat(filepath.Join(claudeDir, filepath.FromSlash(path))); os.IsNotExist(err) {
+			orphans = append(orphans, path)
+		}
+	}
+	sort.Strings(orphans)
+	return orphans
+}
+
+// dirSize adds up the sizes of the files under dir, skipping what it can't
+// read.
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,124 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+)
+
+func TestPrune(t *testing.T) {
+	env := setupTestEnv(t)
+	state := env.syncer.state
+	parent := filepath.Dir(env.claudeDir)
+
+	oldStamp := time.Now().Add(-60 * 24 * time.Hour).Format(BackupTimeLayout)
+	newStamp := time.Now().Add(-time.Hour).Format(BackupTimeLayout)
+	for _, name := range []string{
+		".claude.backup." + oldStamp,
+		".claude.backup.auto." + oldStamp,
+		".claude.backup." + newStamp,
+	} {
+		writeFile(t, filepath.Join(parent, name), "CLAUDE.md", "backup")
+	}
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "local")
+	writeFile(t, env.claudeDir, "CLAUDE.md.conflict."+oldStamp, "old remote")
+	writeFile(t, env.claudeDir, "CLAUDE.md.conflict."+newStamp, "new remote")
+	writeFile(t, env.claudeDir, "agents/a.md.conflict.unknown", "undated")
+	state.RecordConflict("CLAUDE.md.conflict."+oldStamp, ConflictOrigin{Device: "laptop"})
+	state.RecordConflict("agents/gone.md.conflict."+oldStamp, ConflictOrigin{Device: "laptop"})
+
+	for _, path := range []string{"CLAUDE.md", "agents/tmp.swp", "cache/x.bin", config.MCPRemoteKey} {
+		state.Files[path] = &FileState{Path: path, Hash: "h"}
+	}
+	cfg := &config.Config{Exclude: []string{"*.swp"}}
+
+	opts := PruneOptions{Backups: true, Conflicts: true, State: true, OlderThan: 30 * 24 * time.Hour, DryRun: true}
+	result, err := Prune(cfg, state, env.claudeDir, opts)
+	if err != nil {
+		t.Fatalf("Prune (dry run) failed: %v", err)
+	}
+
+	var backups []string
+	for _, b := range result.Backups {
+		backups = append(backups, b.Name)
+	}
+	sort.Strings(backups)
+	if want := []string{".claude.backup." + oldStamp, ".claude.backup.auto." + oldStamp}; !reflect.DeepEqual(backups, want) {
+		t.Errorf("Backups = %v, want %v", backups, want)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].ConflictPath != "CLAUDE.md.conflict."+oldStamp {
+		t.Errorf("Conflicts = %+v, want only the old CLAUDE.md conflict", result.Conflicts)
+	}
+	wantState := []string{"agents/gone.md.conflict." + oldStamp, "agents/tmp.swp", "cache/x.bin"}
+	if !reflect.DeepEqual(result.State, wantState) {
+		t.Errorf("State = %v, want %v", result.State, wantState)
+	}
+	if want := int64(2*len("backup") + len("old remote")); result.Size != want {
+		t.Errorf("Size = %d, want %d", result.Size, want)
+	}
+	if _, err := os.Stat(filepath.Join(parent, ".claude.backup."+oldStamp)); err != nil {
+		t.Error("Dry run removed a backup")
+	}
+	if state.GetFile("cache/x.bin") == nil {
+		t.Error("Dry run removed a state entry")
+	}
+
+	if _, err := Prune(cfg, state, env.claudeDir, PruneOptions{Backups: true, Conflicts: true, State: true, OlderThan: opts.OlderThan}); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	left, err := ListBackups(env.claudeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 1 || left[0].Name != ".claude.backup."+newStamp {
+		t.Errorf("Backups left = %+v, want only the recent one", left)
+	}
+	conflicts, err := FindConflicts(env.claudeDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 2 {
+		t.Errorf("Conflict files left = %+v, want the recent and the undated one", conflicts)
+	}
+	if _, ok := state.ConflictOrigin("CLAUDE.md.conflict." + oldStamp); ok {
+		t.Error("Record of the removed conflict file kept")
+	}
+	if _, ok := state.ConflictOrigin("agents/gone.md.conflict." + oldStamp); ok {
+		t.Error("Record of a missing conflict file kept")
+	}
+	if state.GetFile("CLAUDE.md") == nil || state.GetFile(config.MCPRemoteKey) == nil || state.GetFile("cache/x.bin") != nil || state.GetFile("agents/tmp.swp") != nil {
+		t.Errorf("State files = %v, want only CLAUDE.md and the MCP servers", state.Files)
+	}
+
+	saved, err := LoadStateFromDir(env.stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Files) != 2 {
+		t.Errorf("Saved state has %d files, want 2", len(saved.Files))
+	}
+}
+
+func TestPruneOnlyWhatWasAskedFor(t *testing.T) {
+	env := setupTestEnv(t)
+	stamp := time.Now().Add(-48 * time.Hour).Format(BackupTimeLayout)
+	writeFile(t, filepath.Join(filepath.Dir(env.claudeDir), ".claude.backup."+stamp), "CLAUDE.md", "backup")
+	writeFile(t, env.claudeDir, "CLAUDE.md.conflict."+stamp, "remote")
+
+	result, err := Prune(&config.Config{}, env.syncer.state, env.claudeDir, PruneOptions{Conflicts: true, OlderThan: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if len(result.Backups) != 0 || len(result.Conflicts) != 1 {
+		t.Errorf("Pruned %d backups and %d conflicts, want 0 and 1", len(result.Backups), len(result.Conflicts))
+	}
+	if backups, _ := ListBackups(env.claudeDir); len(backups) != 1 {
+		t.Error("Backup removed without Backups set")
+	}
+}
@@ -0,0 +1,269 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// RegistryKey holds the bucket's device registry: the devices allowed to
+// sync, and the data key files are encrypted to. It is encrypted to each
+// registered device's own key rather than to the data key, so a device can
+// only learn the data key while it is registered.
+const RegistryKey = "_metadata/devices.age"
+
+// ErrNotRegistered is returned when this device's key doesn't open the
+// registry.
+var ErrNotRegistered = errors.New("this device is not registered with the bucket; run 'claude-sync device id' here and 'claude-sync device add' on a registered device")
+
+var errNoDeviceKeys = errors.New("device keys aren't enabled; run 'claude-sync device init' first")
+
+// Registry is the decrypted device registry.
+type Registry struct {
+	Devices []Device `json:"devices"`
+	DataKey string   `json:"data_key"`
+
+	// PendingKey is the data key an unfinished rotation is moving files to.
+	// It replaces DataKey once every object has been re-encrypted.
+	PendingKey string `json:"pending_key,omitempty"`
+}
+
+// Device is a registered device and its own public key.
+type Device struct {
+	Name      string    `json:"name"`
+	Recipient string    `json:"recipient"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+func (r *Registry) find(name string) int {
+	for i, d := range r.Devices {
+		if d.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// DataEncryptor returns an encryptor for the data key, also encrypting to
+// recipients.
+func (r *Registry) DataEncryptor(recipients []string) (*crypto.Encryptor, error) {
+	enc, err := crypto.NewEncryptorFromIdentity(r.DataKey, recipients)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key in device registry: %w", err)
+	}
+	return enc, nil
+}
+
+// LoadRegistry downloads the registry and opens it with device, this
+// device's own key. It returns nil when the bucket has none.
+func LoadRegistry(ctx context.Context, store storage.Storage, device *crypto.Encryptor) (*Registry, error) {
+	found, err := registryExists(ctx, store)
+	if err != nil || !found {
+		return nil, err
+	}
+	encrypted, err := store.Download(ctx, RegistryKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download device registry: %w", err)
+	}
+	data, err := device.Decrypt(encrypted)
+	if err != nil {
+		return nil, ErrNotRegistered
+	}
+	var reg Registry
+	if err := json.Unmarshal(data, &reg); err != nil {
+		return nil, fmt.Errorf("failed to parse device registry: %w", err)
+	}
+	return &reg, nil
+}
+
+func registryExists(ctx context.Context, store storage.Storage) (bool, error) {
+	objects, err := store.List(ctx, RegistryKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to list remote files: %w", err)
+	}
+	for _, obj := range objects {
+		if obj.Key == RegistryKey {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// UnlockDataKey returns an encryptor for the bucket's data key, read from
+// the registry with this device's key.
+func UnlockDataKey(ctx context.Context, store storage.Storage, device *crypto.Encryptor, recipients []string) (*crypto.Encryptor, error) {
+	reg, err := LoadRegistry(ctx, store, device)
+	if err != nil {
+		return nil, err
+	}
+	if reg == nil {
+		return nil, errors.New("device_keys is set but the bucket has no device registry; run 'claude-sync device init' on a device with the bucket's key")
+	}
+	return reg.DataEncryptor(recipients)
+}
+
+// saveRegistry encrypts reg to every registered device and uploads it.
+func saveRegistry(ctx context.Context, store storage.Storage, reg *Registry) error {
+	data, err := json.Marshal(reg)
+	if err != nil {
+		return err
+	}
+	recipients := make([]string, len(reg.Devices))
+	for i, d := range reg.Devices {
+		recipients[i] = d.Recipient
+	}
+	encrypted, err := crypto.EncryptTo(data, recipients)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt device registry: %w", err)
+	}
+	if err := store.Upload(ctx, RegistryKey, encrypted); err != nil {
+		return fmt.Errorf("failed to upload device registry: %w", err)
+	}
+	return nil
+}
+
+// registry loads the registry with this device's key.
+func (s *Syncer) registry(ctx context.Context) (*Registry, error) {
+	if s.device == nil {
+		return nil, errNoDeviceKeys
+	}
+	reg, err := LoadRegistry(ctx, s.storage, s.device)
+	if err == nil && reg == nil {
+		err = errors.New("the bucket has no device registry")
+	}
+	return reg, err
+}
+
+// Devices returns the registry.
+func (s *Syncer) Devices(ctx context.Context) (*Registry, error) {
+	return s.registry(ctx)
+}
+
+// EnableDeviceKeys creates the bucket's registry, registering device as
+// name. The current key becomes the data key, so nothing is re-encrypted;
+// devices still using it directly keep working until the next rotation.
+func (s *Syncer) EnableDeviceKeys(ctx context.Context, device *crypto.Encryptor, name string) error {
+	if s.device != nil {
+		return errors.New("device keys are already enabled")
+	}
+	if err := s.checkFingerprint(ctx, true); err != nil {
+		return err
+	}
+	if found, err := registryExists(ctx, s.storage); err != nil {
+		return err
+	} else if found {
+		return errors.New("the bucket already has a device registry; have a registered device run 'claude-sync device add'")
+	}
+	current := ageCipher(s.encryptor)
+	if current == nil {
+		return errors.New("device keys need the age encryption scheme")
+	}
+	secret, err := current.SecretKey()
+	if err != nil {
+		return err
+	}
+	reg := &Registry{
+		DataKey: secret,
+		Devices: []Device{{Name: name, Recipient: device.PublicKey(), AddedAt: time.Now().UTC()}},
+	}
+	if err := saveRegistry(ctx, s.storage, reg); err != nil {
+		return err
+	}
+	s.device = device
+	return nil
+}
+
+// AddDevice registers recipient, another device's own public key, as name.
+// The registry is re-encrypted to include it, giving it the data key.
+func (s *Syncer) AddDevice(ctx context.Context, name, recipient string) error {
+	reg, err := s.registry(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := crypto.ParseRecipient(recipient); err != nil {
+		return err
+	}
+	for _, d := range reg.Devices {
+		if d.Name == name {
+			return fmt.Errorf("a device named %q is already registered", name)
+		}
+		if d.Recipient == recipient {
+			return fmt.Errorf("that key is already registered as %q", d.Name)
+		}
+	}
+	reg.Devices = append(reg.Devices, Device{Name: name, Recipient: recipient, AddedAt: time.Now().UTC()})
+	return saveRegistry(ctx, s.storage, reg)
+}
+
+// RevokeDevice removes name from the registry and rotates the data key
+// (see RotateDataKey), since the revoked device may have kept a copy of it.
+func (s *Syncer) RevokeDevice(ctx context.Context, name string) (*RekeyResult, error) {
+	reg, err := s.registry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	i := reg.find(name)
+	if i < 0 {
+		return nil, fmt.Errorf("no device named %q is registered", name)
+	}
+	if reg.Devices[i].Recipient == s.device.PublicKey() {
+		return nil, errors.New("this device can't revoke itself; revoke it from another registered device")
+	}
+	reg.Devices = append(reg.Devices[:i], reg.Devices[i+1:]...)
+	return s.rotate(ctx, reg)
+}
+
+// RotateDataKey moves the bucket to a new data key, re-encrypting every
+// object with Rekey. The new key is kept in the registry as PendingKey
+// until that succeeds, so a rotation that fails part way is finished by
+// running it again.
+func (s *Syncer) RotateDataKey(ctx context.Context) (*RekeyResult, error) {
+	reg, err := s.registry(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.rotate(ctx, reg)
+}
+
+func (s *Syncer) rotate(ctx context.Context, reg *Registry) (*RekeyResult, error) {
+	if reg.PendingKey == "" {
+		secret, err := crypto.GenerateIdentity()
+		if err != nil {
+			return nil, err
+		}
+		reg.PendingKey = secret
+	}
+	// Saved before anything is re-encrypted: a revoked device is shut out
+	// of the registry at once, and the new key isn't lost if Rekey stops
+	if err := saveRegistry(ctx, s.storage, reg); err != nil {
+		return nil, err
+	}
+
+	newEnc, err := crypto.NewEncryptorFromIdentity(reg.PendingKey, s.cfg.Recipients)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.Rekey(ctx, newEnc)
+	if err != nil {
+		return result, err
+	}
+	if len(result.Failed) > 0 {
+		return result, fmt.Errorf("%d object(s) can't be decrypted with the old or the new data key", len(result.Failed))
+	}
+
+	reg.DataKey, reg.PendingKey = reg.PendingKey, ""
+	if err := saveRegistry(ctx, s.storage, reg); err != nil {
+		return result, err
+	}
+	s.encryptor = newEnc
+	if s.keyService != nil {
+		s.encryptor = newEnc.WithKeyService(s.keyService)
+	}
+	s.state.KeyFingerprint = ""
+	return result, nil
+}
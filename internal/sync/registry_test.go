@@ -0,0 +1,130 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// deviceKeysPair sets up env as the first registered device and other as a
+// second device with its own key, not yet registered.
+func deviceKeysPair(t *testing.T) (*testEnv, *testEnv) {
+	t.Helper()
+	env := setupTestEnv(t)
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	pushOK(t, env)
+	if err := env.syncer.EnableDeviceKeys(context.Background(), newTestEncryptor(t), "desktop"); err != nil {
+		t.Fatalf("EnableDeviceKeys failed: %v", err)
+	}
+	other := sharedBucketEnv(t, env)
+	other.syncer.encryptor = nil
+	other.syncer.device = newTestEncryptor(t)
+	return env, other
+}
+
+// unlock opens the data key on other, as NewSyncer does with device_keys.
+func unlock(t *testing.T, env, other *testEnv) error {
+	t.Helper()
+	enc, err := UnlockDataKey(context.Background(), env.store, other.syncer.device, nil)
+	if err == nil {
+		other.syncer.encryptor = enc
+	}
+	return err
+}
+
+func TestAddDevice(t *testing.T) {
+	env, other := deviceKeysPair(t)
+	ctx := context.Background()
+
+	if err := unlock(t, env, other); !errors.Is(err, ErrNotRegistered) {
+		t.Fatalf("Expected ErrNotRegistered, got %v", err)
+	}
+	if err := env.syncer.AddDevice(ctx, "laptop", other.syncer.device.PublicKey()); err != nil {
+		t.Fatalf("AddDevice failed: %v", err)
+	}
+	if err := unlock(t, env, other); err != nil {
+		t.Fatalf("Expected the added device to unlock the data key, got %v", err)
+	}
+	if ageCipher(other.syncer.encryptor).PublicKey() != ageCipher(env.syncer.encryptor).PublicKey() {
+		t.Error("Expected the data key to be the key the bucket already used")
+	}
+	if _, err := other.syncer.Pull(ctx); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if readFile(t, other.claudeDir, "CLAUDE.md") != "rules" {
+		t.Error("Expected the file pulled")
+	}
+
+	if err := env.syncer.AddDevice(ctx, "laptop", newTestEncryptor(t).PublicKey()); err == nil {
+		t.Error("Expected a duplicate name refused")
+	}
+	if err := env.syncer.AddDevice(ctx, "spare", "age1notakey"); err == nil {
+		t.Error("Expected an invalid recipient refused")
+	}
+	reg, err := other.syncer.Devices(ctx)
+	if err != nil || len(reg.Devices) != 2 {
+		t.Errorf("Expected two devices, got %+v (%v)", reg, err)
+	}
+}
+
+func TestRevokeDeviceRotatesDataKey(t *testing.T) {
+	env, other := deviceKeysPair(t)
+	ctx := context.Background()
+	if err := env.syncer.AddDevice(ctx, "laptop", other.syncer.device.PublicKey()); err != nil {
+		t.Fatalf("AddDevice failed: %v", err)
+	}
+	if err := unlock(t, env, other); err != nil {
+		t.Fatal(err)
+	}
+	oldKey := ageCipher(other.syncer.encryptor)
+
+	if _, err := env.syncer.RevokeDevice(ctx, "desktop"); err == nil {
+		t.Error("Expected a device not to revoke itself")
+	}
+	result, err := env.syncer.RevokeDevice(ctx, "laptop")
+	if err != nil {
+		t.Fatalf("RevokeDevice failed: %v", err)
+	}
+	if result.Rekeyed == 0 || ageCipher(env.syncer.encryptor).PublicKey() == oldKey.PublicKey() {
+		t.Fatalf("Expected the data key rotated, got %+v", result)
+	}
+
+	if err := unlock(t, env, other); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("Expected the revoked device shut out, got %v", err)
+	}
+	if _, err := oldKey.Decrypt(env.store.objects["CLAUDE.md.age"].data); err == nil {
+		t.Error("Expected the old data key not to open files any more")
+	}
+	reg, err := env.syncer.Devices(ctx)
+	if err != nil || len(reg.Devices) != 1 || reg.PendingKey != "" {
+		t.Fatalf("Unexpected registry %+v (%v)", reg, err)
+	}
+	if data, err := reg.DataEncryptor(nil); err != nil || data.PublicKey() != ageCipher(env.syncer.encryptor).PublicKey() {
+		t.Errorf("Expected the registry to hold the new data key (%v)", err)
+	}
+
+	_, remote, err := env.syncer.KeyFingerprints(ctx)
+	if err != nil || remote != env.syncer.encryptor.KeyID() {
+		t.Errorf("Expected the bucket fingerprint updated, got %q (%v)", remote, err)
+	}
+	if _, err := env.syncer.Pull(ctx); err != nil {
+		t.Errorf("Expected a clean pull with the new key, got %v", err)
+	}
+}
+
+func TestEnableDeviceKeysOnce(t *testing.T) {
+	env, other := deviceKeysPair(t)
+	ctx := context.Background()
+
+	if err := env.syncer.EnableDeviceKeys(ctx, newTestEncryptor(t), "again"); err == nil {
+		t.Error("Expected enabling twice refused")
+	}
+	other.syncer.encryptor = env.syncer.encryptor
+	other.syncer.device = nil
+	if err := other.syncer.EnableDeviceKeys(ctx, newTestEncryptor(t), "laptop"); err == nil {
+		t.Error("Expected an existing registry not overwritten")
+	}
+	if _, err := other.syncer.Devices(ctx); !errors.Is(err, errNoDeviceKeys) {
+		t.Errorf("Expected errNoDeviceKeys, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// RekeyResult reports what Rekey did.
+type RekeyResult struct {
+	Rekeyed    int
+	AlreadyNew int      // Already encrypted to the new key, e.g. by an interrupted run
+	Failed     []string // Keys neither key could decrypt
+}
+
+// Rekey re-encrypts every remote object, claude-sync's own metadata,
+// versions and trash included, from the current key to newEnc. Objects the
+// new key already opens are skipped, so a run that was interrupted can simply
+// be started again. Objects neither key opens are reported in Failed and left
+// alone; the caller should only switch keys when Failed is empty.
+//
+// This device's state is marked as uploaded for every file it rekeyed, since
+// the content is unchanged; other devices download everything once. With
+// sign_manifest, the manifest is re-signed with newEnc's signing key. The
+// bucket's key fingerprint becomes newEnc's.
+func (s *Syncer) Rekey(ctx context.Context, newEnc *crypto.Encryptor) (*RekeyResult, error) {
+	objects, err := s.storage.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote objects: %w", err)
+	}
+
+	// The KDF parameters, key fingerprint and attestations are stored in the
+	// clear, the device registry is encrypted to each device's own key, and
+	// the keyring to the key that wraps the data key
+	live := objects[:0]
+	for _, obj := range objects {
+		if obj.Key != KDFParamsKey && obj.Key != FingerprintKey && obj.Key != RegistryKey && obj.Key != KeyringKey &&
+			!strings.HasPrefix(obj.Key, AttestationPrefix) {
+			live = append(live, obj)
+		}
+	}
+	objects = live
+
+	// Objects are re-encrypted with the storage's KMS key too, but checked
+	// against the new key alone: the KMS key opens old and new objects alike
+	encryptTo := newEnc
+	if s.keyService != nil {
+		encryptTo = newEnc.WithKeyService(s.keyService)
+	}
+
+	result := &RekeyResult{}
+	var rekeyed []string
+	hashes := make(map[string]string, len(objects))
+	sem := make(chan struct{}, defaultWorkers)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	var completed atomic.Int32
+
+	for _, obj := range objects {
+		wg.Add(1)
+		go func(obj storage.ObjectInfo) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			s.progress(ProgressEvent{
+				Action:  "encrypt",
+				Path:    obj.Key,
+				Size:    obj.Size,
+				Current: int(completed.Add(1)),
+				Total:   len(objects),
+			})
+			done, hash, err := s.rekeyObject(ctx, obj.Key, newEnc, encryptTo)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				hashes[obj.Key] = hash
+			}
+			switch {
+			case errors.Is(err, errUndecryptable):
+				result.Failed = append(result.Failed, obj.Key)
+			case err != nil:
+				if firstErr == nil {
+					firstErr = err
+				}
+			case done:
+				result.Rekeyed++
+				rekeyed = append(rekeyed, obj.Key)
+			default:
+				result.AlreadyNew++
+			}
+		}(obj)
+	}
+	wg.Wait()
+	s.progress(ProgressEvent{Action: "encrypt", Complete: true, Total: len(objects)})
+
+	for _, key := range rekeyed {
+		if path, ok := s.localPath(key); ok {
+			s.state.MarkUploaded(path)
+			s.state.SetObjectHash(path, hashes[key])
+		}
+	}
+	if err := s.state.Save(); err != nil {
+		return result, fmt.Errorf("failed to save state: %w", err)
+	}
+	if firstErr != nil {
+		return result, firstErr
+	}
+	if s.cfg.SignManifest && len(result.Failed) == 0 {
+		resigner := *s
+		resigner.encryptor = newEnc
+		paths := make(map[string]string, len(hashes))
+		for key, hash := range hashes {
+			if path, ok := s.localPath(key); ok {
+				paths[path] = hash
+			}
+		}
+		if err := resigner.patchManifest(ctx, paths); err != nil {
+			return result, err
+		}
+	}
+	if len(result.Failed) == 0 {
+		if err := SaveFingerprint(ctx, s.storage, newEnc.Fingerprint()); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+var errUndecryptable = errors.New("object can't be decrypted with either key")
+
+// rekeyObject re-encrypts one object with encryptTo unless newEnc already
+// opens it, reporting false when it does, and returns the hash of the object
+// as now stored.
+func (s *Syncer) rekeyObject(ctx context.Context, key string, newEnc, encryptTo *crypto.Encryptor) (bool, string, error) {
+	encrypted, err := s.storage.Download(ctx, key)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	if _, err := newEnc.Decrypt(encrypted); err == nil {
+		return false, sha256Hex(encrypted), nil
+	}
+	plaintext, err := s.encryptor.Decrypt(encrypted)
+	if err != nil {
+		return false, "", errUndecryptable
+	}
+	reencrypted, err := encryptTo.Encrypt(plaintext)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to encrypt %s: %w", key, err)
+	}
+	if err := s.storage.Upload(ctx, key, reencrypted); err != nil {
+		return false, "", fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return true, sha256Hex(reencrypted), nil
+}
@@ -0,0 +1,132 @@
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tawanorg/claude-sync/internal/crypto"
+)
+
+func newTestEncryptor(t *testing.T) *crypto.Encryptor {
+	t.Helper()
+	keyPath := filepath.Join(t.TempDir(), "age-key.txt")
+	if err := crypto.GenerateKey(keyPath); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := crypto.NewEncryptor(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc
+}
+
+func TestRekey(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Versioning = true
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "settings.json", `{"v":1}`)
+	writeFile(t, env.claudeDir, "agents/reviewer.md", "review")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	// Everything but the plaintext key fingerprint
+	total := len(env.store.objects) - 1
+
+	newEnc := newTestEncryptor(t)
+	result, err := env.syncer.Rekey(ctx, newEnc)
+	if err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+	if result.Rekeyed != total || result.AlreadyNew != 0 || len(result.Failed) != 0 {
+		t.Errorf("Expected all %d objects rekeyed, got %+v", total, result)
+	}
+	for key, obj := range env.store.objects {
+		if key == FingerprintKey {
+			if got := strings.TrimSpace(string(obj.data)); got != newEnc.Fingerprint() {
+				t.Errorf("Expected the new key's fingerprint stored, got %s", got)
+			}
+			continue
+		}
+		if _, err := newEnc.Decrypt(obj.data); err != nil {
+			t.Errorf("%s doesn't open with the new key: %v", key, err)
+		}
+	}
+
+	// Running again finds nothing left to do
+	result, err = env.syncer.Rekey(ctx, newEnc)
+	if err != nil {
+		t.Fatalf("Second Rekey failed: %v", err)
+	}
+	if result.Rekeyed != 0 || result.AlreadyNew != total {
+		t.Errorf("Expected everything already rekeyed, got %+v", result)
+	}
+
+	// With the new key, this device has nothing to pull
+	env.syncer.encryptor = newEnc
+	pulled, err := env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(pulled.Downloaded) != 0 {
+		t.Errorf("Expected no downloads after rekey, got %v", pulled.Downloaded)
+	}
+	if got := readFile(t, env.claudeDir, "settings.json"); got != `{"v":1}` {
+		t.Errorf("Expected content unchanged, got %q", got)
+	}
+}
+
+func TestRekeyReportsForeignObjects(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "settings.json", `{}`)
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	foreign, err := newTestEncryptor(t).Encrypt([]byte("someone else's"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.store.Upload(ctx, "stray.age", foreign); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := env.syncer.Rekey(ctx, newTestEncryptor(t))
+	if err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+	if len(result.Failed) != 1 || result.Failed[0] != "stray.age" {
+		t.Errorf("Expected stray.age reported, got %+v", result)
+	}
+}
+
+func TestRekeyWithAttestations(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Attestations = true
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "v1")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	attestations := 0
+	for key := range env.store.objects {
+		if strings.HasPrefix(key, AttestationPrefix) {
+			attestations++
+		}
+	}
+	if attestations == 0 {
+		t.Fatal("Expected the push to write an attestation")
+	}
+
+	result, err := env.syncer.Rekey(ctx, newTestEncryptor(t))
+	if err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+	if len(result.Failed) != 0 {
+		t.Errorf("Expected attestations left alone, got failures %v", result.Failed)
+	}
+}
@@ -0,0 +1,105 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// RequestLog accumulates storage request counts per command across runs, so
+// users on request-billed providers can see what polling, verification and
+// the like cost over time. It lives next to the state file and, like it, is
+// a disposable local record.
+type RequestLog struct {
+	Since    time.Time                       `json:"since"`
+	Commands map[string]storage.RequestStats `json:"commands"`
+}
+
+// Total sums the requests of every command.
+func (l *RequestLog) Total() storage.RequestStats {
+	var total storage.RequestStats
+	for _, stats := range l.Commands {
+		total = total.Add(stats)
+	}
+	return total
+}
+
+// CommandNames returns the recorded commands, busiest first.
+func (l *RequestLog) CommandNames() []string {
+	names := make([]string, 0, len(l.Commands))
+	for name := range l.Commands {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ti, tj := l.Commands[names[i]].Total(), l.Commands[names[j]].Total()
+		if ti != tj {
+			return ti > tj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// LoadRequestLog reads the request log, returning an empty one if none exists.
+func LoadRequestLog() (*RequestLog, error) {
+	return loadRequestLogFrom(config.RequestsFilePath())
+}
+
+func loadRequestLogFrom(path string) (*RequestLog, error) {
+	log := &RequestLog{Commands: make(map[string]storage.RequestStats)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return log, nil
+		}
+		return nil, fmt.Errorf("failed to read request log: %w", err)
+	}
+	if err := json.Unmarshal(data, log); err != nil {
+		// Only counters: start over rather than fail the command
+		return &RequestLog{Commands: make(map[string]storage.RequestStats)}, nil
+	}
+	if log.Commands == nil {
+		log.Commands = make(map[string]storage.RequestStats)
+	}
+	return log, nil
+}
+
+// RecordRequests adds the requests one command made to the request log.
+func RecordRequests(command string, stats storage.RequestStats) error {
+	return recordRequestsAt(config.RequestsFilePath(), command, stats)
+}
+
+func recordRequestsAt(path, command string, stats storage.RequestStats) error {
+	log, err := loadRequestLogFrom(path)
+	if err != nil {
+		return err
+	}
+	if log.Since.IsZero() {
+		log.Since = time.Now()
+	}
+	log.Commands[command] = log.Commands[command].Add(stats)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// requestStats returns the requests made through the syncer's storage so far,
+// or zero when the storage isn't metered (e.g. test doubles).
+func (s *Syncer) requestStats() storage.RequestStats {
+	if m, ok := s.storage.(interface{ Stats() storage.RequestStats }); ok {
+		return m.Stats()
+	}
+	return storage.RequestStats{}
+}
@@ -0,0 +1,64 @@
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+func TestSyncResultRequests(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.storage = storage.NewMetered(env.store)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# V1")
+	result, err := env.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	// File, manifest and snapshot uploads (plus the snapshot listing)
+	if result.Requests.Put < 3 {
+		t.Errorf("Expected at least 3 puts, got %+v", result.Requests)
+	}
+
+	result, err = env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if result.Requests.List < 1 || result.Requests.Put != 0 {
+		t.Errorf("Expected pull to list without uploading, got %+v", result.Requests)
+	}
+}
+
+func TestRecordRequests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.json")
+
+	if err := recordRequestsAt(path, "push", storage.RequestStats{Put: 3, List: 1}); err != nil {
+		t.Fatalf("recordRequestsAt failed: %v", err)
+	}
+	if err := recordRequestsAt(path, "push", storage.RequestStats{Put: 2}); err != nil {
+		t.Fatalf("recordRequestsAt failed: %v", err)
+	}
+	if err := recordRequestsAt(path, "pull", storage.RequestStats{List: 1, Get: 10}); err != nil {
+		t.Fatalf("recordRequestsAt failed: %v", err)
+	}
+
+	log, err := loadRequestLogFrom(path)
+	if err != nil {
+		t.Fatalf("loadRequestLogFrom failed: %v", err)
+	}
+	if got := log.Commands["push"]; got.Put != 5 || got.List != 1 {
+		t.Errorf("push = %+v, want 5 puts and 1 list", got)
+	}
+	if log.Since.IsZero() {
+		t.Error("Expected Since to be set")
+	}
+	if total := log.Total(); total.Total() != 17 {
+		t.Errorf("Total = %d, want 17", total.Total())
+	}
+	if names := log.CommandNames(); len(names) != 2 || names[0] != "pull" {
+		t.Errorf("CommandNames = %v, want pull first", names)
+	}
+}
@@ -0,0 +1,196 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// RestoreItem is one file a restore would write.
+type RestoreItem struct {
+	Path      string
+	Version   Version
+	Action    string // "create" or "modify"
+	LocalSize int64
+
+	data []byte // Already downloaded while planning, if any
+}
+
+// RestorePlan lists what restoring earlier content would change locally.
+type RestorePlan struct {
+	At       time.Time // The point in time being restored
+	Snapshot string    // Snapshot ID, for whole restores
+
+	Items     []RestoreItem
+	Unchanged []string // Files whose local content already matches
+	Missing   []string // Files with no stored version at or before At
+}
+
+// PlanRestore picks a stored version of one file: the latest at or before at
+// (a date, timestamp, or age such as "2d"), or the nth newest when version is
+// set. With neither, it picks the version before the latest, which undoes the
+// last push of the file.
+func (s *Syncer) PlanRestore(ctx context.Context, relativePath, at string, version int) (*RestorePlan, error) {
+	if at != "" && version != 0 {
+		return nil, fmt.Errorf("use either a time or a version number, not both")
+	}
+
+	versions, err := s.ListVersions(ctx, relativePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no stored versions of %s (versions are kept on push when 'versioning: true' is set)", relativePath)
+	}
+
+	var v Version
+	if at != "" {
+		t, ok := parseTimeRef(at, time.Now())
+		if !ok {
+			return nil, fmt.Errorf("invalid time %q (use a date like 2024-05-01, a timestamp, or an age like 2d)", at)
+		}
+		var found bool
+		if v, found = versionAt(versions, t); !found {
+			return nil, fmt.Errorf("no version of %s at or before %s (oldest is %s)",
+				relativePath, t.Format(time.RFC3339), versions[len(versions)-1].CreatedAt.Format(time.RFC3339))
+		}
+	} else {
+		if version == 0 {
+			version = 2
+		}
+		if version < 1 || version > len(versions) {
+			return nil, fmt.Errorf("%s has %d stored versions; version %d doesn't exist", relativePath, len(versions), version)
+		}
+		v = versions[version-1]
+	}
+
+	data, err := s.FetchVersion(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &RestorePlan{At: v.CreatedAt}
+	localHash, localSize, exists := s.localFileInfo(relativePath)
+	switch {
+	case exists && localHash == s.state.hashBytes(data):
+		plan.Unchanged = append(plan.Unchanged, relativePath)
+	case exists:
+		plan.Items = append(plan.Items, RestoreItem{Path: relativePath, Version: v, Action: "modify", LocalSize: localSize, data: data})
+	default:
+		plan.Items = append(plan.Items, RestoreItem{Path: relativePath, Version: v, Action: "create", data: data})
+	}
+	return plan, nil
+}
+
+// PlanRestoreAll plans restoring every file as it was in the snapshot at ref
+// (a snapshot ID, date, timestamp, or age). Files created since then are left
+// alone: a restore only ever writes content back, it never deletes.
+func (s *Syncer) PlanRestoreAll(ctx context.Context, ref string) (*RestorePlan, error) {
+	snaps, err := s.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info, err := resolveSnapshotRef(snaps, ref, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	snap, err := s.downloadSnapshot(ctx, info)
+	if err != nil {
+		return nil, err
+	}
+	return s.planRestoreSnapshot(ctx, snap)
+}
+
+// planRestoreSnapshot plans restoring every file in snap from the versions
+// stored at or before it was taken.
+func (s *Syncer) planRestoreSnapshot(ctx context.Context, snap *Snapshot) (*RestorePlan, error) {
+	objects, err := s.storage.List(ctx, VersionPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+
+	plan := &RestorePlan{At: snap.CreatedAt, Snapshot: snap.ID}
+	paths := make([]string, 0, len(snap.Files))
+	for path := range snap.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if s.isExcluded(path) {
+			continue
+		}
+		localHash, localSize, exists := s.localFileInfo(path)
+		if meta := snap.Files[path]; exists && meta.Hash != "" && meta.Hash == localHash {
+			plan.Unchanged = append(plan.Unchanged, path)
+			continue
+		}
+		v, found := versionAt(versionsIn(objects, s.versionDir(path), path), snap.CreatedAt)
+		if !found {
+			plan.Missing = append(plan.Missing, path)
+			continue
+		}
+		item := RestoreItem{Path: path, Version: v, Action: "create"}
+		if exists {
+			item.Action, item.LocalSize = "modify", localSize
+		}
+		plan.Items = append(plan.Items, item)
+	}
+	return plan, nil
+}
+
+// Restore writes the planned versions into ~/.claude and returns the paths it
+// restored. State is left alone, so the next push uploads the restored content
+// and other devices pick it up.
+func (s *Syncer) Restore(ctx context.Context, plan *RestorePlan) ([]string, error) {
+	var restored []string
+	for _, item := range plan.Items {
+		data := item.data
+		if data == nil {
+			var err error
+			if data, err = s.FetchVersion(ctx, item.Version); err != nil {
+				return restored, err
+			}
+		}
+		if err := s.writeClaudeFile(item.Path, data); err != nil {
+			return restored, fmt.Errorf("failed to restore %s: %w", item.Path, err)
+		}
+		restored = append(restored, item.Path)
+	}
+	return restored, nil
+}
+
+// versionAt returns the latest version created at or before t from a
+// newest-first list.
+func versionAt(versions []Version, t time.Time) (Version, bool) {
+	for _, v := range versions {
+		if !v.CreatedAt.After(t) {
+			return v, true
+		}
+	}
+	return Version{}, false
+}
+
+// LocalHash hashes a file under ~/.claude the way sync state does, so it can be
+// compared with DescribeVersions hashes. It returns "" if the file doesn't exist.
+func (s *Syncer) LocalHash(relativePath string) string {
+	hash, _, _ := s.localFileInfo(relativePath)
+	return hash
+}
+
+// localFileInfo hashes a file under ~/.claude, reporting false if it doesn't exist.
+func (s *Syncer) localFileInfo(relativePath string) (hash string, size int64, exists bool) {
+	fullPath := filepath.Join(s.claudeDir, relativePath)
+	info, err := os.Stat(fullPath)
+	if err != nil || info.IsDir() {
+		return "", 0, false
+	}
+	hash, err = s.state.hashFile(fullPath)
+	if err != nil {
+		return "", 0, false
+	}
+	return hash, info.Size(), true
+}
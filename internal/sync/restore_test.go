@@ -0,0 +1,124 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRestoreFile(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Versioning = true
+	ctx := context.Background()
+
+	if _, err := env.syncer.PlanRestore(ctx, "settings.json", "", 0); err == nil {
+		t.Error("Expected an error with no stored versions")
+	}
+
+	for _, content := range []string{`{"v":1}`, `{"v":2}`, `{"v":3`} {
+		writeFile(t, env.claudeDir, "settings.json", content)
+		if _, err := env.syncer.Push(ctx); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Default undoes the last push
+	plan, err := env.syncer.PlanRestore(ctx, "settings.json", "", 0)
+	if err != nil {
+		t.Fatalf("PlanRestore failed: %v", err)
+	}
+	if len(plan.Items) != 1 || plan.Items[0].Action != "modify" {
+		t.Fatalf("Expected one modify, got %+v", plan.Items)
+	}
+	restored, err := env.syncer.Restore(ctx, plan)
+	if err != nil || len(restored) != 1 {
+		t.Fatalf("Restore failed: %v (%v)", err, restored)
+	}
+	if got := readFile(t, env.claudeDir, "settings.json"); got != `{"v":2}` {
+		t.Errorf("Expected previous version, got %q", got)
+	}
+
+	// The restore is a local change for the next push
+	changes, err := env.syncer.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Path != "settings.json" {
+		t.Errorf("Expected settings.json pending push, got %+v", changes)
+	}
+
+	plan, err = env.syncer.PlanRestore(ctx, "settings.json", "", 3)
+	if err != nil {
+		t.Fatalf("PlanRestore --version 3 failed: %v", err)
+	}
+	if _, err := env.syncer.Restore(ctx, plan); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if got := readFile(t, env.claudeDir, "settings.json"); got != `{"v":1}` {
+		t.Errorf("Expected oldest version, got %q", got)
+	}
+
+	// Restoring what's already there changes nothing
+	plan, err = env.syncer.PlanRestore(ctx, "settings.json", "", 3)
+	if err != nil {
+		t.Fatalf("PlanRestore failed: %v", err)
+	}
+	if len(plan.Items) != 0 || len(plan.Unchanged) != 1 {
+		t.Errorf("Expected file to be unchanged, got %+v", plan)
+	}
+
+	if _, err := env.syncer.PlanRestore(ctx, "settings.json", "", 4); err == nil {
+		t.Error("Expected an error for a version that doesn't exist")
+	}
+	if _, err := env.syncer.PlanRestore(ctx, "settings.json", "1d", 1); err == nil {
+		t.Error("Expected an error for --at with --version")
+	}
+	if _, err := env.syncer.PlanRestore(ctx, "settings.json", "30d", 0); err == nil {
+		t.Error("Expected an error for a time before the oldest version")
+	}
+}
+
+func TestRestoreAll(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Versioning = true
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# Good")
+	writeFile(t, env.claudeDir, "agents/a.md", "a")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	snaps, err := env.syncer.ListSnapshots(ctx)
+	if err != nil || len(snaps) != 1 {
+		t.Fatalf("Expected one snapshot, got %v (%v)", snaps, err)
+	}
+
+	time.Sleep(1100 * time.Millisecond) // Snapshot IDs have second precision
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# Broken")
+	writeFile(t, env.claudeDir, "agents/new.md", "new")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	plan, err := env.syncer.PlanRestoreAll(ctx, snaps[0].ID)
+	if err != nil {
+		t.Fatalf("PlanRestoreAll failed: %v", err)
+	}
+	if len(plan.Items) != 1 || plan.Items[0].Path != "CLAUDE.md" {
+		t.Fatalf("Expected only CLAUDE.md to restore, got %+v", plan.Items)
+	}
+	if len(plan.Unchanged) != 1 || plan.Unchanged[0] != "agents/a.md" {
+		t.Errorf("Expected agents/a.md unchanged, got %v", plan.Unchanged)
+	}
+	if _, err := env.syncer.Restore(ctx, plan); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if got := readFile(t, env.claudeDir, "CLAUDE.md"); got != "# Good" {
+		t.Errorf("Expected snapshot content, got %q", got)
+	}
+	// Files created after the snapshot are left alone
+	if got := readFile(t, env.claudeDir, "agents/new.md"); got != "new" {
+		t.Errorf("Expected agents/new.md untouched, got %q", got)
+	}
+}
@@ -0,0 +1,104 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PruneVersionsResult reports what PruneVersions removed.
+type PruneVersionsResult struct {
+	Pruned []Version // Versions deleted (or, on a dry run, that would be)
+	Kept   int
+}
+
+// PruneVersions deletes stored versions outside versions_keep and
+// versions_max_age. The newest version of each file, and any version a named
+// snapshot needs, is always kept. With dryRun, nothing is deleted.
+func (s *Syncer) PruneVersions(ctx context.Context, dryRun bool) (*PruneVersionsResult, error) {
+	maxAge, err := s.cfg.VersionsMaxAgeDuration()
+	if err != nil {
+		return nil, err
+	}
+	keep := s.cfg.VersionsKeep
+	if keep <= 0 && maxAge <= 0 {
+		return nil, fmt.Errorf("no retention configured; set versions_keep or versions_max_age")
+	}
+
+	objects, err := s.storage.List(ctx, VersionPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+
+	// Versions a named snapshot restores from are kept regardless
+	pinned, err := s.pinnedVersions(ctx, objects)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group by file: everything before the version name
+	dirs := make(map[string]bool)
+	for _, obj := range objects {
+		if i := strings.LastIndex(obj.Key, "/"); i >= 0 {
+			dirs[obj.Key[:i+1]] = true
+		}
+	}
+	sortedDirs := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sortedDirs = append(sortedDirs, dir)
+	}
+	sort.Strings(sortedDirs)
+
+	result := &PruneVersionsResult{}
+	now := time.Now()
+	for _, dir := range sortedDirs {
+		path := strings.TrimSuffix(strings.TrimPrefix(dir, VersionPrefix), "/")
+		if local, ok := s.localPath(path + ".age"); ok {
+			path = local
+		}
+		versions := versionsIn(objects, dir, path)
+		var prune []Version
+		for _, v := range expiredVersions(versions, keep, maxAge, now) {
+			if !pinned[v.Key] {
+				prune = append(prune, v)
+			}
+		}
+		result.Pruned = append(result.Pruned, prune...)
+		result.Kept += len(versions) - len(prune)
+	}
+
+	if dryRun || len(result.Pruned) == 0 {
+		return result, nil
+	}
+	keys := make([]string, len(result.Pruned))
+	for i, v := range result.Pruned {
+		keys[i] = v.Key
+	}
+	if err := s.storage.DeleteBatch(ctx, keys); err != nil {
+		return nil, fmt.Errorf("failed to delete versions: %w", err)
+	}
+	return result, nil
+}
+
+// hasVersionRetention reports whether pushes should prune versions.
+func (s *Syncer) hasVersionRetention() bool {
+	return s.cfg.Versioning && (s.cfg.VersionsKeep > 0 || s.cfg.VersionsMaxAge != "")
+}
+
+// expiredVersions picks the versions, from a newest-first list, that fall
+// outside the newest keep (when keep > 0) or are older than maxAge (when
+// maxAge > 0). The newest version is never picked.
+func expiredVersions(versions []Version, keep int, maxAge time.Duration, now time.Time) []Version {
+	var expired []Version
+	for i, v := range versions {
+		if i == 0 {
+			continue
+		}
+		if (keep > 0 && i >= keep) || (maxAge > 0 && now.Sub(v.CreatedAt) > maxAge) {
+			expired = append(expired, v)
+		}
+	}
+	return expired
+}
@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExpiredVersions(t *testing.T) {
+	now := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	var versions []Version
+	for i := 0; i < 5; i++ {
+		versions = append(versions, Version{Key: string(rune('a' + i)), CreatedAt: now.AddDate(0, 0, -10*i)})
+	}
+
+	keys := func(vs []Version) string {
+		var s string
+		for _, v := range vs {
+			s += v.Key
+		}
+		return s
+	}
+
+	if got := keys(expiredVersions(versions, 2, 0, now)); got != "cde" {
+		t.Errorf("keep 2: got %q, want cde", got)
+	}
+	if got := keys(expiredVersions(versions, 0, 25*24*time.Hour, now)); got != "de" {
+		t.Errorf("max age 25d: got %q, want de", got)
+	}
+	if got := keys(expiredVersions(versions, 4, 15*24*time.Hour, now)); got != "cde" {
+		t.Errorf("keep 4 and 15d: got %q, want cde", got)
+	}
+	// The newest version survives even when it is too old
+	if got := keys(expiredVersions(versions[3:], 0, time.Hour, now)); got != "e" {
+		t.Errorf("all old: got %q, want e", got)
+	}
+}
+
+func TestPruneVersions(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Versioning = true
+	ctx := context.Background()
+
+	if _, err := env.syncer.PruneVersions(ctx, true); err == nil {
+		t.Error("Expected an error without a retention policy")
+	}
+
+	for _, content := range []string{"1", "2", "3"} {
+		writeFile(t, env.claudeDir, "CLAUDE.md", content)
+		writeFile(t, env.claudeDir, "agents/a.md", content)
+		if _, err := env.syncer.Push(ctx); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	env.syncer.cfg.VersionsKeep = 2
+	result, err := env.syncer.PruneVersions(ctx, true)
+	if err != nil {
+		t.Fatalf("PruneVersions dry run failed: %v", err)
+	}
+	if len(result.Pruned) != 2 || result.Kept != 4 {
+		t.Fatalf("Expected 2 pruned and 4 kept, got %d and %d", len(result.Pruned), result.Kept)
+	}
+	if versions, _ := env.syncer.ListVersions(ctx, "CLAUDE.md"); len(versions) != 3 {
+		t.Errorf("Dry run deleted versions: %d left", len(versions))
+	}
+
+	// Pushing with a policy prunes automatically
+	writeFile(t, env.claudeDir, "CLAUDE.md", "4")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	versions, err := env.syncer.ListVersions(ctx, "CLAUDE.md")
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("Expected 2 versions after push, got %d", len(versions))
+	}
+	if data, _ := env.syncer.FetchVersion(ctx, versions[0]); string(data) != "4" {
+		t.Errorf("Expected newest version kept, got %q", data)
+	}
+	if versions, _ := env.syncer.ListVersions(ctx, "agents/a.md"); len(versions) != 2 {
+		t.Errorf("Expected agents/a.md pruned to 2, got %d", len(versions))
+	}
+}
@@ -0,0 +1,367 @@
+package sync
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProposalPrefix holds changes to reviewed command sets (review_namespaces)
+// waiting for approval: _proposals/<id>/proposal.json.age describes one
+// push's changes and _proposals/<id>/files/<remote key> holds each proposed
+// file, encrypted like any other.
+const ProposalPrefix = "_proposals/"
+
+// ErrSelfApproval is returned when a device tries to approve its own proposal.
+var ErrSelfApproval = errors.New("a proposal must be approved from another device")
+
+// Proposal is a set of changes to reviewed command sets staged by one push.
+type Proposal struct {
+	ID        string           `json:"id"`
+	Device    string           `json:"device"`
+	CreatedAt time.Time        `json:"created_at"`
+	Changes   []ProposedChange `json:"changes"`
+}
+
+// ProposedChange is one file in a proposal.
+type ProposedChange struct {
+	Path    string    `json:"path"`
+	Action  string    `json:"action"` // "add", "modify" or "delete"
+	Hash    string    `json:"hash,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"mod_time,omitempty"`
+}
+
+// PendingReview records a local change this device proposed, so push doesn't
+// propose it again while it waits for review.
+type PendingReview struct {
+	ID   string `json:"id"`
+	Hash string `json:"hash,omitempty"` // Empty for a proposed deletion
+}
+
+func proposalRecordKey(id string) string {
+	return ProposalPrefix + id + "/proposal.json.age"
+}
+
+func (s *Syncer) proposalFileKey(id, relPath string) string {
+	return ProposalPrefix + id + "/files/" + s.remoteKey(relPath)
+}
+
+func newProposalID(now time.Time) string {
+	b := make([]byte, 2)
+	_, _ = rand.Read(b)
+	return now.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(b)
+}
+
+// needsReview reports whether relPath is in a command set listed in
+// review_namespaces.
+func (s *Syncer) needsReview(relPath string) bool {
+	reviewed, err := s.cfg.ReviewedCommandSets()
+	if err != nil || len(reviewed) == 0 {
+		return false
+	}
+	rest, found := strings.CutPrefix(relPath, commandsDir)
+	if !found {
+		return false
+	}
+	first, _, nested := strings.Cut(rest, "/")
+	return nested && reviewed[first]
+}
+
+// splitForReview takes the changes to reviewed command sets out of changes.
+// Those not yet proposed are returned in review; those waiting on an earlier
+// proposal, or whose proposal was just rejected, are only reported in result.
+// It reports whether it changed state.
+func (s *Syncer) splitForReview(ctx context.Context, changes []FileChange, result *SyncResult) (kept, review []FileChange, changed bool, err error) {
+	if len(s.cfg.ReviewNamespaces) == 0 && len(s.state.Proposed) == 0 {
+		return changes, nil, false, nil
+	}
+	rejected, changed, err := s.settleProposals(ctx)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	result.Rejected = rejected
+	skip := make(map[string]bool, len(rejected))
+	for _, path := range rejected {
+		skip[path] = true
+	}
+
+	for _, change := range changes {
+		if !s.needsReview(change.Path) {
+			kept = append(kept, change)
+			continue
+		}
+		if skip[change.Path] {
+			// Reported as rejected; the next push proposes it again
+			continue
+		}
+		if changed && settledTo(s.state.GetFile(change.Path), change) {
+			// Just approved, so the change is already the shared copy
+			continue
+		}
+		if pending, ok := s.state.Proposed[change.Path]; ok && pending.Hash == change.LocalHash {
+			result.AwaitingReview = append(result.AwaitingReview, change.Path)
+			continue
+		}
+		review = append(review, change)
+	}
+	sort.Strings(result.AwaitingReview)
+	return kept, review, changed, nil
+}
+
+// settledTo reports whether a change detected before settling proposals
+// matches the state they left behind.
+func settledTo(file *FileState, change FileChange) bool {
+	if change.Action == "delete" {
+		return file == nil
+	}
+	return file != nil && file.Hash == change.LocalHash
+}
+
+// settleProposals clears this device's pending reviews whose proposal is gone
+// from the remote. An approved change (the manifest now has the proposed
+// hash, or no entry for a proposed deletion) becomes the state baseline; any
+// other was rejected, and its path is returned.
+func (s *Syncer) settleProposals(ctx context.Context) (rejected []string, changed bool, err error) {
+	if len(s.state.Proposed) == 0 {
+		return nil, false, nil
+	}
+	objects, err := s.storage.List(ctx, ProposalPrefix)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list proposals: %w", err)
+	}
+	live := make(map[string]bool)
+	for _, obj := range objects {
+		if id, ok := strings.CutSuffix(strings.TrimPrefix(obj.Key, ProposalPrefix), "/proposal.json.age"); ok {
+			live[id] = true
+		}
+	}
+
+	var manifest *FileManifest
+	manifestLoaded := false
+	for path, pending := range s.state.Proposed {
+		if live[pending.ID] {
+			continue
+		}
+		if !manifestLoaded {
+			manifest, _ = s.downloadManifest(ctx)
+			manifestLoaded = true
+		}
+		delete(s.state.Proposed, path)
+		changed = true
+
+		var meta FileMetadata
+		approved := false
+		if manifest != nil {
+			var ok bool
+			meta, ok = manifest.Files[path]
+			approved = ok == (pending.Hash != "") && meta.Hash == pending.Hash
+		}
+		switch {
+		case !approved:
+			rejected = append(rejected, path)
+		case pending.Hash == "":
+			s.state.RemoveFile(path)
+		default:
+			// The approved content is now the remote copy. If the local file
+			// changed again since, the difference is proposed as usual.
+			if info, err := os.Stat(filepath.Join(s.claudeDir, path)); err == nil {
+				s.state.UpdateFile(path, info, pending.Hash)
+				s.state.MarkUploaded(path)
+				s.state.SetOrigin(path, meta.Device, meta.PushedAt)
+			}
+		}
+	}
+	sort.Strings(rejected)
+	return rejected, changed, nil
+}
+
+// propose uploads changes as a new proposal and records them as pending
+// review. Earlier proposals from this device that the new one entirely
+// replaces are withdrawn.
+func (s *Syncer) propose(ctx context.Context, changes []FileChange) (*Proposal, error) {
+	now := time.Now()
+	p := &Proposal{ID: newProposalID(now), Device: s.state.DeviceID, CreatedAt: now}
+	for _, change := range changes {
+		pc := ProposedChange{Path: change.Path, Action: change.Action}
+		if change.Action != "delete" {
+			encrypted, err := s.encodeFile(change.Path)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", change.Path, err)
+			}
+			if err := s.storage.Upload(ctx, s.proposalFileKey(p.ID, change.Path), encrypted); err != nil {
+				return nil, fmt.Errorf("%s: failed to upload: %w", change.Path, err)
+			}
+			pc.Hash, pc.Size, pc.ModTime = change.LocalHash, change.LocalSize, change.LocalTime
+		}
+		p.Changes = append(p.Changes, pc)
+	}
+	sort.Slice(p.Changes, func(i, j int) bool { return p.Changes[i].Path < p.Changes[j].Path })
+
+	// The record goes last, so a proposal is only listed once complete
+	if err := s.uploadJSON(ctx, proposalRecordKey(p.ID), p); err != nil {
+		return nil, err
+	}
+
+	if s.state.Proposed == nil {
+		s.state.Proposed = make(map[string]PendingReview)
+	}
+	replaced := make(map[string]bool)
+	for _, pc := range p.Changes {
+		if old, ok := s.state.Proposed[pc.Path]; ok {
+			replaced[old.ID] = true
+		}
+		s.state.Proposed[pc.Path] = PendingReview{ID: p.ID, Hash: pc.Hash}
+	}
+	for _, pending := range s.state.Proposed {
+		delete(replaced, pending.ID)
+	}
+	for id := range replaced {
+		if err := s.deleteProposal(ctx, id); err != nil {
+			s.log("Warning: failed to withdraw proposal %s: %v", id, err)
+		}
+	}
+	return p, nil
+}
+
+// ListProposals returns the proposals waiting for review, oldest first.
+func (s *Syncer) ListProposals(ctx context.Context) ([]Proposal, error) {
+	objects, err := s.storage.List(ctx, ProposalPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list proposals: %w", err)
+	}
+	var proposals []Proposal
+	for _, obj := range objects {
+		if !strings.HasSuffix(obj.Key, "/proposal.json.age") {
+			continue
+		}
+		var p Proposal
+		if err := s.downloadJSON(ctx, obj.Key, &p); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", obj.Key, err)
+		}
+		proposals = append(proposals, p)
+	}
+	sort.Slice(proposals, func(i, j int) bool { return proposals[i].CreatedAt.Before(proposals[j].CreatedAt) })
+	return proposals, nil
+}
+
+func (s *Syncer) findProposal(ctx context.Context, id string) (*Proposal, error) {
+	var p Proposal
+	if err := s.downloadJSON(ctx, proposalRecordKey(id), &p); err != nil {
+		return nil, fmt.Errorf("no proposal %q (see 'claude-sync proposals')", id)
+	}
+	return &p, nil
+}
+
+// ApproveProposal makes a proposal's changes the shared copy: each proposed
+// file replaces the remote one, proposed deletions are deleted, and the
+// manifest records the proposing device. Local files are left alone; the
+// next pull brings them in. Approving your own proposal is refused.
+func (s *Syncer) ApproveProposal(ctx context.Context, id string) (*Proposal, error) {
+	p, err := s.findProposal(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p.Device == s.state.DeviceID {
+		return nil, ErrSelfApproval
+	}
+
+	manifest, err := s.downloadManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		manifest = &FileManifest{Files: make(map[string]FileMetadata)}
+	}
+
+	var named []string
+	for _, c := range p.Changes {
+		if c.Action != "delete" {
+			named = append(named, c.Path)
+		}
+	}
+	if err := s.recordKeyNames(ctx, named); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var uploaded, deleteKeys []string
+	for _, c := range p.Changes {
+		key := s.remoteKey(c.Path)
+		if c.Action == "delete" {
+			deleteKeys = append(deleteKeys, key)
+			continue
+		}
+		encrypted, err := s.storage.Download(ctx, s.proposalFileKey(p.ID, c.Path))
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to download proposed file: %w", c.Path, err)
+		}
+		if err := s.storage.Upload(ctx, key, encrypted); err != nil {
+			return nil, fmt.Errorf("%s: failed to upload: %w", c.Path, err)
+		}
+		if s.cfg.Versioning {
+			if err := s.storage.Upload(ctx, s.versionKey(c.Path, now), encrypted); err != nil {
+				return nil, fmt.Errorf("%s: failed to upload version: %w", c.Path, err)
+			}
+		}
+		manifest.Files[c.Path] = FileMetadata{ModTime: c.ModTime, Hash: c.Hash, Size: c.Size, Device: p.Device, PushedAt: now, ObjectHash: sha256Hex(encrypted)}
+		uploaded = append(uploaded, c.Path)
+	}
+
+	var deleted []string
+	if len(deleteKeys) > 0 {
+		done, err := s.deleteRemote(ctx, deleteKeys)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range p.Changes {
+			if c.Action == "delete" && done[s.remoteKey(c.Path)] {
+				delete(manifest.Files, c.Path)
+				deleted = append(deleted, c.Path)
+			}
+		}
+	}
+
+	if err := s.putManifest(ctx, manifest); err != nil {
+		return nil, err
+	}
+	s.updateRemoteCache(uploaded, deleted)
+	if err := s.deleteProposal(ctx, p.ID); err != nil {
+		return p, fmt.Errorf("changes applied, but failed to remove the proposal: %w", err)
+	}
+	s.attestAfterPush(ctx)
+	return p, nil
+}
+
+// RejectProposal discards a proposal. Any device may reject one, including
+// the one that made it. The proposing device finds out on its next push.
+func (s *Syncer) RejectProposal(ctx context.Context, id string) (*Proposal, error) {
+	p, err := s.findProposal(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return p, s.deleteProposal(ctx, p.ID)
+}
+
+// deleteProposal removes every object of a proposal.
+func (s *Syncer) deleteProposal(ctx context.Context, id string) error {
+	objects, err := s.storage.List(ctx, ProposalPrefix+id+"/")
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	keys := make([]string, len(objects))
+	for i, obj := range objects {
+		keys[i] = obj.Key
+	}
+	return s.storage.DeleteBatch(ctx, keys)
+}
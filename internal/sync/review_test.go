@@ -0,0 +1,225 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupReviewEnvs returns two devices sharing one bucket, with the "team"
+// command set under review.
+func setupReviewEnvs(t *testing.T) (author, reviewer *testEnv) {
+	t.Helper()
+	author = setupTestEnv(t)
+	author.syncer.state.DeviceID = "laptop"
+	author.syncer.cfg.CommandNamespaces = []string{"team"}
+	author.syncer.cfg.ReviewNamespaces = []string{"team"}
+
+	reviewer = setupTestEnv(t)
+	reviewer.syncer.state.DeviceID = "desktop"
+	reviewer.syncer.storage = author.store
+	reviewer.store = author.store
+	reviewer.syncer.encryptor = author.syncer.encryptor
+	reviewer.syncer.cfg.CommandNamespaces = []string{"team"}
+	reviewer.syncer.cfg.ReviewNamespaces = []string{"team"}
+	return author, reviewer
+}
+
+func TestReviewedPushCreatesProposal(t *testing.T) {
+	author, _ := setupReviewEnvs(t)
+	ctx := context.Background()
+
+	writeFile(t, author.claudeDir, "commands/team/deploy.md", "deploy v1")
+	writeFile(t, author.claudeDir, "commands/mine.md", "mine")
+
+	result, err := author.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if len(result.Uploaded) != 1 || result.Uploaded[0] != "commands/mine.md" {
+		t.Errorf("Expected only the unreviewed command pushed, got %v", result.Uploaded)
+	}
+	if result.Proposal == nil || len(result.Proposal.Changes) != 1 || result.Proposal.Changes[0].Path != "commands/team/deploy.md" {
+		t.Fatalf("Expected a proposal for the team command, got %+v", result.Proposal)
+	}
+	if _, ok := author.store.objects["commands/team/deploy.md.age"]; ok {
+		t.Error("Reviewed command reached the shared copy without approval")
+	}
+
+	proposals, err := author.syncer.ListProposals(ctx)
+	if err != nil {
+		t.Fatalf("ListProposals failed: %v", err)
+	}
+	if len(proposals) != 1 || proposals[0].ID != result.Proposal.ID || proposals[0].Device != "laptop" {
+		t.Errorf("Expected the new proposal listed, got %+v", proposals)
+	}
+
+	// An unchanged file waiting for review isn't proposed again
+	result, err = author.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if result.Proposal != nil || len(result.AwaitingReview) != 1 {
+		t.Errorf("Expected the change held back awaiting review, got %+v", result)
+	}
+}
+
+func TestApproveProposal(t *testing.T) {
+	author, reviewer := setupReviewEnvs(t)
+	ctx := context.Background()
+
+	writeFile(t, author.claudeDir, "commands/team/deploy.md", "deploy v1")
+	result, err := author.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	id := result.Proposal.ID
+
+	if _, err := author.syncer.ApproveProposal(ctx, id); !errors.Is(err, ErrSelfApproval) {
+		t.Fatalf("Expected self-approval refused, got %v", err)
+	}
+	if _, err := reviewer.syncer.ApproveProposal(ctx, id); err != nil {
+		t.Fatalf("ApproveProposal failed: %v", err)
+	}
+	for key := range author.store.objects {
+		if strings.HasPrefix(key, ProposalPrefix) {
+			t.Errorf("Proposal object %s left behind after approval", key)
+		}
+	}
+
+	pull, err := reviewer.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(pull.Downloaded) != 1 {
+		t.Errorf("Expected the approved command pulled, got %v", pull.Downloaded)
+	}
+	if got := readFile(t, reviewer.claudeDir, "commands/team/deploy.md"); got != "deploy v1" {
+		t.Errorf("Expected approved content, got %q", got)
+	}
+
+	// The author settles the approval and has nothing left to push or pull
+	result, err = author.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if result.Proposal != nil || len(result.AwaitingReview) != 0 || len(result.Rejected) != 0 {
+		t.Errorf("Expected the approved change settled, got %+v", result)
+	}
+	if len(author.syncer.state.Proposed) != 0 {
+		t.Errorf("Expected no pending reviews, got %v", author.syncer.state.Proposed)
+	}
+	pull, err = author.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(pull.Downloaded) != 0 || len(pull.Conflicts) != 0 {
+		t.Errorf("Expected nothing to pull after approval, got %+v", pull)
+	}
+}
+
+func TestApproveProposedDeletion(t *testing.T) {
+	author, reviewer := setupReviewEnvs(t)
+	ctx := context.Background()
+
+	// Seed the shared copy before review is switched on
+	author.syncer.cfg.ReviewNamespaces = nil
+	writeFile(t, author.claudeDir, "commands/team/old.md", "old")
+	if _, err := author.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	author.syncer.cfg.ReviewNamespaces = []string{"team"}
+
+	if err := os.Remove(filepath.Join(author.claudeDir, "commands", "team", "old.md")); err != nil {
+		t.Fatal(err)
+	}
+	result, err := author.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if len(result.Deleted) != 0 || result.Proposal == nil || result.Proposal.Changes[0].Action != "delete" {
+		t.Fatalf("Expected the deletion proposed, got %+v", result)
+	}
+	if _, ok := author.store.objects["commands/team/old.md.age"]; !ok {
+		t.Fatal("Deletion reached the shared copy without approval")
+	}
+
+	if _, err := reviewer.syncer.ApproveProposal(ctx, result.Proposal.ID); err != nil {
+		t.Fatalf("ApproveProposal failed: %v", err)
+	}
+	if _, ok := author.store.objects["commands/team/old.md.age"]; ok {
+		t.Error("Expected the approved deletion applied")
+	}
+
+	result, err = author.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if result.Proposal != nil || len(result.Rejected) != 0 || author.syncer.state.GetFile("commands/team/old.md") != nil {
+		t.Errorf("Expected the approved deletion settled, got %+v", result)
+	}
+}
+
+func TestRejectProposal(t *testing.T) {
+	author, reviewer := setupReviewEnvs(t)
+	ctx := context.Background()
+
+	writeFile(t, author.claudeDir, "commands/team/deploy.md", "deploy v1")
+	result, err := author.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if _, err := reviewer.syncer.RejectProposal(ctx, result.Proposal.ID); err != nil {
+		t.Fatalf("RejectProposal failed: %v", err)
+	}
+	if _, err := reviewer.syncer.ApproveProposal(ctx, result.Proposal.ID); err == nil {
+		t.Error("Expected a rejected proposal to be gone")
+	}
+
+	result, err = author.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if len(result.Rejected) != 1 || result.Rejected[0] != "commands/team/deploy.md" || result.Proposal != nil {
+		t.Errorf("Expected the rejection reported, got %+v", result)
+	}
+
+	// The next push proposes the change again
+	result, err = author.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if result.Proposal == nil {
+		t.Error("Expected the rejected change proposed again")
+	}
+}
+
+func TestNewProposalWithdrawsSupersededOne(t *testing.T) {
+	author, _ := setupReviewEnvs(t)
+	ctx := context.Background()
+
+	writeFile(t, author.claudeDir, "commands/team/deploy.md", "deploy v1")
+	first, err := author.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	writeFile(t, author.claudeDir, "commands/team/deploy.md", "deploy v2")
+	second, err := author.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if second.Proposal == nil || second.Proposal.ID == first.Proposal.ID {
+		t.Fatalf("Expected a new proposal for the edit, got %+v", second.Proposal)
+	}
+
+	proposals, err := author.syncer.ListProposals(ctx)
+	if err != nil {
+		t.Fatalf("ListProposals failed: %v", err)
+	}
+	if len(proposals) != 1 || proposals[0].ID != second.Proposal.ID {
+		t.Errorf("Expected only the newer proposal left, got %+v", proposals)
+	}
+}
@@ -0,0 +1,209 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// RollbackItem is one remote file a rollback would change.
+type RollbackItem struct {
+	Path    string
+	Action  string  // "revert" or "delete"
+	Version Version // Content to put back, for reverts
+}
+
+// RollbackPlan lists what undoing this device's last pushes would change on
+// the remote.
+type RollbackPlan struct {
+	Pushes []SnapshotInfo // The pushes being undone, oldest first
+	Before SnapshotInfo   // The snapshot the remote goes back to
+
+	Items   []RollbackItem
+	Skipped []string // Changed by another device since; left alone
+	Missing []string // No stored version to go back to
+
+	before   *Snapshot
+	manifest *FileManifest
+}
+
+// PlanRollback plans undoing the last n pushes from this device. Each push's
+// changes come from the snapshot it recorded and the one before it; files are
+// put back from stored versions, so modified and deleted files can only be
+// reverted when versioning was on. Files another device has pushed since are
+// skipped rather than overwritten.
+func (s *Syncer) PlanRollback(ctx context.Context, n int) (*RollbackPlan, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("number of pushes must be at least 1")
+	}
+	snaps, err := s.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	device := safeDeviceName(s.state.DeviceID)
+	var own []int
+	for i := len(snaps) - 1; i >= 0 && len(own) < n; i-- {
+		if snaps[i].DeviceID == device {
+			own = append(own, i)
+		}
+	}
+	if len(own) < n {
+		return nil, fmt.Errorf("only %d recorded pushes from this device; can't roll back %d", len(own), n)
+	}
+	first := own[len(own)-1]
+	if first == 0 {
+		return nil, fmt.Errorf("no snapshot from before push %s; can't tell what it changed", snaps[first].ID)
+	}
+
+	plan := &RollbackPlan{Before: snaps[first-1]}
+	for i := len(own) - 1; i >= 0; i-- {
+		plan.Pushes = append(plan.Pushes, snaps[own[i]])
+	}
+
+	// Paths any of the pushes touched, and what the last of them left
+	changed := make(map[string]bool)
+	var after *Snapshot
+	for _, i := range own {
+		to, err := s.downloadSnapshot(ctx, snaps[i])
+		if err != nil {
+			return nil, err
+		}
+		from, err := s.downloadSnapshot(ctx, snaps[i-1])
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range DiffSnapshots(from, to) {
+			changed[c.Path] = true
+		}
+		if after == nil {
+			after = to
+		}
+	}
+	if plan.before, err = s.downloadSnapshot(ctx, plan.Before); err != nil {
+		return nil, err
+	}
+	if plan.manifest, err = s.downloadManifest(ctx); err != nil {
+		return nil, err
+	}
+	if plan.manifest == nil {
+		plan.manifest = &FileManifest{Files: make(map[string]FileMetadata)}
+	}
+
+	versionObjects, err := s.storage.List(ctx, VersionPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+
+	paths := make([]string, 0, len(changed))
+	for path := range changed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if s.isExcluded(path) {
+			continue
+		}
+		current, inCurrent := plan.manifest.Files[path]
+		pushed, inPushed := after.Files[path]
+		if inCurrent != inPushed || (inCurrent && metadataChanged(current, pushed)) {
+			plan.Skipped = append(plan.Skipped, path)
+			continue
+		}
+
+		if _, existed := plan.before.Files[path]; !existed {
+			if inCurrent {
+				plan.Items = append(plan.Items, RollbackItem{Path: path, Action: "delete"})
+			}
+			continue
+		}
+		v, found := versionAt(versionsIn(versionObjects, s.versionDir(path), path), plan.before.CreatedAt)
+		if !found {
+			plan.Missing = append(plan.Missing, path)
+			continue
+		}
+		plan.Items = append(plan.Items, RollbackItem{Path: path, Action: "revert", Version: v})
+	}
+	return plan, nil
+}
+
+// Rollback applies a plan to the remote and returns the paths it changed.
+// Reverted files get a fresh upload, so every device (this one included)
+// pulls the old content on its next pull; files the pushes added are deleted,
+// through the trash when it is enabled. The manifest and a new snapshot
+// record the result, so a rollback can itself be rolled back.
+func (s *Syncer) Rollback(ctx context.Context, plan *RollbackPlan) ([]string, error) {
+	manifest := FileManifest{Files: make(map[string]FileMetadata, len(plan.manifest.Files))}
+	for path, meta := range plan.manifest.Files {
+		manifest.Files[path] = meta
+	}
+
+	var named []string
+	for _, item := range plan.Items {
+		if item.Action != "delete" {
+			named = append(named, item.Path)
+		}
+	}
+	if err := s.recordKeyNames(ctx, named); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var done, deleteKeys []string
+	for _, item := range plan.Items {
+		if item.Action == "delete" {
+			deleteKeys = append(deleteKeys, s.remoteKey(item.Path))
+			continue
+		}
+		key := s.remoteKey(item.Path)
+		hash, err := copyObjectHash(ctx, s.storage, item.Version.Key, key)
+		if err != nil {
+			return done, fmt.Errorf("failed to revert %s: %w", item.Path, err)
+		}
+		if s.cfg.Versioning {
+			if err := copyObject(ctx, s.storage, item.Version.Key, s.versionKey(item.Path, now)); err != nil {
+				return done, fmt.Errorf("failed to save version of %s: %w", item.Path, err)
+			}
+		}
+		meta := plan.before.Files[item.Path]
+		meta.ObjectHash = hash
+		manifest.Files[item.Path] = meta
+		done = append(done, item.Path)
+	}
+
+	var deleted []string
+	if len(deleteKeys) > 0 {
+		gone, err := s.deleteRemote(ctx, deleteKeys)
+		for _, item := range plan.Items {
+			if item.Action == "delete" && gone[s.remoteKey(item.Path)] {
+				delete(manifest.Files, item.Path)
+				deleted = append(deleted, item.Path)
+				done = append(done, item.Path)
+			}
+		}
+		if err != nil {
+			return done, fmt.Errorf("failed to delete files added by the rolled-back pushes: %w", err)
+		}
+	}
+	// Reverted files are left for the next listing: they must look newer
+	// than this device's upload so pull fetches them
+	s.updateRemoteCache(nil, deleted)
+
+	if err := s.putManifest(ctx, &manifest); err != nil {
+		return done, err
+	}
+	snap := Snapshot{
+		ID:        newSnapshotID(now, s.state.DeviceID),
+		CreatedAt: now.UTC(),
+		DeviceID:  s.state.DeviceID,
+		Files:     manifest.Files,
+	}
+	if err := s.uploadJSON(ctx, SnapshotPrefix+snap.ID+".json.age", snap); err != nil {
+		s.log("Warning: failed to record snapshot: %v", err)
+	}
+	s.attestAfterPush(ctx)
+	sort.Strings(done)
+	return done, nil
+}
@@ -0,0 +1,136 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// pushRollbackFixture pushes a good state and then a bad one a second later,
+// since snapshot IDs have second precision.
+func pushRollbackFixture(t *testing.T, env *testEnv) {
+	t.Helper()
+	ctx := context.Background()
+	writeFile(t, env.claudeDir, "settings.json", `{"v":1}`)
+	writeFile(t, env.claudeDir, "CLAUDE.md", "keep me")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+
+	writeFile(t, env.claudeDir, "settings.json", `{"v":`)
+	writeFile(t, env.claudeDir, "agents/new.md", "stray")
+	if err := os.Remove(filepath.Join(env.claudeDir, "CLAUDE.md")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+}
+
+func TestRollback(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Versioning = true
+	ctx := context.Background()
+	pushRollbackFixture(t, env)
+
+	if _, err := env.syncer.PlanRollback(ctx, 3); err == nil {
+		t.Error("Expected an error rolling back more pushes than were made")
+	}
+
+	plan, err := env.syncer.PlanRollback(ctx, 1)
+	if err != nil {
+		t.Fatalf("PlanRollback failed: %v", err)
+	}
+	actions := make(map[string]string)
+	for _, item := range plan.Items {
+		actions[item.Path] = item.Action
+	}
+	want := map[string]string{"settings.json": "revert", "CLAUDE.md": "revert", "agents/new.md": "delete"}
+	if len(actions) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, actions)
+	}
+	for path, action := range want {
+		if actions[path] != action {
+			t.Errorf("%s: expected %s, got %q", path, action, actions[path])
+		}
+	}
+
+	done, err := env.syncer.Rollback(ctx, plan)
+	if err != nil || len(done) != 3 {
+		t.Fatalf("Rollback failed: %v (%v)", err, done)
+	}
+	if _, ok := env.store.objects[env.syncer.remoteKey("agents/new.md")]; ok {
+		t.Error("Expected the file the bad push added to be deleted")
+	}
+	manifest, err := env.syncer.downloadManifest(ctx)
+	if err != nil || manifest == nil {
+		t.Fatalf("downloadManifest failed: %v", err)
+	}
+	if _, ok := manifest.Files["CLAUDE.md"]; !ok {
+		t.Error("Expected CLAUDE.md back in the manifest")
+	}
+
+	// This device's untouched local copies pick up the old content on pull
+	if _, err := env.syncer.Pull(ctx); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if got := readFile(t, env.claudeDir, "settings.json"); got != `{"v":1}` {
+		t.Errorf("Expected rolled-back settings.json, got %q", got)
+	}
+	if got := readFile(t, env.claudeDir, "CLAUDE.md"); got != "keep me" {
+		t.Errorf("Expected CLAUDE.md restored, got %q", got)
+	}
+}
+
+func TestRollbackSkipsFilesChangedElsewhere(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Versioning = true
+	ctx := context.Background()
+	pushRollbackFixture(t, env)
+
+	// Another device pushed settings.json after the bad push
+	manifest, err := env.syncer.downloadManifest(ctx)
+	if err != nil || manifest == nil {
+		t.Fatalf("downloadManifest failed: %v", err)
+	}
+	meta := manifest.Files["settings.json"]
+	meta.Hash, meta.Device = "sha256:other", "other-device"
+	manifest.Files["settings.json"] = meta
+	if err := env.syncer.uploadJSON(ctx, ManifestKey+".age", manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := env.syncer.PlanRollback(ctx, 1)
+	if err != nil {
+		t.Fatalf("PlanRollback failed: %v", err)
+	}
+	if len(plan.Skipped) != 1 || plan.Skipped[0] != "settings.json" {
+		t.Errorf("Expected settings.json skipped, got %v", plan.Skipped)
+	}
+	for _, item := range plan.Items {
+		if item.Path == "settings.json" {
+			t.Error("Expected settings.json not to be rolled back")
+		}
+	}
+}
+
+func TestRollbackWithoutVersions(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	pushRollbackFixture(t, env)
+
+	plan, err := env.syncer.PlanRollback(ctx, 1)
+	if err != nil {
+		t.Fatalf("PlanRollback failed: %v", err)
+	}
+	if len(plan.Missing) != 2 {
+		t.Errorf("Expected modified and deleted files missing without versioning, got %v", plan.Missing)
+	}
+	if len(plan.Items) != 1 || plan.Items[0].Action != "delete" {
+		t.Errorf("Expected only the added file to be deleted, got %+v", plan.Items)
+	}
+}
@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/claudesettings"
+)
+
+// SettingsIssue reports a pulled Claude settings file that failed validation.
+// A settings file Claude Code can't load breaks every session on the device,
+// so pull never replaces a valid local copy with an invalid remote one.
+type SettingsIssue struct {
+	Path string `json:"path"`
+	Err  error  `json:"-"` // Marshaled as "error", its message
+
+	// SetAside names the file the invalid remote content was written to,
+	// when the local copy was valid and kept. Empty when there was no valid
+	// local copy to keep and the remote content was written as usual.
+	SetAside string `json:"set_aside,omitempty"`
+}
+
+// MarshalJSON writes the issue with Err as its message.
+func (i SettingsIssue) MarshalJSON() ([]byte, error) {
+	type issue SettingsIssue
+	out := struct {
+		issue
+		Error string `json:"error,omitempty"`
+	}{issue: issue(i)}
+	if i.Err != nil {
+		out.Error = i.Err.Error()
+	}
+	return json.Marshal(out)
+}
+
+// isSettingsPath reports whether a synced file is one of Claude Code's
+// top-level settings files.
+func isSettingsPath(relativePath string) bool {
+	return relativePath == "settings.json" || relativePath == "settings.local.json"
+}
+
+// checkSettings validates a pulled settings file before it is written. When
+// the remote content is invalid and the local file is valid, the remote
+// content goes to <path>.invalid.<timestamp> and keep is false; the local
+// file is then recorded against the remote hash as modified, so the next
+// push replaces the bad remote copy.
+func (s *Syncer) checkSettings(relativePath string, data []byte) (keep bool, issue *SettingsIssue, err error) {
+	verr := claudesettings.Validate(data)
+	if verr == nil {
+		return true, nil, nil
+	}
+	issue = &SettingsIssue{Path: relativePath, Err: verr}
+
+	fullPath := filepath.Join(s.claudeDir, relativePath)
+	local, err := os.ReadFile(fullPath)
+	if err != nil || claudesettings.Validate(local) != nil {
+		return true, issue, nil
+	}
+
+	aside := fmt.Sprintf("%s.invalid.%s", relativePath, time.Now().Format("20060102-150405"))
+	if err := s.writeClaudeFile(filepath.FromSlash(aside), data); err != nil {
+		return false, nil, fmt.Errorf("failed to set aside invalid settings: %w", err)
+	}
+	issue.SetAside = aside
+
+	if info, err := os.Stat(fullPath); err == nil {
+		s.state.UpdateFile(relativePath, info, s.state.hashBytes(data))
+		s.state.MarkUploaded(relativePath)
+	}
+	return false, issue, nil
+}
+
+// addSettingsChanges fills in SettingsChanges for the settings files among
+// previews by downloading the remote copy. A file that can't be fetched or
+// parsed is left without changes; the preview still lists it.
+func (s *Syncer) addSettingsChanges(ctx context.Context, previews []FilePreview) {
+	for i := range previews {
+		relPath := previews[i].Path
+		if !isSettingsPath(relPath) {
+			continue
+		}
+		remote, err := s.fetchFile(ctx, relPath, s.remoteKey(relPath), nil)
+		if err != nil {
+			continue
+		}
+		local, err := os.ReadFile(filepath.Join(s.claudeDir, relPath))
+		if err != nil {
+			continue
+		}
+		if changes, err := claudesettings.Diff(local, remote); err == nil {
+			previews[i].SettingsChanges = changes
+		}
+	}
+}
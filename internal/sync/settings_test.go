@@ -0,0 +1,113 @@
+package sync
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPullSetsAsideInvalidSettings(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "settings.json", `{"model":"opus"}`)
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	uploadRemote(t, env, "settings.json", `{"model":"opus"`)
+
+	result, err := env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.InvalidSettings) != 1 {
+		t.Fatalf("Expected one settings issue, got %+v", result.InvalidSettings)
+	}
+	issue := result.InvalidSettings[0]
+	if !strings.HasPrefix(issue.SetAside, "settings.json.invalid.") {
+		t.Fatalf("Expected remote content set aside, got %q", issue.SetAside)
+	}
+	if got := readFile(t, env.claudeDir, "settings.json"); got != `{"model":"opus"}` {
+		t.Errorf("Expected local settings kept, got %q", got)
+	}
+	if got := readFile(t, env.claudeDir, issue.SetAside); got != `{"model":"opus"` {
+		t.Errorf("Expected invalid remote content set aside, got %q", got)
+	}
+
+	// The valid local copy is pushed next, replacing the bad remote one
+	changes, err := env.syncer.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	var pending bool
+	for _, c := range changes {
+		if c.Path == "settings.json" && c.Action == "modify" {
+			pending = true
+		}
+	}
+	if !pending {
+		t.Errorf("Expected settings.json pending push, got %+v", changes)
+	}
+}
+
+func TestPullWritesInvalidSettingsWithoutLocalCopy(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	uploadRemote(t, env, "settings.local.json", `not json`)
+
+	result, err := env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.InvalidSettings) != 1 || result.InvalidSettings[0].SetAside != "" {
+		t.Fatalf("Expected one issue without set-aside, got %+v", result.InvalidSettings)
+	}
+	if got := readFile(t, env.claudeDir, "settings.local.json"); got != "not json" {
+		t.Errorf("Expected file written as pulled, got %q", got)
+	}
+}
+
+func TestPullAcceptsValidSettings(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	uploadRemote(t, env, "settings.json", `{"model":"sonnet"}`)
+
+	result, err := env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.InvalidSettings) != 0 {
+		t.Errorf("Expected no settings issues, got %+v", result.InvalidSettings)
+	}
+	if got := readFile(t, env.claudeDir, "settings.json"); got != `{"model":"sonnet"}` {
+		t.Errorf("Expected settings written, got %q", got)
+	}
+}
+
+func TestPreviewPullShowsSettingsChanges(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "settings.json", `{"model":"opus","permissions":{"allow":["Read"]}}`)
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	uploadRemote(t, env, "settings.json", `{"model":"sonnet","permissions":{"allow":["Read","Bash(ls)"]}}`)
+
+	preview, err := env.syncer.PreviewPull(ctx)
+	if err != nil {
+		t.Fatalf("PreviewPull failed: %v", err)
+	}
+	if len(preview.WouldOverwrite) != 1 {
+		t.Fatalf("Expected settings.json to be overwritten, got %+v", preview)
+	}
+	changes := preview.WouldOverwrite[0].SettingsChanges
+	if len(changes) != 2 || changes[0].Key != "model" || changes[1].Key != "permissions.allow" {
+		t.Errorf("Expected model and permissions.allow changes, got %+v", changes)
+	}
+}
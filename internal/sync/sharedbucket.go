@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// setupMarkers are metadata objects every setup writes under its key prefix,
+// by which the setups in a bucket are told apart.
+var setupMarkers = []string{ManifestKey, KDFParamsKey, RegistryKey, FingerprintKey, CanaryKey}
+
+// BucketSetups returns the key prefixes of the setups found in bucket, a
+// store listing the whole bucket with no key prefix of its own. "" stands
+// for a setup without a prefix.
+func BucketSetups(ctx context.Context, bucket storage.Storage) ([]string, error) {
+	objects, err := bucket.List(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote files: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, obj := range objects {
+		for _, marker := range setupMarkers {
+			if obj.Key == marker {
+				seen[""] = true
+			} else if strings.HasSuffix(obj.Key, "/"+marker) {
+				seen[strings.TrimSuffix(obj.Key, marker)] = true
+			}
+		}
+	}
+	prefixes := make([]string, 0, len(seen))
+	for prefix := range seen {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes, nil
+}
+
+// CheckKeyPrefix makes sure a setup under keyPrefix (normalized) keeps clear
+// of the setups BucketSetups found. Setups sharing a bucket each need their
+// own prefix: one without a prefix, or with a prefix another one's sits
+// under, would list the other's objects as its own, and reset or prune them.
+// Joining a setup with the same prefix is fine.
+func CheckKeyPrefix(setups []string, keyPrefix string) error {
+	for _, other := range setups {
+		switch {
+		case other == keyPrefix:
+		case keyPrefix == "":
+			return fmt.Errorf("the bucket is shared with a setup under key_prefix %q; give this one its own prefix with --key-prefix", other)
+		case other == "":
+			return fmt.Errorf("the bucket holds a setup without a key_prefix, which would see this one's files as its own; a shared bucket needs a prefix for every setup")
+		case strings.HasPrefix(other, keyPrefix) || strings.HasPrefix(keyPrefix, other):
+			return fmt.Errorf("key_prefix %q overlaps the setup under %q in the bucket; pick a prefix neither is inside", keyPrefix, other)
+		}
+	}
+	return nil
+}
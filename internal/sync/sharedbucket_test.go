@@ -0,0 +1,65 @@
+package sync
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCheckKeyPrefix(t *testing.T) {
+	ctx := context.Background()
+
+	bucket := newMockStorage()
+	setups, err := BucketSetups(ctx, bucket)
+	if err != nil || len(setups) != 0 {
+		t.Fatalf("BucketSetups on an empty bucket = %v, %v", setups, err)
+	}
+	if err := CheckKeyPrefix(setups, ""); err != nil {
+		t.Errorf("Empty bucket: %v", err)
+	}
+	for _, key := range []string{
+		"laptops/tew/" + ManifestKey,
+		"laptops/tew/CLAUDE.md.age",
+		"work/" + KDFParamsKey,
+	} {
+		if err := bucket.Upload(ctx, key, []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	setups, err = BucketSetups(ctx, bucket)
+	if err != nil {
+		t.Fatalf("BucketSetups failed: %v", err)
+	}
+	if want := []string{"laptops/tew/", "work/"}; !reflect.DeepEqual(setups, want) {
+		t.Errorf("BucketSetups = %v, want %v", setups, want)
+	}
+
+	for _, tt := range []struct {
+		prefix string
+		ok     bool
+	}{
+		{"", false},            // Would see both as its own
+		{"laptops/tew/", true}, // Joins that setup
+		{"laptops/ana/", true}, // A sibling
+		{"laptops/", false},    // laptops/tew/ is inside it
+		{"work/old/", false},   // Inside work/
+		{"workshop/", true},    // Not inside work/
+	} {
+		err := CheckKeyPrefix(setups, tt.prefix)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckKeyPrefix(%q) = %v, want ok %v", tt.prefix, err, tt.ok)
+		}
+	}
+
+	// A setup at the root of the bucket keeps every other setup out
+	if err := bucket.Upload(ctx, ManifestKey, []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if setups, err = BucketSetups(ctx, bucket); err != nil {
+		t.Fatalf("BucketSetups failed: %v", err)
+	}
+	if err := CheckKeyPrefix(setups, "laptops/ana/"); err == nil {
+		t.Error("Expected an error next to a setup without a prefix")
+	}
+}
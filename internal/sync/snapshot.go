@@ -0,0 +1,245 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SnapshotPrefix is the remote prefix holding point-in-time copies of the
+// manifest. Every push that changes something records one, so the remote
+// state at any past push can be reconstructed for diffs.
+const SnapshotPrefix = "_metadata/snapshots/"
+
+// snapshotIDLayout is the timestamp part of a snapshot ID. It sorts
+// lexically in chronological order.
+const snapshotIDLayout = "20060102T150405Z"
+
+// CurrentSnapshotRef names the live remote manifest in snapshot references.
+const CurrentSnapshotRef = "current"
+
+// Snapshot is the remote file set as it was right after a push.
+type Snapshot struct {
+	ID        string                  `json:"id"`
+	CreatedAt time.Time               `json:"created_at"`
+	DeviceID  string                  `json:"device_id"`
+	Files     map[string]FileMetadata `json:"files"`
+}
+
+// SnapshotInfo identifies a snapshot without downloading it.
+type SnapshotInfo struct {
+	ID        string
+	CreatedAt time.Time
+	DeviceID  string
+	Key       string
+}
+
+// SnapshotChange describes how one file differs between two snapshots.
+type SnapshotChange struct {
+	Path     string    `json:"path"`
+	Status   string    `json:"status"` // "added", "removed", "modified"
+	FromSize int64     `json:"from_size"`
+	ToSize   int64     `json:"to_size"`
+	FromTime time.Time `json:"from_time,omitzero"`
+	ToTime   time.Time `json:"to_time,omitzero"`
+}
+
+var unsafeIDChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// newSnapshotID builds a sortable ID from the push time and device.
+func newSnapshotID(t time.Time, deviceID string) string {
+	return t.UTC().Format(snapshotIDLayout) + "-" + safeDeviceName(deviceID)
+}
+
+// safeDeviceName is a device ID as it appears in snapshot and version names.
+func safeDeviceName(deviceID string) string {
+	device := strings.Trim(unsafeIDChars.ReplaceAllString(deviceID, "-"), "-")
+	if device == "" {
+		device = "unknown"
+	}
+	return device
+}
+
+// parseSnapshotKey extracts snapshot info from a remote key, reporting false
+// for keys that aren't snapshots.
+func parseSnapshotKey(key string) (SnapshotInfo, bool) {
+	if !strings.HasPrefix(key, SnapshotPrefix) || !strings.HasSuffix(key, ".json.age") {
+		return SnapshotInfo{}, false
+	}
+	id := strings.TrimSuffix(strings.TrimPrefix(key, SnapshotPrefix), ".json.age")
+	if len(id) < len(snapshotIDLayout) || strings.Contains(id, "/") {
+		return SnapshotInfo{}, false
+	}
+	created, err := time.Parse(snapshotIDLayout, id[:len(snapshotIDLayout)])
+	if err != nil {
+		return SnapshotInfo{}, false
+	}
+	device := strings.TrimPrefix(id[len(snapshotIDLayout):], "-")
+	return SnapshotInfo{ID: id, CreatedAt: created, DeviceID: device, Key: key}, true
+}
+
+// recordSnapshot stores the current manifest as a new snapshot.
+func (s *Syncer) recordSnapshot(ctx context.Context) error {
+	now := time.Now()
+	snap := Snapshot{
+		ID:        newSnapshotID(now, s.state.DeviceID),
+		CreatedAt: now.UTC(),
+		DeviceID:  s.state.DeviceID,
+		Files:     s.buildManifest().Files,
+	}
+	return s.uploadJSON(ctx, SnapshotPrefix+snap.ID+".json.age", snap)
+}
+
+// ListSnapshots returns all recorded snapshots, oldest first.
+func (s *Syncer) ListSnapshots(ctx context.Context) ([]SnapshotInfo, error) {
+	objects, err := s.storage.List(ctx, SnapshotPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var snaps []SnapshotInfo
+	for _, obj := range objects {
+		if info, ok := parseSnapshotKey(obj.Key); ok {
+			snaps = append(snaps, info)
+		}
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].ID < snaps[j].ID })
+	return snaps, nil
+}
+
+// LoadSnapshot resolves ref and downloads the snapshot it names. ref may be
+// "current" (or empty) for the live manifest, a snapshot ID or unique ID
+// prefix, a date or timestamp (the latest snapshot at or before it), or a
+// relative age such as "7d" or "36h".
+func (s *Syncer) LoadSnapshot(ctx context.Context, ref string) (*Snapshot, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || ref == CurrentSnapshotRef {
+		manifest, err := s.downloadManifest(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if manifest == nil {
+			return nil, fmt.Errorf("no remote manifest found; push first")
+		}
+		return &Snapshot{ID: CurrentSnapshotRef, CreatedAt: time.Now().UTC(), Files: manifest.Files}, nil
+	}
+
+	snaps, err := s.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info, err := resolveSnapshotRef(snaps, ref, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var snap Snapshot
+	if err := s.downloadJSON(ctx, info.Key, &snap); err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", info.ID, err)
+	}
+	if snap.Files == nil {
+		snap.Files = make(map[string]FileMetadata)
+	}
+	return &snap, nil
+}
+
+// resolveSnapshotRef picks the snapshot a reference names from a sorted list.
+func resolveSnapshotRef(snaps []SnapshotInfo, ref string, now time.Time) (SnapshotInfo, error) {
+	if len(snaps) == 0 {
+		return SnapshotInfo{}, fmt.Errorf("no snapshots recorded yet; snapshots are created on push")
+	}
+
+	// Exact ID or unique prefix
+	var matches []SnapshotInfo
+	for _, snap := range snaps {
+		if snap.ID == ref {
+			return snap, nil
+		}
+		if strings.HasPrefix(snap.ID, ref) {
+			matches = append(matches, snap)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+
+	at, ok := parseTimeRef(ref, now)
+	if !ok {
+		if len(matches) > 1 {
+			return SnapshotInfo{}, fmt.Errorf("snapshot %q is ambiguous (%d matches)", ref, len(matches))
+		}
+		return SnapshotInfo{}, fmt.Errorf("unknown snapshot %q (use an ID, a date like 2024-05-01, or an age like 7d)", ref)
+	}
+
+	// Latest snapshot taken at or before the requested time
+	for i := len(snaps) - 1; i >= 0; i-- {
+		if !snaps[i].CreatedAt.After(at) {
+			return snaps[i], nil
+		}
+	}
+	return SnapshotInfo{}, fmt.Errorf("no snapshot exists at or before %s (oldest is %s)",
+		at.Format(time.RFC3339), snaps[0].CreatedAt.Format(time.RFC3339))
+}
+
+// parseTimeRef parses dates, timestamps, and relative ages ("90m", "36h", "7d").
+func parseTimeRef(ref string, now time.Time) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, ref, time.Local); err == nil {
+			return t, true
+		}
+	}
+
+	if strings.HasSuffix(ref, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(ref, "d")); err == nil && days >= 0 {
+			return now.AddDate(0, 0, -days), true
+		}
+	}
+	if d, err := time.ParseDuration(ref); err == nil && d >= 0 {
+		return now.Add(-d), true
+	}
+	return time.Time{}, false
+}
+
+// DiffSnapshots lists files added, removed, or modified between two snapshots,
+// sorted by path. Files are compared by content hash when both sides recorded
+// one, and by size and mtime otherwise (manifests written by older versions).
+func DiffSnapshots(from, to *Snapshot) []SnapshotChange {
+	var changes []SnapshotChange
+
+	for path, toMeta := range to.Files {
+		fromMeta, existed := from.Files[path]
+		switch {
+		case !existed:
+			changes = append(changes, SnapshotChange{Path: path, Status: "added", ToSize: toMeta.Size, ToTime: toMeta.ModTime})
+		case metadataChanged(fromMeta, toMeta):
+			changes = append(changes, SnapshotChange{
+				Path:     path,
+				Status:   "modified",
+				FromSize: fromMeta.Size,
+				ToSize:   toMeta.Size,
+				FromTime: fromMeta.ModTime,
+				ToTime:   toMeta.ModTime,
+			})
+		}
+	}
+
+	for path, fromMeta := range from.Files {
+		if _, exists := to.Files[path]; !exists {
+			changes = append(changes, SnapshotChange{Path: path, Status: "removed", FromSize: fromMeta.Size, FromTime: fromMeta.ModTime})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func metadataChanged(a, b FileMetadata) bool {
+	if a.Hash != "" && b.Hash != "" && hashAlgorithmOf(a.Hash) == hashAlgorithmOf(b.Hash) {
+		return a.Hash != b.Hash
+	}
+	return a.Size != b.Size || !a.ModTime.Equal(b.ModTime)
+}
@@ -0,0 +1,167 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPushRecordsSnapshot(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# V1")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	snaps, err := env.syncer.ListSnapshots(ctx)
+	if err != nil {
+		t.Fatalf("ListSnapshots failed: %v", err)
+	}
+	if len(snaps) != 1 {
+		t.Fatalf("Expected 1 snapshot, got %d", len(snaps))
+	}
+
+	snap, err := env.syncer.LoadSnapshot(ctx, snaps[0].ID)
+	if err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	meta, ok := snap.Files["CLAUDE.md"]
+	if !ok {
+		t.Fatalf("Snapshot missing CLAUDE.md: %v", snap.Files)
+	}
+	if meta.Hash == "" || meta.Size != 4 {
+		t.Errorf("Expected hash and size in snapshot, got %+v", meta)
+	}
+
+	// A push with nothing to do records no new snapshot
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	snaps, _ = env.syncer.ListSnapshots(ctx)
+	if len(snaps) != 1 {
+		t.Errorf("Expected no snapshot for a no-op push, got %d", len(snaps))
+	}
+}
+
+func TestLoadSnapshotCurrent(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	if _, err := env.syncer.LoadSnapshot(ctx, CurrentSnapshotRef); err == nil {
+		t.Error("Expected an error before anything was pushed")
+	}
+
+	writeFile(t, env.claudeDir, "agents/a.md", "a")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	snap, err := env.syncer.LoadSnapshot(ctx, "")
+	if err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if _, ok := snap.Files["agents/a.md"]; !ok {
+		t.Errorf("Current snapshot missing agents/a.md: %v", snap.Files)
+	}
+}
+
+func TestResolveSnapshotRef(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	snaps := []SnapshotInfo{
+		{ID: newSnapshotID(base, "laptop"), CreatedAt: base},
+		{ID: newSnapshotID(base.Add(48*time.Hour), "desktop"), CreatedAt: base.Add(48 * time.Hour)},
+		{ID: newSnapshotID(base.Add(96*time.Hour), "laptop"), CreatedAt: base.Add(96 * time.Hour)},
+	}
+	now := base.Add(100 * time.Hour)
+
+	tests := []struct {
+		ref     string
+		want    int
+		wantErr bool
+	}{
+		{ref: snaps[1].ID, want: 1},
+		{ref: "20240503", want: 1},             // unique prefix
+		{ref: "2024-05-04", want: 1},           // latest at or before midnight May 4
+		{ref: "2024-05-05T13:00:00Z", want: 2}, // timestamp
+		{ref: "3d", want: 0},                   // 72h before "now"
+		{ref: "2024-04-01", wantErr: true},     // before the first snapshot
+		{ref: "not-a-snapshot", wantErr: true}, // unparseable
+		{ref: "202405", wantErr: true},         // ambiguous prefix
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := resolveSnapshotRef(snaps, tt.ref, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %s", got.ID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got.ID != snaps[tt.want].ID {
+				t.Errorf("Got %s, want %s", got.ID, snaps[tt.want].ID)
+			}
+		})
+	}
+}
+
+func TestParseSnapshotKey(t *testing.T) {
+	info, ok := parseSnapshotKey(SnapshotPrefix + "20240501T120000Z-my-laptop.json.age")
+	if !ok {
+		t.Fatal("Expected snapshot key to parse")
+	}
+	if info.DeviceID != "my-laptop" || !info.CreatedAt.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+
+	for _, key := range []string{ManifestKey + ".age", SnapshotPrefix + "garbage.json.age", "CLAUDE.md.age"} {
+		if _, ok := parseSnapshotKey(key); ok {
+			t.Errorf("Expected %s not to parse as a snapshot", key)
+		}
+	}
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	t1 := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	from := &Snapshot{Files: map[string]FileMetadata{
+		"CLAUDE.md":     {Hash: "a", Size: 1, ModTime: t1},
+		"agents/old.md": {Hash: "b", Size: 2, ModTime: t1},
+		"same.md":       {Hash: "c", Size: 3, ModTime: t1},
+		"legacy.md":     {Size: 4, ModTime: t1},
+	}}
+	to := &Snapshot{Files: map[string]FileMetadata{
+		"CLAUDE.md":     {Hash: "a2", Size: 5, ModTime: t2},
+		"agents/new.md": {Hash: "d", Size: 6, ModTime: t2},
+		"same.md":       {Hash: "c", Size: 3, ModTime: t2}, // touched, same content
+		"legacy.md":     {Size: 4, ModTime: t2},
+	}}
+
+	changes := DiffSnapshots(from, to)
+	got := make(map[string]string)
+	for _, c := range changes {
+		got[c.Path] = c.Status
+	}
+	want := map[string]string{
+		"CLAUDE.md":     "modified",
+		"agents/new.md": "added",
+		"agents/old.md": "removed",
+		"legacy.md":     "modified",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Got changes %v, want %v", got, want)
+	}
+	for path, status := range want {
+		if got[path] != status {
+			t.Errorf("%s: got %q, want %q", path, got[path], status)
+		}
+	}
+	if changes[0].Path != "CLAUDE.md" {
+		t.Errorf("Expected changes sorted by path, got %v", changes)
+	}
+}
@@ -0,0 +1,29 @@
+package sync
+
+import "time"
+
+// StaleWarning says local changes have gone unpushed for longer than the
+// configured stale_after, so devices are drifting apart.
+type StaleWarning struct {
+	LastPush time.Time // When this device last pushed (or synced)
+	Pending  int       // Local changes waiting to be pushed
+}
+
+// CheckStale returns a warning when there are pending local changes and this
+// device hasn't pushed within stale_after. It uses local state only, so it is
+// cheap enough to run on every status.
+func (s *Syncer) CheckStale(changes []FileChange, now time.Time) (*StaleWarning, error) {
+	threshold, err := s.cfg.StaleAfterDuration()
+	if err != nil || threshold <= 0 || len(changes) == 0 {
+		return nil, err
+	}
+
+	last := s.state.LastPush
+	if last.IsZero() {
+		last = s.state.LastSync
+	}
+	if last.IsZero() || now.Sub(last) < threshold {
+		return nil, nil // Never synced: the first push is its own prompt
+	}
+	return &StaleWarning{LastPush: last, Pending: len(changes)}, nil
+}
@@ -0,0 +1,46 @@
+package sync
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckStale(t *testing.T) {
+	env := setupTestEnv(t)
+	now := time.Now()
+	changes := []FileChange{{Path: "CLAUDE.md", Action: "modify"}}
+
+	// Never synced
+	if w, err := env.syncer.CheckStale(changes, now); err != nil || w != nil {
+		t.Errorf("Expected no warning before the first sync, got %+v (%v)", w, err)
+	}
+
+	env.syncer.state.LastPush = now.Add(-20 * 24 * time.Hour)
+	w, err := env.syncer.CheckStale(changes, now)
+	if err != nil || w == nil {
+		t.Fatalf("Expected a warning after 20 days, got %+v (%v)", w, err)
+	}
+	if w.Pending != 1 || !w.LastPush.Equal(env.syncer.state.LastPush) {
+		t.Errorf("Unexpected warning %+v", w)
+	}
+
+	if w, _ := env.syncer.CheckStale(nil, now); w != nil {
+		t.Error("Expected no warning without pending changes")
+	}
+
+	env.syncer.cfg.StaleAfter = "30d"
+	if w, _ := env.syncer.CheckStale(changes, now); w != nil {
+		t.Error("Expected no warning within stale_after")
+	}
+
+	env.syncer.cfg.StaleAfter = "0"
+	env.syncer.state.LastPush = now.Add(-365 * 24 * time.Hour)
+	if w, _ := env.syncer.CheckStale(changes, now); w != nil {
+		t.Error("Expected stale_after 0 to turn the warning off")
+	}
+
+	env.syncer.cfg.StaleAfter = "soon"
+	if _, err := env.syncer.CheckStale(changes, now); err == nil {
+		t.Error("Expected an error for an invalid stale_after")
+	}
+}
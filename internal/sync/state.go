@@ -1,11 +1,8 @@
 package sync
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"sync"
@@ -20,6 +17,20 @@ type FileState struct {
 	Size     int64     `json:"size"`
 	ModTime  time.Time `json:"mod_time"`
 	Uploaded time.Time `json:"uploaded,omitempty"`
+
+	// Origin is the device that pushed this content and PushedAt is when.
+	Origin   string    `json:"origin,omitempty"`
+	PushedAt time.Time `json:"pushed_at,omitempty"`
+
+	// ObjectHash is the SHA-256 of the remote object last pushed or pulled
+	// for this content, for the signed manifest.
+	ObjectHash string `json:"object_hash,omitempty"`
+}
+
+// ConflictOrigin records where the remote side of a conflict came from.
+type ConflictOrigin struct {
+	Device   string    `json:"device,omitempty"`
+	PushedAt time.Time `json:"pushed_at,omitempty"`
 }
 
 type SyncState struct {
@@ -32,9 +43,36 @@ type SyncState struct {
 	// MCPBaseline stores the last-synced normalized MCP server configs for three-way merge.
 	MCPBaseline json.RawMessage `json:"mcp_baseline,omitempty"`
 
+	// Conflicts maps saved .conflict files to the origin of their remote content.
+	Conflicts map[string]ConflictOrigin `json:"conflicts,omitempty"`
+
+	// HashAlgorithm is the algorithm file hashes are made with; empty means
+	// SHA-256. MigrateHashes changes it.
+	HashAlgorithm string `json:"hash_algorithm,omitempty"`
+
+	// Paused holds paths held back from sync by 'claude-sync pause'.
+	Paused map[string]Pause `json:"paused,omitempty"`
+
+	// Proposed holds local changes to reviewed command sets that this device
+	// proposed and that are waiting for another device's approval.
+	Proposed map[string]PendingReview `json:"proposed,omitempty"`
+
+	// Home and ClaudeDir are the directories this state was built for; see
+	// DetectMove.
+	Home      string `json:"home,omitempty"`
+	ClaudeDir string `json:"claude_dir,omitempty"`
+
+	// KeyFingerprint is this device's key fingerprint once it was found to
+	// match the bucket's; see checkFingerprint.
+	KeyFingerprint string `json:"key_fingerprint,omitempty"`
+
 	// savePath is the custom path to save state to (if set)
 	savePath string     `json:"-"`
 	mu       sync.Mutex `json:"-"`
+
+	// syncWrites flushes the state file to disk before it replaces the old
+	// one, for state kept on a network filesystem
+	syncWrites bool
 }
 
 func LoadState() (*SyncState, error) {
@@ -90,11 +128,16 @@ func NewState() *SyncState {
 	}
 }
 
-func (s *SyncState) Save() error {
-	statePath := s.savePath
-	if statePath == "" {
-		statePath = config.StateFilePath()
+// path returns where the state file is saved.
+func (s *SyncState) path() string {
+	if s.savePath != "" {
+		return s.savePath
 	}
+	return config.StateFilePath()
+}
+
+func (s *SyncState) Save() error {
+	statePath := s.path()
 
 	// Ensure directory exists
 	dir := filepath.Dir(statePath)
@@ -121,6 +164,14 @@ func (s *SyncState) Save() error {
 		_ = tmp.Close()
 		return fmt.Errorf("failed to write state: %w", err)
 	}
+	// On a network filesystem, make sure the server has the new state before
+	// it replaces the old one
+	if s.syncWrites {
+		if err := tmp.Sync(); err != nil {
+			_ = tmp.Close()
+			return fmt.Errorf("failed to flush state: %w", err)
+		}
+	}
 	if err := tmp.Chmod(0600); err != nil {
 		_ = tmp.Close()
 		return fmt.Errorf("failed to set state permissions: %w", err)
@@ -154,6 +205,50 @@ func (s *SyncState) MarkUploaded(relativePath string) {
 	}
 }
 
+// SetObjectHash records the hash of a tracked file's remote object.
+func (s *SyncState) SetObjectHash(relativePath, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.Files[relativePath]; ok {
+		f.ObjectHash = hash
+	}
+}
+
+// SetOrigin records which device pushed the content of a tracked file, and when.
+func (s *SyncState) SetOrigin(relativePath, device string, pushedAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.Files[relativePath]; ok {
+		f.Origin = device
+		f.PushedAt = pushedAt
+	}
+}
+
+// RecordConflict remembers the origin of a saved .conflict file.
+func (s *SyncState) RecordConflict(conflictPath string, origin ConflictOrigin) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Conflicts == nil {
+		s.Conflicts = make(map[string]ConflictOrigin)
+	}
+	s.Conflicts[conflictPath] = origin
+}
+
+// ConflictOrigin returns the recorded origin of a saved .conflict file.
+func (s *SyncState) ConflictOrigin(conflictPath string) (ConflictOrigin, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	origin, ok := s.Conflicts[conflictPath]
+	return origin, ok
+}
+
+// ClearConflict forgets a .conflict file once it has been resolved.
+func (s *SyncState) ClearConflict(conflictPath string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Conflicts, conflictPath)
+}
+
 func (s *SyncState) GetFile(relativePath string) *FileState {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -166,6 +261,24 @@ func (s *SyncState) RemoveFile(relativePath string) {
 	delete(s.Files, relativePath)
 }
 
+// renameFile moves a tracked file's state to a new path.
+func (s *SyncState) renameFile(from, to string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.Files[from]; ok {
+		delete(s.Files, from)
+		f.Path = to
+		s.Files[to] = f
+	}
+}
+
+// setLocation records the home and Claude directory the state is for.
+func (s *SyncState) setLocation(home, claudeDir string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Home, s.ClaudeDir = home, claudeDir
+}
+
 // IsEmpty returns true if no files have been synced yet (first sync)
 func (s *SyncState) IsEmpty() bool {
 	return len(s.Files) == 0 && s.LastSync.IsZero()
@@ -201,19 +314,9 @@ func (s *SyncState) SetMCPBaseline(servers MCPServers) error {
 	return nil
 }
 
+// HashFile returns the SHA-256 hash of a file.
 func HashFile(path string) (string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return "", err
-	}
-	defer func() { _ = f.Close() }()
-
-	h := sha256.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", err
-	}
-
-	return hex.EncodeToString(h.Sum(nil)), nil
+	return hashFileWith(config.HashSHA256, path)
 }
 
 func GetLocalFiles(claudeDir string, syncPaths []string, excludeFn ...func(string) bool) (map[string]os.FileInfo, error) {
@@ -285,11 +388,11 @@ func GetLocalFiles(claudeDir string, syncPaths []string, excludeFn ...func(strin
 }
 
 type FileChange struct {
-	Path      string
-	Action    string // "add", "modify", "delete"
-	LocalHash string
-	LocalSize int64
-	LocalTime time.Time
+	Path      string    `json:"path"`
+	Action    string    `json:"action"` // "add", "modify", "delete"
+	LocalHash string    `json:"local_hash,omitempty"`
+	LocalSize int64     `json:"local_size"`
+	LocalTime time.Time `json:"local_time,omitzero"`
 }
 
 func (s *SyncState) DetectChanges(claudeDir string, syncPaths []string, excludeFn ...func(string) bool) ([]FileChange, error) {
@@ -303,7 +406,7 @@ func (s *SyncState) DetectChanges(claudeDir string, syncPaths []string, excludeF
 	// Check for new or modified files
 	for relPath, info := range localFiles {
 		fullPath := filepath.Join(claudeDir, relPath)
-		hash, err := HashFile(fullPath)
+		hash, err := s.hashFile(fullPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to hash %s: %w", relPath, err)
 		}
@@ -0,0 +1,147 @@
+package sync
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// RemoteStats summarizes what the bucket holds, for 'claude-sync stats'.
+type RemoteStats struct {
+	Objects int   `json:"objects"`
+	Size    int64 `json:"size"`
+
+	// Areas breaks the synced files down by top-level sync path (projects,
+	// agents, CLAUDE.md, ...), largest first. Internal is claude-sync's own
+	// data (metadata, versions, trash) and Unknown the keys this device
+	// can't map to a local path.
+	Areas    []AreaStats `json:"areas"`
+	Internal AreaStats   `json:"internal"`
+	Unknown  AreaStats   `json:"unknown"`
+
+	// Largest lists the biggest synced files, largest first.
+	Largest []RemoteEntry `json:"largest"`
+
+	// Devices lists every device seen in the manifest, most recent first.
+	Devices []DeviceActivity `json:"devices"`
+}
+
+// AreaStats counts the objects under one area of the bucket.
+type AreaStats struct {
+	Path    string `json:"path,omitempty"`
+	Objects int    `json:"objects"`
+	Size    int64  `json:"size"`
+}
+
+func (a *AreaStats) add(size int64) {
+	a.Objects++
+	a.Size += size
+}
+
+// DeviceActivity is when a device last pushed and pulled. LastPush comes
+// from the manifest: the newest file pushed by the device that is still
+// current. Pulls leave nothing in the bucket, so LastPull is only known for
+// this device.
+type DeviceActivity struct {
+	Device   string    `json:"device"`
+	Current  bool      `json:"current,omitempty"`
+	LastPush time.Time `json:"last_push,omitzero"`
+	LastPull time.Time `json:"last_pull,omitzero"`
+}
+
+// Stats lists the bucket and totals it, keeping the top largest files.
+func (s *Syncer) Stats(ctx context.Context, top int) (*RemoteStats, error) {
+	entries, err := s.ListRemote(ctx, "", true)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &RemoteStats{}
+	areas := make(map[string]*AreaStats)
+	var files []RemoteEntry
+	for _, e := range entries {
+		stats.Objects++
+		stats.Size += e.Size
+		switch {
+		case e.Path != "":
+			area := leaseArea(e.Path)
+			if areas[area] == nil {
+				areas[area] = &AreaStats{Path: area}
+			}
+			areas[area].add(e.Size)
+			files = append(files, e)
+		case isReservedKey(e.Key):
+			stats.Internal.add(e.Size)
+		default:
+			stats.Unknown.add(e.Size)
+		}
+	}
+
+	stats.Areas = make([]AreaStats, 0, len(areas))
+	for _, a := range areas {
+		stats.Areas = append(stats.Areas, *a)
+	}
+	sort.Slice(stats.Areas, func(i, j int) bool {
+		if stats.Areas[i].Size != stats.Areas[j].Size {
+			return stats.Areas[i].Size > stats.Areas[j].Size
+		}
+		return stats.Areas[i].Path < stats.Areas[j].Path
+	})
+
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Size > files[j].Size })
+	if len(files) > top {
+		files = files[:top]
+	}
+	stats.Largest = files
+
+	manifest, err := s.downloadManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats.Devices = s.deviceActivity(manifest)
+	return stats, nil
+}
+
+// deviceActivity collects the last push of each device in manifest, which
+// may be nil, and this device's own last push and pull from its state.
+func (s *Syncer) deviceActivity(manifest *FileManifest) []DeviceActivity {
+	byDevice := make(map[string]*DeviceActivity)
+	if manifest != nil {
+		for _, meta := range manifest.Files {
+			if meta.Device == "" {
+				continue
+			}
+			d := byDevice[meta.Device]
+			if d == nil {
+				d = &DeviceActivity{Device: meta.Device}
+				byDevice[meta.Device] = d
+			}
+			if meta.PushedAt.After(d.LastPush) {
+				d.LastPush = meta.PushedAt
+			}
+		}
+	}
+
+	self := byDevice[s.state.DeviceID]
+	if self == nil {
+		self = &DeviceActivity{Device: s.state.DeviceID}
+		byDevice[s.state.DeviceID] = self
+	}
+	self.Current = true
+	if s.state.LastPush.After(self.LastPush) {
+		self.LastPush = s.state.LastPush
+	}
+	self.LastPull = s.state.LastPull
+
+	devices := make([]DeviceActivity, 0, len(byDevice))
+	for _, d := range byDevice {
+		devices = append(devices, *d)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if !devices[i].LastPush.Equal(devices[j].LastPush) {
+			return devices[i].LastPush.After(devices[j].LastPush)
+		}
+		return devices[i].Device < devices[j].Device
+	})
+	return devices
+}
@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "agents/a.md", "a")
+	writeFile(t, env.claudeDir, "agents/big.md", string(make([]byte, 4096)))
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# notes")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	env.store.Upload(ctx, "o/0123abcd.age", []byte("opaque"))
+
+	stats, err := env.syncer.Stats(ctx, 2)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+
+	var sum int64
+	for _, a := range stats.Areas {
+		sum += a.Size
+	}
+	if sum+stats.Internal.Size+stats.Unknown.Size != stats.Size {
+		t.Errorf("areas add up to %d, want the total %d", sum+stats.Internal.Size+stats.Unknown.Size, stats.Size)
+	}
+	if len(stats.Areas) != 2 || stats.Areas[0].Path != "agents" || stats.Areas[0].Objects != 2 {
+		t.Errorf("Areas = %+v, want agents (2 files) first, then CLAUDE.md", stats.Areas)
+	}
+	if stats.Internal.Objects == 0 {
+		t.Error("Internal is empty, want the manifest")
+	}
+	if stats.Unknown.Objects != 1 {
+		t.Errorf("Unknown = %+v, want the opaque key", stats.Unknown)
+	}
+	if len(stats.Largest) != 2 || stats.Largest[0].Path != "agents/big.md" {
+		t.Errorf("Largest = %+v, want 2 files starting with agents/big.md", stats.Largest)
+	}
+	if len(stats.Devices) != 1 || !stats.Devices[0].Current || stats.Devices[0].LastPush.IsZero() {
+		t.Errorf("Devices = %+v, want this device with its last push", stats.Devices)
+	}
+}
+
+func TestDeviceActivity(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.state.DeviceID = "laptop"
+	now := time.Now()
+	env.syncer.state.LastPull = now
+
+	manifest := &FileManifest{Files: map[string]FileMetadata{
+		"a.md": {Device: "desktop", PushedAt: now.Add(-time.Hour)},
+		"b.md": {Device: "desktop", PushedAt: now.Add(-2 * time.Hour)},
+		"c.md": {Device: "laptop", PushedAt: now.Add(-3 * time.Hour)},
+		"d.md": {},
+	}}
+	devices := env.syncer.deviceActivity(manifest)
+	if len(devices) != 2 {
+		t.Fatalf("deviceActivity = %+v, want desktop and laptop", devices)
+	}
+	if d := devices[0]; d.Device != "desktop" || !d.LastPush.Equal(now.Add(-time.Hour)) || d.Current || !d.LastPull.IsZero() {
+		t.Errorf("devices[0] = %+v, want desktop's newest push and no pull", d)
+	}
+	if d := devices[1]; d.Device != "laptop" || !d.Current || !d.LastPull.Equal(now) {
+		t.Errorf("devices[1] = %+v, want this device with its last pull", d)
+	}
+}
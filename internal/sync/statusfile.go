@@ -0,0 +1,154 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+)
+
+// SyncStatus is the summary in ~/.claude-sync/status.json, rewritten after
+// every push and pull so shell prompts and status bars can show the sync
+// state without loading keys or contacting storage.
+type SyncStatus struct {
+	Updated   time.Time `json:"updated"`
+	LastSync  time.Time `json:"last_sync"`            // Last push or pull that didn't fail
+	LastOp    string    `json:"last_op,omitempty"`    // "push" or "pull"
+	LastError string    `json:"last_error,omitempty"` // Why the last push or pull failed
+	Pending   int       `json:"pending"`              // Local changes not pushed yet
+	Conflicts int       `json:"conflicts"`            // Unresolved conflict files
+}
+
+// State sums the status up in one word: "error" when the last run failed,
+// then "conflicts", "pending", "ok", or "unknown" before the first run.
+func (st *SyncStatus) State() string {
+	switch {
+	case st.Updated.IsZero():
+		return "unknown"
+	case st.LastError != "":
+		return "error"
+	case st.Conflicts > 0:
+		return "conflicts"
+	case st.Pending > 0:
+		return "pending"
+	}
+	return "ok"
+}
+
+// LoadSyncStatus reads the status file; it is empty until the first sync.
+func LoadSyncStatus() (*SyncStatus, error) {
+	return loadSyncStatusFrom(config.StatusFilePath())
+}
+
+func loadSyncStatusFrom(path string) (*SyncStatus, error) {
+	st := &SyncStatus{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+		return nil, fmt.Errorf("failed to read status file: %w", err)
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to parse status file: %w", err)
+	}
+	return st, nil
+}
+
+// UpdateSyncStatus applies update to the status file. The file is replaced
+// in one rename, so a prompt reading it never sees half of it.
+func UpdateSyncStatus(update func(*SyncStatus)) error {
+	return updateSyncStatusAt(config.StatusFilePath(), update)
+}
+
+func updateSyncStatusAt(path string, update func(*SyncStatus)) error {
+	st, err := loadSyncStatusFrom(path)
+	if err != nil {
+		// A damaged file is rebuilt from scratch
+		st = &SyncStatus{}
+	}
+	update(st)
+	st.Updated = time.Now()
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".status-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write status file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }() // no-op if rename succeeded
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write status file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write status file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to write status file: %w", err)
+	}
+	return nil
+}
+
+// RecordSyncStatus updates the status file after op ("push" or "pull")
+// ended with result and runErr, counting pending changes and conflicts
+// afresh. A run with failed files counts as failed.
+func (s *Syncer) RecordSyncStatus(ctx context.Context, op string, result *SyncResult, runErr error) error {
+	if runErr == nil && result != nil && len(result.Errors) > 0 {
+		runErr = fmt.Errorf("%d file(s) failed to %s", len(result.Errors), op)
+	}
+	changes, err := s.Status(ctx)
+	if err != nil {
+		return err
+	}
+	return s.updateSyncStatus(changes, func(st *SyncStatus) {
+		if runErr != nil {
+			st.LastError = runErr.Error()
+			return
+		}
+		st.LastSync = time.Now()
+		st.LastOp = op
+		st.LastError = ""
+	})
+}
+
+// RefreshSyncStatus updates the counts in the status file, given the
+// pending changes 'claude-sync status' found.
+func (s *Syncer) RefreshSyncStatus(changes []FileChange) error {
+	return s.updateSyncStatus(changes, func(*SyncStatus) {})
+}
+
+func (s *Syncer) updateSyncStatus(changes []FileChange, update func(*SyncStatus)) error {
+	conflicts, err := s.ListConflicts()
+	if err != nil {
+		return err
+	}
+	return UpdateSyncStatus(func(st *SyncStatus) {
+		st.Pending = len(changes)
+		st.Conflicts = len(conflicts)
+		update(st)
+	})
+}
+
+// RecountConflicts updates the conflict count in the status file, after
+// conflicts in claudeDir were resolved.
+func RecountConflicts(claudeDir string) error {
+	conflicts, err := FindConflicts(claudeDir, nil)
+	if err != nil {
+		return err
+	}
+	return UpdateSyncStatus(func(st *SyncStatus) {
+		st.Conflicts = len(conflicts)
+	})
+}
@@ -0,0 +1,56 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRecordSyncStatus(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	load := func() *SyncStatus {
+		t.Helper()
+		st, err := LoadSyncStatus()
+		if err != nil {
+			t.Fatalf("LoadSyncStatus failed: %v", err)
+		}
+		return st
+	}
+	if got := load().State(); got != "unknown" {
+		t.Errorf("State before any sync = %q, want unknown", got)
+	}
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	result := pushOK(t, env)
+	if err := env.syncer.RecordSyncStatus(ctx, "push", result, nil); err != nil {
+		t.Fatalf("RecordSyncStatus failed: %v", err)
+	}
+	st := load()
+	if st.State() != "ok" || st.LastOp != "push" || st.LastSync.IsZero() {
+		t.Errorf("After push: %+v", st)
+	}
+	lastSync := st.LastSync
+
+	writeFile(t, env.claudeDir, "agents/a.md", "agent a")
+	if err := env.syncer.RecordSyncStatus(ctx, "pull", nil, errors.New("offline")); err != nil {
+		t.Fatalf("RecordSyncStatus failed: %v", err)
+	}
+	st = load()
+	if st.State() != "error" || st.LastError != "offline" || st.Pending != 1 {
+		t.Errorf("After failed pull: %+v", st)
+	}
+	if !st.LastSync.Equal(lastSync) {
+		t.Errorf("Failed pull moved LastSync to %v", st.LastSync)
+	}
+
+	writeFile(t, env.claudeDir, "CLAUDE.md.conflict.20260208-095132", "remote rules")
+	if err := RecountConflicts(env.claudeDir); err != nil {
+		t.Fatalf("RecountConflicts failed: %v", err)
+	}
+	if st = load(); st.Conflicts != 1 {
+		t.Errorf("Conflicts = %d, want 1", st.Conflicts)
+	}
+}
@@ -5,20 +5,27 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/tawanorg/claude-sync/internal/claudesettings"
 	"github.com/tawanorg/claude-sync/internal/config"
 	"github.com/tawanorg/claude-sync/internal/crypto"
+	"github.com/tawanorg/claude-sync/internal/kms"
 	"github.com/tawanorg/claude-sync/internal/storage"
 
-	// Register storage adapters
+	// Register KMS providers and storage adapters
+	_ "github.com/tawanorg/claude-sync/internal/kms/awskms"
+	_ "github.com/tawanorg/claude-sync/internal/kms/gcpkms"
 	_ "github.com/tawanorg/claude-sync/internal/storage/gcs"
 	_ "github.com/tawanorg/claude-sync/internal/storage/r2"
 	_ "github.com/tawanorg/claude-sync/internal/storage/s3"
@@ -36,30 +43,136 @@ const ManifestKey = "_metadata/manifest.json"
 // FileManifest stores metadata about synced files, primarily mtimes.
 type FileManifest struct {
 	Files map[string]FileMetadata `json:"files"`
+
+	// SignerKey and Signature are set when the pushing device has
+	// sign_manifest on; see putManifest.
+	SignerKey string `json:"signer_key,omitempty"`
+	Signature string `json:"signature,omitempty"`
 }
 
 // FileMetadata stores metadata for a single file.
 type FileMetadata struct {
 	ModTime time.Time `json:"mod_time"`
+	Hash    string    `json:"hash,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+
+	// Device and PushedAt identify which device pushed this content and when.
+	Device   string    `json:"device,omitempty"`
+	PushedAt time.Time `json:"pushed_at,omitempty"`
+
+	// ObjectHash is the SHA-256 of the stored (encrypted) object.
+	ObjectHash string `json:"object_hash,omitempty"`
 }
 
 type Syncer struct {
 	storage    storage.Storage
-	encryptor  *crypto.Encryptor
+	encryptor  crypto.Cipher
 	state      *SyncState
 	claudeDir  string
+	homeDir    string
 	quiet      bool
 	onProgress ProgressFunc
 	cfg        *config.Config
 	paths      *PathMapper
+	netFS      string            // Filesystem type when claudeDir is on a network filesystem
+	keys       *keyNamer         // Derives opaque remote keys when obfuscate_keys is on
+	device     *crypto.Encryptor // This device's own key when device_keys is on
+	notifier   Notifier          // Receives sync events; nil when none are configured
+	keyService crypto.KeyService // The storage's KMS key; nil when it has none
+
+	// openBucket opens another bucket with this storage's settings, for
+	// following a bucket move; nil when the Syncer wasn't built from config.
+	openBucket func(bucket string) (storage.Storage, error)
+
+	confirmDeletes bool // Push may delete more than delete_threshold files
+	forceUpload    bool // Push uploads every file, changed or not
+
+	windows       []syncWindow // From sync_windows
+	ignoreWindows bool         // Sync paths whose window is closed anyway
+
+	plan map[string]bool // Set by PullPlan: the only changes pull may make
+	skip map[string]bool // Set by PullSelected: paths the user chose not to pull
+	only map[string]bool // Set by PushSelected: the only paths push may change
 }
 
 type SyncResult struct {
-	Uploaded   []string
-	Downloaded []string
-	Deleted    []string
-	Conflicts  []string
-	Errors     []error
+	Uploaded   []string `json:"uploaded"`
+	Downloaded []string `json:"downloaded"`
+	Deleted    []string `json:"deleted"`
+	Conflicts  []string `json:"conflicts"`
+	Errors     []error  `json:"-"` // Marshaled as messages
+
+	// InvalidJSONL lists pulled .jsonl files with lines that don't parse.
+	InvalidJSONL []JSONLIssue `json:"invalid_jsonl,omitempty"`
+
+	// InvalidSettings lists pulled settings files that failed validation.
+	InvalidSettings []SettingsIssue `json:"invalid_settings,omitempty"`
+
+	// ShadowedCommands lists remote slash commands pull skipped because a
+	// higher-ranked command set defines the same name (command_namespaces).
+	ShadowedCommands []ShadowedCommand `json:"shadowed_commands,omitempty"`
+
+	// Paused lists local changes push held back because their path is
+	// paused.
+	Paused []string `json:"paused,omitempty"`
+
+	// Deferred lists local changes push held back because their path's
+	// sync window is closed.
+	Deferred []string `json:"deferred,omitempty"`
+
+	// Proposal is the proposal push staged for changes to reviewed command
+	// sets (review_namespaces), if any. AwaitingReview lists changes held
+	// back because an earlier proposal is still waiting; Rejected lists
+	// changes whose proposal was rejected, proposed again on the next push.
+	Proposal       *Proposal `json:"proposal,omitempty"`
+	AwaitingReview []string  `json:"awaiting_review,omitempty"`
+	Rejected       []string  `json:"rejected,omitempty"`
+
+	// Backup is the automatic backup pull made of the files it overwrote
+	// (pull_backups), if any.
+	Backup string `json:"backup,omitempty"`
+
+	// PlanChanged lists files PullPlan left alone because they changed
+	// since the preview; a later pull picks them up.
+	PlanChanged []string `json:"plan_changed,omitempty"`
+
+	// Skipped lists the files of the plan PullSelected was told to leave
+	// alone, or the changes PushSelected wasn't given.
+	Skipped []string `json:"skipped,omitempty"`
+
+	// BucketMoved is set when push or pull found the bucket had been moved
+	// with 'claude-sync remote move' and synced with the new bucket instead;
+	// the caller should switch its config to it.
+	BucketMoved *BucketMove `json:"bucket_moved,omitempty"`
+
+	// Requests counts the storage calls the operation made (zero when the
+	// storage isn't metered).
+	Requests storage.RequestStats `json:"requests"`
+}
+
+// MarshalJSON writes the result with Errors as their messages and empty
+// lists as [] rather than null, for --json output.
+func (r SyncResult) MarshalJSON() ([]byte, error) {
+	type result SyncResult
+	out := struct {
+		result
+		Errors []string `json:"errors"`
+	}{result: result(r), Errors: errorMessages(r.Errors)}
+	for _, list := range []*[]string{&out.Uploaded, &out.Downloaded, &out.Deleted, &out.Conflicts} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+	return json.Marshal(out)
+}
+
+// errorMessages returns each error's message.
+func errorMessages(errs []error) []string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return messages
 }
 
 type ProgressEvent struct {
@@ -70,10 +183,68 @@ type ProgressEvent struct {
 	Total    int
 	Complete bool
 	Error    error
+
+	// Finished marks the event sent when Path's upload or download is done
+	// (or has failed), after the one sent when it started.
+	Finished bool
+
+	// Bytes is how much of TotalBytes, the size of every file the push or
+	// pull transfers, is done: the sizes of the files finished so far, and
+	// the share done of those in flight. Only upload and download events
+	// set them.
+	Bytes      int64
+	TotalBytes int64
+
+	// InFlight marks the events sent as Path's object is sent or received,
+	// between the one sent when it started and the Finished one. FileBytes
+	// is how much of Size is done.
+	InFlight  bool
+	FileBytes int64
 }
 
 type ProgressFunc func(event ProgressEvent)
 
+// byteProgress counts the bytes a push or pull has transferred, for the
+// Bytes of its events: the files finished, and how far along the ones in
+// flight are.
+type byteProgress struct {
+	done, inFlight atomic.Int64
+}
+
+func (b *byteProgress) load() int64 {
+	return b.done.Load() + b.inFlight.Load()
+}
+
+// trackBytes returns ctx for transferring the object of the file event is
+// about, under which the adapter's progress is sent as InFlight copies of
+// event, its share scaled to event.Size (an upload's object is compressed
+// and encrypted). finish counts the file in full once it's done and
+// returns the new total.
+func (s *Syncer) trackBytes(ctx context.Context, b *byteProgress, event ProgressEvent) (tracked context.Context, finish func() int64) {
+	var counted atomic.Int64
+	tracked = storage.WithProgress(ctx, func(done, objectSize int64) {
+		if objectSize <= 0 {
+			objectSize = event.Size // A download's listed size
+		}
+		share := event.Size
+		if done < objectSize {
+			share = done * event.Size / objectSize
+		}
+		b.inFlight.Add(share - counted.Swap(share))
+
+		e := event
+		e.InFlight = true
+		e.FileBytes = share
+		e.Bytes = b.load()
+		s.progress(e)
+	})
+	finish = func() int64 {
+		b.inFlight.Add(-counted.Swap(0))
+		return b.done.Add(event.Size)
+	}
+	return tracked, finish
+}
+
 func NewSyncer(cfg *config.Config, quiet bool) (*Syncer, error) {
 	storageCfg := cfg.GetStorageConfig()
 	store, err := storage.New(storageCfg)
@@ -81,13 +252,106 @@ func NewSyncer(cfg *config.Config, quiet bool) (*Syncer, error) {
 		return nil, fmt.Errorf("failed to create storage client: %w", err)
 	}
 
-	enc, err := crypto.NewEncryptor(cfg.EncryptionKey)
+	enc, err := crypto.NewCipher(cfg.Encryption, cfg.EncryptionKey, cfg.Recipients)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create encryptor: %w", err)
 	}
 
+	notifier, err := NewNotifier(cfg.Notify)
+	if err != nil {
+		return nil, err
+	}
+
+	// With device keys, the configured key only opens the device registry,
+	// which holds the key files are encrypted to
+	var device *crypto.Encryptor
+	if cfg.DeviceKeys {
+		if device = ageCipher(enc); device == nil {
+			return nil, errors.New("device_keys needs the age encryption scheme")
+		}
+		if enc, err = UnlockDataKey(context.Background(), store, device, cfg.Recipients); err != nil {
+			return nil, err
+		}
+	}
+
+	// With a keyring, the configured key only opens the keyring, which holds
+	// the key files are encrypted to
+	if cfg.Keyring {
+		if device != nil {
+			return nil, errors.New("keyring and device_keys can't both be set")
+		}
+		if enc, err = UnlockKeyring(context.Background(), store, enc, cfg.Recipients); err != nil {
+			return nil, err
+		}
+	}
+
+	// File keys are also wrapped with the storage's KMS key, if it has one
+	keyService, err := kms.New(storageCfg)
+	if err != nil {
+		return nil, err
+	}
+	if keyService != nil {
+		ageEnc := ageCipher(enc)
+		if ageEnc == nil {
+			return nil, errors.New("kms needs the age encryption scheme")
+		}
+		enc = ageEnc.WithKeyService(keyService)
+	}
+
+	s, err := newLocalSyncer(cfg, quiet)
+	if err != nil {
+		return nil, err
+	}
+
+	// During a bucket move's grace window, keep the old bucket current too
+	previous := cfg.MirroredBucket(time.Now())
+	var mirrorErr error
+	if previous != "" {
+		previousCfg := *storageCfg
+		previousCfg.Bucket = previous
+		var previousStore storage.Storage
+		if previousStore, mirrorErr = storage.New(&previousCfg); mirrorErr == nil {
+			store = storage.NewMirrored(store, previousStore)
+		}
+	}
+
+	s.storage = store
+	s.openBucket = func(bucket string) (storage.Storage, error) {
+		bucketCfg := *storageCfg
+		bucketCfg.Bucket = bucket
+		return storage.New(&bucketCfg)
+	}
+	s.encryptor = enc
+	s.device = device
+	s.notifier = notifier
+	s.keyService = keyService
+	if mirrorErr != nil {
+		// Devices that haven't switched yet still read the old bucket
+		slog.Warn("can't open the previous bucket", "bucket", previous, "error", mirrorErr)
+		s.log("Warning: can't open the previous bucket %s (%v); until %s, devices that haven't switched buckets won't see this device's pushes",
+			previous, mirrorErr, cfg.PreviousBucketUntil.Local().Format("2006-01-02"))
+	}
+	if cfg.ObfuscateKeys {
+		if err := s.loadKeyNames(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// NewOfflineSyncer creates a Syncer from this device's state and config
+// alone, without storage or keys, for an offline Plan. It works with no
+// storage configured at all; anything that needs the remote fails.
+func NewOfflineSyncer(cfg *config.Config, quiet bool) (*Syncer, error) {
+	return newLocalSyncer(cfg, quiet)
+}
+
+// newLocalSyncer sets up the parts of a Syncer that come from this device:
+// the state, the Claude directory, and the settings deciding what syncs.
+func newLocalSyncer(cfg *config.Config, quiet bool) (*Syncer, error) {
 	// Use overridden state path if provided, otherwise use default
 	var state *SyncState
+	var err error
 	if cfg.StateDirOverride != "" {
 		state, err = LoadStateFromDir(cfg.StateDirOverride)
 	} else {
@@ -103,43 +367,131 @@ func NewSyncer(cfg *config.Config, quiet bool) (*Syncer, error) {
 		claudeDir = cfg.ClaudeDirOverride
 	}
 
+	// A changed hash_algorithm re-hashes tracked files once, so switching
+	// doesn't make every file look modified
+	algorithm, err := cfg.HashAlgorithmName()
+	if err != nil {
+		return nil, err
+	}
+	if state.hashAlgorithm() != algorithm {
+		if _, err := state.MigrateHashes(claudeDir, algorithm); err != nil {
+			return nil, fmt.Errorf("failed to migrate file hashes: %w", err)
+		}
+		if err := state.Save(); err != nil {
+			return nil, fmt.Errorf("failed to save state: %w", err)
+		}
+	}
+
+	if _, err := cfg.CommandPrecedence(); err != nil {
+		return nil, err
+	}
+	windows, err := parseSyncWindows(cfg.SyncWindows)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cfg.ReviewedCommandSets(); err != nil {
+		return nil, err
+	}
+
 	homeDir, _ := os.UserHomeDir()
 	mapper, err := NewPathMapper(homeDir, cfg.PathMap)
 	if err != nil {
 		return nil, err
 	}
 
+	netFS := networkFSFor(claudeDir, cfg.NetworkFS)
+	state.syncWrites = netFS != ""
+
 	return &Syncer{
-		storage:   store,
-		encryptor: enc,
 		state:     state,
 		claudeDir: claudeDir,
+		homeDir:   homeDir,
 		quiet:     quiet,
 		cfg:       cfg,
 		paths:     mapper,
+		netFS:     netFS,
+		windows:   windows,
 	}, nil
 }
 
 // NewSyncerWith creates a Syncer with pre-built dependencies (for testing).
-func NewSyncerWith(cfg *config.Config, store storage.Storage, enc *crypto.Encryptor, state *SyncState, claudeDir string, quiet bool) *Syncer {
+func NewSyncerWith(cfg *config.Config, store storage.Storage, enc crypto.Cipher, state *SyncState, claudeDir string, quiet bool) *Syncer {
 	homeDir, _ := os.UserHomeDir()
 	mapper, _ := NewPathMapper(homeDir, cfg.PathMap)
+	windows, _ := parseSyncWindows(cfg.SyncWindows)
 	return &Syncer{
 		storage:   store,
 		encryptor: enc,
 		state:     state,
 		claudeDir: claudeDir,
+		homeDir:   homeDir,
 		quiet:     quiet,
 		cfg:       cfg,
 		paths:     mapper,
+		windows:   windows,
 	}
 }
 
+// ageCipher returns enc when it is an age Encryptor, which the features
+// built on age keys (device keys, KMS) need; otherwise nil.
+func ageCipher(enc crypto.Cipher) *crypto.Encryptor {
+	ageEnc, _ := enc.(*crypto.Encryptor)
+	return ageEnc
+}
+
 func (s *Syncer) SetProgressFunc(fn ProgressFunc) {
 	s.onProgress = fn
 }
 
+// SetConfirmDeletes lets Push delete more remote files than delete_threshold
+// allows, once the user has agreed to it.
+func (s *Syncer) SetConfirmDeletes(confirm bool) {
+	s.confirmDeletes = confirm
+}
+
+// SetForceUpload makes Push encrypt and upload every local file in the sync
+// paths, not only the changed ones, to repair a remote or a state file that
+// no longer match the local files.
+func (s *Syncer) SetForceUpload(force bool) {
+	s.forceUpload = force
+}
+
+// addUnchanged adds an upload for every local file changes doesn't cover
+// yet, for SetForceUpload. Files in reviewed command sets are left out: an
+// unchanged one would be proposed for review again.
+func (s *Syncer) addUnchanged(changes []FileChange) ([]FileChange, error) {
+	covered := make(map[string]bool, len(changes))
+	for _, change := range changes {
+		covered[change.Path] = true
+	}
+	localFiles, err := GetLocalFiles(s.claudeDir, s.syncPaths(), s.isExcluded)
+	if err != nil {
+		return nil, err
+	}
+
+	var unchanged []FileChange
+	for relPath, info := range localFiles {
+		if covered[relPath] || s.needsReview(relPath) {
+			continue
+		}
+		change := FileChange{Path: relPath, Action: "modify", LocalSize: info.Size(), LocalTime: info.ModTime()}
+		if f := s.state.GetFile(relPath); f != nil {
+			change.LocalHash = f.Hash
+		}
+		unchanged = append(unchanged, change)
+	}
+	sort.Slice(unchanged, func(i, j int) bool { return unchanged[i].Path < unchanged[j].Path })
+	return append(changes, unchanged...), nil
+}
+
 func (s *Syncer) progress(event ProgressEvent) {
+	switch {
+	case event.Error != nil:
+		slog.Warn("sync file failed", "action", event.Action, "path", event.Path, "error", event.Error)
+	case !event.Complete && !event.Finished && !event.InFlight:
+		slog.Debug("sync progress", "action", event.Action, "path", event.Path, "size", event.Size,
+			"current", event.Current, "total", event.Total)
+	}
 	if s.onProgress != nil {
 		s.onProgress(event)
 	}
@@ -149,10 +501,45 @@ func (s *Syncer) isExcluded(relPath string) bool {
 	return s.cfg.IsExcluded(relPath)
 }
 
+// dropHostSkipped drops the deletions of paths this machine doesn't sync
+// because of a hosts override: they are missing here on purpose, and other
+// devices still sync them.
+func (s *Syncer) dropHostSkipped(changes []FileChange) []FileChange {
+	if len(s.cfg.Hosts) == 0 {
+		return changes
+	}
+	kept := changes[:0:0]
+	for _, change := range changes {
+		if change.Action == "delete" && s.cfg.SkippedOnHost(change.Path) {
+			continue
+		}
+		kept = append(kept, change)
+	}
+	return kept
+}
+
+// dropUnselected drops the changes PushSelected wasn't given, returning
+// their paths sorted.
+func (s *Syncer) dropUnselected(changes []FileChange) (kept []FileChange, skipped []string) {
+	if s.only == nil {
+		return changes, nil
+	}
+	for _, change := range changes {
+		if !s.only[change.Path] {
+			skipped = append(skipped, change.Path)
+			continue
+		}
+		kept = append(kept, change)
+	}
+	sort.Strings(skipped)
+	return kept, skipped
+}
+
 // syncPaths returns the set of ~/.claude paths to sync, honoring the
-// configured scope ("full" by default, or "sessions" for portable data only).
+// configured scope ("full" by default, or "sessions" for portable data only)
+// and this machine's hosts overrides.
 func (s *Syncer) syncPaths() []string {
-	return config.ScopedSyncPaths(s.cfg.Scope)
+	return s.cfg.ScopeSyncPaths()
 }
 
 // Scope returns the configured sync scope (empty means the default "full").
@@ -166,8 +553,22 @@ func (s *Syncer) log(format string, args ...interface{}) {
 	}
 }
 
-func (s *Syncer) Push(ctx context.Context) (*SyncResult, error) {
-	result := &SyncResult{}
+func (s *Syncer) push(ctx context.Context) (*SyncResult, error) {
+	if err := s.checkLocation(); err != nil {
+		return nil, err
+	}
+	// Requests are counted from here, on the bucket moved to if there is one
+	moved, err := s.followBucketMove(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &SyncResult{BucketMoved: moved}
+	startRequests := s.requestStats()
+	defer func() { result.Requests = s.requestStats().Sub(startRequests) }()
+
+	if err := s.checkFingerprint(ctx, true); err != nil {
+		return nil, err
+	}
 
 	s.progress(ProgressEvent{Action: "scan", Path: "Detecting changes..."})
 
@@ -175,12 +576,78 @@ func (s *Syncer) Push(ctx context.Context) (*SyncResult, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to detect changes: %w", err)
 	}
+	if s.forceUpload {
+		if changes, err = s.addUnchanged(changes); err != nil {
+			return nil, fmt.Errorf("failed to list local files: %w", err)
+		}
+	}
+	changes = s.dropHostSkipped(changes)
+	changes, result.Paused = s.dropPaused(changes)
+	changes, result.Deferred = s.dropOutsideWindows(changes)
+	changes, result.Skipped = s.dropUnselected(changes)
+	if err := s.checkDeleteThreshold(changes); err != nil {
+		return nil, err
+	}
+
+	changes, review, reviewChanged, err := s.splitForReview(ctx, changes, result)
+	if err != nil {
+		return nil, err
+	}
+
+	// Upload keys must be in the key index before anything is stored under them
+	var named []string
+	for _, list := range [][]FileChange{changes, review} {
+		for _, change := range list {
+			if change.Action != "delete" {
+				named = append(named, change.Path)
+			}
+		}
+	}
+	if err := s.recordKeyNames(ctx, named); err != nil {
+		return nil, err
+	}
+
+	if len(review) > 0 {
+		if result.Proposal, err = s.propose(ctx, review); err != nil {
+			return nil, fmt.Errorf("failed to stage changes for review: %w", err)
+		}
+		reviewChanged = true
+	}
 
 	if len(changes) == 0 {
-		s.progress(ProgressEvent{Action: "scan", Complete: true})
+		if result.Proposal == nil {
+			s.progress(ProgressEvent{Action: "scan", Complete: true})
+		}
+		// sign_manifest was just turned on: sign the manifest now
+		if s.manifestNeedsSigning(ctx) {
+			if err := s.uploadManifest(ctx, nil); err != nil {
+				return result, err
+			}
+			reviewChanged = true
+		}
+		if reviewChanged {
+			if err := s.state.Save(); err != nil {
+				return result, fmt.Errorf("failed to save state: %w", err)
+			}
+		}
 		return result, nil
 	}
 
+	// Leases are renewed until the manifest is written and released when
+	// the push returns
+	stopRenewing := func() error { return nil }
+	if s.cfg.PushLeases {
+		leases, err := s.acquireLeases(ctx, changes)
+		if err != nil {
+			return nil, err
+		}
+		stopRenewing = s.renewLeases(ctx, leases)
+		defer func() {
+			_ = stopRenewing()
+			s.releaseLeases(ctx, leases)
+		}()
+	}
+
 	// Separate uploads from deletes
 	var uploads, deletes []FileChange
 	for _, change := range changes {
@@ -193,8 +660,13 @@ func (s *Syncer) Push(ctx context.Context) (*SyncResult, error) {
 	}
 
 	total := len(changes)
+	var totalBytes int64
+	for _, change := range uploads {
+		totalBytes += change.LocalSize
+	}
 	var mu sync.Mutex
 	var completed atomic.Int32
+	var transferred byteProgress
 
 	// Process uploads concurrently
 	if len(uploads) > 0 {
@@ -208,21 +680,23 @@ func (s *Syncer) Push(ctx context.Context) (*SyncResult, error) {
 				sem <- struct{}{}
 				defer func() { <-sem }()
 
-				n := int(completed.Add(1))
-				s.progress(ProgressEvent{
-					Action:  "upload",
-					Path:    change.Path,
-					Size:    change.LocalSize,
-					Current: n,
-					Total:   total,
-				})
-
-				if err := s.uploadFile(ctx, change.Path); err != nil {
-					s.progress(ProgressEvent{
-						Action: "upload",
-						Path:   change.Path,
-						Error:  err,
-					})
+				event := ProgressEvent{
+					Action:     "upload",
+					Path:       change.Path,
+					Size:       change.LocalSize,
+					Current:    int(completed.Add(1)),
+					Total:      total,
+					TotalBytes: totalBytes,
+				}
+				started := event
+				started.Bytes = transferred.load()
+				s.progress(started)
+
+				fileCtx, finish := s.trackBytes(ctx, &transferred, event)
+				err := s.uploadFile(fileCtx, change.Path)
+				event.Error, event.Finished, event.Bytes = err, true, finish()
+				s.progress(event)
+				if err != nil {
 					mu.Lock()
 					result.Errors = append(result.Errors, fmt.Errorf("%s: %w", change.Path, err))
 					mu.Unlock()
@@ -242,24 +716,47 @@ func (s *Syncer) Push(ctx context.Context) (*SyncResult, error) {
 		for i, change := range deletes {
 			deleteKeys[i] = s.remoteKey(change.Path)
 		}
-		if err := s.storage.DeleteBatch(ctx, deleteKeys); err != nil {
-			result.Errors = append(result.Errors, fmt.Errorf("batch delete: %w", err))
-		} else {
-			for _, change := range deletes {
+		deleted, err := s.deleteRemote(ctx, deleteKeys)
+		if err != nil {
+			result.Errors = append(result.Errors, err)
+		}
+		for _, change := range deletes {
+			if deleted[s.remoteKey(change.Path)] {
 				s.state.RemoveFile(change.Path)
 				result.Deleted = append(result.Deleted, change.Path)
 			}
 		}
 	}
 
-	s.progress(ProgressEvent{Action: "upload", Complete: true, Total: total})
+	s.progress(ProgressEvent{Action: "upload", Complete: true, Total: total, Bytes: transferred.load(), TotalBytes: totalBytes})
+	s.updateRemoteCache(result.Uploaded, result.Deleted)
 
 	// Upload manifest with file mtimes for cross-device mtime preservation
 	if len(result.Uploaded) > 0 || len(result.Deleted) > 0 {
-		if err := s.uploadManifest(ctx); err != nil {
+		var err error
+		if s.cfg.PushLeases {
+			err = s.withManifestLease(ctx, func() error { return s.uploadManifest(ctx, result.Deleted) })
+		} else {
+			err = s.uploadManifest(ctx, result.Deleted)
+		}
+		if err != nil {
 			// Log but don't fail - manifest is best-effort
 			s.log("Warning: failed to upload manifest: %v", err)
 		}
+		if err := s.recordSnapshot(ctx); err != nil {
+			s.log("Warning: failed to record snapshot: %v", err)
+		}
+	}
+	if err := stopRenewing(); err != nil {
+		result.Errors = append(result.Errors, err)
+	}
+	if len(result.Uploaded) > 0 && s.hasVersionRetention() {
+		if _, err := s.PruneVersions(ctx, false); err != nil {
+			s.log("Warning: failed to prune versions: %v", err)
+		}
+	}
+	if len(result.Uploaded) > 0 || len(result.Deleted) > 0 {
+		s.attestAfterPush(ctx)
 	}
 
 	s.state.LastPush = time.Now()
@@ -271,15 +768,29 @@ func (s *Syncer) Push(ctx context.Context) (*SyncResult, error) {
 	return result, nil
 }
 
-func (s *Syncer) Pull(ctx context.Context) (*SyncResult, error) {
-	result := &SyncResult{}
+func (s *Syncer) pull(ctx context.Context) (*SyncResult, error) {
+	if err := s.checkLocation(); err != nil {
+		return nil, err
+	}
+	// Requests are counted from here, on the bucket moved to if there is one
+	moved, err := s.followBucketMove(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &SyncResult{BucketMoved: moved}
+	startRequests := s.requestStats()
+	defer func() { result.Requests = s.requestStats().Sub(startRequests) }()
+
+	if err := s.checkFingerprint(ctx, false); err != nil {
+		return nil, err
+	}
 
 	s.progress(ProgressEvent{Action: "scan", Path: "Fetching remote file list..."})
 
 	// List all remote objects
-	remoteObjects, err := s.storage.List(ctx, "")
+	remoteObjects, err := s.listRemote(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list remote objects: %w", err)
+		return nil, err
 	}
 
 	if len(remoteObjects) == 0 {
@@ -287,14 +798,17 @@ func (s *Syncer) Pull(ctx context.Context) (*SyncResult, error) {
 		return result, nil
 	}
 
-	// Download manifest for mtime restoration (best-effort, may not exist)
-	manifest, _ := s.downloadManifest(ctx)
+	// Download manifest for mtime restoration (best-effort, may not exist,
+	// unless sign_manifest requires a trusted signature)
+	manifest, err := s.pullManifest(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	// Build remote file map
 	remoteFiles, skipped := s.buildRemoteMap(remoteObjects)
 	for _, key := range skipped {
-		result.Errors = append(result.Errors,
-			fmt.Errorf("%s: unknown path token; add the matching path_map entry on this device", key))
+		result.Errors = append(result.Errors, s.unresolvedKeyError(key))
 	}
 
 	// Get current local files
@@ -302,6 +816,7 @@ func (s *Syncer) Pull(ctx context.Context) (*SyncResult, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get local files: %w", err)
 	}
+	result.ShadowedCommands = s.dropShadowedCommands(remoteFiles, localFiles)
 
 	// Build list of files to download
 	type downloadTask struct {
@@ -309,9 +824,18 @@ func (s *Syncer) Pull(ctx context.Context) (*SyncResult, error) {
 		remoteObj storage.ObjectInfo
 	}
 	var toDownload []downloadTask
+	planned := make(map[string]bool)
 
 	for localPath, remoteObj := range remoteFiles {
 		localInfo, localExists := localFiles[localPath]
+		if s.skip[localPath] {
+			result.Skipped = append(result.Skipped, localPath)
+			continue
+		}
+		if s.plan != nil && !s.plannedAction(localPath, remoteObj, localInfo, localExists, planned) {
+			result.PlanChanged = append(result.PlanChanged, localPath)
+			continue
+		}
 		stateFile := s.state.GetFile(localPath)
 
 		shouldDownload := false
@@ -323,21 +847,19 @@ func (s *Syncer) Pull(ctx context.Context) (*SyncResult, error) {
 			if remoteObj.LastModified.After(stateFile.Uploaded) {
 				// Remote was updated after we last uploaded
 				// Check if local was also modified
-				localHash, _ := HashFile(filepath.Join(s.claudeDir, localPath))
+				localHash, _ := s.state.hashFile(filepath.Join(s.claudeDir, localPath))
 				if localHash != stateFile.Hash {
-					// Conflict: both changed
-					result.Conflicts = append(result.Conflicts, localPath)
-					s.progress(ProgressEvent{
-						Action: "conflict",
-						Path:   localPath,
-					})
-					if err := s.handleConflict(ctx, localPath, remoteObj); err != nil {
-						result.Errors = append(result.Errors, err)
-					}
+					// Both changed: only a conflict if the contents actually differ
+					s.resolveConflict(ctx, result, localPath, remoteObj, manifest)
 					continue
 				}
 				shouldDownload = true
 			}
+		} else if s.netFS != "" {
+			// A file server's mtimes follow its own clock: compare contents
+			// rather than letting a skewed mtime overwrite the local file
+			s.resolveConflict(ctx, result, localPath, remoteObj, manifest)
+			continue
 		} else if localInfo.ModTime().Before(remoteObj.LastModified) {
 			shouldDownload = true
 		}
@@ -346,9 +868,38 @@ func (s *Syncer) Pull(ctx context.Context) (*SyncResult, error) {
 			toDownload = append(toDownload, downloadTask{localPath, remoteObj})
 		}
 	}
+	if s.plan != nil {
+		result.PlanChanged = s.planChanged(result.PlanChanged, planned)
+	}
+	sort.Strings(result.Skipped)
+
+	// Keep a copy of the local files this pull replaces (pull_backups)
+	if s.cfg.PullBackups > 0 {
+		var overwritten []string
+		for _, task := range toDownload {
+			if _, ok := localFiles[task.localPath]; ok {
+				overwritten = append(overwritten, task.localPath)
+			}
+		}
+		if len(overwritten) > 0 {
+			backupDir, err := createAutoBackup(s.claudeDir, overwritten)
+			if err != nil {
+				return result, fmt.Errorf("failed to back up files before pull: %w", err)
+			}
+			result.Backup = backupDir
+			if _, err := PruneBackups(s.claudeDir, s.cfg.PullBackups); err != nil {
+				s.log("Warning: failed to prune old backups: %v", err)
+			}
+		}
+	}
 
 	// Download files concurrently
 	total := len(toDownload)
+	var totalBytes int64
+	for _, task := range toDownload {
+		totalBytes += task.remoteObj.Size
+	}
+	var transferred byteProgress
 	if total > 0 {
 		sem := make(chan struct{}, defaultWorkers)
 		var wg sync.WaitGroup
@@ -362,34 +913,54 @@ func (s *Syncer) Pull(ctx context.Context) (*SyncResult, error) {
 				sem <- struct{}{}
 				defer func() { <-sem }()
 
-				n := int(completed.Add(1))
-				s.progress(ProgressEvent{
-					Action:  "download",
-					Path:    task.localPath,
-					Size:    task.remoteObj.Size,
-					Current: n,
-					Total:   total,
-				})
+				event := ProgressEvent{
+					Action:     "download",
+					Path:       task.localPath,
+					Size:       task.remoteObj.Size,
+					Current:    int(completed.Add(1)),
+					Total:      total,
+					TotalBytes: totalBytes,
+				}
+				started := event
+				started.Bytes = transferred.load()
+				s.progress(started)
 
-				// Get original mtime from manifest if available
+				// Get original mtime and origin from manifest if available
 				var mtime *time.Time
+				var origin FileMetadata
 				if manifest != nil {
 					if meta, ok := manifest.Files[task.localPath]; ok {
-						mtime = &meta.ModTime
+						if !meta.ModTime.IsZero() {
+							mtime = &meta.ModTime
+						}
+						origin = meta
 					}
 				}
 
-				if err := s.downloadFile(ctx, task.localPath, task.remoteObj.Key, mtime); err != nil {
-					s.progress(ProgressEvent{
-						Action: "download",
-						Path:   task.localPath,
-						Error:  err,
-					})
+				var objectHash string
+				check := s.objectCheck(manifest, task.localPath, &objectHash)
+				fileCtx, finish := s.trackBytes(ctx, &transferred, event)
+				issue, settingsIssue, err := s.downloadFile(fileCtx, task.localPath, task.remoteObj.Key, mtime, check)
+				event.Error, event.Finished, event.Bytes = err, true, finish()
+				s.progress(event)
+				mu.Lock()
+				if issue != nil {
+					result.InvalidJSONL = append(result.InvalidJSONL, *issue)
+				}
+				if settingsIssue != nil {
+					result.InvalidSettings = append(result.InvalidSettings, *settingsIssue)
+				}
+				mu.Unlock()
+				if err != nil {
 					mu.Lock()
 					result.Errors = append(result.Errors, fmt.Errorf("%s: %w", task.localPath, err))
 					mu.Unlock()
 					return
 				}
+				if origin.Device != "" {
+					s.state.SetOrigin(task.localPath, origin.Device, origin.PushedAt)
+				}
+				s.state.SetObjectHash(task.localPath, objectHash)
 				mu.Lock()
 				result.Downloaded = append(result.Downloaded, task.localPath)
 				mu.Unlock()
@@ -398,7 +969,7 @@ func (s *Syncer) Pull(ctx context.Context) (*SyncResult, error) {
 		wg.Wait()
 	}
 
-	s.progress(ProgressEvent{Action: "download", Complete: true, Total: total})
+	s.progress(ProgressEvent{Action: "download", Complete: true, Total: total, Bytes: transferred.load(), TotalBytes: totalBytes})
 
 	s.state.LastPull = time.Now()
 	s.state.LastSync = time.Now()
@@ -409,17 +980,60 @@ func (s *Syncer) Pull(ctx context.Context) (*SyncResult, error) {
 	return result, nil
 }
 
+// Status returns the local changes a push would upload or delete. Changes to
+// paused paths and to paths outside their sync window are left out.
 func (s *Syncer) Status(ctx context.Context) ([]FileChange, error) {
-	return s.state.DetectChanges(s.claudeDir, s.syncPaths(), s.isExcluded)
+	changes, err := s.state.DetectChanges(s.claudeDir, s.syncPaths(), s.isExcluded)
+	if err != nil {
+		return nil, err
+	}
+	changes = s.dropHostSkipped(changes)
+	changes, _ = s.dropPaused(changes)
+	changes, _ = s.dropOutsideWindows(changes)
+	return changes, nil
 }
 
 func (s *Syncer) uploadFile(ctx context.Context, relativePath string) error {
 	fullPath := filepath.Join(s.claudeDir, relativePath)
 
+	encrypted, err := s.encodeFile(relativePath)
+	if err != nil {
+		return err
+	}
+
+	// Upload
+	remoteKey := s.remoteKey(relativePath)
+	if err := s.storage.Upload(ctx, remoteKey, encrypted); err != nil {
+		return fmt.Errorf("failed to upload: %w", err)
+	}
+
+	// Keep a copy so an overwrite can be undone
+	now := time.Now()
+	if s.cfg.Versioning {
+		// Progress counts the file's upload once
+		if err := s.storage.Upload(storage.WithProgress(ctx, nil), s.versionKey(relativePath, now), encrypted); err != nil {
+			return fmt.Errorf("failed to upload version: %w", err)
+		}
+	}
+
+	// Update state
+	info, _ := os.Stat(fullPath)
+	hash, _ := s.state.hashFile(fullPath)
+	s.state.UpdateFile(relativePath, info, hash)
+	s.state.MarkUploaded(relativePath)
+	s.state.SetOrigin(relativePath, s.state.DeviceID, now)
+	s.state.SetObjectHash(relativePath, sha256Hex(encrypted))
+
+	return nil
+}
+
+// encodeFile reads a local file and returns it as stored remotely:
+// path-normalized where that applies, compressed and encrypted.
+func (s *Syncer) encodeFile(relativePath string) ([]byte, error) {
 	// Read file
-	data, err := os.ReadFile(fullPath)
+	data, err := os.ReadFile(filepath.Join(s.claudeDir, relativePath))
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
 	// Replace machine-specific paths with portable tokens in session content
@@ -430,50 +1044,88 @@ func (s *Syncer) uploadFile(ctx context.Context, relativePath string) error {
 	// Compress
 	compressed, err := gzipCompress(data)
 	if err != nil {
-		return fmt.Errorf("failed to compress: %w", err)
+		return nil, fmt.Errorf("failed to compress: %w", err)
 	}
 
 	// Encrypt
 	encrypted, err := s.encryptor.Encrypt(compressed)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt: %w", err)
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
 	}
+	return encrypted, nil
+}
 
-	// Upload
-	remoteKey := s.remoteKey(relativePath)
-	if err := s.storage.Upload(ctx, remoteKey, encrypted); err != nil {
-		return fmt.Errorf("failed to upload: %w", err)
+// downloadFile downloads and decrypts a file from remote storage.
+// If originalMtime is non-nil, the file's modification time will be restored to that value.
+// JSONL and settings files are validated first; see checkJSONL and checkSettings.
+// check is passed on to fetchFile.
+func (s *Syncer) downloadFile(ctx context.Context, relativePath, remoteKey string, originalMtime *time.Time, check func([]byte) error) (*JSONLIssue, *SettingsIssue, error) {
+	data, err := s.fetchFile(ctx, relativePath, remoteKey, check)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Update state
-	info, _ := os.Stat(fullPath)
-	hash, _ := HashFile(fullPath)
-	s.state.UpdateFile(relativePath, info, hash)
-	s.state.MarkUploaded(relativePath)
+	if isSettingsPath(relativePath) {
+		keep, settingsIssue, err := s.checkSettings(relativePath, data)
+		if err != nil || !keep {
+			return nil, settingsIssue, err
+		}
+		if err := s.writeLocalFile(relativePath, data, originalMtime); err != nil {
+			return nil, settingsIssue, err
+		}
+		return nil, settingsIssue, nil
+	}
 
-	return nil
+	var issue *JSONLIssue
+	remoteHash := ""
+	if isJSONLPath(relativePath) {
+		remoteHash = s.state.hashBytes(data)
+		if data, issue, err = s.checkJSONL(relativePath, data); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := s.writeLocalFile(relativePath, data, originalMtime); err != nil {
+		return issue, nil, err
+	}
+
+	// Record the remote content as the baseline, so the next push shares the repair
+	if issue != nil && issue.Quarantined != "" {
+		info, err := os.Stat(filepath.Join(s.claudeDir, relativePath))
+		if err == nil {
+			s.state.UpdateFile(relativePath, info, remoteHash)
+			s.state.MarkUploaded(relativePath)
+		}
+	}
+	return issue, nil, nil
 }
 
-// downloadFile downloads and decrypts a file from remote storage.
-// If originalMtime is non-nil, the file's modification time will be restored to that value.
-func (s *Syncer) downloadFile(ctx context.Context, relativePath, remoteKey string, originalMtime *time.Time) error {
+// fetchFile downloads a remote object and returns its plaintext, with portable
+// path tokens resolved for this device. If check is non-nil, it is given the
+// downloaded object before anything else is done with it.
+func (s *Syncer) fetchFile(ctx context.Context, relativePath, remoteKey string, check func(encrypted []byte) error) ([]byte, error) {
 	// Download
 	encrypted, err := s.storage.Download(ctx, remoteKey)
 	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+	if check != nil {
+		if err := check(encrypted); err != nil {
+			return nil, err
+		}
 	}
 
 	// Decrypt
 	data, err := s.encryptor.Decrypt(encrypted)
 	if err != nil {
-		return fmt.Errorf("failed to decrypt: %w", err)
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
 	}
 
 	// Decompress if gzipped (backward-compatible with uncompressed data)
 	if isGzipped(data) {
 		data, err = gzipDecompress(data)
 		if err != nil {
-			return fmt.Errorf("failed to decompress: %w", err)
+			return nil, fmt.Errorf("failed to decompress: %w", err)
 		}
 	}
 
@@ -482,6 +1134,35 @@ func (s *Syncer) downloadFile(ctx context.Context, relativePath, remoteKey strin
 		data = s.paths.ResolveContent(data)
 	}
 
+	return data, nil
+}
+
+// writeLocalFile writes downloaded content under claudeDir and records it in state.
+func (s *Syncer) writeLocalFile(relativePath string, data []byte, originalMtime *time.Time) error {
+	if err := s.writeClaudeFile(relativePath, data); err != nil {
+		return err
+	}
+	fullPath := filepath.Join(s.claudeDir, relativePath)
+
+	// Restore original modification time if provided
+	if originalMtime != nil {
+		if err := os.Chtimes(fullPath, *originalMtime, *originalMtime); err != nil {
+			// Log but don't fail - mtime restoration is best-effort
+			s.log("Warning: failed to restore mtime for %s: %v", relativePath, err)
+		}
+	}
+
+	// Update state
+	info, _ := os.Stat(fullPath)
+	hash, _ := s.state.hashFile(fullPath)
+	s.state.UpdateFile(relativePath, info, hash)
+	s.state.MarkUploaded(relativePath)
+
+	return nil
+}
+
+// writeClaudeFile writes content under ~/.claude without touching state.
+func (s *Syncer) writeClaudeFile(relativePath string, data []byte) error {
 	// Guard against path traversal from crafted remote keys
 	fullPath := filepath.Join(s.claudeDir, relativePath)
 	if !strings.HasPrefix(filepath.Clean(fullPath), filepath.Clean(s.claudeDir)+string(filepath.Separator)) {
@@ -498,122 +1179,230 @@ func (s *Syncer) downloadFile(ctx context.Context, relativePath, remoteKey strin
 	if err := os.WriteFile(fullPath, data, 0600); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
+	return nil
+}
 
-	// Restore original modification time if provided
-	if originalMtime != nil {
-		if err := os.Chtimes(fullPath, *originalMtime, *originalMtime); err != nil {
-			// Log but don't fail - mtime restoration is best-effort
-			s.log("Warning: failed to restore mtime for %s: %v", relativePath, err)
-		}
+// handleConflict is called when both the local file and its remote copy changed
+// since the last sync. If the decrypted remote content is byte-for-byte identical
+// to the local file (the same edit was made on both devices), state is simply
+// reconciled and conflicted is false. Otherwise local is kept and the remote
+// version is saved next to it as a .conflict file, with the device and push
+// time from origin (when the manifest has them) recorded in state.
+func (s *Syncer) handleConflict(ctx context.Context, relativePath string, remoteObj storage.ObjectInfo, origin *FileMetadata, check func([]byte) error) (conflicted bool, err error) {
+	remoteData, err := s.fetchFile(ctx, relativePath, remoteObj.Key, check)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch remote version of %s: %w", relativePath, err)
 	}
 
-	// Update state
-	info, _ := os.Stat(fullPath)
-	hash, _ := HashFile(fullPath)
-	s.state.UpdateFile(relativePath, info, hash)
-	s.state.MarkUploaded(relativePath)
-
-	return nil
-}
+	fullPath := filepath.Join(s.claudeDir, relativePath)
+	if localData, err := os.ReadFile(fullPath); err == nil && bytes.Equal(localData, remoteData) {
+		if info, err := os.Stat(fullPath); err == nil {
+			s.state.UpdateFile(relativePath, info, s.state.hashBytes(localData))
+			s.state.MarkUploaded(relativePath)
+		}
+		return false, nil
+	}
 
-func (s *Syncer) handleConflict(ctx context.Context, relativePath string, remoteObj storage.ObjectInfo) error {
 	s.log("Conflict detected: %s (keeping local, saving remote as .conflict)", relativePath)
 
-	// Download remote version with conflict suffix
+	// Save remote version with conflict suffix
 	conflictPath := relativePath + ".conflict." + time.Now().Format("20060102-150405")
-	if err := s.downloadFile(ctx, conflictPath, remoteObj.Key, nil); err != nil {
-		return fmt.Errorf("failed to save conflict file: %w", err)
+	if err := s.writeLocalFile(conflictPath, remoteData, nil); err != nil {
+		return true, fmt.Errorf("failed to save conflict file: %w", err)
+	}
+	conflictOrigin := ConflictOrigin{PushedAt: remoteObj.LastModified}
+	if origin != nil {
+		conflictOrigin.Device = origin.Device
+		if !origin.PushedAt.IsZero() {
+			conflictOrigin.PushedAt = origin.PushedAt
+		}
 	}
+	s.state.RecordConflict(conflictPath, conflictOrigin)
 
-	return nil
+	return true, nil
+}
+
+// resolveConflict runs handleConflict for a pulled file and records the
+// outcome in result.
+func (s *Syncer) resolveConflict(ctx context.Context, result *SyncResult, relativePath string, remoteObj storage.ObjectInfo, manifest *FileManifest) {
+	var origin *FileMetadata
+	if manifest != nil {
+		if meta, ok := manifest.Files[relativePath]; ok {
+			origin = &meta
+		}
+	}
+	var objectHash string
+	conflicted, err := s.handleConflict(ctx, relativePath, remoteObj, origin, s.objectCheck(manifest, relativePath, &objectHash))
+	if err != nil {
+		result.Errors = append(result.Errors, err)
+	} else if !conflicted {
+		s.state.SetObjectHash(relativePath, objectHash)
+	}
+	if conflicted {
+		result.Conflicts = append(result.Conflicts, relativePath)
+		s.progress(ProgressEvent{
+			Action: "conflict",
+			Path:   relativePath,
+		})
+	}
 }
 
-// uploadManifest builds and uploads a manifest containing file mtimes from current state.
-func (s *Syncer) uploadManifest(ctx context.Context) error {
+// buildManifest snapshots the current state as a manifest.
+func (s *Syncer) buildManifest() FileManifest {
 	manifest := FileManifest{
 		Files: make(map[string]FileMetadata),
 	}
 
-	// Build manifest from current state
 	s.state.mu.Lock()
 	for path, fs := range s.state.Files {
 		manifest.Files[path] = FileMetadata{
-			ModTime: fs.ModTime,
+			ModTime:    fs.ModTime,
+			Hash:       fs.Hash,
+			Size:       fs.Size,
+			Device:     fs.Origin,
+			PushedAt:   fs.PushedAt,
+			ObjectHash: fs.ObjectHash,
 		}
 	}
 	s.state.mu.Unlock()
 
-	// Serialize manifest
-	data, err := json.Marshal(manifest)
+	return manifest
+}
+
+// uploadManifest builds and uploads a manifest containing file mtimes from
+// current state, keeping the bucket's entries for files pushed by other
+// devices (see mergeRemoteManifest) but not those of the deleted paths.
+func (s *Syncer) uploadManifest(ctx context.Context, deleted []string) error {
+	s.fillObjectHashes(ctx)
+	manifest := s.buildManifest()
+	if err := s.mergeRemoteManifest(ctx, &manifest, deleted); err != nil {
+		return fmt.Errorf("failed to merge the remote manifest: %w", err)
+	}
+	return s.putManifest(ctx, &manifest)
+}
+
+// downloadManifest downloads and parses the file manifest from remote storage.
+// Returns nil if no manifest exists (backward compatibility with older syncs).
+func (s *Syncer) downloadManifest(ctx context.Context) (*FileManifest, error) {
+	encrypted, err := s.storage.Download(ctx, ManifestKey+".age")
 	if err != nil {
-		return fmt.Errorf("failed to serialize manifest: %w", err)
+		// Manifest may not exist for older syncs - that's OK
+		return nil, nil
 	}
 
-	// Compress
-	compressed, err := gzipCompress(data)
+	var manifest FileManifest
+	if err := s.decodeJSON(encrypted, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+// uploadJSON serializes v, compresses and encrypts it, and stores it under key.
+func (s *Syncer) uploadJSON(ctx context.Context, key string, v interface{}) error {
+	encrypted, err := s.encodeJSON(v)
 	if err != nil {
-		return fmt.Errorf("failed to compress manifest: %w", err)
+		return err
 	}
+	return s.storage.Upload(ctx, key, encrypted)
+}
 
-	// Encrypt
-	encrypted, err := s.encryptor.Encrypt(compressed)
+// encodeJSON serializes, compresses and encrypts v as uploadJSON stores it.
+func (s *Syncer) encodeJSON(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt manifest: %w", err)
+		return nil, fmt.Errorf("failed to serialize: %w", err)
 	}
 
-	// Upload
-	remoteKey := ManifestKey + ".age"
-	if err := s.storage.Upload(ctx, remoteKey, encrypted); err != nil {
-		return fmt.Errorf("failed to upload manifest: %w", err)
+	compressed, err := gzipCompress(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress: %w", err)
 	}
 
-	return nil
+	encrypted, err := s.encryptor.Encrypt(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return encrypted, nil
 }
 
-// downloadManifest downloads and parses the file manifest from remote storage.
-// Returns nil if no manifest exists (backward compatibility with older syncs).
-func (s *Syncer) downloadManifest(ctx context.Context) (*FileManifest, error) {
-	remoteKey := ManifestKey + ".age"
-
-	// Download
-	encrypted, err := s.storage.Download(ctx, remoteKey)
+// downloadJSON fetches an object written by uploadJSON and decodes it into v.
+func (s *Syncer) downloadJSON(ctx context.Context, key string, v interface{}) error {
+	encrypted, err := s.storage.Download(ctx, key)
 	if err != nil {
-		// Manifest may not exist for older syncs - that's OK
-		return nil, nil
+		return err
 	}
+	return s.decodeJSON(encrypted, v)
+}
 
-	// Decrypt
+// decodeJSON decrypts, decompresses if needed, and parses an encrypted JSON object.
+func (s *Syncer) decodeJSON(encrypted []byte, v interface{}) error {
 	data, err := s.encryptor.Decrypt(encrypted)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt manifest: %w", err)
+		return fmt.Errorf("failed to decrypt: %w", err)
 	}
 
-	// Decompress if gzipped
 	if isGzipped(data) {
 		data, err = gzipDecompress(data)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decompress manifest: %w", err)
+			return fmt.Errorf("failed to decompress: %w", err)
 		}
 	}
 
-	// Parse
-	var manifest FileManifest
-	if err := json.Unmarshal(data, &manifest); err != nil {
-		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse: %w", err)
 	}
-
-	return &manifest, nil
+	return nil
 }
 
 func (s *Syncer) remoteKey(relativePath string) string {
 	// Normalize machine-specific path segments, add .age extension
-	return s.paths.NormalizeRelPath(relativePath) + ".age"
+	key := s.paths.NormalizeRelPath(relativePath)
+	if s.keys != nil {
+		key = s.keys.name(key)
+	}
+	return key + ".age"
 }
 
 // localPath maps a remote key back to a local relative path. ok is false when
-// the key uses a path_map token this device doesn't define.
+// the key uses a path_map token this device doesn't define, or is an opaque
+// key missing from the key index.
 func (s *Syncer) localPath(remoteKey string) (string, bool) {
-	return s.paths.ResolveRelPath(strings.TrimSuffix(remoteKey, ".age"))
+	name := strings.TrimSuffix(remoteKey, ".age")
+	if strings.HasPrefix(name, ObjectPrefix) {
+		if s.keys == nil {
+			return "", false
+		}
+		var ok bool
+		if name, ok = s.keys.path(name); !ok {
+			return "", false
+		}
+	}
+	return s.paths.ResolveRelPath(name)
+}
+
+// StatRemote returns remote metadata for the given local relative paths in
+// one batched request, keyed by relative path. Paths with no remote object
+// are absent from the result.
+func (s *Syncer) StatRemote(ctx context.Context, relativePaths []string) (map[string]*storage.ObjectInfo, error) {
+	keys := make([]string, 0, len(relativePaths))
+	relByKey := make(map[string]string, len(relativePaths))
+	for _, rel := range relativePaths {
+		key := s.remoteKey(rel)
+		keys = append(keys, key)
+		relByKey[key] = rel
+	}
+
+	objects, err := s.storage.HeadBatch(ctx, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat remote files: %w", err)
+	}
+
+	result := make(map[string]*storage.ObjectInfo, len(objects))
+	for key, obj := range objects {
+		result[relByKey[key]] = obj
+	}
+	return result, nil
 }
 
 // buildRemoteMap maps remote objects to local relative paths, skipping
@@ -627,21 +1416,19 @@ func (s *Syncer) buildRemoteMap(remoteObjects []storage.ObjectInfo) (remoteFiles
 		if !strings.HasSuffix(obj.Key, ".age") {
 			continue
 		}
+		// Skip claude-sync's own data (MCP externals, metadata, versions)
+		if isReservedKey(obj.Key) {
+			continue
+		}
 		localPath, ok := s.localPath(obj.Key)
 		if !ok {
 			skipped = append(skipped, obj.Key)
 			continue
 		}
-		// Skip external files (handled by MCP sync)
-		if strings.HasPrefix(localPath, "_external/") {
-			continue
-		}
-		// Skip metadata files (manifest, etc.)
-		if strings.HasPrefix(localPath, "_metadata/") {
-			continue
-		}
-		// Skip excluded paths
-		if s.isExcluded(localPath) {
+		// Skip excluded and paused paths, those outside their sync window,
+		// and those this host doesn't sync
+		if s.isExcluded(localPath) || s.isPaused(localPath) || s.outsideWindow(localPath, time.Now()) ||
+			s.cfg.SkippedOnHost(localPath) {
 			continue
 		}
 		if existing, dup := remoteFiles[localPath]; dup {
@@ -666,34 +1453,67 @@ func (s *Syncer) HasState() bool {
 
 // FilePreview represents a file that would be affected by a pull operation
 type FilePreview struct {
-	Path       string
-	LocalTime  time.Time
-	RemoteTime time.Time
-	LocalSize  int64
-	RemoteSize int64
-	LocalOnly  bool // File exists only locally
-	RemoteOnly bool // File exists only remotely
+	Path       string    `json:"path"`
+	RemoteKey  string    `json:"remote_key,omitempty"`
+	LocalTime  time.Time `json:"local_time"`
+	RemoteTime time.Time `json:"remote_time"`
+	LocalSize  int64     `json:"local_size"`
+	RemoteSize int64     `json:"remote_size"`
+	LocalOnly  bool      `json:"local_only,omitempty"`  // File exists only locally
+	RemoteOnly bool      `json:"remote_only,omitempty"` // File exists only remotely
+
+	// Reason says why pull would treat the file this way, e.g. "changed
+	// remotely since the last sync".
+	Reason string `json:"reason"`
+
+	// SettingsChanges lists the settings a pull would change, for settings
+	// files that would be overwritten or conflict. Only PreviewPull fills it.
+	SettingsChanges []claudesettings.Change `json:"settings_changes,omitempty"`
 }
 
-// PullPreview represents what would happen during a pull operation
+// PullPreview represents what would happen during a pull operation. Each
+// list is sorted by path.
 type PullPreview struct {
-	WouldDownload  []FilePreview // New remote files that would be downloaded
-	WouldOverwrite []FilePreview // Existing local files that would be replaced
-	WouldKeep      []FilePreview // Local files that would be kept (local newer)
-	WouldConflict  []FilePreview // Files that would create a conflict
-	LocalOnlyFiles []FilePreview // Files that exist only locally
+	WouldDownload  []FilePreview `json:"would_download"`   // New remote files that would be downloaded
+	WouldOverwrite []FilePreview `json:"would_overwrite"`  // Existing local files that would be replaced
+	WouldKeep      []FilePreview `json:"would_keep"`       // Local files that would be kept (local newer)
+	WouldConflict  []FilePreview `json:"would_conflict"`   // Files that would create a conflict
+	LocalOnlyFiles []FilePreview `json:"local_only_files"` // Files that exist only locally
+
+	ShadowedCommands []ShadowedCommand `json:"shadowed_commands,omitempty"` // Commands pull would skip
+
+	// DownloadBytes is the size of the remote files pull would write, new
+	// and overwriting; ReplacedBytes is the size of the local files they
+	// would replace.
+	DownloadBytes int64 `json:"download_bytes"`
+	ReplacedBytes int64 `json:"replaced_bytes"`
+
+	// PlanID identifies exactly these decisions about exactly these file
+	// versions. PullPlan executes the preview and nothing that changed
+	// since; see also SavePullPlan.
+	PlanID string `json:"plan_id"`
 }
 
 // PreviewPull returns a preview of what would happen during a pull operation
 // without actually making any changes
 func (s *Syncer) PreviewPull(ctx context.Context) (*PullPreview, error) {
-	preview := &PullPreview{}
-
 	// List all remote objects
-	remoteObjects, err := s.storage.List(ctx, "")
+	remoteObjects, err := s.listRemote(ctx)
+	if err != nil {
+		return nil, err
+	}
+	preview, err := s.previewPullFrom(remoteObjects)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list remote objects: %w", err)
+		return nil, err
 	}
+	s.addSettingsChanges(ctx, preview.WouldOverwrite)
+	s.addSettingsChanges(ctx, preview.WouldConflict)
+	return preview, nil
+}
+
+// previewPullFrom computes a pull preview against a given remote listing.
+func (s *Syncer) previewPullFrom(remoteObjects []storage.ObjectInfo) (*PullPreview, error) {
+	preview := &PullPreview{}
 
 	// Build remote file map
 	remoteFiles, _ := s.buildRemoteMap(remoteObjects)
@@ -703,75 +1523,52 @@ func (s *Syncer) PreviewPull(ctx context.Context) (*PullPreview, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get local files: %w", err)
 	}
+	preview.ShadowedCommands = s.dropShadowedCommands(remoteFiles, localFiles)
 
 	// Analyze each remote file
 	for localPath, remoteObj := range remoteFiles {
 		localInfo, localExists := localFiles[localPath]
-		stateFile := s.state.GetFile(localPath)
-
-		fp := FilePreview{
-			Path:       localPath,
-			RemoteTime: remoteObj.LastModified,
-			RemoteSize: remoteObj.Size,
-		}
-
-		if localExists {
-			fp.LocalTime = localInfo.ModTime()
-			fp.LocalSize = localInfo.Size()
-		}
-
-		if !localExists {
-			// New file from remote
-			fp.RemoteOnly = true
+		fp, action := s.previewFile(localPath, remoteObj, localInfo, localExists)
+		switch action {
+		case pullDownload:
 			preview.WouldDownload = append(preview.WouldDownload, fp)
-		} else if stateFile != nil {
-			// Check if remote is newer than our last known state
-			if remoteObj.LastModified.After(stateFile.Uploaded) {
-				// Remote was updated after we last uploaded
-				localHash, _ := HashFile(filepath.Join(s.claudeDir, localPath))
-				if localHash != stateFile.Hash {
-					// Conflict: both changed
-					preview.WouldConflict = append(preview.WouldConflict, fp)
-				} else {
-					// Only remote changed
-					preview.WouldOverwrite = append(preview.WouldOverwrite, fp)
-				}
-			} else {
-				// Local is current
-				preview.WouldKeep = append(preview.WouldKeep, fp)
-			}
-		} else {
-			// No state - compare timestamps
-			if localInfo.ModTime().Before(remoteObj.LastModified) {
-				preview.WouldOverwrite = append(preview.WouldOverwrite, fp)
-			} else {
-				preview.WouldKeep = append(preview.WouldKeep, fp)
-			}
+		case pullOverwrite:
+			preview.WouldOverwrite = append(preview.WouldOverwrite, fp)
+		case pullConflict:
+			preview.WouldConflict = append(preview.WouldConflict, fp)
+		default:
+			preview.WouldKeep = append(preview.WouldKeep, fp)
 		}
 	}
 
 	// Find local-only files
+	shadowed := make(map[string]bool, len(preview.ShadowedCommands))
+	for _, c := range preview.ShadowedCommands {
+		shadowed[c.Path] = true
+	}
 	for localPath, localInfo := range localFiles {
-		if _, exists := remoteFiles[localPath]; !exists {
+		if _, exists := remoteFiles[localPath]; !exists && !shadowed[localPath] {
 			preview.LocalOnlyFiles = append(preview.LocalOnlyFiles, FilePreview{
 				Path:      localPath,
 				LocalTime: localInfo.ModTime(),
 				LocalSize: localInfo.Size(),
 				LocalOnly: true,
+				Reason:    "not on the remote",
 			})
 		}
 	}
 
+	preview.finish()
 	return preview, nil
 }
 
 type DiffEntry struct {
-	Path       string
-	Status     string // "local_only", "remote_only", "modified", "synced"
-	LocalSize  int64
-	RemoteSize int64
-	LocalTime  time.Time
-	RemoteTime time.Time
+	Path       string    `json:"path"`
+	Status     string    `json:"status"` // "local_only", "remote_only", "modified", "synced"
+	LocalSize  int64     `json:"local_size"`
+	RemoteSize int64     `json:"remote_size"`
+	LocalTime  time.Time `json:"local_time,omitzero"`
+	RemoteTime time.Time `json:"remote_time,omitzero"`
 }
 
 func (s *Syncer) Diff(ctx context.Context) ([]DiffEntry, error) {
@@ -784,9 +1581,9 @@ func (s *Syncer) Diff(ctx context.Context) ([]DiffEntry, error) {
 	}
 
 	// Get remote files
-	remoteObjects, err := s.storage.List(ctx, "")
+	remoteObjects, err := s.listRemote(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list remote objects: %w", err)
+		return nil, err
 	}
 
 	remoteFiles, _ := s.buildRemoteMap(remoteObjects)
@@ -804,7 +1601,7 @@ func (s *Syncer) Diff(ctx context.Context) ([]DiffEntry, error) {
 		} else {
 			stateFile := s.state.GetFile(relPath)
 			if stateFile != nil {
-				localHash, _ := HashFile(filepath.Join(s.claudeDir, relPath))
+				localHash, _ := s.state.hashFile(filepath.Join(s.claudeDir, relPath))
 				if localHash != stateFile.Hash || remoteObj.LastModified.After(stateFile.Uploaded) {
 					entries = append(entries, DiffEntry{
 						Path:       relPath,
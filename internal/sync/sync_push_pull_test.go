@@ -1,10 +1,15 @@
 package sync
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
@@ -15,40 +20,60 @@ import (
 	"github.com/tawanorg/claude-sync/internal/storage"
 )
 
-// mockStorage implements storage.Storage in-memory for testing.
+// mockStorage implements storage.Storage in-memory for testing, with
+// conditional uploads.
 type mockStorage struct {
 	mu      sync.Mutex
 	objects map[string]mockObject
+	writes  int
 }
 
 type mockObject struct {
 	data         []byte
 	lastModified time.Time
+	etag         string
 }
 
 func newMockStorage() *mockStorage {
 	return &mockStorage{objects: make(map[string]mockObject)}
 }
 
-func (m *mockStorage) Upload(_ context.Context, key string, data []byte) error {
+func (m *mockStorage) Upload(ctx context.Context, key string, data []byte) error {
+	// Sent like an adapter's request body, progress and all
+	cp, err := io.ReadAll(storage.ProgressReader(ctx, bytes.NewReader(data), int64(len(data))))
+	if err != nil {
+		return err
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	cp := make([]byte, len(data))
-	copy(cp, data)
-	m.objects[key] = mockObject{data: cp, lastModified: time.Now()}
+	m.put(key, cp)
 	return nil
 }
 
-func (m *mockStorage) Download(_ context.Context, key string) ([]byte, error) {
+// put stores data under key with a new ETag. Callers hold m.mu.
+func (m *mockStorage) put(key string, data []byte) {
+	m.writes++
+	m.objects[key] = mockObject{data: data, lastModified: time.Now(), etag: fmt.Sprintf(`"%d"`, m.writes)}
+}
+
+func (m *mockStorage) UploadIfMatch(_ context.Context, key string, data []byte, etag string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if m.objects[key].etag != etag {
+		return fmt.Errorf("failed to upload %s: %w", key, storage.ErrPreconditionFailed)
+	}
+	m.put(key, append([]byte(nil), data...))
+	return nil
+}
+
+func (m *mockStorage) Download(ctx context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
 	obj, ok := m.objects[key]
+	m.mu.Unlock()
 	if !ok {
-		return nil, fmt.Errorf("object not found: %s", key)
+		return nil, fmt.Errorf("%w: %s", storage.ErrNotFound, key)
 	}
-	cp := make([]byte, len(obj.data))
-	copy(cp, obj.data)
-	return cp, nil
+	return io.ReadAll(storage.ProgressReader(ctx, bytes.NewReader(obj.data), int64(len(obj.data))))
 }
 
 func (m *mockStorage) Delete(_ context.Context, key string) error {
@@ -77,6 +102,7 @@ func (m *mockStorage) List(_ context.Context, prefix string) ([]storage.ObjectIn
 				Key:          key,
 				Size:         int64(len(obj.data)),
 				LastModified: obj.lastModified,
+				ETag:         obj.etag,
 			})
 		}
 	}
@@ -88,20 +114,25 @@ func (m *mockStorage) Head(_ context.Context, key string) (*storage.ObjectInfo,
 	defer m.mu.Unlock()
 	obj, ok := m.objects[key]
 	if !ok {
-		return nil, fmt.Errorf("object not found: %s", key)
+		return nil, fmt.Errorf("%w: %s", storage.ErrNotFound, key)
 	}
 	return &storage.ObjectInfo{
 		Key:          key,
 		Size:         int64(len(obj.data)),
 		LastModified: obj.lastModified,
+		ETag:         obj.etag,
 	}, nil
 }
 
+func (m *mockStorage) HeadBatch(ctx context.Context, keys []string) (map[string]*storage.ObjectInfo, error) {
+	return storage.HeadBatchByList(ctx, m, keys)
+}
+
 func (m *mockStorage) BucketExists(_ context.Context) (bool, error) {
 	return true, nil
 }
 
-// ListUserObjects returns objects excluding metadata (_metadata/) and external (_external/) files.
+// ListUserObjects returns objects excluding metadata (_metadata/), external (_external/) and version (_versions/) files.
 // Use this in tests to count only actual synced user files.
 func (m *mockStorage) ListUserObjects(ctx context.Context) ([]storage.ObjectInfo, error) {
 	objs, err := m.List(ctx, "")
@@ -110,7 +141,7 @@ func (m *mockStorage) ListUserObjects(ctx context.Context) ([]storage.ObjectInfo
 	}
 	var result []storage.ObjectInfo
 	for _, obj := range objs {
-		if strings.HasPrefix(obj.Key, "_metadata/") || strings.HasPrefix(obj.Key, "_external/") {
+		if isReservedKey(obj.Key) {
 			continue
 		}
 		result = append(result, obj)
@@ -394,6 +425,51 @@ func TestPullDetectsConflicts(t *testing.T) {
 	}
 }
 
+func TestPullReconcilesIdenticalConflict(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# V1")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	// The same edit lands locally and on another device
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# V2 everywhere")
+	encrypted, err := env.syncer.encryptor.Encrypt([]byte("# V2 everywhere"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := env.store.Upload(ctx, "CLAUDE.md.age", encrypted); err != nil {
+		t.Fatalf("Upload to mock failed: %v", err)
+	}
+
+	result, err := env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.Conflicts) != 0 {
+		t.Errorf("Expected identical content to reconcile, got conflicts: %v", result.Conflicts)
+	}
+
+	entries, _ := os.ReadDir(env.claudeDir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".conflict.") {
+			t.Errorf("Unexpected conflict file: %s", e.Name())
+		}
+	}
+
+	// State now matches the shared content, so nothing is left to push
+	changes, err := env.syncer.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected no pending changes after reconcile, got %v", changes)
+	}
+}
+
 func TestNoConflictWhenOnlyRemoteChanged(t *testing.T) {
 	env := setupTestEnv(t)
 	ctx := context.Background()
@@ -605,6 +681,78 @@ func TestPushNoChangesIsNoop(t *testing.T) {
 	}
 }
 
+func TestForcePushUploadsUnchangedFiles(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	writeFile(t, env.claudeDir, "agents/a.md", "agent a")
+	writeFile(t, env.claudeDir, "agents/b.md", "agent b")
+	pushOK(t, env)
+
+	// The remote lost one object and has garbage in another; state doesn't know
+	_ = env.store.Delete(ctx, "agents/a.md.age")
+	_ = env.store.Upload(ctx, "agents/b.md.age", []byte("garbage"))
+	writeFile(t, env.claudeDir, "CLAUDE.md", "new rules")
+
+	if result := pushOK(t, env); len(result.Uploaded) != 1 {
+		t.Fatalf("Plain push uploaded %v, want only CLAUDE.md", result.Uploaded)
+	}
+
+	env.syncer.SetForceUpload(true)
+	result := pushOK(t, env)
+	sort.Strings(result.Uploaded)
+	if want := []string{"CLAUDE.md", "agents/a.md", "agents/b.md"}; !reflect.DeepEqual(result.Uploaded, want) {
+		t.Errorf("Uploaded = %v, want %v", result.Uploaded, want)
+	}
+	for path, want := range map[string]string{"agents/a.md": "agent a", "agents/b.md": "agent b"} {
+		data, err := env.syncer.ReadRemote(ctx, path, 0)
+		if err != nil {
+			t.Fatalf("ReadRemote %s failed: %v", path, err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", path, data, want)
+		}
+	}
+}
+
+func TestPushSelected(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	writeFile(t, env.claudeDir, "agents/old.md", "old")
+	pushOK(t, env)
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "new rules")
+	writeFile(t, env.claudeDir, "agents/new.md", "new")
+	if err := os.Remove(filepath.Join(env.claudeDir, "agents", "old.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := env.syncer.PendingPush()
+	if err != nil {
+		t.Fatalf("PendingPush failed: %v", err)
+	}
+	if len(pending) != 3 {
+		t.Fatalf("PendingPush = %+v, want 3 changes", pending)
+	}
+
+	result, err := env.syncer.PushSelected(ctx, []string{"agents/new.md", "agents/old.md"})
+	if err != nil {
+		t.Fatalf("PushSelected failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Uploaded, []string{"agents/new.md"}) || !reflect.DeepEqual(result.Deleted, []string{"agents/old.md"}) {
+		t.Errorf("Uploaded %v and deleted %v", result.Uploaded, result.Deleted)
+	}
+	if !reflect.DeepEqual(result.Skipped, []string{"CLAUDE.md"}) {
+		t.Errorf("Skipped = %v, want [CLAUDE.md]", result.Skipped)
+	}
+
+	result = pushOK(t, env)
+	if !reflect.DeepEqual(result.Uploaded, []string{"CLAUDE.md"}) || len(result.Skipped) != 0 {
+		t.Errorf("Next push uploaded %v, skipped %v; want the held-back CLAUDE.md", result.Uploaded, result.Skipped)
+	}
+}
+
 func TestPullEmptyRemoteIsNoop(t *testing.T) {
 	env := setupTestEnv(t)
 	ctx := context.Background()
@@ -663,3 +811,161 @@ func TestPullSetsRestrictivePermissions(t *testing.T) {
 		t.Errorf("Expected dir mode 0700, got %o", got)
 	}
 }
+
+func TestStatRemote(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "agents/a.md", "aaa")
+	writeFile(t, env.claudeDir, "agents/b.md", "b")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	got, err := env.syncer.StatRemote(ctx, []string{"agents/a.md", "agents/b.md", "agents/missing.md"})
+	if err != nil {
+		t.Fatalf("StatRemote failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 remote entries, got %v", got)
+	}
+	if got["agents/a.md"] == nil || got["agents/a.md"].Key != "agents/a.md.age" {
+		t.Errorf("Unexpected entry for agents/a.md: %+v", got["agents/a.md"])
+	}
+	if _, ok := got["agents/missing.md"]; ok {
+		t.Error("Expected missing path to be absent")
+	}
+}
+
+func TestHostOverrideKeepsRemotePaths(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "agents/a.md", "v1")
+	writeFile(t, env.claudeDir, "CLAUDE.md", "v1")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	// This host stops syncing agents/ and clears it out locally
+	host, err := os.Hostname()
+	if err != nil {
+		t.Skip("no hostname")
+	}
+	env.syncer.cfg.Hosts = []config.HostOverride{{Host: host, RemovePaths: []string{"agents"}}}
+	if err := os.RemoveAll(filepath.Join(env.claudeDir, "agents")); err != nil {
+		t.Fatal(err)
+	}
+	uploadRemote(t, env, "agents/b.md", "from another device")
+
+	changes, err := env.syncer.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected no pending changes, got %+v", changes)
+	}
+	result, err := env.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if len(result.Deleted) != 0 {
+		t.Errorf("Push deleted %v, which this host doesn't sync", result.Deleted)
+	}
+	if _, ok := env.store.objects["agents/a.md.age"]; !ok {
+		t.Error("Remote copy of a path this host doesn't sync was deleted")
+	}
+
+	result, err = env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.Downloaded) != 0 {
+		t.Errorf("Pull fetched %v, which this host doesn't sync", result.Downloaded)
+	}
+}
+
+func TestProgressReportsBytes(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "agents/a.md", "aaaa")
+	writeFile(t, env.claudeDir, "agents/b.md", "bbbbbb")
+
+	var mu sync.Mutex
+	var finished []ProgressEvent
+	var last ProgressEvent
+	env.syncer.SetProgressFunc(func(event ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		if event.Finished {
+			finished = append(finished, event)
+		}
+		last = event
+	})
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if len(finished) != 2 {
+		t.Fatalf("Expected 2 finished uploads, got %+v", finished)
+	}
+	for _, e := range finished {
+		if e.TotalBytes != 10 {
+			t.Errorf("TotalBytes = %d, want 10", e.TotalBytes)
+		}
+	}
+	if got := max(finished[0].Bytes, finished[1].Bytes); got != 10 {
+		t.Errorf("Bytes after both uploads = %d, want 10", got)
+	}
+	if !last.Complete || last.Bytes != 10 {
+		t.Errorf("Final event = %+v, want complete with 10 bytes", last)
+	}
+}
+
+func TestProgressReportsBytesInFlight(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	// Random data doesn't compress, so its object spans several reports
+	data := make([]byte, 512*1024)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, env.claudeDir, "projects/big.jsonl", string(data))
+
+	for _, op := range []string{"upload", "download"} {
+		var inFlight []ProgressEvent
+		env.syncer.SetProgressFunc(func(event ProgressEvent) {
+			if event.InFlight {
+				inFlight = append(inFlight, event)
+			}
+		})
+		if op == "upload" {
+			pushOK(t, env)
+		} else {
+			if err := os.Remove(filepath.Join(env.claudeDir, "projects/big.jsonl")); err != nil {
+				t.Fatal(err)
+			}
+			env.syncer.state.RemoveFile("projects/big.jsonl")
+			if _, err := env.syncer.Pull(ctx); err != nil {
+				t.Fatalf("Pull failed: %v", err)
+			}
+		}
+
+		if len(inFlight) < 2 {
+			t.Fatalf("%s: expected several in-flight events, got %d", op, len(inFlight))
+		}
+		for i, e := range inFlight {
+			if e.Action != op || e.FileBytes > e.Size || e.Bytes != e.FileBytes {
+				t.Errorf("%s: event %+v out of line", op, e)
+			}
+			if i > 0 && e.FileBytes < inFlight[i-1].FileBytes {
+				t.Errorf("%s: FileBytes went back from %d to %d", op, inFlight[i-1].FileBytes, e.FileBytes)
+			}
+		}
+		if last := inFlight[len(inFlight)-1]; last.FileBytes != last.Size {
+			t.Errorf("%s: last in-flight event at %d of %d bytes", op, last.FileBytes, last.Size)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package sync
 
 import (
+	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -313,3 +315,30 @@ func TestSyncPathsConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestSyncResultJSON(t *testing.T) {
+	result := &SyncResult{
+		Uploaded:        []string{"CLAUDE.md"},
+		Errors:          []error{errors.New("upload agents/a.md: denied")},
+		InvalidSettings: []SettingsIssue{{Path: "settings.json", Err: errors.New("bad hook")}},
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if errs, _ := got["errors"].([]any); len(errs) != 1 || errs[0] != "upload agents/a.md: denied" {
+		t.Errorf("errors = %v, want the message", got["errors"])
+	}
+	if deleted, ok := got["deleted"].([]any); !ok || len(deleted) != 0 {
+		t.Errorf("deleted = %v, want []", got["deleted"])
+	}
+	issues, _ := got["invalid_settings"].([]any)
+	if len(issues) != 1 || issues[0].(map[string]any)["error"] != "bad hook" {
+		t.Errorf("invalid_settings = %v, want the error message", got["invalid_settings"])
+	}
+}
@@ -0,0 +1,285 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// TrashPrefix holds remote objects deleted by push or 'reset --remote' when
+// trash is enabled. Each one is kept at _trash/<deleted at>/<original key> until
+// it is restored or the trash is emptied.
+const TrashPrefix = "_trash/"
+
+// trashBatchLayout names the batch an object was trashed in. Everything moved
+// by one push or reset shares a batch.
+const trashBatchLayout = "20060102T150405Z"
+
+// TrashItem is an object in the remote trash.
+type TrashItem struct {
+	Path      string // Local relative path, or the original key if it can't be mapped
+	Key       string // Key in the trash
+	Original  string // Key it was deleted from
+	Batch     string
+	DeletedAt time.Time
+	Size      int64
+}
+
+func trashKey(original string, at time.Time) string {
+	return TrashPrefix + at.UTC().Format(trashBatchLayout) + "/" + original
+}
+
+// parseTrashKey splits a trash key into its batch and original key.
+func parseTrashKey(key string) (batch, original string, at time.Time, ok bool) {
+	rest, found := strings.CutPrefix(key, TrashPrefix)
+	if !found {
+		return "", "", time.Time{}, false
+	}
+	batch, original, found = strings.Cut(rest, "/")
+	if !found || original == "" {
+		return "", "", time.Time{}, false
+	}
+	at, err := time.Parse(trashBatchLayout, batch)
+	if err != nil {
+		return "", "", time.Time{}, false
+	}
+	return batch, original, at, true
+}
+
+// MoveToTrash copies each object into the trash, then deletes the originals
+// in one batch. Object stores have no rename, so the copy is a download and
+// re-upload of the encrypted blob; nothing is decrypted. Keys already in the
+// trash are left alone. It returns the keys that were moved: if any copy
+// fails, no original is deleted.
+func MoveToTrash(ctx context.Context, store storage.Storage, keys []string, at time.Time) ([]string, error) {
+	var toMove []string
+	for _, key := range keys {
+		if !strings.HasPrefix(key, TrashPrefix) {
+			toMove = append(toMove, key)
+		}
+	}
+	if len(toMove) == 0 {
+		return nil, nil
+	}
+
+	sem := make(chan struct{}, defaultWorkers)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	for _, key := range toMove {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			err := copyObject(ctx, store, key, trashKey(key, at))
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to move %s to trash: %w", key, err)
+				}
+				mu.Unlock()
+			}
+		}(key)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	if err := store.DeleteBatch(ctx, toMove); err != nil {
+		return nil, fmt.Errorf("batch delete: %w", err)
+	}
+	return toMove, nil
+}
+
+func copyObject(ctx context.Context, store storage.Storage, from, to string) error {
+	_, err := copyObjectHash(ctx, store, from, to)
+	return err
+}
+
+// copyObjectHash is copyObject, returning the hash of the copied object.
+func copyObjectHash(ctx context.Context, store storage.Storage, from, to string) (string, error) {
+	data, err := store.Download(ctx, from)
+	if err != nil {
+		return "", err
+	}
+	if err := store.Upload(ctx, to, data); err != nil {
+		return "", err
+	}
+	return sha256Hex(data), nil
+}
+
+// deleteRemote deletes objects removed by a push, moving them to the trash
+// when it is enabled. It returns the keys that are gone.
+func (s *Syncer) deleteRemote(ctx context.Context, keys []string) (map[string]bool, error) {
+	deleted := make(map[string]bool, len(keys))
+	if s.cfg.Trash {
+		moved, err := MoveToTrash(ctx, s.storage, keys, time.Now())
+		for _, key := range moved {
+			deleted[key] = true
+		}
+		return deleted, err
+	}
+
+	if err := s.storage.DeleteBatch(ctx, keys); err != nil {
+		return deleted, fmt.Errorf("batch delete: %w", err)
+	}
+	for _, key := range keys {
+		deleted[key] = true
+	}
+	return deleted, nil
+}
+
+// ListTrash returns everything in the remote trash, most recently deleted
+// first.
+func (s *Syncer) ListTrash(ctx context.Context) ([]TrashItem, error) {
+	objects, err := s.storage.List(ctx, TrashPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trash: %w", err)
+	}
+
+	var items []TrashItem
+	for _, obj := range objects {
+		batch, original, at, ok := parseTrashKey(obj.Key)
+		if !ok {
+			continue
+		}
+		path := strings.TrimSuffix(original, ".age")
+		if local, ok := s.localPath(original); ok && !isReservedKey(original) {
+			path = local
+		}
+		items = append(items, TrashItem{
+			Path:      path,
+			Key:       obj.Key,
+			Original:  original,
+			Batch:     batch,
+			DeletedAt: at,
+			Size:      obj.Size,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].DeletedAt.Equal(items[j].DeletedAt) {
+			return items[i].DeletedAt.After(items[j].DeletedAt)
+		}
+		return items[i].Path < items[j].Path
+	})
+	return items, nil
+}
+
+// MatchTrash picks the items a 'trash restore' argument refers to: a batch
+// ID, a file, or a directory. When a file was trashed more than once, only
+// its most recent deletion is picked. items must be sorted as ListTrash
+// returns them.
+func MatchTrash(items []TrashItem, ref string) []TrashItem {
+	ref = strings.TrimSuffix(ref, "/")
+	seen := make(map[string]bool)
+	var matched []TrashItem
+	for _, item := range items {
+		if item.Batch != ref && item.Path != ref && !strings.HasPrefix(item.Path, ref+"/") {
+			continue
+		}
+		if seen[item.Original] {
+			continue
+		}
+		seen[item.Original] = true
+		matched = append(matched, item)
+	}
+	return matched
+}
+
+// RestoreTrashResult reports what RestoreTrash did.
+type RestoreTrashResult struct {
+	Restored []TrashItem
+	Skipped  []TrashItem // The original key holds a newer object
+}
+
+// RestoreTrash moves items back to their original keys. An item whose
+// original key has been written again since it was deleted is skipped unless
+// overwrite is set. Local files are not touched: pull brings them back.
+func (s *Syncer) RestoreTrash(ctx context.Context, items []TrashItem, overwrite bool) (*RestoreTrashResult, error) {
+	result := &RestoreTrashResult{}
+	if len(items) == 0 {
+		return result, nil
+	}
+
+	existing := make(map[string]*storage.ObjectInfo)
+	if !overwrite {
+		originals := make([]string, len(items))
+		for i, item := range items {
+			originals[i] = item.Original
+		}
+		var err error
+		existing, err = s.storage.HeadBatch(ctx, originals)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check restore targets: %w", err)
+		}
+	}
+
+	var restoredKeys []string
+	hashes := make(map[string]string)
+	for _, item := range items {
+		if existing[item.Original] != nil {
+			result.Skipped = append(result.Skipped, item)
+			continue
+		}
+		hash, err := copyObjectHash(ctx, s.storage, item.Key, item.Original)
+		if err != nil {
+			return result, fmt.Errorf("failed to restore %s: %w", item.Path, err)
+		}
+		result.Restored = append(result.Restored, item)
+		restoredKeys = append(restoredKeys, item.Key)
+		if path, ok := s.localPath(item.Original); ok {
+			hashes[path] = hash
+		}
+	}
+
+	// A signed manifest must cover the restored files for them to be pulled
+	if s.cfg.SignManifest && len(hashes) > 0 {
+		if err := s.patchManifest(ctx, hashes); err != nil {
+			return result, err
+		}
+	}
+
+	if len(restoredKeys) > 0 {
+		if err := s.storage.DeleteBatch(ctx, restoredKeys); err != nil {
+			return result, fmt.Errorf("failed to remove restored files from trash: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// EmptyTrash permanently deletes trashed objects deleted more than olderThan
+// ago, or all of them when olderThan is zero. With dryRun, nothing is deleted.
+func (s *Syncer) EmptyTrash(ctx context.Context, olderThan time.Duration, dryRun bool) ([]TrashItem, error) {
+	items, err := s.ListTrash(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var expired []TrashItem
+	for _, item := range items {
+		if olderThan == 0 || item.DeletedAt.Before(cutoff) {
+			expired = append(expired, item)
+		}
+	}
+	if dryRun || len(expired) == 0 {
+		return expired, nil
+	}
+
+	keys := make([]string, len(expired))
+	for i, item := range expired {
+		keys[i] = item.Key
+	}
+	if err := s.storage.DeleteBatch(ctx, keys); err != nil {
+		return nil, fmt.Errorf("failed to empty trash: %w", err)
+	}
+	return expired, nil
+}
@@ -0,0 +1,184 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPushMovesDeletesToTrash(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Trash = true
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "agents/a.md", "agent a")
+	writeFile(t, env.claudeDir, "agents/b.md", "agent b")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(env.claudeDir, "agents/a.md")); err != nil {
+		t.Fatal(err)
+	}
+	result, err := env.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if len(result.Deleted) != 1 {
+		t.Fatalf("Expected 1 deletion, got %v (errors: %v)", result.Deleted, result.Errors)
+	}
+	if _, ok := env.store.objects["agents/a.md.age"]; ok {
+		t.Error("Expected original object to be gone")
+	}
+
+	items, err := env.syncer.ListTrash(ctx)
+	if err != nil {
+		t.Fatalf("ListTrash failed: %v", err)
+	}
+	if len(items) != 1 || items[0].Path != "agents/a.md" || items[0].Original != "agents/a.md.age" {
+		t.Fatalf("Unexpected trash: %+v", items)
+	}
+	if !strings.HasPrefix(items[0].Key, TrashPrefix+items[0].Batch+"/") {
+		t.Errorf("Unexpected trash key %q", items[0].Key)
+	}
+
+	// Trash is claude-sync's own data: pull must not bring it back
+	preview, err := env.syncer.PreviewPull(ctx)
+	if err != nil {
+		t.Fatalf("PreviewPull failed: %v", err)
+	}
+	for _, f := range preview.WouldDownload {
+		if strings.HasPrefix(f.Path, TrashPrefix) {
+			t.Errorf("Pull would download trashed %s", f.Path)
+		}
+	}
+
+	restored, err := env.syncer.RestoreTrash(ctx, MatchTrash(items, "agents"), false)
+	if err != nil {
+		t.Fatalf("RestoreTrash failed: %v", err)
+	}
+	if len(restored.Restored) != 1 || len(restored.Skipped) != 0 {
+		t.Fatalf("Unexpected restore result: %+v", restored)
+	}
+	if _, ok := env.store.objects[items[0].Key]; ok {
+		t.Error("Expected restored object to leave the trash")
+	}
+
+	if _, err := env.syncer.Pull(ctx); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if got := readFile(t, env.claudeDir, "agents/a.md"); got != "agent a" {
+		t.Errorf("Expected restored content, got %q", got)
+	}
+}
+
+func TestPushDeletesWithoutTrashByDefault(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "x")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if err := os.Remove(filepath.Join(env.claudeDir, "CLAUDE.md")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	items, err := env.syncer.ListTrash(ctx)
+	if err != nil {
+		t.Fatalf("ListTrash failed: %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("Expected empty trash, got %+v", items)
+	}
+}
+
+func TestRestoreTrashSkipsRepushed(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	old := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	env.store.objects["CLAUDE.md.age"] = mockObject{data: []byte("old")}
+	if _, err := MoveToTrash(ctx, env.store, []string{"CLAUDE.md.age"}, old); err != nil {
+		t.Fatalf("MoveToTrash failed: %v", err)
+	}
+	env.store.objects["CLAUDE.md.age"] = mockObject{data: []byte("new")}
+
+	items, err := env.syncer.ListTrash(ctx)
+	if err != nil {
+		t.Fatalf("ListTrash failed: %v", err)
+	}
+	result, err := env.syncer.RestoreTrash(ctx, items, false)
+	if err != nil {
+		t.Fatalf("RestoreTrash failed: %v", err)
+	}
+	if len(result.Skipped) != 1 || len(result.Restored) != 0 {
+		t.Fatalf("Expected the re-pushed file to be skipped, got %+v", result)
+	}
+	if got := string(env.store.objects["CLAUDE.md.age"].data); got != "new" {
+		t.Errorf("Expected newer object to be kept, got %q", got)
+	}
+
+	if _, err := env.syncer.RestoreTrash(ctx, items, true); err != nil {
+		t.Fatalf("RestoreTrash with overwrite failed: %v", err)
+	}
+	if got := string(env.store.objects["CLAUDE.md.age"].data); got != "old" {
+		t.Errorf("Expected overwrite to restore trashed object, got %q", got)
+	}
+}
+
+func TestEmptyTrash(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	env.store.objects["agents/old.md.age"] = mockObject{data: []byte("a")}
+	env.store.objects["agents/new.md.age"] = mockObject{data: []byte("b")}
+	if _, err := MoveToTrash(ctx, env.store, []string{"agents/old.md.age"}, time.Now().Add(-40*24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MoveToTrash(ctx, env.store, []string{"agents/new.md.age"}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := env.syncer.EmptyTrash(ctx, 30*24*time.Hour, true)
+	if err != nil {
+		t.Fatalf("EmptyTrash dry run failed: %v", err)
+	}
+	if len(expired) != 1 || expired[0].Path != "agents/old.md" {
+		t.Fatalf("Expected only the old file, got %+v", expired)
+	}
+	if items, _ := env.syncer.ListTrash(ctx); len(items) != 2 {
+		t.Errorf("Dry run deleted files: %d left", len(items))
+	}
+
+	if _, err := env.syncer.EmptyTrash(ctx, 0, false); err != nil {
+		t.Fatalf("EmptyTrash failed: %v", err)
+	}
+	if items, _ := env.syncer.ListTrash(ctx); len(items) != 0 {
+		t.Errorf("Expected empty trash, got %+v", items)
+	}
+}
+
+func TestMatchTrash(t *testing.T) {
+	items := []TrashItem{
+		{Path: "agents/a.md", Original: "agents/a.md.age", Batch: "20260302T000000Z"},
+		{Path: "agents/b.md", Original: "agents/b.md.age", Batch: "20260302T000000Z"},
+		{Path: "agents/a.md", Original: "agents/a.md.age", Batch: "20260301T000000Z"},
+		{Path: "agents-old/c.md", Original: "agents-old/c.md.age", Batch: "20260301T000000Z"},
+	}
+
+	if got := MatchTrash(items, "agents/"); len(got) != 2 || got[0].Batch != "20260302T000000Z" {
+		t.Errorf("Directory match: %+v", got)
+	}
+	if got := MatchTrash(items, "20260301T000000Z"); len(got) != 2 {
+		t.Errorf("Batch match: %+v", got)
+	}
+	if got := MatchTrash(items, "agents/a.md"); len(got) != 1 || got[0].Batch != "20260302T000000Z" {
+		t.Errorf("Expected newest deletion of a.md, got %+v", got)
+	}
+}
@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// updateAttempts bounds how often updateJSON starts over after another
+// device wrote the object in between.
+const updateAttempts = 5
+
+// downloadJSONVersion is downloadJSON that also returns the object's ETag,
+// for uploadJSONIfMatch. found is false, with no error, when there is no
+// object.
+func (s *Syncer) downloadJSONVersion(ctx context.Context, key string, v interface{}) (etag string, found bool, err error) {
+	info, err := s.storage.Head(ctx, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	// Should the object change before the download, the ETag is older than
+	// what was read, and the conditional upload fails as it should
+	if err := s.downloadJSON(ctx, key, v); err != nil {
+		return "", true, err
+	}
+	return info.ETag, true, nil
+}
+
+// uploadJSONIfMatch is uploadJSON on the condition that the object is
+// still the one with etag, or that there is none when found is false. It
+// returns an error wrapping storage.ErrPreconditionFailed if the object
+// changed. Stores that can't upload conditionally, or report no ETag,
+// upload plainly.
+func (s *Syncer) uploadJSONIfMatch(ctx context.Context, key string, v interface{}, etag string, found bool) error {
+	encrypted, err := s.encodeJSON(v)
+	if err != nil {
+		return err
+	}
+	if found && etag == "" {
+		return s.storage.Upload(ctx, key, encrypted)
+	}
+	err = storage.UploadIfMatch(ctx, s.storage, key, encrypted, etag)
+	if errors.Is(err, storage.ErrConditionalUnsupported) {
+		return s.storage.Upload(ctx, key, encrypted)
+	}
+	return err
+}
+
+// updateJSON rewrites the encrypted JSON object at key, which other devices
+// may be rewriting at the same time. update gets the stored value, or a
+// zero one with found false, and changes it in place. A write that lost a
+// race with another device's starts over from a fresh read, so neither
+// device's change is lost; on stores without conditional uploads the last
+// write wins.
+func updateJSON[T any](ctx context.Context, s *Syncer, key string, update func(v *T, found bool) error) error {
+	for attempt := 1; ; attempt++ {
+		var v T
+		etag, found, err := s.downloadJSONVersion(ctx, key, &v)
+		if err != nil {
+			return err
+		}
+		if err := update(&v, found); err != nil {
+			return err
+		}
+		err = s.uploadJSONIfMatch(ctx, key, v, etag, found)
+		if errors.Is(err, storage.ErrPreconditionFailed) && attempt < updateAttempts {
+			continue
+		}
+		return err
+	}
+}
@@ -0,0 +1,141 @@
+package sync
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+func TestVerifyIntegrity(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	writeFile(t, env.claudeDir, "agents/a.md", "agent a")
+	writeFile(t, env.claudeDir, "agents/b.md", "agent b")
+	writeFile(t, env.claudeDir, "agents/c.md", "agent c")
+	pushOK(t, env)
+
+	report, err := env.syncer.VerifyIntegrity(ctx, true, nil)
+	if err != nil {
+		t.Fatalf("VerifyIntegrity failed: %v", err)
+	}
+	if report.Files != 4 || len(report.Problems) != 0 || len(report.Strays) != 0 {
+		t.Fatalf("Clean bucket: %d files, problems %+v, strays %+v", report.Files, report.Problems, report.Strays)
+	}
+	if report.Downloaded == 0 {
+		t.Error("Deep check downloaded nothing")
+	}
+
+	_ = env.store.Delete(ctx, "CLAUDE.md.age")
+	_ = env.store.Upload(ctx, "agents/a.md.age", []byte("not an age file"))
+	_ = env.store.Upload(ctx, "agents/b.md.age", nil)
+	_ = env.store.Upload(ctx, "notes.txt", []byte("dropped in by hand"))
+	encrypted, err := env.syncer.encryptor.Encrypt([]byte("nobody tracks this"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = env.store.Upload(ctx, "agents/orphan.md.age", encrypted)
+
+	report, err = env.syncer.VerifyIntegrity(ctx, false, nil)
+	if err != nil {
+		t.Fatalf("VerifyIntegrity failed: %v", err)
+	}
+	if got := issueKeys(report.Problems); !reflect.DeepEqual(got, []string{"CLAUDE.md.age", "agents/b.md.age"}) {
+		t.Errorf("Problems = %+v, want the missing and the empty object", report.Problems)
+	}
+	if got := issueKeys(report.Strays); !reflect.DeepEqual(got, []string{"agents/orphan.md.age", "notes.txt"}) {
+		t.Errorf("Strays = %+v", report.Strays)
+	}
+	if report.Downloaded != 0 {
+		t.Errorf("Shallow check downloaded %d bytes", report.Downloaded)
+	}
+
+	report, err = env.syncer.VerifyIntegrity(ctx, true, nil)
+	if err != nil {
+		t.Fatalf("VerifyIntegrity failed: %v", err)
+	}
+	if got := issueKeys(report.Problems); !reflect.DeepEqual(got, []string{"CLAUDE.md.age", "agents/a.md.age", "agents/b.md.age"}) {
+		t.Errorf("Deep problems = %+v, want the corrupt object too", report.Problems)
+	}
+}
+
+func TestVerifyIntegrityPaths(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	writeFile(t, env.claudeDir, "CLAUDE.md", "rules")
+	writeFile(t, env.claudeDir, "agents/a.md", "agent a")
+	writeFile(t, env.claudeDir, "agents/b.md", "agent b")
+	pushOK(t, env)
+
+	_ = env.store.Delete(ctx, "CLAUDE.md.age")
+	_ = env.store.Upload(ctx, "agents/a.md.age", []byte("not an age file"))
+	_ = env.store.Upload(ctx, "notes.txt", []byte("dropped in by hand"))
+
+	report, err := env.syncer.VerifyIntegrity(ctx, true, []string{"CLAUDE.md", "agents/b.md"})
+	if err != nil {
+		t.Fatalf("VerifyIntegrity failed: %v", err)
+	}
+	if report.Files != 1 || len(report.Strays) != 0 {
+		t.Errorf("Expected just agents/b.md checked and no strays, got %d files, strays %+v", report.Files, report.Strays)
+	}
+	if got := issueKeys(report.Problems); !reflect.DeepEqual(got, []string{"CLAUDE.md.age"}) {
+		t.Errorf("Problems = %+v, want only the missing CLAUDE.md", report.Problems)
+	}
+}
+
+func TestVerifyObjectContentHash(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+	writeFile(t, env.claudeDir, "agents/a.md", "agent a")
+	pushOK(t, env)
+
+	// An object re-encrypted by someone holding the key passes the object
+	// check when no object hash is recorded, but not the content check
+	compressed, err := gzipCompress([]byte("swapped"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := env.syncer.encryptor.Encrypt(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = env.store.Upload(ctx, "agents/a.md.age", encrypted)
+
+	want := FileMetadata{Hash: env.syncer.state.GetFile("agents/a.md").Hash}
+	obj, err := env.store.Head(ctx, "agents/a.md.age")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problem := env.syncer.verifyObject(ctx, *obj, "agents/a.md", want); problem != "decrypted content doesn't match its recorded hash" {
+		t.Errorf("verifyObject = %q", problem)
+	}
+
+	want.Hash = env.syncer.state.hashBytes([]byte("swapped"))
+	if problem := env.syncer.verifyObject(ctx, *obj, "agents/a.md", want); problem != "" {
+		t.Errorf("verifyObject = %q, want no problem", problem)
+	}
+}
+
+func issueKeys(issues []IntegrityIssue) []string {
+	keys := make([]string, len(issues))
+	for i, issue := range issues {
+		keys[i] = issue.Key
+	}
+	return keys
+}
+
+func TestMD5ETag(t *testing.T) {
+	if etag, ok := md5ETag(storage.ObjectInfo{ETag: `"9E107D9D372BB6826BD81D3542A419D6"`, MD5ETag: true}); !ok || etag != "9e107d9d372bb6826bd81d3542a419d6" {
+		t.Errorf("md5ETag = %q, %v", etag, ok)
+	}
+	if _, ok := md5ETag(storage.ObjectInfo{ETag: `"9e107d9d372bb6826bd81d3542a419d6-2"`, MD5ETag: true}); ok {
+		t.Error("Multipart ETag taken for an MD5")
+	}
+
+	// An S3 listing, WebDAV, or SSE-KMS and SSE-C objects
+	if _, ok := md5ETag(storage.ObjectInfo{ETag: "9e107d9d372bb6826bd81d3542a419d6"}); ok {
+		t.Error("ETag taken for an MD5 without the adapter vouching for it")
+	}
+}
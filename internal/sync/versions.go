@@ -0,0 +1,137 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/storage"
+)
+
+// VersionPrefix is the remote prefix holding previous copies of pushed files.
+// With versioning enabled, every upload also writes its encrypted blob to
+// _versions/<path>/<timestamp>-<device>.age, so a bad push can be undone.
+const VersionPrefix = "_versions/"
+
+// versionIDLayout is the timestamp part of a version name. Milliseconds keep
+// two pushes from the same device within a second apart, and it sorts
+// lexically in chronological order.
+const versionIDLayout = "20060102T150405.000Z"
+
+// Version is one stored copy of a file.
+type Version struct {
+	Path      string // Local relative path of the file
+	Key       string // Remote key of this copy
+	CreatedAt time.Time
+	Device    string
+	Size      int64
+}
+
+// reservedPrefixes are remote prefixes that hold claude-sync's own data rather
+// than files under ~/.claude.
+var reservedPrefixes = []string{"_external/", "_metadata/", VersionPrefix, LeasePrefix, TrashPrefix, ProposalPrefix, AttestationPrefix}
+
+// isReservedKey reports whether a remote key belongs to claude-sync itself.
+func isReservedKey(key string) bool {
+	for _, prefix := range reservedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// versionDir is the remote prefix under which a file's versions are stored.
+func (s *Syncer) versionDir(relativePath string) string {
+	return VersionPrefix + strings.TrimSuffix(s.remoteKey(relativePath), ".age") + "/"
+}
+
+// versionKey is the remote key for the copy of a file pushed at t.
+func (s *Syncer) versionKey(relativePath string, t time.Time) string {
+	return s.versionDir(relativePath) + t.UTC().Format(versionIDLayout) + "-" + safeDeviceName(s.state.DeviceID) + ".age"
+}
+
+// parseVersionName extracts the push time and device from the last segment
+// of a version key, reporting false for anything else.
+func parseVersionName(name string) (time.Time, string, bool) {
+	if !strings.HasSuffix(name, ".age") {
+		return time.Time{}, "", false
+	}
+	name = strings.TrimSuffix(name, ".age")
+	if len(name) < len(versionIDLayout) {
+		return time.Time{}, "", false
+	}
+	created, err := time.Parse(versionIDLayout, name[:len(versionIDLayout)])
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return created, strings.TrimPrefix(name[len(versionIDLayout):], "-"), true
+}
+
+// ListVersions returns the stored versions of a file, newest first.
+func (s *Syncer) ListVersions(ctx context.Context, relativePath string) ([]Version, error) {
+	dir := s.versionDir(relativePath)
+	objects, err := s.storage.List(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions: %w", err)
+	}
+	return versionsIn(objects, dir, relativePath), nil
+}
+
+// versionsIn picks the versions of one file out of a listing, newest first.
+func versionsIn(objects []storage.ObjectInfo, dir, relativePath string) []Version {
+	var versions []Version
+	for _, obj := range objects {
+		if !strings.HasPrefix(obj.Key, dir) {
+			continue
+		}
+		name := strings.TrimPrefix(obj.Key, dir)
+		if strings.Contains(name, "/") {
+			continue // Versions of a file nested under this path
+		}
+		created, device, ok := parseVersionName(name)
+		if !ok {
+			continue
+		}
+		versions = append(versions, Version{
+			Path:      relativePath,
+			Key:       obj.Key,
+			CreatedAt: created,
+			Device:    device,
+			Size:      obj.Size,
+		})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Key > versions[j].Key })
+	return versions
+}
+
+// FetchVersion downloads and decrypts a stored version of a file.
+func (s *Syncer) FetchVersion(ctx context.Context, v Version) ([]byte, error) {
+	data, err := s.fetchFile(ctx, v.Path, v.Key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("version %s: %w", v.Key, err)
+	}
+	return data, nil
+}
+
+// VersionDetail is a stored version with facts that need its content.
+type VersionDetail struct {
+	Version
+	Hash        string // SHA-256 of the content, as recorded in state
+	ContentSize int64
+}
+
+// DescribeVersions downloads each version to report its size and hash.
+func (s *Syncer) DescribeVersions(ctx context.Context, versions []Version) ([]VersionDetail, error) {
+	details := make([]VersionDetail, len(versions))
+	for i, v := range versions {
+		data, err := s.FetchVersion(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		details[i] = VersionDetail{Version: v, Hash: s.state.hashBytes(data), ContentSize: int64(len(data))}
+	}
+	return details, nil
+}
@@ -0,0 +1,125 @@
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVersionsKeptOnPush(t *testing.T) {
+	env := setupTestEnv(t)
+	env.syncer.cfg.Versioning = true
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "settings.json", `{"v":1}`)
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond) // Distinct version timestamps
+	writeFile(t, env.claudeDir, "settings.json", `{"v":2`)
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	versions, err := env.syncer.ListVersions(ctx, "settings.json")
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Fatalf("Expected 2 versions, got %d", len(versions))
+	}
+	if !versions[0].CreatedAt.After(versions[1].CreatedAt) {
+		t.Errorf("Expected newest first, got %v then %v", versions[0].CreatedAt, versions[1].CreatedAt)
+	}
+	if versions[0].Device == "" {
+		t.Error("Expected version to record the pushing device")
+	}
+
+	// The corrupted push can be undone from the older version
+	data, err := env.syncer.FetchVersion(ctx, versions[1])
+	if err != nil {
+		t.Fatalf("FetchVersion failed: %v", err)
+	}
+	if string(data) != `{"v":1}` {
+		t.Errorf("Expected first version content, got %q", data)
+	}
+
+	details, err := env.syncer.DescribeVersions(ctx, versions)
+	if err != nil {
+		t.Fatalf("DescribeVersions failed: %v", err)
+	}
+	if details[1].Hash != hashBytes([]byte(`{"v":1}`)) || details[1].ContentSize != 7 {
+		t.Errorf("Expected hash and size of first version, got %+v", details[1])
+	}
+	localHash, _ := HashFile(filepath.Join(env.claudeDir, "settings.json"))
+	if details[0].Hash != localHash {
+		t.Errorf("Expected latest version to match the local file")
+	}
+
+	// Versions are not user files
+	objects, _ := env.store.ListUserObjects(ctx)
+	if len(objects) != 1 {
+		t.Errorf("Expected 1 user object, got %d", len(objects))
+	}
+	preview, err := env.syncer.PreviewPull(ctx)
+	if err != nil {
+		t.Fatalf("PreviewPull failed: %v", err)
+	}
+	for _, p := range preview.WouldDownload {
+		if strings.HasPrefix(p.Path, VersionPrefix) {
+			t.Errorf("Pull would download version %s", p.Path)
+		}
+	}
+}
+
+func TestVersionsDisabledByDefault(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	writeFile(t, env.claudeDir, "CLAUDE.md", "# Hello")
+	if _, err := env.syncer.Push(ctx); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	versions, err := env.syncer.ListVersions(ctx, "CLAUDE.md")
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != 0 {
+		t.Errorf("Expected no versions without versioning, got %d", len(versions))
+	}
+}
+
+func TestParseVersionName(t *testing.T) {
+	created, device, ok := parseVersionName("20260102T030405.678Z-my-laptop.age")
+	if !ok {
+		t.Fatal("Expected version name to parse")
+	}
+	if want := time.Date(2026, 1, 2, 3, 4, 5, 678e6, time.UTC); !created.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, created)
+	}
+	if device != "my-laptop" {
+		t.Errorf("Expected device my-laptop, got %q", device)
+	}
+
+	for _, name := range []string{"settings.json.age", "20260102T030405.678Z-x", "nope.age"} {
+		if _, _, ok := parseVersionName(name); ok {
+			t.Errorf("Expected %q not to parse", name)
+		}
+	}
+}
+
+func TestIsReservedKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"_metadata/manifest.json":                      true,
+		"_external/mcp-servers.json.age":               true,
+		"_versions/CLAUDE.md/20260102T030405.678Z.age": true,
+		"CLAUDE.md.age":                                false,
+		"agents/_versions/x.md.age":                    false,
+	} {
+		if got := isReservedKey(key); got != want {
+			t.Errorf("isReservedKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
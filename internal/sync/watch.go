@@ -0,0 +1,135 @@
+package sync
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+)
+
+// fileStamp is what a Watcher compares between scans.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// Watcher finds local changes by scanning the sync paths for files whose
+// size or modification time moved since the previous scan. Polling needs no
+// platform support, and a file still being written shows up as changed
+// again at every scan until it's done.
+type Watcher struct {
+	claudeDir  string
+	syncPaths  []string
+	isExcluded func(string) bool
+	last       map[string]fileStamp
+}
+
+// NewWatcher watches the files cfg syncs.
+func NewWatcher(cfg *config.Config) *Watcher {
+	claudeDir := config.ClaudeDir()
+	if cfg.ClaudeDirOverride != "" {
+		claudeDir = cfg.ClaudeDirOverride
+	}
+	return &Watcher{claudeDir: claudeDir, syncPaths: cfg.ScopeSyncPaths(), isExcluded: cfg.IsExcluded}
+}
+
+// Scan returns the paths added, changed or removed since the previous scan,
+// sorted. The first scan only records what's there.
+func (w *Watcher) Scan() ([]string, error) {
+	files, err := GetLocalFiles(w.claudeDir, w.syncPaths, w.isExcluded)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]fileStamp, len(files))
+	for relPath, info := range files {
+		current[relPath] = stampOf(info)
+	}
+
+	var changed []string
+	if w.last != nil {
+		for relPath, stamp := range current {
+			if prev, ok := w.last[relPath]; !ok || prev.size != stamp.size || !prev.modTime.Equal(stamp.modTime) {
+				changed = append(changed, relPath)
+			}
+		}
+		for relPath := range w.last {
+			if _, ok := current[relPath]; !ok {
+				changed = append(changed, relPath)
+			}
+		}
+	}
+	w.last = current
+	sort.Strings(changed)
+	return changed, nil
+}
+
+func stampOf(info os.FileInfo) fileStamp {
+	return fileStamp{size: info.Size(), modTime: info.ModTime()}
+}
+
+// PushBatch coalesces the changes a Watcher reports into pushes. A burst of
+// writes becomes one push once no file in it has changed for debounce. A
+// burst that never pauses is pushed every maxDelay anyway, leaving out the
+// files that changed within debounce: they're probably still being written,
+// and go in a later batch.
+type PushBatch struct {
+	debounce time.Duration
+	maxDelay time.Duration
+	changed  map[string]time.Time // Path → when last seen changing
+	since    time.Time            // When the batch's oldest change was seen
+}
+
+// NewPushBatch returns an empty batch.
+func NewPushBatch(debounce, maxDelay time.Duration) *PushBatch {
+	return &PushBatch{debounce: debounce, maxDelay: maxDelay, changed: make(map[string]time.Time)}
+}
+
+// Add records paths as changing at now.
+func (b *PushBatch) Add(paths []string, now time.Time) {
+	if len(paths) > 0 && len(b.changed) == 0 {
+		b.since = now
+	}
+	for _, path := range paths {
+		b.changed[path] = now
+	}
+}
+
+// Take returns the paths to push at now, sorted, and removes them from the
+// batch; nil while the batch should wait.
+func (b *PushBatch) Take(now time.Time) []string {
+	if len(b.changed) == 0 {
+		return nil
+	}
+	var latest time.Time
+	for _, t := range b.changed {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	if now.Sub(latest) < b.debounce && now.Sub(b.since) < b.maxDelay {
+		return nil
+	}
+
+	var paths []string
+	var oldest time.Time
+	for path, t := range b.changed {
+		if now.Sub(t) >= b.debounce {
+			paths = append(paths, path)
+			delete(b.changed, path)
+		} else if oldest.IsZero() || t.Before(oldest) {
+			oldest = t
+		}
+	}
+	// What's still being written starts the next batch
+	if len(paths) > 0 {
+		b.since = oldest
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Clear empties the batch, after a push of everything.
+func (b *PushBatch) Clear() {
+	b.changed = make(map[string]time.Time)
+}
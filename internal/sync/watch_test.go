@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+)
+
+func TestWatcherScan(t *testing.T) {
+	claudeDir := t.TempDir()
+	writeFile(t, claudeDir, "CLAUDE.md", "rules")
+	writeFile(t, claudeDir, "agents/a.md", "agent a")
+	w := NewWatcher(&config.Config{ClaudeDirOverride: claudeDir})
+
+	scan := func() []string {
+		t.Helper()
+		changed, err := w.Scan()
+		if err != nil {
+			t.Fatalf("Scan failed: %v", err)
+		}
+		return changed
+	}
+	if changed := scan(); changed != nil {
+		t.Errorf("First scan = %v, want nothing", changed)
+	}
+
+	writeFile(t, claudeDir, "CLAUDE.md", "more rules")
+	writeFile(t, claudeDir, "agents/b.md", "agent b")
+	if err := os.Remove(filepath.Join(claudeDir, "agents/a.md")); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"CLAUDE.md", "agents/a.md", "agents/b.md"}
+	if changed := scan(); !reflect.DeepEqual(changed, want) {
+		t.Errorf("Scan = %v, want %v", changed, want)
+	}
+	if changed := scan(); changed != nil {
+		t.Errorf("Scan with no writes = %v", changed)
+	}
+}
+
+func TestPushBatch(t *testing.T) {
+	start := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+	b := NewPushBatch(5*time.Second, 30*time.Second)
+
+	// A burst of writes waits until it has been quiet for the debounce
+	b.Add([]string{"a.jsonl"}, at(0))
+	b.Add([]string{"a.jsonl", "b.jsonl"}, at(3))
+	if got := b.Take(at(7)); got != nil {
+		t.Errorf("Take during the burst = %v", got)
+	}
+	if got, want := b.Take(at(8)), []string{"a.jsonl", "b.jsonl"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Take after the burst = %v, want %v", got, want)
+	}
+	if got := b.Take(at(20)); got != nil {
+		t.Errorf("Take of an empty batch = %v", got)
+	}
+
+	// A burst that never pauses is pushed at the max delay, without the
+	// file still being written
+	for s := 40; s <= 70; s += 2 {
+		b.Add([]string{"live.jsonl"}, at(s))
+	}
+	b.Add([]string{"done.jsonl"}, at(41))
+	if got, want := b.Take(at(70)), []string{"done.jsonl"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Take at the max delay = %v, want %v", got, want)
+	}
+	if got, want := b.Take(at(75)), []string{"live.jsonl"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Take once writing stopped = %v, want %v", got, want)
+	}
+}
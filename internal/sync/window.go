@@ -0,0 +1,121 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+)
+
+// syncWindow is a parsed sync_windows entry.
+type syncWindow struct {
+	paths      []string
+	start, end int // Minutes after local midnight; end < start spans midnight
+}
+
+// CheckSyncWindows reports the first invalid sync_windows entry, as
+// NewSyncer would.
+func CheckSyncWindows(cfgs []config.SyncWindow) error {
+	_, err := parseSyncWindows(cfgs)
+	return err
+}
+
+func parseSyncWindows(cfgs []config.SyncWindow) ([]syncWindow, error) {
+	var windows []syncWindow
+	for _, cfg := range cfgs {
+		if len(cfg.Paths) == 0 {
+			return nil, fmt.Errorf("sync window %s-%s lists no paths", cfg.Start, cfg.End)
+		}
+		start, err := parseClock(cfg.Start)
+		if err != nil {
+			return nil, fmt.Errorf("sync window start: %w", err)
+		}
+		end, err := parseClock(cfg.End)
+		if err != nil {
+			return nil, fmt.Errorf("sync window end: %w", err)
+		}
+		if start == end {
+			return nil, fmt.Errorf("sync window %s-%s is empty", cfg.Start, cfg.End)
+		}
+		w := syncWindow{start: start, end: end}
+		for _, path := range cfg.Paths {
+			w.paths = append(w.paths, cleanPausePath(path))
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// parseClock parses "HH:MM" (or "24:00") into minutes after midnight.
+func parseClock(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (want HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w syncWindow) covers(relPath string) bool {
+	for _, path := range w.paths {
+		if relPath == path || strings.HasPrefix(relPath, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (w syncWindow) open(now time.Time) bool {
+	minute := now.Hour()*60 + now.Minute()
+	if w.start < w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+// SetIgnoreWindows lets push and pull sync paths whose sync window is
+// closed, for a sync the user asked for explicitly.
+func (s *Syncer) SetIgnoreWindows(ignore bool) {
+	s.ignoreWindows = ignore
+}
+
+// outsideWindow reports whether relPath has sync windows and none of them
+// is open at now.
+func (s *Syncer) outsideWindow(relPath string, now time.Time) bool {
+	if s.ignoreWindows {
+		return false
+	}
+	covered := false
+	for _, w := range s.windows {
+		if !w.covers(relPath) {
+			continue
+		}
+		if w.open(now) {
+			return false
+		}
+		covered = true
+	}
+	return covered
+}
+
+// dropOutsideWindows removes changes to paths whose sync window is closed,
+// returning those kept and the paths deferred.
+func (s *Syncer) dropOutsideWindows(changes []FileChange) (kept []FileChange, deferred []string) {
+	if len(s.windows) == 0 || s.ignoreWindows {
+		return changes, nil
+	}
+	now := time.Now()
+	for _, change := range changes {
+		if s.outsideWindow(change.Path, now) {
+			deferred = append(deferred, change.Path)
+			continue
+		}
+		kept = append(kept, change)
+	}
+	sort.Strings(deferred)
+	return kept, deferred
+}
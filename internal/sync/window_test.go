@@ -0,0 +1,132 @@
+package sync
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tawanorg/claude-sync/internal/config"
+)
+
+func TestSyncWindowOpen(t *testing.T) {
+	at := func(clock string) time.Time {
+		t, _ := time.Parse("15:04", clock)
+		return t
+	}
+	windows, err := parseSyncWindows([]config.SyncWindow{
+		{Paths: []string{"projects/"}, Start: "01:00", End: "06:00"},
+		{Paths: []string{"todos"}, Start: "22:00", End: "02:00"},
+	})
+	if err != nil {
+		t.Fatalf("parseSyncWindows: %v", err)
+	}
+	tests := []struct {
+		window int
+		clock  string
+		want   bool
+	}{
+		{0, "00:59", false},
+		{0, "01:00", true},
+		{0, "05:59", true},
+		{0, "06:00", false},
+		{1, "21:59", false},
+		{1, "23:30", true},
+		{1, "01:59", true},
+		{1, "02:00", false},
+	}
+	for _, tt := range tests {
+		if got := windows[tt.window].open(at(tt.clock)); got != tt.want {
+			t.Errorf("window %d open at %s = %v, want %v", tt.window, tt.clock, got, tt.want)
+		}
+	}
+	if !windows[0].covers("projects/a/b.jsonl") || windows[0].covers("projects2/x") || windows[0].covers("CLAUDE.md") {
+		t.Error("covers() matched the wrong paths")
+	}
+}
+
+func TestParseSyncWindowsErrors(t *testing.T) {
+	tests := []struct {
+		window config.SyncWindow
+		want   string
+	}{
+		{config.SyncWindow{Start: "01:00", End: "06:00"}, "lists no paths"},
+		{config.SyncWindow{Paths: []string{"projects"}, Start: "1am", End: "06:00"}, "invalid time"},
+		{config.SyncWindow{Paths: []string{"projects"}, Start: "01:00", End: "25:00"}, "invalid time"},
+		{config.SyncWindow{Paths: []string{"projects"}, Start: "01:00", End: "01:00"}, "is empty"},
+	}
+	for _, tt := range tests {
+		err := CheckSyncWindows([]config.SyncWindow{tt.window})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("CheckSyncWindows(%+v) = %v, want %q", tt.window, err, tt.want)
+		}
+	}
+}
+
+func TestSyncWindowsHoldBackPushAndPull(t *testing.T) {
+	env := setupTestEnv(t)
+	ctx := context.Background()
+
+	// projects/ only syncs in a window starting an hour from now
+	now := time.Now()
+	windows, err := parseSyncWindows([]config.SyncWindow{{
+		Paths: []string{"projects"},
+		Start: now.Add(time.Hour).Format("15:04"),
+		End:   now.Add(2 * time.Hour).Format("15:04"),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.syncer.windows = windows
+
+	writeFile(t, env.claudeDir, "projects/p/session.jsonl", "{}\n")
+	writeFile(t, env.claudeDir, "CLAUDE.md", "v1")
+	result, err := env.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if len(result.Uploaded) != 1 || result.Uploaded[0] != "CLAUDE.md" {
+		t.Errorf("Expected only CLAUDE.md pushed, got %v", result.Uploaded)
+	}
+	if len(result.Deferred) != 1 || result.Deferred[0] != "projects/p/session.jsonl" {
+		t.Errorf("Expected the session deferred, got %v", result.Deferred)
+	}
+	changes, err := env.syncer.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected no pending changes outside the window, got %+v", changes)
+	}
+
+	env.syncer.SetIgnoreWindows(true)
+	result, err = env.syncer.Push(ctx)
+	if err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if len(result.Uploaded) != 1 || len(result.Deferred) != 0 {
+		t.Errorf("Expected the session pushed with --ignore-windows, got %+v", result)
+	}
+
+	// Pull leaves the windowed path alone too
+	env.syncer.SetIgnoreWindows(false)
+	uploadRemote(t, env, "projects/p/other.jsonl", "{}\n")
+	result, err = env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.Downloaded) != 0 {
+		t.Errorf("Expected nothing pulled outside the window, got %v", result.Downloaded)
+	}
+
+	// Inside the window it syncs as usual
+	env.syncer.windows[0].start = 0
+	env.syncer.windows[0].end = 24 * 60
+	result, err = env.syncer.Pull(ctx)
+	if err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(result.Downloaded) != 1 {
+		t.Errorf("Expected the session pulled inside the window, got %v", result.Downloaded)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"runtime"
 	"strings"
+	"time"
 )
 
 // TruncatePath shortens a file path to maxLen characters,
@@ -35,6 +36,44 @@ func FormatSize(size int64) string {
 	}
 }
 
+// FormatRelativeTime describes t relative to now, e.g. "2 hours ago" or
+// "in 3 days".
+func FormatRelativeTime(t, now time.Time) string {
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	const day = 24 * time.Hour
+	var s string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		s = plural(int(d/time.Minute), "minute")
+	case d < day:
+		s = plural(int(d/time.Hour), "hour")
+	case d < 30*day:
+		s = plural(int(d/day), "day")
+	case d < 365*day:
+		s = plural(int(d/(30*day)), "month")
+	default:
+		s = plural(int(d/(365*day)), "year")
+	}
+	if future {
+		return "in " + s
+	}
+	return s + " ago"
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
 // CompareVersions performs a simple semver comparison.
 // Returns -1 if v1 < v2, 0 if equal, 1 if v1 > v2.
 func CompareVersions(v1, v2 string) int {
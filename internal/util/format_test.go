@@ -3,6 +3,7 @@ package util
 import (
 	"runtime"
 	"testing"
+	"time"
 )
 
 func TestTruncatePath(t *testing.T) {
@@ -60,6 +61,34 @@ func TestFormatSize(t *testing.T) {
 	}
 }
 
+func TestFormatRelativeTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		ago      time.Duration
+		expected string
+	}{
+		{"seconds", 30 * time.Second, "just now"},
+		{"one minute", time.Minute, "1 minute ago"},
+		{"minutes", 45 * time.Minute, "45 minutes ago"},
+		{"hours", 2*time.Hour + 10*time.Minute, "2 hours ago"},
+		{"one day", 25 * time.Hour, "1 day ago"},
+		{"days", 6 * 24 * time.Hour, "6 days ago"},
+		{"months", 65 * 24 * time.Hour, "2 months ago"},
+		{"years", 800 * 24 * time.Hour, "2 years ago"},
+		{"future", -3 * time.Hour, "in 3 hours"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FormatRelativeTime(now.Add(-tt.ago), now)
+			if result != tt.expected {
+				t.Errorf("FormatRelativeTime(-%v) = %q, want %q", tt.ago, result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestCompareVersions(t *testing.T) {
 	tests := []struct {
 		name     string